@@ -98,7 +98,7 @@ extract_and_log_metrics() {
 }
 
 create_dummy_tree() {
-    go run ./tools/mktree "$1" "$2"
+    "$TREEBALL_BIN" mktree "$1" "$2"
 }
 
 run_benchmarks() {
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidAge = errors.New("invalid age")
+
+// ageDateLayouts are the layouts of the dates accepted as ages (besides durations).
+var ageDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", time.DateOnly}
+
+// parseAge returns the point in time of an age (as for --newer-than), which is
+// either a duration before now (such as "30d", "2w" or "12h"), with days and
+// weeks in addition to the units of [time.ParseDuration], or a date (such as
+// "2024-01-31" or "2024-01-31T12:00:00Z", in local time unless given a zone).
+func parseAge(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	for _, layout := range ageDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	var unit time.Duration
+
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour //nolint:mnd
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour //nolint:mnd
+	}
+
+	if unit > 0 {
+		n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		if err == nil && n >= 0 && n <= int64(time.Duration(1<<62)/unit) {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	} else if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q (expected e.g. 30d, 12h or 2024-01-31)", errInvalidAge, s)
+}
+
+// sourceDateEpochEnvVar is the environment variable of the fixed time of
+// reproducible builds (see https://reproducible-builds.org/specs/source-date-epoch/).
+const sourceDateEpochEnvVar = "SOURCE_DATE_EPOCH"
+
+var errInvalidModTime = errors.New("invalid modification time")
+
+// parseModTime returns the point in time of a fixed modification time (as for
+// --mtime), which is either a number of seconds since the epoch prefixed with
+// an @ (such as "@0", as with SOURCE_DATE_EPOCH and GNU tar) or a date (as
+// with [parseAge], such as "2024-01-31T12:00:00Z"). The point in time is the
+// one of the epoch in UTC, as only whole seconds can be recorded in tarballs.
+func parseModTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if secs, ok := strings.CutPrefix(s, "@"); ok {
+		n, err := strconv.ParseInt(secs, 10, 64)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("%w: %q (expected @ followed by non-negative seconds)", errInvalidModTime, s)
+		}
+
+		return time.Unix(n, 0).UTC(), nil
+	}
+
+	for _, layout := range ageDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Truncate(time.Second).UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q (expected e.g. @0 or 2024-01-31)", errInvalidModTime, s)
+}
+
+// fixedModTime returns the fixed modification time of created entries (as
+// for --mtime, see [parseModTime]), which is otherwise the one of any non-empty
+// SOURCE_DATE_EPOCH (as seconds since the epoch), or zero for none at all.
+func fixedModTime(mtime string) (time.Time, error) {
+	if mtime != "" {
+		return parseModTime(mtime)
+	}
+
+	if epoch := strings.TrimSpace(os.Getenv(sourceDateEpochEnvVar)); epoch != "" {
+		t, err := parseModTime("@" + epoch)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w (of %s)", err, sourceDateEpochEnvVar)
+		}
+
+		return t, nil
+	}
+
+	return time.Time{}, nil
+}
+
+// parseAgeFilters returns the points in time of the ages of --newer-than and
+// --older-than (zero for any not given), relative to the current time.
+func parseAgeFilters(newerThan string, olderThan string) (time.Time, time.Time, error) {
+	var newer, older time.Time
+	var err error
+
+	now := time.Now()
+
+	if newerThan != "" {
+		if newer, err = parseAge(newerThan, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	if olderThan != "" {
+		if older, err = parseAge(olderThan, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	return newer, older, nil
+}
+
+// filtersAge returns if the files of walks are filtered by their age
+// (as set in [ProgramConfig.NewerThan] and [ProgramConfig.OlderThan]).
+func (prog *Program) filtersAge() bool {
+	return !prog.config.NewerThan.IsZero() || !prog.config.OlderThan.IsZero()
+}
+
+// matchesAge returns if a file was modified within the bounds of the
+// [ProgramConfig.NewerThan] and [ProgramConfig.OlderThan] (both exclusive).
+func (prog *Program) matchesAge(info fs.FileInfo) bool {
+	mtime := info.ModTime()
+
+	if !prog.config.NewerThan.IsZero() && !mtime.After(prog.config.NewerThan) {
+		return false
+	}
+
+	if !prog.config.OlderThan.IsZero() && !mtime.Before(prog.config.OlderThan) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// The ages of the files of the tree for the age filter tests.
+var ageTree = map[string]time.Duration{
+	"/src/new.txt":     time.Hour,
+	"/src/old/mid.txt": 10 * 24 * time.Hour,
+	"/src/old/old.txt": 100 * 24 * time.Hour,
+}
+
+// Expectation: The ages should be parsed as durations before now or as dates.
+func Test_parseAge_Table(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		age     string
+		want    time.Time
+		wantErr bool
+	}{
+		{"30d", now.Add(-30 * 24 * time.Hour), false},
+		{"2w", now.Add(-14 * 24 * time.Hour), false},
+		{"12h", now.Add(-12 * time.Hour), false},
+		{"1h30m", now.Add(-90 * time.Minute), false},
+		{"2024-01-31", time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local), false},
+		{"2024-01-31 08:00:00", time.Date(2024, 1, 31, 8, 0, 0, 0, time.Local), false},
+		{"2024-01-31T08:00:00Z", time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC), false},
+		{"", time.Time{}, true},
+		{"d", time.Time{}, true},
+		{"-5d", time.Time{}, true},
+		{"-5h", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+		{"2024-13-01", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.age, func(t *testing.T) {
+			got, err := parseAge(tt.age, now)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidAge)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, tt.want.Equal(got), "want %v, got %v", tt.want, got)
+		})
+	}
+}
+
+// Expectation: Only the files within the bounds of the ages should be archived, along with all directories.
+func Test_Program_Create_AgeFilters_Success(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		newerThan time.Duration
+		olderThan time.Duration
+		want      string
+	}{
+		{"newer", 7 * 24 * time.Hour, 0, "new.txt\nold\n"},
+		{"older", 0, 7 * 24 * time.Hour, "old\nold/mid.txt\nold/old.txt\n"},
+		{"window", 30 * 24 * time.Hour, 7 * 24 * time.Hour, "old\nold/mid.txt\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := newTreeFs(t, slices.Collect(maps.Keys(ageTree))...)
+			for p, age := range ageTree {
+				require.NoError(t, fs.Chtimes(p, now.Add(-age), now.Add(-age)))
+			}
+
+			config := &ProgramConfig{}
+			if tt.newerThan > 0 {
+				config.NewerThan = now.Add(-tt.newerThan)
+			}
+			if tt.olderThan > 0 {
+				config.OlderThan = now.Add(-tt.olderThan)
+			}
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, config)
+			require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+			require.Equal(t, tt.want, stdoutBuf.String())
+		})
+	}
+}
+
+// Expectation: Only the files within the bounds of the ages should be compared.
+func Test_Program_Check_AgeFilters_Success(t *testing.T) {
+	now := time.Now()
+	fs := newTreeFs(t, slices.Collect(maps.Keys(ageTree))...)
+	for p, age := range ageTree {
+		require.NoError(t, fs.Chtimes(p, now.Add(-age), now.Add(-age)))
+	}
+
+	require.NoError(t, afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{"new.txt", "old/"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{NewerThan: now.Add(-24 * time.Hour)})
+	_, err := prog.Check(t.Context(), "/snapshot.tar.gz", []string{"/src"}, nil)
+	require.NoError(t, err)
+}
+
+// Expectation: An invalid age should be rejected by the command.
+func Test_CLI_CreateCommand_InvalidAge_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"create", "/src", "/out.tar.gz", "--newer-than=soon"})
+
+	require.ErrorIs(t, cmd.Execute(), errInvalidAge)
+}
+
+// Expectation: The modification times should be parsed as seconds since the epoch or as dates.
+func Test_parseModTime_Table(t *testing.T) {
+	tests := []struct {
+		mtime   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"@0", time.Unix(0, 0), false},
+		{"@1700000000", time.Unix(1700000000, 0), false},
+		{"2024-01-31T08:00:00Z", time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC), false},
+		{"2024-01-31", time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local), false},
+		{"@", time.Time{}, true},
+		{"@-1", time.Time{}, true},
+		{"@1.5", time.Time{}, true},
+		{"30d", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mtime, func(t *testing.T) {
+			got, err := parseModTime(tt.mtime)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidModTime)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, tt.want.Equal(got), "want %v, got %v", tt.want, got)
+		})
+	}
+}
+
+// Expectation: The SOURCE_DATE_EPOCH should be used, unless a modification time is given.
+func Test_fixedModTime_SourceDateEpoch_Success(t *testing.T) {
+	t.Setenv(sourceDateEpochEnvVar, "1700000000")
+
+	got, err := fixedModTime("")
+	require.NoError(t, err)
+	require.True(t, time.Unix(1700000000, 0).Equal(got))
+
+	got, err = fixedModTime("@0")
+	require.NoError(t, err)
+	require.True(t, time.Unix(0, 0).Equal(got))
+
+	t.Setenv(sourceDateEpochEnvVar, "")
+
+	got, err = fixedModTime("")
+	require.NoError(t, err)
+	require.True(t, got.IsZero())
+
+	t.Setenv(sourceDateEpochEnvVar, "tomorrow")
+
+	_, err = fixedModTime("")
+	require.ErrorIs(t, err, errInvalidModTime)
+}
+
+// Expectation: Archives created with a fixed modification time should be byte-for-byte identical.
+func Test_Program_Create_ModTime_Success(t *testing.T) {
+	modTime := time.Unix(1700000000, 0).UTC()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{ModTime: modTime, Annotate: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/one.tar.gz", nil))
+
+	time.Sleep(time.Second) // The time of the creation would otherwise differ.
+
+	require.NoError(t, prog.Create(t.Context(), "/src", "/two.tar.gz", nil))
+
+	one, err := afero.ReadFile(fs, "/one.tar.gz")
+	require.NoError(t, err)
+
+	two, err := afero.ReadFile(fs, "/two.tar.gz")
+	require.NoError(t, err)
+
+	require.Equal(t, one, two)
+
+	gr, err := gzip.NewReader(bytes.NewReader(one))
+	require.NoError(t, err)
+	require.True(t, modTime.Equal(gr.ModTime))
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		if hdr.Typeflag != tar.TypeXGlobalHeader {
+			require.True(t, modTime.Equal(hdr.ModTime), "%s: %v", hdr.Name, hdr.ModTime)
+		}
+	}
+
+	prog = NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	annotations, err := prog.Show(t.Context(), "/one.tar.gz")
+	require.NoError(t, err)
+	require.Equal(t, "2023-11-14T22:13:20Z", annotations["created"])
+}
+
+// Expectation: An invalid modification time should be rejected by the command.
+func Test_CLI_CreateCommand_InvalidModTime_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"create", "/src", "/out.tar.gz", "--mtime=@soon"})
+
+	require.ErrorIs(t, cmd.Execute(), errInvalidModTime)
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// paxAnnotationPrefix is the prefix of the PAX records of the annotations of
+// archives, as a vendor-specific keyword (see [writeAnnotations]).
+const paxAnnotationPrefix = "TREEBALL."
+
+// paxGlobalHeaderName is the name of the PAX global header (as written by GNU tar).
+const paxGlobalHeaderName = "pax_global_header"
+
+// annotationKeys are the keys of the annotations written by [Program.Create],
+// in the order they are shown in by [Program.Show] (followed by any other keys).
+var annotationKeys = []string{"created", "hostname", "root", "version", "comment", "fingerprint"}
+
+// annotationTailSize is the size of the end of a tarball that is searched for
+// its trailing annotations (see [Program.trailingAnnotations]), which are only
+// a few blocks (compressed into even less), so that it is plenty for them.
+const annotationTailSize = 64 << 10
+
+// annotates returns if the program annotates created archives, which is either
+// with [ProgramConfig.Annotate] or with a [ProgramConfig.Comment] to annotate.
+func (prog *Program) annotates() bool {
+	return prog.config.Annotate || prog.config.Comment != ""
+}
+
+// annotations returns the annotations of an archive created from the tree at
+// input, being the time of its creation, the hostname, the absolute path of the
+// tree, the version of the program and any [ProgramConfig.Comment]. With a fixed
+// [ProgramConfig.ModTime], that is recorded as the time of the creation instead.
+func (prog *Program) annotations(input string) map[string]string {
+	created := time.Now()
+	if !prog.config.ModTime.IsZero() {
+		created = prog.config.ModTime
+	}
+
+	records := map[string]string{
+		"created": created.UTC().Format(time.RFC3339),
+		"version": Version,
+		"root":    input,
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		records["hostname"] = hostname
+	}
+
+	if abs, err := filepath.Abs(input); err == nil {
+		records["root"] = abs
+	}
+
+	if prog.config.Comment != "" {
+		records["comment"] = prog.config.Comment
+	}
+
+	return records
+}
+
+// writeAnnotations writes annotations (see [Program.annotations]) as the PAX
+// records of a global header, which is ignored by readers as being no entry.
+func writeAnnotations(tw *tar.Writer, annotations map[string]string) error {
+	records := make(map[string]string)
+	for key, value := range annotations {
+		records[paxAnnotationPrefix+key] = value
+	}
+
+	hdr := &tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		Name:       paxGlobalHeaderName,
+		PAXRecords: records,
+		Format:     tar.FormatPAX,
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write annotations: %w", err)
+	}
+
+	return nil
+}
+
+// headerAnnotations returns the annotations of a PAX global header (nil: none).
+func headerAnnotations(hdr *tar.Header) map[string]string {
+	var annotations map[string]string
+
+	for key, value := range hdr.PAXRecords {
+		if name, ok := strings.CutPrefix(key, paxAnnotationPrefix); ok {
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[name] = value
+		}
+	}
+
+	return annotations
+}
+
+// trailingAnnotations returns the annotations at the very end of a tarball
+// (nil: none), being the fingerprint of its entries (see [Program.writeTarball]).
+// These are written into the last gzip member of a tarball, so that they can be
+// found by decompressing only the end of it (see [readTrailingAnnotations]).
+// Tarballs that are not a (seekable) file, such as split ones, have none.
+func (prog *Program) trailingAnnotations(input string) map[string]string {
+	f, err := prog.fs.Open(input)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() < int64(len(gzipMagic)) {
+		return nil
+	}
+
+	magic := make([]byte, len(gzipMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		return nil
+	}
+
+	tail := make([]byte, min(info.Size(), annotationTailSize))
+	if _, err := f.ReadAt(tail, info.Size()-int64(len(tail))); err != nil {
+		return nil
+	}
+
+	return readTrailingAnnotations(tail, bytes.Equal(magic, gzipMagic))
+}
+
+// readTrailingAnnotations returns the annotations of a PAX global header that
+// is the last header within the tail of a tarball (nil: none). For a compressed
+// tarball, this header needs to be the only one within the last gzip member.
+// The possible starts of the header (or of the member) are tried from the end.
+func readTrailingAnnotations(tail []byte, compressed bool) map[string]string {
+	if !compressed {
+		// The tail ends at the end of the tarball, which is of whole blocks.
+		for i := len(tail) - tarBlockSize; i >= 0; i -= tarBlockSize {
+			if annotations := readLastAnnotations(bytes.NewReader(tail[i:])); annotations != nil {
+				return annotations
+			}
+		}
+
+		return nil
+	}
+
+	for i := len(tail) - len(gzipMagic); i >= 0; i-- {
+		if !bytes.HasPrefix(tail[i:], gzipMagic) {
+			continue
+		}
+
+		r := bytes.NewReader(tail[i:])
+
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			continue
+		}
+		zr.Multistream(false)
+
+		data, err := io.ReadAll(io.LimitReader(zr, annotationTailSize))
+		if err != nil || r.Len() > 0 {
+			continue // Not a (valid) member, or not the last one.
+		}
+
+		if annotations := readLastAnnotations(bytes.NewReader(data)); annotations != nil {
+			return annotations
+		}
+	}
+
+	return nil
+}
+
+// readLastAnnotations returns the annotations of a tar stream consisting of
+// only a PAX global header with annotations (nil: any other tar stream).
+func readLastAnnotations(r io.Reader) map[string]string {
+	tr := tar.NewReader(r)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Typeflag != tar.TypeXGlobalHeader {
+		return nil
+	}
+
+	if _, err := tr.Next(); !errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	return headerAnnotations(hdr)
+}
+
+// Show writes to standard output the annotations of a given tarball (as written
+// by [Program.Create] with [ProgramConfig.Annotate]), one "key: value" per line.
+//
+// The annotations are read from the PAX global headers preceding the entries
+// of the tarball, so that only its beginning needs to be read (and decompressed),
+// as well as from the end of the tarball (see [Program.trailingAnnotations]).
+// Any set fields of the gzip header follow them (see [printGzipHeader]).
+// The annotations are also returned, being empty for tarballs without any.
+// The ctx parameter controls early cancellation.
+func (prog *Program) Show(ctx context.Context, input string) (map[string]string, error) {
+	progressFrom(ctx).setPhase("showing %s", input)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRemotes()
+
+	f, err := prog.openArchive(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	ar, err := newArchiveReader(contextReader{ctx: ctx, r: f})
+	if err != nil {
+		return nil, err
+	}
+	defer ar.Close()
+
+	tr := tar.NewReader(ar)
+	annotations := make(map[string]string)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read from tar: %w", err)
+		}
+
+		if hdr.Typeflag != tar.TypeXGlobalHeader {
+			break
+		}
+
+		maps.Copy(annotations, headerAnnotations(hdr))
+	}
+
+	maps.Copy(annotations, prog.trailingAnnotations(input))
+
+	for _, key := range sortedAnnotationKeys(annotations) {
+		fmt.Fprintf(prog.stdout, "%s: %s\n", key, annotations[key])
+	}
+
+	hdr, err := prog.readGzipHeader(input)
+	if err != nil {
+		return nil, err
+	}
+	printGzipHeader(prog.stdout, hdr)
+
+	return annotations, nil
+}
+
+// sortedAnnotationKeys returns the keys of the annotations in the order of
+// [annotationKeys], followed by any other keys in lexicographic order.
+func sortedAnnotationKeys(annotations map[string]string) []string {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+
+	slices.SortFunc(keys, func(a, b string) int {
+		ia, ib := slices.Index(annotationKeys, a), slices.Index(annotationKeys, b)
+
+		switch {
+		case ia >= 0 && ib >= 0:
+			return ia - ib
+		case ia >= 0:
+			return -1
+		case ib >= 0:
+			return 1
+		}
+
+		return strings.Compare(a, b)
+	})
+
+	return keys
+}
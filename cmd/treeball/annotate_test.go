@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The annotations of a created archive should be shown in the fixed order.
+func Test_Program_Show_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Comment: "before the migration"})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	var stdoutBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	annotations, err := prog.Show(t.Context(), "/out.tar.gz")
+	require.NoError(t, err)
+
+	require.Equal(t, "before the migration", annotations["comment"])
+	require.Equal(t, Version, annotations["version"])
+	require.NotEmpty(t, annotations["root"])
+
+	created, err := time.Parse(time.RFC3339, annotations["created"])
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), created, time.Minute)
+
+	lines := bytes.Split(bytes.TrimSpace(stdoutBuf.Bytes()), []byte("\n"))
+	require.Len(t, lines, len(annotations))
+	require.True(t, bytes.HasPrefix(lines[0], []byte("created: ")))
+	require.Equal(t, "comment: before the migration", string(lines[len(lines)-2]))
+	require.True(t, bytes.HasPrefix(lines[len(lines)-1], []byte("fingerprint: ")))
+}
+
+// Expectation: An archive without annotations should show nothing.
+func Test_Program_Show_NoAnnotations_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	annotations, err := prog.Show(t.Context(), "/input.tar.gz")
+	require.NoError(t, err)
+
+	require.Empty(t, annotations)
+	require.Empty(t, stdoutBuf.String())
+}
+
+// Expectation: The annotations should not be listed or verified as entries.
+func Test_Program_Create_Annotate_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Annotate: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	require.Equal(t, []string{paxGlobalHeaderName, "a.txt", "b/", "b/c.txt", paxGlobalHeaderName}, readTarNames(t, fs, "/out.tar.gz"))
+
+	var stdoutBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{ListFormat: ListLong})
+	require.NoError(t, prog.List(t.Context(), "/out.tar.gz", false, nil))
+	require.NotContains(t, stdoutBuf.String(), paxGlobalHeaderName)
+
+	stdoutBuf.Reset()
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Verify(t.Context(), "/out.tar.gz"))
+	require.Contains(t, stdoutBuf.String(), "entries: 3")
+}
+
+// Expectation: The annotations should not be written in tar formats without PAX records.
+func Test_Program_Create_AnnotateFormat_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Annotate: true, TarFormat: tar.FormatUSTAR})
+	require.ErrorContains(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil), "not representable in the ustar format")
+}
+
+// Expectation: The keys should be in the order of the known keys, followed by any others.
+func Test_sortedAnnotationKeys_Success(t *testing.T) {
+	annotations := map[string]string{"zeta": "", "comment": "", "alpha": "", "created": "", "root": ""}
+
+	require.Equal(t, []string{"created", "root", "comment", "alpha", "zeta"}, sortedAnnotationKeys(annotations))
+}
+
+// Expectation: The fingerprint should be that of the entries, as computed by the hash of the tree.
+func Test_Program_Create_Fingerprint_Table(t *testing.T) {
+	tests := []struct {
+		name        string
+		level       int
+		memberEvery int
+	}{
+		{"Compressed", gzip.DefaultCompression, 0},
+		{"Uncompressed", gzip.NoCompression, 0},
+		{"Members", gzip.BestSpeed, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "/src/a/b.txt", []byte("b"), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "/src/a-b/c.txt", []byte("c"), 0o644))
+
+			gzipConfig := gzipConfigDefault
+			gzipConfig.CompressionLevel = tt.level
+
+			prog := NewProgram(fs, io.Discard, io.Discard, &gzipConfig, nil, &ProgramConfig{Annotate: true, MemberEvery: tt.memberEvery})
+			require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+			var stdoutBuf bytes.Buffer
+
+			prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+			sum, err := prog.Hash(t.Context(), []string{"/src"}, ChecksumSHA256, nil)
+			require.NoError(t, err)
+
+			require.Equal(t, hex.EncodeToString(sum), prog.trailingAnnotations("/out.tar.gz")["fingerprint"])
+		})
+	}
+}
+
+// Expectation: Tarballs without trailing annotations should have no fingerprint.
+func Test_Program_trailingAnnotations_None_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/empty.tar.gz", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	require.Nil(t, prog.trailingAnnotations("/input.tar.gz"))
+	require.Nil(t, prog.trailingAnnotations("/empty.tar.gz"))
+	require.Nil(t, prog.trailingAnnotations("/missing.tar.gz"))
+}
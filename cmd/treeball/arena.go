@@ -0,0 +1,45 @@
+package main
+
+import "unsafe"
+
+const (
+	pathArenaBlockSize = 16 << 10                // Bytes of the blocks of a [pathArena]
+	pathArenaMaxString = pathArenaBlockSize >> 3 // Bytes of the largest strings held by a [pathArena]
+)
+
+// pathArena holds the strings of streamed paths in shared blocks, rather than
+// each of them in an allocation of its own. The external sort holds up to a
+// chunk of paths per worker, all of which the garbage collector has to mark on
+// every cycle, which for individually allocated paths is its dominant cost on
+// massive trees. Any of the (few) blocks is kept alive by any of its strings,
+// so consumers keeping only some of the paths around should clone them.
+//
+// A pathArena is not safe for concurrent use, as it is meant for a single stream.
+type pathArena struct {
+	block []byte
+}
+
+// join returns the string of s with the suffix appended, as held by the arena.
+// Any strings too large to share a block are still allocated on their own.
+func (a *pathArena) join(s string, suffix string) string {
+	n := len(s) + len(suffix)
+
+	if n == 0 {
+		return ""
+	}
+
+	if n > pathArenaMaxString {
+		return s + suffix
+	}
+
+	if cap(a.block)-len(a.block) < n {
+		a.block = make([]byte, 0, pathArenaBlockSize)
+	}
+
+	start := len(a.block)
+	a.block = append(a.block, s...)
+	a.block = append(a.block, suffix...)
+
+	// The bytes are never modified after, as the block is only ever appended to.
+	return unsafe.String(&a.block[start], n)
+}
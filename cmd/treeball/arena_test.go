@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The strings should be joined with their suffixes, sharing the blocks of the arena.
+func Test_pathArena_join_Success(t *testing.T) {
+	var arena pathArena
+
+	a := arena.join("a/b", "/")
+	b := arena.join("a/b/c.txt", "")
+
+	require.Equal(t, "a/b/", a)
+	require.Equal(t, "a/b/c.txt", b)
+	require.Same(t, unsafe.StringData(a), &arena.block[0])
+	require.Same(t, unsafe.StringData(b), &arena.block[len(a)])
+	require.Empty(t, arena.join("", ""))
+}
+
+// Expectation: A new block should be started once full, without modifying the strings of the previous one.
+func Test_pathArena_join_NewBlock_Success(t *testing.T) {
+	var arena pathArena
+
+	name := strings.Repeat("x", pathArenaMaxString)
+
+	var got []string
+	for range pathArenaBlockSize/pathArenaMaxString + 1 {
+		got = append(got, arena.join(name, ""))
+	}
+
+	for _, s := range got {
+		require.Equal(t, name, s)
+	}
+
+	require.Equal(t, pathArenaMaxString, len(arena.block))
+}
+
+// Expectation: Any strings too large to share a block should be allocated on their own.
+func Test_pathArena_join_Large_Success(t *testing.T) {
+	var arena pathArena
+
+	name := strings.Repeat("x", pathArenaMaxString)
+
+	require.Equal(t, name+"/", arena.join(name, "/"))
+	require.Nil(t, arena.block)
+}
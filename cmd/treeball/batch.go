@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/lanrat/extsort"
+	"github.com/lanrat/extsort/diff"
+	"github.com/spf13/afero"
+)
+
+const (
+	pairFieldsMin = 2
+	pairFieldsMax = 3
+)
+
+var errInvalidPair = errors.New("invalid pair")
+
+// DiffPair is a pair of sources to compare, as consumed by [Program.DiffBatch].
+type DiffPair struct {
+	Old    string // Path of the old source (directory or tarball)
+	New    string // Path of the new source (directory or tarball)
+	Output string // Path of the diff tarball to create (empty: none)
+}
+
+// pairResult is the result of one [DiffPair] of a [Program.DiffBatch] operation.
+type pairResult struct {
+	report afero.File   // Temporary file holding the printed differences
+	result *diff.Result // Result of the comparison (nil: failure)
+	err    error        // Error of the comparison (as from [Program.Diff])
+}
+
+// DiffBatch compares many pairs of sources within a single invocation.
+//
+// Each of the pairs is compared as with [Program.Diff], with up to as many
+// pairs compared concurrently as there are sorting workers configured, which
+// are shared between the concurrent pairs (as is the temporary directory). The
+// differences of each pair are collected in a temporary file, and printed as
+// one consolidated report (in the order of the pairs) once all are compared.
+// Any paths matching the excludes slice are skipped for all of the pairs. The
+// ctx parameter controls early cancellation.
+//
+// This function returns:
+//   - ErrDiffsFound: if any differences are found for any pair
+//   - nil: if all the pairs are identical (no output files)
+//   - ErrEntriesSkipped: if any entries were skipped (joined with the above)
+//   - error: if the comparison of any pair failed (for any reason)
+func (prog *Program) DiffBatch(ctx context.Context, pairs []DiffPair, excludes []string) error {
+	concurrency := max(1, min(len(pairs), prog.extSortConfig.NumWorkers))
+
+	sorterCfg := *prog.extSortConfig
+	sorterCfg.NumWorkers = max(1, prog.extSortConfig.NumWorkers/concurrency)
+
+	results := make([]pairResult, len(pairs))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, pair := range pairs {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer recoverWorker(ctx)
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i].err = fmt.Errorf("failed to start diff: %w", ctx.Err())
+
+				return
+			}
+			defer func() { <-sem }()
+
+			results[i] = prog.diffPair(ctx, pair, &sorterCfg, excludes)
+		}()
+	}
+
+	wg.Wait()
+
+	defer func() {
+		for _, r := range results {
+			if r.report != nil {
+				_ = r.report.Close()
+				_ = prog.fs.Remove(r.report.Name())
+			}
+		}
+	}()
+
+	return prog.printBatchReport(pairs, results)
+}
+
+// diffPair compares a single [DiffPair] of a [Program.DiffBatch] operation.
+func (prog *Program) diffPair(ctx context.Context, pair DiffPair, sorterCfg *extsort.Config, excludes []string) pairResult {
+	report, err := afero.TempFile(prog.fs, sorterCfg.TempFilesDir, "treeball-batch-*")
+	if err != nil {
+		return pairResult{err: fmt.Errorf("failed to create report file: %w", err)}
+	}
+
+	pairProg := *prog
+	pairProg.stdout = report
+	pairProg.extSortConfig = sorterCfg
+
+	result, err := pairProg.Diff(ctx, pair.Old, pair.New, pair.Output, excludes)
+
+	return pairResult{report: report, result: result, err: err}
+}
+
+// printBatchReport prints the consolidated report of a [Program.DiffBatch]
+// operation and returns the overall result (as documented there).
+func (prog *Program) printBatchReport(pairs []DiffPair, results []pairResult) error {
+	var failures []error
+	var differing int
+	var skipped bool
+
+	for i, r := range results {
+		prog.printPath(fmt.Sprintf("=== %s -> %s", pairs[i].Old, pairs[i].New))
+
+		if r.report != nil {
+			if _, err := r.report.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to read report file: %w", err)
+			}
+
+			if _, err := io.Copy(prog.stdout, r.report); err != nil {
+				return fmt.Errorf("failed to read report file: %w", err)
+			}
+		}
+
+		if r.result == nil {
+			prog.printPath(fmt.Sprintf("=== failed: %v", r.err))
+			failures = append(failures, fmt.Errorf("%s -> %s: %w", pairs[i].Old, pairs[i].New, r.err))
+
+			continue
+		}
+
+		prog.printPath(fmt.Sprintf("=== removed: %d, added: %d", r.result.ExtraA, r.result.ExtraB))
+
+		if r.result.ExtraA > 0 || r.result.ExtraB > 0 {
+			differing++
+		}
+
+		if errors.Is(r.err, ErrEntriesSkipped) {
+			skipped = true
+		}
+	}
+
+	prog.printPath(fmt.Sprintf("=== pairs: %d, differing: %d, failed: %d", len(pairs), differing, len(failures)))
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to diff %d of %d pairs: %w", len(failures), len(pairs), errors.Join(failures...))
+	}
+
+	var err error
+	if differing > 0 {
+		err = ErrDiffsFound
+	}
+
+	if skipped {
+		return errors.Join(err, ErrEntriesSkipped)
+	}
+
+	return err
+}
+
+// readPairs reads the pairs of a [Program.DiffBatch] operation from a file.
+// Each line holds the old source, new source and optional output path (all
+// tab-separated), while any empty lines and lines starting with a '#' are ignored.
+func (prog *Program) readPairs(path string) ([]DiffPair, error) {
+	file, err := prog.fs.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pairs file: %w", err)
+	}
+	defer file.Close()
+
+	var pairs []DiffPair
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < pairFieldsMin || len(fields) > pairFieldsMax {
+			return nil, fmt.Errorf("%w: line %d: expected <old>, <new> and optional <diff.tar.gz> (tab-separated)", errInvalidPair, lineNum)
+		}
+
+		pair := DiffPair{Old: fields[0], New: fields[1]}
+		if len(fields) == pairFieldsMax {
+			pair.Output = fields[2]
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading pairs file: %w", err)
+	}
+
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("%w: no pairs in %s", errInvalidPair, path)
+	}
+
+	return pairs, nil
+}
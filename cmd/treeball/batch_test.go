@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: All pairs should be compared and printed as one consolidated report in order.
+func Test_Program_DiffBatch_DiffsFound_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/1-old.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/1-new.tar.gz", createTar([]string{"a.txt", "c.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/2-old.tar.gz", createTar([]string{"x.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/2-new.tar.gz", createTar([]string{"x.txt"}), 0o644))
+
+	pairs := []DiffPair{
+		{Old: "/1-old.tar.gz", New: "/1-new.tar.gz", Output: "/1-diff.tar.gz"},
+		{Old: "/2-old.tar.gz", New: "/2-new.tar.gz", Output: "/2-diff.tar.gz"},
+	}
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	err := prog.DiffBatch(t.Context(), pairs, nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	want := "=== /1-old.tar.gz -> /1-new.tar.gz\n" +
+		"--- b.txt\n" +
+		"+++ c.txt\n" +
+		"=== removed: 1, added: 1\n" +
+		"=== /2-old.tar.gz -> /2-new.tar.gz\n" +
+		"=== removed: 0, added: 0\n" +
+		"=== pairs: 2, differing: 1, failed: 0\n"
+	require.Equal(t, want, stdoutBuf.String())
+
+	require.Equal(t, []string{"---/b.txt", "+++/c.txt"}, readTarNames(t, fs, "/1-diff.tar.gz"))
+
+	_, err = fs.Stat("/2-diff.tar.gz")
+	require.Error(t, err)
+}
+
+// Expectation: Identical pairs should return no error.
+func Test_Program_DiffBatch_NoDiffsFound_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	pairs := []DiffPair{
+		{Old: "/old.tar.gz", New: "/new.tar.gz", Output: "/1-diff.tar.gz"},
+		{Old: "/new.tar.gz", New: "/old.tar.gz", Output: "/2-diff.tar.gz"},
+	}
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.DiffBatch(t.Context(), pairs, nil))
+}
+
+// Expectation: A failing pair should fail the batch, but not prevent the other pairs.
+func Test_Program_DiffBatch_PairFailed_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"b.txt"}), 0o644))
+
+	pairs := []DiffPair{
+		{Old: "/missing.tar.gz", New: "/new.tar.gz", Output: "/1-diff.tar.gz"},
+		{Old: "/old.tar.gz", New: "/new.tar.gz", Output: "/2-diff.tar.gz"},
+	}
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	err := prog.DiffBatch(t.Context(), pairs, nil)
+	require.ErrorContains(t, err, "failed to diff 1 of 2 pairs")
+	require.NotErrorIs(t, err, ErrDiffsFound)
+	require.Equal(t, exitCodeFailure, exitCodeFor(err))
+
+	require.Contains(t, stdoutBuf.String(), "=== pairs: 2, differing: 1, failed: 1\n")
+
+	_, err = fs.Stat("/2-diff.tar.gz")
+	require.NoError(t, err)
+}
+
+// Expectation: The pairs should be read from a file, ignoring empty lines and comments.
+func Test_Program_readPairs_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	content := "# nightly snapshots\n/a old.tar.gz\t/mnt/a\t/a diff.tar.gz\n\n/b.tar.gz\t/mnt/b\n"
+	require.NoError(t, afero.WriteFile(fs, "/pairs.txt", []byte(content), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	pairs, err := prog.readPairs("/pairs.txt")
+	require.NoError(t, err)
+
+	require.Equal(t, []DiffPair{
+		{Old: "/a old.tar.gz", New: "/mnt/a", Output: "/a diff.tar.gz"},
+		{Old: "/b.tar.gz", New: "/mnt/b"},
+	}, pairs)
+}
+
+// Expectation: An invalid pairs file should raise the appropriate error.
+func Test_Program_readPairs_Error(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{"Missing field", "/a.tar.gz\n", "line 1"},
+		{"Extra field", "# comment\n/a.tar.gz\t/mnt/a\t/a-diff.tar.gz\t/extra\n", "line 2"},
+		{"No pairs", "# comment\n\n", "no pairs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/pairs.txt", []byte(tt.content), 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+			_, err := prog.readPairs("/pairs.txt")
+			require.ErrorIs(t, err, errInvalidPair)
+			require.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
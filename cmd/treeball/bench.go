@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/desertwitch/treeball/internal/mktree"
+	"github.com/spf13/afero"
+)
+
+var errInvalidFileCount = errors.New("invalid file count")
+
+// BenchStage is the measured result of one stage of a [Program.Bench] operation.
+type BenchStage struct {
+	Name     string        // Name of the stage (mktree, create, list, diff)
+	Entries  int64         // Entries processed by the stage
+	Duration time.Duration // Duration of the stage
+}
+
+// Throughput returns the entries processed per second of the [BenchStage].
+func (s BenchStage) Throughput() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+
+	return float64(s.Entries) / s.Duration.Seconds()
+}
+
+// Bench measures the throughput of the operations with the program's configurations.
+//
+// A synthetic tree of the given amount of files is generated (as with the
+// mktree command) in a temporary directory within the temporary directory
+// of the sorting. A tarball is then created from the tree, listed in sorted
+// order, and compared against the tree, with the throughput of each stage
+// printed to standard output. This allows for the sizing of the concurrency
+// and sorting configurations for the hardware at hand. All of the generated
+// files are removed again. The ctx parameter controls early cancellation.
+func (prog *Program) Bench(ctx context.Context, files int) ([]BenchStage, error) {
+	if files <= 0 {
+		return nil, fmt.Errorf("%w: %d", errInvalidFileCount, files)
+	}
+
+	base, err := afero.TempDir(prog.fs, prog.extSortConfig.TempFilesDir, "treeball-bench-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bench directory: %w", err)
+	}
+	defer prog.fs.RemoveAll(base) //nolint:errcheck
+
+	tree := filepath.Join(base, "tree")
+	tarball := filepath.Join(base, "tree.tar.gz")
+
+	quiet := *prog
+	quiet.stdout = io.Discard
+
+	var stages []BenchStage
+
+	report := func(name string, entries int64, duration time.Duration) {
+		s := BenchStage{Name: name, Entries: entries, Duration: duration}
+		stages = append(stages, s)
+
+		fmt.Fprintf(prog.stdout, "%s: %d entries in %s (%.0f entries/s)\n",
+			s.Name, s.Entries, s.Duration.Round(time.Millisecond), s.Throughput())
+	}
+
+	progressFrom(ctx).setPhase("generating a tree of %d files in %s", files, tree)
+
+	start := time.Now()
+	if err := mktree.CreateTree(ctx, prog.fs, tree, files, nil); err != nil {
+		return stages, fmt.Errorf("failed to bench mktree: %w", err)
+	}
+	mktreeTime := time.Since(start)
+
+	start = time.Now()
+	if err := quiet.Create(ctx, tree, tarball, nil); err != nil {
+		return stages, fmt.Errorf("failed to bench create: %w", err)
+	}
+	createTime := time.Since(start)
+
+	// The entries are counted from the tarball (unmeasured), as to include the directories.
+	count, err := quiet.Count(ctx, tarball, nil)
+	if err != nil {
+		return stages, fmt.Errorf("failed to count entries: %w", err)
+	}
+	entries := count.Files + count.Directories
+
+	report("mktree", entries, mktreeTime)
+	report("create", entries, createTime)
+
+	start = time.Now()
+	if err := quiet.List(ctx, tarball, true, nil); err != nil {
+		return stages, fmt.Errorf("failed to bench list: %w", err)
+	}
+	report("list", entries, time.Since(start))
+
+	start = time.Now()
+	if _, err := quiet.Diff(ctx, tarball, tree, "", nil); err != nil {
+		return stages, fmt.Errorf("failed to bench diff: %w", err)
+	}
+	report("diff", entries, time.Since(start))
+
+	return stages, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: All the stages should be measured and printed, with the generated files removed.
+func Test_Program_Bench_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	var stdout bytes.Buffer
+
+	prog := NewProgram(fs, &stdout, io.Discard, nil, nil, nil)
+
+	stages, err := prog.Bench(t.Context(), 250)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(stages))
+	for _, s := range stages {
+		names = append(names, s.Name)
+		require.Equal(t, int64(250+3+3), s.Entries) // Files, group and higher-level directories.
+	}
+	require.Equal(t, []string{"mktree", "create", "list", "diff"}, names)
+
+	require.Contains(t, stdout.String(), "create: 256 entries in ")
+	require.Contains(t, stdout.String(), "entries/s)\n")
+
+	leftovers, err := afero.Glob(fs, "/tmp/treeball-bench-*")
+	require.NoError(t, err)
+	require.Empty(t, leftovers)
+}
+
+// Expectation: An invalid amount of files should be rejected.
+func Test_Program_Bench_InvalidFileCount_Error(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Bench(t.Context(), 0)
+	require.ErrorIs(t, err, errInvalidFileCount)
+}
+
+// Expectation: A cancellation should stop the benchmark, with the generated files removed.
+func Test_Program_Bench_CtxCancel_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err := prog.Bench(ctx, 250)
+	require.ErrorIs(t, err, context.Canceled)
+
+	leftovers, err := afero.Glob(fs, "/tmp/treeball-bench-*")
+	require.NoError(t, err)
+	require.Empty(t, leftovers)
+}
+
+// Expectation: The throughput should be derived from the entries and duration.
+func Test_BenchStage_Throughput_Table(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage BenchStage
+		want  float64
+	}{
+		{"regular", BenchStage{Entries: 1000, Duration: 2e9}, 500},
+		{"no duration", BenchStage{Entries: 1000}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.InDelta(t, tt.want, tt.stage.Throughput(), 0.001)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"lukechampine.com/blake3"
+)
+
+// ChecksumAlgo is an algorithm of the checksums of created archives.
+type ChecksumAlgo int
+
+const (
+	// ChecksumNone writes no checksums.
+	ChecksumNone ChecksumAlgo = iota
+
+	// ChecksumSHA256 writes SHA-256 checksums (as sha256sum).
+	ChecksumSHA256
+
+	// ChecksumBLAKE3 writes BLAKE3 checksums (as b3sum).
+	ChecksumBLAKE3
+)
+
+var errInvalidChecksumAlgo = errors.New("invalid checksum algorithm")
+
+// parseChecksumAlgo returns the [ChecksumAlgo] for an algorithm name (as for --checksum).
+func parseChecksumAlgo(name string) (ChecksumAlgo, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return ChecksumNone, nil
+	case "sha256":
+		return ChecksumSHA256, nil
+	case "blake3":
+		return ChecksumBLAKE3, nil
+	default:
+		return ChecksumNone, fmt.Errorf("%w: %q (expected sha256 or blake3)", errInvalidChecksumAlgo, name)
+	}
+}
+
+// String returns the name of the [ChecksumAlgo].
+func (a ChecksumAlgo) String() string {
+	switch a {
+	case ChecksumSHA256:
+		return "sha256"
+	case ChecksumBLAKE3:
+		return "blake3"
+	default:
+		return "none"
+	}
+}
+
+// newHash returns a new [hash.Hash] of the [ChecksumAlgo] (nil: [ChecksumNone]).
+func (a ChecksumAlgo) newHash() hash.Hash {
+	switch a {
+	case ChecksumSHA256:
+		return sha256.New()
+	case ChecksumBLAKE3:
+		return blake3.New(32, nil) //nolint:mnd
+	default:
+		return nil
+	}
+}
+
+// checksumPath returns the path of the checksum file of an archive.
+func checksumPath(archive string, algo ChecksumAlgo) string {
+	return archive + "." + algo.String()
+}
+
+// checksumWriter returns the writer to write an output file through, so that
+// its checksum is computed along the way (as set in [ProgramConfig.Checksum]),
+// along with the [hash.Hash] to sum up at the end (nil: no checksum to compute).
+func (prog *Program) checksumWriter(w io.Writer) (io.Writer, hash.Hash) {
+	h := prog.config.Checksum.newHash()
+	if h == nil {
+		return w, nil
+	}
+
+	return io.MultiWriter(w, h), h
+}
+
+// writeChecksum writes the checksum of an archive alongside it (see [checksumPath]),
+// in the format of sha256sum (or b3sum), so that it can be checked by either of
+// them from within the directory of the archive. The checksum is also printed to
+// standard error. The checksum file is protected as the output files (see
+// [Program.createOutput]). The ctx parameter controls early cancellation.
+func (prog *Program) writeChecksum(ctx context.Context, archive string, sum []byte) error {
+	digest := hex.EncodeToString(sum)
+
+	out, err := prog.createOutput(ctx, checksumPath(archive, prog.config.Checksum))
+	if err != nil {
+		return fmt.Errorf("failed to create checksum file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := fmt.Fprintf(out, "%s  %s\n", digest, filepath.Base(archive)); err != nil {
+		return fmt.Errorf("failed to write checksum file: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close checksum file: %w", err)
+	}
+
+	prog.infof("%s: %s", prog.config.Checksum, digest)
+
+	return nil
+}
+
+// sumFile returns the checksum of the file at a path (as of [ProgramConfig.Checksum]),
+// for any files not written through a [Program.checksumWriter] in their entirety.
+func (prog *Program) sumFile(ctx context.Context, p string) ([]byte, error) {
+	f, err := prog.openArchive(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for checksum: %w", err)
+	}
+	defer f.Close()
+
+	h := prog.config.Checksum.newHash()
+	if _, err := io.Copy(h, contextReader{ctx: ctx, r: f}); err != nil {
+		return nil, fmt.Errorf("failed to compute checksum: %w", err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// writeSidecars writes the checksum (see [Program.writeChecksum]) and the detached
+// signature (see [Program.writeSignature]) of a fully written and closed output
+// file alongside it, as far as these are enabled. The h parameter is the hash of
+// the [Program.checksumWriter] the output was written through (nil: re-read).
+func (prog *Program) writeSidecars(ctx context.Context, output string, h hash.Hash, signer archiveSigner) error {
+	if prog.config.Checksum != ChecksumNone {
+		var sum []byte
+
+		if h != nil {
+			sum = h.Sum(nil)
+		} else {
+			var err error
+			if sum, err = prog.sumFile(ctx, output); err != nil {
+				return err
+			}
+		}
+
+		if err := prog.writeChecksum(ctx, output, sum); err != nil {
+			return err
+		}
+	}
+
+	if signer != nil {
+		if err := prog.writeSignature(ctx, signer, output); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
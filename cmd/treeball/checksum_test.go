@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"lukechampine.com/blake3"
+)
+
+// Expectation: The checksum algorithm names should be parsed into their algorithms.
+func Test_parseChecksumAlgo_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ChecksumAlgo
+		wantErr bool
+	}{
+		{"", ChecksumNone, false},
+		{"none", ChecksumNone, false},
+		{"SHA256", ChecksumSHA256, false},
+		{"blake3", ChecksumBLAKE3, false},
+		{"md5", ChecksumNone, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChecksumAlgo(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidChecksumAlgo)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The checksum of a created archive should be written alongside it and printed.
+func Test_Program_Create_Checksum_Table(t *testing.T) {
+	tests := []struct {
+		algo ChecksumAlgo
+		sum  func(data []byte) string
+	}{
+		{ChecksumSHA256, func(data []byte) string { s := sha256.Sum256(data); return hex.EncodeToString(s[:]) }},
+		{ChecksumBLAKE3, func(data []byte) string { s := blake3.Sum256(data); return hex.EncodeToString(s[:]) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algo.String(), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+
+			var stderrBuf bytes.Buffer
+
+			prog := NewProgram(fs, io.Discard, &stderrBuf, nil, nil, &ProgramConfig{Checksum: tt.algo})
+			require.NoError(t, prog.Create(t.Context(), "/src", "/out/archive.tar.gz", nil))
+
+			data, err := afero.ReadFile(fs, "/out/archive.tar.gz")
+			require.NoError(t, err)
+			digest := tt.sum(data)
+
+			sidecar, err := afero.ReadFile(fs, "/out/archive.tar.gz."+tt.algo.String())
+			require.NoError(t, err)
+			require.Equal(t, digest+"  archive.tar.gz\n", string(sidecar))
+			require.Contains(t, stderrBuf.String(), tt.algo.String()+": "+digest+"\n")
+		})
+	}
+}
+
+// Expectation: The checksum of a resumed creation should be that of the entire archive.
+func Test_Program_Create_ChecksumResumed_Success(t *testing.T) {
+	fs := newTreeFs(t, resumeTree...)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{CheckpointEvery: 2})
+	prog.fsWalker = failingPathWalker{walker: prog.fsWalker, path: "/src/b/f.txt"}
+
+	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorContains(t, err, "simulated permission denied")
+
+	prog = NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{CheckpointEvery: 2, Resume: true, Checksum: ChecksumSHA256})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	data, err := afero.ReadFile(fs, "/out.tar.gz")
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+
+	sidecar, err := afero.ReadFile(fs, "/out.tar.gz.sha256")
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(sum[:])+"  out.tar.gz\n", string(sidecar))
+}
+
+// Expectation: The checksum of a diff tarball should be written alongside it.
+func Test_Program_Diff_Checksum_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"b.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Checksum: ChecksumSHA256})
+
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	data, err := afero.ReadFile(fs, "/diff.tar.gz")
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+
+	sidecar, err := afero.ReadFile(fs, "/diff.tar.gz.sha256")
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(sum[:])+"  diff.tar.gz\n", string(sidecar))
+}
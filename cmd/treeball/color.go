@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ColorMode is the colorization of the printed output (as for --color).
+type ColorMode int
+
+const (
+	// ColorAuto colorizes the output only if it is a terminal (and $NO_COLOR is
+	// not set). It is resolved by the commands as of their standard output (see
+	// [ColorMode.resolve]), while any unresolved output is left uncolored.
+	ColorAuto ColorMode = iota
+
+	// ColorAlways colorizes the output, regardless of where it is written to.
+	ColorAlways
+
+	// ColorNever does not colorize the output.
+	ColorNever
+)
+
+const (
+	colorEnvVar = "NO_COLOR"
+
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorBlue   = "\x1b[1;34m"
+	colorDim    = "\x1b[2m"
+)
+
+var errInvalidColorMode = errors.New("invalid color mode")
+
+// parseColorMode returns the [ColorMode] for a mode name (as for --color).
+func parseColorMode(name string) (ColorMode, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return ColorAuto, nil
+	case "always":
+		return ColorAlways, nil
+	case "never":
+		return ColorNever, nil
+	default:
+		return ColorAuto, fmt.Errorf("%w: %q (expected auto, always or never)", errInvalidColorMode, name)
+	}
+}
+
+// resolve returns the [ColorMode] for output written to w, which is either
+// [ColorAlways] or [ColorNever] (as [ColorAuto] is resolved as per w). It is
+// to be called on the standard output before any pager is started, as the
+// pager (with "less -R") passes the colors through to the terminal.
+func (m ColorMode) resolve(w io.Writer) ColorMode {
+	if m != ColorAuto {
+		return m
+	}
+
+	if _, ok := os.LookupEnv(colorEnvVar); ok || !isTerminal(w) {
+		return ColorNever
+	}
+
+	return ColorAlways
+}
+
+// colored returns the text in the given color, if the output of the program
+// is colorized at all (as of [ProgramConfig.Color]), otherwise the text as-is.
+func (prog *Program) colored(color string, text string) string {
+	if prog.config.Color != ColorAlways {
+		return text
+	}
+
+	return color + text + colorReset
+}
+
+// formatPath returns a path as it is to be printed, quoted as per [Program.quote]
+// and colored as a directory, if it is one (as by its trailing slash).
+func (prog *Program) formatPath(path string) string {
+	if !strings.HasSuffix(path, "/") {
+		return prog.quote(path)
+	}
+
+	return prog.colored(colorBlue, prog.quote(path))
+}
+
+// infof prints an informational message to standard error, unless quiet.
+// Any buffered standard output is flushed first (see [bufferOutput]), so that
+// both stay in order when written to the same file (as with 2>&1).
+func (prog *Program) infof(format string, args ...any) {
+	if prog.config.Quiet {
+		return
+	}
+
+	_ = prog.flushOutput() // Any error is returned again by the final flush.
+	fmt.Fprintf(prog.stderr, format+"\n", args...)
+}
+
+// warnf prints a warning to standard error, with the "warning:" prefix being
+// colored as for the standard output (as both are mostly the same terminal),
+// unless quiet. Any buffered standard output is flushed first (as for infof).
+func (prog *Program) warnf(format string, args ...any) {
+	if prog.config.Quiet {
+		return
+	}
+
+	_ = prog.flushOutput() // Any error is returned again by the final flush.
+	fmt.Fprintf(prog.stderr, "%s %s\n", prog.colored(colorYellow, "warning:"), fmt.Sprintf(format, args...))
+}
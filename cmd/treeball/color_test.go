@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The names should be parsed into their respective modes, rejecting unknown ones.
+func Test_parseColorMode_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected ColorMode
+		ok       bool
+	}{
+		{"", ColorAuto, true},
+		{"auto", ColorAuto, true},
+		{"ALWAYS", ColorAlways, true},
+		{"never", ColorNever, true},
+		{"sometimes", ColorAuto, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, err := parseColorMode(tt.name)
+			if !tt.ok {
+				require.ErrorIs(t, err, errInvalidColorMode)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, mode)
+		})
+	}
+}
+
+// Expectation: The automatic mode should not colorize outputs that are no terminals.
+func Test_ColorMode_resolve_Success(t *testing.T) {
+	require.Equal(t, ColorNever, ColorAuto.resolve(&bytes.Buffer{}))
+	require.Equal(t, ColorNever, ColorAuto.resolve(nil))
+	require.Equal(t, ColorAlways, ColorAlways.resolve(&bytes.Buffer{}))
+	require.Equal(t, ColorNever, ColorNever.resolve(&bytes.Buffer{}))
+}
+
+// Expectation: The text should only be colored with the output being colorized.
+func Test_Program_colored_Success(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+	require.Equal(t, "a/", prog.formatPath("a/"))
+
+	prog = NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{Color: ColorAlways})
+	require.Equal(t, colorBlue+"a/"+colorReset, prog.formatPath("a/"))
+	require.Equal(t, "a.txt", prog.formatPath("a.txt"))
+}
+
+// Expectation: The warnings should be printed with their (colored) prefix.
+func Test_Program_warnf_Success(t *testing.T) {
+	var stderrBuf bytes.Buffer
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, &stderrBuf, nil, nil, nil)
+	prog.warnf("skipping %q", "a")
+	require.Equal(t, "warning: skipping \"a\"\n", stderrBuf.String())
+
+	stderrBuf.Reset()
+
+	prog = NewProgram(afero.NewMemMapFs(), io.Discard, &stderrBuf, nil, nil, &ProgramConfig{Color: ColorAlways})
+	prog.warnf("skipping %q", "a")
+	require.Equal(t, colorYellow+"warning:"+colorReset+" skipping \"a\"\n", stderrBuf.String())
+}
+
+// Expectation: The removed and added paths should be colored with the output being colorized.
+func Test_Program_Diff_Color_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "c.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Color: ColorAlways})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, colorRed+"--- b.txt"+colorReset+"\n"+colorGreen+"+++ c.txt"+colorReset+"\n", stdoutBuf.String())
+}
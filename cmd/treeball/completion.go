@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// archiveExtensions are the file extensions of archives (compressed or plain),
+// as suggested for archive arguments and recognized in directories of archives.
+var archiveExtensions = []string{"tar.gz", "tgz", "tar"}
+
+// trimArchiveExtension returns a file name without its archive extension,
+// and whether it had any (i.e. is the name of an archive).
+func trimArchiveExtension(name string) (string, bool) {
+	for _, ext := range archiveExtensions {
+		if trimmed, ok := strings.CutSuffix(name, "."+ext); ok {
+			return trimmed, true
+		}
+	}
+
+	return name, false
+}
+
+// completeArchives suggests the archives (and directories to descend into).
+func completeArchives(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return archiveExtensions, cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeDirs suggests only the directories.
+func completeDirs(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+// completeFiles suggests any files and directories (the default of the shell).
+func completeFiles(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveDefault
+}
+
+// completeNothing suggests nothing (e.g. for numbers or other free-form values).
+func completeNothing(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeValues returns a completion function suggesting the fixed values.
+func completeValues(values ...string) cobra.CompletionFunc {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var suggestions []string
+
+		for _, v := range values {
+			if strings.HasPrefix(v, toComplete) {
+				suggestions = append(suggestions, v)
+			}
+		}
+
+		return suggestions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completePositional returns a completion function suggesting per position of
+// the argument being completed, with the last function repeating for any more.
+func completePositional(fns ...cobra.CompletionFunc) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(fns) == 0 {
+			return completeNothing(cmd, args, toComplete)
+		}
+
+		return fns[min(len(args), len(fns)-1)](cmd, args, toComplete)
+	}
+}
+
+// flagCompletions are the completion functions of the flags, by their names,
+// which are registered for all commands that have any of these flags.
+var flagCompletions = map[string]cobra.CompletionFunc{
+	"tmpdir":        completeDirs,
+	"dest":          completeDirs,
+	"excludes-from": completeFiles,
+	"pairs-from":    completeFiles,
+	"sign-key":      completeFiles,
+	"signature":     completeFiles,
+	"trusted-keys":  completeFiles,
+	"output":        completeFiles,
+	"cpuprofile":    completeFiles,
+	"memprofile":    completeFiles,
+	"trace":         completeFiles,
+	"sort-by":       completeValues("name", "reverse", "depth", "version"),
+	"type":          completeValues("f", "d"),
+	"only":          completeValues("all", "added", "removed"),
+	"tar-format":    completeValues("auto", "ustar", "pax", "gnu"),
+	"checksum":      completeValues("none", "sha256", "blake3"),
+	"color":         completeValues("auto", "always", "never"),
+	"algorithm":     completeValues("sha256", "blake3"),
+	"profile":       completeValues(profileNames()...),
+	"on-exists":     completeValues("skip", "overwrite", "rename"),
+}
+
+// registerFlagCompletions registers the completion functions of the flags of
+// the command and all of its subcommands (see flagCompletions).
+func registerFlagCompletions(cmd *cobra.Command) {
+	register := func(flags *pflag.FlagSet) {
+		flags.VisitAll(func(f *pflag.Flag) {
+			if fn, ok := flagCompletions[f.Name]; ok {
+				_ = cmd.RegisterFlagCompletionFunc(f.Name, fn)
+			}
+		})
+	}
+
+	register(cmd.LocalNonPersistentFlags())
+	register(cmd.PersistentFlags())
+
+	for _, sub := range cmd.Commands() {
+		registerFlagCompletions(sub)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to run a completion request and return its suggestions and directive.
+func runCompletion(t *testing.T, args ...string) ([]string, string) {
+	t.Helper()
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), afero.NewMemMapFs(), &stdoutBuf, nil)
+	cmd.SetArgs(append([]string{cobra.ShellCompRequestCmd}, args...))
+	require.NoError(t, cmd.Execute())
+
+	lines := strings.Split(strings.TrimSpace(stdoutBuf.String()), "\n")
+	require.NotEmpty(t, lines)
+
+	return lines[:len(lines)-1], lines[len(lines)-1]
+}
+
+// Expectation: The completion function of the argument's position should be used, with the last repeating.
+func Test_completePositional_Table(t *testing.T) {
+	fn := completePositional(completeDirs, completeArchives)
+
+	tests := []struct {
+		name string
+		args []string
+		want cobra.ShellCompDirective
+	}{
+		{"First", nil, cobra.ShellCompDirectiveFilterDirs},
+		{"Second", []string{"a"}, cobra.ShellCompDirectiveFilterFileExt},
+		{"Repeating", []string{"a", "b", "c"}, cobra.ShellCompDirectiveFilterFileExt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, directive := fn(nil, tt.args, "")
+			require.Equal(t, tt.want, directive)
+		})
+	}
+
+	_, directive := completePositional()(nil, nil, "")
+	require.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+}
+
+// Expectation: Only the values with the prefix being completed should be suggested.
+func Test_completeValues_Success(t *testing.T) {
+	values, directive := completeValues("name", "reverse", "depth")(nil, nil, "de")
+
+	require.Equal(t, []string{"depth"}, values)
+	require.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+}
+
+// Expectation: The archive arguments should be completed with the archive extensions.
+func Test_CLI_Complete_Archive_Success(t *testing.T) {
+	values, directive := runCompletion(t, "list", "")
+
+	require.Equal(t, archiveExtensions, values)
+	require.Equal(t, fmt.Sprintf(":%d", cobra.ShellCompDirectiveFilterFileExt), directive)
+}
+
+// Expectation: The archive extensions (compressed or plain) should be trimmed from the file names.
+func Test_trimArchiveExtension_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		trimmed string
+		ok      bool
+	}{
+		{"monday.tar.gz", "monday", true},
+		{"tuesday.tgz", "tuesday", true},
+		{"wednesday.tar", "wednesday", true},
+		{"notes.txt", "notes.txt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trimmed, ok := trimArchiveExtension(tt.name)
+			require.Equal(t, tt.trimmed, trimmed)
+			require.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+// Expectation: The flags with fixed values should be completed with those values.
+func Test_CLI_Complete_FlagValues_Success(t *testing.T) {
+	values, _ := runCompletion(t, "list", "--sort-by", "")
+	require.Equal(t, []string{"name", "reverse", "depth", "version"}, values)
+
+	values, _ = runCompletion(t, "create", "--profile", "")
+	require.Equal(t, profileNames(), values)
+}
+
+// Expectation: The completion command should generate a script for the shell.
+func Test_CLI_CompletionCommand_Success(t *testing.T) {
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), afero.NewMemMapFs(), &stdoutBuf, nil)
+	cmd.SetArgs([]string{"completion", "bash"})
+
+	require.NoError(t, cmd.Execute())
+	require.Contains(t, stdoutBuf.String(), "__start_treeball")
+}
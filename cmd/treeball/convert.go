@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// zipMagic are the first bytes of any zip archive (of its first local file header).
+	zipMagic = []byte("PK\x03\x04")
+
+	// zipEmptyMagic are the first bytes of an empty zip archive (of its end record).
+	zipEmptyMagic = []byte("PK\x05\x06")
+)
+
+// Convert strips the contents of an existing (content) archive, rewriting it
+// into a tarball of zero-byte dummies, as if created by treeball from the tree
+// the archive was made of (e.g. for lightweight browsable indexes of backups,
+// without walking the original filesystem again).
+//
+// The input parameter specifies the path to the archive to convert, which is
+// either a tarball (compressed or not, see [newArchiveReader]) or a zip archive
+// (as detected from its extension or first bytes), the output parameter the
+// path of the tarball file to create. The paths of the entries are normalized
+// and written in sorted order as with [Program.Normalize], with any paths
+// matching the excludes slice skipped. The ctx parameter controls early
+// cancellation.
+func (prog *Program) Convert(ctx context.Context, input string, output string, excludes []string) error {
+	var creationDone bool
+
+	progressFrom(ctx).setPhase("converting %s into %s", input, output)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input, output)
+	if err != nil {
+		return err
+	}
+	defer closeRemotes()
+
+	if filepath.Clean(input) == filepath.Clean(output) {
+		return errors.New("failed to convert: input and output must not be the same file")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	var paths <-chan string
+	var errs <-chan error
+
+	if prog.isZipFile(input) {
+		paths, errs = prog.zipPathStream(ctx, input, excludes)
+	} else {
+		paths, errs = prog.tarPathStream(ctx, input, true, excludes)
+	}
+
+	out, err := prog.createOutput(ctx, output)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	defer func() {
+		if !creationDone {
+			_ = prog.fs.Remove(output)
+		}
+	}()
+	defer out.Close()
+
+	gw, err := prog.newArchiveWriter(out)
+	if err != nil {
+		return err
+	}
+	defer gw.Close()
+
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	if err := prog.recreateEntries(tw, paths); err != nil {
+		return err
+	}
+
+	for err := range errs {
+		if err != nil {
+			return fmt.Errorf("failure during convert: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+
+	if prog.config.VerifyWrites {
+		if err := prog.verifyOutput(ctx, output); err != nil {
+			return err
+		}
+	}
+
+	creationDone = true
+
+	return nil
+}
+
+// isZipFile returns if the file at a path is a zip archive (rather than a
+// tarball), as detected from its extension or its first bytes.
+func (prog *Program) isZipFile(p string) bool {
+	if strings.HasSuffix(strings.ToLower(p), ".zip") {
+		return true
+	}
+
+	return prog.hasFileMagic(p, zipMagic) || prog.hasFileMagic(p, zipEmptyMagic)
+}
+
+// zipPathStream returns a sorted stream of the paths of a zip archive, in the
+// same form as those of a tarball (see [normalizeTarPath]), skipping any paths
+// excluded as with [Program.tarPathStream]. As the entries are read from the
+// central directory at the end of the archive, it needs to be a seekable file
+// (and cannot be streamed, such as from a web server or of split parts).
+func (prog *Program) zipPathStream(ctx context.Context, input string, excludes []string) (<-chan string, <-chan error) {
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	matcher := prog.excludeMatcher(excludes)
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		f, err := prog.fs.Open(input)
+		if err != nil {
+			errs <- fmt.Errorf("failed to open input file: %w", err)
+
+			return
+		}
+		defer f.Close()
+
+		fi, err := f.Stat()
+		if err != nil {
+			errs <- fmt.Errorf("failed to stat input file: %w", err)
+
+			return
+		}
+
+		zr, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			errs <- fmt.Errorf("failed to open zip archive: %w", err)
+
+			return
+		}
+
+		for _, zf := range zr.File {
+			hdr := &tar.Header{Name: zf.Name, Typeflag: tar.TypeReg}
+			if zf.FileInfo().IsDir() {
+				hdr.Typeflag = tar.TypeDir
+			}
+
+			p, ok, err := prog.tarEntryPath(hdr)
+			if err != nil {
+				errs <- fmt.Errorf("failed to stream from zip: %w", err)
+
+				return
+			}
+
+			if excluded, err := prog.isExcluded(p, strings.HasSuffix(p, "/"), matcher); err != nil {
+				errs <- fmt.Errorf("failed to check for exclusion: %w", err)
+
+				return
+			} else if ok && !excluded {
+				select {
+				case paths <- p:
+				case <-ctx.Done():
+					errs <- fmt.Errorf("failed to stream from zip: %w", ctx.Err())
+
+					return
+				}
+			}
+
+			progress.addEntry()
+		}
+	}()
+
+	return extsortStrings(ctx, paths, errs, prog.extSortConfig)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to create a zip archive of the given files (with contents).
+func createZip(t *testing.T, files map[string]string, names []string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+// Expectation: A tarball with contents should be stripped into a tarball of dummies.
+func Test_Program_Convert_Tarball_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./docs/b.txt", Typeflag: tar.TypeReg, Size: 5, Mode: 0o644}))
+	_, err := tw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./a.txt", Typeflag: tar.TypeReg, Size: 3, Mode: 0o644}))
+	_, err = tw.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/backup.tar", buf.Bytes(), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Convert(t.Context(), "/backup.tar", "/tree.tar.gz", nil))
+
+	require.Equal(t, []string{"a.txt", "docs/", "docs/b.txt"}, readTarNames(t, fs, "/tree.tar.gz"))
+	require.Equal(t, "a.txt\ndocs/\ndocs/b.txt\n", stdoutBuf.String())
+
+	require.NoError(t, prog.Verify(t.Context(), "/tree.tar.gz"))
+}
+
+// Expectation: A zip archive should be stripped into a tarball of dummies, leaving out the excluded paths.
+func Test_Program_Convert_Zip_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	files := map[string]string{"photos/": "", "photos/b.jpg": "jpeg", "photos/Thumbs.db": "db", "a/c.txt": "text"}
+	require.NoError(t, afero.WriteFile(fs, "/backup.zip", createZip(t, files, []string{"photos/", "photos/b.jpg", "photos/Thumbs.db", "a/c.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Convert(t.Context(), "/backup.zip", "/tree.tar.gz", []string{"**/Thumbs.db"}))
+
+	require.Equal(t, []string{"a/", "a/c.txt", "photos/", "photos/b.jpg"}, readTarNames(t, fs, "/tree.tar.gz"))
+}
+
+// Expectation: A zip archive should be recognized by its first bytes, regardless of its extension.
+func Test_Program_isZipFile_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/backup.bin", createZip(t, map[string]string{"a.txt": "a"}, []string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/empty.bin", createZip(t, nil, nil), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/backup.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.True(t, prog.isZipFile("/backup.bin"))
+	require.True(t, prog.isZipFile("/empty.bin"))
+	require.True(t, prog.isZipFile("/missing.ZIP"))
+	require.False(t, prog.isZipFile("/backup.tar.gz"))
+}
+
+// Expectation: Paths of a zip archive escaping the root of the tree should be rejected.
+func Test_Program_Convert_Zip_EscapingPath_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/backup.zip", createZip(t, map[string]string{"../evil.txt": "x"}, []string{"../evil.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	err := prog.Convert(t.Context(), "/backup.zip", "/tree.tar.gz", nil)
+	require.ErrorIs(t, err, errInvalidTarPath)
+
+	exists, _ := afero.Exists(fs, "/tree.tar.gz")
+	require.False(t, exists)
+}
+
+// Expectation: A corrupt zip archive should raise the appropriate error.
+func Test_Program_Convert_Zip_Corrupt_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/backup.zip", []byte("PK\x03\x04 not a zip archive"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.ErrorContains(t, prog.Convert(t.Context(), "/backup.zip", "/tree.tar.gz", nil), "failed to open zip archive")
+}
+
+// Expectation: Converting a file into itself should be refused.
+func Test_Program_Convert_SameFile_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/backup.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.ErrorContains(t, prog.Convert(t.Context(), "/backup.tar.gz", "/backup.tar.gz", nil), "must not be the same file")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// crashStackSize is the most bytes of the stacks of all goroutines in a crash report.
+const crashStackSize = 4 << 20
+
+var errPanic = errors.New("panic")
+
+// crashSecretMarkers are the (upper-case) parts of the names of environment
+// variables of which the values are redacted in crash reports (such as the
+// credentials of remotes), as these are meant to be attached to bug reports.
+var crashSecretMarkers = []string{"PASS", "SECRET", "TOKEN", "KEY", "CREDENTIAL", "AUTH", "SESSION"}
+
+// crashReport holds what is known of the running command at the time of a
+// panic, for writing a diagnostics file (see [writeCrashReport]), so that a
+// failure of e.g. a hours-long run over millions of files can be analyzed
+// without having to reproduce it first.
+type crashReport struct {
+	Panic    any       // Value the command panicked with
+	Stack    []byte    // Stack of the panicking goroutine (as of debug.Stack)
+	Args     []string  // Arguments of the program (without its name)
+	Command  string    // Full name of the command that panicked (empty: unknown)
+	Flags    []string  // Flags that were given to the command (as --name=value)
+	Progress *Progress // Progress of the command up to the panic (or nil)
+}
+
+// newCrashReport returns the [crashReport] of a panic of the root command.
+// It is to be called from the deferred function recovering the panic, as the
+// stack of the panicking goroutine is then still the one of the panic.
+func newCrashReport(rootCmd *cobra.Command, args []string, r any, progress *Progress) crashReport {
+	report := crashReport{
+		Panic:    r,
+		Stack:    debug.Stack(),
+		Args:     args,
+		Progress: progress,
+	}
+
+	if p, ok := r.(*workerPanic); ok {
+		report.Panic, report.Stack = p.value, p.stack
+	}
+
+	if cmd, _, err := rootCmd.Find(args); err == nil {
+		report.Command = cmd.CommandPath()
+		cmd.Flags().Visit(func(f *pflag.Flag) {
+			report.Flags = append(report.Flags, fmt.Sprintf("--%s=%s", f.Name, f.Value))
+		})
+	}
+
+	return report
+}
+
+// crashHandlerKey is the key of the crash handler of a context (see [withCrashHandler]).
+type crashHandlerKey struct{}
+
+// withCrashHandler returns a context carrying the function that handles the
+// panics of the worker goroutines of operations (see [recoverWorker]), which
+// are not recovered by the goroutine running the command. The function is to
+// not return (such as before exiting), as any streams of the panicked worker
+// are otherwise closed as if complete.
+func withCrashHandler(ctx context.Context, handle func(r any)) context.Context {
+	return context.WithValue(ctx, crashHandlerKey{}, handle)
+}
+
+// recoverWorker recovers a panic of a worker goroutine, handing it to the crash
+// handler of the context (see [withCrashHandler]). It is to be deferred by the
+// worker goroutines (after any other deferred functions, so that it runs first).
+// Without any crash handler in the context, the panic is not recovered.
+func recoverWorker(ctx context.Context) {
+	handle, ok := ctx.Value(crashHandlerKey{}).(func(r any))
+	if !ok {
+		return
+	}
+
+	if r := recover(); r != nil {
+		handle(r)
+	}
+}
+
+// workerPanic is a panic recovered in a goroutine without a context (such as
+// a worker of a [ParallelWalker]), which is passed on to the goroutine it works
+// for, to be panicked with again there, along with the stack of the panic.
+type workerPanic struct {
+	value any
+	stack []byte
+}
+
+func (p *workerPanic) String() string {
+	return fmt.Sprint(p.value)
+}
+
+// crashDir returns the directory to write the crash reports of the root command
+// into, which is that of any --tmpdir, otherwise that of the system.
+func crashDir(rootCmd *cobra.Command) string {
+	if f := rootCmd.PersistentFlags().Lookup("tmpdir"); f != nil && f.Value.String() != "" {
+		return f.Value.String()
+	}
+
+	return os.TempDir()
+}
+
+// writeCrashReport writes the [crashReport] as a diagnostics file into dir,
+// with the stack traces of all goroutines, the configuration of the command,
+// its progress up to the panic, and its environment, returning its path.
+func writeCrashReport(fs afero.Fs, dir string, report crashReport) (string, error) {
+	f, err := afero.TempFile(fs, dir, "treeball-crash-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create diagnostics file: %w", err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "treeball crash report (%s)\n\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&buf, "panic: %v\n\n", report.Panic)
+
+	fmt.Fprintf(&buf, "== version\ntreeball version %s\n\n", buildInfo())
+
+	fmt.Fprintf(&buf, "== command\n")
+	fmt.Fprintf(&buf, "args: %q\n", report.Args)
+	fmt.Fprintf(&buf, "command: %s\n", report.Command)
+	for _, flag := range report.Flags {
+		fmt.Fprintf(&buf, "flag: %s\n", flag)
+	}
+	fmt.Fprintln(&buf)
+
+	fmt.Fprintf(&buf, "== progress\n")
+	if p := report.Progress; p != nil {
+		p.Snapshot(&buf)
+		fmt.Fprintf(&buf, "diffs: %d, written: %s, spilled: %s\n", p.diffs.Load(), formatBytes(p.written.Load()), formatBytes(p.spilled.Load()))
+	}
+	fmt.Fprintln(&buf)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	fmt.Fprintf(&buf, "== runtime\n")
+	fmt.Fprintf(&buf, "cpus: %d, gomaxprocs: %d, goroutines: %d\n", runtime.NumCPU(), runtime.GOMAXPROCS(0), runtime.NumGoroutine())
+	fmt.Fprintf(&buf, "heap: %s, sys: %s, gc cycles: %d\n\n", formatBytes(int64(mem.HeapAlloc)), formatBytes(int64(mem.Sys)), mem.NumGC) //nolint:gosec
+
+	fmt.Fprintf(&buf, "== environment\n")
+	for _, kv := range crashEnviron(os.Environ()) {
+		fmt.Fprintln(&buf, kv)
+	}
+	fmt.Fprintln(&buf)
+
+	fmt.Fprintf(&buf, "== stack of the panic\n%s\n", report.Stack)
+
+	stacks := make([]byte, crashStackSize)
+	stacks = stacks[:runtime.Stack(stacks, true)]
+	fmt.Fprintf(&buf, "== stacks of all goroutines\n%s\n", stacks)
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to write diagnostics file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close diagnostics file: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+// crashEnviron returns the environment (as of [os.Environ]) as sorted, with
+// the values of any variables that may hold secrets redacted.
+func crashEnviron(environ []string) []string {
+	env := make([]string, 0, len(environ))
+
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+
+		upper := strings.ToUpper(name)
+		if slices.ContainsFunc(crashSecretMarkers, func(m string) bool { return strings.Contains(upper, m) }) {
+			kv = name + "=[redacted]"
+		}
+
+		env = append(env, kv)
+	}
+
+	slices.Sort(env)
+
+	return env
+}
+
+// recoverCommand turns a panic of the root command into an error, after writing
+// the diagnostics of it into a file (see [writeCrashReport]), which is named in
+// the error (rather than dumping a raw panic). If the file cannot be written,
+// the stack of the panic is printed to stderr instead. It is called for the
+// panics of the goroutine running the command, as well as for those of any of
+// its workers (see [recoverWorker]).
+func recoverCommand(rootCmd *cobra.Command, args []string, r any, progress *Progress) error {
+	report := newCrashReport(rootCmd, args, r, progress)
+
+	path, err := writeCrashReport(afero.NewOsFs(), crashDir(rootCmd), report)
+	if err != nil {
+		fmt.Fprintf(rootCmd.ErrOrStderr(), "%s\n", report.Stack)
+
+		return fmt.Errorf("%w: %v (%w)", errPanic, r, err)
+	}
+
+	return fmt.Errorf("%w: %v (diagnostics written to %s, please attach these to any bug report)", errPanic, r, path)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to run a root command with a panicking subcommand,
+// returning the error of the recovered panic.
+func runPanickingCmd(t *testing.T, progress *Progress, args ...string) (err error) {
+	t.Helper()
+
+	ctx := t.Context()
+	if progress != nil {
+		ctx = context.WithValue(ctx, progressKey{}, progress)
+	}
+
+	rootCmd := newRootCmd(ctx, afero.NewMemMapFs(), io.Discard, io.Discard)
+	rootCmd.AddCommand(&cobra.Command{
+		Use: "boom",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			panic("boom")
+		},
+	})
+	rootCmd.SetArgs(args)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverCommand(rootCmd, args, r, progress)
+		}
+	}()
+
+	return rootCmd.Execute()
+}
+
+// Expectation: A panic of a command should be turned into an error naming its diagnostics file.
+func Test_recoverCommand_Success(t *testing.T) {
+	dir := t.TempDir()
+
+	_, progress := withProgress(t.Context())
+	progress.setPhase("walking")
+	progress.addEntry()
+
+	err := runPanickingCmd(t, progress, "boom", "--tmpdir="+dir, "--workers=3")
+	require.ErrorIs(t, err, errPanic)
+	require.ErrorContains(t, err, "boom")
+
+	matches, globErr := filepath.Glob(filepath.Join(dir, "treeball-crash-*.txt"))
+	require.NoError(t, globErr)
+	require.Len(t, matches, 1)
+	require.ErrorContains(t, err, matches[0])
+
+	data, readErr := os.ReadFile(matches[0])
+	require.NoError(t, readErr)
+
+	report := string(data)
+	require.Contains(t, report, "panic: boom")
+	require.Contains(t, report, "command: treeball boom")
+	require.Contains(t, report, "flag: --workers=3")
+	require.Contains(t, report, "progress: walking (entries: 1")
+	require.Contains(t, report, "runPanickingCmd")
+	require.Contains(t, report, "== stacks of all goroutines")
+}
+
+// Expectation: A panic of a command should still be turned into an error without a diagnostics file.
+func Test_recoverCommand_Unwritable_Error(t *testing.T) {
+	err := runPanickingCmd(t, nil, "boom", "--tmpdir="+filepath.Join(t.TempDir(), "missing"))
+	require.ErrorIs(t, err, errPanic)
+	require.ErrorContains(t, err, "failed to create diagnostics file")
+}
+
+// A helper walker for tests to simulate a panic of a walk.
+type panickingWalker struct{}
+
+// A helper function for tests to simulate a panic of a walk.
+func (panickingWalker) WalkDir(_ string, _ fs.WalkDirFunc) error {
+	panic("simulated panic")
+}
+
+// Expectation: A panic of a stream goroutine should be handed to the crash handler, writing the diagnostics file.
+func Test_recoverWorker_Stream_Success(t *testing.T) {
+	dir := t.TempDir()
+
+	rootCmd := newRootCmd(t.Context(), afero.NewMemMapFs(), io.Discard, io.Discard)
+	require.NoError(t, rootCmd.PersistentFlags().Set("tmpdir", dir))
+
+	crashErrs := make(chan error, 1)
+	ctx := withCrashHandler(t.Context(), func(r any) {
+		crashErrs <- recoverCommand(rootCmd, nil, r, nil)
+	})
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+	prog.fsWalker = panickingWalker{}
+
+	paths, errs := prog.fsPathStream(ctx, "/src", "", false, nil)
+	for range paths {
+	}
+	for range errs {
+	}
+
+	err := <-crashErrs
+	require.ErrorIs(t, err, errPanic)
+	require.ErrorContains(t, err, "simulated panic")
+
+	matches, globErr := filepath.Glob(filepath.Join(dir, "treeball-crash-*.txt"))
+	require.NoError(t, globErr)
+	require.Len(t, matches, 1)
+
+	data, readErr := os.ReadFile(matches[0])
+	require.NoError(t, readErr)
+	require.Contains(t, string(data), "panic: simulated panic")
+	require.Contains(t, string(data), "fsPathStream")
+}
+
+// Expectation: A panic of a worker goroutine should not be recovered without a crash handler.
+func Test_recoverWorker_NoHandler_Error(t *testing.T) {
+	require.PanicsWithValue(t, "simulated panic", func() {
+		defer recoverWorker(t.Context())
+
+		panic("simulated panic")
+	})
+}
+
+// Expectation: A panic passed on from a worker should be reported with its original value and stack.
+func Test_newCrashReport_WorkerPanic_Success(t *testing.T) {
+	rootCmd := newRootCmd(t.Context(), afero.NewMemMapFs(), io.Discard, io.Discard)
+
+	report := newCrashReport(rootCmd, nil, &workerPanic{value: "boom", stack: []byte("worker stack")}, nil)
+	require.Equal(t, "boom", report.Panic)
+	require.Equal(t, []byte("worker stack"), report.Stack)
+}
+
+// Expectation: The diagnostics file should be written, with any secrets of the environment redacted.
+func Test_writeCrashReport_Success(t *testing.T) {
+	t.Setenv("TREEBALL_TEST_PASSWORD", "hunter2")
+	t.Setenv("TREEBALL_TEST_PLAIN", "visible")
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/tmp", 0o755))
+
+	path, err := writeCrashReport(fs, "/tmp", crashReport{
+		Panic: "boom",
+		Stack: []byte("goroutine 1 [running]:"),
+		Args:  []string{"list", "/input.tar.gz", "--sort-by=depth"},
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(path, "/tmp/treeball-crash-"))
+
+	data, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+
+	report := string(data)
+	require.Contains(t, report, "panic: boom")
+	require.Contains(t, report, `args: ["list" "/input.tar.gz" "--sort-by=depth"]`)
+	require.Contains(t, report, "goroutine 1 [running]:")
+	require.Contains(t, report, "TREEBALL_TEST_PASSWORD=[redacted]")
+	require.Contains(t, report, "TREEBALL_TEST_PLAIN=visible")
+	require.NotContains(t, report, "hunter2")
+}
+
+// Expectation: The diagnostics file should not be written into a read-only location.
+func Test_writeCrashReport_ReadOnly_Error(t *testing.T) {
+	_, err := writeCrashReport(afero.NewReadOnlyFs(afero.NewMemMapFs()), "/tmp", crashReport{Panic: "boom"})
+	require.ErrorContains(t, err, "failed to create diagnostics file")
+}
+
+// Expectation: The values of the variables that may hold secrets should be redacted.
+func Test_crashEnviron_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		kv   string
+		want string
+	}{
+		{"Plain", "HOME=/root", "HOME=/root"},
+		{"Password", "RCLONE_RC_PASS=x", "RCLONE_RC_PASS=[redacted]"},
+		{"Secret key", "AWS_SECRET_ACCESS_KEY=x", "AWS_SECRET_ACCESS_KEY=[redacted]"},
+		{"Session token", "AWS_SESSION_TOKEN=x", "AWS_SESSION_TOKEN=[redacted]"},
+		{"Lower case", "github_token=x", "github_token=[redacted]"},
+		{"Empty value", "SSH_AUTH_SOCK=", "SSH_AUTH_SOCK=[redacted]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, []string{tt.want}, crashEnviron([]string{tt.kv}))
+		})
+	}
+}
@@ -3,85 +3,491 @@ package main
 import (
 	"archive/tar"
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"strings"
 
-	pgzip "github.com/klauspost/pgzip"
+	"github.com/spf13/afero"
 )
 
+// rsyncableEntries is the average amount of entries between the gzip members of
+// rsyncable tarballs (see [isRsyncableBoundary]).
+const rsyncableEntries = 1024
+
+// CreateEstimate is the expected outcome of a [Program.Create] operation.
+type CreateEstimate struct {
+	Entries        int64 // Amount of entries (files and directories) to be written
+	Size           int64 // Size of the uncompressed tarball (in bytes)
+	CompressedSize int64 // Size of the compressed tarball (in bytes)
+}
+
 // Create produces a tarball of a target directory structure.
 // Any encountered files are replaced with zero-byte empty dummies.
 //
 // The input parameter specifies the root directory to package. The output
 // parameter is the path of the tarball file to create. Any paths matching the
 // excludes slice are skipped. The ctx parameter controls early cancellation.
+//
+// With [ProgramConfig.CheckpointEvery], checkpoints are persisted alongside the
+// output file, which is then kept (rather than removed) upon any failure, so the
+// creation can be continued with [ProgramConfig.Resume] instead of restarted.
+//
+// With [ProgramConfig.SplitSize], the tarball is split into parts of at most
+// that size instead (see [Program.createSplitOutput]), which are read again
+// as one by all other operations (see [Program.openArchive]).
+//
+// With [ProgramConfig.Checksum], the checksum of the created tarball is written
+// alongside it (see [Program.writeChecksum]), as is a detached signature with
+// [ProgramConfig.SignKey] (see [Program.VerifySignature]).
+//
+// With [ProgramConfig.Fsync], the output is synced to its storage before the
+// creation is complete, and with [ProgramConfig.VerifyWrites] it is read back
+// and decoded (see [Program.verifyOutput]), before the creation is complete.
 func (prog *Program) Create(ctx context.Context, input string, output string, excludes []string) error {
-	var creationDone bool
+	var creationDone, checkpointed bool
+	var resume *createCheckpoint
+	var out outputFile
+
+	progressFrom(ctx).setPhase("creating %s from %s", output, input)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input, output)
+	if err != nil {
+		return err
+	}
+	defer closeRemotes()
 
-	out, err := prog.fs.Create(output)
+	signer, err := prog.signer()
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
+	}
+
+	absInput, err := filepath.Abs(input)
+	if err != nil {
+		return fmt.Errorf("failed to obtain absolute path: %w", err)
+	}
+
+	removeOutput := func() { _ = prog.fs.Remove(output) }
+
+	switch {
+	case prog.config.SplitSize > 0:
+		if prog.config.Resume {
+			return errors.New("failed to resume: not supported for split archives")
+		}
+
+		split, err := prog.createSplitOutput(ctx, output, prog.config.SplitSize)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		out, removeOutput = split, split.remove
+
+	case prog.config.Resume:
+		if resume, err = prog.readCheckpoint(output); err != nil {
+			return fmt.Errorf("failed to resume: %w", err)
+		}
+
+		if resume.Input != absInput {
+			return fmt.Errorf("failed to resume: checkpoint is of another input: %s", resume.Input)
+		}
+
+		if out, err = prog.openResumedOutput(ctx, output, resume.Offset); err != nil {
+			return fmt.Errorf("failed to resume: %w", err)
+		}
+		checkpointed = true
+
+		prog.infof("resuming after %q (%d entries)", resume.LastPath, resume.Entries)
+
+	default:
+		out, err = prog.createOutput(ctx, output)
+		if errors.Is(err, ErrOutputExists) {
+			if _, cpErr := prog.fs.Stat(checkpointPath(output)); cpErr == nil {
+				err = fmt.Errorf("%w (or --resume to continue its interrupted creation)", err)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+
+		_ = prog.fs.Remove(checkpointPath(output)) // Any stale one.
 	}
 
 	defer func() {
-		if !creationDone {
-			_ = prog.fs.Remove(output)
+		if !creationDone && !checkpointed {
+			removeOutput()
 		}
 	}()
 	defer out.Close()
 
-	gw, err := pgzip.NewWriterLevel(out, prog.gzipConfig.CompressionLevel)
+	ctx, skipped := withSkipCounter(ctx)
+
+	opts := tarballOptions{
+		onEntry: func(relPath string) {
+			prog.printPath(relPath)
+		},
+		resume: resume,
+	}
+
+	// Split archives cannot be resumed, so they are never checkpointed either.
+	if prog.config.CheckpointEvery > 0 && prog.config.SplitSize == 0 {
+		opts.checkpoint = func(cp createCheckpoint) error {
+			if err := out.Sync(); err != nil {
+				return fmt.Errorf("failed to sync output file: %w", err)
+			}
+
+			cp.Input = absInput
+			if err := prog.writeCheckpoint(output, cp); err != nil {
+				return err
+			}
+			checkpointed = true
+
+			return nil
+		}
+	}
+
+	// A resumed output is only partially written here, so it is summed up at the end.
+	w, sum := io.Writer(out), hash.Hash(nil)
+	if resume == nil {
+		w, sum = prog.checksumWriter(out)
+	}
+
+	if _, err := prog.writeTarball(ctx, w, input, excludes, opts); err != nil {
+		return fmt.Errorf("failure during create: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+
+	if prog.config.VerifyWrites {
+		if err := prog.verifyOutput(ctx, output); err != nil {
+			return err
+		}
+	}
+
+	creationDone = true
+
+	_ = prog.fs.Remove(checkpointPath(output))
+
+	if err := prog.writeSidecars(ctx, output, sum, signer); err != nil {
+		return err
+	}
+
+	return prog.checkSkipped(nil, skipped)
+}
+
+// outputFile is an output file being written, which is either an [afero.File]
+// or the [splitWriter] of the parts of a split archive.
+type outputFile interface {
+	io.WriteCloser
+	Sync() error
+}
+
+// openResumedOutput opens an output file for resuming its creation, which is
+// truncated to the offset of its checkpoint (discarding anything written after).
+func (prog *Program) openResumedOutput(ctx context.Context, output string, offset int64) (afero.File, error) {
+	out, err := prog.fs.OpenFile(output, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %w", err)
+	}
+
+	if info, err := out.Stat(); err != nil {
+		out.Close()
+
+		return nil, fmt.Errorf("failed to stat output file: %w", err)
+	} else if info.Size() < offset {
+		out.Close()
+
+		return nil, fmt.Errorf("%w: output file is smaller than at its checkpoint", errNoCheckpoint)
+	}
+
+	if err := out.Truncate(offset); err != nil {
+		out.Close()
+
+		return nil, fmt.Errorf("failed to truncate output file: %w", err)
+	}
+
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		out.Close()
+
+		return nil, fmt.Errorf("failed to seek output file: %w", err)
+	}
+
+	return progressFrom(ctx).trackWrites(prog.syncOnClose(out)), nil
+}
+
+// Estimate performs a dry-run of [Program.Create], printing the expected
+// entry count and compressed output size of the tarball to standard output.
+//
+// The tarball is produced (with the program's [GzipConfig]) but never written
+// anywhere, so the sizes are those which [Program.Create] would produce given
+// an unchanged directory tree. The parameters are those of [Program.Create].
+func (prog *Program) Estimate(ctx context.Context, input string, excludes []string) (*CreateEstimate, error) {
+	compressed := &countingWriter{w: io.Discard}
+
+	est := &CreateEstimate{}
+
+	progressFrom(ctx).setPhase("estimating %s", input)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRemotes()
+
+	ctx, skipped := withSkipCounter(ctx)
+
+	size, err := prog.writeTarball(ctx, compressed, input, excludes, tarballOptions{onEntry: func(string) {
+		est.Entries++
+	}})
 	if err != nil {
-		return fmt.Errorf("failed to initialize gzip writer: %w", err)
+		return nil, fmt.Errorf("failure during estimate: %w", err)
+	}
+
+	est.Size = size
+	est.CompressedSize = compressed.n
+
+	fmt.Fprintf(prog.stdout, "entries: %d\n", est.Entries)
+	fmt.Fprintf(prog.stdout, "size: %d bytes (uncompressed: %d bytes)\n", est.CompressedSize, est.Size)
+
+	return est, prog.checkSkipped(nil, skipped)
+}
+
+// tarballOptions are the options of a [Program.writeTarball] operation.
+type tarballOptions struct {
+	onEntry    func(relPath string)            // Called with the relative path of every written entry (as transformed)
+	resume     *createCheckpoint               // Checkpoint to resume writing after (nil: from the start)
+	checkpoint func(cp createCheckpoint) error // Called every [ProgramConfig.CheckpointEvery] entries (nil: never)
+}
+
+// writeTarball writes a compressed tarball of the directory tree at input to w
+// (or a plain tarball, with a compression level of none; see [Program.newArchiveWriter]).
+// It returns the size of the written tarball before compression (in bytes).
+//
+// With checkpoints, the tarball is written as a sequence of gzip members, each
+// ending at a checkpoint, so that a resumption can truncate the tarball at the
+// checkpoint's offset and append further members (a valid gzip stream). The
+// tar stream itself continues seamlessly across the members' boundaries.
+// A plain tarball is instead just flushed up to the end of every checkpoint.
+//
+// With [ProgramConfig.MemberEvery], further members are ended in between, so
+// that the tarball can be decompressed starting at any of the members (each
+// of which starts with a tar header), e.g. for resetting readers or splitting.
+//
+// With [ProgramConfig.Rsyncable], further members are ended after the entries
+// at content-defined boundaries (see [isRsyncableBoundary]) in between.
+//
+// With annotations (see [Program.annotates]), these are written at the start of
+// the tarball, and the fingerprint of its entries (as a [listingDigest] of them
+// with SHA-256, so as computed by [Program.Hash]) at the end of it, in a gzip
+// member of its own (see [Program.trailingAnnotations]).
+//
+// With [ProgramConfig.Transforms], the names of the entries are rewritten (see
+// [Program.transformName]) as written, while the walk itself (and so any of its
+// excludes and checkpoints) is still of the names within the directory tree.
+func (prog *Program) writeTarball(ctx context.Context, w io.Writer, input string, excludes []string, opts tarballOptions) (int64, error) {
+	// The compressed output observes cancellation per write, as the compression
+	// of large blocks may otherwise outlast a cancellation by a long time.
+	compressed := &countingWriter{w: contextWriter{ctx: ctx, w: w}}
+	uncompressed := &countingWriter{}
+
+	var entries, sinceCheckpoint, sinceMember int64
+	var resumeAfter string
+
+	if prog.capturesMetadata() {
+		if err := prog.checkMetadataSupported(input); err != nil {
+			return 0, fmt.Errorf("failed to capture metadata: %w", err)
+		}
+
+		if format := prog.config.TarFormat; format == tar.FormatUSTAR || format == tar.FormatGNU {
+			return 0, fmt.Errorf("failed to capture metadata: not representable in the %s format (use pax)", strings.ToLower(format.String()))
+		}
+	}
+
+	if prog.annotates() {
+		if format := prog.config.TarFormat; format == tar.FormatUSTAR || format == tar.FormatGNU {
+			return 0, fmt.Errorf("failed to annotate: not representable in the %s format (use pax)", strings.ToLower(format.String()))
+		}
 	}
-	defer gw.Close()
 
-	if err := gw.SetConcurrency(prog.gzipConfig.BlockSize, prog.gzipConfig.BlockCount); err != nil {
-		return fmt.Errorf("failed to set gzip writer settings: %w", err)
+	if opts.resume != nil {
+		compressed.n = opts.resume.Offset
+		entries = opts.resume.Entries
+		resumeAfter = opts.resume.LastPath
 	}
 
-	tw := tar.NewWriter(gw)
+	gw, err := prog.newArchiveWriter(compressed)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = gw.Close() }()
+
+	uncompressed.w = gw
+
+	tw := tar.NewWriter(uncompressed)
 	defer tw.Close()
 
-	if err := prog.fsWalker.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
-		if err := ctx.Err(); err != nil {
-			return fmt.Errorf("failed to walk filesystem: %w", err)
+	// A resumed tarball is not fingerprinted, as its previous entries are not hashed.
+	var fingerprint *listingDigest
+
+	if prog.annotates() && opts.resume == nil {
+		if err := writeAnnotations(tw, prog.annotations(input)); err != nil {
+			return 0, err
 		}
+		fingerprint = newListingDigest(ChecksumSHA256)
+	}
 
+	if err := prog.walkTree(ctx, input, "", excludes, func(relPath string, d fs.DirEntry) error {
+		if resumeAfter != "" {
+			if skip, err := resumeSkip(relPath, d, resumeAfter); skip {
+				return err
+			}
+			resumeAfter = ""
+		}
+
+		hdr, err := prog.entryHeader(input, relPath, d)
 		if err != nil {
-			return fmt.Errorf("failed to walk filesystem: %w", err)
+			return err
 		}
 
-		if path == input {
+		name, ok := prog.transformName(hdr.Name)
+		if !ok {
 			return nil
 		}
+		hdr.Name = name
 
-		relPath, err := filepath.Rel(input, path)
-		if err != nil {
-			return fmt.Errorf("failed to obtain relative path: %w", err)
+		if err := writeTarHeader(tw, hdr); err != nil {
+			return fmt.Errorf("failed to write dummy file: %w", err)
+		}
+
+		if fingerprint != nil {
+			fingerprint.add(metadataRecord(hdr.Name, hdr.PAXRecords))
+		}
+
+		if opts.onEntry != nil {
+			opts.onEntry(filepath.FromSlash(strings.TrimSuffix(name, "/")))
 		}
 
-		if excluded, err := isExcluded(relPath, d.IsDir(), excludes); err != nil {
-			return fmt.Errorf("failed to check for exclusion: %w", err)
-		} else if excluded && d.IsDir() {
-			return filepath.SkipDir
-		} else if excluded {
+		entries++
+		sinceCheckpoint++
+		sinceMember++
+
+		atCheckpoint := opts.checkpoint != nil && sinceCheckpoint >= int64(prog.config.CheckpointEvery)
+		atMember := (prog.config.MemberEvery > 0 && sinceMember >= int64(prog.config.MemberEvery)) ||
+			(prog.config.Rsyncable && isRsyncableBoundary(hdr.Name))
+
+		if !atCheckpoint && !atMember {
 			return nil
 		}
+		sinceMember = 0
 
-		if err := writeDummyFile(tw, relPath, d.IsDir()); err != nil {
-			return fmt.Errorf("failed to write dummy file: %w", err)
+		// The tar writer is not closed, as that would write the end-of-archive
+		// trailer, rather only the current gzip member is finished at this point.
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("failed to flush tar writer: %w", err)
+		}
+
+		if err := gw.Close(); err != nil {
+			return fmt.Errorf("failed to close gzip writer: %w", err)
 		}
 
-		fmt.Fprintln(prog.stdout, relPath)
+		if atCheckpoint {
+			sinceCheckpoint = 0
+
+			if err := opts.checkpoint(createCheckpoint{LastPath: relPath, Offset: compressed.n, Entries: entries}); err != nil {
+				return err
+			}
+		}
+
+		if gw, err = prog.newArchiveWriter(compressed); err != nil {
+			return err
+		}
+		uncompressed.w = gw
 
 		return nil
 	}); err != nil {
-		return fmt.Errorf("failure during create: %w", err)
+		return 0, err
 	}
 
-	creationDone = true
+	if fingerprint != nil {
+		// The fingerprint is written into a gzip member of its own, the last one,
+		// so that it can be read without decompressing the entire tarball.
+		if err := tw.Flush(); err != nil {
+			return 0, fmt.Errorf("failed to flush tar writer: %w", err)
+		}
+
+		if err := gw.Close(); err != nil {
+			return 0, fmt.Errorf("failed to close gzip writer: %w", err)
+		}
+
+		if gw, err = prog.newArchiveWriter(compressed); err != nil {
+			return 0, err
+		}
+		uncompressed.w = gw
+
+		if err := writeAnnotations(tw, map[string]string{"fingerprint": hex.EncodeToString(fingerprint.sum())}); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close tar writer: %w", err)
+	}
+
+	if err := gw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	return uncompressed.n, nil
+}
+
+// entryHeader returns the tar header of an entry of the tree, which is that of
+// a dummy file (see [dummyHeader]), but with the actual type of special files
+// with [ProgramConfig.SpecialFiles], with any captured metadata (see
+// [Program.entryMetadata]) as its PAX records, with any fixed
+// [ProgramConfig.ModTime] as its modification time, and with any overridden
+// permission bits and owners (see [Program.setEntryOwnership]).
+func (prog *Program) entryHeader(root string, relPath string, d fs.DirEntry) (*tar.Header, error) {
+	hdr := dummyHeader(relPath, d.IsDir(), prog.config.TarFormat)
+	hdr.ModTime = prog.config.ModTime
+	prog.setEntryOwnership(hdr)
+
+	if typeflag, ok := specialTypeflag(d.Type()); ok && prog.config.SpecialFiles {
+		info, err := d.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat special file: %w", err)
+		}
+
+		setSpecialType(hdr, typeflag, info)
+	}
+
+	if prog.capturesMetadata() && !isVirtualEntry(d) {
+		records, err := prog.entryMetadata(filepath.Join(root, relPath))
+		if err != nil {
+			return nil, err
+		}
+
+		hdr.PAXRecords = records
+	}
+
+	return hdr, nil
+}
+
+// isRsyncableBoundary returns if a gzip member is to end after the entry of a
+// name, which is the case for about one in [rsyncableEntries] names. Like with
+// the rsyncable gzip, the boundaries only depend on the content (the names) and
+// the compression starts afresh with each member, so that the compressed bytes
+// of any member with the same entries are the same, regardless of whatever came
+// before. Any changes of a tree thus only change the members of their entries,
+// for rsync (and similar tools) to transfer only these, not the entire tarball.
+func isRsyncableBoundary(name string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(name))
 
-	return nil
+	return h.Sum64()%rsyncableEntries == 0
 }
@@ -2,13 +2,17 @@ package main
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
@@ -24,6 +28,11 @@ func (e errorFs) Create(name string) (afero.File, error) {
 	return nil, errors.New("simulated create failure")
 }
 
+// A helper function for tests to simulate file creation failure.
+func (e errorFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return nil, errors.New("simulated create failure")
+}
+
 // A helper function for tests to simulate file opening failure.
 func (e errorFs) Open(name string) (afero.File, error) {
 	return nil, errors.New("simulated open failure")
@@ -44,7 +53,7 @@ func Test_Program_Create_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{}))
 
 	f, err := fs.Open("/out.tar.gz")
@@ -70,6 +79,121 @@ func Test_Program_Create_Success(t *testing.T) {
 	require.Equal(t, []string{"a.txt", "b/", "b/c.txt"}, names)
 }
 
+// Expectation: A plain tarball (rather than a gzip wrapper) should be created without compression.
+func Test_Program_Create_NoCompression_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+
+	cfg := gzipConfigDefault
+	cfg.CompressionLevel = gzip.NoCompression
+
+	prog := NewProgram(fs, io.Discard, io.Discard, &cfg, nil, &ProgramConfig{CheckpointEvery: 1})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar", []string{}))
+
+	f, err := fs.Open("/out.tar")
+	require.NoError(t, err)
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		names = append(names, hdr.Name)
+	}
+
+	require.Equal(t, []string{"a.txt", "b/", "b/c.txt"}, names)
+
+	var stdoutBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.List(t.Context(), "/out.tar", true, nil))
+	require.Equal(t, "a.txt\nb/\nb/c.txt\n", stdoutBuf.String())
+}
+
+// Expectation: An archive should be written as gzip members of the given number of entries, each decompressible on its own.
+func Test_Program_Create_MemberEvery_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{MemberEvery: 2})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{}))
+
+	data, err := afero.ReadFile(fs, "/out.tar.gz")
+	require.NoError(t, err)
+
+	var firsts []string
+
+	br := bytes.NewReader(data)
+	for br.Len() > 0 {
+		gr, err := gzip.NewReader(br)
+		require.NoError(t, err)
+		gr.Multistream(false)
+
+		member, err := io.ReadAll(gr)
+		require.NoError(t, err)
+
+		hdr, err := tar.NewReader(bytes.NewReader(member)).Next()
+		if err == io.EOF {
+			break // The member with the end-of-archive trailer.
+		}
+		require.NoError(t, err)
+
+		firsts = append(firsts, hdr.Name)
+	}
+
+	require.Equal(t, []string{"a.txt", "b/c.txt"}, firsts)
+	require.Equal(t, []string{"a.txt", "b/", "b/c.txt"}, readTarNames(t, fs, "/out.tar.gz"))
+}
+
+// Expectation: The tarballs of trees differing in one entry should only differ in the gzip member of that entry.
+func Test_Program_Create_Rsyncable_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	for i := range 8 * rsyncableEntries {
+		require.NoError(t, afero.WriteFile(fs, fmt.Sprintf("/old/f%05d.txt", i), nil, 0o644))
+		require.NoError(t, afero.WriteFile(fs, fmt.Sprintf("/new/f%05d.txt", i), nil, 0o644))
+	}
+	require.NoError(t, afero.WriteFile(fs, "/new/a.txt", nil, 0o644))
+
+	commonSuffix := func(config ProgramConfig) (int, int) {
+		prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &config)
+		require.NoError(t, prog.Create(t.Context(), "/old", "/old.tar.gz", nil))
+		require.NoError(t, prog.Create(t.Context(), "/new", "/new.tar.gz", nil))
+
+		oldData, err := afero.ReadFile(fs, "/old.tar.gz")
+		require.NoError(t, err)
+
+		newData, err := afero.ReadFile(fs, "/new.tar.gz")
+		require.NoError(t, err)
+
+		var n int
+		for n < len(oldData) && n < len(newData) && oldData[len(oldData)-1-n] == newData[len(newData)-1-n] {
+			n++
+		}
+
+		require.NoError(t, fs.Remove("/old.tar.gz"))
+		require.NoError(t, fs.Remove("/new.tar.gz"))
+
+		return n, len(newData)
+	}
+
+	n, size := commonSuffix(ProgramConfig{ModTime: time.Unix(1, 0), Rsyncable: true})
+	require.Greater(t, n, size/2)
+
+	n, _ = commonSuffix(ProgramConfig{ModTime: time.Unix(1, 0)})
+	require.Less(t, n, size/2)
+}
+
 // Expectation: A tarball should be created with all given paths contained, except the excluded folder.
 func Test_Program_Create_WithExcludes_Success(t *testing.T) {
 	fs := afero.NewMemMapFs()
@@ -77,7 +201,7 @@ func Test_Program_Create_WithExcludes_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{"b"}))
 
 	f, err := fs.Open("/out.tar.gz")
@@ -110,7 +234,7 @@ func Test_Program_Create_WithFileExcluded_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{"b/*.txt"}))
 
 	f, err := fs.Open("/out.tar.gz")
@@ -143,7 +267,7 @@ func Test_Program_Create_InvalidExcludePattern_Error(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	err := prog.Create(t.Context(), "/src", "/out.tar.gz", []string{"b["})
 
 	require.Error(t, err)
@@ -160,7 +284,7 @@ func Test_Program_Create_CtxCancel_Error(t *testing.T) {
 
 	cancel()
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	require.ErrorIs(t, prog.Create(ctx, "/src", "/out.tar.gz", []string{}), context.Canceled)
 
 	_, err := fs.Stat("/out.tar.gz")
@@ -177,7 +301,7 @@ func Test_Program_Create_InvalidCompressorBlockCount_Error(t *testing.T) {
 	cfg := gzipConfigDefault
 	cfg.BlockCount = -1
 
-	prog := NewProgram(fs, io.Discard, io.Discard, &cfg, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, &cfg, nil, nil)
 	require.Error(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{}))
 
 	_, err := fs.Stat("/out.tar.gz")
@@ -194,7 +318,7 @@ func Test_Program_Create_InvalidCompressorBlockSize_Error(t *testing.T) {
 	cfg := gzipConfigDefault
 	cfg.BlockSize = -1
 
-	prog := NewProgram(fs, io.Discard, io.Discard, &cfg, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, &cfg, nil, nil)
 	require.Error(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{}))
 
 	_, err := fs.Stat("/out.tar.gz")
@@ -211,7 +335,7 @@ func Test_Program_Create_InvalidCompressorCompLevel_Error(t *testing.T) {
 	cfg := gzipConfigDefault
 	cfg.CompressionLevel = -17
 
-	prog := NewProgram(fs, io.Discard, io.Discard, &cfg, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, &cfg, nil, nil)
 	require.Error(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{}))
 
 	_, err := fs.Stat("/out.tar.gz")
@@ -227,7 +351,7 @@ func Test_Program_Create_CreateFile_Error(t *testing.T) {
 
 	fs := errorFs{Fs: baseFs}
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 
 	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
 	require.Error(t, err)
@@ -243,7 +367,7 @@ func Test_Program_Create_WalkDir_Error(t *testing.T) {
 
 	require.NoError(t, afero.WriteFile(fs, "/src/file.txt", []byte("test"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	prog.fsWalker = errorWalker{}
 
 	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
@@ -253,3 +377,189 @@ func Test_Program_Create_WalkDir_Error(t *testing.T) {
 	_, statErr := fs.Stat("/out.tar.gz")
 	require.ErrorIs(t, statErr, os.ErrNotExist)
 }
+
+// Expectation: The estimate should match the tarball that would be created.
+func Test_Program_Estimate_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	est, err := prog.Estimate(t.Context(), "/src", []string{})
+	require.NoError(t, err)
+
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{}))
+
+	info, err := fs.Stat("/out.tar.gz")
+	require.NoError(t, err)
+
+	require.Equal(t, int64(3), est.Entries)
+	require.Equal(t, int64(3*512+1024), est.Size)
+	require.Equal(t, info.Size(), est.CompressedSize)
+	require.Contains(t, stdoutBuf.String(), "entries: 3\n")
+	require.Contains(t, stdoutBuf.String(), fmt.Sprintf("size: %d bytes", info.Size()))
+}
+
+// Expectation: An error should be returned when the walk fails.
+func Test_Program_Estimate_WalkDir_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	prog.fsWalker = errorWalker{}
+
+	_, err := prog.Estimate(t.Context(), "/src", nil)
+	require.ErrorContains(t, err, "failure during estimate")
+}
+
+// A helper walker for tests to simulate a walk failure at a specific path.
+type failingPathWalker struct {
+	walker Walker
+	path   string
+}
+
+// A helper function for tests to simulate a walk failure at a specific path.
+func (w failingPathWalker) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return w.walker.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if path == w.path {
+			return fn(path, d, errors.New("simulated permission denied"))
+		}
+
+		return fn(path, d, err)
+	})
+}
+
+// Expectation: Unreadable entries should be skipped with a warning and counted.
+func Test_Program_Create_SkipErrors_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/d.txt", []byte("d"), 0o644))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil, &ProgramConfig{SkipErrors: true})
+	prog.fsWalker = failingPathWalker{walker: prog.fsWalker, path: "/src/b"}
+
+	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorIs(t, err, ErrEntriesSkipped)
+
+	require.Equal(t, "a.txt\nd.txt\n", stdoutBuf.String())
+	require.Contains(t, stderrBuf.String(), `warning: skipping "/src/b": simulated permission denied`)
+	require.Contains(t, stderrBuf.String(), "warning: 1 entries were skipped due to errors")
+
+	_, err = fs.Stat("/out.tar.gz")
+	require.NoError(t, err)
+}
+
+// Expectation: Unreadable entries should fail the operation without skipping.
+func Test_Program_Create_SkipErrors_Disabled_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	prog.fsWalker = failingPathWalker{walker: prog.fsWalker, path: "/src/b"}
+
+	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorContains(t, err, "simulated permission denied")
+	require.NotErrorIs(t, err, ErrEntriesSkipped)
+}
+
+// Expectation: An unreadable root should fail the operation even when skipping.
+func Test_Program_Create_SkipErrors_Root_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SkipErrors: true})
+	prog.fsWalker = errorWalker{}
+
+	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorContains(t, err, "simulated walk failure")
+	require.NotErrorIs(t, err, ErrEntriesSkipped)
+}
+
+// Expectation: An existing output file should not be overwritten without --force.
+func Test_Program_Create_OutputExists_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", []byte("previous"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorIs(t, err, ErrOutputExists)
+
+	data, err := afero.ReadFile(fs, "/out.tar.gz")
+	require.NoError(t, err)
+	require.Equal(t, "previous", string(data))
+}
+
+// Expectation: An existing output file should be overwritten with --force.
+func Test_Program_Create_OutputExistsForce_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", []byte("previous"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Force: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	require.Equal(t, []string{"a.txt"}, readTarNames(t, fs, "/out.tar.gz"))
+}
+
+// Expectation: Existing output files should be renamed aside with --backup, never replacing previous backups.
+func Test_Program_Create_OutputExistsBackup_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", []byte("previous"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz.bak", []byte("older"), 0o644))
+
+	var stderrBuf bytes.Buffer
+
+	prog := NewProgram(fs, io.Discard, &stderrBuf, nil, nil, &ProgramConfig{Backup: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	require.Equal(t, []string{"a.txt"}, readTarNames(t, fs, "/out.tar.gz"))
+	require.Equal(t, []string{"a.txt"}, readTarNames(t, fs, "/out.tar.gz.bak.2"))
+
+	data, err := afero.ReadFile(fs, "/out.tar.gz.bak.1")
+	require.NoError(t, err)
+	require.Equal(t, "previous", string(data))
+
+	data, err = afero.ReadFile(fs, "/out.tar.gz.bak")
+	require.NoError(t, err)
+	require.Equal(t, "older", string(data))
+
+	require.Contains(t, stderrBuf.String(), `backed up "/out.tar.gz" to "/out.tar.gz.bak.1"`)
+}
+
+// Expectation: Existing non-regular output files (such as /dev/null) should always be written to.
+func Test_Program_Create_OutputDevNull_Success(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644))
+
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Create(t.Context(), src, os.DevNull, nil))
+}
+
+// Expectation: The written paths should be printed NUL-delimited with --print0.
+func Test_Program_Create_Print0_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Print0: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	require.Equal(t, "a.txt\x00b\x00b/c.txt\x00", stdoutBuf.String())
+}
@@ -2,15 +2,96 @@ package main
 
 import (
 	"archive/tar"
-	"compress/gzip"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/lanrat/extsort/diff"
 )
 
+// DiffSide is a side of the differences between two sources.
+type DiffSide int
+
+const (
+	// DiffSideBoth considers both the added and the removed paths.
+	DiffSideBoth DiffSide = iota
+
+	// DiffSideAdded considers only the added paths ("+++").
+	DiffSideAdded
+
+	// DiffSideRemoved considers only the removed paths ("---").
+	DiffSideRemoved
+)
+
+// diffDrift is the [diff.Delta] of a path present in both sources, but with
+// differing metadata (as captured with [ProgramConfig.Xattrs] or [ProgramConfig.ACLs]).
+const diffDrift diff.Delta = diff.OLD + 1
+
+const (
+	diffAddedPrefix   = "+++" // Default synthetic directory of the added paths
+	diffRemovedPrefix = "---" // Default synthetic directory of the removed paths
+	diffDriftPrefix   = "~~~" // Synthetic directory of the drifted paths
+)
+
+var (
+	errInvalidDiffSide   = errors.New("invalid side of differences")
+	errInvalidDiffPrefix = errors.New("invalid diff prefix")
+	errDiffLimitReached  = errors.New("limit of differences reached")
+)
+
+// parseDiffSide returns the [DiffSide] for a side name (as for --only).
+func parseDiffSide(name string) (DiffSide, error) {
+	switch strings.ToLower(name) {
+	case "", "all":
+		return DiffSideBoth, nil
+	case "added":
+		return DiffSideAdded, nil
+	case "removed":
+		return DiffSideRemoved, nil
+	default:
+		return DiffSideBoth, fmt.Errorf("%w: %q (expected all, added or removed)", errInvalidDiffSide, name)
+	}
+}
+
+// parseDiffPrefixes returns the synthetic directories of the added and removed
+// paths (as for --added-prefix and --removed-prefix), as relative and cleaned
+// paths, which need to differ from each other (and from that of the drift).
+func parseDiffPrefixes(added string, removed string) (string, string, error) {
+	prefixes := []string{added, removed}
+
+	for i, p := range prefixes {
+		clean := path.Clean(strings.Trim(p, "/"))
+		if p == "" || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return "", "", fmt.Errorf("%w: %q (expected a relative directory)", errInvalidDiffPrefix, p)
+		}
+		prefixes[i] = clean
+	}
+
+	if prefixes[0] == prefixes[1] || slices.Contains(prefixes, diffDriftPrefix) {
+		return "", "", fmt.Errorf("%w: %q and %q (expected distinct directories)", errInvalidDiffPrefix, added, removed)
+	}
+
+	return prefixes[0], prefixes[1], nil
+}
+
+// diffPrefix returns the synthetic directory of a [diff.Delta] in diff tarballs,
+// as per [ProgramConfig.AddedPrefix] and [ProgramConfig.RemovedPrefix].
+func (prog *Program) diffPrefix(delta diff.Delta) string {
+	switch delta {
+	case diff.OLD:
+		return cmp.Or(prog.config.RemovedPrefix, diffRemovedPrefix)
+	case diff.NEW:
+		return cmp.Or(prog.config.AddedPrefix, diffAddedPrefix)
+	default:
+		return diffDriftPrefix
+	}
+}
+
 // Diff compares the contents of two sources (directories or tarballs) and
 // produces a synthetic tarball representing only the differences between them.
 //
@@ -19,22 +100,65 @@ import (
 //   - Added paths are placed under a synthetic "+++" directory.
 //   - Removed paths are placed under a synthetic "---" directory.
 //
+// These directories can be named otherwise with [ProgramConfig.AddedPrefix] and
+// [ProgramConfig.RemovedPrefix] (e.g. for tools not coping with such names).
+//
 // Each differing file or folder is represented as a dummy entry to avoid
 // including real file contents. Any paths matching the excludes slice are
-// skipped on both sides of the input and for resulting diff-consideration.
+// skipped on both sides of the input and for resulting diff-consideration,
+// as are those matching [ProgramConfig.ExcludesOld] or [ProgramConfig.ExcludesNew]
+// on only that side (such as for paths only ever existing on one of the sides).
+// With [ProgramConfig.OnlySide], only the differences of that side are
+// considered (as if there were none on the other side, also for the result).
+// With [ProgramConfig.Xattrs] or [ProgramConfig.ACLs], the captured metadata of
+// the entries is compared as well, with any paths differing only by it placed
+// under a synthetic "~~~" directory (counting as both removed and added).
+// With [ProgramConfig.MaxDiffs], the comparison is stopped early once as many
+// differences were found (with the result only counting those up to then).
 //
 // This function returns:
 //   - (*diff.Result, ErrDiffsFound): if any differences are found
 //   - (*diff.Result, nil): if the sources are identical (no output file)
+//   - (*diff.Result, ErrEntriesSkipped): if any entries were skipped (joined with the above)
 //   - (nil, error): for any other failure (I/O, gzip, comparison error, etc.)
 //
+// An empty output path only prints the differences (without any output file).
+// With [ProgramConfig.Checksum] and [ProgramConfig.SignKey], the checksum and a
+// detached signature of the diff tarball are written alongside it (see [Program.Create]).
+// With [ProgramConfig.Fsync] and [ProgramConfig.VerifyWrites], the diff tarball
+// is synced and read back before completing, also as with [Program.Create].
 // The ctx parameter controls early cancellation.
 func (prog *Program) Diff(ctx context.Context, cmpOld string, cmpNew string, output string, excludes []string) (*diff.Result, error) { //nolint:unparam
+	return prog.DiffSources(ctx, []string{cmpOld}, []string{cmpNew}, output, excludes)
+}
+
+// DiffSources is a variant of [Program.Diff] that compares multiple sources.
+//
+// The slices cmpOld and cmpNew can each contain either a single source (as for
+// [Program.Diff]) or multiple directories in the dir:Prefix=/path format, which
+// are merged into one tree under their prefixes (as that of a multi-root archive).
+// A single directory in said format is placed under its prefix for comparison.
+func (prog *Program) DiffSources(ctx context.Context, cmpOld []string, cmpNew []string, output string, excludes []string) (*diff.Result, error) {
 	var hasDifferences bool
-	var oldStream, newStream <-chan string
-	var oldErrs, newErrs <-chan error
 
-	out, err := prog.fs.Create(output)
+	progressFrom(ctx).setPhase("comparing %s with %s", strings.Join(cmpOld, ", "), strings.Join(cmpNew, ", "))
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, slices.Concat(cmpOld, cmpNew, []string{output})...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRemotes()
+
+	if output == "" {
+		return prog.diffSources(ctx, cmpOld, cmpNew, excludes, prog.printDelta)
+	}
+
+	signer, err := prog.signer()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := prog.createOutput(ctx, output)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create output file: %w", err)
 	}
@@ -46,54 +170,326 @@ func (prog *Program) Diff(ctx context.Context, cmpOld string, cmpNew string, out
 	}()
 	defer out.Close()
 
-	gw, err := gzip.NewWriterLevel(out, prog.gzipConfig.CompressionLevel)
+	w, sum := prog.checksumWriter(out)
+
+	gw, err := prog.newArchiveWriter(w)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize gzip writer: %w", err)
+		return nil, err
 	}
 	defer gw.Close()
 
 	tw := tar.NewWriter(gw)
 	defer tw.Close()
 
-	if oldStream, oldErrs, err = prog.multiPathStream(ctx, cmpOld, true, excludes); err != nil {
+	result, err := prog.diffSources(ctx, cmpOld, cmpNew, excludes, func(delta diff.Delta, item string) error {
+		if err := prog.printDelta(delta, item); err != nil {
+			return err
+		}
+
+		switch delta {
+		case diff.OLD, diff.NEW, diffDrift:
+			isDir := strings.HasSuffix(item, "/")
+
+			return writeDummyFile(tw, filepath.Join(prog.diffPrefix(delta), item), isDir, prog.config.TarFormat)
+		}
+
+		return nil
+	})
+	if result != nil && (result.ExtraA > 0 || result.ExtraB > 0) {
+		hasDifferences = true
+	}
+
+	if hasDifferences {
+		if err := tw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close tar writer: %w", err)
+		}
+
+		if err := gw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close gzip writer: %w", err)
+		}
+
+		if err := out.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close output file: %w", err)
+		}
+
+		if prog.config.VerifyWrites {
+			if err := prog.verifyOutput(ctx, output); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := prog.writeSidecars(ctx, output, sum, signer); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, err
+}
+
+// printDelta prints a difference to standard output.
+func (prog *Program) printDelta(delta diff.Delta, item string) error {
+	switch delta {
+	case diff.OLD:
+		prog.printPath(prog.colored(colorRed, "--- "+prog.quote(item)))
+	case diff.NEW:
+		prog.printPath(prog.colored(colorGreen, "+++ "+prog.quote(item)))
+	case diffDrift:
+		prog.printPath(prog.colored(colorYellow, "~~~ "+prog.quote(item)))
+	}
+
+	return nil
+}
+
+// Check compares an archive against a live directory tree, like [Program.Diff]
+// but without producing a diff tarball (the most common verification task).
+//
+// The archive parameter is the tarball to compare, roots are either a single
+// directory or multiple directories in the dir:Prefix=/path format (as with
+// [Program.DiffSources]). Any differences are printed to standard output,
+// followed by a summary of their amounts. Any paths matching the excludes
+// slice are skipped on both sides. The returns are those of [Program.Diff].
+// The ctx parameter controls early cancellation.
+func (prog *Program) Check(ctx context.Context, archive string, roots []string, excludes []string) (*diff.Result, error) {
+	progressFrom(ctx).setPhase("checking %s against %s", archive, strings.Join(roots, ", "))
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, append([]string{archive}, roots...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRemotes()
+
+	result, err := prog.diffSources(ctx, []string{archive}, roots, excludes, prog.printDelta)
+	if result != nil && (result.ExtraA > 0 || result.ExtraB > 0) {
+		prog.printPath(fmt.Sprintf("removed: %d, added: %d", result.ExtraA, result.ExtraB))
+	}
+
+	return result, err
+}
+
+// DiffSummary compares the sources like [Program.DiffSources], but only prints
+// the aggregate amounts of the differences (without streaming any paths or
+// writing a diff tarball), broken down by their top-level directories (with
+// any files at the top level grouped together as "."). It is
+// meant for quickly finding whether anything changed, with the returns being
+// those of [Program.Diff]. The ctx parameter controls early cancellation.
+func (prog *Program) DiffSummary(ctx context.Context, cmpOld []string, cmpNew []string, excludes []string) (*diff.Result, error) {
+	progressFrom(ctx).setPhase("comparing %s with %s", strings.Join(cmpOld, ", "), strings.Join(cmpNew, ", "))
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, slices.Concat(cmpOld, cmpNew)...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRemotes()
+
+	type counts struct{ removed, added int }
+
+	var tops []string
+	perTop := make(map[string]*counts)
+
+	result, err := prog.diffSources(ctx, cmpOld, cmpNew, excludes, func(delta diff.Delta, item string) error {
+		top := "." // The files at the top level are grouped together.
+		if i := strings.Index(item, "/"); i >= 0 {
+			top = item[:i+1]
+		}
+
+		c, ok := perTop[top]
+		if !ok {
+			top = strings.Clone(top) // Not keeping the (shared) blocks of a pathArena alive.
+			c = &counts{}
+			perTop[top] = c
+			tops = append(tops, top)
+		}
+
+		switch delta {
+		case diff.OLD:
+			c.removed++
+		case diff.NEW:
+			c.added++
+		case diffDrift:
+			c.removed++
+			c.added++
+		}
+
+		return nil
+	})
+	if result == nil || (result.ExtraA == 0 && result.ExtraB == 0) {
+		return result, err
+	}
+
+	for _, top := range tops {
+		prog.printPath(fmt.Sprintf("%s: removed: %d, added: %d", prog.formatPath(top), perTop[top].removed, perTop[top].added))
+	}
+	prog.printPath(fmt.Sprintf("removed: %d, added: %d", result.ExtraA, result.ExtraB))
+
+	return result, err
+}
+
+// diffSources streams the differences between the sources to the function fn.
+// The returns are those of [Program.DiffSources], but without creating output.
+func (prog *Program) diffSources(ctx context.Context, cmpOld []string, cmpNew []string, excludes []string, fn func(delta diff.Delta, item string) error) (*diff.Result, error) {
+	var err error
+	var oldStream, newStream <-chan string
+	var oldErrs, newErrs <-chan error
+
+	if prog.sameFingerprints(cmpOld, cmpNew) {
+		prog.infof("identical by the fingerprints of the archives")
+
+		return &diff.Result{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx, skipped := withSkipCounter(ctx)
+	progress := progressFrom(ctx)
+
+	if oldStream, oldErrs, err = prog.sourcesPathStream(ctx, cmpOld, slices.Concat(excludes, prog.config.ExcludesOld)); err != nil {
 		return nil, fmt.Errorf("failed to establish stream: %w", err)
 	}
-	if newStream, newErrs, err = prog.multiPathStream(ctx, cmpNew, true, excludes); err != nil {
+	if newStream, newErrs, err = prog.sourcesPathStream(ctx, cmpNew, slices.Concat(excludes, prog.config.ExcludesNew)); err != nil {
 		return nil, fmt.Errorf("failed to establish stream: %w", err)
 	}
 
-	result, err := diff.Strings(
-		ctx,
-		oldStream, newStream,
-		oldErrs, newErrs,
-		func(delta diff.Delta, item string) error {
-			switch delta {
-			case diff.OLD:
-				fmt.Fprintf(prog.stdout, "--- %s\n", item)
+	if prog.remapsPaths() {
+		oldStream, oldErrs = prog.remappedPathStream(ctx, oldStream, oldErrs, prog.config.MapsOld)
+		newStream, newErrs = prog.remappedPathStream(ctx, newStream, newErrs, prog.config.MapsNew)
+	}
+
+	if prog.config.FilesOnly {
+		oldStream = filesOnlyStream(ctx, oldStream)
+		newStream = filesOnlyStream(ctx, newStream)
+	}
 
-				isDir := strings.HasSuffix(item, "/")
+	var emitted int
 
-				return writeDummyFile(tw, filepath.Join("---", item), isDir)
-			case diff.NEW:
-				fmt.Fprintf(prog.stdout, "+++ %s\n", item)
+	emit := func(delta diff.Delta, record string) error {
+		progress.addDiff()
 
-				isDir := strings.HasSuffix(item, "/")
+		if err := fn(delta, recordPath(record)); err != nil {
+			return err
+		}
 
-				return writeDummyFile(tw, filepath.Join("+++", item), isDir)
-			}
+		if emitted++; prog.config.MaxDiffs > 0 && emitted >= prog.config.MaxDiffs {
+			return errDiffLimitReached
+		}
 
+		return nil
+	}
+
+	// With captured metadata, the items are records (see [metadataRecord]), of
+	// which those of the same path are adjacent, as they differ only after it.
+	var pending string
+	var pendingDelta diff.Delta
+	var hasPending bool
+
+	result, err := diff.Generic(ctx, oldStream, newStream, oldErrs, newErrs, prog.comparePaths, func(delta diff.Delta, item string) error {
+		if (delta == diff.OLD && prog.config.OnlySide == DiffSideAdded) || (delta == diff.NEW && prog.config.OnlySide == DiffSideRemoved) {
 			return nil
-		},
-	)
+		}
+
+		if !prog.capturesMetadata() {
+			return emit(delta, item)
+		}
+
+		if hasPending && delta != pendingDelta && prog.comparePaths(recordPath(pending), recordPath(item)) == 0 {
+			hasPending = false
+
+			return emit(diffDrift, item)
+		}
+
+		if hasPending {
+			if err := emit(pendingDelta, pending); err != nil {
+				return err
+			}
+		}
+		pending, pendingDelta, hasPending = item, delta, true
+
+		return nil
+	})
+	if err == nil && hasPending {
+		err = emit(pendingDelta, pending)
+	}
+	if errors.Is(err, errDiffLimitReached) {
+		// The streams are no longer consumed, so their producers are canceled.
+		cancel()
+		if emitted == 1 {
+			prog.infof("stopped after the first difference")
+		} else {
+			prog.infof("stopped after the first %d differences", emitted)
+		}
+
+		err = nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failure during diff: %w", err)
 	}
 
+	switch prog.config.OnlySide {
+	case DiffSideAdded:
+		result.ExtraA = 0
+	case DiffSideRemoved:
+		result.ExtraB = 0
+	case DiffSideBoth:
+	}
+
 	if result.ExtraA > 0 || result.ExtraB > 0 {
-		hasDifferences = true
+		return &result, prog.checkSkipped(ErrDiffsFound, skipped)
+	}
+
+	return &result, prog.checkSkipped(nil, skipped)
+}
 
-		return &result, ErrDiffsFound
+// sameFingerprints returns if two sources are known to be identical without
+// comparing them, which is the case for two tarballs with the same fingerprint
+// of their entries (see [Program.trailingAnnotations]). As the same excludes
+// (and other filters) apply to the entries of both, so the results of that
+// are identical as well, unless with any excludes or mappings of only either side.
+func (prog *Program) sameFingerprints(cmpOld []string, cmpNew []string) bool {
+	if len(cmpOld) != 1 || len(cmpNew) != 1 {
+		return false
 	}
 
-	return &result, nil
+	if len(prog.config.ExcludesOld) > 0 || len(prog.config.ExcludesNew) > 0 || len(prog.config.MapsOld) > 0 || len(prog.config.MapsNew) > 0 {
+		return false
+	}
+
+	var fingerprints [2]string
+
+	for i, arg := range []string{cmpOld[0], cmpNew[0]} {
+		src, err := parseSource(arg)
+		if err != nil || src.git || src.prefix != "" {
+			return false
+		}
+
+		if fingerprints[i] = prog.trailingAnnotations(src.path)["fingerprint"]; fingerprints[i] == "" {
+			return false
+		}
+	}
+
+	return fingerprints[0] == fingerprints[1]
+}
+
+// filesOnlyStream returns a stream of only the files of a stream of paths,
+// leaving out any directories (as recognized by their trailing slash).
+func filesOnlyStream(ctx context.Context, paths <-chan string) <-chan string {
+	files := make(chan string, fsStreamBuffer)
+
+	go func() {
+		defer close(files)
+		defer recoverWorker(ctx)
+
+		for p := range paths {
+			if strings.HasSuffix(recordPath(p), "/") {
+				continue
+			}
+
+			select {
+			case files <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return files
 }
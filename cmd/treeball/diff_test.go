@@ -22,7 +22,7 @@ func createTar(entries []string) []byte {
 	tw := tar.NewWriter(gz)
 
 	for _, name := range entries {
-		_ = writeDummyFile(tw, name, strings.HasSuffix(name, "/"))
+		_ = writeDummyFile(tw, name, strings.HasSuffix(name, "/"), tar.FormatUnknown)
 	}
 
 	_ = tw.Close()
@@ -38,7 +38,7 @@ func Test_Program_Diff_OldPathMissing_Stat_Error(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/old1.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
 
 	require.Error(t, err)
@@ -55,7 +55,7 @@ func Test_Program_Diff_NewPathMissing_Stat_Error(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new1.tar.gz", "/diff.tar.gz", nil)
 
 	require.Error(t, err)
@@ -72,7 +72,7 @@ func Test_Program_Diff_InvalidExcludePattern_Error(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "b/", "b/y.txt"}), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", []string{"a["})
 
 	require.Error(t, err)
@@ -86,7 +86,7 @@ func Test_Program_Diff_TarVsTar_DiffsFound_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "b/", "b/y.txt"}), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
 	require.ErrorIs(t, err, ErrDiffsFound)
 
@@ -120,7 +120,7 @@ func Test_Program_Diff_TarVsTar_NoDiffsFound_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
 	require.NoError(t, err)
 
@@ -128,6 +128,42 @@ func Test_Program_Diff_TarVsTar_NoDiffsFound_Success(t *testing.T) {
 	require.ErrorIs(t, err, os.ErrNotExist)
 }
 
+// Expectation: The per-side excludes should only apply to the paths of their side.
+func Test_Program_Diff_PerSideExcludes_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "tmp/", "tmp/x"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{".recycle/", ".recycle/a.txt", "a.txt", "tmp/", "tmp/x"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{
+		ExcludesOld: []string{"tmp/x"},
+		ExcludesNew: []string{".recycle", ".recycle/**"},
+	})
+	res, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, uint64(0), res.ExtraA)
+	require.Equal(t, uint64(1), res.ExtraB)
+	require.Equal(t, "+++ tmp/x\n", stdoutBuf.String())
+}
+
+// Expectation: The per-side excludes should apply in addition to the shared excludes.
+func Test_Program_Diff_PerSideExcludes_Shared_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "cache/", "cache/x"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "b.tmp", "cache/", "cache/y"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{ExcludesNew: []string{"*.tmp"}})
+	res, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", []string{"cache/*"})
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(0), res.ExtraA)
+	require.Equal(t, uint64(0), res.ExtraB)
+}
+
 // Expectation: No differences found between two tarballs when exclusions are applied, and the output file removed.
 func Test_Program_Diff_TarVsTar_NoDiffsFound_Excludes_Success(t *testing.T) {
 	fs := afero.NewMemMapFs()
@@ -156,7 +192,7 @@ func Test_Program_Diff_TarVsTar_NoDiffsFound_Excludes_Success(t *testing.T) {
 	})
 	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", newTar, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 
 	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", []string{"**/vendor/**"})
 	require.NoError(t, err)
@@ -179,7 +215,7 @@ func Test_Program_Diff_TarVsFolder_DiffsFound_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/cmpNew/a.txt", []byte{}, 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/cmpNew/b/y.txt", []byte{}, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/cmpOld.tar.gz", "/cmpNew", "/diff.tar.gz", nil)
 	require.ErrorIs(t, err, ErrDiffsFound)
 
@@ -216,7 +252,7 @@ func Test_Program_Diff_TarVsFolder_NoDiffsFound_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/cmpNew/a.txt", []byte{}, 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/cmpNew/b/x.txt", []byte{}, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/cmpOld.tar.gz", "/cmpNew", "/diff.tar.gz", nil)
 	require.NoError(t, err)
 
@@ -245,7 +281,7 @@ func Test_Program_Diff_TarVsFolder_NoDiffsFound_Excludes_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/cmpNew/app/internal/util.go", []byte{}, 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/cmpNew/app/vendor/github.com/lib/lib_v2.go", []byte{}, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/cmpOld.tar.gz", "/cmpNew", "/diff.tar.gz", []string{"**/vendor/**"})
 	require.NoError(t, err)
 
@@ -267,7 +303,7 @@ func Test_Program_Diff_FolderVsTar_DiffsFound_Success(t *testing.T) {
 		"b/y.txt",
 	}), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/cmpOld", "/cmpNew.tar.gz", "/diff.tar.gz", nil)
 	require.ErrorIs(t, err, ErrDiffsFound)
 
@@ -304,7 +340,7 @@ func Test_Program_Diff_FolderVsTar_NoDiffsFound_Success(t *testing.T) {
 		"b/x.txt",
 	}), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/cmpOld", "/cmpNew.tar.gz", "/diff.tar.gz", nil)
 	require.NoError(t, err)
 
@@ -333,7 +369,7 @@ func Test_Program_Diff_FolderVsTar_NoDiffsFound_Excludes_Success(t *testing.T) {
 		"app/vendor/github.com/lib/lib_v2.go",
 	}), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/cmpOld", "/cmpNew.tar.gz", "/diff.tar.gz", []string{"**/vendor/**"})
 	require.NoError(t, err)
 
@@ -353,7 +389,7 @@ func Test_Program_Diff_FolderVsFolder_DiffsFound_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/new/a.txt", []byte{}, 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/new/b/y.txt", []byte{}, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/old", "/new", "/diff.tar.gz", nil)
 	require.ErrorIs(t, err, ErrDiffsFound)
 
@@ -388,7 +424,7 @@ func Test_Program_Diff_FolderVsFolder_NoDiffsFound_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/new/a.txt", []byte{}, 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/new/b/x.txt", []byte{}, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/old", "/new", "/diff.tar.gz", nil)
 	require.NoError(t, err)
 
@@ -412,7 +448,7 @@ func Test_Program_Diff_FolderVsFolder_NoDiffsFound_Excludes_Success(t *testing.T
 	require.NoError(t, afero.WriteFile(fs, "/new/app/internal/util.go", []byte{}, 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/new/app/vendor/github.com/lib/lib_v2.go", []byte{}, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(t.Context(), "/old", "/new", "/diff.tar.gz", []string{"**/vendor/**"})
 	require.NoError(t, err)
 
@@ -430,7 +466,7 @@ func Test_Program_Diff_CtxCancel_Error(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.Diff(ctx, "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
 	require.ErrorIs(t, err, context.Canceled)
 
@@ -446,7 +482,7 @@ func Test_Program_Diff_CreateFile_Error(t *testing.T) {
 	require.NoError(t, afero.WriteFile(baseFs, "/new.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
 
 	fs := errorFs{Fs: baseFs}
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 
 	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
 	require.Error(t, err)
@@ -466,10 +502,542 @@ func Test_Program_Diff_InvalidCompressorCompLevel_Error(t *testing.T) {
 	cfg := gzipConfigDefault
 	cfg.CompressionLevel = -17
 
-	prog := NewProgram(fs, io.Discard, io.Discard, &cfg, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, &cfg, nil, nil)
 	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
 	require.Error(t, err)
 
 	_, err = fs.Stat("/diff.tar.gz")
 	require.ErrorIs(t, err, os.ErrNotExist)
 }
+
+// Expectation: Differences below the maximum depth should not be considered.
+func Test_Program_Diff_MaxDepth_NoDiffsFound_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "b/", "b/y.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{MaxDepth: 1})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.NoError(t, err)
+
+	_, err = fs.Stat("/diff.tar.gz")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: Skipped entries should take precedence over found differences.
+func Test_Program_Diff_SkipErrors_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new/b.txt", []byte("b"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new/c/d.txt", []byte("d"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SkipErrors: true})
+	prog.fsWalker = failingPathWalker{walker: prog.fsWalker, path: "/new/c"}
+
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new", "/diff.tar.gz", nil)
+	require.ErrorIs(t, err, ErrEntriesSkipped)
+	require.ErrorIs(t, err, ErrDiffsFound)
+}
+
+// Expectation: A multi-root archive should be comparable against its merged sources.
+func Test_Program_DiffSources_Merged_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{
+		"Movies/", "Movies/a.mkv", "TV/", "TV/e01.mkv", "TV/e02.mkv",
+	}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/mnt/m/a.mkv", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/mnt/t/e01.mkv", []byte("e"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	res, err := prog.DiffSources(t.Context(), []string{"/snapshot.tar.gz"}, []string{"dir:Movies=/mnt/m", "dir:TV=/mnt/t"}, "/diff.tar.gz", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, uint64(1), res.ExtraA)
+	require.Equal(t, uint64(0), res.ExtraB)
+	require.Equal(t, "--- TV/e02.mkv\n", stdoutBuf.String())
+}
+
+// Expectation: An existing output file should not be overwritten without --force.
+func Test_Program_Diff_OutputExists_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"b.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/diff.tar.gz", []byte("previous"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.ErrorIs(t, err, ErrOutputExists)
+
+	data, err := afero.ReadFile(fs, "/diff.tar.gz")
+	require.NoError(t, err)
+	require.Equal(t, "previous", string(data))
+}
+
+// Expectation: Checking an archive against an identical tree should report nothing.
+func Test_Program_Check_NoDiffsFound_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/x.txt", []byte("x"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	res, err := prog.Check(t.Context(), "/snapshot.tar.gz", []string{"/src"}, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(0), res.ExtraA)
+	require.Equal(t, uint64(0), res.ExtraB)
+	require.Empty(t, stdoutBuf.String())
+}
+
+// Expectation: Checking an archive against a changed tree should report the differences without any output file.
+func Test_Program_Check_DiffsFound_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/c.txt", []byte("c"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	res, err := prog.Check(t.Context(), "/snapshot.tar.gz", []string{"/src"}, nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, uint64(2), res.ExtraA)
+	require.Equal(t, uint64(1), res.ExtraB)
+	require.Equal(t, "--- b/\n--- b/x.txt\n+++ c.txt\nremoved: 2, added: 1\n", stdoutBuf.String())
+
+	files, err := afero.ReadDir(fs, "/")
+	require.NoError(t, err)
+	require.Len(t, files, 2) // Only the snapshot and the source tree.
+}
+
+// Expectation: A missing archive should raise the appropriate error.
+func Test_Program_Check_ArchiveMissing_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	_, err := prog.Check(t.Context(), "/snapshot.tar.gz", []string{"/src"}, nil)
+
+	require.ErrorContains(t, err, "stat")
+	require.NotErrorIs(t, err, ErrDiffsFound)
+}
+
+// Expectation: Directory additions and removals should not count as differences with --files-only.
+func Test_Program_Diff_FilesOnly_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt", "empty/"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt", "c/", "c/d/"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{FilesOnly: true})
+	res, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(0), res.ExtraA)
+	require.Equal(t, uint64(0), res.ExtraB)
+
+	_, err = fs.Stat("/diff.tar.gz")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: File additions and removals should still count as differences with --files-only.
+func Test_Program_Diff_FilesOnly_DiffsFound_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "c/", "c/y.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{FilesOnly: true})
+	res, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, uint64(1), res.ExtraA)
+	require.Equal(t, uint64(1), res.ExtraB)
+	require.Equal(t, "--- b/x.txt\n+++ c/y.txt\n", stdoutBuf.String())
+}
+
+// Expectation: An empty output path should only report the differences without any output file.
+func Test_Program_Diff_NoOutput_DiffsFound_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "c.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	res, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, uint64(1), res.ExtraA)
+	require.Equal(t, uint64(1), res.ExtraB)
+	require.Equal(t, "--- b.txt\n+++ c.txt\n", stdoutBuf.String())
+
+	files, err := afero.ReadDir(fs, "/")
+	require.NoError(t, err)
+	require.Len(t, files, 2) // Only the two sources.
+}
+
+// Expectation: Only the differences of the selected side should be considered with --only.
+func Test_Program_Diff_OnlySide_Table(t *testing.T) {
+	tests := []struct {
+		name   string
+		side   DiffSide
+		extraA uint64
+		extraB uint64
+		stdout string
+		names  []string
+	}{
+		{"Both sides", DiffSideBoth, 1, 1, "--- b.txt\n+++ c.txt\n", []string{"---/b.txt", "+++/c.txt"}},
+		{"Added only", DiffSideAdded, 0, 1, "+++ c.txt\n", []string{"+++/c.txt"}},
+		{"Removed only", DiffSideRemoved, 1, 0, "--- b.txt\n", []string{"---/b.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "c.txt"}), 0o644))
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{OnlySide: tt.side})
+			res, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+			require.ErrorIs(t, err, ErrDiffsFound)
+
+			require.Equal(t, tt.extraA, res.ExtraA)
+			require.Equal(t, tt.extraB, res.ExtraB)
+			require.Equal(t, tt.stdout, stdoutBuf.String())
+			require.Equal(t, tt.names, readTarNames(t, fs, "/diff.tar.gz"))
+		})
+	}
+}
+
+// Expectation: Differences of only the other side should not count as differences with --only.
+func Test_Program_Diff_OnlySide_NoDiffsFound_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "c.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{OnlySide: DiffSideRemoved})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.NoError(t, err)
+
+	_, err = fs.Stat("/diff.tar.gz")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: The sides of differences should be parsed from their names.
+func Test_parseDiffSide_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    DiffSide
+		wantErr bool
+	}{
+		{"", DiffSideBoth, false},
+		{"all", DiffSideBoth, false},
+		{"added", DiffSideAdded, false},
+		{"REMOVED", DiffSideRemoved, false},
+		{"changed", DiffSideBoth, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDiffSide(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidDiffSide)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: Differences should be printed NUL-delimited with --print0.
+func Test_Program_Diff_Print0_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b\n.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "c.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Print0: true})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- b\n.txt\x00+++ c.txt\x00", stdoutBuf.String())
+}
+
+// Expectation: Paths differing only by case should not be differences with --ignore-case.
+func Test_Program_Diff_IgnoreCase_Table(t *testing.T) {
+	tests := []struct {
+		name       string
+		ignoreCase bool
+		stdout     string
+	}{
+		{"Case-sensitive", false, "--- Movies/Alien.mkv\n+++ Movies/B.mkv\n+++ Movies/alien.mkv\n--- Movies/b.mkv\n+++ Movies/c.mkv\n"},
+		{"Case-insensitive", true, "+++ Movies/c.mkv\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"Movies/", "Movies/Alien.mkv", "Movies/b.mkv"}), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "/new/Movies/alien.mkv", []byte("a"), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "/new/Movies/B.mkv", []byte("b"), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "/new/Movies/c.mkv", []byte("c"), 0o644))
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{IgnoreCase: tt.ignoreCase})
+			_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new", "", nil)
+			require.ErrorIs(t, err, ErrDiffsFound)
+
+			require.Equal(t, tt.stdout, stdoutBuf.String())
+		})
+	}
+}
+
+// Expectation: The prefixes from the table should be cleaned, or rejected if invalid or ambiguous.
+func Test_parseDiffPrefixes_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		added   string
+		removed string
+		want    [2]string
+		ok      bool
+	}{
+		{"Defaults", "+++", "---", [2]string{"+++", "---"}, true},
+		{"Named", "added/", "/removed", [2]string{"added", "removed"}, true},
+		{"Nested", "diff/added", "diff/removed", [2]string{"diff/added", "diff/removed"}, true},
+		{"Empty", "", "---", [2]string{}, false},
+		{"Root", "/", "---", [2]string{}, false},
+		{"Parent", "+++", "../removed", [2]string{}, false},
+		{"Same", "diff", "diff/", [2]string{}, false},
+		{"Drift", "~~~", "---", [2]string{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed, err := parseDiffPrefixes(tt.added, tt.removed)
+			if !tt.ok {
+				require.ErrorIs(t, err, errInvalidDiffPrefix)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, [2]string{added, removed})
+		})
+	}
+}
+
+// Expectation: The differences should be placed under the configured prefixes, with the printed ones unchanged.
+func Test_Program_Diff_Prefixes_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "c/"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{AddedPrefix: "added", RemovedPrefix: "removed"})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- b.txt\n+++ c/\n", stdoutBuf.String())
+	require.Equal(t, []string{"removed/b.txt", "added/c/"}, readTarNames(t, fs, "/diff.tar.gz"))
+}
+
+// Expectation: Only the amounts of the differences should be printed, per top-level directory and in total.
+func Test_Program_DiffSummary_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a/", "a/x.txt", "a/y.txt", "b/", "z.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a/", "a/y.txt", "b/", "b/c/", "b/c/d.txt", "z.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	result, err := prog.DiffSummary(t.Context(), []string{"/old.tar.gz"}, []string{"/new.tar.gz"}, nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+	require.Equal(t, uint64(1), result.ExtraA)
+	require.Equal(t, uint64(2), result.ExtraB)
+
+	require.Equal(t, "a/: removed: 1, added: 0\nb/: removed: 0, added: 2\nremoved: 1, added: 2\n", stdoutBuf.String())
+}
+
+// Expectation: The files at the top level should be grouped together, along the top-level directories.
+func Test_Program_DiffSummary_TopLevelFiles_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a/", "a/x.txt", "b.txt", "c.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a/", "d.txt", "e.txt", "f/"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	_, err := prog.DiffSummary(t.Context(), []string{"/old.tar.gz"}, []string{"/new.tar.gz"}, nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "a/: removed: 1, added: 0\n.: removed: 2, added: 2\nf/: removed: 0, added: 1\nremoved: 3, added: 3\n", stdoutBuf.String())
+}
+
+// Expectation: Nothing should be printed for identical sources.
+func Test_Program_DiffSummary_NoDiffs_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	_, err := prog.DiffSummary(t.Context(), []string{"/old.tar.gz"}, []string{"/new.tar.gz"}, nil)
+	require.NoError(t, err)
+	require.Empty(t, stdoutBuf.String())
+}
+
+// Expectation: The comparison should be stopped after the limit of differences, still returning ErrDiffsFound.
+func Test_Program_Diff_MaxDiffs_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b.txt", "c.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"d.txt", "e.txt"}), 0o644))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil, &ProgramConfig{MaxDiffs: 2})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- a.txt\n--- b.txt\n", stdoutBuf.String())
+	require.Contains(t, stderrBuf.String(), "stopped after the first 2 differences")
+	require.Equal(t, []string{"---/a.txt", "---/b.txt"}, readTarNames(t, fs, "/diff.tar.gz"))
+}
+
+// Expectation: The comparison should be stopped after the first difference, with the message in the singular.
+func Test_Program_Diff_StopOnFirst_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"c.txt"}), 0o644))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil, &ProgramConfig{MaxDiffs: 1})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- a.txt\n", stdoutBuf.String())
+	require.Contains(t, stderrBuf.String(), "stopped after the first difference\n")
+}
+
+// Expectation: The comparison should run to completion with fewer differences than the limit.
+func Test_Program_Diff_MaxDiffs_BelowLimit_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"b.txt", "c.txt"}), 0o644))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil, &ProgramConfig{MaxDiffs: 5})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- a.txt\n+++ c.txt\n", stdoutBuf.String())
+	require.Empty(t, stderrBuf.String())
+}
+
+// Expectation: Two tarballs with the same fingerprint should be identical without a comparison.
+func Test_Program_Diff_SameFingerprints_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Comment: "old"})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/old.tar.gz", nil))
+
+	prog = NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Comment: "new"})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/new.tar.gz", nil))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil, nil)
+	result, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.NoError(t, err)
+
+	require.Zero(t, result.ExtraA+result.ExtraB)
+	require.Empty(t, stdoutBuf.String())
+	require.Contains(t, stderrBuf.String(), "identical by the fingerprints")
+
+	_, err = fs.Stat("/diff.tar.gz")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: Two tarballs with different fingerprints should be compared as usual.
+func Test_Program_Diff_DifferentFingerprints_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Annotate: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/old.tar.gz", nil))
+
+	require.NoError(t, afero.WriteFile(fs, "/src/b.txt", []byte("b"), 0o644))
+	require.NoError(t, prog.Create(t.Context(), "/src", "/new.tar.gz", nil))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil, nil)
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "+++ b.txt\n", stdoutBuf.String())
+	require.Empty(t, stderrBuf.String())
+}
+
+// Expectation: The fingerprints should not be relied upon with excludes of only either side.
+func Test_Program_Diff_SameFingerprints_ExcludesOld_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b.txt", []byte("b"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Annotate: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/old.tar.gz", nil))
+	require.NoError(t, prog.Create(t.Context(), "/src", "/new.tar.gz", nil))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil, &ProgramConfig{ExcludesOld: []string{"b.txt"}})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "+++ b.txt\n", stdoutBuf.String())
+	require.Empty(t, stderrBuf.String())
+}
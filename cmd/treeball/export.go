@@ -0,0 +1,228 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ExportFormat is a format of the output of a [Program.Export] operation.
+type ExportFormat int
+
+const (
+	// ExportSQLite is an SQLite database (see [Program.Export]).
+	ExportSQLite ExportFormat = iota
+)
+
+var errInvalidExportFormat = errors.New("invalid export format")
+
+// parseExportFormat returns the [ExportFormat] for a format name (as for --format).
+func parseExportFormat(name string) (ExportFormat, error) {
+	switch strings.ToLower(name) {
+	case "", "sqlite":
+		return ExportSQLite, nil
+	default:
+		return ExportSQLite, fmt.Errorf("%w: %q (expected sqlite)", errInvalidExportFormat, name)
+	}
+}
+
+// archiveEntry is an entry of a tarball along with its recorded metadata, as
+// written by [Program.Export] (and by [Program.List] as JSON Lines).
+type archiveEntry struct {
+	Path    string     `json:"path"`            // Path of the entry (with a trailing slash for directories)
+	Type    string     `json:"type"`            // Type of the entry ("file" or "dir")
+	Size    *int64     `json:"size,omitempty"`  // Size of the entry (nil: directories)
+	ModTime *time.Time `json:"mtime,omitempty"` // Modification time of the entry (nil: not recorded)
+
+	TypeFlag byte   `json:"-"` // Type flag of the tar header (zero: not recorded, see [formatLongEntry])
+	Mode     *int64 `json:"-"` // Permission bits of the tar header (nil: not recorded, see [formatLongEntry])
+}
+
+// Export writes the entries of a given tarball into a file of another format,
+// for further processing (such as reporting or ad-hoc queries) by other tools.
+//
+// The input parameter specifies the path to the tarball, the output parameter
+// the path of the file to create in the given format. With [ExportSQLite], the
+// entries are written into the "entries" table of an SQLite database, with the
+// columns "path", "type" ("file" or "dir"), "size" (NULL for directories) and
+// "mtime" (in seconds since the epoch, NULL where not recorded in the tarball,
+// as for the zero-byte dummies written by [Program.Create]). Any paths
+// matching the excludes slice are skipped, as are any outside of the
+// [ProgramConfig.Subdir] (if there is one). The output file is protected and
+// removed upon failure as with [Program.Create]. The amount of entries is
+// printed at the end and returned. The ctx parameter controls early cancellation.
+func (prog *Program) Export(ctx context.Context, input string, output string, format ExportFormat, excludes []string) (int64, error) {
+	var exportDone bool
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	progressFrom(ctx).setPhase("exporting %s", input)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input, output)
+	if err != nil {
+		return 0, err
+	}
+	defer closeRemotes()
+
+	out, err := prog.createOutput(ctx, output)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	defer func() {
+		if !exportDone {
+			_ = prog.fs.Remove(output)
+		}
+	}()
+	defer out.Close()
+
+	// The database is built in a temporary file on the local disk, as SQLite
+	// needs to access it randomly, and only then copied to the output file
+	// (which can so also be on a remote, such as in object storage).
+	tmp, err := os.CreateTemp(prog.extSortConfig.TempFilesDir, "treeball-export-*.db")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	entries, errs := prog.tarEntryStream(ctx, input, excludes)
+
+	var count int64
+
+	switch format {
+	case ExportSQLite:
+		count, err = writeSQLite(ctx, tmp.Name(), entries)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failure during export: %w", err)
+	}
+
+	for err := range errs {
+		if err != nil {
+			return 0, fmt.Errorf("failure during export: %w", err)
+		}
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open temporary file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(out, contextReader{ctx: ctx, r: f}); err != nil {
+		return 0, fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close output file: %w", err)
+	}
+
+	exportDone = true
+
+	fmt.Fprintf(prog.stdout, "entries: %d\n", count)
+
+	return count, nil
+}
+
+// tarEntryStream returns a stream of the entries of a tarball in the original
+// archive's order, skipping any paths excluded as with [Program.tarPathStream],
+// and any outside of the [ProgramConfig.Subdir] (if there is one).
+func (prog *Program) tarEntryStream(ctx context.Context, path string, excludes []string) (<-chan archiveEntry, <-chan error) {
+	entries := make(chan archiveEntry, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	matcher := prog.excludeMatcher(excludes)
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(entries)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		f, err := prog.openArchive(path)
+		if err != nil {
+			errs <- fmt.Errorf("failed to open input file: %w", err)
+
+			return
+		}
+		defer f.Close()
+
+		cr := &countingReader{r: contextReader{ctx: ctx, r: f}}
+
+		ar, err := newArchiveReader(cr)
+		if err != nil {
+			errs <- err
+
+			return
+		}
+		defer ar.Close()
+
+		tc := &countingReader{r: ar}
+		tr := newConcatTarReader(tc, false)
+
+		var index int64
+		var prevPath string
+
+		for ; ; index++ {
+			offset, compressedOffset := tc.n.Load(), cr.n.Load()
+
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			} else if err != nil {
+				errs <- fmt.Errorf("failed to stream from tar: %w", &StreamError{
+					Index: index, PrevPath: prevPath,
+					Offset: offset, CompressedOffset: compressedOffset, Err: err,
+				})
+
+				return
+			}
+
+			if hdr.Typeflag == tar.TypeXGlobalHeader {
+				continue // Annotations of the archive (see Program.Show), not an entry.
+			}
+
+			isDir := strings.HasSuffix(hdr.Name, "/")
+
+			if excluded, err := prog.isExcluded(hdr.Name, isDir, matcher); err != nil {
+				errs <- fmt.Errorf("failed to check for exclusion: %w", err)
+
+				return
+			} else if !excluded && prog.inSubdir(hdr.Name) {
+				entry := archiveEntry{Path: hdr.Name, Type: manifestTypeFile, TypeFlag: hdr.Typeflag, Mode: &hdr.Mode}
+
+				if isDir {
+					entry.Type = manifestTypeDir
+				} else {
+					entry.Size = &hdr.Size
+				}
+
+				if !hdr.ModTime.IsZero() && hdr.ModTime.Unix() > 0 {
+					modTime := hdr.ModTime.UTC()
+					entry.ModTime = &modTime
+				}
+
+				select {
+				case entries <- entry:
+				case <-ctx.Done():
+					errs <- fmt.Errorf("failed to stream from tar: %w", ctx.Err())
+
+					return
+				}
+			}
+
+			prevPath = hdr.Name
+			progress.addEntry()
+		}
+	}()
+
+	return entries, errs
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The export formats should be parsed from their names.
+func Test_parseExportFormat_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ExportFormat
+		wantErr bool
+	}{
+		{"", ExportSQLite, false},
+		{"sqlite", ExportSQLite, false},
+		{"SQLite", ExportSQLite, false},
+		{"csv", ExportSQLite, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExportFormat(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidExportFormat)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
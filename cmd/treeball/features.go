@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Feature is an optional subsystem, which can be left out of a build using build tags.
+//
+// All features are compiled in by default, while building with the "minimal" tag leaves
+// out all of them (for small builds, e.g. for embedded or NAS systems). Any individual
+// feature can also be left out using the respective "no_<name>" build tag instead.
+type Feature struct {
+	Name        string // Name of the feature (as used in the build tag)
+	Description string // Short description of the feature
+	Enabled     bool   // Feature is compiled into the build
+}
+
+// Features returns all optional features, and whether they were compiled into the build.
+func Features() []Feature {
+	return []Feature{
+		{Name: "pager", Description: "piping of output into an interactive pager", Enabled: featurePager},
+		{Name: "tui", Description: "interactive side-by-side viewing of differences (diff --tui)", Enabled: featureTUI},
+		{Name: "metrics", Description: "serving of Prometheus metrics (--metrics-listen)", Enabled: featureMetrics},
+		{Name: "serve", Description: "serving of a REST API over archives (serve)", Enabled: featureServe},
+		{Name: "watch", Description: "continuous snapshotting of directory trees (watch)", Enabled: featureWatch},
+		{Name: "sftp", Description: "reading of remote sources over SSH (ssh://)", Enabled: featureSFTP},
+		{Name: "s3", Description: "sources and archives in S3-compatible object storage (s3://)", Enabled: featureS3},
+		{Name: "http", Description: "reading of archives from web servers (http://, https://)", Enabled: featureHTTP},
+		{Name: "rclone", Description: "reading of remote sources through rclone (rclone://)", Enabled: featureRclone},
+		{Name: "sqlite", Description: "exporting of listings into SQLite databases (export)", Enabled: featureSQLite},
+		{Name: "sign", Description: "detached SSH signatures of archives (--sign-key)", Enabled: featureSign},
+	}
+}
+
+// printFeatures writes a human-readable table of all optional features to w.
+func printFeatures(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:mnd
+
+	for _, f := range Features() {
+		state := "disabled"
+		if f.Enabled {
+			state = "enabled"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Name, state, f.Description)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write features: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: All features should be described and reflect their build state.
+func Test_Features_Success(t *testing.T) {
+	features := Features()
+	require.NotEmpty(t, features)
+
+	for _, f := range features {
+		require.NotEmpty(t, f.Name)
+		require.NotEmpty(t, f.Description)
+
+		if f.Name == "pager" {
+			require.Equal(t, featurePager, f.Enabled)
+		}
+	}
+}
+
+// Expectation: The features should be printed as a table, one feature per line.
+func Test_printFeatures_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, printFeatures(&buf))
+	require.Contains(t, buf.String(), "pager")
+}
+
+// Expectation: The features should not be printed to a failing writer.
+func Test_printFeatures_Write_Error(t *testing.T) {
+	require.Error(t, printFeatures(errorWriter{}))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// syncedFile is an output file that is synced to its storage upon closing,
+// along with its directory (see [ProgramConfig.Fsync]), so that a completed
+// command means a written output, even if the storage (such as an USB disk
+// or network mount) goes away right after, rather than just a cached one.
+type syncedFile struct {
+	afero.File
+
+	fs     afero.Fs
+	closed bool
+}
+
+// syncOnClose returns the file as a [syncedFile] with [ProgramConfig.Fsync],
+// otherwise the file as-is.
+func (prog *Program) syncOnClose(f afero.File) afero.File {
+	if !prog.config.Fsync {
+		return f
+	}
+
+	return &syncedFile{File: f, fs: prog.fs}
+}
+
+// Close is a method that syncs and closes the file, then syncs its directory.
+// It only closes the file for any further calls (as for any deferred ones).
+func (f *syncedFile) Close() error {
+	if f.closed {
+		return f.File.Close() //nolint:wrapcheck
+	}
+	f.closed = true
+
+	if err := f.File.Sync(); err != nil {
+		_ = f.File.Close()
+
+		return fmt.Errorf("failed to sync output file: %w", err)
+	}
+
+	if err := f.File.Close(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return syncDir(f.fs, filepath.Dir(f.Name()))
+}
+
+// syncDir syncs a directory to its storage, so that the entries created in it
+// (or renamed into it) persist. Any directories that cannot be synced (as on
+// Windows, or without directories, as on object storages) are left as they are.
+func syncDir(fsys afero.Fs, dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := fsys.Open(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to open output directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, errors.ErrUnsupported) {
+		return fmt.Errorf("failed to sync output directory: %w", err)
+	}
+
+	return nil
+}
+
+// verifyOutput re-reads an output archive once written and closed, decoding
+// all of its entries (see [ProgramConfig.VerifyWrites]), so that a truncated
+// or otherwise corrupted output fails the command, rather than being noticed
+// only once it is needed. Any cached parts of the archive are dropped first
+// (see [dropCache]), so that it is read back from its storage where possible.
+func (prog *Program) verifyOutput(ctx context.Context, output string) error {
+	progressFrom(ctx).setPhase("verifying %s", output)
+
+	parts, err := prog.archiveParts(output)
+	if err != nil {
+		return fmt.Errorf("failed to verify output file: %w", err)
+	}
+
+	for _, part := range parts {
+		if f, err := prog.fs.Open(part); err == nil {
+			dropCache(f)
+			_ = f.Close()
+		}
+	}
+
+	f, err := prog.openArchive(output)
+	if err != nil {
+		return fmt.Errorf("failed to verify output file: %w", err)
+	}
+	defer f.Close()
+
+	ar, err := newArchiveReader(contextReader{ctx: ctx, r: f})
+	if err != nil {
+		return fmt.Errorf("failed to verify output file: %w", err)
+	}
+	defer ar.Close()
+
+	tr := newConcatTarReader(ar, true)
+
+	var entries int64
+
+	for ; ; entries++ {
+		if _, err := tr.Next(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to verify output file: %w (after %d entries)", err, entries)
+		}
+	}
+
+	prog.infof("verified %q (%d entries)", output, entries)
+
+	return nil
+}
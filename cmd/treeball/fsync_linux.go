@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/afero"
+	"golang.org/x/sys/unix"
+)
+
+// dropCache drops the cached pages of a (synced) local file, so that it is
+// read back from its storage. Files of any other filesystems are left as-is.
+func dropCache(f afero.File) {
+	if osf, ok := f.(*os.File); ok {
+		_ = unix.Fadvise(int(osf.Fd()), 0, 0, unix.FADV_DONTNEED) //nolint:gosec
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+var errSyncFailed = errors.New("sync failed")
+
+// failingSyncFile is an [afero.File] of which all syncs fail.
+type failingSyncFile struct {
+	afero.File
+}
+
+func (f failingSyncFile) Sync() error {
+	return errSyncFailed
+}
+
+// Expectation: The output file should be synced and closed, also when closed more than once.
+func Test_syncedFile_Success(t *testing.T) {
+	dir := t.TempDir()
+	fs := afero.NewOsFs()
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Fsync: true})
+
+	out, err := prog.createOutput(t.Context(), filepath.Join(dir, "out.txt"))
+	require.NoError(t, err)
+	require.IsType(t, &syncedFile{}, out)
+
+	_, err = out.Write([]byte("test"))
+	require.NoError(t, err)
+
+	require.NoError(t, out.Close())
+	require.Error(t, out.Close()) // Already closed.
+
+	data, err := afero.ReadFile(fs, filepath.Join(dir, "out.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "test", string(data))
+}
+
+// Expectation: The output file should not be wrapped without the option.
+func Test_syncOnClose_Disabled_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{})
+
+	f, err := fs.Create("/out.txt")
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.Equal(t, f, prog.syncOnClose(f))
+}
+
+// Expectation: A failing sync of the output file should fail its closing.
+func Test_syncedFile_Sync_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	f, err := fs.Create("/out.txt")
+	require.NoError(t, err)
+
+	sf := &syncedFile{File: failingSyncFile{f}, fs: fs}
+	require.ErrorIs(t, sf.Close(), errSyncFailed)
+}
+
+// Expectation: A directory that does not exist should be left as it is.
+func Test_syncDir_Missing_Success(t *testing.T) {
+	require.NoError(t, syncDir(afero.NewMemMapFs(), "/missing"))
+}
+
+// Expectation: A directory should be synced to its storage.
+func Test_syncDir_Success(t *testing.T) {
+	require.NoError(t, syncDir(afero.NewOsFs(), t.TempDir()))
+}
+
+// Expectation: A written archive should be read back and decoded without an error.
+func Test_verifyOutput_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", createTar([]string{"a.txt", "b/"}), 0o644))
+
+	var stderrBuf bytes.Buffer
+
+	prog := NewProgram(fs, io.Discard, &stderrBuf, nil, nil, &ProgramConfig{VerifyWrites: true})
+	require.NoError(t, prog.verifyOutput(t.Context(), "/out.tar.gz"))
+	require.Contains(t, stderrBuf.String(), `verified "/out.tar.gz" (2 entries)`)
+}
+
+// Expectation: A truncated archive should fail its verification.
+func Test_verifyOutput_Truncated_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	data := createTar([]string{"a.txt", "b.txt"})
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", data[:len(data)-20], 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{VerifyWrites: true})
+	require.ErrorContains(t, prog.verifyOutput(t.Context(), "/out.tar.gz"), "failed to verify output file")
+}
+
+// Expectation: A missing archive should fail its verification.
+func Test_verifyOutput_Missing_Error(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{VerifyWrites: true})
+	require.ErrorContains(t, prog.verifyOutput(t.Context(), "/out.tar.gz"), "failed to verify output file")
+}
+
+// Expectation: The outputs of the commands should be synced and verified given the flags.
+func Test_CLI_FsyncVerify_Success(t *testing.T) {
+	dir := t.TempDir()
+	fs := afero.NewOsFs()
+
+	require.NoError(t, fs.MkdirAll(filepath.Join(dir, "input", "sub"), 0o755))
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(dir, "input", "sub", "file.txt"), []byte("test"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(dir, "old.tar.gz"), createTar([]string{"a.txt"}), 0o644))
+
+	var stderrBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, &stderrBuf)
+	cmd.SetArgs([]string{"create", filepath.Join(dir, "input"), filepath.Join(dir, "new.tar.gz"), "--fsync", "--verify-after-write"})
+	require.NoError(t, cmd.Execute())
+	require.Contains(t, stderrBuf.String(), "verified")
+
+	cmd = newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"diff", filepath.Join(dir, "old.tar.gz"), filepath.Join(dir, "new.tar.gz"), filepath.Join(dir, "diff.tar.gz"), "--fsync", "--verify-after-write"})
+	require.ErrorIs(t, cmd.Execute(), ErrDiffsFound)
+
+	cmd = newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"normalize", filepath.Join(dir, "diff.tar.gz"), filepath.Join(dir, "normalized.tar.gz"), "--fsync", "--verify-after-write"})
+	require.NoError(t, cmd.Execute())
+
+	require.Contains(t, readTarNames(t, fs, filepath.Join(dir, "normalized.tar.gz")), "+++/sub/file.txt")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/spf13/afero"
+)
+
+const gitIgnoreFile = ".gitignore"
+
+// gitIgnoreRule is a single parsed pattern line of a .gitignore file.
+type gitIgnoreRule struct {
+	pattern string // Pattern in 'doublestar' format (relative to the .gitignore location)
+	negate  bool   // Pattern re-includes previously ignored paths ("!" prefix)
+	dirOnly bool   // Pattern only matches directories ("/" suffix)
+}
+
+// gitIgnoreSet are all rules of a .gitignore file, located in the base directory.
+type gitIgnoreSet struct {
+	base  string // Relative (slash-terminated) directory of the .gitignore file
+	rules []gitIgnoreRule
+}
+
+// gitIgnoreStack holds the rule sets of all .gitignore files that apply to
+// the currently walked directory, ordered from the shallowest to the deepest.
+//
+// It relies on the walking order being depth-first, so that rule sets
+// of already fully walked directories can be discarded on the way.
+type gitIgnoreStack struct {
+	sets       []gitIgnoreSet
+	ignoreCase bool // Match the rules case-insensitively
+}
+
+// load parses the .gitignore file of the directory at path (if one exists)
+// and pushes its rules onto the stack. The relDir parameter is the directory
+// path relative to the walked root, which the patterns are anchored to.
+func (s *gitIgnoreStack) load(fsys afero.Fs, path string, relDir string) error {
+	f, err := fsys.Open(filepath.Join(path, gitIgnoreFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to open gitignore file: %w", err)
+	}
+	defer f.Close()
+
+	rules, err := parseGitIgnore(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse gitignore file (%s): %w", path, err)
+	}
+
+	if s.ignoreCase {
+		for i := range rules {
+			rules[i].pattern = strings.ToLower(rules[i].pattern)
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	base := filepath.ToSlash(relDir)
+	if base != "" && !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	s.pop(base)
+	s.sets = append(s.sets, gitIgnoreSet{base: base, rules: rules})
+
+	return nil
+}
+
+// pop discards all rule sets which are not applicable to the given path.
+func (s *gitIgnoreStack) pop(path string) {
+	for len(s.sets) > 0 {
+		if strings.HasPrefix(path, s.sets[len(s.sets)-1].base) {
+			return
+		}
+		s.sets = s.sets[:len(s.sets)-1]
+	}
+}
+
+// match returns if a path (relative to the walked root) is ignored.
+// As with git, the last matching rule decides about the path's fate.
+func (s *gitIgnoreStack) match(path string, isDir bool) bool {
+	var ignored bool
+
+	path = filepath.ToSlash(filepath.Clean(path))
+	s.pop(path)
+
+	for _, set := range s.sets {
+		rel := strings.TrimPrefix(path, set.base)
+		if s.ignoreCase {
+			rel = strings.ToLower(rel)
+		}
+
+		for _, rule := range set.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+
+			// Patterns were validated during parsing, errors are impossible.
+			if matched, _ := doublestar.Match(rule.pattern, rel); matched {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	return ignored
+}
+
+// parseGitIgnore parses the contents of a .gitignore file into a set of rules.
+func parseGitIgnore(r io.Reader) ([]gitIgnoreRule, error) {
+	var rules []gitIgnoreRule
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		rule, ok := parseGitIgnoreLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		if !doublestar.ValidatePattern(rule.pattern) {
+			return nil, fmt.Errorf("invalid pattern: %q", scanner.Text())
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading: %w", err)
+	}
+
+	return rules, nil
+}
+
+// parseGitIgnoreLine converts a line of a .gitignore file into a rule.
+// The returned boolean is false for lines not containing any pattern.
+func parseGitIgnoreLine(line string) (gitIgnoreRule, bool) {
+	var rule gitIgnoreRule
+
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are ignored, unless they are escaped with a backslash.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimSuffix(line, " ")
+	}
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	if line == "" {
+		return rule, false
+	}
+
+	// A separator at the beginning or in the middle anchors the pattern to
+	// the .gitignore location, otherwise it may match at any level below it.
+	if strings.Contains(line, "/") {
+		rule.pattern = strings.TrimPrefix(line, "/")
+	} else {
+		rule.pattern = "**/" + line
+	}
+
+	return rule, true
+}
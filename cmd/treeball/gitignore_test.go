@@ -0,0 +1,185 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The lines from the table should be parsed into their respective rules.
+func Test_parseGitIgnoreLine_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected gitIgnoreRule
+		ok       bool
+	}{
+		{"Empty line", "", gitIgnoreRule{}, false},
+		{"Whitespace line", "   ", gitIgnoreRule{}, false},
+		{"Comment line", "# comment", gitIgnoreRule{}, false},
+		{"Lone negation", "!", gitIgnoreRule{}, false},
+		{"Lone slash", "/", gitIgnoreRule{}, false},
+		{"Unanchored file", "*.log", gitIgnoreRule{pattern: "**/*.log"}, true},
+		{"Unanchored dir", "build/", gitIgnoreRule{pattern: "**/build", dirOnly: true}, true},
+		{"Anchored by leading slash", "/build", gitIgnoreRule{pattern: "build"}, true},
+		{"Anchored by middle slash", "docs/*.md", gitIgnoreRule{pattern: "docs/*.md"}, true},
+		{"Negation", "!keep.log", gitIgnoreRule{pattern: "**/keep.log", negate: true}, true},
+		{"Negated dir", "!/out/", gitIgnoreRule{pattern: "out", negate: true, dirOnly: true}, true},
+		{"Escaped hash", "\\#file", gitIgnoreRule{pattern: "**/\\#file"}, true},
+		{"Escaped negation", "\\!file", gitIgnoreRule{pattern: "**/\\!file"}, true},
+		{"Trailing spaces", "a.txt   ", gitIgnoreRule{pattern: "**/a.txt"}, true},
+		{"Escaped trailing space", "a.txt\\ ", gitIgnoreRule{pattern: "**/a.txt\\ "}, true},
+		{"Carriage return", "a.txt\r", gitIgnoreRule{pattern: "**/a.txt"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := parseGitIgnoreLine(tt.line)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				require.Equal(t, tt.expected, rule)
+			}
+		})
+	}
+}
+
+// Expectation: An invalid pattern in a .gitignore file should produce an error.
+func Test_parseGitIgnore_InvalidPattern_Error(t *testing.T) {
+	_, err := parseGitIgnore(strings.NewReader("*.log\nfoo[\n"))
+
+	require.Error(t, err)
+	require.ErrorContains(t, err, "invalid pattern")
+}
+
+// Expectation: The rules of nested .gitignore files should be respected, with the last match deciding.
+func Test_gitIgnoreStack_Match_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/.gitignore", []byte("*.log\n!keep.log\n/build/\n"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/sub/.gitignore", []byte("!*.log\nlocal.txt\n"), 0o644))
+
+	s := &gitIgnoreStack{}
+	require.NoError(t, s.load(fs, "/src", ""))
+
+	require.True(t, s.match("a.log", false))
+	require.False(t, s.match("keep.log", false))
+	require.True(t, s.match("build", true))
+	require.False(t, s.match("build", false))
+	require.False(t, s.match("other/build", true))
+	require.True(t, s.match("other/a.log", false))
+	require.False(t, s.match("local.txt", false))
+
+	require.NoError(t, s.load(fs, "/src/sub", "sub"))
+
+	require.False(t, s.match("sub/a.log", false))
+	require.True(t, s.match("sub/local.txt", false))
+	require.True(t, s.match("sub/deep/local.txt", false))
+
+	require.False(t, s.match("subway/local.txt", false))
+	require.True(t, s.match("subway/a.log", false))
+}
+
+// Expectation: A non-existing .gitignore file should not produce an error or any rules.
+func Test_gitIgnoreStack_Load_Missing_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, fs.MkdirAll("/src", 0o755))
+
+	s := &gitIgnoreStack{}
+	require.NoError(t, s.load(fs, "/src", ""))
+	require.Empty(t, s.sets)
+}
+
+// Expectation: The .gitignore files should be applied during the creation of a tarball.
+func Test_Program_Create_GitIgnore_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/.gitignore", []byte("*.log\n/build/\n"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/a.log", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/build/out.bin", []byte("b"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/sub/.gitignore", []byte("!debug.log\n"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/sub/debug.log", []byte("d"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/sub/trace.log", []byte("t"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/sub/build/out.bin", []byte("b"), 0o644))
+
+	var stdoutBuf strings.Builder
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{GitIgnore: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	paths := strings.Split(strings.TrimSpace(stdoutBuf.String()), "\n")
+	require.Equal(t, []string{
+		".gitignore",
+		"a.txt",
+		"sub",
+		"sub/.gitignore",
+		"sub/build",
+		"sub/build/out.bin",
+		"sub/debug.log",
+	}, paths)
+}
+
+// Expectation: The .gitignore files should not be applied when the option is not enabled.
+func Test_Program_fsPathStream_GitIgnoreDisabled_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/.gitignore", []byte("*.log\n"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/a.log", []byte("a"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.fsPathStream(t.Context(), "/src", "", true, nil)
+
+	got := make([]string, 0, len(paths))
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{".gitignore", "a.log"}, got)
+}
+
+// Expectation: An invalid .gitignore file should produce an error during walking.
+func Test_Program_fsPathStream_GitIgnoreInvalid_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/sub/.gitignore", []byte("foo[\n"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/sub/a.log", []byte("a"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{GitIgnore: true})
+	paths, errs := prog.fsPathStream(t.Context(), "/src", "", false, nil)
+
+	for range paths {
+		t.Fatal("should not emit paths")
+	}
+
+	select {
+	case err := <-errs:
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "gitignore")
+	default:
+		t.Fatal("expected error from fsPathStream")
+	}
+}
+
+// Expectation: The rules should be matched case-insensitively when enabled.
+func Test_gitIgnoreStack_Match_IgnoreCase_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/.gitignore", []byte("*.LOG\n"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/Sub/.gitignore", []byte("Local.txt\n"), 0o644))
+
+	s := &gitIgnoreStack{ignoreCase: true}
+	require.NoError(t, s.load(fs, "/src", ""))
+	require.NoError(t, s.load(fs, "/src/Sub", "Sub"))
+
+	require.True(t, s.match("Sub/a.log", false))
+	require.True(t, s.match("Sub/LOCAL.TXT", false))
+	require.False(t, s.match("Sub/other.txt", false))
+}
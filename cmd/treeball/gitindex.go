@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	gitSourcePrefix = "git:"
+
+	gitIndexSignature = "DIRC"
+	gitIndexEntrySize = 62     // Fixed size of an index entry (up to and including its flags)
+	gitModeDir        = 040000 // Mode of a directory entry (of a sparse index)
+	gitModeGitlink    = 0160000
+	gitFlagExtended   = 0x4000
+	gitFlagNameMask   = 0x0fff
+)
+
+var errInvalidGitIndex = errors.New("invalid git index")
+
+// gitIndexPath returns the path of the index file of the git repository with
+// the worktree at repo, also resolving any .git file (of linked worktrees and
+// submodules) pointing to the actual git directory elsewhere.
+func (prog *Program) gitIndexPath(repo string) (string, error) {
+	dotGit := joinPath(repo, ".git")
+
+	info, err := prog.fs.Stat(dotGit)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat git directory: %w", err)
+	}
+
+	if info.IsDir() {
+		return joinPath(dotGit, "index"), nil
+	}
+
+	f, err := prog.fs.Open(dotGit)
+	if err != nil {
+		return "", fmt.Errorf("failed to open git file: %w", err)
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read git file: %w", err)
+	}
+
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(line), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("%w: %q is neither a directory nor a gitdir file", errInvalidGitIndex, dotGit)
+	}
+
+	if !filepath.IsAbs(gitDir) && !strings.HasPrefix(gitDir, "/") {
+		gitDir = joinPath(repo, gitDir)
+	}
+
+	return joinPath(gitDir, "index"), nil
+}
+
+// gitPathStream returns a stream of the paths tracked in the index of the git
+// repository with the worktree at repo (as of "git ls-files"), in the same form
+// as those of a tarball, skipping any paths excluded as with [Program.tarPathStream].
+//
+// The index only records files (and submodules), so any directories containing
+// them are streamed as well (with a trailing slash), as they would be contained
+// in an archive of the worktree. Submodules are streamed as (empty) directories.
+// The .git directory itself is never streamed, as it is not tracked in the index.
+func (prog *Program) gitPathStream(ctx context.Context, repo string, sort bool, excludes []string) (<-chan string, <-chan error) {
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	matcher := prog.excludeMatcher(excludes)
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		indexPath, err := prog.gitIndexPath(repo)
+		if err != nil {
+			errs <- err
+
+			return
+		}
+
+		f, err := prog.fs.Open(indexPath)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return // A repository without any commits or staged files has no index.
+			}
+			errs <- fmt.Errorf("failed to open git index: %w", err)
+
+			return
+		}
+		defer f.Close()
+
+		emit := func(p string) error {
+			if excluded, err := prog.isExcluded(p, strings.HasSuffix(p, "/"), matcher); err != nil {
+				return fmt.Errorf("failed to check for exclusion: %w", err)
+			} else if excluded {
+				return nil
+			}
+
+			select {
+			case paths <- p:
+			case <-ctx.Done():
+				return fmt.Errorf("failed to stream from git index: %w", ctx.Err())
+			}
+
+			progress.addEntry()
+
+			return nil
+		}
+
+		// The index is sorted by the paths' bytes, so the contents of any one
+		// directory are contiguous, and a directory was already streamed if it
+		// contains the directory of the previous entry (or is that one itself).
+		var prevPath, prevDir string
+
+		err = readGitIndex(contextReader{ctx: ctx, r: f}, func(p string, mode uint32) error {
+			if p == prevPath {
+				return nil // Any further stages of a conflicted path.
+			}
+			prevPath = p
+
+			if mode&0170000 == gitModeDir || mode&0170000 == gitModeGitlink {
+				p = strings.TrimSuffix(p, "/") + "/"
+			}
+
+			for i := range len(p) - 1 {
+				if p[i] != '/' || strings.HasPrefix(prevDir, p[:i+1]) {
+					continue
+				}
+				if err := emit(p[:i+1]); err != nil {
+					return err
+				}
+			}
+
+			if err := emit(p); err != nil {
+				return err
+			}
+
+			if strings.HasSuffix(p, "/") {
+				prevDir = p
+			} else {
+				prevDir = p[:strings.LastIndex(p, "/")+1]
+			}
+
+			return nil
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	if !sort {
+		return paths, errs
+	}
+
+	return extsortStringsFunc(ctx, paths, errs, prog.extSortConfig, prog.comparePaths)
+}
+
+// readGitIndex reads a git index file (of versions 2 to 4) from r, calling fn
+// with the path and mode of every entry, in the order of the index (sorted by
+// the paths' bytes, with any conflicted paths being contained once per stage).
+func readGitIndex(r io.Reader, fn func(p string, mode uint32) error) error {
+	br := bufio.NewReader(r)
+
+	var header [12]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return fmt.Errorf("failed to read git index: %w", err)
+	}
+
+	if string(header[:4]) != gitIndexSignature {
+		return fmt.Errorf("%w: bad signature", errInvalidGitIndex)
+	}
+
+	version := binary.BigEndian.Uint32(header[4:8])
+	if version < 2 || version > 4 { //nolint:mnd
+		return fmt.Errorf("%w: unsupported version %d", errInvalidGitIndex, version)
+	}
+
+	count := binary.BigEndian.Uint32(header[8:12])
+
+	var entry [gitIndexEntrySize + 2]byte
+	var prev []byte
+
+	for i := range count {
+		if _, err := io.ReadFull(br, entry[:gitIndexEntrySize]); err != nil {
+			return fmt.Errorf("failed to read git index entry %d: %w", i, err)
+		}
+
+		mode := binary.BigEndian.Uint32(entry[24:28])
+		flags := binary.BigEndian.Uint16(entry[60:62])
+		size := gitIndexEntrySize
+
+		if flags&gitFlagExtended != 0 {
+			if version < 3 { //nolint:mnd
+				return fmt.Errorf("%w: extended flags in version %d", errInvalidGitIndex, version)
+			}
+			if _, err := io.ReadFull(br, entry[gitIndexEntrySize:]); err != nil {
+				return fmt.Errorf("failed to read git index entry %d: %w", i, err)
+			}
+			size += 2
+		}
+
+		var name []byte
+
+		if version == 4 { //nolint:mnd
+			strip, err := readGitVarint(br)
+			if err != nil {
+				return fmt.Errorf("failed to read git index entry %d: %w", i, err)
+			}
+			if strip > uint64(len(prev)) {
+				return fmt.Errorf("%w: entry %d strips beyond the previous path", errInvalidGitIndex, i)
+			}
+
+			suffix, err := br.ReadBytes(0)
+			if err != nil {
+				return fmt.Errorf("failed to read git index entry %d: %w", i, err)
+			}
+
+			name = append(prev[:len(prev)-int(strip):len(prev)-int(strip)], suffix[:len(suffix)-1]...)
+		} else {
+			var err error
+
+			name, err = br.ReadBytes(0)
+			if err != nil {
+				return fmt.Errorf("failed to read git index entry %d: %w", i, err)
+			}
+			name = name[:len(name)-1]
+
+			if n := int(flags & gitFlagNameMask); n < gitFlagNameMask && n != len(name) {
+				return fmt.Errorf("%w: entry %d has a mismatching name length", errInvalidGitIndex, i)
+			}
+
+			// Entries are padded with 1-8 NUL bytes (the first one read already).
+			padding := 8 - (size+len(name))%8 - 1
+			if _, err := br.Discard(padding); err != nil {
+				return fmt.Errorf("failed to read git index entry %d: %w", i, err)
+			}
+		}
+
+		if len(name) == 0 || bytes.HasPrefix(name, []byte("/")) {
+			return fmt.Errorf("%w: entry %d has an invalid path %q", errInvalidGitIndex, i, name)
+		}
+
+		if err := fn(string(name), mode); err != nil {
+			return err
+		}
+
+		prev = name
+	}
+
+	return nil
+}
+
+// readGitVarint reads a variable-length integer as encoded in git index files
+// of version 4 (the offset encoding of git, differing from [binary.Uvarint]).
+func readGitVarint(br io.ByteReader) (uint64, error) {
+	c, err := br.ReadByte()
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	val := uint64(c & 0x7f) //nolint:mnd
+
+	for c&0x80 != 0 {
+		if c, err = br.ReadByte(); err != nil {
+			return 0, err //nolint:wrapcheck
+		}
+		val = ((val + 1) << 7) | uint64(c&0x7f) //nolint:mnd
+	}
+
+	return val, nil
+}
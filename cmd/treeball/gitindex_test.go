@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// gitIndexEntry is an entry of a git index file created by [createGitIndex].
+type gitIndexEntry struct {
+	path  string
+	mode  uint32
+	stage uint16
+}
+
+// A helper function for tests to create a git index file (of version 2 or 4).
+func createGitIndex(t *testing.T, version uint32, entries []gitIndexEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	buf.WriteString(gitIndexSignature)
+	require.NoError(t, binary.Write(&buf, binary.BigEndian, version))
+	require.NoError(t, binary.Write(&buf, binary.BigEndian, uint32(len(entries))))
+
+	var prev string
+
+	for _, e := range entries {
+		fixed := make([]byte, gitIndexEntrySize)
+		binary.BigEndian.PutUint32(fixed[24:28], e.mode)
+		binary.BigEndian.PutUint16(fixed[60:62], e.stage<<12|uint16(min(len(e.path), gitFlagNameMask)))
+		buf.Write(fixed)
+
+		if version == 4 {
+			common := 0
+			for common < len(prev) && common < len(e.path) && prev[common] == e.path[common] {
+				common++
+			}
+			buf.Write(binary.AppendUvarint(nil, uint64(len(prev)-common))) // Same as git's encoding below 128.
+			buf.WriteString(e.path[common:])
+			buf.WriteByte(0)
+		} else {
+			buf.WriteString(e.path)
+			buf.Write(make([]byte, 8-(gitIndexEntrySize+len(e.path))%8))
+		}
+
+		prev = e.path
+	}
+
+	buf.Write(make([]byte, 20)) // Checksum (not verified)
+
+	return buf.Bytes()
+}
+
+// Expectation: The tracked paths should be streamed along with their directories.
+func Test_Program_gitPathStream_Table(t *testing.T) {
+	entries := []gitIndexEntry{
+		{path: "README.md", mode: 0o100644},
+		{path: "cmd/a/main.go", mode: 0o100644},
+		{path: "cmd/a/main_test.go", mode: 0o100644},
+		{path: "cmd/a-b/main.go", mode: 0o100755},
+		{path: "conflict.txt", mode: 0o100644, stage: 1},
+		{path: "conflict.txt", mode: 0o100644, stage: 2},
+		{path: "conflict.txt", mode: 0o100644, stage: 3},
+		{path: "vendor/lib", mode: gitModeGitlink},
+	}
+
+	want := []string{
+		"README.md",
+		"cmd/",
+		"cmd/a/",
+		"cmd/a/main.go",
+		"cmd/a/main_test.go",
+		"cmd/a-b/",
+		"cmd/a-b/main.go",
+		"conflict.txt",
+		"vendor/",
+		"vendor/lib/",
+	}
+
+	for _, version := range []uint32{2, 4} {
+		t.Run(map[uint32]string{2: "Version 2", 4: "Version 4"}[version], func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/repo/.git/index", createGitIndex(t, version, entries), 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+			paths, errs := prog.gitPathStream(t.Context(), "/repo", false, nil)
+
+			var got []string
+			for p := range paths {
+				got = append(got, p)
+			}
+			require.NoError(t, <-errs)
+
+			require.Equal(t, want, got)
+		})
+	}
+}
+
+// Expectation: The index of a linked worktree should be found through its .git file.
+func Test_Program_gitIndexPath_Worktree_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/wt/.git", []byte("gitdir: ../repo/.git/worktrees/wt\n"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	indexPath, err := prog.gitIndexPath("/wt")
+	require.NoError(t, err)
+	require.Equal(t, "/repo/.git/worktrees/wt/index", indexPath)
+}
+
+// Expectation: A file other than a git index should be rejected.
+func Test_readGitIndex_Invalid_Error(t *testing.T) {
+	err := readGitIndex(bytes.NewReader([]byte("not an index at all")), func(string, uint32) error {
+		return nil
+	})
+	require.ErrorIs(t, err, errInvalidGitIndex)
+}
+
+// Expectation: The git index should be compared against its worktree, showing any untracked drift.
+func Test_Program_Diff_Git_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/repo/.git/index", createGitIndex(t, 2, []gitIndexEntry{
+		{path: "deleted.go", mode: 0o100644},
+		{path: "src/main.go", mode: 0o100644},
+	}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/repo/src/main.go", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/repo/untracked.go", nil, 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	_, err := prog.Diff(t.Context(), "git:/repo", "/repo", "", []string{".git/**"})
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- deleted.go\n+++ untracked.go\n", stdoutBuf.String())
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/lanrat/extsort"
+	"github.com/spf13/cobra"
+)
+
+// addGlobalFlags declares the options applying to all of the subcommands as
+// persistent flags of the root command, rather than each subcommand declaring
+// them on its own (see [applyGlobalOptions]).
+func addGlobalFlags(rootCmd *cobra.Command) {
+	rootCmd.PersistentFlags().String("tmpdir", extSortConfigDefault.TempFilesDir, "on-disk location for intermediate files")
+	rootCmd.PersistentFlags().Int("workers", extSortConfigDefault.NumWorkers, "workers for concurrent operations")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "print only errors to standard error (no informational messages or warnings)")
+	rootCmd.PersistentFlags().String("color", "auto", "colorize the output (auto: only when a terminal, always, never)")
+}
+
+// applyGlobalOptions applies the global options (see [addGlobalFlags]) as given
+// to a command to its configurations (any of which may be nil), resolving the
+// colorization for output written to w. Any options not known to the command,
+// as when not run as a subcommand of the root command, are left at defaults.
+func applyGlobalOptions(cmd *cobra.Command, w io.Writer, sorterConfig *extsort.Config, programConfig *ProgramConfig) error {
+	flags := cmd.Flags()
+
+	color := "auto"
+	if f := flags.Lookup("color"); f != nil {
+		color = f.Value.String()
+	}
+
+	colorMode, err := parseColorMode(color)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate color arguments: %w", err)
+	}
+
+	if sorterConfig != nil {
+		if f := flags.Lookup("tmpdir"); f != nil {
+			sorterConfig.TempFilesDir = f.Value.String()
+		}
+		if workers, ok := intFlag(cmd, "workers"); ok {
+			sorterConfig.NumWorkers = workers
+		}
+	}
+
+	if programConfig != nil {
+		programConfig.Quiet, _ = flags.GetBool("quiet")
+		programConfig.Color = colorMode.resolve(w)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to run a subcommand of a root command with the
+// global flags, returning the configurations that the options were applied to.
+func runGlobalTestCmd(t *testing.T, args ...string) (ProgramConfig, error) {
+	t.Helper()
+
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	rootCmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+	addGlobalFlags(rootCmd)
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use: "sub",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := applyGlobalOptions(cmd, io.Discard, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			require.Equal(t, "/scratch", sorterConfig.TempFilesDir)
+			require.Equal(t, 7, sorterConfig.NumWorkers)
+
+			return nil
+		},
+	})
+	rootCmd.SetArgs(args)
+
+	return programConfig, rootCmd.Execute()
+}
+
+// Expectation: The global options should apply to the subcommands, given before or after them.
+func Test_applyGlobalOptions_Success(t *testing.T) {
+	config, err := runGlobalTestCmd(t, "--tmpdir=/scratch", "sub", "--workers=7", "-q", "--color=always")
+	require.NoError(t, err)
+
+	require.True(t, config.Quiet)
+	require.Equal(t, ColorAlways, config.Color)
+}
+
+// Expectation: An invalid color mode should be rejected.
+func Test_applyGlobalOptions_InvalidColor_Error(t *testing.T) {
+	_, err := runGlobalTestCmd(t, "sub", "--color=sometimes")
+	require.ErrorIs(t, err, errInvalidColorMode)
+}
+
+// Expectation: Commands not run as a subcommand of the root command should be left at defaults.
+func Test_applyGlobalOptions_NoFlags_Success(t *testing.T) {
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{Quiet: true}
+
+	require.NoError(t, applyGlobalOptions(&cobra.Command{Use: "test"}, io.Discard, &sorterConfig, &programConfig))
+
+	require.Equal(t, extSortConfigDefault, sorterConfig)
+	require.False(t, programConfig.Quiet)
+	require.Equal(t, ColorNever, programConfig.Color)
+}
+
+// Expectation: With --quiet, no informational messages or warnings should be printed.
+func Test_CLI_Quiet_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", []byte("previous"), 0o644))
+
+	var stderrBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, &stderrBuf)
+	cmd.SetArgs([]string{"-q", "create", "/src", "/out.tar.gz", "--backup", "--checksum=sha256"})
+	require.NoError(t, cmd.Execute())
+
+	require.Equal(t, []string{"a.txt"}, readTarNames(t, fs, "/out.tar.gz"))
+	require.Empty(t, stderrBuf.String())
+}
+
+// Expectation: Without --quiet, informational messages should be printed.
+func Test_CLI_NotQuiet_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", []byte("previous"), 0o644))
+
+	var stderrBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, &stderrBuf)
+	cmd.SetArgs([]string{"create", "/src", "/out.tar.gz", "--backup", "--checksum=sha256"})
+	require.NoError(t, cmd.Execute())
+
+	require.Contains(t, stderrBuf.String(), `backed up "/out.tar.gz" to "/out.tar.gz.bak"`)
+	require.Contains(t, stderrBuf.String(), "sha256: ")
+}
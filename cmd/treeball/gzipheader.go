@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var errInvalidGzipHeader = errors.New("invalid gzip header")
+
+// GzipHeader holds the metadata of the gzip headers of created tarballs, which
+// some archive management tools key off (and which are shown by 'show' and
+// 'verify', see [printGzipHeader]). By default, these are all empty.
+type GzipHeader struct {
+	Name    string    // Name of the compressed file (empty: none)
+	Comment string    // Comment on the compressed file (empty: none)
+	ModTime time.Time // Modification time of the compressed file (zero: that of ProgramConfig.ModTime)
+}
+
+// parseGzipHeader returns the [GzipHeader] of the arguments of --gzip-name,
+// --gzip-mtime and --gzip-comment. The modification time is either "now" or a
+// fixed time (see [parseModTime]). In reproducible mode (with a fixed time, see
+// [fixedModTime]), "now" is that time instead, as it would otherwise differ
+// between runs. The name and comment need to be Latin-1 (without NUL bytes),
+// as that is all that gzip headers can hold.
+func parseGzipHeader(name string, mtime string, comment string, fixed time.Time) (GzipHeader, error) {
+	for _, s := range []string{name, comment} {
+		if !isGzipHeaderString(s) {
+			return GzipHeader{}, fmt.Errorf("%w: %q (expected Latin-1 characters)", errInvalidGzipHeader, s)
+		}
+	}
+
+	hdr := GzipHeader{Name: name, Comment: comment}
+
+	switch strings.TrimSpace(mtime) {
+	case "":
+	case "now":
+		if fixed.IsZero() {
+			var err error
+			if fixed, err = fixedModTime(""); err != nil {
+				return GzipHeader{}, err
+			}
+		}
+
+		hdr.ModTime = fixed
+		if hdr.ModTime.IsZero() {
+			hdr.ModTime = time.Now().Truncate(time.Second).UTC()
+		}
+
+	default:
+		t, err := parseModTime(mtime)
+		if err != nil {
+			return GzipHeader{}, err
+		}
+		hdr.ModTime = t
+	}
+
+	return hdr, nil
+}
+
+// isGzipHeaderString returns if a string can be held by a gzip header, which
+// is Latin-1 (ISO 8859-1) without any NUL bytes (as these terminate it).
+func isGzipHeaderString(s string) bool {
+	for _, r := range s {
+		if r == 0 || r > 0xff {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readGzipHeader returns the gzip header of the first member of an archive, or
+// nil for archives without any (such as plain tarballs, see [newArchiveReader]).
+func (prog *Program) readGzipHeader(input string) (*gzip.Header, error) {
+	f, err := prog.openArchive(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+
+	if magic, err := br.Peek(len(gzipMagic)); err != nil || !bytes.Equal(magic, gzipMagic) {
+		return nil, nil //nolint:nilnil
+	}
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip header: %w", err)
+	}
+	defer zr.Close()
+
+	return &zr.Header, nil
+}
+
+// printGzipHeader writes to w the fields of the gzip header of an archive (see
+// [Program.readGzipHeader]) that are set, one "gzip-field: value" per line.
+func printGzipHeader(w io.Writer, hdr *gzip.Header) {
+	if hdr == nil {
+		return
+	}
+
+	if hdr.Name != "" {
+		fmt.Fprintf(w, "gzip-name: %s\n", hdr.Name)
+	}
+
+	if !hdr.ModTime.IsZero() {
+		fmt.Fprintf(w, "gzip-mtime: %s\n", hdr.ModTime.UTC().Format(time.RFC3339))
+	}
+
+	if hdr.Comment != "" {
+		fmt.Fprintf(w, "gzip-comment: %s\n", hdr.Comment)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The arguments of the gzip header should be parsed, with "now" being fixed in reproducible mode.
+func Test_parseGzipHeader_Table(t *testing.T) {
+	t.Setenv(sourceDateEpochEnvVar, "")
+
+	fixed := time.Unix(1700000000, 0).UTC()
+
+	tests := []struct {
+		name    string
+		gzName  string
+		mtime   string
+		comment string
+		fixed   time.Time
+		want    GzipHeader
+		wantErr bool
+	}{
+		{"Empty", "", "", "", time.Time{}, GzipHeader{}, false},
+		{"Name and comment", "tree.tar", "", "nightly", time.Time{}, GzipHeader{Name: "tree.tar", Comment: "nightly"}, false},
+		{"Latin-1", "café.tar", "", "", time.Time{}, GzipHeader{Name: "café.tar"}, false},
+		{"Seconds", "", "@0", "", time.Time{}, GzipHeader{ModTime: time.Unix(0, 0).UTC()}, false},
+		{"Now reproducible", "", "now", "", fixed, GzipHeader{ModTime: fixed}, false},
+		{"Not Latin-1", "日本.tar", "", "", time.Time{}, GzipHeader{}, true},
+		{"NUL byte", "", "", "a\x00b", time.Time{}, GzipHeader{}, true},
+		{"Invalid time", "", "soon", "", time.Time{}, GzipHeader{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGzipHeader(tt.gzName, tt.mtime, tt.comment, tt.fixed)
+			if tt.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The "now" of the gzip header should be the current time, unless a SOURCE_DATE_EPOCH is set.
+func Test_parseGzipHeader_Now_Success(t *testing.T) {
+	t.Setenv(sourceDateEpochEnvVar, "")
+
+	hdr, err := parseGzipHeader("", "now", "", time.Time{})
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), hdr.ModTime, time.Minute)
+
+	t.Setenv(sourceDateEpochEnvVar, "1700000000")
+
+	hdr, err = parseGzipHeader("", "now", "", time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1700000000, 0).UTC(), hdr.ModTime)
+}
+
+// Expectation: The gzip headers should carry the configured metadata.
+func Test_newArchiveWriter_GzipHeader_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	mtime := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, &gzipConfigDefault, nil, &ProgramConfig{
+		GzipHeader: GzipHeader{Name: "tree.tar", Comment: "nightly", ModTime: mtime},
+	})
+
+	gw, err := prog.newArchiveWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	require.Equal(t, "tree.tar", zr.Name)
+	require.Equal(t, "nightly", zr.Comment)
+	require.True(t, mtime.Equal(zr.ModTime))
+}
+
+// Expectation: The gzip headers should record no modification time at all by default.
+func Test_newArchiveWriter_NoModTime_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, &gzipConfigDefault, nil, &ProgramConfig{})
+
+	gw, err := prog.newArchiveWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	require.True(t, zr.ModTime.IsZero())
+	require.Empty(t, zr.Name)
+	require.Empty(t, zr.Comment)
+}
+
+// Expectation: A plain tarball should have no gzip header.
+func Test_readGzipHeader_Plain_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/plain.tar", make([]byte, 2*tarBlockSize), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	hdr, err := prog.readGzipHeader("/plain.tar")
+	require.NoError(t, err)
+	require.Nil(t, hdr)
+}
+
+// Expectation: The gzip header should be set by 'create' and shown by 'show' and 'verify'.
+func Test_CLI_GzipHeader_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, fs.MkdirAll("/src/sub", 0o755))
+	require.NoError(t, afero.WriteFile(fs, "/src/sub/file.txt", []byte("test"), 0o644))
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"create", "/src", "/out.tar.gz", "--gzip-name=tree.tar", "--gzip-mtime=@1700000000", "--gzip-comment=nightly"})
+	require.NoError(t, cmd.Execute())
+
+	want := "gzip-name: tree.tar\ngzip-mtime: 2023-11-14T22:13:20Z\ngzip-comment: nightly\n"
+
+	var showBuf, verifyBuf bytes.Buffer
+
+	cmd = newRootCmd(t.Context(), fs, &showBuf, io.Discard)
+	cmd.SetArgs([]string{"show", "/out.tar.gz"})
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, want, showBuf.String())
+
+	cmd = newRootCmd(t.Context(), fs, &verifyBuf, io.Discard)
+	cmd.SetArgs([]string{"verify", "/out.tar.gz"})
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, want+"entries: 2\n", verifyBuf.String())
+}
+
+// Expectation: A gzip header that cannot be held by gzip should be rejected.
+func Test_CLI_GzipHeader_Invalid_Error(t *testing.T) {
+	cmd := newRootCmd(t.Context(), afero.NewMemMapFs(), io.Discard, io.Discard)
+	cmd.SetArgs([]string{"create", "/src", "/out.tar.gz", "--gzip-comment=日本"})
+	require.ErrorIs(t, cmd.Execute(), errInvalidGzipHeader)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+var errNoHashAlgo = errors.New("no hash algorithm")
+
+// listingDigest is a digest of a listing of paths (or of records of paths with
+// their metadata, see [metadataRecord]), which is computed over every record as
+// its length (as an unsigned varint) followed by the record itself, so that no
+// two different listings have the same sequence of hashed bytes.
+type listingDigest struct {
+	h   hash.Hash
+	buf []byte
+}
+
+// newListingDigest returns a new [listingDigest] of the algorithm (nil: [ChecksumNone]).
+func newListingDigest(algo ChecksumAlgo) *listingDigest {
+	h := algo.newHash()
+	if h == nil {
+		return nil
+	}
+
+	return &listingDigest{h: h}
+}
+
+// add adds the next record of the listing to the digest.
+func (d *listingDigest) add(record string) {
+	d.buf = binary.AppendUvarint(d.buf[:0], uint64(len(record)))
+	d.buf = append(d.buf, record...)
+
+	_, _ = d.h.Write(d.buf) // Never returns an error.
+}
+
+// sum returns the digest of the records added so far.
+func (d *listingDigest) sum() []byte {
+	return d.h.Sum(nil)
+}
+
+// compareRecordTarOrder compares two records (see [metadataRecord]) by the
+// paths they are of, in the order of tarballs written by [Program.Create].
+func compareRecordTarOrder(a string, b string) int {
+	return compareTarOrder(recordPath(a), recordPath(b))
+}
+
+// Hash computes a fingerprint of the paths of the given sources (as with
+// [Program.DiffSources]), so that two sources can be compared for equality
+// by exchanging their (32-byte) digests rather than by running a full diff.
+//
+// The digest is a [listingDigest] computed with the given algorithm over all
+// paths in the order of tarballs written by [Program.Create] (see [compareTarOrder]),
+// so that it does not depend on the kind of the source (directory, tarball or
+// git worktree), only on the paths it contains, and that it equals the fingerprint
+// recorded in annotated tarballs (see [Program.writeTarball]) with SHA-256.
+// With captured metadata (see [ProgramConfig.Xattrs] and [ProgramConfig.ACLs]),
+// the metadata of the paths are included in the digest.
+// The hex-encoded digest is written to standard output, and also returned.
+// The ctx parameter controls early cancellation.
+func (prog *Program) Hash(ctx context.Context, sources []string, algo ChecksumAlgo, excludes []string) ([]byte, error) {
+	progressFrom(ctx).setPhase("hashing %s", strings.Join(sources, ", "))
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, sources...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRemotes()
+
+	digest := newListingDigest(algo)
+	if digest == nil {
+		return nil, fmt.Errorf("failed to hash: %w", errNoHashAlgo)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	ctx, skipped := withSkipCounter(ctx)
+
+	paths, errs, err := prog.sourcesPathStream(ctx, sources, excludes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish stream: %w", err)
+	}
+
+	if prog.config.FilesOnly {
+		paths = filesOnlyStream(ctx, paths)
+	}
+
+	// The sources are merged (and deduplicated) in sorted order, only then to
+	// be sorted into the order of tarballs (which a merge could not be done in).
+	paths, errs = extsortStringsFunc(ctx, paths, errs, prog.extSortConfig, compareRecordTarOrder)
+
+	for p := range paths {
+		digest.add(p)
+	}
+
+	for err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failure during hash: %w", err)
+		}
+	}
+
+	sum := digest.sum()
+	fmt.Fprintln(prog.stdout, hex.EncodeToString(sum))
+
+	return sum, prog.checkSkipped(nil, skipped)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: A directory and a tarball created from it should have the same digest.
+func Test_Program_Hash_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	var stdoutBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	dirSum, err := prog.Hash(t.Context(), []string{"/src"}, ChecksumSHA256, nil)
+	require.NoError(t, err)
+	require.Len(t, dirSum, 32)
+	require.Equal(t, hex.EncodeToString(dirSum)+"\n", stdoutBuf.String())
+
+	tarSum, err := prog.Hash(t.Context(), []string{"/out.tar.gz"}, ChecksumSHA256, nil)
+	require.NoError(t, err)
+	require.Equal(t, dirSum, tarSum)
+}
+
+// Expectation: Sources with different paths should have different digests.
+func Test_Program_Hash_Differences_Table(t *testing.T) {
+	base := []string{"a.txt", "b/", "b/c.txt"}
+
+	tests := []struct {
+		name    string
+		entries []string
+	}{
+		{"Added path", []string{"a.txt", "b/", "b/c.txt", "d.txt"}},
+		{"Removed path", []string{"a.txt", "b/"}},
+		{"Renamed path", []string{"a.txt", "b/", "b/d.txt"}},
+		{"Concatenated paths", []string{"a.txtb/", "b/c.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			require.NoError(t, afero.WriteFile(fs, "/base.tar.gz", createTar(base), 0o644))
+			require.NoError(t, afero.WriteFile(fs, "/other.tar.gz", createTar(tt.entries), 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+			baseSum, err := prog.Hash(t.Context(), []string{"/base.tar.gz"}, ChecksumSHA256, nil)
+			require.NoError(t, err)
+
+			otherSum, err := prog.Hash(t.Context(), []string{"/other.tar.gz"}, ChecksumSHA256, nil)
+			require.NoError(t, err)
+
+			require.NotEqual(t, baseSum, otherSum)
+		})
+	}
+}
+
+// Expectation: The excluded paths should not be part of the digest.
+func Test_Program_Hash_WithExcludes_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/full.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/part.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	fullSum, err := prog.Hash(t.Context(), []string{"/full.tar.gz"}, ChecksumBLAKE3, []string{"b.txt"})
+	require.NoError(t, err)
+	require.Len(t, fullSum, 32)
+
+	partSum, err := prog.Hash(t.Context(), []string{"/part.tar.gz"}, ChecksumBLAKE3, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, partSum, fullSum)
+}
+
+// Expectation: An error should be returned without a hash algorithm.
+func Test_Program_Hash_NoAlgorithm_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Hash(t.Context(), []string{"/input.tar.gz"}, ChecksumNone, nil)
+	require.ErrorIs(t, err, errNoHashAlgo)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// hashdeepMagic are the first bytes of any hashdeep manifest (audit file).
+var hashdeepMagic = []byte("%%%% HASHDEEP-1.0")
+
+const (
+	hashdeepHeaderPrefix  = "%%%% "
+	hashdeepInvokedPrefix = "## Invoked from: "
+	hashdeepFilenameField = "filename"
+)
+
+var errInvalidHashdeep = errors.New("invalid hashdeep manifest")
+
+// isHashdeepFile returns if the file at a path is a hashdeep manifest (rather
+// than a tarball), as detected from its first bytes (see [Program.hasFileMagic]).
+func (prog *Program) isHashdeepFile(p string) bool {
+	return prog.hasFileMagic(p, hashdeepMagic)
+}
+
+// hashdeepPathStream returns a sorted stream of the paths of a hashdeep manifest
+// (as written by "hashdeep -r" or "md5deep -r" in hashdeep mode), in the same
+// form as those of a tarball, skipping any paths excluded as with
+// [Program.tarPathStream]. Only the paths are streamed, the hashes are ignored.
+//
+// A manifest only records files, so any directories containing them are
+// streamed as well (with a trailing slash), as they would be contained in an
+// archive of the same tree. As the files are recorded in no particular order,
+// the stream is always sorted (to derive the directories from it).
+func (prog *Program) hashdeepPathStream(ctx context.Context, p string, excludes []string) (<-chan string, <-chan error) {
+	files := make(chan string, tarStreamBuffer)
+	fileErrs := make(chan error, 1)
+
+	matcher := prog.excludeMatcher(excludes)
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(files)
+		defer close(fileErrs)
+		defer recoverWorker(ctx)
+
+		f, err := prog.fs.Open(p)
+		if err != nil {
+			fileErrs <- fmt.Errorf("failed to open input file: %w", err)
+
+			return
+		}
+		defer f.Close()
+
+		err = readHashdeep(contextReader{ctx: ctx, r: f}, func(file string) error {
+			if excluded, err := prog.isExcluded(file, false, matcher); err != nil {
+				return fmt.Errorf("failed to check for exclusion: %w", err)
+			} else if excluded {
+				return nil
+			}
+
+			select {
+			case files <- file:
+			case <-ctx.Done():
+				return fmt.Errorf("failed to stream from hashdeep: %w", ctx.Err())
+			}
+
+			progress.addEntry()
+
+			return nil
+		})
+		if err != nil {
+			fileErrs <- err
+		}
+	}()
+
+	sorted, sortedErrs := extsortStringsFunc(ctx, files, fileErrs, prog.extSortConfig, prog.comparePaths)
+
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		var dirs []string // Stack of the directories containing the current file.
+		var last string
+
+		for file := range sorted {
+			if file == last {
+				continue
+			}
+			last = file
+
+			for len(dirs) > 0 && !strings.HasPrefix(file, dirs[len(dirs)-1]) {
+				dirs = dirs[:len(dirs)-1]
+			}
+
+			for _, dir := range manifestParents(file, dirs) {
+				dirs = append(dirs, dir)
+
+				if excluded, err := prog.isExcluded(dir, true, matcher); err != nil {
+					errs <- fmt.Errorf("failed to check for exclusion: %w", err)
+
+					return
+				} else if excluded {
+					continue
+				}
+
+				select {
+				case paths <- dir:
+				case <-ctx.Done():
+					errs <- fmt.Errorf("failed to stream from hashdeep: %w", ctx.Err())
+
+					return
+				}
+			}
+
+			select {
+			case paths <- file:
+			case <-ctx.Done():
+				errs <- fmt.Errorf("failed to stream from hashdeep: %w", ctx.Err())
+
+				return
+			}
+		}
+
+		for err := range sortedErrs {
+			if err != nil {
+				errs <- err
+
+				return
+			}
+		}
+	}()
+
+	return paths, errs
+}
+
+// readHashdeep reads a hashdeep manifest from r, calling fn with the path of
+// every file (relative to the directory hashdeep was invoked from).
+//
+// The paths are recorded as absolute ones (unless hashdeep was run with -l),
+// so those are made relative to the directory in the "Invoked from" comment.
+// Manifests are so best created from within the root of the tree (such as with
+// "cd /mnt/data && hashdeep -r ."), while any paths outside of it are rejected.
+// Paths of manifests created on Windows are converted to forward slashes.
+func readHashdeep(r io.Reader, fn func(file string) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20) //nolint:mnd
+
+	var lineNo int
+	var fields int
+	var invokedFrom string
+	var windows bool
+
+	for sc.Scan() {
+		lineNo++
+
+		line := strings.TrimSuffix(sc.Text(), "\r")
+
+		if header, ok := strings.CutPrefix(line, hashdeepHeaderPrefix); ok {
+			if lineNo == 1 {
+				continue
+			}
+
+			names := strings.Split(header, ",")
+			if names[len(names)-1] != hashdeepFilenameField {
+				return fmt.Errorf("%w: line %d: unexpected header %q", errInvalidHashdeep, lineNo, header)
+			}
+			fields = len(names)
+
+			continue
+		}
+
+		if dir, ok := strings.CutPrefix(line, hashdeepInvokedPrefix); ok {
+			invokedFrom = dir
+			windows = isWindowsPath(dir)
+
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if fields == 0 {
+			return fmt.Errorf("%w: line %d: entry before the header", errInvalidHashdeep, lineNo)
+		}
+
+		// The filename is the last field, which can itself contain commas.
+		values := strings.SplitN(line, ",", fields)
+		if len(values) != fields {
+			return fmt.Errorf("%w: line %d: expected %d fields", errInvalidHashdeep, lineNo, fields)
+		}
+
+		file, err := hashdeepRelPath(values[fields-1], invokedFrom, windows)
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %w", errInvalidHashdeep, lineNo, err)
+		}
+
+		if err := fn(file); err != nil {
+			return err
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read hashdeep: %w", err)
+	}
+
+	return nil
+}
+
+// hashdeepRelPath returns the path of a file of a hashdeep manifest relative
+// to the directory that hashdeep was invoked from (see [readHashdeep]).
+func hashdeepRelPath(file string, invokedFrom string, windows bool) (string, error) {
+	if windows {
+		file = strings.ReplaceAll(file, "\\", "/")
+		invokedFrom = strings.ReplaceAll(invokedFrom, "\\", "/")
+	}
+
+	if path.IsAbs(file) || (windows && isWindowsPath(file)) {
+		if invokedFrom == "" {
+			return "", fmt.Errorf("%q is absolute without an invocation directory", file)
+		}
+
+		rel, ok := strings.CutPrefix(path.Clean(file), strings.TrimSuffix(path.Clean(invokedFrom), "/")+"/")
+		if !ok {
+			return "", fmt.Errorf("%q is outside of the invocation directory %q", file, invokedFrom)
+		}
+		file = rel
+	}
+
+	file = path.Clean(file)
+	if file == "." || file == ".." || strings.HasPrefix(file, "../") || path.IsAbs(file) {
+		return "", fmt.Errorf("%q is outside of the invocation directory", file)
+	}
+
+	return file, nil
+}
+
+// isWindowsPath returns if a path is an absolute path with a drive letter.
+func isWindowsPath(p string) bool {
+	return len(p) >= 3 && p[1] == ':' && (p[2] == '\\' || p[2] == '/') && //nolint:mnd
+		(p[0] >= 'A' && p[0] <= 'Z' || p[0] >= 'a' && p[0] <= 'z')
+}
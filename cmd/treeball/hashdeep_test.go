@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to read all files of a hashdeep manifest.
+func readHashdeepFiles(t *testing.T, manifest string) ([]string, error) {
+	t.Helper()
+
+	var files []string
+
+	err := readHashdeep(strings.NewReader(manifest), func(file string) error {
+		files = append(files, file)
+
+		return nil
+	})
+
+	return files, err
+}
+
+// Expectation: The files of a manifest should be read relative to the invocation directory.
+func Test_readHashdeep_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{
+			"Absolute paths",
+			"%%%% HASHDEEP-1.0\n%%%% size,md5,sha256,filename\n## Invoked from: /mnt/data\n## $ hashdeep -r .\n##\n" +
+				"3,abc,def,/mnt/data/b/c.txt\n1,abc,def,/mnt/data/a, b.txt\n",
+			[]string{"b/c.txt", "a, b.txt"},
+		},
+		{
+			"Relative paths",
+			"%%%% HASHDEEP-1.0\n%%%% size,sha1,filename\n## Invoked from: /home/user\n" +
+				"3,abc,./b/c.txt\n1,abc,a.txt\n",
+			[]string{"b/c.txt", "a.txt"},
+		},
+		{
+			"Windows paths",
+			"%%%% HASHDEEP-1.0\r\n%%%% size,md5,filename\r\n## Invoked from: C:\\Data\r\n" +
+				"3,abc,C:\\Data\\b\\c.txt\r\n",
+			[]string{"b/c.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readHashdeepFiles(t, tt.manifest)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: Manifests with files outside of the invocation directory (or malformed ones) should be rejected.
+func Test_readHashdeep_Invalid_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{"Outside of invocation directory", "%%%% HASHDEEP-1.0\n%%%% size,md5,filename\n## Invoked from: /mnt/data\n1,abc,/etc/passwd\n"},
+		{"Ascending relative path", "%%%% HASHDEEP-1.0\n%%%% size,md5,filename\n1,abc,../etc/passwd\n"},
+		{"Missing header", "%%%% HASHDEEP-1.0\n1,abc,a.txt\n"},
+		{"Missing fields", "%%%% HASHDEEP-1.0\n%%%% size,md5,filename\n1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readHashdeepFiles(t, tt.manifest)
+			require.ErrorIs(t, err, errInvalidHashdeep)
+		})
+	}
+}
+
+// Expectation: A manifest should be compared against an archive as a diff source, with its directories derived.
+func Test_Program_Diff_Hashdeep_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt", "b/", "b/c/", "b/c/d.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/audit.hashdeep", []byte(
+		"%%%% HASHDEEP-1.0\n%%%% size,md5,filename\n## Invoked from: /mnt/data\n"+
+			"1,abc,/mnt/data/b/c/d.txt\n1,abc,/mnt/data/e/f.txt\n1,abc,/mnt/data/b/x.txt\n",
+	), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	_, err := prog.Diff(t.Context(), "/archive.tar.gz", "/audit.hashdeep", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- a.txt\n+++ b/x.txt\n+++ e/\n+++ e/f.txt\n", stdoutBuf.String())
+}
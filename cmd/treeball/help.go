@@ -13,17 +13,63 @@ the overhead of preserving file contents.
 The program works efficiently even with millions of files, intelligently off-loading data to
 disk when system resources would otherwise become too constrained. It supports these commands:
 
-  create - build a tarball from a given directory tree
-  diff   - generate a diff tarball containing only the changes between two sources
-  list   - produce a sorted or unsorted listing of all the contents of a given tarball
+  create    - build a tarball from a given directory tree
+  diff      - generate a diff tarball containing only the changes between two sources
+  check     - compare a tarball against a live directory tree (without any output file)
+  list      - produce a sorted or unsorted listing of all the contents of a given tarball
+  recreate  - regenerate a tarball from an (externally edited) manifest of paths
+  normalize - rewrite a (foreign) tarball into a canonical one, as if created by treeball
+  convert   - strip the contents of a (content) tarball or zip archive into a treeball
+  verify    - check a given tarball for corruption, duplicate entries, and sorted order
+  show      - display the annotations of a given tarball (such as its creation and comment)
+  hash      - compute a fingerprint of the paths of a source (for fast equality checks)
+  bench     - measure the throughput of the operations on a synthetic tree (for sizing)
+  serve     - serve a REST API for listing, searching, and diffing archives over HTTP
+  watch     - continuously snapshot a directory tree upon changes (into tarballs)
+  snapshot  - create a timestamped tarball of a directory tree, pruning older ones
+  patterns  - debug exclude patterns, reporting which of them match the given paths
+
+The most common commands can also be run by their aliases: mk (create), cmp (diff), chk (check),
+ls (list) and snap (snapshot). Likewise, the most common options have shorthands: -e (--exclude),
+-f (--force), -q (--quiet), -0 (--print0), and for list -l (--long), -c (--count), -o (--output).
+
+The optional features compiled into the program can be listed with the 'features' command.
+The version and build metadata (commit, build date, Go version) are printed with 'version'.
+Scripts for shell completion are generated with the 'completion' command (e.g. for bash).
 
 All commands print their primary results (such as file paths or differences) to standard output
 (stdout). Any encountered errors and operational messages are printed to standard error (stderr).
+With --quiet (-q), only the errors are printed there (without any informational messages or warnings).
+The options --tmpdir, --workers, --quiet and --color apply to all commands (given before or after
+the command), with the on-disk location for intermediate files, the workers for concurrent
+operations, and the colorization of the output (auto: only when a terminal, always, never).
+With --print0 (-0), any printed paths are terminated by NUL bytes instead of newlines, so that
+paths containing newlines (or other hostile characters) can be piped safely into 'xargs -0'.
+With --quoting=shell or --quoting=c (for list, diff and check), such paths are instead printed
+quoted (as for a shell, or as C string literals), so that each is kept on its own line.
+A snapshot of the progress of a running command (its current phase, the entries read, and the
+data spilled to disk) is printed to stderr upon SIGUSR2 (or SIGINFO, i.e. Ctrl+T, on BSD/macOS).
+With --metrics-listen (e.g. :9090), these are also served as Prometheus metrics (at /metrics).
+With --profile (fast, balanced, small), the compression, block, and sorting options are preset
+together (for speed or small archives), with any of these options given explicitly taking precedence.
+The concurrency of --walkers and --workers is checked against the files allowed open at once
+(as set with 'ulimit -n') before running, which is an error if exceeded, unless governed with
+--max-open-files (not exceeding 'ulimit -n'), which lowers the concurrency to stay within it.
+With --cpuprofile, --memprofile and --trace, a CPU profile, a memory profile (upon completion) and
+an execution trace of a command are written to the given files, for analyzing the performance on
+the hardware at hand (with 'go tool pprof' and 'go tool trace') rather than tuning by guesswork.
+Should a command crash (panic), a diagnostics file with the stack traces, the options, the progress
+up to the crash, and the environment (with any secrets redacted) is written into --tmpdir (or the
+temporary directory of the system), of which the path is printed (to attach it to a bug report).
 
 Exit Codes:
   0 - Success
-  1 - Differences found (only for 'diff')
+  1 - Differences found (only for 'diff' and 'check')
   2 - General failure (invalid input, I/O errors, etc.)
+  3 - Completed with warnings (e.g. entries skipped due to --skip-errors)
+  4 - Archive is corrupt or truncated (only for 'verify')
+  5 - Archive contains duplicate entries (only for 'verify')
+  6 - Archive is not in sorted order (only for 'verify')
 
 For detailed help on a specific command, run:
   treeball help <command>`
@@ -34,13 +80,158 @@ For detailed help on a specific command, run:
 
 The command will recursively include all files and directories under <root-folder>.
 Files will be compressed as zero-byte placeholder files with their names preserved.
+With --compression=0, a plain (uncompressed) tarball is written instead (e.g. as a .tar).
+Symbolic links are not followed, unless --follow-symlinks is given, which descends into
+any linked directories (except for those forming loops, which are detected and skipped).
+Directories can be read concurrently with --walkers (e.g. on high-latency network filesystems),
+which keeps the order of the walk, but is not combined with --follow-symlinks (walking serially).
+
+The <root-folder> can also be on a remote host as ssh://[user@]host[:port]/path, which is then
+walked over SFTP (read-only), e.g. to archive a directory of a NAS without mounting it first.
+The host key is verified against ~/.ssh/known_hosts, authenticating with any SSH agent or the
+default keys in ~/.ssh (without passphrase). Symbolic links are never followed on remote hosts.
+
+The <root-folder> can also be in S3-compatible object storage as s3://bucket/prefix, of which
+the keys are then archived as paths (with any "/" separating their directories). The endpoint
+is taken from $AWS_ENDPOINT_URL_S3 or $AWS_ENDPOINT_URL (otherwise AWS itself) and the region
+from $AWS_REGION, authenticating with the usual environment variables or ~/.aws/credentials.
+The <output.tar.gz> can also be written to object storage as s3://bucket/key (as any archives
+given to the other commands can be read from it), e.g. to keep the inventories off the host.
+
+The <root-folder> can also be on any remote configured with rclone as rclone://remote/path
+(e.g. B2, Google Drive, SMB or WebDAV), which is then walked through the remote control of a
+running rclone instance (rclone rcd), reached at $RCLONE_RC_URL (or http://localhost:5572/)
+and authenticated with any $RCLONE_RC_USER and $RCLONE_RC_PASS (read-only, only directories).
 
 Excludes are expected as relative to <root-folder> and following 'doublestar' format:
 https://github.com/bmatcuk/doublestar?tab=readme-ov-file#patterns
+With --pattern-syntax, they can instead be given as lines of a .gitignore file (gitignore,
+with negation and the last matching deciding) or rules of an rsync filter file (rsync).
+
+Regular expressions (--exclude-regex) are matched against the same relative paths, but
+with directories carrying a trailing slash (e.g. '^cache/$' to exclude just that folder).
+Any excludes can be matched case-insensitively with --ignore-case (e.g. '*.mkv' and '*.MKV').
+
+With --include (or an --includes-from file, as for --excludes-from), only the files matching
+any of the includes (or within a directory matching any) are archived, on top of the excludes.
+Directories are archived regardless, as their contents may still match (see --prune-empty).
+
+With --gitignore, any .gitignore files encountered in the tree are applied on top of the
+excludes, following the usual git semantics (anchoring, negation with '!', directory-only).
+
+With --descend-archives, any nested archives in the tree (.tar.gz, .tgz, .tar and .zip files)
+are archived as directories of their entries (e.g. "photos.zip/2024/a.jpg"), rather than as
+opaque files, with the excludes applying to those paths. Archives within them are not descended.
+
+With --skip-errors, any unreadable entries (e.g. permission-denied directories) are skipped
+with a warning instead of failing the entire operation, with their count reported at the end.
+
+An existing <output.tar.gz> is never overwritten, unless --force is given; alternatively,
+--backup renames it aside first (to *.bak, or *.bak.N if such backups exist already).
+With --fsync, the <output.tar.gz> (and its directory) is synced to its storage before the command
+completes, and with --verify-after-write, it is read back and decoded, failing upon any corruption
+(e.g. for removable disks and network mounts, where any cached output is lost when yanked).
+
+The format of the tar headers can be forced with --tar-format, e.g. to 'pax' for long (unicode)
+paths with third-party readers, or to 'ustar' for ancient consumers (failing for paths that it
+cannot represent). By default ('auto'), the oldest format able to represent each path is chosen.
+
+With --checkpoint-every, checkpoints are persisted alongside the output (as the file
+<output.tar.gz>.checkpoint) every so many entries, so that an interrupted creation of a large
+tree can be continued with --resume (given the same arguments) rather than restarted. The
+entries of a tree are always written in the same sorted order, making any checkpoint a
+well-defined resumption point. An interrupted creation keeps its output only in case a
+checkpoint was reached before; without --checkpoint-every, it is always removed (and so
+cannot be resumed).
+
+The tarball is written as a gzip member per checkpoint, which can be ended more often with
+--member-every (every so many entries), so that each member starts with a tar header and can
+be decompressed on its own (e.g. for resetting readers, or splitting the tarball at members).
+With --rsyncable, members are also ended after about every 1024th entry, as chosen by the
+names of the entries (much like 'gzip --rsyncable'), so that the tarballs of a changed tree
+only differ in the members of the changed entries, and rsync transfers only these members.
+
+With --sign-key, a detached signature of the tarball is written alongside it (as
+<output.tar.gz>.sig), signed with the given private SSH key (as created with 'ssh-keygen', not
+protected by a passphrase), so that consumers can check that the archive was not tampered with.
+The signature can be checked with 'verify --signature', as well as with 'ssh-keygen -Y verify'
+(in the 'file' namespace). An existing signature file is protected as the output file itself.
+
+With --annotate, the time of the creation, the hostname, the (absolute) root of the tree and the
+version of treeball are recorded in the tarball (in a PAX global header, ignored by other tar
+readers), along with any --comment (which implies --annotate), to be displayed with 'show'.
+The fingerprint of the entries (as computed by 'hash') is recorded at the end of the tarball, so
+that 'diff' can find two tarballs identical without comparing them (not for resumed creations).
+Annotations require the PAX format, so they cannot be combined with --tar-format=ustar (or gnu).
+
+With --checksum=sha256 (or blake3), the checksum of the tarball is computed while writing it,
+and written alongside it (as <output.tar.gz>.sha256 or .blake3, in the format of sha256sum and
+b3sum, which can check it with -c) as well as printed to standard error (stderr), so that it
+does not need to be computed separately (e.g. for any downstream transfers of the tarball).
+
+With --split-size, the tarball is written in parts of at most the given size (e.g. 4G or 700M),
+named <output.tar.gz>.000, <output.tar.gz>.001, and so on, as for media or storage with limits
+on the size of files. Concatenating the parts restores the tarball (e.g. 'cat output.tar.gz.*'),
+while the other commands read them as one archive (given by <output.tar.gz> or its .000 part).
+Any checksum or signature is of the entire tarball. Split archives cannot be checkpointed, so
+--split-size cannot be combined with --checkpoint-every (nor with --resume).
+
+With --newer-than and --older-than, only the files modified after (or before) the given age are
+archived, as either a duration before now (e.g. 30d, 2w or 12h) or a date (e.g. 2024-01-31).
+Directories are archived regardless of their own age, as their contents may still match.
+
+With --owner and --group (each repeatable), only the files owned by any of the given users (and
+any of the given groups) are archived, as names or numeric IDs, such as to produce per-user
+inventories of shared trees. Directories are again archived regardless of their own owner.
+
+With --prune-empty, any directories without files are left out (such as those whose entire
+contents were excluded, e.g. by --exclude='**/*.tmp'), rather than archived as lone entries.
+Directories at the --max-depth are kept, as only their contents are not considered.
+
+With --mode-file and --mode-dir, the files and directories are recorded with the given (octal)
+permission bits instead of 0666 and 0777, and with --entry-owner and --entry-group, with the given
+owner instead of none (as a name, resolved into its ID on this system, as an ID, or as NAME:ID
+for owners only known to the extracting system), such as for extraction environments requiring
+a specific ownership or stricter modes. Unlike --owner and --group, these never filter the tree.
+
+With --mtime, all entries are recorded with the given modification time (as seconds since the
+epoch prefixed with an @, e.g. @0, or as a date, e.g. 2024-01-31T12:00:00Z), as is the header of
+the gzip compression (and the time of the creation, with --annotate). Without --mtime, the time of
+any set SOURCE_DATE_EPOCH is used instead, so that archives created within reproducible builds do
+not change between runs. Entries are otherwise recorded without any modification time at all.
+
+With --gzip-name, --gzip-mtime and --gzip-comment, the name, modification time (now, @SECONDS
+or a date) and comment fields of the gzip header are set, such as for archive management tools
+keying off them (shown by 'show' and 'verify'). These are empty by default, with the time being
+that of --mtime. With a fixed time (reproducible mode), 'now' is that time instead of the current.
+
+With --transform (repeatable, applied in order), the names of the entries are rewritten with a
+sed-style expression (s/REGEX/REPLACEMENT/FLAGS, as GNU tar's --transform), e.g. to prepend a
+disk label (s,^,disk1/,) or to drop a staging prefix (s,^staging/,,). Any character after the s
+can delimit the expression, the replacement can refer to the match (&) and its groups (\1-\9),
+and the flags g (every match) and i (ignoring case) are supported. Expressions are applied to
+the names without the trailing slashes of directories, and entries transformed into an empty
+name are left out. The printed paths are the transformed names, while excludes and --resume
+still apply to the names within the tree. No parent directories are added for new prefixes, as
+readers of treeball add any missing ones (see 'normalize' to write them into the tarball).
+
+With --special-files, any FIFOs and (character or block) devices are written with their actual
+types (and device numbers) instead of as regular files, so that snapshots of system-level trees
+(such as /dev) are faithful. Sockets cannot be represented in tarballs, so remain regular files.
+
+With --xattrs and --acls, the extended attributes and POSIX ACLs of the entries are captured
+into PAX records (as SCHILY.xattr.* and SCHILY.acl.*, just as with GNU tar), e.g. for auditing
+the permissions and labels of shared NAS exports with 'diff'. These are only read from local
+directories, and require the (default) pax tar format. The ACLs are those of Linux systems.
+
+With --estimate, the tree is walked and compressed as usual, but nothing is written to disk.
+Instead, the expected entry count and output size are reported, so that space can be provisioned.
+The <output.tar.gz> argument is then optional and ignored if given.
 
 All paths written to the tarball will be printed to standard output (stdout), any errors
 or other relevant operational output will be printed to standard error (stderr) respectively.
-The command will return with an exit code 0 in case of success; an exit code 2 for any errors.`
+The command will return with an exit code 0 in case of success; an exit code 2 for any errors;
+an exit code 3 in case of completion with warnings (e.g. entries skipped with --skip-errors).`
 
 	createExample = `
 # Archive the current directory:
@@ -50,7 +241,52 @@ treeball create . output.tar.gz
 treeball create /mnt/data output.tar.gz --exclude='src/**/main.go'
 
 # Archive a directory with exclusions from a file:
-treeball create /mnt/data output.tar.gz --excludes-from=./excludes.txt`
+treeball create /mnt/data output.tar.gz --excludes-from=./excludes.txt
+
+# Archive only the videos (and the directories holding them) of a directory:
+treeball create /mnt/data videos.tar.gz --includes-from=./videos.txt --prune-empty
+
+# Archive a source code directory respecting its .gitignore files:
+treeball create ~/src/project output.tar.gz --gitignore
+
+# Archive a photo library along with the contents of its zipped photo sets:
+treeball create /mnt/photos output.tar.gz --descend-archives
+
+# Archive a directory with the names of its entries under a disk label:
+treeball create /mnt/disk1 output.tar.gz --transform='s,^,disk1/,'
+
+# Continue an interrupted creation of an archive (as checkpointed with --checkpoint-every):
+treeball create /mnt/data output.tar.gz --checkpoint-every=10000 --resume
+
+# Archive a directory along with a detached signature (as output.tar.gz.sig):
+treeball create /mnt/data output.tar.gz --sign-key=~/.ssh/id_ed25519
+
+# Archive a directory along with its checksum (as output.tar.gz.sha256):
+treeball create /mnt/data output.tar.gz --checksum=sha256
+
+# Archive a directory in parts of at most 4 GiB (as output.tar.gz.000, ...):
+treeball create /mnt/data output.tar.gz --split-size=4G
+
+# Archive only the files of a directory modified within the last 30 days:
+treeball create /mnt/data recent.tar.gz --newer-than=30d
+
+# Archive only the files of a shared directory owned by a user:
+treeball create /mnt/share alice.tar.gz --owner=alice
+
+# Archive a directory without its temporary files (nor any directories left empty by that):
+treeball create /mnt/data output.tar.gz --exclude='**/*.tmp' --prune-empty
+
+# Estimate the size of an archive without creating it:
+treeball create /mnt/data --estimate
+
+# Archive a directory of a remote host (over SFTP):
+treeball create ssh://admin@nas/volume1/data output.tar.gz
+
+# Archive the keys under a prefix of a bucket (into the same bucket):
+treeball create s3://media/cold s3://media/inventory/cold.tar.gz
+
+# Archive a directory of a remote configured with rclone (with rclone rcd running):
+treeball create rclone://gdrive/Media output.tar.gz`
 
 	diffHelpShort = "Create a diff tarball from any two pre-existing sources"
 
@@ -60,21 +296,153 @@ The command will compare the structure of two existing (directory tree) sources
 a "diff" tarball reflecting any additions or removals, comparing the "old" and "new" sources.
 This helps to identify which paths were recently added or lost (e.g., for recovery scenarios).
 
-The command supports sources as either an existing directory or an existing tarball (.tar.gz).
+The command supports sources as either an existing directory or an existing tarball (.tar.gz),
+which can also be a plain (uncompressed) tarball (.tar), as detected from its contents.
+Concatenated tarballs (also as concatenated gzip members) are read as one tarball.
+Either source can also be an mtree specification (as detected from its '#mtree' first line),
+e.g. as written by 'list --format=mtree', by mtree(8) or by bsdtar, for interchange with other
+tree verification tooling; only the paths and types of its entries are compared.
+Either source can also be a plain text list of paths (.txt or .lst), one path per line or
+NUL-delimited (e.g. from 'find -print0'), such as from 'find', 'rclone lsf' or vendor tools.
+Directories are expected to carry a trailing slash in such lists (as with 'rclone lsf').
+Either source can also be a hashdeep manifest (detected from its "%%%% HASHDEEP-1.0" first line),
+e.g. for cross-verification with forensic tooling; only the paths of its files are compared, with
+any absolute paths taken relative to the directory hashdeep was invoked from (see its comments).
+Either source can also be the index of a git repository as git:/path (of its worktree), so that
+the tracked paths (as of 'git ls-files', along with their directories) can be compared against
+the worktree itself (showing any untracked drift), an archive or any other source.
 This means you can compare tar vs. tar, tar vs. dir, dir vs. tar and dir vs. dir respectively.
+Symbolic links in directory sources are only descended into with --follow-symlinks.
+Directory sources can be read concurrently with --walkers (not combined with --follow-symlinks).
+Directory sources can also be on a remote host as ssh://[user@]host[:port]/path, which are then
+walked over SFTP (read-only), with the same authentication as for the 'create' command.
+Sources can also be in S3-compatible object storage as s3://bucket/prefix (see 'create'),
+either as a tree of keys or an archive, as can also the diff tarball be written to it.
+Archives can also be read from a web server as http(s)://host[:port]/path (streamed once).
+Directory sources can also be on any remote configured with rclone as rclone://remote/path.
+
+The "new" side can also be several directories merged under prefixes, each given in the
+dir:Prefix=/path format (e.g. dir:Movies=/mnt/m dir:TV=/mnt/t), so that an archive holding
+multiple roots can be compared against all of its sources. Excludes then apply to the
+merged paths (including the prefixes), as they would to the paths of such an archive.
 
 Excludes are expected as relative to given sources and following 'doublestar' format:
 https://github.com/bmatcuk/doublestar?tab=readme-ov-file#patterns
+With --pattern-syntax, they can instead be given as lines of a .gitignore file (gitignore,
+with negation and the last matching deciding) or rules of an rsync filter file (rsync).
+
+With --include (or an --includes-from file), only the files matching any of the includes (or
+within a directory matching any) are compared, while directories are compared regardless.
+
+With --exclude-old and --exclude-new, patterns are excluded from only the old (or new) side,
+in addition to those of --exclude, for noise that only ever exists on one of the sides (such
+as the '.recycle/**' of a replica), so that no broader patterns are needed on both sides.
+
+With --strip-components=N, the first N components of all paths of both sides are stripped
+before comparing (dropping any paths of fewer components), while --map-old and --map-new
+rewrite the leading components of the paths of only that side (in the FROM=>TO format, e.g.
+'mnt/disk1=>' to strip them, or 'disk1=>disk2' to rename them, the first matching one applying),
+e.g. for archives created from different mount points of the same data. Excludes still
+apply to the paths as they were, while the differences are reported with the rewritten ones.
+
+Regular expressions (--exclude-regex) are matched against the same relative paths, but
+with directories carrying a trailing slash (e.g. '^cache/$' to exclude just that folder).
+With --ignore-case, any excludes are matched case-insensitively (e.g. '*.mkv' and '*.MKV'), and
+the paths are also compared case-insensitively, so that 'Movie.mkv' and 'movie.mkv' are not a
+difference (e.g. when comparing a case-insensitive SMB/NTFS source against a Linux replica).
+
+With --gitignore, any .gitignore files encountered in directory sources are applied on top
+of the excludes, following the usual git semantics (anchoring, negation with '!', ...).
+
+With --descend-archives, any nested archives in directory sources (.tar.gz, .tgz, .tar and .zip
+files) are compared as directories of their entries, as with 'create --descend-archives'.
+
+With --skip-errors, any unreadable entries (e.g. permission-denied directories) in directory
+sources are skipped with a warning instead of failing, with their count reported at the end.
+
+With --files-only, only files are compared, so that any directories added or removed (such as
+the placeholder directories of rsync targets) do not count as differences. Files within them
+still do, so directories only ever matter for the comparison by means of their contents.
+
+The entries of tarballs created by other tools (such as GNU tar) are normalized as they are read,
+stripping any leading "./" or "/", skipping the root directory and any PAX global headers, and
+removing duplicate entries (of appended archives), so that only actual differences are reported.
+With --strict, any such entries are rejected as an error instead (see also 'normalize').
+
+With --newer-than and --older-than, only the files of directory sources modified after (or
+before) the given age are compared, as either a duration before now (e.g. 30d) or a date.
+With --owner and --group, only the files of directory sources owned by any of the given users
+(and any of the given groups) are compared, as names or numeric IDs.
+With --prune-empty, any directories without files (such as after exclusions) are left out of
+all sources, so that these do not show up as differences on their own.
+
+With --xattrs and --acls, the extended attributes and POSIX ACLs of the entries (as captured by
+'create') are compared as well, with any paths differing only by them reported as "~~~ path"
+(placed under a synthetic "~~~" directory in the <diff.tar.gz>). Such a drift counts both as
+removed and added. Both sources should have been captured with the same of these options.
+
+With --only=added or --only=removed, just that side of the differences is considered (e.g. to
+audit for data loss), both for the output and for the exit code, as if there were no others.
+
+With --max-diffs=N, the comparison is stopped as soon as N differences were found (returning
+exit code 1 immediately, with any <diff.tar.gz> holding just those), and --stop-on-first is
+the same as --max-diffs=1 (e.g. for monitoring scripts only needing to know if anything changed).
+
+Two tarballs with the same fingerprint (as recorded with --annotate of 'create') are identical
+without comparing them, so that such a diff takes only milliseconds (instead of minutes).
+
+An existing <diff.tar.gz> is never overwritten, unless --force is given; alternatively,
+--backup renames it aside first (to *.bak, or *.bak.N if such backups exist already).
+With --fsync and --verify-after-write, the <diff.tar.gz> is synced and read back (see 'create').
+With --sign-key and --checksum, a detached signature and the checksum of the <diff.tar.gz> are
+written alongside it (see 'create').
+
+The added and removed paths are placed under synthetic "+++" and "---" directories of the
+<diff.tar.gz>, which can be named otherwise with --added-prefix and --removed-prefix (e.g. as
+'added' and 'removed', for downstream tools that choke on such names); the printed lines of
+the differences keep their "+++ " and "--- " prefixes regardless.
+
+The <diff.tar.gz> can be left out to only report the differences (without any output file),
+when there is a single "new" source; with --no-output, all of the arguments after <old> are
+taken as "new" sources instead (e.g. for merged sources), so that none is taken as output.
+
+With --tui, the differences are instead shown side by side in an interactive viewer (when a
+terminal), with the removed paths on the left (old) side, the added paths on the right (new)
+side, and their directories collapsible (arrow keys or h/j/k/l to navigate, enter to toggle,
+q to quit). As with --no-output, all arguments after <old> are then taken as "new" sources.
+
+With --summary-only, only the amounts of the removed and added paths are printed (in total and
+per top-level directory, with any files at the top level as "."), without any output file, for
+a quick check whether anything changed.
+The exit codes remain the same, and all arguments after <old> are taken as "new" sources.
+
+With --pairs-from, many pairs of sources are compared in one invocation (instead of the
+arguments), each given on a line as <old>, <new> and optional <diff.tar.gz> separated by tabs.
+The pairs are compared concurrently, sharing the --workers and --tmpdir between them, and
+their differences are printed as one consolidated report (in the order of the pairs).
 
 Any differences will also be written to standard output (stdout), while any other operational
 output will be written to standard error (stderr). The program will return with an exit code
-0 in case no differences were found; with an exit code 1 in case some differences were found.
+0 in case no differences were found; with an exit code 1 in case some differences were found;
+with an exit code 3 in case of completion with warnings (taking precedence over exit code 1),
+e.g. when any unreadable entries were skipped, as any differences may then be incomplete.
+
+When standard output is a terminal, it is piped into the pager set in $PAGER (or "less"),
+which returns immediately for output fitting into one screen; --no-pager disables this.
+The removed ("---") and added ("+++") paths are then also colored (as are any warnings),
+as controlled with --color=auto|always|never (auto: only when a terminal, unless $NO_COLOR).
 
 Performance considerations with massive archives:
 The external sorting mechanism may off-load excess data to on-disk locations to conserve RAM.
 Ensure that a suitable --tmpdir is provided (in terms of speed and available space), as such
 data can peak at multiple gigabytes. If none is provided, the intelligent mechanism will try
-choose one for you, falling back to the system's default temporary file location on failure.`
+choose one for you, falling back to the system's default temporary file location on failure.
+With --assume-sorted-walk, directory sources are instead walked in the sorted order of their
+paths (sorting the entries of each directory), so that they need no external sorting at all;
+the order is verified while walking. This is not possible combined with --follow-symlinks,
+--descend-archives or --ignore-case, where directory sources are then sorted as usual.
+With --report-usage, the peak heap and the most data held in temporary files at once are printed
+to stderr upon completion, so that the memory (--chunksize) and --tmpdir can be sized for them.`
 
 	diffExample = `
 # Basic usage of the command:
@@ -84,11 +452,104 @@ treeball diff old.tar.gz new.tar.gz diff.tar.gz
 treeball diff old.tar.gz /mnt/new diff.tar.gz
 
 # Just see the diff in the terminal (without file output):
-treeball diff old.tar.gz new.tar.gz /dev/null
+treeball diff old.tar.gz new.tar.gz
+
+# Reconcile the drift of a replica in an interactive side-by-side viewer:
+treeball diff /mnt/data /mnt/replica --tui
+
+# Just see whether (and where) anything changed, as amounts per top-level directory:
+treeball diff old.tar.gz /mnt/new --summary-only
+
+# Quick high-level comparison of only the first two levels:
+treeball diff old.tar.gz new.tar.gz diff.tar.gz --max-depth=2
+
+# Comparison of a local archive against a directory of a remote host (over SFTP):
+treeball diff old.tar.gz ssh://admin@nas/volume1/data diff.tar.gz
+
+# Comparison of an archive against the keys of a bucket (both in object storage):
+treeball diff s3://media/inventory/cold.tar.gz s3://media/cold
+
+# Comparison of an archive published on a web server against a local directory:
+treeball diff https://backup.lan/snapshots/latest.tar.gz /mnt/data
+
+# Comparison of a multi-root archive against its merged source directories:
+treeball diff snapshot.tar.gz dir:Movies=/mnt/m dir:TV=/mnt/t diff.tar.gz
+
+# Comparison of what is tracked in git against what is on disk (untracked drift):
+treeball diff git:/src/project /src/project --exclude=".git/**"
+
+# Comparison of only the files (ignoring any directory additions/removals):
+treeball diff old.tar.gz /mnt/new diff.tar.gz --files-only
+
+# Comparison against a replica, ignoring its recycle bin (existing only on the replica):
+treeball diff /mnt/data /mnt/replica diff.tar.gz --exclude-new='.recycle/**'
+
+# Comparison of archives created from different mount points of the same data:
+treeball diff disk1.tar.gz disk2.tar.gz diff.tar.gz --map-old='mnt/disk1=>' --map-new='mnt/disk2=>'
+
+# Audit for data loss, considering only the removed paths:
+treeball diff old.tar.gz /mnt/new diff.tar.gz --only=removed
+
+# Comparison of a case-insensitive source against a replica (ignoring case):
+treeball diff /mnt/smb /mnt/replica diff.tar.gz --ignore-case
+
+# Comparison of many pairs (one tab-separated pair per line) in one invocation:
+treeball diff --pairs-from=pairs.txt
 
 # Use of an on-disk temporary directory (for massive archives):
 treeball diff old.tar.gz new.tar.gz diff.tar.gz --tmpdir=/mnt/largedisk`
 
+	checkHelpShort = "Check a tarball against a live directory tree"
+
+	checkHelpLong = `Check a tarball against a live directory tree, without producing any output file.
+
+This is the same comparison as with 'diff' (of the tarball as the old, and the directory tree
+as the new source), but only reporting the differences rather than also writing a diff tarball.
+The directory tree can also be several directories merged under prefixes (dir:Prefix=/path),
+as for multi-root archives (see the 'diff' command), on remote hosts (ssh://host/path)
+in object storage (s3://bucket/prefix), which can also hold the tarball (s3://bucket/key),
+and on any remote configured with rclone (rclone://remote/path).
+
+Excludes are expected as relative to given source and following 'doublestar' format:
+https://github.com/bmatcuk/doublestar?tab=readme-ov-file#patterns
+With --pattern-syntax, they can instead be given as lines of a .gitignore file (gitignore,
+with negation and the last matching deciding) or rules of an rsync filter file (rsync).
+
+With --ignore-case, the paths are compared (and any excludes matched) case-insensitively.
+With --files-only, only files are compared, so that any directories added or removed (such as
+the placeholder directories of rsync targets) do not count as differences.
+With --strict, any non-canonical entries of tarballs (e.g. "./" prefixes) are rejected as errors.
+With --xattrs and --acls, any drift of the extended attributes and ACLs is reported as "~~~ path".
+With --newer-than and --older-than, only the files modified after (or before) the age are compared.
+With --owner and --group, only the files owned by the given users (and groups) are compared.
+With --prune-empty, any directories without files (such as after exclusions) are left out.
+With --descend-archives, any nested archives are compared as directories of their entries.
+With --include (or --includes-from), only the files matching any of the includes are compared.
+With --only=added or --only=removed, just that side of the differences is considered.
+With --max-diffs=N (or --stop-on-first), the check is stopped after the first N differences.
+
+Any differences are printed to standard output (stdout), as "--- path" for paths removed from
+and "+++ path" for paths added to the tree, followed by a summary of their amounts. Any errors
+or other operational output are printed to standard error (stderr) respectively. The command
+returns with an exit code 0 when identical; an exit code 1 for differences; 2 for any errors.
+On a terminal, the differences (and any warnings) are colored, as controlled with --color.
+
+Performance considerations with massive archives:
+The external sorting mechanism may off-load excess data to on-disk locations to conserve RAM.
+Ensure that a suitable --tmpdir is provided (in terms of speed and available space), as such
+data can peak at multiple gigabytes. With --report-usage, the peak heap and temporary disk usage
+are printed to stderr upon completion (as for 'diff').`
+
+	checkExample = `
+# Check an archive against its directory tree:
+treeball check snapshot.tar.gz /mnt/data
+
+# Check a multi-root archive against its merged directory trees:
+treeball check snapshot.tar.gz dir:Movies=/mnt/m dir:TV=/mnt/t
+
+# Check only whether anything changed (stopping at the first difference):
+treeball check snapshot.tar.gz /mnt/data --stop-on-first`
+
 	listHelpShort = "List the paths contained in a tarball (sorted by default)"
 
 	listHelpLong = `List all contained paths in a tarball, either sorted or in original order.
@@ -96,18 +557,69 @@ treeball diff old.tar.gz new.tar.gz diff.tar.gz --tmpdir=/mnt/largedisk`
 By default, the paths are sorted alphabetically, which improves readability and makes it
 easier to 'diff' or otherwise process. --sort=false preserves the original archive order.
 
+Other orders can be chosen with --sort-by: 'reverse' (reverse alphabetically), 'depth' (by the
+depth of the paths, then alphabetically), and 'version' (naturally, with any numbers within the
+paths compared numerically, e.g. 'file2' before 'file10'); the default order is 'name'.
+
+The tarball can also be read from S3-compatible object storage as s3://bucket/key (see 'create'),
+or from a web server as http(s)://host[:port]/path, which is streamed without any range requests.
+Instead of a tarball, a plain text list of paths (.txt or .lst), an mtree specification or a
+hashdeep manifest can also be listed (see 'diff'), e.g. to sort, filter or convert the listings of other tooling.
+
 Excludes are expected as relative to given source and following 'doublestar' format:
 https://github.com/bmatcuk/doublestar?tab=readme-ov-file#patterns
+With --pattern-syntax, they can instead be given as lines of a .gitignore file (gitignore,
+with negation and the last matching deciding) or rules of an rsync filter file (rsync).
+
+Regular expressions (--exclude-regex) are matched against the same relative paths, but
+with directories carrying a trailing slash (e.g. '^cache/$' to exclude just that folder).
+Any excludes can be matched case-insensitively with --ignore-case (e.g. '*.mkv' and '*.MKV').
+
+With --match, only the paths matching any of the given patterns (in the same 'doublestar' format)
+are listed, e.g. to narrow a listing to a subtree ('Movies/**') or an extension ('**/*.mkv').
+With --type=f or --type=d, only the files or only the directories are listed respectively.
+With --subdir, only the entries within the given subdirectory (e.g. 'media/movies/', including
+the subdirectory itself) are listed, with their full paths. Of an index file (see below), only
+the records up to the end of the subdirectory are read, so pulling the listing of one directory
+out of a massive archive returns as soon as that directory's listing is complete.
+With --count, only the amounts of files and directories (to be listed) are printed, without
+sorting any of the entries, which returns quickly even for the most massive of archives.
+
+With --format=jsonl, every entry is printed as a JSON object on its own line (JSON Lines), with
+its 'path', its 'type' ('file' or 'dir'), and any metadata recorded in the tarball ('size' for
+files, and 'mtime' unless the tarball holds none, as for the placeholders written by 'create'),
+so that tools like 'jq' need not derive the type of an entry from any trailing slash. With
+--format=mtree, an mtree specification is printed (with the full paths of all entries), which
+can be consumed by other tree verification tooling, or compared against with 'diff'. With -l
+(or --format=long), the type, permission bits, size and modification time recorded in the tar
+headers are printed in columns before the paths (as with 'ls -l', e.g. for foreign tarballs).
+Such listings are always read from the tarball itself (rather than any index file).
+
+With --output, the listing is written to the given file instead of standard output, which is
+compressed with gzip if the file name ends in '.gz' (e.g. for the most massive of listings).
+An existing output file is never overwritten, unless --force (or --backup) is given.
+
+Sorted listings and --count are read from the index file of the tarball (<input.tar.gz>.tbi,
+as built by 'index') instead, if there is one matching the tarball, so that the tarball does not
+need to be decompressed at all. An index file of a since modified tarball is ignored (with a
+warning), as are all index files with --no-index.
 
 All listed paths are printed to standard output (stdout), while any operational output and
 encountered errors will be written to standard error (stderr) respectively. The command
 returns with an exit code 0 upon success; an exit code 2 for any encountered errors.
 
+When standard output is a terminal, it is piped into the pager set in $PAGER (or "less"),
+which returns immediately for output fitting into one screen; --no-pager disables this.
+The directories are then also colored (as are any warnings), as controlled with the option
+--color=auto|always|never (auto: only when a terminal, unless $NO_COLOR is set).
+
 Performance considerations with massive archives:
 The external sorting mechanism may off-load excess data to on-disk locations to conserve RAM.
 Ensure that a suitable --tmpdir is provided (in terms of speed and available space), as such
 data can peak at multiple gigabytes. If none is provided, the intelligent mechanism will try
-choose one for you, falling back to the system's default temporary file location on failure.`
+choose one for you, falling back to the system's default temporary file location on failure.
+With --report-usage, the peak heap and temporary disk usage are printed to stderr upon completion
+(as for 'diff'), for sizing the memory (--chunksize) and --tmpdir for the archives at hand.`
 
 	listExample = `
 # List the contents as sorted (default):
@@ -116,6 +628,531 @@ treeball list input.tar.gz
 # List the contents in their original archive order:
 treeball list input.tar.gz --sort=false
 
+# List the contents in natural order (e.g. 'file2' before 'file10'):
+treeball list input.tar.gz --sort-by=version
+
+# List only the top-level contents:
+treeball list input.tar.gz --max-depth=1
+
+# List only the contents of a subtree with a given extension:
+treeball list input.tar.gz --match='Movies/**/*.mkv'
+
+# List only the contents of one directory of an indexed archive:
+treeball list input.tar.gz --subdir=media/movies/
+
+# Count the files and directories contained:
+treeball list input.tar.gz --count
+
+# Write the contents to a compressed file:
+treeball list input.tar.gz --output=listing.txt.gz
+
+# Pipe the contents safely into other tools (NUL-delimited):
+treeball list input.tar.gz --print0 | xargs -0 -n1 echo
+
+# Show the contents safely in a terminal (quoting any paths with hostile characters):
+treeball list input.tar.gz --quoting=shell
+
+# Pipe the entries into jq as JSON Lines (e.g. only the directories):
+treeball list input.tar.gz --format=jsonl | jq -r 'select(.type == "dir") | .path'
+
+# Show the stored metadata of the entries (type, mode, size and mtime) in columns:
+treeball list foreign.tar.gz -l
+
+# List the contents of an archive published on a web server:
+treeball list https://backup.lan/snapshots/latest.tar.gz
+
 # Use of an on-disk temporary directory (for massive archives):
 treeball list input.tar.gz --tmpdir=/mnt/largedisk`
+
+	recreateHelpShort = "Recreate a tarball from a manifest of paths"
+
+	recreateHelpLong = `Recreate a tarball purely from a manifest of paths (plus any metadata).
+
+The manifest is a JSON array of entries, each with a relative "path" and an optional "type"
+("file" or "dir"; otherwise directories are recognized by their trailing slash). Any other
+fields of the entries (such as metadata) are ignored, as with all treeball archives, entries
+are written as zero-byte placeholder files. This allows for round trips of archives through
+external editing tools (such as spreadsheets or scripts) back into a canonical archive.
+
+The entries are written in sorted order, with any missing parent directories added and
+any duplicate entries removed, regardless of how the manifest was edited in the meantime.
+A path that is both a file and a directory (e.g. "a" and "a/") is rejected as an error.
+Large manifests are sorted using the same on-disk mechanism as with 'diff' (see --tmpdir).
+
+An existing <output.tar.gz> is never overwritten, unless --force is given; alternatively,
+--backup renames it aside first (to *.bak, or *.bak.N if such backups exist already).
+With --fsync and --verify-after-write, the <output.tar.gz> is synced and read back (see 'create').
+
+All paths written to the tarball will be printed to standard output (stdout), any errors
+or other relevant operational output will be printed to standard error (stderr) respectively.
+The command will return with an exit code 0 in case of success; an exit code 2 for any errors.`
+
+	recreateExample = `
+# Recreate an archive from a manifest:
+treeball recreate manifest.json output.tar.gz
+
+# Example of a manifest:
+[
+  {"path": "Movies/", "type": "dir"},
+  {"path": "Movies/Alien (1979).mkv", "size": 1234567890},
+  {"path": "TV/Show/S01E01.mkv"}
+]`
+
+	normalizeHelpShort = "Rewrite a (foreign) tarball into a canonical one"
+
+	normalizeHelpLong = `Rewrite an existing (possibly foreign) tarball into a canonical one.
+
+Tarballs created by other tools represent the same tree in many different ways, e.g. with
+paths prefixed by "./" or "/", directories without trailing slashes, entries in the order of
+the filesystem, missing parent directories, or duplicate entries (of appended archives). When
+diffed against treeball archives, these representation differences show up as noisy results.
+
+The paths of the <input.tar.gz> are cleaned (stripping any leading "./" or "/"), directories
+carry a trailing slash, and any other types of entries (such as symlinks) become files. The
+entries are written in sorted order, with any missing parent directories added and duplicates
+removed, as zero-byte placeholder files with consistent headers (as written by 'recreate').
+Paths escaping the root of the tree (through "..") are rejected as an error.
+
+An existing <output.tar.gz> is never overwritten, unless --force is given; alternatively,
+--backup renames it aside first (to *.bak, or *.bak.N if such backups exist already).
+With --fsync and --verify-after-write, the <output.tar.gz> is synced and read back (see 'create').
+
+All paths written to the tarball will be printed to standard output (stdout), any errors
+or other relevant operational output will be printed to standard error (stderr) respectively.
+The command will return with an exit code 0 in case of success; an exit code 2 for any errors.`
+
+	normalizeExample = `
+# Normalize an archive created by another tool:
+tar -czf foreign.tar.gz -C /mnt/data .
+treeball normalize foreign.tar.gz output.tar.gz
+
+# Compare a normalized archive against a directory tree:
+treeball diff output.tar.gz /mnt/data`
+
+	convertHelpShort = "Strip the contents of a (content) tarball or zip archive into a treeball"
+
+	convertHelpLong = `Strip the contents of an existing (content) tarball or zip archive into a treeball.
+
+Existing full backups already hold the tree they were made of, so that their lightweight and
+browsable treeball is derived from them without walking the original filesystem again. Only the
+headers of the entries are read, with the contents skipped (so that no space is needed for them).
+
+The <input> is either a tarball (compressed with gzip or not) or a zip archive, as detected from
+its '.zip' extension or its first bytes (zip archives need to be local files, as their entries
+are read from their end). The paths of the entries are normalized as with 'normalize', written in
+sorted order as zero-byte placeholder files, with any missing parent directories added and any
+duplicates removed. Any paths matching the exclude patterns are left out of the <output.tar.gz>.
+
+An existing <output.tar.gz> is never overwritten, unless --force is given; alternatively,
+--backup renames it aside first (to *.bak, or *.bak.N if such backups exist already).
+With --fsync and --verify-after-write, the <output.tar.gz> is synced and read back (see 'create').
+
+All paths written to the tarball will be printed to standard output (stdout), any errors
+or other relevant operational output will be printed to standard error (stderr) respectively.
+The command will return with an exit code 0 in case of success; an exit code 2 for any errors.`
+
+	convertExample = `
+# Derive a lightweight index of a full backup:
+treeball convert backup.tar.gz tree.tar.gz
+
+# Derive a lightweight index of a zip archive, leaving out any thumbnails:
+treeball convert photos.zip tree.tar.gz --exclude="**/Thumbs.db"`
+
+	indexHelpShort = "Build the index file of a tarball for fast repeated listing"
+
+	indexHelpLong = `Build the index file of a tarball for fast repeated listing.
+
+The command reads the entire tarball once, writing all of its paths in sorted order (along with
+the offsets of their entries within the tar stream) into an index file next to the tarball, as
+<input.tar.gz>.tbi. Sorted listings and counts with 'list' are then read from the index file,
+rather than decompressing the tarball for every query. Any existing index file is replaced.
+
+The index file records the size and modification time of the tarball, so that an index file of
+a since modified tarball is recognized as stale (and ignored) rather than returning wrong results.
+
+The amount of indexed entries is printed to standard output (stdout), while any encountered
+errors will be written to standard error (stderr) respectively. The command returns with an
+exit code 0 upon success; an exit code 2 for any encountered errors.
+
+Performance considerations with massive archives:
+The paths are sorted using the same on-disk sorting mechanism as with 'list', so ensure that a
+suitable --tmpdir is provided (in terms of speed and available space).`
+
+	indexExample = `
+# Build the index file of an archive (as input.tar.gz.tbi):
+treeball index input.tar.gz
+
+# List the archive (read from the index file):
+treeball list input.tar.gz
+
+# List the archive without reading the index file:
+treeball list input.tar.gz --no-index`
+
+	exportHelpShort = "Export the entries of a tarball into a database"
+
+	exportHelpLong = `Export the entries of a tarball into a database, for reporting or ad-hoc queries.
+
+With --format=sqlite (the default), the entries are written into the 'entries' table of a new
+SQLite database at <output.db>, with the columns 'path', 'type' ('file' or 'dir'), 'size' (NULL
+for directories) and 'mtime' (in seconds since the epoch, NULL where not recorded in the tarball,
+as for the zero-byte placeholders written by 'create'). The paths are indexed for fast lookups.
+With --subdir, only the entries within the given subdirectory (e.g. 'media/movies/', including
+the subdirectory itself) are exported, with their full paths.
+
+Excludes are expected as relative to given source and following 'doublestar' format:
+https://github.com/bmatcuk/doublestar?tab=readme-ov-file#patterns
+With --pattern-syntax, they can instead be given as lines of a .gitignore file (gitignore,
+with negation and the last matching deciding) or rules of an rsync filter file (rsync).
+
+The amount of exported entries is printed to standard output (stdout), while any encountered
+errors will be written to standard error (stderr) respectively. An existing output file is
+never overwritten, unless --force (or --backup) is given. The command returns with an exit
+code 0 upon success; an exit code 2 for any encountered errors.
+
+The database is first built in a temporary file (in --tmpdir, if given), and only then copied
+to the output file, which can so also be written to S3-compatible object storage (s3://).`
+
+	exportExample = `
+# Export the entries of an archive into an SQLite database:
+treeball export input.tar.gz inventory.db
+
+# Count the files per top-level directory:
+sqlite3 inventory.db "SELECT substr(path, 1, instr(path, '/')) AS top, COUNT(*) FROM entries WHERE type = 'file' GROUP BY top"`
+
+	restoreHelpShort = "Restore the tree of a tarball into a partially existing folder"
+
+	restoreHelpLong = `Restore the tree of a tarball into a folder, merging it into any existing contents.
+
+All directories and files of the tarball are created within <dest-folder> (which is created if
+missing), with the files as zero-byte placeholders (as they are written by 'create'). This allows
+for re-seeding a partially lost library (such as one of the *arr applications) with placeholders
+for all missing files, so that these are recognized as such and can be re-acquired.
+
+Existing directories are always merged into. Any other existing paths fail the command by default,
+so that nothing is replaced by mistake. With --on-exists, these are instead either left as they
+are (skip), replaced with the placeholder (overwrite, but never existing directories), or kept
+with the placeholder restored next to them (rename, as <name>.restored, or <name>.restored.N if
+that is also taken). With --only-missing (the same as --on-exists=skip), only the missing paths
+are restored. A directory of the tarball that is skipped (as a non-directory exists at its path)
+is so along with its contents, while one that is renamed receives its contents under its new name.
+Entries are never restored through an existing symbolic link within <dest-folder> (which would
+place them wherever it points to), which fails the command instead.
+
+Excludes are expected as relative to given source and following 'doublestar' format:
+https://github.com/bmatcuk/doublestar?tab=readme-ov-file#patterns
+With --pattern-syntax, they can instead be given as lines of a .gitignore file (gitignore,
+with negation and the last matching deciding) or rules of an rsync filter file (rsync).
+
+The paths of all restored entries (relative to <dest-folder>) are printed to standard output
+(stdout), while a summary and any encountered errors will be written to standard error (stderr)
+respectively. The command returns with an exit code 0 upon success; an exit code 2 for any
+encountered errors (in which case the entries restored until then are kept).
+
+Performance considerations with massive archives:
+The paths are sorted (so that directories are restored before their contents) using the same
+on-disk sorting mechanism as with 'list', so ensure that a suitable --tmpdir is provided (in
+terms of speed and available space).`
+
+	restoreExample = `
+# Restore the placeholders of all missing files into a library:
+treeball restore library.tar.gz /mnt/user/media --only-missing
+
+# Restore all placeholders, keeping any existing files (as *.restored) next to them:
+treeball restore library.tar.gz /mnt/user/media --on-exists=rename
+
+# Restore all placeholders except those of the TV shows into an empty folder:
+treeball restore library.tar.gz /mnt/user/restored --exclude='tv/**'`
+
+	verifyHelpShort = "Verify the integrity of a tarball"
+
+	verifyHelpLong = `Verify the integrity of a tarball, decoding all of its compressed data and tar headers.
+
+Unlike the other commands, which only surface corruption once they reach the affected part,
+the entire archive is checked upfront. This includes the checksums of the compressed data,
+truncation of the archive, duplicate entries, and the order of the entries. An archive is
+considered sorted if it is either in the order of a directory walk (as written by 'create')
+or in alphabetically sorted order (as written by 'recreate').
+
+Any set fields of the gzip header (gzip-name, gzip-mtime and gzip-comment), any duplicate entries
+and the first entry out of sorted order are printed to standard output (stdout), followed by the
+amount of entries; any corruption is printed to standard error (stderr).
+The command returns with an exit code 0 for an intact archive; an exit code 4 for a corrupt or
+truncated archive, 5 for duplicate entries, 6 for an unsorted archive, or 2 for any other errors.
+With multiple findings, the most severe one determines the exit code (in the above order).
+
+With --signature, the detached signature of the archive (as written with --sign-key of 'create',
+or by 'ssh-keygen -Y sign -n file') is checked first, which must have been made by any of the
+public keys in --trusted-keys (in the format of an authorized_keys file, such as any *.pub file).
+The fingerprint of the signing key is printed to standard output (stdout). An archive not
+matching its signature, or one signed by an untrusted key, is taken as corrupt (exit code 4).
+
+Performance considerations with massive archives:
+Duplicate entries are detected using the same on-disk sorting mechanism as with 'list', so
+ensure that a suitable --tmpdir is provided (in terms of speed and available space).`
+
+	verifyExample = `
+# Verify the integrity of an archive:
+treeball verify input.tar.gz
+
+# Verify the integrity and the signature of an archive (as created with --sign-key):
+treeball verify input.tar.gz --signature=input.tar.gz.sig --trusted-keys=~/.ssh/id_ed25519.pub
+
+# Use of an on-disk temporary directory (for massive archives):
+treeball verify input.tar.gz --tmpdir=/mnt/largedisk`
+
+	showHelpShort = "Display the annotations of a tarball"
+
+	showHelpLong = `Display the annotations of a tarball, as recorded with --annotate (or --comment) of 'create'.
+
+The annotations are printed to standard output (stdout) as "key: value" lines, being the time
+of the creation of the tarball (created, in UTC), the hostname, the (absolute) root of the tree,
+the version of treeball that created it, any comment, and the fingerprint of the entries (as
+computed by 'hash'). Tarballs without annotations print nothing. As the annotations precede all
+entries (except for the fingerprint, which follows them), only the ends of the tarball are read.
+Any set fields of the gzip header follow (gzip-name, gzip-mtime and gzip-comment, see 'create').`
+
+	showExample = `
+# Display the annotations of an archive:
+treeball show input.tar.gz
+
+# Create an annotated archive and display its annotations:
+treeball create /mnt/data output.tar.gz --comment="before the migration"
+treeball show output.tar.gz`
+
+	hashHelpShort = "Compute a fingerprint of the paths of a source"
+
+	hashHelpLong = `Compute a fingerprint of the paths of a source, for fast equality checks.
+
+The source is either a directory, a tarball or a git worktree (as with 'diff', including several
+directories merged under prefixes). A deterministic digest (of 32 bytes) is computed over all of
+its paths (in the order of 'create') and printed to standard output (stdout) in hexadecimal, so
+that two sources (e.g. on different machines) can be compared for equality by exchanging their
+digests, instead of running a full diff. A directory and a tarball created from it have the same
+digest, which (with SHA-256) is also the fingerprint recorded with --annotate of 'create'.
+
+The digest only depends on the paths (and their order), so the same excludes and filters must be
+given for the digests of two sources to be comparable. With --xattrs and --acls, the extended
+attributes and POSIX ACLs of the entries are included in the digest as well. The algorithm of
+the digest is SHA-256, unless --algorithm=blake3 is given (the faster one on most machines).
+
+Performance considerations with massive trees:
+The paths are sorted using the same on-disk sorting mechanism as with 'diff', so ensure that
+a suitable --tmpdir is provided (in terms of speed and available space).`
+
+	hashExample = `
+# Compute the fingerprint of a directory:
+treeball hash /mnt/data
+
+# Compare a directory against an archive, without running a full diff:
+[ "$(treeball hash /mnt/data)" = "$(treeball hash backup.tar.gz)" ] && echo "unchanged"
+
+# Compute the fingerprint including the extended attributes (with BLAKE3):
+treeball hash /mnt/data --xattrs --algorithm=blake3`
+
+	benchHelpShort = "Measure the throughput of the operations on a synthetic tree"
+
+	benchHelpLong = `Measure the throughput of the operations on a synthetic tree.
+
+The command generates a synthetic tree of --files (empty) files in a temporary directory,
+creates a tarball from it, lists the tarball in sorted order, and compares the tarball
+against the tree. The throughput of each of the stages (in entries per second) is printed
+to standard output (stdout). All the generated files are removed again at the end.
+
+As all of the stages run with the given options (e.g. --workers, --chunksize, --walkers),
+this gives a standardized way to size these options for the hardware at hand. Note that the
+--tmpdir is used for the synthetic tree as well, so it should be on the storage in question.`
+
+	benchExample = `
+# Measure the throughput with the default options:
+treeball bench
+
+# Measure the throughput of a larger tree with more workers:
+treeball bench --files=1000000 --workers=8
+
+# Measure the throughput on a specific storage:
+treeball bench --tmpdir=/mnt/largedisk`
+
+	serveHelpShort = "Serve a REST API for listing, searching, and diffing archives over HTTP"
+
+	serveHelpLong = `Serve a REST API for listing, searching, and diffing archives over HTTP.
+
+The arguments are tarballs, or directories containing tarballs (*.tar.gz or *.tgz), which
+are served under their file names without the extension (e.g. "nightly" for nightly.tar.gz).
+Directories are rescanned upon every request, so that new archives are served right away.
+This allows for dashboards and other tooling to query archives without shelling out.
+
+  GET /archives                - JSON array of the served archives (name, path, size)
+  GET /archives/<name>/list    - sorted listing of an archive (one path per line)
+  GET /archives/<name>/find    - unsorted listing of only the paths matching any ?match
+  GET /archives/<name>/stats   - JSON object of the amounts of files and directories
+  GET /diff?old=<a>&new=<b>    - differences between two archives (as with 'diff')
+
+The listings take the query parameters exclude, match, and type (the latter also sort and
+sort-by), while the differences take the query parameters exclude, only, and files-only.
+These correspond to the respective commands' options, with exclude and match repeatable.
+The listings and differences are streamed, so that even massive archives can be served.
+Failures to read an archive are answered with a JSON object of the error, locating the
+offending entry by its index, path, offsets and the path of the last good entry before it.
+
+The command serves until interrupted (e.g. with Ctrl+C), with any running requests aborted.`
+
+	serveExample = `
+# Serve a single archive at port 8080:
+treeball serve /mnt/backups/nightly.tar.gz
+
+# Serve all archives of a directory at another port:
+treeball serve --listen=127.0.0.1:9000 /mnt/backups
+
+# Query the served archives:
+curl "http://localhost:8080/archives/nightly/find?match=**/*.mkv"
+curl "http://localhost:8080/diff?old=monday&new=tuesday&only=removed"`
+
+	watchHelpShort = "Continuously snapshot a directory tree upon changes (into tarballs)"
+
+	watchHelpLong = `Continuously snapshot a directory tree upon changes (into tarballs).
+
+An initial snapshot of the <root-folder> is created in the <output-folder> right away, after
+which the tree is watched for changes (using inotify, kqueue, etc.). Once per --interval, a
+new snapshot is created if any changes happened since the last one, so the tree is rescanned
+only when it actually changed. This allows for a continuous inventory of e.g. an ingest
+directory, without any cron jobs doing full rescans at fixed times.
+
+The snapshots are named by their (UTC) time of creation, such as
+snapshot-20250102T150405.000Z.tar.gz, with their paths printed to standard output (stdout).
+With --diffs, a diff tarball against the previous snapshot (as with 'diff') is also created
+alongside each snapshot, while any snapshots without differences are discarded again.
+
+Any failures of snapshots (after the initial one) are printed to standard error (stderr) and
+retried at the next interval. The command watches until interrupted (e.g. with Ctrl+C).
+With --rsyncable, the snapshots are written for efficient transfers with rsync (see 'create').
+With --fsync and --verify-after-write, the snapshots are synced and read back (see 'create').`
+
+	watchExample = `
+# Snapshot an ingest directory upon changes, at most every minute:
+treeball watch /mnt/ingest /mnt/snapshots
+
+# Snapshot at most every ten minutes, also creating diff tarballs:
+treeball watch /mnt/ingest /mnt/snapshots --interval=10m --diffs`
+
+	snapshotHelpShort = "Create a timestamped tarball of a directory tree, pruning older ones"
+
+	snapshotHelpLong = `Create a timestamped tarball of a directory tree, pruning older ones.
+
+The tarball is created (as with 'create') in the --dest directory, named after the --name
+template, which is a Go template with these fields (in the form of {{.Field}}):
+
+  Host      - hostname of the machine
+  Root      - base name of the <root-folder>
+  Date      - local date of the creation (e.g. 2025-01-02)
+  Time      - local time of the creation (e.g. 150405)
+  Timestamp - UTC time of the creation (e.g. 20250102T150405Z)
+  Unix      - Unix time of the creation (in seconds)
+
+With any of the --keep-* options, the older tarballs of the same name template are pruned,
+unless they are among the --keep-last tarballs, or the last tarball of one of the most recent
+--keep-daily days, --keep-weekly weeks, or --keep-monthly months. Their times are those of
+their last modification, and the created tarball is always kept. Any parts and sidecar files
+(checksums, signatures, indexes) of pruned tarballs are removed along with them. Without any
+of the --keep-* options, no tarballs are pruned. The path of the created tarball is printed to standard
+output (stdout), as are any pruned ones (prefixed with "pruned: "). An existing tarball of the
+same name (e.g. of the same day, if the name has no time) is only overwritten with --force.
+
+With --rsyncable, the tarballs are written for efficient transfers with rsync (see 'create'),
+e.g. so that daily snapshots transfer only their changes to offsite storage.
+With --fsync and --verify-after-write, the tarballs are synced and read back (see 'create').`
+
+	snapshotExample = `
+# Create a snapshot of a directory, keeping all older ones:
+treeball snapshot /mnt/data --dest=/mnt/snaps
+
+# Create a snapshot named after the host, keeping a week of daily and a month of weekly ones:
+treeball snapshot /mnt/data --dest=/mnt/snaps --name="{{.Host}}-{{.Date}}.tar.gz" --keep-daily=7 --keep-weekly=4`
+
+	mktreeHelpShort = "Generate a synthetic tree (for benchmarks and test fixtures)"
+
+	mktreeHelpLong = `Generate a synthetic tree of <file-count> (empty) files under <base-folder>.
+
+The files are spread across directories of --files-per-dir files each, which are nested in
+--depth levels of directories (named e.g. dept_00/proj_000/batch_0000/group_000000). The file
+names are formatted from --name-pattern with their index within their directory. The layout
+is the same as used by 'bench', so that the trees are comparable across runs and machines.
+
+This is a development command, which is not needed for any of the regular operations.`
+
+	mktreeExample = `
+# Generate a tree of one million files:
+treeball mktree /mnt/bench 1000000
+
+# Generate a flat tree of photos as test fixture:
+treeball mktree ./fixtures 500 --depth=1 --files-per-dir=250 --name-pattern="IMG_%04d.jpg"`
+
+	featuresHelpShort = "List the optional features compiled into the program"
+
+	featuresHelpLong = `List the optional features compiled into the program.
+
+Optional subsystems can be left out of a build using Go build tags, which allows for small
+builds (e.g. for embedded or NAS systems) while a regular build includes all the features.
+Building with the 'minimal' tag leaves out all optional features, while any individual one
+can be left out using its respective 'no_<name>' tag (e.g. 'no_pager').
+
+Each feature is printed to standard output (stdout) along with its state in the build.`
+
+	versionHelpShort = "Print the version and build metadata of the program"
+
+	versionHelpLong = `Print the version and build metadata of the program.
+
+Besides the version, the hash of the commit of the sources, the date of the build, the version
+of the Go toolchain (and the platform), and the optional features compiled into the build are
+printed, so that bug reports and audits of deployments can identify exactly what is running.
+These are populated by the build process, or otherwise taken from the information embedded by
+the Go toolchain (such as with 'go install'). The same is printed with --version.
+
+With --json, the metadata is printed as a JSON object instead (e.g. for collecting it from
+a fleet of machines), of which the "features" are the names of the enabled features only.`
+
+	versionExample = `
+# Print the version and build metadata:
+treeball version
+
+# Print the build metadata as JSON:
+treeball version --json`
+
+	patternsHelpShort = "Debug the exclude patterns as matched by the other commands"
+
+	patternsHelpLong = `Debug the exclude patterns as matched by the other commands.
+
+The subcommands match exclude patterns just as the other commands do, without any walk of a
+directory tree or reading of an archive, so that these can be checked before a full run.
+
+  test - report for each of the given paths which exclude (if any) matches it`
+
+	patternsTestHelpShort = "Report which exclude (if any) matches each of the given paths"
+
+	patternsTestHelpLong = `Report which exclude (if any) matches each of the given paths.
+
+The paths are relative to the root of a directory tree (or archive), as they would be matched
+during any other command, with directories carrying a trailing slash (e.g. 'vendor/'), so that
+any directory-only patterns (e.g. 'cache/') can be told apart from those matching files as well.
+
+Excludes are expected as relative to given sources and following 'doublestar' format:
+https://github.com/bmatcuk/doublestar?tab=readme-ov-file#patterns
+With --pattern-syntax, they can instead be given as lines of a .gitignore file (gitignore,
+with negation and the last matching deciding) or rules of an rsync filter file (rsync).
+
+Each path is printed to standard output (stdout) along with the first exclude matching it (as
+the --exclude, --exclude-regex or --max-depth it was given by), or as not excluded otherwise.
+With --include (or --includes-from), any files matching none of the includes are excluded.
+Paths within an excluded directory, but not excluded themselves, are pointed out as such, as
+these are left out of walks of directories (while only the paths themselves are matched in
+archives). Any .gitignore files (of --gitignore) are not considered, as these depend on the tree.`
+
+	patternsTestExample = `
+# Check which paths of a tree a pattern matches (vendor/ and its contents, but no nested ones):
+treeball patterns test --exclude='vendor/**' vendor/ vendor/lib/lib.go app/vendor/x.go
+
+# Check a directory-only pattern against a directory and a file of the same name:
+treeball patterns test --exclude='cache/' cache/ cache
+
+# Check the patterns of an excludes file, matched case-insensitively:
+treeball patterns test --excludes-from=./excludes.txt --ignore-case Movies/Extras/a.MKV`
 )
@@ -0,0 +1,21 @@
+//go:build minimal || no_http
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/spf13/afero"
+)
+
+const featureHTTP = false
+
+var errHTTPDisabled = errors.New("http:// and https:// sources are not compiled into this build")
+
+// dialHTTP is a stub for builds without the http feature compiled in.
+// It always returns an error, as no web server can be read from in such a build.
+func dialHTTP(_ context.Context, _ string) (afero.Fs, io.Closer, error) {
+	return nil, nil, errHTTPDisabled
+}
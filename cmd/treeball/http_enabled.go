@@ -0,0 +1,246 @@
+//go:build !minimal && !no_http
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const featureHTTP = true
+
+var errHTTPStatus = errors.New("unexpected http status")
+
+// dialHTTP prepares the reading of archives from a web server (as of a remote
+// being the scheme and authority of an http:// or https:// source), returning
+// its filesystem (read-only) and the closer aborting any requests in progress.
+//
+// Nothing is connected upfront, as every file is requested on its own. Any user
+// and password given as part of the authority are sent with basic authentication.
+func dialHTTP(ctx context.Context, remote string) (afero.Fs, io.Closer, error) {
+	base, err := url.Parse(remote)
+	if err != nil || base.Hostname() == "" {
+		return nil, nil, fmt.Errorf("%w: %q (expected https://host[:port]/path)", errInvalidRemote, remote)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	h := &httpFs{ctx: ctx, cancel: cancel, client: &http.Client{}, base: base}
+
+	return h, h, nil
+}
+
+// httpFs is a read-only [afero.Fs] of the files of a web server, with the paths
+// of their URLs as paths. The files are streamed with sequential reads of the
+// entire response (without any range requests), so they cannot be seeked.
+type httpFs struct {
+	ctx    context.Context //nolint:containedctx
+	cancel context.CancelFunc
+	client *http.Client
+	base   *url.URL // Scheme and authority of the web server
+}
+
+// Close aborts any requests of the filesystem still in progress.
+func (h *httpFs) Close() error {
+	h.cancel()
+
+	return nil
+}
+
+// do sends a request for a path, returning the response if it was successful.
+// The path is escaped into the URL (with any characters such as % or # being
+// part of the file names). The files are requested without any content encoding,
+// as archives are often served as gzip-encoded (which would otherwise be
+// decompressed on receipt).
+func (h *httpFs) do(op string, method string, name string) (*http.Response, error) {
+	u := *h.base
+	u.Path, u.RawPath = name, ""
+
+	req, err := http.NewRequestWithContext(h.ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		resp.Body.Close()
+
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		resp.Body.Close()
+
+		return nil, &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("%w: %s", errHTTPStatus, resp.Status)}
+	}
+
+	return resp, nil
+}
+
+// info returns the [os.FileInfo] of a file from the headers of its response.
+func (h *httpFs) info(resp *http.Response) *httpFileInfo {
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return &httpFileInfo{name: path.Base(resp.Request.URL.Path), size: max(resp.ContentLength, 0), modTime: modTime}
+}
+
+func (h *httpFs) Create(name string) (afero.File, error) {
+	return nil, &fs.PathError{Op: "create", Path: name, Err: errRemoteReadOnly}
+}
+
+func (h *httpFs) Mkdir(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errRemoteReadOnly}
+}
+
+func (h *httpFs) MkdirAll(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errRemoteReadOnly}
+}
+
+func (h *httpFs) Open(name string) (afero.File, error) {
+	resp, err := h.do("open", http.MethodGet, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpFile{name: name, body: resp.Body, info: h.info(resp)}, nil
+}
+
+func (h *httpFs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errRemoteReadOnly}
+	}
+
+	return h.Open(name)
+}
+
+func (h *httpFs) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: errRemoteReadOnly}
+}
+
+func (h *httpFs) RemoveAll(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: errRemoteReadOnly}
+}
+
+func (h *httpFs) Rename(oldname string, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errRemoteReadOnly}
+}
+
+// Stat returns the [os.FileInfo] of a file (requested with a HEAD request).
+// All paths are files, as web servers have no notion of listable directories.
+func (h *httpFs) Stat(name string) (os.FileInfo, error) {
+	resp, err := h.do("stat", http.MethodHead, name)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return h.info(resp), nil
+}
+
+func (h *httpFs) Name() string {
+	return "httpFs"
+}
+
+func (h *httpFs) Chmod(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: errRemoteReadOnly}
+}
+
+func (h *httpFs) Chown(name string, _ int, _ int) error {
+	return &fs.PathError{Op: "chown", Path: name, Err: errRemoteReadOnly}
+}
+
+func (h *httpFs) Chtimes(name string, _ time.Time, _ time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: errRemoteReadOnly}
+}
+
+// httpFileInfo is the [os.FileInfo] of a file of an [httpFs].
+type httpFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi *httpFileInfo) Name() string       { return fi.name }
+func (fi *httpFileInfo) Size() int64        { return fi.size }
+func (fi *httpFileInfo) Mode() fs.FileMode  { return 0o444 } //nolint:mnd
+func (fi *httpFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *httpFileInfo) IsDir() bool        { return false }
+func (fi *httpFileInfo) Sys() any           { return nil }
+
+// httpFile is an [afero.File] of an [httpFs], streaming the body of its response.
+type httpFile struct {
+	name string
+	body io.ReadCloser
+	info *httpFileInfo
+}
+
+func (f *httpFile) unsupported(op string) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *httpFile) Close() error {
+	return f.body.Close() //nolint:wrapcheck
+}
+
+func (f *httpFile) Read(p []byte) (int, error) {
+	return f.body.Read(p) //nolint:wrapcheck
+}
+
+func (f *httpFile) ReadAt(_ []byte, _ int64) (int, error) {
+	return 0, f.unsupported("read")
+}
+
+func (f *httpFile) Seek(_ int64, _ int) (int64, error) {
+	return 0, f.unsupported("seek")
+}
+
+func (f *httpFile) Write(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errRemoteReadOnly}
+}
+
+func (f *httpFile) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errRemoteReadOnly}
+}
+
+func (f *httpFile) WriteString(_ string) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errRemoteReadOnly}
+}
+
+func (f *httpFile) Name() string {
+	return f.name
+}
+
+func (f *httpFile) Readdir(_ int) ([]os.FileInfo, error) {
+	return nil, f.unsupported("readdir")
+}
+
+func (f *httpFile) Readdirnames(_ int) ([]string, error) {
+	return nil, f.unsupported("readdir")
+}
+
+func (f *httpFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *httpFile) Sync() error {
+	return nil
+}
+
+func (f *httpFile) Truncate(_ int64) error {
+	return &fs.PathError{Op: "truncate", Path: f.name, Err: errRemoteReadOnly}
+}
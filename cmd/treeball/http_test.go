@@ -0,0 +1,102 @@
+//go:build !minimal && !no_http
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to start a web server serving the given files (by path),
+// always declaring them as gzip-encoded (as some servers do for any .tar.gz files).
+func newTestHTTPServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		http.ServeContent(w, r, r.URL.Path, time.Now(), bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// Expectation: An archive published on a web server should be listed (streamed as it is served).
+func Test_Program_List_HTTP_Success(t *testing.T) {
+	srv := newTestHTTPServer(t, map[string][]byte{"/snapshots/snap.tar.gz": createTar([]string{"b.txt", "a.txt"})})
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(afero.NewMemMapFs(), &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.List(t.Context(), srv.URL+"/snapshots/snap.tar.gz", true, nil))
+
+	require.Equal(t, "a.txt\nb.txt\n", stdoutBuf.String())
+}
+
+// Expectation: The paths of archives should be escaped into their URLs, with any special characters as part of their names.
+func Test_Program_List_HTTPEscaped_Success(t *testing.T) {
+	srv := newTestHTTPServer(t, map[string][]byte{"/my snaps/100% #1?.tar.gz": createTar([]string{"a.txt"})})
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(afero.NewMemMapFs(), &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.List(t.Context(), srv.URL+"/my snaps/100% #1?.tar.gz", true, nil))
+
+	require.Equal(t, "a.txt\n", stdoutBuf.String())
+}
+
+// Expectation: An archive published on a web server should be compared against a local tree.
+func Test_Program_Diff_HTTP_Success(t *testing.T) {
+	srv := newTestHTTPServer(t, map[string][]byte{"/snap.tar.gz": createTar([]string{"a.txt", "b.txt"})})
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/data", 0o755))
+	require.NoError(t, afero.WriteFile(fs, "/data/b.txt", nil, 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	_, err := prog.Diff(t.Context(), srv.URL+"/snap.tar.gz", "/data", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- a.txt\n", stdoutBuf.String())
+}
+
+// Expectation: An archive not published on a web server should be returned as not existing.
+func Test_Program_List_HTTPNotFound_Error(t *testing.T) {
+	srv := newTestHTTPServer(t, nil)
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.List(t.Context(), srv.URL+"/missing.tar.gz", true, nil)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+// Expectation: An archive should not be written to a web server.
+func Test_Program_ListTo_HTTPOutput_Error(t *testing.T) {
+	srv := newTestHTTPServer(t, nil)
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.ListTo(t.Context(), "/in.tar.gz", true, nil, srv.URL+"/out.txt")
+	require.ErrorIs(t, err, errRemoteReadOnly)
+}
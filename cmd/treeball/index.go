@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	indexSuffix  = ".tbi"
+	indexVersion = 1
+)
+
+var errIndexInvalid = errors.New("invalid index file")
+
+// archiveIndexHeader is the header of an index file, identifying the archive
+// it was built from, so that an index of a since modified archive is ignored.
+type archiveIndexHeader struct {
+	Version int       `json:"version"` // Version of the index format
+	Size    int64     `json:"size"`    // Size of the archive at the time of indexing
+	ModTime time.Time `json:"modTime"` // Modification time of the archive at the time of indexing
+}
+
+// indexPath returns the path of the (sidecar) index file of an archive.
+func indexPath(input string) string {
+	return input + indexSuffix
+}
+
+// Index builds the sidecar index file of a given tarball (as <input>.tbi),
+// so that listing the tarball no longer requires decompressing all of it.
+//
+// The index file is gzip-compressed, beginning with a JSON header line (see
+// [archiveIndexHeader]), followed by a record for every entry of the tarball
+// in sorted order (by name), each being the byte offset of the entry's header
+// within the (uncompressed) tar stream and its path, separated by a tab and
+// terminated with a NUL byte. Any existing index file is replaced, as it is
+// only ever derived from the tarball. The amount of entries is printed at the
+// end and returned. The ctx parameter controls early cancellation.
+func (prog *Program) Index(ctx context.Context, input string) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	progressFrom(ctx).setPhase("indexing %s", input)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input, indexPath(input))
+	if err != nil {
+		return 0, err
+	}
+	defer closeRemotes()
+
+	info, err := prog.fs.Stat(input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat input file: %w", err)
+	}
+
+	records, errs := prog.tarRecordStream(ctx, input)
+	records, errs = extsortStrings(ctx, records, errs, prog.extSortConfig)
+
+	tmp := indexPath(input) + ".tmp"
+
+	out, err := prog.fs.Create(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create index file: %w", err)
+	}
+	defer func() {
+		out.Close()
+		_ = prog.fs.Remove(tmp)
+	}()
+
+	gw := gzip.NewWriter(progressFrom(ctx).trackWrites(out))
+	bw := bufio.NewWriter(gw)
+
+	header, err := json.Marshal(archiveIndexHeader{Version: indexVersion, Size: info.Size(), ModTime: info.ModTime()})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode index header: %w", err)
+	}
+	// Any errors of the buffered writer persist, so are checked once flushing.
+	fmt.Fprintf(bw, "%s\n", header)
+
+	var entries int64
+
+	for record := range records {
+		path, offset, _ := strings.Cut(record, "\x00")
+		fmt.Fprintf(bw, "%s\t%s\x00", offset, path)
+
+		entries++
+	}
+
+	for err := range errs {
+		if err != nil {
+			return 0, fmt.Errorf("failure during indexing: %w", err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write index file: %w", err)
+	}
+
+	if err := gw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close index file: %w", err)
+	}
+
+	if err := prog.fs.Rename(tmp, indexPath(input)); err != nil {
+		return 0, fmt.Errorf("failed to write index file: %w", err)
+	}
+
+	fmt.Fprintf(prog.stdout, "entries: %d\n", entries)
+
+	return entries, nil
+}
+
+// tarRecordStream returns a stream of the entries of a tarball as records
+// of their path and the offset of their header within the tar stream (as
+// decimal), separated by a NUL byte, so that the records sort by path.
+func (prog *Program) tarRecordStream(ctx context.Context, path string) (<-chan string, <-chan error) {
+	records := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		f, err := prog.openArchive(path)
+		if err != nil {
+			errs <- fmt.Errorf("failed to open input file: %w", err)
+
+			return
+		}
+		defer f.Close()
+
+		cr := &countingReader{r: contextReader{ctx: ctx, r: f}}
+
+		ar, err := newArchiveReader(cr)
+		if err != nil {
+			errs <- err
+
+			return
+		}
+		defer ar.Close()
+
+		tc := &countingReader{r: ar}
+		tr := newConcatTarReader(tc, false)
+
+		var index int64
+		var prevPath string
+
+		for ; ; index++ {
+			offset, compressedOffset := tc.n.Load(), cr.n.Load()
+
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			} else if err != nil {
+				errs <- fmt.Errorf("failed to stream from tar: %w", &StreamError{
+					Index: index, PrevPath: prevPath,
+					Offset: offset, CompressedOffset: compressedOffset, Err: err,
+				})
+
+				return
+			}
+
+			p, ok, err := prog.tarEntryPath(hdr)
+			if err != nil {
+				errs <- fmt.Errorf("failed to stream from tar: %w", &StreamError{
+					Index: index, Path: hdr.Name, PrevPath: prevPath,
+					Offset: offset, CompressedOffset: compressedOffset, Err: err,
+				})
+
+				return
+			}
+
+			// The offset is that of the entry's first header, as any extended
+			// headers (e.g. PAX records of long paths) precede the entry itself.
+			if ok {
+				select {
+				case records <- p + "\x00" + strconv.FormatInt(offset, 10):
+				case <-ctx.Done():
+					errs <- fmt.Errorf("failed to stream from tar: %w", ctx.Err())
+
+					return
+				}
+			}
+
+			prevPath = hdr.Name
+			progress.addEntry()
+		}
+	}()
+
+	return records, errs
+}
+
+// indexPathStream returns a stream of the paths of a tarball in sorted order
+// (by name) as read from its index file, skipping any paths excluded as with
+// [Program.tarPathStream], and if there is an index file to be read at all.
+// With a [ProgramConfig.Subdir], the index file is only read up to its end.
+//
+// An index file is only read if it matches the size and modification time of
+// the tarball (otherwise it is stale, and a warning is printed), and never with
+// [ProgramConfig.NoIndex]. Without one, the tarball itself is to be streamed.
+func (prog *Program) indexPathStream(ctx context.Context, input string, excludes []string) (<-chan string, <-chan error, bool) {
+	if prog.config.NoIndex {
+		return nil, nil, false
+	}
+
+	f, err := prog.fs.Open(indexPath(input))
+	if err != nil {
+		return nil, nil, false
+	}
+
+	info, err := prog.fs.Stat(input)
+	if err != nil {
+		f.Close()
+
+		return nil, nil, false
+	}
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		prog.warnf("ignoring index %q: %v", indexPath(input), fmt.Errorf("%w: %w", errIndexInvalid, err))
+
+		return nil, nil, false
+	}
+
+	br := bufio.NewReader(gr)
+
+	var header archiveIndexHeader
+	if line, err := br.ReadBytes('\n'); err != nil || json.Unmarshal(line, &header) != nil || header.Version != indexVersion {
+		f.Close()
+		prog.warnf("ignoring index %q: %v", indexPath(input), errIndexInvalid)
+
+		return nil, nil, false
+	}
+
+	if header.Size != info.Size() || !header.ModTime.Equal(info.ModTime()) {
+		f.Close()
+		prog.warnf("ignoring index %q: archive was modified since (re-run 'treeball index')", indexPath(input))
+
+		return nil, nil, false
+	}
+
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	matcher := prog.excludeMatcher(excludes)
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer f.Close()
+		defer recoverWorker(ctx)
+
+		for {
+			record, err := br.ReadString('\x00')
+			if errors.Is(err, io.EOF) && record == "" {
+				return
+			} else if err != nil {
+				errs <- fmt.Errorf("failed to stream from index: %w", err)
+
+				return
+			}
+
+			_, path, ok := strings.Cut(strings.TrimSuffix(record, "\x00"), "\t")
+			if !ok {
+				errs <- fmt.Errorf("failed to stream from index: %w: %q", errIndexInvalid, record)
+
+				return
+			}
+
+			// The paths of a subdirectory are all adjacent in the sorted order.
+			if !prog.inSubdir(path) {
+				if path > prog.config.Subdir {
+					return
+				}
+
+				continue
+			}
+
+			if excluded, err := prog.isExcluded(path, strings.HasSuffix(path, "/"), matcher); err != nil {
+				errs <- fmt.Errorf("failed to check for exclusion: %w", err)
+
+				return
+			} else if !excluded {
+				select {
+				case paths <- path:
+				case <-ctx.Done():
+					errs <- fmt.Errorf("failed to stream from index: %w", ctx.Err())
+
+					return
+				}
+			}
+
+			progress.addEntry()
+		}
+	}()
+
+	return paths, errs, true
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to replace an archive with garbage of the same size
+// and modification time, so that it can only be listed from its index file.
+func replaceWithGarbage(t *testing.T, fs afero.Fs, path string) {
+	t.Helper()
+
+	info, err := fs.Stat(path)
+	require.NoError(t, err)
+
+	size, modTime := info.Size(), info.ModTime()
+
+	require.NoError(t, afero.WriteFile(fs, path, bytes.Repeat([]byte{'x'}, int(size)), 0o644))
+	require.NoError(t, fs.Chtimes(path, modTime, modTime))
+}
+
+// Expectation: The index file should contain all paths in sorted order along with their offsets.
+func Test_Program_Index_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"z.txt", "a.txt", "dir/"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	entries, err := prog.Index(t.Context(), "/archive.tar.gz")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), entries)
+	require.Equal(t, "entries: 3\n", stdoutBuf.String())
+
+	f, err := fs.Open("/archive.tar.gz.tbi")
+	require.NoError(t, err)
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(gr)
+	require.NoError(t, err)
+
+	header, records, ok := strings.Cut(string(data), "\n")
+	require.True(t, ok)
+	require.Contains(t, header, `"version":1`)
+	require.Equal(t, "512\ta.txt\x001024\tdir/\x000\tz.txt\x00", records)
+
+	_, err = fs.Stat("/archive.tar.gz.tbi.tmp")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: A sorted listing and counting should be read from the index file instead of the archive.
+func Test_Program_List_Indexed_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"z.txt", "a.txt", "dir/", "dir/b.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{OnlyType: EntryTypeFile})
+
+	_, err := prog.Index(t.Context(), "/archive.tar.gz")
+	require.NoError(t, err)
+
+	replaceWithGarbage(t, fs, "/archive.tar.gz")
+
+	var stdoutBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{OnlyType: EntryTypeFile})
+	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, []string{"z.txt"}))
+	require.Equal(t, "a.txt\ndir/b.txt\n", stdoutBuf.String())
+
+	stdoutBuf.Reset()
+
+	count, err := prog.Count(t.Context(), "/archive.tar.gz", nil)
+	require.NoError(t, err)
+	require.Equal(t, &ListCount{Files: 3, Directories: 0}, count)
+}
+
+// Expectation: An index file should not be read for unsorted listings, or with NoIndex.
+func Test_Program_List_Indexed_Bypassed_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    bool
+		noIndex bool
+	}{
+		{"Unsorted", false, false},
+		{"NoIndex", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{NoIndex: tt.noIndex})
+
+			_, err := prog.Index(t.Context(), "/archive.tar.gz")
+			require.NoError(t, err)
+
+			replaceWithGarbage(t, fs, "/archive.tar.gz")
+
+			err = prog.List(t.Context(), "/archive.tar.gz", tt.sort, nil)
+			require.Error(t, err)
+		})
+	}
+}
+
+// Expectation: An index file of a since modified archive should be ignored with a warning.
+func Test_Program_List_IndexStale_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Index(t.Context(), "/archive.tar.gz")
+	require.NoError(t, err)
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+	require.NoError(t, fs.Chtimes("/archive.tar.gz", time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil, nil)
+	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, nil))
+
+	require.Equal(t, "a.txt\nb.txt\n", stdoutBuf.String())
+	require.Contains(t, stderrBuf.String(), "warning: ignoring index")
+}
+
+// Expectation: A corrupt archive should fail to be indexed, leaving no index file behind.
+func Test_Program_Index_Corrupt_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	data := createTar([]string{"a.txt", "b.txt"})
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", data[:len(data)/2], 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Index(t.Context(), "/archive.tar.gz")
+	require.Error(t, err)
+	require.True(t, isCorruption(err))
+
+	for _, path := range []string{"/archive.tar.gz.tbi", "/archive.tar.gz.tbi.tmp"} {
+		_, err = fs.Stat(path)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+}
+
+// Expectation: The index file should only be read up to the end of the subdirectory.
+func Test_Program_List_IndexedSubdir_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt", "b/", "b/c.txt", "d.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Index(t.Context(), "/archive.tar.gz")
+	require.NoError(t, err)
+
+	// Corrupts the index file after the record following the subdirectory, which must so never be read.
+	f, err := fs.Open("/archive.tar.gz.tbi")
+	require.NoError(t, err)
+
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err = gw.Write([]byte(string(data)+"corrupt\x00"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz.tbi", buf.Bytes(), 0o644))
+
+	replaceWithGarbage(t, fs, "/archive.tar.gz")
+
+	var stdoutBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Subdir: "b/"})
+	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, nil))
+	require.Equal(t, "b/\nb/c.txt\n", stdoutBuf.String())
+
+	prog = NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Subdir: "d/"})
+	require.ErrorIs(t, prog.List(t.Context(), "/archive.tar.gz", true, nil), errIndexInvalid)
+}
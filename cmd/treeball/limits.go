@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// reservedOpenFiles are the files held open besides those of the concurrent
+// operations (see [applyOpenFilesLimit]), such as the standard streams, the
+// inputs and outputs, and the connections of any remotes or metrics.
+const reservedOpenFiles = 32
+
+var errOpenFilesLimit = errors.New("open files limit exceeded")
+
+// applyOpenFilesLimit checks the concurrency of a command (the directories
+// read concurrently with --walkers, each holding a directory open, and the
+// workers of --workers, each holding a temporary file of the external sorting
+// or an archive open) against the files allowed to be held open at once by
+// the system (RLIMIT_NOFILE, as set with 'ulimit -n'), before anything is run.
+//
+// With a maxOpenFiles (as for --max-open-files, which must not exceed the
+// system's limit), the concurrency is governed by that instead, lowering the
+// values of the flags so that they stay within it. Without one, concurrency
+// exceeding the system's limit is an error rather than failing midway.
+func applyOpenFilesLimit(cmd *cobra.Command, maxOpenFiles int) error {
+	limit, ok := openFilesLimit()
+	if !ok {
+		limit = 0
+	}
+
+	return governOpenFiles(cmd, maxOpenFiles, limit)
+}
+
+// governOpenFiles is [applyOpenFilesLimit] with the system's limit (0: unknown).
+func governOpenFiles(cmd *cobra.Command, maxOpenFiles int, limit int) error {
+	if maxOpenFiles < 0 {
+		return fmt.Errorf("%w: --max-open-files=%d must not be negative", errOpenFilesLimit, maxOpenFiles)
+	}
+
+	if maxOpenFiles > 0 && limit > 0 && maxOpenFiles > limit {
+		return fmt.Errorf("%w: --max-open-files=%d exceeds the limit of the system of %d (raise it with 'ulimit -n')", errOpenFilesLimit, maxOpenFiles, limit)
+	}
+
+	governed := maxOpenFiles > 0
+	if !governed {
+		if limit == 0 {
+			return nil
+		}
+		maxOpenFiles = limit
+	}
+
+	walkers, hasWalkers := intFlag(cmd, "walkers")
+	workers, hasWorkers := intFlag(cmd, "workers")
+
+	if !hasWalkers && !hasWorkers {
+		return nil
+	}
+
+	if maxOpenFiles <= reservedOpenFiles {
+		return fmt.Errorf("%w: at least %d files must be allowed open (of %d)", errOpenFilesLimit, reservedOpenFiles+1, maxOpenFiles)
+	}
+
+	budget := maxOpenFiles - reservedOpenFiles
+	if walkers+workers <= budget {
+		return nil
+	}
+
+	if !governed {
+		return fmt.Errorf("%w: --walkers=%d and --workers=%d may need %d files open, of only %d allowed by the system (raise it with 'ulimit -n', or lower them or set --max-open-files)",
+			errOpenFilesLimit, walkers, workers, walkers+workers+reservedOpenFiles, limit)
+	}
+
+	// The workers are given up to half of the budget, the walkers the remainder.
+	workers = max(1, min(workers, budget/2)) //nolint:mnd
+	walkers = max(0, min(walkers, budget-workers))
+
+	for name, value := range map[string]int{"walkers": walkers, "workers": workers} {
+		if f := cmd.Flags().Lookup(name); f != nil {
+			if err := cmd.Flags().Set(name, strconv.Itoa(value)); err != nil {
+				return fmt.Errorf("failed to govern open files: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// intFlag returns the value of an integer flag of a command, if it has one.
+func intFlag(cmd *cobra.Command, name string) (int, bool) {
+	if cmd.Flags().Lookup(name) == nil {
+		return 0, false
+	}
+
+	v, err := cmd.Flags().GetInt(name)
+	if err != nil {
+		return 0, false
+	}
+
+	return v, true
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// openFilesLimit returns false, as the limit of open files is not available.
+func openFilesLimit() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to return a command with the concurrency flags set.
+func newLimitsTestCmd(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+
+	var walkers, workers int
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().IntVar(&walkers, "walkers", 0, "")
+	cmd.Flags().IntVar(&workers, "workers", 4, "")
+	require.NoError(t, cmd.Flags().Parse(args))
+
+	return cmd
+}
+
+// Expectation: The concurrency should be checked against the limit, or governed by the maximum.
+func Test_governOpenFiles_Table(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		maxOpenFiles int
+		limit        int
+		wantWalkers  int
+		wantWorkers  int
+		wantErr      bool
+	}{
+		{"Unknown limit", []string{"--walkers=5000"}, 0, 0, 5000, 4, false},
+		{"Within limit", []string{"--walkers=64"}, 0, 1024, 64, 4, false},
+		{"Exceeding limit", []string{"--walkers=2000"}, 0, 1024, 0, 0, true},
+		{"Governed", []string{"--walkers=2000", "--workers=64"}, 96, 1024, 32, 32, false},
+		{"Governed within", []string{"--walkers=8"}, 64, 1024, 8, 4, false},
+		{"Governed unknown limit", []string{"--walkers=100"}, 64, 0, 28, 4, false},
+		{"Maximum exceeding limit", nil, 2048, 1024, 0, 0, true},
+		{"Maximum too low", nil, 8, 1024, 0, 0, true},
+		{"Negative maximum", nil, -1, 1024, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newLimitsTestCmd(t, tt.args...)
+
+			err := governOpenFiles(cmd, tt.maxOpenFiles, tt.limit)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errOpenFilesLimit)
+
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, tt.wantWalkers, mustGetInt(t, cmd, "walkers"))
+			require.Equal(t, tt.wantWorkers, mustGetInt(t, cmd, "workers"))
+		})
+	}
+}
+
+// Expectation: Commands without any concurrency should not be checked.
+func Test_governOpenFiles_NoFlags_Success(t *testing.T) {
+	require.NoError(t, governOpenFiles(&cobra.Command{Use: "test"}, 0, 8))
+}
+
+// Expectation: The maximum should govern the concurrency of the command.
+func Test_CLI_MaxOpenFiles_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"hash", "/src", "--walkers=1000", "--workers=4", "--max-open-files=64"})
+	require.NoError(t, cmd.Execute())
+
+	hashCmd, _, err := cmd.Find([]string{"hash"})
+	require.NoError(t, err)
+
+	require.Equal(t, 28, mustGetInt(t, hashCmd, "walkers"))
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package main
+
+import (
+	"math"
+	"syscall"
+)
+
+// openFilesLimit returns the (soft) limit of the files a process may hold open
+// at once (RLIMIT_NOFILE), or false if there is no such limit (or it is unknown).
+func openFilesLimit() (int, bool) {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, false
+	}
+
+	if cur := uint64(rlim.Cur); cur == 0 || cur > math.MaxInt32 { //nolint:gosec,unconvert
+		return 0, false
+	}
+
+	return int(rlim.Cur), true
+}
@@ -1,21 +1,321 @@
 package main
 
 import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
 )
 
+// EntryType is a type of the entries of a tarball.
+type EntryType int
+
+const (
+	// EntryTypeAny is any type of entry.
+	EntryTypeAny EntryType = iota
+
+	// EntryTypeFile is a file (without a trailing slash).
+	EntryTypeFile
+
+	// EntryTypeDir is a directory (with a trailing slash).
+	EntryTypeDir
+)
+
+// SortOrder is an order of the entries of a sorted listing.
+type SortOrder int
+
+const (
+	// SortByName sorts the entries lexicographically by their path.
+	SortByName SortOrder = iota
+
+	// SortByReverse sorts the entries reverse-lexicographically by their path.
+	SortByReverse
+
+	// SortByDepth sorts the entries by the depth of their path (then by name).
+	SortByDepth
+
+	// SortByVersion sorts the entries naturally, with any numbers within
+	// their path compared numerically (e.g. "file2" before "file10").
+	SortByVersion
+)
+
+var errInvalidSortOrder = errors.New("invalid sort order")
+
+// parseSortOrder returns the [SortOrder] for an order name (as for --sort-by).
+func parseSortOrder(name string) (SortOrder, error) {
+	switch strings.ToLower(name) {
+	case "", "name":
+		return SortByName, nil
+	case "reverse":
+		return SortByReverse, nil
+	case "depth":
+		return SortByDepth, nil
+	case "version":
+		return SortByVersion, nil
+	default:
+		return SortByName, fmt.Errorf("%w: %q (expected name, reverse, depth or version)", errInvalidSortOrder, name)
+	}
+}
+
+// compareFunc returns the function comparing two paths in the [SortOrder].
+func (o SortOrder) compareFunc() func(a, b string) int {
+	switch o {
+	case SortByReverse:
+		return func(a, b string) int {
+			return strings.Compare(b, a)
+		}
+	case SortByDepth:
+		return func(a, b string) int {
+			if c := pathDepth(a) - pathDepth(b); c != 0 {
+				return c
+			}
+
+			return strings.Compare(a, b)
+		}
+	case SortByVersion:
+		return compareVersion
+	case SortByName:
+	}
+
+	return strings.Compare
+}
+
+// compareVersion compares two paths naturally, comparing any runs of digits
+// numerically and the remainder lexicographically (e.g. "file2" < "file10").
+// Paths equal by their numbers (e.g. "file01" and "file1") fall back to the
+// lexicographic order, so that the order remains strict and deterministic.
+func compareVersion(a, b string) int {
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			ei, ej := digitsEnd(a, i), digitsEnd(b, j)
+			na, nb := strings.TrimLeft(a[i:ei], "0"), strings.TrimLeft(b[j:ej], "0")
+
+			if c := len(na) - len(nb); c != 0 {
+				return c
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+
+			i, j = ei, ej
+
+			continue
+		}
+
+		if a[i] != b[j] {
+			return int(a[i]) - int(b[j])
+		}
+
+		i++
+		j++
+	}
+
+	if c := (len(a) - i) - (len(b) - j); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a, b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// digitsEnd returns the index after the run of digits starting at index i.
+func digitsEnd(s string, i int) int {
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+
+	return i
+}
+
+// ListFormat is a format of the output of a [Program.List] operation.
+type ListFormat int
+
+const (
+	// ListText lists the paths as text, one per line (or NUL-terminated).
+	ListText ListFormat = iota
+
+	// ListJSONL lists the entries as JSON Lines, one object per line (see [archiveEntry]).
+	ListJSONL
+
+	// ListMtree lists the entries as an mtree specification (see [formatMtreeEntry]).
+	ListMtree
+
+	// ListLong lists the entries with their type, mode, size and modification
+	// time in columns before their paths, as "ls -l" does (see [formatLongEntry]).
+	ListLong
+)
+
+var errInvalidListFormat = errors.New("invalid list format")
+
+// parseListFormat returns the [ListFormat] for a format name (as for --format).
+func parseListFormat(name string) (ListFormat, error) {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return ListText, nil
+	case "jsonl":
+		return ListJSONL, nil
+	case "mtree":
+		return ListMtree, nil
+	case "long":
+		return ListLong, nil
+	default:
+		return ListText, fmt.Errorf("%w: %q (expected text, jsonl, mtree or long)", errInvalidListFormat, name)
+	}
+}
+
+// recordPath returns the path of a record of a path stream, which is either
+// the path itself or the path followed by a NUL byte and further data (such
+// as the JSON object of its entry), as paths never contain any NUL bytes.
+func recordPath(record string) string {
+	path, _, _ := strings.Cut(record, "\x00")
+
+	return path
+}
+
+var errInvalidSubdir = errors.New("invalid subdirectory")
+
+// parseSubdir returns the normalized directory path (with a trailing slash)
+// of a subdirectory of archives (as for --subdir), or an empty one for all.
+func parseSubdir(arg string) (string, error) {
+	dir := path.Clean(strings.Trim(arg, "/"))
+	if dir == "." {
+		return "", nil
+	}
+
+	if dir == ".." || strings.HasPrefix(dir, "../") {
+		return "", fmt.Errorf("%w: %q (must not escape the tree)", errInvalidSubdir, arg)
+	}
+
+	return dir + "/", nil
+}
+
+// inSubdir returns if a path is within the [ProgramConfig.Subdir] (including
+// the subdirectory itself), which all paths are without a subdirectory.
+func (prog *Program) inSubdir(p string) bool {
+	return strings.HasPrefix(p, prog.config.Subdir)
+}
+
+// filtersPaths returns if the paths of listings are to be filtered (see [Program.filterPathStream]).
+func (prog *Program) filtersPaths() bool {
+	return len(prog.config.Matches) > 0 || prog.config.OnlyType != EntryTypeAny || prog.config.Subdir != ""
+}
+
+var errInvalidEntryType = errors.New("invalid entry type")
+
+// parseEntryType returns the [EntryType] for a type name (as for --type).
+func parseEntryType(name string) (EntryType, error) {
+	switch strings.ToLower(name) {
+	case "", "any":
+		return EntryTypeAny, nil
+	case "f", "file":
+		return EntryTypeFile, nil
+	case "d", "dir":
+		return EntryTypeDir, nil
+	default:
+		return EntryTypeAny, fmt.Errorf("%w: %q (expected f or d)", errInvalidEntryType, name)
+	}
+}
+
 // List writes to standard output the contents of a given tarball.
 //
-// The input parameter specifies the path to the tarball. If sort is true, the
-// entries are printed in alphabetically sorted order; otherwise, they are
-// written in the original archive's order. Any paths matching the excludes
-// slice are skipped. The ctx parameter controls early cancellation.
+// The input parameter specifies the path to the tarball, which can also be a
+// list of paths, an mtree specification or a hashdeep manifest (see
+// [Program.filePathStream]). If sort is true, the entries are printed in sorted
+// order (alphabetically, unless another order is set in [ProgramConfig.SortBy]);
+// otherwise, they are written in the original archive's order. Any paths
+// matching the excludes slice are skipped, as are any not matching the
+// [ProgramConfig.Matches] (if there are any) or the [ProgramConfig.OnlyType],
+// and any outside of the [ProgramConfig.Subdir] (if there is one).
+// The ctx parameter controls early cancellation.
+//
+// Sorted listings are read from the tarball's index file instead (as built by
+// [Program.Index]), if there is one matching the tarball, so that the tarball
+// does not need to be decompressed (nor sorted, if sorted by name) at all.
+// Of the index file, only the records up to the end of any subdirectory are read.
+//
+// With [ListJSONL] as the [ProgramConfig.ListFormat], every entry is printed as
+// a JSON object on its own line (with its type and any recorded metadata, see
+// [archiveEntry]) instead, which are always read from the tarball itself. The
+// same goes for [ListMtree], with which an mtree specification is printed, and
+// for [ListLong], with which the metadata is printed in columns before the paths.
 func (prog *Program) List(ctx context.Context, input string, sort bool, excludes []string) error {
-	paths, errs := prog.tarPathStream(ctx, input, sort, excludes)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
 
-	for path := range paths {
-		fmt.Fprintln(prog.stdout, path)
+	progressFrom(ctx).setPhase("listing %s", input)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input)
+	if err != nil {
+		return err
+	}
+	defer closeRemotes()
+
+	var paths <-chan string
+	var errs <-chan error
+	var indexed bool
+
+	text := prog.config.ListFormat == ListText
+
+	if sort && text {
+		paths, errs, indexed = prog.indexPathStream(ctx, input, excludes)
+	}
+	switch {
+	case !text && prog.isPathSource(input):
+		paths, errs = prog.filePathStream(ctx, input, false, excludes)
+		entries, entryErrs := pathEntryStream(ctx, paths, errs)
+		paths, errs = prog.entryRecordStream(ctx, entries, entryErrs)
+	case !text:
+		entries, entryErrs := prog.tarEntryStream(ctx, input, excludes)
+		paths, errs = prog.entryRecordStream(ctx, entries, entryErrs)
+	case !indexed:
+		paths, errs = prog.filePathStream(ctx, input, false, excludes)
+	}
+
+	if prog.filtersPaths() {
+		paths, errs = prog.filterPathStream(ctx, paths, errs)
+	}
+
+	if sort && (!indexed || prog.config.SortBy != SortByName) {
+		compare := prog.config.SortBy.compareFunc()
+
+		paths, errs = extsortStringsFunc(ctx, paths, errs, prog.extSortConfig, func(a, b string) int {
+			return compare(recordPath(a), recordPath(b))
+		})
+	}
+
+	if prog.config.ListFormat == ListMtree {
+		fmt.Fprintln(prog.stdout, string(mtreeMagic))
+	}
+
+	for record := range paths {
+		if prog.config.ListFormat == ListLong {
+			path, columns, _ := strings.Cut(record, "\x00")
+			prog.printPath(columns + "  " + prog.formatPath(path))
+
+			continue
+		}
+
+		if !text {
+			_, line, _ := strings.Cut(record, "\x00")
+			fmt.Fprintln(prog.stdout, line)
+
+			continue
+		}
+
+		prog.printPath(prog.formatPath(record))
 	}
 
 	for err := range errs {
@@ -26,3 +326,334 @@ func (prog *Program) List(ctx context.Context, input string, sort bool, excludes
 
 	return nil
 }
+
+// ListTo is a variant of [Program.List] writing the listing to an output
+// file instead of standard output, which is compressed with gzip if its path
+// ends in ".gz" (e.g. for massive listings). The output file is protected and
+// removed upon failure as with [Program.Create]. The other parameters are
+// those of [Program.List].
+func (prog *Program) ListTo(ctx context.Context, input string, sort bool, excludes []string, output string) error {
+	var listingDone bool
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input, output)
+	if err != nil {
+		return err
+	}
+	defer closeRemotes()
+
+	out, err := prog.createOutput(ctx, output)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	defer func() {
+		if !listingDone {
+			_ = prog.fs.Remove(output)
+		}
+	}()
+	defer out.Close()
+
+	var w io.Writer = out
+
+	var gw *gzip.Writer
+	if strings.HasSuffix(output, ".gz") {
+		if gw, err = gzip.NewWriterLevel(out, prog.gzipConfig.CompressionLevel); err != nil {
+			return fmt.Errorf("failed to initialize gzip writer: %w", err)
+		}
+		defer gw.Close()
+
+		w = gw
+	}
+
+	bw := bufio.NewWriter(w)
+
+	listProg := *prog
+	listProg.stdout = bw
+
+	if err := listProg.List(ctx, input, sort, excludes); err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			return fmt.Errorf("failed to close gzip writer: %w", err)
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+
+	listingDone = true
+
+	return nil
+}
+
+// ListCount is the result of a [Program.Count] operation.
+type ListCount struct {
+	Files       int64 // Amount of files (without a trailing slash)
+	Directories int64 // Amount of directories (with a trailing slash)
+}
+
+// Count writes to standard output the amounts of files and directories
+// contained in a given tarball, as they would be listed by [Program.List].
+//
+// The entries are only counted, never sorted or printed, so that even massive
+// archives are counted quickly (from the tarball's index file, if there is one
+// as with [Program.List]). The parameters are those of [Program.List].
+func (prog *Program) Count(ctx context.Context, input string, excludes []string) (*ListCount, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	progressFrom(ctx).setPhase("counting %s", input)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRemotes()
+
+	paths, errs, indexed := prog.indexPathStream(ctx, input, excludes)
+	if !indexed {
+		paths, errs = prog.filePathStream(ctx, input, false, excludes)
+	}
+
+	if prog.filtersPaths() {
+		paths, errs = prog.filterPathStream(ctx, paths, errs)
+	}
+
+	count := &ListCount{}
+
+	for path := range paths {
+		if strings.HasSuffix(path, "/") {
+			count.Directories++
+		} else {
+			count.Files++
+		}
+	}
+
+	for err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failure during counting: %w", err)
+		}
+	}
+
+	fmt.Fprintf(prog.stdout, "files: %d\n", count.Files)
+	fmt.Fprintf(prog.stdout, "directories: %d\n", count.Directories)
+
+	return count, nil
+}
+
+// filterPathStream returns a stream of only the paths matching any of the
+// [ProgramConfig.Matches] (if there are any) and the [ProgramConfig.OnlyType],
+// within the [ProgramConfig.Subdir] (if there is one), passing through any errors of the given stream. The stream can also be one
+// of records (see [recordPath]), which are then matched by their paths.
+func (prog *Program) filterPathStream(ctx context.Context, input <-chan string, inputErrs <-chan error) (<-chan string, <-chan error) {
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	matches := prog.foldExcludes(prog.config.Matches)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		for record := range input {
+			p := recordPath(record)
+			isDir := strings.HasSuffix(p, "/")
+
+			if (prog.config.OnlyType == EntryTypeFile && isDir) || (prog.config.OnlyType == EntryTypeDir && !isDir) {
+				continue
+			}
+
+			if !prog.inSubdir(p) {
+				continue
+			}
+
+			if len(matches) > 0 {
+				folded := p
+				if prog.config.IgnoreCase {
+					folded = strings.ToLower(p)
+				}
+
+				matched, err := isExcluded(folded, isDir, matches)
+				if err != nil {
+					errs <- fmt.Errorf("failed to check for match: %w", err)
+
+					return
+				}
+
+				if !matched {
+					continue
+				}
+			}
+
+			select {
+			case paths <- record:
+			case <-ctx.Done():
+				errs <- fmt.Errorf("failed to stream filtered paths: %w", ctx.Err())
+
+				return
+			}
+		}
+
+		for err := range inputErrs {
+			if err != nil {
+				errs <- err
+
+				return
+			}
+		}
+	}()
+
+	return paths, errs
+}
+
+// entryRecordStream returns a stream of records (see [recordPath]) of the given
+// entries, each being the entry's path and its line in the [ProgramConfig.ListFormat]
+// (a JSON object for JSON Lines, an mtree entry, or the columns of a long listing),
+// passing through any errors of the given stream.
+func (prog *Program) entryRecordStream(ctx context.Context, input <-chan archiveEntry, inputErrs <-chan error) (<-chan string, <-chan error) {
+	records := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(records)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		for entry := range input {
+			var line string
+
+			switch prog.config.ListFormat {
+			case ListMtree:
+				line = formatMtreeEntry(entry)
+			case ListLong:
+				line = formatLongEntry(entry)
+			case ListText, ListJSONL:
+				object, err := json.Marshal(entry)
+				if err != nil {
+					errs <- fmt.Errorf("failed to encode entry %q: %w", entry.Path, err)
+
+					return
+				}
+				line = string(object)
+			}
+
+			select {
+			case records <- entry.Path + "\x00" + line:
+			case <-ctx.Done():
+				errs <- fmt.Errorf("failed to stream entries: %w", ctx.Err())
+
+				return
+			}
+		}
+
+		for err := range inputErrs {
+			if err != nil {
+				errs <- err
+
+				return
+			}
+		}
+	}()
+
+	return records, errs
+}
+
+// pathEntryStream returns a stream of the entries of the given paths, for
+// sources without any recorded metadata (such as lists of paths), of which
+// the types are derived from the paths (with directories carrying a trailing
+// slash), passing through any errors of the given stream.
+func pathEntryStream(ctx context.Context, input <-chan string, inputErrs <-chan error) (<-chan archiveEntry, <-chan error) {
+	entries := make(chan archiveEntry, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(entries)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		for p := range input {
+			entry := archiveEntry{Path: p, Type: manifestTypeFile}
+			if strings.HasSuffix(p, "/") {
+				entry.Type = manifestTypeDir
+			}
+
+			select {
+			case entries <- entry:
+			case <-ctx.Done():
+				errs <- fmt.Errorf("failed to stream entries: %w", ctx.Err())
+
+				return
+			}
+		}
+
+		for err := range inputErrs {
+			if err != nil {
+				errs <- err
+
+				return
+			}
+		}
+	}()
+
+	return entries, errs
+}
+
+// longTimeLayout is the layout of the modification times of a long listing.
+const longTimeLayout = "2006-01-02 15:04:05"
+
+// formatLongEntry returns the columns of an entry in a long listing (without
+// its path), being its type and permission bits (as "ls -l" prints them), its
+// size and its modification time (in UTC). Any metadata not recorded for the
+// entry (such as for lists of paths) is printed as "?" (for the permission
+// bits) or "-" (for the size and modification time) instead.
+func formatLongEntry(entry archiveEntry) string {
+	mode := "?????????"
+	if entry.Mode != nil {
+		mode = fs.FileMode(*entry.Mode).Perm().String()[1:]
+	}
+
+	size := "-"
+	if entry.Size != nil {
+		size = strconv.FormatInt(*entry.Size, 10)
+	}
+
+	modTime := "-"
+	if entry.ModTime != nil {
+		modTime = entry.ModTime.UTC().Format(longTimeLayout)
+	}
+
+	return fmt.Sprintf("%c%s %12s %-19s", longTypeChar(entry), mode, size, modTime)
+}
+
+// longTypeChar returns the character of the type of an entry in a long listing,
+// as "ls -l" prints it, from its type flag (or its type, if none is recorded).
+func longTypeChar(entry archiveEntry) byte {
+	switch entry.TypeFlag {
+	case tar.TypeDir:
+		return 'd'
+	case tar.TypeSymlink:
+		return 'l'
+	case tar.TypeLink:
+		return 'h'
+	case tar.TypeChar:
+		return 'c'
+	case tar.TypeBlock:
+		return 'b'
+	case tar.TypeFifo:
+		return 'p'
+	}
+
+	if entry.Type == manifestTypeDir {
+		return 'd'
+	}
+
+	return '-'
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"io"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
@@ -19,7 +24,7 @@ func Test_Program_List_Sorted_Success(t *testing.T) {
 
 	var stdoutBuf bytes.Buffer
 
-	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil)
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
 	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, nil))
 
 	paths := strings.Split(strings.TrimSpace(stdoutBuf.String()), "\n")
@@ -34,7 +39,7 @@ func Test_Program_List_Sorted_Excludes_Success(t *testing.T) {
 
 	var stdoutBuf bytes.Buffer
 
-	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil)
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
 	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, []string{"y.txt"}))
 
 	paths := strings.Split(strings.TrimSpace(stdoutBuf.String()), "\n")
@@ -49,7 +54,7 @@ func Test_Program_List_Unsorted_Success(t *testing.T) {
 
 	var stdoutBuf bytes.Buffer
 
-	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil)
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
 	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", false, nil))
 
 	paths := strings.Split(strings.TrimSpace(stdoutBuf.String()), "\n")
@@ -64,7 +69,7 @@ func Test_Program_List_Unsorted_Excludes_Success(t *testing.T) {
 
 	var stdoutBuf bytes.Buffer
 
-	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil)
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
 	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", false, []string{"y.txt"}))
 
 	paths := strings.Split(strings.TrimSpace(stdoutBuf.String()), "\n")
@@ -82,6 +87,491 @@ func Test_Program_List_CtxCancel_Error(t *testing.T) {
 
 	var stdoutBuf, stderrBuf bytes.Buffer
 
-	prog := NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil)
+	prog := NewProgram(fs, &stdoutBuf, &stderrBuf, nil, nil, nil)
 	require.ErrorIs(t, prog.List(ctx, "/archive.tar.gz", false, nil), context.Canceled)
 }
+
+// Expectation: Paths containing newlines should be printed NUL-delimited with --print0.
+func Test_Program_List_Print0_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a\nb.txt", "c.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Print0: true})
+	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, nil))
+
+	require.Equal(t, "a\nb.txt\x00c.txt\x00", stdoutBuf.String())
+}
+
+// Expectation: Only the paths matching any of the match patterns should be listed.
+func Test_Program_List_Matches_Table(t *testing.T) {
+	tests := []struct {
+		name       string
+		matches    []string
+		ignoreCase bool
+		excludes   []string
+		want       []string
+	}{
+		{"Subtree", []string{"Movies/**"}, false, nil, []string{"Movies/", "Movies/a.mkv", "Movies/b.TXT"}},
+		{"Extension", []string{"**/*.mkv"}, false, nil, []string{"Movies/a.mkv", "TV/e01.mkv"}},
+		{"Multiple patterns", []string{"**/*.mkv", "*.txt"}, false, nil, []string{"Movies/a.mkv", "TV/e01.mkv", "z.txt"}},
+		{"Ignoring case", []string{"**/*.txt"}, true, nil, []string{"Movies/b.TXT", "z.txt"}},
+		{"With excludes", []string{"**/*.mkv"}, false, []string{"TV/**"}, []string{"Movies/a.mkv"}},
+		{"No matches", []string{"**/*.iso"}, false, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{
+				"Movies/", "Movies/a.mkv", "Movies/b.TXT", "TV/", "TV/e01.mkv", "z.txt",
+			}), 0o644))
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Matches: tt.matches, IgnoreCase: tt.ignoreCase})
+			require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, tt.excludes))
+
+			var paths []string
+			if out := strings.TrimSpace(stdoutBuf.String()); out != "" {
+				paths = strings.Split(out, "\n")
+			}
+			require.Equal(t, tt.want, paths)
+		})
+	}
+}
+
+// Expectation: An invalid match pattern should raise the appropriate error.
+func Test_Program_List_InvalidMatch_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Matches: []string{"[a-"}})
+	err := prog.List(t.Context(), "/archive.tar.gz", true, nil)
+	require.ErrorContains(t, err, "failed to check for match")
+}
+
+// Expectation: Only the entries of the given type should be listed.
+func Test_Program_List_OnlyType_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		onlyType EntryType
+		matches  []string
+		want     []string
+	}{
+		{"Any", EntryTypeAny, nil, []string{"a.txt", "dir/", "dir/b.txt", "dir/sub/"}},
+		{"Files", EntryTypeFile, nil, []string{"a.txt", "dir/b.txt"}},
+		{"Directories", EntryTypeDir, nil, []string{"dir/", "dir/sub/"}},
+		{"Directories with matches", EntryTypeDir, []string{"dir/*"}, []string{"dir/sub/"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt", "dir/", "dir/b.txt", "dir/sub/"}), 0o644))
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{OnlyType: tt.onlyType, Matches: tt.matches})
+			require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, nil))
+
+			paths := strings.Split(strings.TrimSpace(stdoutBuf.String()), "\n")
+			require.Equal(t, tt.want, paths)
+		})
+	}
+}
+
+// Expectation: The entry types should be parsed from their names.
+func Test_parseEntryType_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    EntryType
+		wantErr bool
+	}{
+		{"", EntryTypeAny, false},
+		{"f", EntryTypeFile, false},
+		{"file", EntryTypeFile, false},
+		{"D", EntryTypeDir, false},
+		{"l", EntryTypeAny, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEntryType(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidEntryType)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The entries should be listed in the given sort order.
+func Test_Program_List_SortBy_Table(t *testing.T) {
+	tests := []struct {
+		name   string
+		sortBy SortOrder
+		want   []string
+	}{
+		{"Name", SortByName, []string{"a/", "a/file10", "a/file2", "b", "file1"}},
+		{"Reverse", SortByReverse, []string{"file1", "b", "a/file2", "a/file10", "a/"}},
+		{"Depth", SortByDepth, []string{"a/", "b", "file1", "a/file10", "a/file2"}},
+		{"Version", SortByVersion, []string{"a/", "a/file2", "a/file10", "b", "file1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"b", "a/", "a/file10", "a/file2", "file1"}), 0o644))
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{SortBy: tt.sortBy})
+			require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, nil))
+
+			paths := strings.Split(strings.TrimSpace(stdoutBuf.String()), "\n")
+			require.Equal(t, tt.want, paths)
+		})
+	}
+}
+
+// Expectation: The paths should be compared naturally, with numbers compared numerically.
+func Test_compareVersion_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{"Equal", "file1", "file1", 0},
+		{"Smaller number", "file2", "file10", -1},
+		{"Larger number", "file10", "file2", 1},
+		{"Leading zeros", "file01", "file1", -1},
+		{"Text before number", "v1.2.10", "v1.10.1", -1},
+		{"Prefix", "file", "file1", -1},
+		{"Text only", "abc", "abd", -1},
+		{"Multiple numbers", "s01e10", "s01e9", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareVersion(tt.a, tt.b)
+
+			switch {
+			case tt.want < 0:
+				require.Negative(t, got)
+			case tt.want > 0:
+				require.Positive(t, got)
+			default:
+				require.Zero(t, got)
+			}
+		})
+	}
+}
+
+// Expectation: The sort orders should be parsed from their names.
+func Test_parseSortOrder_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    SortOrder
+		wantErr bool
+	}{
+		{"", SortByName, false},
+		{"name", SortByName, false},
+		{"reverse", SortByReverse, false},
+		{"Depth", SortByDepth, false},
+		{"version", SortByVersion, false},
+		{"size", SortByName, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSortOrder(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidSortOrder)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The list formats should be parsed from their names.
+func Test_parseListFormat_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ListFormat
+		wantErr bool
+	}{
+		{"", ListText, false},
+		{"text", ListText, false},
+		{"JSONL", ListJSONL, false},
+		{"mtree", ListMtree, false},
+		{"long", ListLong, false},
+		{"json", ListText, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseListFormat(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidListFormat)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The entries should be listed as sorted JSON Lines, also filtered by their type.
+func Test_Program_List_JSONL_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"z.txt", "dir/", "dir/a.txt", "y.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{ListFormat: ListJSONL, Matches: []string{"dir/**", "z.txt"}})
+	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, nil))
+
+	require.Equal(t, `{"path":"dir/","type":"dir"}
+{"path":"dir/a.txt","type":"file","size":0}
+{"path":"z.txt","type":"file","size":0}
+`, stdoutBuf.String())
+}
+
+// Expectation: The metadata recorded in the archive should be listed as JSON Lines.
+func Test_Program_List_JSONLMetadata_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "movie.mkv", Typeflag: tar.TypeReg, Size: 5, ModTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Mode: 0o644}))
+	_, err := tw.Write([]byte("movie"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", buf.Bytes(), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{ListFormat: ListJSONL})
+	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", false, nil))
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(stdoutBuf.Bytes(), &entry))
+
+	require.Equal(t, map[string]any{"path": "movie.mkv", "type": "file", "size": float64(5), "mtime": "2024-05-01T12:00:00Z"}, entry)
+}
+
+// Expectation: The metadata recorded in the tar headers should be listed in columns before the paths.
+func Test_Program_List_Long_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "dir/link", Typeflag: tar.TypeSymlink, Linkname: "movie.mkv", Mode: 0o777}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "dir/movie.mkv", Typeflag: tar.TypeReg, Size: 5, ModTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Mode: 0o640}))
+	_, err := tw.Write([]byte("movie"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", buf.Bytes(), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{ListFormat: ListLong})
+	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, nil))
+
+	require.Equal(t, `drwxr-xr-x            - -                    dir/
+lrwxrwxrwx            0 -                    dir/link
+-rw-r-----            5 2024-05-01 12:00:00  dir/movie.mkv
+`, stdoutBuf.String())
+}
+
+// Expectation: The metadata not recorded for the entries (as of lists of paths) should be listed as placeholders.
+func Test_formatLongEntry_Unrecorded_Success(t *testing.T) {
+	require.Equal(t, "d?????????            - -                  ", formatLongEntry(archiveEntry{Path: "dir/", Type: manifestTypeDir}))
+	require.Equal(t, "-?????????            - -                  ", formatLongEntry(archiveEntry{Path: "a.txt", Type: manifestTypeFile}))
+}
+
+// Expectation: The amounts of files and directories should be printed without the entries.
+func Test_Program_Count_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"z.txt", "a.txt", "dir/", "dir/b.txt", "dir/sub/"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	count, err := prog.Count(t.Context(), "/archive.tar.gz", []string{"z.txt"})
+	require.NoError(t, err)
+
+	require.Equal(t, &ListCount{Files: 2, Directories: 2}, count)
+	require.Equal(t, "files: 2\ndirectories: 2\n", stdoutBuf.String())
+}
+
+// Expectation: The amounts should only include the entries matching the filters.
+func Test_Program_Count_Filters_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt", "dir/", "dir/b.txt", "dir/sub/"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Matches: []string{"dir/**"}, OnlyType: EntryTypeDir})
+	count, err := prog.Count(t.Context(), "/archive.tar.gz", nil)
+	require.NoError(t, err)
+
+	require.Equal(t, &ListCount{Files: 0, Directories: 2}, count)
+}
+
+// Expectation: A corrupt archive should raise the appropriate error.
+func Test_Program_Count_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", []byte("not a tarball"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	_, err := prog.Count(t.Context(), "/archive.tar.gz", nil)
+	require.ErrorContains(t, err, "failure during counting")
+}
+
+// Expectation: The listing should be written to the (optionally compressed) output file.
+func Test_Program_ListTo_Success(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{"Plain", "/listing.txt"},
+		{"Compressed", "/listing.txt.gz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"z.txt", "a.txt", "dir/"}), 0o644))
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+			require.NoError(t, prog.ListTo(t.Context(), "/archive.tar.gz", true, nil, tt.output))
+			require.Empty(t, stdoutBuf.String())
+
+			f, err := fs.Open(tt.output)
+			require.NoError(t, err)
+			defer f.Close()
+
+			var r io.Reader = f
+			if strings.HasSuffix(tt.output, ".gz") {
+				gzr, err := gzip.NewReader(f)
+				require.NoError(t, err)
+				r = gzr
+			}
+
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, "a.txt\ndir/\nz.txt\n", string(data))
+		})
+	}
+}
+
+// Expectation: An existing output file should be protected and a failed output file removed.
+func Test_Program_ListTo_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/listing.txt", []byte("previous"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.ListTo(t.Context(), "/archive.tar.gz", true, nil, "/listing.txt")
+	require.ErrorIs(t, err, ErrOutputExists)
+
+	err = prog.ListTo(t.Context(), "/missing.tar.gz", true, nil, "/other.txt")
+	require.ErrorContains(t, err, "failure during listing")
+
+	_, err = fs.Stat("/other.txt")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: The subdirectories should be normalized to relative paths with a trailing slash, or rejected.
+func Test_parseSubdir_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+		ok   bool
+	}{
+		{"Empty", "", "", true},
+		{"Root", "/", "", true},
+		{"Plain", "media/movies", "media/movies/", true},
+		{"Slashes", "/media/movies/", "media/movies/", true},
+		{"Unclean", "media/./x/../movies", "media/movies/", true},
+		{"Parent", "../media", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSubdir(tt.arg)
+			if !tt.ok {
+				require.ErrorIs(t, err, errInvalidSubdir)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: Only the entries within the subdirectory should be listed (and counted), in any format.
+func Test_Program_List_Subdir_Table(t *testing.T) {
+	tests := []struct {
+		name   string
+		sort   bool
+		format ListFormat
+		want   string
+	}{
+		{"Sorted", true, ListText, "media/movies/\nmedia/movies/a.mkv\nmedia/movies/b/\n"},
+		{"Unsorted", false, ListText, "media/movies/\nmedia/movies/b/\nmedia/movies/a.mkv\n"},
+		{"JSONL", true, ListJSONL, `{"path":"media/movies/","type":"dir"}` + "\n" + `{"path":"media/movies/a.mkv","type":"file","size":0}` + "\n" + `{"path":"media/movies/b/","type":"dir"}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"media/", "media/movies/", "media/movies/b/", "media/movies/a.mkv", "media/movies-old/", "media/x.txt"}), 0o644))
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Subdir: "media/movies/", ListFormat: tt.format})
+			require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", tt.sort, nil))
+			require.Equal(t, tt.want, stdoutBuf.String())
+
+			count, err := prog.Count(t.Context(), "/archive.tar.gz", nil)
+			require.NoError(t, err)
+			require.Equal(t, &ListCount{Files: 1, Directories: 2}, count)
+		})
+	}
+}
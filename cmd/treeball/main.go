@@ -9,18 +9,44 @@ the overhead of preserving file contents.
 The program works efficiently even with millions of files, intelligently off-loading data to
 disk when system resources would otherwise become too constrained. It supports these commands:
 
-	create - build a tarball from a given directory tree
-	diff   - generate a diff tarball containing only the changes between two sources
-	list   - produce a sorted or unsorted listing of all the contents of a given tarball
+	create    - build a tarball from a given directory tree
+	diff      - generate a diff tarball containing only the changes between two sources
+	check     - compare a tarball against a live directory tree (without any output file)
+	list      - produce a sorted or unsorted listing of all the contents of a given tarball
+	recreate  - regenerate a tarball from an (externally edited) manifest of paths
+	normalize - rewrite a (foreign) tarball into a canonical one, as if created by treeball
+	convert   - strip the contents of a (content) tarball or zip archive into a treeball
+	verify    - check a given tarball for corruption, duplicate entries, and sorted order
+	show      - display the annotations of a given tarball (such as its creation and comment)
+	hash      - compute a fingerprint of the paths of a source (for fast equality checks)
+	bench     - measure the throughput of the operations on a synthetic tree (for sizing)
+	serve     - serve a REST API for listing, searching, and diffing archives over HTTP
+	watch     - continuously snapshot a directory tree upon changes (into tarballs)
+	snapshot  - create a timestamped tarball of a directory tree, pruning older ones
+	patterns  - debug exclude patterns, reporting which of them match the given paths
+
+The optional features compiled into the program can be listed with the 'features' command.
+Scripts for shell completion are generated with the 'completion' command (e.g. for bash).
 
 All commands print their primary results (such as file paths or differences) to standard output
 (stdout). Any encountered errors and operational messages are printed to standard error (stderr).
+With --print0 (-0), any printed paths are terminated by NUL bytes instead of newlines, so that
+paths containing newlines (or other hostile characters) can be piped safely into 'xargs -0'.
+A snapshot of the progress of a running command (its current phase, the entries read, and the
+data spilled to disk) is printed to stderr upon SIGUSR2 (or SIGINFO, i.e. Ctrl+T, on BSD/macOS).
+With --metrics-listen (e.g. :9090), these are also served as Prometheus metrics (at /metrics).
+With --profile (fast, balanced, small), the compression, block, and sorting options are preset
+together (for speed or small archives), with any of these options given explicitly taking precedence.
 
 Exit Codes:
 
 	0 - Success
-	1 - Differences found (only for 'diff')
+	1 - Differences found (only for 'diff' and 'check')
 	2 - General failure (invalid input, I/O errors, etc.)
+	3 - Completed with warnings (e.g. entries skipped due to --skip-errors)
+	4 - Archive is corrupt or truncated (only for 'verify')
+	5 - Archive contains duplicate entries (only for 'verify')
+	6 - Archive is not in sorted order (only for 'verify')
 */
 package main
 
@@ -34,9 +60,13 @@ import (
 	"os/signal"
 	"runtime"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/desertwitch/treeball/internal/mktree"
 	"github.com/lanrat/extsort"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
@@ -52,6 +82,16 @@ const (
 	exitCodeSuccess    int = 0
 	exitCodeDiffsFound int = 1
 	exitCodeFailure    int = 2
+	exitCodePartial    int = 3
+	exitCodeCorrupt    int = 4
+	exitCodeDuplicates int = 5
+	exitCodeUnsorted   int = 6
+
+	defaultBenchFiles int = 100_000
+
+	defaultServeListen string = ":8080"
+
+	defaultWatchInterval time.Duration = time.Minute
 
 	exitTimeout time.Duration = 10 * time.Second
 )
@@ -64,7 +104,7 @@ var (
 	gzipConfigDefault = GzipConfig{
 		BlockSize:        1 << 20,               // Approximate size of blocks (pgzip operations)
 		BlockCount:       runtime.GOMAXPROCS(0), // Amount of blocks processing in parallel (pgzip operations)
-		CompressionLevel: gzip.BestCompression,  // Target level for compression (0: none to 9: highest)
+		CompressionLevel: gzip.BestCompression,  // Target level for compression (0: none, as plain tar to 9: highest)
 	}
 
 	//nolint:mnd
@@ -78,9 +118,39 @@ var (
 
 	// ErrDiffsFound is an exit-code relevant sentinel error.
 	ErrDiffsFound = errors.New("differences were found")
+
+	// ErrOutputExists is returned when an existing output file would be overwritten.
+	ErrOutputExists = errors.New("output file already exists")
+
+	// ErrPartialSuccess is an exit-code relevant sentinel error.
+	// It is the base of all errors indicating completion with warnings.
+	ErrPartialSuccess = errors.New("completed with warnings")
+
+	// ErrEntriesSkipped is an exit-code relevant sentinel error.
+	ErrEntriesSkipped = fmt.Errorf("%w: entries were skipped", ErrPartialSuccess)
+
+	// ErrArchiveCorrupt is an exit-code relevant sentinel error.
+	ErrArchiveCorrupt = errors.New("archive is corrupt")
+
+	// ErrSignatureInvalid is an exit-code relevant sentinel error.
+	// It is returned when a signature does not match an archive (as if corrupt),
+	// or was not made by any of the trusted keys.
+	ErrSignatureInvalid = fmt.Errorf("%w: signature is invalid", ErrArchiveCorrupt)
+
+	// ErrArchiveDuplicates is an exit-code relevant sentinel error.
+	ErrArchiveDuplicates = errors.New("archive contains duplicate entries")
+
+	// ErrArchiveUnsorted is an exit-code relevant sentinel error.
+	ErrArchiveUnsorted = errors.New("archive is not in sorted order")
 )
 
 // Program is the primary structure of the application.
+//
+// A Program holds no mutable state across its operations, so one configured
+// instance is safe for concurrent use by multiple goroutines (e.g. to serve
+// many simultaneous requests), provided that its stdout and stderr writers
+// are themselves safe for concurrent use. The configurations are copied by
+// [NewProgram], so later changes to the originals do not affect the Program.
 type Program struct {
 	fs       afero.Fs
 	fsWalker Walker
@@ -90,12 +160,14 @@ type Program struct {
 
 	gzipConfig    *GzipConfig
 	extSortConfig *extsort.Config
+	config        *ProgramConfig
+
+	includes Matcher // Matcher of the [ProgramConfig.Includes] (nil: none)
 }
 
 // NewProgram returns a pointer to a new [Program].
-func NewProgram(fs afero.Fs, stdout io.Writer, stderr io.Writer, gzipConfig *GzipConfig, extsortConfig *extsort.Config) *Program {
-	var walker Walker
-
+// Any nil configurations are substituted with their respective defaults.
+func NewProgram(fs afero.Fs, stdout io.Writer, stderr io.Writer, gzipConfig *GzipConfig, extsortConfig *extsort.Config, config *ProgramConfig) *Program {
 	if fs == nil {
 		fs = afero.NewOsFs()
 	}
@@ -108,50 +180,187 @@ func NewProgram(fs afero.Fs, stdout io.Writer, stderr io.Writer, gzipConfig *Gzi
 		stderr = os.Stderr
 	}
 
-	if gzipConfig == nil {
-		cfg := gzipConfigDefault
-		gzipConfig = &cfg
+	gzipCfg := gzipConfigDefault
+	if gzipConfig != nil {
+		gzipCfg = *gzipConfig
 	}
 
-	if extsortConfig == nil {
-		cfg := extSortConfigDefault
-		extsortConfig = &cfg
+	extsortCfg := extSortConfigDefault
+	if extsortConfig != nil {
+		extsortCfg = *extsortConfig
 	}
 
-	if _, ok := fs.(*afero.OsFs); ok {
-		walker = OSWalker{}
-	} else {
-		walker = AferoWalker{FS: fs}
+	cfg := ProgramConfig{}
+	if config != nil {
+		cfg = *config
+		cfg.ExcludeRegexes = slices.Clone(config.ExcludeRegexes)
+		cfg.Matches = slices.Clone(config.Matches)
+		cfg.Includes = slices.Clone(config.Includes)
+		cfg.Owners = slices.Clone(config.Owners)
+		cfg.Groups = slices.Clone(config.Groups)
+		cfg.ExcludesOld = slices.Clone(config.ExcludesOld)
+		cfg.ExcludesNew = slices.Clone(config.ExcludesNew)
+		cfg.MapsOld = slices.Clone(config.MapsOld)
+		cfg.MapsNew = slices.Clone(config.MapsNew)
+
+		cfg.Transforms = slices.Clone(config.Transforms)
+		for i, transform := range cfg.Transforms {
+			t := *transform
+			cfg.Transforms[i] = &t
+		}
+
+		if config.EntryOwner != nil {
+			owner := *config.EntryOwner
+			cfg.EntryOwner = &owner
+		}
+		if config.EntryGroup != nil {
+			group := *config.EntryGroup
+			cfg.EntryGroup = &group
+		}
+
+		// The includes are folded here (unlike the excludes, per operation), as
+		// these are part of the configuration, being matched along any excludes.
+		if cfg.IgnoreCase {
+			for i, include := range cfg.Includes {
+				cfg.Includes[i] = strings.ToLower(include)
+			}
+		}
+	}
+	config = &cfg
+
+	var includes Matcher
+	if len(config.Includes) > 0 {
+		includes = newMatcher(config.PatternSyntax, config.Includes)
 	}
 
 	return &Program{
 		fs:            fs,
-		fsWalker:      walker,
+		fsWalker:      newWalker(fs, config),
 		stdout:        stdout,
 		stderr:        stderr,
-		gzipConfig:    gzipConfig,
-		extSortConfig: extsortConfig,
+		gzipConfig:    &gzipCfg,
+		extSortConfig: &extsortCfg,
+		config:        config,
+		includes:      includes,
+	}
+}
+
+// newWalker returns the [Walker] for a filesystem as per the [ProgramConfig].
+func newWalker(fs afero.Fs, config *ProgramConfig) Walker {
+	var walker Walker
+
+	// Remote sources are never walked with the native walk (nor following symbolic links).
+	if rfs, ok := fs.(*remoteFs); ok {
+		var remote Walker = AferoWalker{FS: rfs}
+		if config.WalkWorkers > 1 || walksSorted(config) {
+			remote = ParallelWalker{FS: rfs, Workers: config.WalkWorkers, PathOrder: walksSorted(config)}
+		}
+
+		return remoteWalker{local: newWalker(rfs.Fs, config), remote: remote}
+	}
+
+	if _, ok := fs.(*afero.OsFs); ok {
+		walker = OSWalker{FollowSymlinks: config.FollowSymlinks}
+	} else {
+		walker = AferoWalker{FS: fs}
+	}
+
+	// Symbolic links are only followed by the serial walk (with loop detection).
+	if (config.WalkWorkers > 1 && !config.FollowSymlinks) || walksSorted(config) {
+		walker = ParallelWalker{FS: fs, Workers: config.WalkWorkers, PathOrder: walksSorted(config)}
 	}
+
+	return walker
 }
 
 func newRootCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var metricsListen string
+	var profile string
+	var maxOpenFiles int
+
+	profiling := &Profiling{}
+
+	// The operations report their progress into the context (for the metrics).
+	progress := progressFrom(ctx)
+	if progress == nil {
+		ctx, progress = withProgress(ctx)
+	}
+
 	rootCmd := &cobra.Command{
-		Use:               "treeball",
-		Short:             rootHelpShort,
-		Long:              rootHelpLong,
-		Version:           Version,
-		SilenceErrors:     true,
-		SilenceUsage:      true,
-		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+		Use:           "treeball",
+		Short:         rootHelpShort,
+		Long:          rootHelpLong,
+		Version:       buildInfo().String(),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if profile != "" {
+				p, err := parseProfile(profile)
+				if err != nil {
+					return fmt.Errorf("failed to evaluate profile arguments: %w", err)
+				}
+
+				if err := applyProfile(cmd, p); err != nil {
+					return err
+				}
+			}
+
+			if err := applyOpenFilesLimit(cmd, maxOpenFiles); err != nil {
+				return err
+			}
+
+			if metricsListen != "" {
+				addr, stop, err := startMetrics(metricsListen, progress)
+				if err != nil {
+					return fmt.Errorf("failed to serve metrics: %w", err)
+				}
+				context.AfterFunc(ctx, stop)
+
+				if quiet, _ := cmd.Flags().GetBool("quiet"); !quiet {
+					fmt.Fprintf(cmd.ErrOrStderr(), "serving metrics at http://%s/metrics\n", addr)
+				}
+			}
+
+			// The profiling is started last, as it is only stopped after a run.
+			return profiling.Start(fs)
+		},
 	}
 	rootCmd.SetOut(stdout)
 	rootCmd.SetErr(stderr)
 
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "preset of the performance options (fast, balanced, small); explicit options take precedence")
+	rootCmd.PersistentFlags().IntVar(&maxOpenFiles, "max-open-files", 0, "files to hold open at once at most, governing --walkers and --workers (0: as allowed by 'ulimit -n')")
+	rootCmd.PersistentFlags().StringVar(&metricsListen, "metrics-listen", "", "address to serve Prometheus metrics at (e.g. :9090)")
+	rootCmd.PersistentFlags().StringVar(&profiling.CPUProfile, "cpuprofile", "", "write a CPU profile to this file (for 'go tool pprof')")
+	rootCmd.PersistentFlags().StringVar(&profiling.MemProfile, "memprofile", "", "write a memory profile to this file upon completion (for 'go tool pprof')")
+	rootCmd.PersistentFlags().StringVar(&profiling.Trace, "trace", "", "write an execution trace to this file (for 'go tool trace')")
+	addGlobalFlags(rootCmd)
+
 	createCmd := newCreateCmd(ctx, fs, stdout, stderr)
 	diffCmd := newDiffCmd(ctx, fs, stdout, stderr)
+	checkCmd := newCheckCmd(ctx, fs, stdout, stderr)
 	listCmd := newListCmd(ctx, fs, stdout, stderr)
+	recreateCmd := newRecreateCmd(ctx, fs, stdout, stderr)
+	normalizeCmd := newNormalizeCmd(ctx, fs, stdout, stderr)
+	convertCmd := newConvertCmd(ctx, fs, stdout, stderr)
+	indexCmd := newIndexCmd(ctx, fs, stdout, stderr)
+	exportCmd := newExportCmd(ctx, fs, stdout, stderr)
+	restoreCmd := newRestoreCmd(ctx, fs, stdout, stderr)
+	verifyCmd := newVerifyCmd(ctx, fs, stdout, stderr)
+	showCmd := newShowCmd(ctx, fs, stdout, stderr)
+	hashCmd := newHashCmd(ctx, fs, stdout, stderr)
+	benchCmd := newBenchCmd(ctx, fs, stdout, stderr)
+	serveCmd := newServeCmd(ctx, fs, stdout, stderr)
+	watchCmd := newWatchCmd(ctx, fs, stdout, stderr)
+	snapshotCmd := newSnapshotCmd(ctx, fs, stdout, stderr)
+	mktreeCmd := newMktreeCmd(ctx, fs)
+	featuresCmd := newFeaturesCmd()
+	versionCmd := newVersionCmd()
+	patternsCmd := newPatternsCmd(fs, stdout, stderr)
 
-	rootCmd.AddCommand(createCmd, diffCmd, listCmd)
+	rootCmd.AddCommand(createCmd, diffCmd, checkCmd, listCmd, recreateCmd, normalizeCmd, convertCmd, indexCmd, exportCmd, restoreCmd, verifyCmd, showCmd, hashCmd, benchCmd, serveCmd, watchCmd, snapshotCmd, mktreeCmd, featuresCmd, versionCmd, patternsCmd)
+	registerFlagCompletions(rootCmd)
+	stopAfterRun(rootCmd, profiling.Stop)
 
 	return rootCmd
 }
@@ -159,124 +368,1667 @@ func newRootCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Wr
 func newCreateCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
 	var excludes []string
 	var excludesFile string
+	var includes []string
+	var includesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var newerThan string
+	var olderThan string
+	var owners []string
+	var groups []string
+	var estimate bool
+	var tarFormat string
+	var gzipName, gzipMtime, gzipComment string
+	var checksum string
+	var splitSize string
+	var transforms []string
+	var mtime string
+	var modeFile string
+	var modeDir string
+	var entryOwner string
+	var entryGroup string
 
 	compressorConfig := gzipConfigDefault
+	programConfig := ProgramConfig{}
 
 	createCmd := &cobra.Command{
 		Use:     "create <root-folder> <output.tar.gz>",
+		Aliases: []string{"mk"},
 		Short:   createHelpShort,
 		Long:    createHelpLong,
 		Example: createExample,
-		Args:    cobra.ExactArgs(2), //nolint:mnd
-		RunE: func(_ *cobra.Command, args []string) error {
-			prog := NewProgram(fs, stdout, stderr, &compressorConfig, nil)
+		Args: func(cmd *cobra.Command, args []string) error {
+			if estimate {
+				return cobra.RangeArgs(1, 2)(cmd, args) //nolint:mnd
+			}
+
+			return cobra.ExactArgs(2)(cmd, args) //nolint:mnd
+		},
+		ValidArgsFunction: completePositional(completeDirs, completeArchives, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, nil, &programConfig); err != nil {
+				return err
+			}
+
+			if err := checkMaxDepth(programConfig.MaxDepth); err != nil {
+				return fmt.Errorf("failed to evaluate max-depth arguments: %w", err)
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			if programConfig.NewerThan, programConfig.OlderThan, err = parseAgeFilters(newerThan, olderThan); err != nil {
+				return fmt.Errorf("failed to evaluate age arguments: %w", err)
+			}
+
+			if programConfig.Owners, programConfig.Groups, err = parseOwnerFilters(owners, groups); err != nil {
+				return fmt.Errorf("failed to evaluate owner arguments: %w", err)
+			}
+
+			format, err := parseTarFormat(tarFormat)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate format arguments: %w", err)
+			}
+			programConfig.TarFormat = format
+
+			algo, err := parseChecksumAlgo(checksum)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate checksum arguments: %w", err)
+			}
+			programConfig.Checksum = algo
+
+			if splitSize != "" {
+				size, err := parseSize(splitSize)
+				if err != nil {
+					return fmt.Errorf("failed to evaluate split arguments: %w", err)
+				}
+				programConfig.SplitSize = size
+			}
+
+			if programConfig.Transforms, err = parseNameTransforms(transforms); err != nil {
+				return fmt.Errorf("failed to evaluate transform arguments: %w", err)
+			}
+
+			if programConfig.ModTime, err = fixedModTime(mtime); err != nil {
+				return fmt.Errorf("failed to evaluate mtime arguments: %w", err)
+			}
+
+			if programConfig.GzipHeader, err = parseGzipHeader(gzipName, gzipMtime, gzipComment, programConfig.ModTime); err != nil {
+				return fmt.Errorf("failed to evaluate gzip arguments: %w", err)
+			}
+
+			if programConfig.FileMode, err = parseEntryMode(modeFile); err != nil {
+				return fmt.Errorf("failed to evaluate mode arguments: %w", err)
+			}
+
+			if programConfig.DirMode, err = parseEntryMode(modeDir); err != nil {
+				return fmt.Errorf("failed to evaluate mode arguments: %w", err)
+			}
+
+			if programConfig.EntryOwner, programConfig.EntryGroup, err = parseEntryOwners(entryOwner, entryGroup); err != nil {
+				return fmt.Errorf("failed to evaluate entry owner arguments: %w", err)
+			}
+
+			if programConfig.Includes, err = mergePatterns(fs, "include", includes, includesFile); err != nil {
+				return fmt.Errorf("failed to evaluate include arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, stdout, stderr, &compressorConfig, nil, &programConfig)
 
 			excl, err := prog.mergeExcludes(excludes, excludesFile)
 			if err != nil {
 				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
 			}
 
+			if estimate {
+				_, err := prog.Estimate(ctx, args[0], excl)
+
+				return err
+			}
+
 			return prog.Create(ctx, args[0], args[1], excl)
 		},
 	}
 
-	createCmd.Flags().StringArrayVar(&excludes, "exclude", nil, "pattern to exclude; can be repeated multiple times")
+	createCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
 	createCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
-	createCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none - 9: highest)")
+	createCmd.Flags().StringArrayVar(&includes, "include", nil, "pattern of files to include (excluding all others); can be repeated multiple times")
+	createCmd.Flags().StringVar(&includesFile, "includes-from", "", "path to a file containing include patterns")
+	createCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	createCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	createCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "match excludes case-insensitively")
+	createCmd.Flags().IntVar(&programConfig.MaxDepth, "max-depth", 0, "maximum depth of paths to consider (0: unlimited)")
+	createCmd.Flags().StringVar(&newerThan, "newer-than", "", "consider only files modified after this age (e.g. 30d, 12h or 2024-01-31)")
+	createCmd.Flags().StringVar(&olderThan, "older-than", "", "consider only files modified before this age (e.g. 30d, 12h or 2024-01-31)")
+	createCmd.Flags().StringArrayVar(&owners, "owner", nil, "consider only files owned by this user (name or uid); can be repeated multiple times")
+	createCmd.Flags().StringArrayVar(&groups, "group", nil, "consider only files owned by this group (name or gid); can be repeated multiple times")
+	createCmd.Flags().BoolVar(&programConfig.PruneEmpty, "prune-empty", false, "leave out directories without any files (such as after exclusions)")
+	createCmd.Flags().StringVar(&modeFile, "mode-file", "", "permission bits of the file entries (octal, e.g. 0644; default: 0666)")
+	createCmd.Flags().StringVar(&modeDir, "mode-dir", "", "permission bits of the directory entries (octal, e.g. 0755; default: 0777)")
+	createCmd.Flags().StringVar(&entryOwner, "entry-owner", "", "owner to record for all entries (NAME, ID or NAME:ID; default: none)")
+	createCmd.Flags().StringVar(&entryGroup, "entry-group", "", "group to record for all entries (NAME, ID or NAME:ID; default: none)")
+	createCmd.Flags().StringVar(&mtime, "mtime", "", "fixed modification time of all entries (e.g. @0 or 2024-01-31; default: $SOURCE_DATE_EPOCH, if set)")
+	createCmd.Flags().StringArrayVar(&transforms, "transform", nil, "rewrite the names of entries with a sed-style expression (s/REGEX/REPLACEMENT/FLAGS); can be repeated multiple times")
+	createCmd.Flags().BoolVar(&programConfig.FollowSymlinks, "follow-symlinks", false, "follow symbolic links to directories in the tree")
+	createCmd.Flags().BoolVar(&programConfig.SpecialFiles, "special-files", false, "write special files (FIFOs, devices) with their types (instead of as regular files)")
+	createCmd.Flags().BoolVar(&programConfig.Xattrs, "xattrs", false, "capture the extended attributes of entries (as PAX records)")
+	createCmd.Flags().BoolVar(&programConfig.ACLs, "acls", false, "capture the POSIX ACLs of entries (as PAX records)")
+	createCmd.Flags().IntVar(&programConfig.WalkWorkers, "walkers", 0, "directories to read concurrently when walking (0: serially)")
+	createCmd.Flags().BoolVar(&programConfig.GitIgnore, "gitignore", false, "apply .gitignore files encountered in the directory tree")
+	createCmd.Flags().BoolVar(&programConfig.DescendArchives, "descend-archives", false, "walk any nested archives (.tar.gz, .tgz, .tar, .zip) as directories of their entries")
+	createCmd.Flags().BoolVar(&programConfig.SkipErrors, "skip-errors", false, "skip unreadable entries (with warning) instead of failing")
+	createCmd.Flags().BoolVarP(&programConfig.Print0, "print0", "0", false, "terminate printed paths with NUL bytes (instead of newlines)")
+	createCmd.Flags().BoolVarP(&programConfig.Force, "force", "f", false, "overwrite the output file if it already exists")
+	createCmd.Flags().BoolVar(&programConfig.Backup, "backup", false, "rename an existing output file aside (to *.bak) first")
+	createCmd.Flags().BoolVar(&programConfig.Fsync, "fsync", false, "sync the output file (and its directory) to its storage before completing")
+	createCmd.Flags().BoolVar(&programConfig.VerifyWrites, "verify-after-write", false, "read back and decode the output file before completing")
+	createCmd.Flags().BoolVar(&programConfig.Annotate, "annotate", false, "record the creation time, hostname, root and version (in a PAX global header)")
+	createCmd.Flags().StringVar(&programConfig.Comment, "comment", "", "comment to record alongside the annotations (implies --annotate)")
+	createCmd.Flags().StringVar(&programConfig.SignKey, "sign-key", "", "private SSH key to write a detached signature (*.sig) with")
+	createCmd.Flags().StringVar(&checksum, "checksum", "none", "algorithm of a checksum file to write alongside (none, sha256, blake3)")
+	createCmd.Flags().StringVar(&splitSize, "split-size", "", "split the output into parts of at most this size (e.g. 4G, as *.000, *.001, ...)")
+	createCmd.Flags().BoolVar(&programConfig.Resume, "resume", false, "continue an interrupted creation from its last checkpoint (as written with --checkpoint-every)")
+	createCmd.Flags().IntVar(&programConfig.CheckpointEvery, "checkpoint-every", 0, "entries between checkpoints for --resume (0: none)")
+	createCmd.Flags().IntVar(&programConfig.MemberEvery, "member-every", 0, "entries between gzip members, each decompressible on its own (0: only at checkpoints)")
+	createCmd.Flags().BoolVar(&programConfig.Rsyncable, "rsyncable", false, "end gzip members at content-defined entries, for efficient transfers of changed tarballs with rsync")
+	createCmd.Flags().BoolVar(&estimate, "estimate", false, "only report the expected entry count and output size")
+	createCmd.Flags().StringVar(&tarFormat, "tar-format", "auto", "format of the tar headers (auto, ustar, pax, gnu)")
+	createCmd.Flags().StringVar(&gzipName, "gzip-name", "", "name of the file recorded in the gzip header")
+	createCmd.Flags().StringVar(&gzipMtime, "gzip-mtime", "", "modification time recorded in the gzip header (now, @SECONDS or a date; default: that of --mtime)")
+	createCmd.Flags().StringVar(&gzipComment, "gzip-comment", "", "comment recorded in the gzip header")
+	createCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none, as plain tar - 9: highest)")
 	createCmd.Flags().IntVar(&compressorConfig.BlockSize, "blocksize", gzipConfigDefault.BlockSize, "block size for compressing")
 	createCmd.Flags().IntVar(&compressorConfig.BlockCount, "blockcount", gzipConfigDefault.BlockCount, "blocks to compress in parallel")
 
+	createCmd.MarkFlagsMutuallyExclusive("split-size", "checkpoint-every")
+	createCmd.MarkFlagsMutuallyExclusive("split-size", "resume")
+
 	return createCmd
 }
 
 func newDiffCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
 	var excludes []string
 	var excludesFile string
+	var includes []string
+	var includesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var newerThan string
+	var olderThan string
+	var owners []string
+	var groups []string
+	var noPager bool
+	var quoting string
+	var onlySide string
+	var tarFormat string
+	var gzipName, gzipMtime, gzipComment string
+	var pairsFile string
+	var noOutput bool
+	var tui bool
+	var summaryOnly bool
+	var stopOnFirst bool
+	var addedPrefix string
+	var removedPrefix string
+	var mapsOld []string
+	var mapsNew []string
+	var checksum string
+	var reportUsage bool
 
 	sorterConfig := extSortConfigDefault
 	compressorConfig := gzipConfigDefault
+	programConfig := ProgramConfig{}
 
 	diffCmd := &cobra.Command{
-		Use:     "diff <old> <new> <diff.tar.gz>",
+		Use:     "diff <old> <new>... [<diff.tar.gz>]",
+		Aliases: []string{"cmp"},
 		Short:   diffHelpShort,
 		Long:    diffHelpLong,
 		Example: diffExample,
-		Args:    cobra.ExactArgs(3), //nolint:mnd
-		RunE: func(_ *cobra.Command, args []string) error {
-			prog := NewProgram(fs, stdout, stderr, &compressorConfig, &sorterConfig)
+		Args: func(cmd *cobra.Command, args []string) error {
+			if pairsFile != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+
+			return cobra.MinimumNArgs(2)(cmd, args) //nolint:mnd
+		},
+		ValidArgsFunction: completeFiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			if err := checkMaxDepth(programConfig.MaxDepth); err != nil {
+				return fmt.Errorf("failed to evaluate max-depth arguments: %w", err)
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			if programConfig.NewerThan, programConfig.OlderThan, err = parseAgeFilters(newerThan, olderThan); err != nil {
+				return fmt.Errorf("failed to evaluate age arguments: %w", err)
+			}
+
+			if programConfig.Owners, programConfig.Groups, err = parseOwnerFilters(owners, groups); err != nil {
+				return fmt.Errorf("failed to evaluate owner arguments: %w", err)
+			}
+
+			side, err := parseDiffSide(onlySide)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate only arguments: %w", err)
+			}
+			programConfig.OnlySide = side
+
+			if stopOnFirst {
+				programConfig.MaxDiffs = 1
+			}
+
+			if programConfig.AddedPrefix, programConfig.RemovedPrefix, err = parseDiffPrefixes(addedPrefix, removedPrefix); err != nil {
+				return fmt.Errorf("failed to evaluate prefix arguments: %w", err)
+			}
+
+			if programConfig.MapsOld, err = parsePathMappings(mapsOld); err != nil {
+				return fmt.Errorf("failed to evaluate map arguments: %w", err)
+			}
+
+			if programConfig.MapsNew, err = parsePathMappings(mapsNew); err != nil {
+				return fmt.Errorf("failed to evaluate map arguments: %w", err)
+			}
+
+			format, err := parseTarFormat(tarFormat)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate format arguments: %w", err)
+			}
+			programConfig.TarFormat = format
+
+			if programConfig.GzipHeader, err = parseGzipHeader(gzipName, gzipMtime, gzipComment, programConfig.ModTime); err != nil {
+				return fmt.Errorf("failed to evaluate gzip arguments: %w", err)
+			}
+
+			algo, err := parseChecksumAlgo(checksum)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate checksum arguments: %w", err)
+			}
+			programConfig.Checksum = algo
+
+			if programConfig.Quoting, err = parseQuotingStyle(quoting); err != nil {
+				return fmt.Errorf("failed to evaluate quoting arguments: %w", err)
+			}
+
+			// The usage is reported once any pager has exited (not to overlay it).
+			if reportUsage {
+				defer progressFrom(ctx).reportUsage(stderr)()
+			}
+
+			out, closePager := setupPager(stdout, stderr, noPager || tui)
+			defer closePager()
+
+			if programConfig.Includes, err = mergePatterns(fs, "include", includes, includesFile); err != nil {
+				return fmt.Errorf("failed to evaluate include arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, out, stderr, &compressorConfig, &sorterConfig, &programConfig)
 
 			excl, err := prog.mergeExcludes(excludes, excludesFile)
 			if err != nil {
 				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
 			}
 
-			_, err = prog.Diff(ctx, args[0], args[1], args[2], excl)
+			if tui {
+				_, err = prog.DiffTUI(ctx, args[:1], args[1:], excl)
+
+				return err
+			}
+
+			if summaryOnly {
+				_, err = prog.DiffSummary(ctx, args[:1], args[1:], excl)
+
+				return err
+			}
+
+			if pairsFile != "" {
+				pairs, err := prog.readPairs(pairsFile)
+				if err != nil {
+					return fmt.Errorf("failed to evaluate pairs arguments: %w", err)
+				}
+
+				if noOutput {
+					for i := range pairs {
+						pairs[i].Output = ""
+					}
+				}
+
+				return prog.DiffBatch(ctx, pairs, excl)
+			}
+
+			cmpNew, output := args[1:], ""
+			if !noOutput && len(args) > 2 { //nolint:mnd
+				cmpNew, output = args[1:len(args)-1], args[len(args)-1]
+			}
+
+			_, err = prog.DiffSources(ctx, args[:1], cmpNew, output, excl)
 
 			return err
 		},
 	}
 
-	diffCmd.Flags().StringArrayVar(&excludes, "exclude", nil, "pattern to exclude; can be repeated multiple times")
+	diffCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
+	diffCmd.Flags().StringArrayVar(&programConfig.ExcludesOld, "exclude-old", nil, "pattern to exclude from only the old source; can be repeated multiple times")
+	diffCmd.Flags().StringArrayVar(&programConfig.ExcludesNew, "exclude-new", nil, "pattern to exclude from only the new sources; can be repeated multiple times")
+	diffCmd.Flags().IntVar(&programConfig.StripComponents, "strip-components", 0, "leading path components to strip from the paths of both sides before comparing")
+	diffCmd.Flags().StringArrayVar(&mapsOld, "map-old", nil, "rewrite leading path components of the old source (FROM=>TO); can be repeated multiple times")
+	diffCmd.Flags().StringArrayVar(&mapsNew, "map-new", nil, "rewrite leading path components of the new sources (FROM=>TO); can be repeated multiple times")
 	diffCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
-	diffCmd.Flags().StringVar(&sorterConfig.TempFilesDir, "tmpdir", extSortConfigDefault.TempFilesDir, "on-disk location for intermediate files")
-	diffCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none - 9: highest)")
-	diffCmd.Flags().IntVar(&sorterConfig.NumWorkers, "workers", extSortConfigDefault.NumWorkers, "workers for concurrent operations")
+	diffCmd.Flags().StringArrayVar(&includes, "include", nil, "pattern of files to include (excluding all others); can be repeated multiple times")
+	diffCmd.Flags().StringVar(&includesFile, "includes-from", "", "path to a file containing include patterns")
+	diffCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	diffCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	diffCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "compare paths and match excludes case-insensitively")
+	diffCmd.Flags().IntVar(&programConfig.MaxDepth, "max-depth", 0, "maximum depth of paths to consider (0: unlimited)")
+	diffCmd.Flags().StringVar(&newerThan, "newer-than", "", "consider only files modified after this age (e.g. 30d, 12h or 2024-01-31)")
+	diffCmd.Flags().StringVar(&olderThan, "older-than", "", "consider only files modified before this age (e.g. 30d, 12h or 2024-01-31)")
+	diffCmd.Flags().StringArrayVar(&owners, "owner", nil, "consider only files owned by this user (name or uid); can be repeated multiple times")
+	diffCmd.Flags().StringArrayVar(&groups, "group", nil, "consider only files owned by this group (name or gid); can be repeated multiple times")
+	diffCmd.Flags().BoolVar(&programConfig.PruneEmpty, "prune-empty", false, "leave out directories without any files (such as after exclusions)")
+	diffCmd.Flags().BoolVar(&programConfig.FollowSymlinks, "follow-symlinks", false, "follow symbolic links to directories in directory sources")
+	diffCmd.Flags().IntVar(&programConfig.WalkWorkers, "walkers", 0, "directories to read concurrently when walking (0: serially)")
+	diffCmd.Flags().BoolVar(&programConfig.GitIgnore, "gitignore", false, "apply .gitignore files encountered in directory sources")
+	diffCmd.Flags().BoolVar(&programConfig.DescendArchives, "descend-archives", false, "walk any nested archives (.tar.gz, .tgz, .tar, .zip) as directories of their entries")
+	diffCmd.Flags().BoolVar(&programConfig.SortedWalk, "assume-sorted-walk", false, "walk directory sources in sorted order (instead of sorting their paths on disk)")
+	diffCmd.Flags().BoolVar(&programConfig.FilesOnly, "files-only", false, "compare only files (ignoring any directory additions/removals)")
+	diffCmd.Flags().BoolVar(&programConfig.Strict, "strict", false, "reject non-canonical tarball entries (instead of normalizing them)")
+	diffCmd.Flags().BoolVar(&programConfig.Xattrs, "xattrs", false, "compare the extended attributes of entries (reporting drift as ~~~)")
+	diffCmd.Flags().BoolVar(&programConfig.ACLs, "acls", false, "compare the POSIX ACLs of entries (reporting drift as ~~~)")
+	diffCmd.Flags().StringVar(&onlySide, "only", "all", "side of the differences to consider (all, added, removed)")
+	diffCmd.Flags().IntVar(&programConfig.MaxDiffs, "max-diffs", 0, "stop comparing after this many differences (0: unlimited)")
+	diffCmd.Flags().BoolVar(&stopOnFirst, "stop-on-first", false, "stop comparing after the first difference (as --max-diffs=1)")
+	diffCmd.Flags().StringVar(&addedPrefix, "added-prefix", diffAddedPrefix, "directory of the added paths in the <diff.tar.gz>")
+	diffCmd.Flags().StringVar(&removedPrefix, "removed-prefix", diffRemovedPrefix, "directory of the removed paths in the <diff.tar.gz>")
+	diffCmd.Flags().BoolVar(&programConfig.SkipErrors, "skip-errors", false, "skip unreadable entries (with warning) instead of failing")
+	diffCmd.Flags().BoolVarP(&programConfig.Force, "force", "f", false, "overwrite the output file if it already exists")
+	diffCmd.Flags().BoolVar(&programConfig.Backup, "backup", false, "rename an existing output file aside (to *.bak) first")
+	diffCmd.Flags().BoolVar(&programConfig.Fsync, "fsync", false, "sync the output file (and its directory) to its storage before completing")
+	diffCmd.Flags().BoolVar(&programConfig.VerifyWrites, "verify-after-write", false, "read back and decode the output file before completing")
+	diffCmd.Flags().StringVar(&programConfig.SignKey, "sign-key", "", "private SSH key to write a detached signature (*.sig) with")
+	diffCmd.Flags().StringVar(&checksum, "checksum", "none", "algorithm of a checksum file to write alongside (none, sha256, blake3)")
+	diffCmd.Flags().StringVar(&pairsFile, "pairs-from", "", "path to a file of (tab-separated) pairs to compare in one invocation")
+	diffCmd.Flags().BoolVar(&noOutput, "no-output", false, "only report the differences (without any output file)")
+	diffCmd.Flags().BoolVar(&summaryOnly, "summary-only", false, "only print the amounts of the differences per top-level directory (without any output file)")
+	diffCmd.Flags().BoolVar(&tui, "tui", false, "view the differences side by side in an interactive viewer (without any output file)")
+	diffCmd.Flags().BoolVar(&reportUsage, "report-usage", false, "print the peak heap and temporary disk usage to stderr upon completion (for sizing)")
+	diffCmd.Flags().BoolVarP(&programConfig.Print0, "print0", "0", false, "terminate printed paths with NUL bytes (instead of newlines)")
+	diffCmd.Flags().StringVar(&quoting, "quoting", "literal", "quoting of the printed paths (literal, shell, c)")
+	diffCmd.Flags().BoolVar(&noPager, "no-pager", false, "do not pipe the output into a pager (when a terminal)")
+	diffCmd.Flags().StringVar(&tarFormat, "tar-format", "auto", "format of the tar headers (auto, ustar, pax, gnu)")
+	diffCmd.Flags().StringVar(&gzipName, "gzip-name", "", "name of the file recorded in the gzip header")
+	diffCmd.Flags().StringVar(&gzipMtime, "gzip-mtime", "", "modification time recorded in the gzip header (now, @SECONDS or a date; default: none)")
+	diffCmd.Flags().StringVar(&gzipComment, "gzip-comment", "", "comment recorded in the gzip header")
+	diffCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none, as plain tar - 9: highest)")
 	diffCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
 
+	diffCmd.MarkFlagsMutuallyExclusive("tui", "summary-only", "pairs-from")
+	diffCmd.MarkFlagsMutuallyExclusive("max-diffs", "stop-on-first")
+
 	return diffCmd
 }
 
+func newCheckCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var excludes []string
+	var excludesFile string
+	var includes []string
+	var includesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var newerThan string
+	var olderThan string
+	var owners []string
+	var groups []string
+	var noPager bool
+	var quoting string
+	var onlySide string
+	var stopOnFirst bool
+	var reportUsage bool
+
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	checkCmd := &cobra.Command{
+		Use:               "check <archive.tar.gz> <root>...",
+		Aliases:           []string{"chk"},
+		Short:             checkHelpShort,
+		Long:              checkHelpLong,
+		Example:           checkExample,
+		Args:              cobra.MinimumNArgs(2), //nolint:mnd
+		ValidArgsFunction: completePositional(completeArchives, completeDirs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			if err := checkMaxDepth(programConfig.MaxDepth); err != nil {
+				return fmt.Errorf("failed to evaluate max-depth arguments: %w", err)
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			if programConfig.NewerThan, programConfig.OlderThan, err = parseAgeFilters(newerThan, olderThan); err != nil {
+				return fmt.Errorf("failed to evaluate age arguments: %w", err)
+			}
+
+			if programConfig.Owners, programConfig.Groups, err = parseOwnerFilters(owners, groups); err != nil {
+				return fmt.Errorf("failed to evaluate owner arguments: %w", err)
+			}
+
+			side, err := parseDiffSide(onlySide)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate only arguments: %w", err)
+			}
+			programConfig.OnlySide = side
+
+			if stopOnFirst {
+				programConfig.MaxDiffs = 1
+			}
+
+			if programConfig.Quoting, err = parseQuotingStyle(quoting); err != nil {
+				return fmt.Errorf("failed to evaluate quoting arguments: %w", err)
+			}
+
+			if reportUsage {
+				defer progressFrom(ctx).reportUsage(stderr)()
+			}
+
+			out, closePager := setupPager(stdout, stderr, noPager)
+			defer closePager()
+
+			if programConfig.Includes, err = mergePatterns(fs, "include", includes, includesFile); err != nil {
+				return fmt.Errorf("failed to evaluate include arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, out, stderr, nil, &sorterConfig, &programConfig)
+
+			excl, err := prog.mergeExcludes(excludes, excludesFile)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+
+			_, err = prog.Check(ctx, args[0], args[1:], excl)
+
+			return err
+		},
+	}
+
+	checkCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
+	checkCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
+	checkCmd.Flags().StringArrayVar(&includes, "include", nil, "pattern of files to include (excluding all others); can be repeated multiple times")
+	checkCmd.Flags().StringVar(&includesFile, "includes-from", "", "path to a file containing include patterns")
+	checkCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	checkCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	checkCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "compare paths and match excludes case-insensitively")
+	checkCmd.Flags().IntVar(&programConfig.MaxDepth, "max-depth", 0, "maximum depth of paths to consider (0: unlimited)")
+	checkCmd.Flags().StringVar(&newerThan, "newer-than", "", "consider only files modified after this age (e.g. 30d, 12h or 2024-01-31)")
+	checkCmd.Flags().StringVar(&olderThan, "older-than", "", "consider only files modified before this age (e.g. 30d, 12h or 2024-01-31)")
+	checkCmd.Flags().StringArrayVar(&owners, "owner", nil, "consider only files owned by this user (name or uid); can be repeated multiple times")
+	checkCmd.Flags().StringArrayVar(&groups, "group", nil, "consider only files owned by this group (name or gid); can be repeated multiple times")
+	checkCmd.Flags().BoolVar(&programConfig.PruneEmpty, "prune-empty", false, "leave out directories without any files (such as after exclusions)")
+	checkCmd.Flags().BoolVar(&programConfig.FollowSymlinks, "follow-symlinks", false, "follow symbolic links to directories in directory sources")
+	checkCmd.Flags().IntVar(&programConfig.WalkWorkers, "walkers", 0, "directories to read concurrently when walking (0: serially)")
+	checkCmd.Flags().BoolVar(&programConfig.GitIgnore, "gitignore", false, "apply .gitignore files encountered in directory sources")
+	checkCmd.Flags().BoolVar(&programConfig.DescendArchives, "descend-archives", false, "walk any nested archives (.tar.gz, .tgz, .tar, .zip) as directories of their entries")
+	checkCmd.Flags().BoolVar(&programConfig.FilesOnly, "files-only", false, "compare only files (ignoring any directory additions/removals)")
+	checkCmd.Flags().BoolVar(&programConfig.Strict, "strict", false, "reject non-canonical tarball entries (instead of normalizing them)")
+	checkCmd.Flags().BoolVar(&programConfig.Xattrs, "xattrs", false, "compare the extended attributes of entries (reporting drift as ~~~)")
+	checkCmd.Flags().BoolVar(&programConfig.ACLs, "acls", false, "compare the POSIX ACLs of entries (reporting drift as ~~~)")
+	checkCmd.Flags().StringVar(&onlySide, "only", "all", "side of the differences to consider (all, added, removed)")
+	checkCmd.Flags().IntVar(&programConfig.MaxDiffs, "max-diffs", 0, "stop comparing after this many differences (0: unlimited)")
+	checkCmd.Flags().BoolVar(&stopOnFirst, "stop-on-first", false, "stop comparing after the first difference (as --max-diffs=1)")
+	checkCmd.Flags().BoolVar(&programConfig.SkipErrors, "skip-errors", false, "skip unreadable entries (with warning) instead of failing")
+	checkCmd.Flags().BoolVar(&reportUsage, "report-usage", false, "print the peak heap and temporary disk usage to stderr upon completion (for sizing)")
+	checkCmd.Flags().BoolVarP(&programConfig.Print0, "print0", "0", false, "terminate printed paths with NUL bytes (instead of newlines)")
+	checkCmd.Flags().StringVar(&quoting, "quoting", "literal", "quoting of the printed paths (literal, shell, c)")
+	checkCmd.Flags().BoolVar(&noPager, "no-pager", false, "do not pipe the output into a pager (when a terminal)")
+	checkCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	checkCmd.MarkFlagsMutuallyExclusive("max-diffs", "stop-on-first")
+
+	return checkCmd
+}
+
 func newListCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
 	var excludes []string
 	var excludesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var noPager bool
+	var quoting string
+	var entryType string
+	var subdir string
+	var sortBy string
+	var count bool
+	var output string
+	var format string
+	var long bool
+	var reportUsage bool
 
 	sort := true
 	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
 
 	listCmd := &cobra.Command{
-		Use:     "list <input.tar.gz>",
-		Short:   listHelpShort,
-		Long:    listHelpLong,
-		Example: listExample,
-		Args:    cobra.ExactArgs(1),
-		RunE: func(_ *cobra.Command, args []string) error {
-			prog := NewProgram(fs, stdout, stderr, nil, &sorterConfig)
+		Use:               "list <input.tar.gz>",
+		Aliases:           []string{"ls"},
+		Short:             listHelpShort,
+		Long:              listHelpLong,
+		Example:           listExample,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePositional(completeArchives, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			colorTo := stdout
+			if output != "" {
+				colorTo = nil // The output file is never a terminal (colorized only with --color=always)
+			}
+			if err := applyGlobalOptions(cmd, colorTo, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			if err := checkMaxDepth(programConfig.MaxDepth); err != nil {
+				return fmt.Errorf("failed to evaluate max-depth arguments: %w", err)
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			typ, err := parseEntryType(entryType)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate type arguments: %w", err)
+			}
+			programConfig.OnlyType = typ
+
+			if programConfig.Subdir, err = parseSubdir(subdir); err != nil {
+				return fmt.Errorf("failed to evaluate subdir arguments: %w", err)
+			}
+
+			order, err := parseSortOrder(sortBy)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate sort arguments: %w", err)
+			}
+			programConfig.SortBy = order
+
+			listFormat, err := parseListFormat(format)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate format arguments: %w", err)
+			}
+			programConfig.ListFormat = listFormat
+
+			if long {
+				programConfig.ListFormat = ListLong
+			}
+
+			if programConfig.Quoting, err = parseQuotingStyle(quoting); err != nil {
+				return fmt.Errorf("failed to evaluate quoting arguments: %w", err)
+			}
+
+			if reportUsage {
+				defer progressFrom(ctx).reportUsage(stderr)()
+			}
+
+			out, closePager := setupPager(stdout, stderr, noPager || output != "")
+			defer closePager()
+
+			prog := NewProgram(fs, out, stderr, nil, &sorterConfig, &programConfig)
 
 			excl, err := prog.mergeExcludes(excludes, excludesFile)
 			if err != nil {
 				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
 			}
 
+			if count {
+				_, err := prog.Count(ctx, args[0], excl)
+
+				return err
+			}
+
+			if output != "" {
+				return prog.ListTo(ctx, args[0], sort, excl, output)
+			}
+
 			return prog.List(ctx, args[0], sort, excl)
 		},
 	}
 
-	listCmd.Flags().StringArrayVar(&excludes, "exclude", nil, "pattern to exclude; can be repeated multiple times")
+	listCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
 	listCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
+	listCmd.Flags().StringArrayVar(&programConfig.Matches, "match", nil, "pattern to match for paths to be listed; can be repeated multiple times")
+	listCmd.Flags().StringVar(&entryType, "type", "", "type of the entries to be listed (f: files, d: directories)")
+	listCmd.Flags().StringVar(&subdir, "subdir", "", "list only the entries within this subdirectory (e.g. media/movies/)")
+	listCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	listCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	listCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "match excludes case-insensitively")
+	listCmd.Flags().IntVar(&programConfig.MaxDepth, "max-depth", 0, "maximum depth of paths to consider (0: unlimited)")
 	listCmd.Flags().BoolVar(&sort, "sort", true, "sort the output list; for better comparability")
-	listCmd.Flags().StringVar(&sorterConfig.TempFilesDir, "tmpdir", extSortConfigDefault.TempFilesDir, "on-disk location for intermediate files")
-	listCmd.Flags().IntVar(&sorterConfig.NumWorkers, "workers", extSortConfigDefault.NumWorkers, "workers for concurrent operations")
+	listCmd.Flags().StringVar(&sortBy, "sort-by", "name", "order of the sorted output list (name, reverse, depth, version)")
+	listCmd.Flags().StringVar(&format, "format", "text", "format of the output list (text, jsonl, mtree, long)")
+	listCmd.Flags().BoolVarP(&long, "long", "l", false, "list the type, mode, size and mtime of entries (as --format=long)")
+	listCmd.Flags().BoolVarP(&count, "count", "c", false, "only report the amounts of files and directories (without sorting)")
+	listCmd.Flags().BoolVar(&reportUsage, "report-usage", false, "print the peak heap and temporary disk usage to stderr upon completion (for sizing)")
+	listCmd.Flags().BoolVarP(&programConfig.Print0, "print0", "0", false, "terminate printed paths with NUL bytes (instead of newlines)")
+	listCmd.Flags().StringVarP(&output, "output", "o", "", "write the output list to a file instead (compressed if ending in .gz)")
+	listCmd.Flags().BoolVarP(&programConfig.Force, "force", "f", false, "overwrite the output file if it already exists")
+	listCmd.Flags().BoolVar(&programConfig.Backup, "backup", false, "rename an existing output file aside (to *.bak) first")
+	listCmd.Flags().StringVar(&quoting, "quoting", "literal", "quoting of the printed paths (literal, shell, c)")
+	listCmd.Flags().BoolVar(&noPager, "no-pager", false, "do not pipe the output into a pager (when a terminal)")
+	listCmd.Flags().BoolVar(&programConfig.NoIndex, "no-index", false, "never read the index file of the tarball (as built by index)")
 	listCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
 
+	listCmd.MarkFlagsMutuallyExclusive("long", "format")
+	listCmd.MarkFlagsMutuallyExclusive("long", "count")
+
 	return listCmd
 }
 
-func main() {
-	var exitCode int
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Fprintf(os.Stderr, "panic: %v\n\n", r)
-			debug.PrintStack()
-			exitCode = exitCodeFailure
-		}
-		os.Exit(exitCode)
-	}()
+func newRecreateCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var tarFormat string
+	var gzipName, gzipMtime, gzipComment string
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	sorterConfig := extSortConfigDefault
+	compressorConfig := gzipConfigDefault
+	programConfig := ProgramConfig{}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	recreateCmd := &cobra.Command{
+		Use:               "recreate <manifest.json> <output.tar.gz>",
+		Short:             recreateHelpShort,
+		Long:              recreateHelpLong,
+		Example:           recreateExample,
+		Args:              cobra.ExactArgs(2), //nolint:mnd
+		ValidArgsFunction: completePositional(completeFiles, completeArchives, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			format, err := parseTarFormat(tarFormat)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate format arguments: %w", err)
+			}
+			programConfig.TarFormat = format
+
+			if programConfig.GzipHeader, err = parseGzipHeader(gzipName, gzipMtime, gzipComment, programConfig.ModTime); err != nil {
+				return fmt.Errorf("failed to evaluate gzip arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, stdout, stderr, &compressorConfig, &sorterConfig, &programConfig)
+
+			return prog.Recreate(ctx, args[0], args[1])
+		},
+	}
+
+	recreateCmd.Flags().BoolVarP(&programConfig.Print0, "print0", "0", false, "terminate printed paths with NUL bytes (instead of newlines)")
+	recreateCmd.Flags().BoolVarP(&programConfig.Force, "force", "f", false, "overwrite the output file if it already exists")
+	recreateCmd.Flags().BoolVar(&programConfig.Backup, "backup", false, "rename an existing output file aside (to *.bak) first")
+	recreateCmd.Flags().BoolVar(&programConfig.Fsync, "fsync", false, "sync the output file (and its directory) to its storage before completing")
+	recreateCmd.Flags().BoolVar(&programConfig.VerifyWrites, "verify-after-write", false, "read back and decode the output file before completing")
+	recreateCmd.Flags().StringVar(&tarFormat, "tar-format", "auto", "format of the tar headers (auto, ustar, pax, gnu)")
+	recreateCmd.Flags().StringVar(&gzipName, "gzip-name", "", "name of the file recorded in the gzip header")
+	recreateCmd.Flags().StringVar(&gzipMtime, "gzip-mtime", "", "modification time recorded in the gzip header (now, @SECONDS or a date; default: none)")
+	recreateCmd.Flags().StringVar(&gzipComment, "gzip-comment", "", "comment recorded in the gzip header")
+	recreateCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none, as plain tar - 9: highest)")
+	recreateCmd.Flags().IntVar(&compressorConfig.BlockSize, "blocksize", gzipConfigDefault.BlockSize, "block size for compressing")
+	recreateCmd.Flags().IntVar(&compressorConfig.BlockCount, "blockcount", gzipConfigDefault.BlockCount, "blocks to compress in parallel")
+	recreateCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return recreateCmd
+}
+
+func newNormalizeCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var tarFormat string
+	var gzipName, gzipMtime, gzipComment string
+
+	sorterConfig := extSortConfigDefault
+	compressorConfig := gzipConfigDefault
+	programConfig := ProgramConfig{}
+
+	normalizeCmd := &cobra.Command{
+		Use:               "normalize <input.tar.gz> <output.tar.gz>",
+		Short:             normalizeHelpShort,
+		Long:              normalizeHelpLong,
+		Example:           normalizeExample,
+		Args:              cobra.ExactArgs(2), //nolint:mnd
+		ValidArgsFunction: completePositional(completeArchives, completeArchives, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			format, err := parseTarFormat(tarFormat)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate format arguments: %w", err)
+			}
+			programConfig.TarFormat = format
+
+			if programConfig.GzipHeader, err = parseGzipHeader(gzipName, gzipMtime, gzipComment, programConfig.ModTime); err != nil {
+				return fmt.Errorf("failed to evaluate gzip arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, stdout, stderr, &compressorConfig, &sorterConfig, &programConfig)
+
+			return prog.Normalize(ctx, args[0], args[1])
+		},
+	}
+
+	normalizeCmd.Flags().BoolVarP(&programConfig.Print0, "print0", "0", false, "terminate printed paths with NUL bytes (instead of newlines)")
+	normalizeCmd.Flags().BoolVarP(&programConfig.Force, "force", "f", false, "overwrite the output file if it already exists")
+	normalizeCmd.Flags().BoolVar(&programConfig.Backup, "backup", false, "rename an existing output file aside (to *.bak) first")
+	normalizeCmd.Flags().BoolVar(&programConfig.Fsync, "fsync", false, "sync the output file (and its directory) to its storage before completing")
+	normalizeCmd.Flags().BoolVar(&programConfig.VerifyWrites, "verify-after-write", false, "read back and decode the output file before completing")
+	normalizeCmd.Flags().StringVar(&tarFormat, "tar-format", "auto", "format of the tar headers (auto, ustar, pax, gnu)")
+	normalizeCmd.Flags().StringVar(&gzipName, "gzip-name", "", "name of the file recorded in the gzip header")
+	normalizeCmd.Flags().StringVar(&gzipMtime, "gzip-mtime", "", "modification time recorded in the gzip header (now, @SECONDS or a date; default: none)")
+	normalizeCmd.Flags().StringVar(&gzipComment, "gzip-comment", "", "comment recorded in the gzip header")
+	normalizeCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none, as plain tar - 9: highest)")
+	normalizeCmd.Flags().IntVar(&compressorConfig.BlockSize, "blocksize", gzipConfigDefault.BlockSize, "block size for compressing")
+	normalizeCmd.Flags().IntVar(&compressorConfig.BlockCount, "blockcount", gzipConfigDefault.BlockCount, "blocks to compress in parallel")
+	normalizeCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return normalizeCmd
+}
+
+func newConvertCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var excludes []string
+	var excludesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var tarFormat string
+	var gzipName, gzipMtime, gzipComment string
+
+	sorterConfig := extSortConfigDefault
+	compressorConfig := gzipConfigDefault
+	programConfig := ProgramConfig{}
+
+	convertCmd := &cobra.Command{
+		Use:               "convert <input> <output.tar.gz>",
+		Short:             convertHelpShort,
+		Long:              convertHelpLong,
+		Example:           convertExample,
+		Args:              cobra.ExactArgs(2), //nolint:mnd
+		ValidArgsFunction: completePositional(completeFiles, completeArchives, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, false)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			format, err := parseTarFormat(tarFormat)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate format arguments: %w", err)
+			}
+			programConfig.TarFormat = format
+
+			if programConfig.GzipHeader, err = parseGzipHeader(gzipName, gzipMtime, gzipComment, programConfig.ModTime); err != nil {
+				return fmt.Errorf("failed to evaluate gzip arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, stdout, stderr, &compressorConfig, &sorterConfig, &programConfig)
+
+			excl, err := prog.mergeExcludes(excludes, excludesFile)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+
+			return prog.Convert(ctx, args[0], args[1], excl)
+		},
+	}
+
+	convertCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
+	convertCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
+	convertCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	convertCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	convertCmd.Flags().BoolVar(&programConfig.Strict, "strict", false, "reject non-canonical tarball entries (instead of normalizing them)")
+	convertCmd.Flags().BoolVarP(&programConfig.Print0, "print0", "0", false, "terminate printed paths with NUL bytes (instead of newlines)")
+	convertCmd.Flags().BoolVarP(&programConfig.Force, "force", "f", false, "overwrite the output file if it already exists")
+	convertCmd.Flags().BoolVar(&programConfig.Backup, "backup", false, "rename an existing output file aside (to *.bak) first")
+	convertCmd.Flags().BoolVar(&programConfig.Fsync, "fsync", false, "sync the output file (and its directory) to its storage before completing")
+	convertCmd.Flags().BoolVar(&programConfig.VerifyWrites, "verify-after-write", false, "read back and decode the output file before completing")
+	convertCmd.Flags().StringVar(&tarFormat, "tar-format", "auto", "format of the tar headers (auto, ustar, pax, gnu)")
+	convertCmd.Flags().StringVar(&gzipName, "gzip-name", "", "name of the file recorded in the gzip header")
+	convertCmd.Flags().StringVar(&gzipMtime, "gzip-mtime", "", "modification time recorded in the gzip header (now, @SECONDS or a date; default: none)")
+	convertCmd.Flags().StringVar(&gzipComment, "gzip-comment", "", "comment recorded in the gzip header")
+	convertCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none, as plain tar - 9: highest)")
+	convertCmd.Flags().IntVar(&compressorConfig.BlockSize, "blocksize", gzipConfigDefault.BlockSize, "block size for compressing")
+	convertCmd.Flags().IntVar(&compressorConfig.BlockCount, "blockcount", gzipConfigDefault.BlockCount, "blocks to compress in parallel")
+	convertCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return convertCmd
+}
+
+func newIndexCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	indexCmd := &cobra.Command{
+		Use:               "index <input.tar.gz>",
+		Short:             indexHelpShort,
+		Long:              indexHelpLong,
+		Example:           indexExample,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePositional(completeArchives, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			prog := NewProgram(fs, stdout, stderr, nil, &sorterConfig, &programConfig)
+
+			_, err := prog.Index(ctx, args[0])
+
+			return err
+		},
+	}
+
+	indexCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return indexCmd
+}
+
+func newExportCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var excludes []string
+	var excludesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var format string
+	var subdir string
+
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	exportCmd := &cobra.Command{
+		Use:               "export <input.tar.gz> <output.db>",
+		Short:             exportHelpShort,
+		Long:              exportHelpLong,
+		Example:           exportExample,
+		Args:              cobra.ExactArgs(2), //nolint:mnd
+		ValidArgsFunction: completePositional(completeArchives, completeFiles, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			if err := checkMaxDepth(programConfig.MaxDepth); err != nil {
+				return fmt.Errorf("failed to evaluate max-depth arguments: %w", err)
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			exportFormat, err := parseExportFormat(format)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate format arguments: %w", err)
+			}
+
+			if programConfig.Subdir, err = parseSubdir(subdir); err != nil {
+				return fmt.Errorf("failed to evaluate subdir arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, stdout, stderr, nil, &sorterConfig, &programConfig)
+
+			excl, err := prog.mergeExcludes(excludes, excludesFile)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+
+			_, err = prog.Export(ctx, args[0], args[1], exportFormat, excl)
+
+			return err
+		},
+	}
+
+	exportCmd.Flags().StringVar(&format, "format", "sqlite", "format of the output file (sqlite)")
+	exportCmd.Flags().StringVar(&subdir, "subdir", "", "export only the entries within this subdirectory (e.g. media/movies/)")
+	exportCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
+	exportCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
+	exportCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	exportCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	exportCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "match excludes case-insensitively")
+	exportCmd.Flags().IntVar(&programConfig.MaxDepth, "max-depth", 0, "maximum depth of paths to consider (0: unlimited)")
+	exportCmd.Flags().BoolVarP(&programConfig.Force, "force", "f", false, "overwrite the output file if it already exists")
+	exportCmd.Flags().BoolVar(&programConfig.Backup, "backup", false, "rename an existing output file aside (to *.bak) first")
+
+	return exportCmd
+}
+
+func newRestoreCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var excludes []string
+	var excludesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var onExists string
+	var onlyMissing bool
+
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	restoreCmd := &cobra.Command{
+		Use:               "restore <input.tar.gz> <dest-folder>",
+		Short:             restoreHelpShort,
+		Long:              restoreHelpLong,
+		Example:           restoreExample,
+		Args:              cobra.ExactArgs(2), //nolint:mnd
+		ValidArgsFunction: completePositional(completeArchives, completeDirs, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			if err := checkMaxDepth(programConfig.MaxDepth); err != nil {
+				return fmt.Errorf("failed to evaluate max-depth arguments: %w", err)
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			if programConfig.OnExists, err = parseRestorePolicy(onExists); err != nil {
+				return fmt.Errorf("failed to evaluate on-exists arguments: %w", err)
+			}
+			if onlyMissing {
+				programConfig.OnExists = RestoreSkip
+			}
+
+			prog := NewProgram(fs, stdout, stderr, nil, &sorterConfig, &programConfig)
+
+			excl, err := prog.mergeExcludes(excludes, excludesFile)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+
+			_, err = prog.Restore(ctx, args[0], args[1], excl)
+
+			return err
+		},
+	}
+
+	restoreCmd.Flags().StringVar(&onExists, "on-exists", "", "handling of paths existing in the destination (skip, overwrite, rename; default: fail)")
+	restoreCmd.Flags().BoolVar(&onlyMissing, "only-missing", false, "restore only the paths missing from the destination (same as --on-exists=skip)")
+	restoreCmd.MarkFlagsMutuallyExclusive("on-exists", "only-missing")
+	restoreCmd.Flags().BoolVarP(&programConfig.Print0, "print0", "0", false, "terminate printed paths with NUL bytes (instead of newlines)")
+	restoreCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
+	restoreCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
+	restoreCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	restoreCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	restoreCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "match excludes case-insensitively")
+	restoreCmd.Flags().IntVar(&programConfig.MaxDepth, "max-depth", 0, "maximum depth of paths to consider (0: unlimited)")
+	restoreCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return restoreCmd
+}
+
+func newVerifyCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var signature string
+	var trustedKeys string
+
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	verifyCmd := &cobra.Command{
+		Use:               "verify <input.tar.gz>",
+		Short:             verifyHelpShort,
+		Long:              verifyHelpLong,
+		Example:           verifyExample,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePositional(completeArchives, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			prog := NewProgram(fs, stdout, stderr, nil, &sorterConfig, &programConfig)
+
+			if signature != "" {
+				if err := prog.VerifySignature(ctx, args[0], signature, trustedKeys); err != nil {
+					return err
+				}
+			}
+
+			return prog.Verify(ctx, args[0])
+		},
+	}
+
+	verifyCmd.Flags().StringVar(&signature, "signature", "", "path to a detached signature of the archive to check (e.g. *.tar.gz.sig)")
+	verifyCmd.Flags().StringVar(&trustedKeys, "trusted-keys", "", "path to the public keys trusted to sign (authorized_keys format)")
+	verifyCmd.MarkFlagsRequiredTogether("signature", "trusted-keys")
+	verifyCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return verifyCmd
+}
+
+func newShowCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	programConfig := ProgramConfig{}
+
+	showCmd := &cobra.Command{
+		Use:               "show <input.tar.gz>",
+		Short:             showHelpShort,
+		Long:              showHelpLong,
+		Example:           showExample,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePositional(completeArchives, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, nil, &programConfig); err != nil {
+				return err
+			}
+
+			prog := NewProgram(fs, stdout, stderr, nil, nil, &programConfig)
+
+			_, err := prog.Show(ctx, args[0])
+
+			return err
+		},
+	}
+
+	return showCmd
+}
+
+func newHashCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var excludes []string
+	var excludesFile string
+	var includes []string
+	var includesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var newerThan string
+	var olderThan string
+	var owners []string
+	var groups []string
+	var algorithm string
+
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	hashCmd := &cobra.Command{
+		Use:               "hash <source>...",
+		Short:             hashHelpShort,
+		Long:              hashHelpLong,
+		Example:           hashExample,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeFiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			if err := checkMaxDepth(programConfig.MaxDepth); err != nil {
+				return fmt.Errorf("failed to evaluate max-depth arguments: %w", err)
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			if programConfig.NewerThan, programConfig.OlderThan, err = parseAgeFilters(newerThan, olderThan); err != nil {
+				return fmt.Errorf("failed to evaluate age arguments: %w", err)
+			}
+
+			if programConfig.Owners, programConfig.Groups, err = parseOwnerFilters(owners, groups); err != nil {
+				return fmt.Errorf("failed to evaluate owner arguments: %w", err)
+			}
+
+			algo, err := parseChecksumAlgo(algorithm)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate algorithm arguments: %w", err)
+			}
+
+			if programConfig.Includes, err = mergePatterns(fs, "include", includes, includesFile); err != nil {
+				return fmt.Errorf("failed to evaluate include arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, stdout, stderr, nil, &sorterConfig, &programConfig)
+
+			excl, err := prog.mergeExcludes(excludes, excludesFile)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+
+			_, err = prog.Hash(ctx, args, algo, excl)
+
+			return err
+		},
+	}
+
+	hashCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
+	hashCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
+	hashCmd.Flags().StringArrayVar(&includes, "include", nil, "pattern of files to include (excluding all others); can be repeated multiple times")
+	hashCmd.Flags().StringVar(&includesFile, "includes-from", "", "path to a file containing include patterns")
+	hashCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	hashCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	hashCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "match excludes case-insensitively")
+	hashCmd.Flags().IntVar(&programConfig.MaxDepth, "max-depth", 0, "maximum depth of paths to consider (0: unlimited)")
+	hashCmd.Flags().StringVar(&newerThan, "newer-than", "", "consider only files modified after this age (e.g. 30d, 12h or 2024-01-31)")
+	hashCmd.Flags().StringVar(&olderThan, "older-than", "", "consider only files modified before this age (e.g. 30d, 12h or 2024-01-31)")
+	hashCmd.Flags().StringArrayVar(&owners, "owner", nil, "consider only files owned by this user (name or uid); can be repeated multiple times")
+	hashCmd.Flags().StringArrayVar(&groups, "group", nil, "consider only files owned by this group (name or gid); can be repeated multiple times")
+	hashCmd.Flags().BoolVar(&programConfig.PruneEmpty, "prune-empty", false, "leave out directories without any files (such as after exclusions)")
+	hashCmd.Flags().BoolVar(&programConfig.FollowSymlinks, "follow-symlinks", false, "follow symbolic links to directories in directory sources")
+	hashCmd.Flags().IntVar(&programConfig.WalkWorkers, "walkers", 0, "directories to read concurrently when walking (0: serially)")
+	hashCmd.Flags().BoolVar(&programConfig.GitIgnore, "gitignore", false, "apply .gitignore files encountered in directory sources")
+	hashCmd.Flags().BoolVar(&programConfig.DescendArchives, "descend-archives", false, "walk any nested archives (.tar.gz, .tgz, .tar, .zip) as directories of their entries")
+	hashCmd.Flags().BoolVar(&programConfig.FilesOnly, "files-only", false, "hash only files (ignoring any directories)")
+	hashCmd.Flags().BoolVar(&programConfig.Strict, "strict", false, "reject non-canonical tarball entries (instead of normalizing them)")
+	hashCmd.Flags().BoolVar(&programConfig.Xattrs, "xattrs", false, "include the extended attributes of entries in the hash")
+	hashCmd.Flags().BoolVar(&programConfig.ACLs, "acls", false, "include the POSIX ACLs of entries in the hash")
+	hashCmd.Flags().StringVar(&algorithm, "algorithm", "sha256", "algorithm of the hash (sha256, blake3)")
+	hashCmd.Flags().BoolVar(&programConfig.SkipErrors, "skip-errors", false, "skip unreadable entries (with warning) instead of failing")
+	hashCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return hashCmd
+}
+
+func newBenchCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var files int
+
+	compressorConfig := gzipConfigDefault
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	benchCmd := &cobra.Command{
+		Use:               "bench",
+		Short:             benchHelpShort,
+		Long:              benchHelpLong,
+		Example:           benchExample,
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: completeNothing,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			prog := NewProgram(fs, stdout, stderr, &compressorConfig, &sorterConfig, &programConfig)
+
+			_, err := prog.Bench(ctx, files)
+
+			return err
+		},
+	}
+
+	benchCmd.Flags().IntVar(&files, "files", defaultBenchFiles, "amount of files in the synthetic tree")
+	benchCmd.Flags().IntVar(&programConfig.WalkWorkers, "walkers", 0, "directories to read concurrently when walking (0: serially)")
+	benchCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none, as plain tar - 9: highest)")
+	benchCmd.Flags().IntVar(&compressorConfig.BlockSize, "blocksize", gzipConfigDefault.BlockSize, "block size for compressing")
+	benchCmd.Flags().IntVar(&compressorConfig.BlockCount, "blockcount", gzipConfigDefault.BlockCount, "blocks to compress in parallel")
+	benchCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return benchCmd
+}
+
+func newServeCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var listen string
+
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	serveCmd := &cobra.Command{
+		Use:               "serve <archive.tar.gz|archive-folder>...",
+		Short:             serveHelpShort,
+		Long:              serveHelpLong,
+		Example:           serveExample,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeArchives,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			prog := NewProgram(fs, stdout, stderr, nil, &sorterConfig, &programConfig)
+
+			return prog.Serve(ctx, listen, args)
+		},
+	}
+
+	serveCmd.Flags().StringVar(&listen, "listen", defaultServeListen, "address to serve the API at (e.g. :8080)")
+	serveCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "match excludes and matches case-insensitively")
+	serveCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return serveCmd
+}
+
+func newWatchCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var excludes []string
+	var excludesFile string
+	var includes []string
+	var includesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var interval time.Duration
+	var diffs bool
+	var tarFormat string
+
+	compressorConfig := gzipConfigDefault
+	sorterConfig := extSortConfigDefault
+	programConfig := ProgramConfig{}
+
+	watchCmd := &cobra.Command{
+		Use:               "watch <root-folder> <output-folder>",
+		Short:             watchHelpShort,
+		Long:              watchHelpLong,
+		Example:           watchExample,
+		Args:              cobra.ExactArgs(2), //nolint:mnd
+		ValidArgsFunction: completePositional(completeDirs, completeDirs, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, &sorterConfig, &programConfig); err != nil {
+				return err
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			format, err := parseTarFormat(tarFormat)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate format arguments: %w", err)
+			}
+			programConfig.TarFormat = format
+
+			if programConfig.Includes, err = mergePatterns(fs, "include", includes, includesFile); err != nil {
+				return fmt.Errorf("failed to evaluate include arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, stdout, stderr, &compressorConfig, &sorterConfig, &programConfig)
+
+			excl, err := prog.mergeExcludes(excludes, excludesFile)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+
+			return prog.Watch(ctx, args[0], args[1], interval, diffs, excl)
+		},
+	}
+
+	watchCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
+	watchCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
+	watchCmd.Flags().StringArrayVar(&includes, "include", nil, "pattern of files to include (excluding all others); can be repeated multiple times")
+	watchCmd.Flags().StringVar(&includesFile, "includes-from", "", "path to a file containing include patterns")
+	watchCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	watchCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	watchCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "match excludes case-insensitively")
+	watchCmd.Flags().BoolVar(&programConfig.SkipErrors, "skip-errors", false, "skip unreadable entries (with warning) instead of failing")
+	watchCmd.Flags().DurationVar(&interval, "interval", defaultWatchInterval, "interval between snapshots (taken only upon changes)")
+	watchCmd.Flags().BoolVar(&diffs, "diffs", false, "also create diff tarballs against the previous snapshots")
+	watchCmd.Flags().BoolVar(&programConfig.Fsync, "fsync", false, "sync the output file (and its directory) to its storage before completing")
+	watchCmd.Flags().BoolVar(&programConfig.VerifyWrites, "verify-after-write", false, "read back and decode the output file before completing")
+	watchCmd.Flags().StringVar(&tarFormat, "tar-format", "auto", "format of the tar headers (auto, ustar, pax, gnu)")
+	watchCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none, as plain tar - 9: highest)")
+	watchCmd.Flags().BoolVar(&programConfig.Rsyncable, "rsyncable", false, "end gzip members at content-defined entries, for efficient transfers of changed tarballs with rsync")
+	watchCmd.Flags().IntVar(&compressorConfig.BlockSize, "blocksize", gzipConfigDefault.BlockSize, "block size for compressing")
+	watchCmd.Flags().IntVar(&compressorConfig.BlockCount, "blockcount", gzipConfigDefault.BlockCount, "blocks to compress in parallel")
+	watchCmd.Flags().IntVar(&sorterConfig.ChunkSize, "chunksize", extSortConfigDefault.ChunkSize, "max records per worker before spilling to disk")
+
+	return watchCmd
+}
+
+func newSnapshotCmd(ctx context.Context, fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var excludes []string
+	var excludesFile string
+	var includes []string
+	var includesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+	var newerThan string
+	var olderThan string
+	var owners []string
+	var groups []string
+	var tarFormat string
+	var gzipName, gzipMtime, gzipComment string
+
+	compressorConfig := gzipConfigDefault
+	programConfig := ProgramConfig{}
+	snapshotConfig := SnapshotConfig{}
+
+	snapshotCmd := &cobra.Command{
+		Use:               "snapshot <root-folder> --dest=<archive-folder>",
+		Aliases:           []string{"snap"},
+		Short:             snapshotHelpShort,
+		Long:              snapshotHelpLong,
+		Example:           snapshotExample,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePositional(completeDirs, completeNothing),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, nil, &programConfig); err != nil {
+				return err
+			}
+
+			if err := checkMaxDepth(programConfig.MaxDepth); err != nil {
+				return fmt.Errorf("failed to evaluate max-depth arguments: %w", err)
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			if programConfig.NewerThan, programConfig.OlderThan, err = parseAgeFilters(newerThan, olderThan); err != nil {
+				return fmt.Errorf("failed to evaluate age arguments: %w", err)
+			}
+
+			if programConfig.Owners, programConfig.Groups, err = parseOwnerFilters(owners, groups); err != nil {
+				return fmt.Errorf("failed to evaluate owner arguments: %w", err)
+			}
+
+			format, err := parseTarFormat(tarFormat)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate format arguments: %w", err)
+			}
+			programConfig.TarFormat = format
+
+			if programConfig.GzipHeader, err = parseGzipHeader(gzipName, gzipMtime, gzipComment, programConfig.ModTime); err != nil {
+				return fmt.Errorf("failed to evaluate gzip arguments: %w", err)
+			}
+
+			if programConfig.Includes, err = mergePatterns(fs, "include", includes, includesFile); err != nil {
+				return fmt.Errorf("failed to evaluate include arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, stdout, stderr, &compressorConfig, nil, &programConfig)
+
+			excl, err := prog.mergeExcludes(excludes, excludesFile)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+
+			_, _, err = prog.Snapshot(ctx, args[0], excl, &snapshotConfig)
+
+			return err
+		},
+	}
+
+	snapshotCmd.Flags().StringVar(&snapshotConfig.Dest, "dest", "", "directory to create the archive in (and to prune)")
+	snapshotCmd.Flags().StringVar(&snapshotConfig.Name, "name", defaultSnapshotName, "template of the archive name (fields: Host, Root, Date, Time, Timestamp, Unix)")
+	snapshotCmd.Flags().IntVar(&snapshotConfig.KeepLast, "keep-last", 0, "most recent archives to keep")
+	snapshotCmd.Flags().IntVar(&snapshotConfig.KeepDaily, "keep-daily", 0, "most recent days to keep the last archive of")
+	snapshotCmd.Flags().IntVar(&snapshotConfig.KeepWeekly, "keep-weekly", 0, "most recent weeks to keep the last archive of")
+	snapshotCmd.Flags().IntVar(&snapshotConfig.KeepMonthly, "keep-monthly", 0, "most recent months to keep the last archive of")
+	snapshotCmd.Flags().BoolVarP(&programConfig.Force, "force", "f", false, "overwrite an archive of the same name (e.g. of the same day)")
+	snapshotCmd.Flags().BoolVar(&programConfig.Fsync, "fsync", false, "sync the output file (and its directory) to its storage before completing")
+	snapshotCmd.Flags().BoolVar(&programConfig.VerifyWrites, "verify-after-write", false, "read back and decode the output file before completing")
+	snapshotCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
+	snapshotCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
+	snapshotCmd.Flags().StringArrayVar(&includes, "include", nil, "pattern of files to include (excluding all others); can be repeated multiple times")
+	snapshotCmd.Flags().StringVar(&includesFile, "includes-from", "", "path to a file containing include patterns")
+	snapshotCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	snapshotCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	snapshotCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "match excludes case-insensitively")
+	snapshotCmd.Flags().IntVar(&programConfig.MaxDepth, "max-depth", 0, "maximum depth of paths to consider (0: unlimited)")
+	snapshotCmd.Flags().StringVar(&newerThan, "newer-than", "", "consider only files modified after this age (e.g. 30d, 12h or 2024-01-31)")
+	snapshotCmd.Flags().StringVar(&olderThan, "older-than", "", "consider only files modified before this age (e.g. 30d, 12h or 2024-01-31)")
+	snapshotCmd.Flags().StringArrayVar(&owners, "owner", nil, "consider only files owned by this user (name or uid); can be repeated multiple times")
+	snapshotCmd.Flags().StringArrayVar(&groups, "group", nil, "consider only files owned by this group (name or gid); can be repeated multiple times")
+	snapshotCmd.Flags().BoolVar(&programConfig.PruneEmpty, "prune-empty", false, "leave out directories without any files (such as after exclusions)")
+	snapshotCmd.Flags().BoolVar(&programConfig.FollowSymlinks, "follow-symlinks", false, "follow symbolic links to directories in the tree")
+	snapshotCmd.Flags().BoolVar(&programConfig.SpecialFiles, "special-files", false, "write special files (FIFOs, devices) with their types (instead of as regular files)")
+	snapshotCmd.Flags().IntVar(&programConfig.WalkWorkers, "walkers", 0, "directories to read concurrently when walking (0: serially)")
+	snapshotCmd.Flags().BoolVar(&programConfig.GitIgnore, "gitignore", false, "apply .gitignore files encountered in the directory tree")
+	snapshotCmd.Flags().BoolVar(&programConfig.SkipErrors, "skip-errors", false, "skip unreadable entries (with warning) instead of failing")
+	snapshotCmd.Flags().StringVar(&tarFormat, "tar-format", "auto", "format of the tar headers (auto, ustar, pax, gnu)")
+	snapshotCmd.Flags().StringVar(&gzipName, "gzip-name", "", "name of the file recorded in the gzip header")
+	snapshotCmd.Flags().StringVar(&gzipMtime, "gzip-mtime", "", "modification time recorded in the gzip header (now, @SECONDS or a date; default: none)")
+	snapshotCmd.Flags().StringVar(&gzipComment, "gzip-comment", "", "comment recorded in the gzip header")
+	snapshotCmd.Flags().IntVar(&compressorConfig.CompressionLevel, "compression", gzipConfigDefault.CompressionLevel, "level of compression (0: none, as plain tar - 9: highest)")
+	snapshotCmd.Flags().BoolVar(&programConfig.Rsyncable, "rsyncable", false, "end gzip members at content-defined entries, for efficient transfers of changed tarballs with rsync")
+	snapshotCmd.Flags().IntVar(&compressorConfig.BlockSize, "blocksize", gzipConfigDefault.BlockSize, "block size for compressing")
+	snapshotCmd.Flags().IntVar(&compressorConfig.BlockCount, "blockcount", gzipConfigDefault.BlockCount, "blocks to compress in parallel")
+
+	_ = snapshotCmd.MarkFlagRequired("dest")
+
+	return snapshotCmd
+}
+
+func newMktreeCmd(ctx context.Context, fs afero.Fs) *cobra.Command {
+	treeConfig := mktree.DefaultConfig
+
+	mktreeCmd := &cobra.Command{
+		Use:               "mktree <base-folder> <file-count>",
+		Short:             mktreeHelpShort,
+		Long:              mktreeHelpLong,
+		Example:           mktreeExample,
+		Hidden:            true,
+		Args:              cobra.ExactArgs(2), //nolint:mnd
+		ValidArgsFunction: completePositional(completeDirs, completeNothing),
+		RunE: func(_ *cobra.Command, args []string) error {
+			files, err := strconv.Atoi(args[1])
+			if err != nil || files <= 0 {
+				return fmt.Errorf("%w: %s", errInvalidFileCount, args[1])
+			}
+
+			progressFrom(ctx).setPhase("generating a tree of %d files in %s", files, args[0])
+
+			if err := mktree.CreateTree(ctx, fs, args[0], files, &treeConfig); err != nil {
+				return fmt.Errorf("failed to create tree: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	mktreeCmd.Flags().IntVar(&treeConfig.FilesPerDir, "files-per-dir", mktree.DefaultConfig.FilesPerDir, "amount of files per (innermost) directory")
+	mktreeCmd.Flags().IntVar(&treeConfig.Depth, "depth", mktree.DefaultConfig.Depth, "levels of directories above the files")
+	mktreeCmd.Flags().StringVar(&treeConfig.NamePattern, "name-pattern", mktree.DefaultConfig.NamePattern, "pattern of the file names (formatted with the index in the directory)")
+
+	return mktreeCmd
+}
+
+func newFeaturesCmd() *cobra.Command {
+	featuresCmd := &cobra.Command{
+		Use:               "features",
+		Short:             featuresHelpShort,
+		Long:              featuresHelpLong,
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: completeNothing,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return printFeatures(cmd.OutOrStdout())
+		},
+	}
+
+	return featuresCmd
+}
+
+func newVersionCmd() *cobra.Command {
+	var asJSON bool
+
+	versionCmd := &cobra.Command{
+		Use:               "version",
+		Short:             versionHelpShort,
+		Long:              versionHelpLong,
+		Example:           versionExample,
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: completeNothing,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return printVersion(cmd.OutOrStdout(), buildInfo(), asJSON)
+		},
+	}
+
+	versionCmd.Flags().BoolVar(&asJSON, "json", false, "print the build metadata as JSON (for audits)")
+
+	return versionCmd
+}
+
+func newPatternsCmd(fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	patternsCmd := &cobra.Command{
+		Use:   "patterns",
+		Short: patternsHelpShort,
+		Long:  patternsHelpLong,
+		Args:  cobra.NoArgs,
+	}
+
+	patternsCmd.AddCommand(newPatternsTestCmd(fs, stdout, stderr))
+
+	return patternsCmd
+}
+
+func newPatternsTestCmd(fs afero.Fs, stdout io.Writer, stderr io.Writer) *cobra.Command {
+	var excludes []string
+	var excludesFile string
+	var includes []string
+	var includesFile string
+	var excludeRegexes []string
+	var patternSyntax string
+
+	programConfig := ProgramConfig{}
+
+	testCmd := &cobra.Command{
+		Use:               "test <path>...",
+		Short:             patternsTestHelpShort,
+		Long:              patternsTestHelpLong,
+		Example:           patternsTestExample,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeNothing,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyGlobalOptions(cmd, stdout, nil, &programConfig); err != nil {
+				return err
+			}
+
+			if err := checkMaxDepth(programConfig.MaxDepth); err != nil {
+				return fmt.Errorf("failed to evaluate max-depth arguments: %w", err)
+			}
+
+			regexes, err := compileRegexes(excludeRegexes, programConfig.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+			programConfig.ExcludeRegexes = regexes
+
+			if programConfig.PatternSyntax, err = parsePatternSyntax(patternSyntax); err != nil {
+				return fmt.Errorf("failed to evaluate pattern arguments: %w", err)
+			}
+
+			if programConfig.Includes, err = mergePatterns(fs, "include", includes, includesFile); err != nil {
+				return fmt.Errorf("failed to evaluate include arguments: %w", err)
+			}
+
+			prog := NewProgram(fs, stdout, stderr, nil, nil, &programConfig)
+
+			excl, err := prog.mergeExcludes(excludes, excludesFile)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate exclude arguments: %w", err)
+			}
+
+			return prog.ExplainPatterns(args, excl)
+		},
+	}
+
+	testCmd.Flags().StringArrayVarP(&excludes, "exclude", "e", nil, "pattern to exclude; can be repeated multiple times")
+	testCmd.Flags().StringVar(&excludesFile, "excludes-from", "", "path to a file containing exclude patterns")
+	testCmd.Flags().StringArrayVar(&includes, "include", nil, "pattern of files to include (excluding all others); can be repeated multiple times")
+	testCmd.Flags().StringVar(&includesFile, "includes-from", "", "path to a file containing include patterns")
+	testCmd.Flags().StringArrayVar(&excludeRegexes, "exclude-regex", nil, "regular expression to exclude; can be repeated multiple times")
+	testCmd.Flags().StringVar(&patternSyntax, "pattern-syntax", "doublestar", "dialect of the exclude and include patterns (doublestar, gitignore, rsync)")
+	testCmd.Flags().BoolVar(&programConfig.IgnoreCase, "ignore-case", false, "match excludes case-insensitively")
+	testCmd.Flags().IntVar(&programConfig.MaxDepth, "max-depth", 0, "maximum depth of paths to consider (0: unlimited)")
+	testCmd.Flags().BoolVarP(&programConfig.Print0, "print0", "0", false, "terminate printed lines with NUL bytes (instead of newlines)")
+
+	return testCmd
+}
+
+// exitCodeFor returns the exit code of the program for a command's result err.
+// A partial success takes precedence over differences, as those may then be
+// incomplete (and should not be taken at face value by any monitoring).
+// Any findings of a verification are ordered by their severity likewise.
+func exitCodeFor(err error) int {
+	switch {
+	case err == nil:
+		return exitCodeSuccess
+	case errors.Is(err, ErrArchiveCorrupt):
+		return exitCodeCorrupt
+	case errors.Is(err, ErrArchiveDuplicates):
+		return exitCodeDuplicates
+	case errors.Is(err, ErrArchiveUnsorted):
+		return exitCodeUnsorted
+	case errors.Is(err, ErrPartialSuccess):
+		return exitCodePartial
+	case errors.Is(err, ErrDiffsFound):
+		return exitCodeDiffsFound
+	default:
+		return exitCodeFailure
+	}
+}
+
+func main() {
+	var exitCode int
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "panic: %v\n\n", r)
+			debug.PrintStack()
+			exitCode = exitCodeFailure
+		}
+		os.Exit(exitCode)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	sigChan2 := make(chan os.Signal, 1)
 	signal.Notify(sigChan2, syscall.SIGUSR1)
@@ -287,33 +2039,64 @@ func main() {
 		}
 	}()
 
+	ctx, progress := withProgress(ctx)
+
+	sigChan3 := make(chan os.Signal, 1)
+	signal.Notify(sigChan3, progressSignals...)
+
+	go func() {
+		for range sigChan3 {
+			progress.Snapshot(os.Stderr)
+		}
+	}()
+
+	stdout, flushStdout := bufferOutput(os.Stdout)
+
 	errChan := make(chan error, 1)
+
+	// The panics of the workers end the command as those of the command itself,
+	// with the worker held until exiting (so that its streams are not completed).
+	var rootCmd *cobra.Command
+	ctx = withCrashHandler(ctx, func(r any) {
+		errChan <- recoverCommand(rootCmd, os.Args[1:], r, progress)
+		select {}
+	})
+	rootCmd = newRootCmd(ctx, afero.NewOsFs(), stdout, os.Stderr)
+
 	go func() {
-		rootCmd := newRootCmd(ctx, afero.NewOsFs(), os.Stdout, os.Stderr)
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- recoverCommand(rootCmd, os.Args[1:], r, progress)
+			}
+		}()
 		errChan <- rootCmd.Execute()
 	}()
 
 	select {
 	case err := <-errChan:
-		if err != nil {
-			if errors.Is(err, ErrDiffsFound) {
-				exitCode = exitCodeDiffsFound
-			} else {
-				exitCode = exitCodeFailure
-				fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			}
-		} else {
-			exitCode = exitCodeSuccess
+		exitCode = exitCodeFor(err)
+
+		// Output that was not written at all fails any (otherwise successful) operation.
+		if flushErr := flushStdout(); flushErr != nil && exitCode != exitCodeFailure && exitCode != exitCodeCorrupt {
+			exitCode, err = exitCodeFailure, fmt.Errorf("failed to write output: %w", flushErr)
+		}
+
+		if exitCode == exitCodeFailure || exitCode == exitCodeCorrupt {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		}
 
 	case <-sigChan:
-		fmt.Fprintln(os.Stderr, "interrupting...")
+		fmt.Fprintln(os.Stderr, "interrupting... (repeat to force)")
 		cancel()
 
 		select {
 		case <-errChan:
+			_ = flushStdout() // The output printed up to the interruption.
 			exitCode = exitCodeFailure
 			fmt.Fprintln(os.Stderr, "interrupted (exited)")
+		case <-sigChan:
+			exitCode = exitCodeFailure
+			fmt.Fprintln(os.Stderr, "interrupted (forced)")
 		case <-time.After(exitTimeout):
 			exitCode = exitCodeFailure
 			fmt.Fprintln(os.Stderr, "interrupted (killed)")
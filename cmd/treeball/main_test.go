@@ -1,6 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -46,6 +53,45 @@ func Test_CLI_DiffCommand_NoDiffsFound_Success(t *testing.T) {
 	require.NoError(t, cmd.Execute())
 }
 
+// Expectation: The 'diff' subcommand should exclude the per-side patterns from only their side.
+func Test_CLI_DiffCommand_PerSideExcludes_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "old.txt"}), 0o644)
+	_ = afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt", "new.txt"}), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, nil, nil)
+	cmd.SetArgs([]string{"diff", "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", "--exclude-old=old.txt", "--exclude-new=new.txt"})
+
+	require.NoError(t, cmd.Execute())
+}
+
+// Expectation: The 'check' subcommand should compare only the files matching the includes of a file.
+func Test_CLI_CheckCommand_IncludesFrom_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/includes.txt", []byte("# videos only\n**/*.mkv\n"), 0o644)
+	_ = afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{"a.mkv", "sub/", "sub/b.mkv"}), 0o644)
+	_ = afero.WriteFile(fs, "/src/a.mkv", nil, 0o644)
+	_ = afero.WriteFile(fs, "/src/notes.txt", nil, 0o644)
+	_ = afero.WriteFile(fs, "/src/sub/b.mkv", nil, 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"check", "/snapshot.tar.gz", "/src", "--includes-from=/includes.txt"})
+
+	require.NoError(t, cmd.Execute())
+}
+
+// Expectation: The 'create' subcommand should error when the include file does not exist.
+func Test_CLI_CreateCommand_IncludeFileMissing_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"create", "/one", "/two", "--includes-from=/a.txt"})
+
+	require.ErrorContains(t, cmd.Execute(), "failed to open include file")
+}
+
 // Expectation: The 'list' subcommand should not error when invoked with a valid tarball.
 func Test_CLI_ListCommand_Success(t *testing.T) {
 	fs := afero.NewMemMapFs()
@@ -58,6 +104,36 @@ func Test_CLI_ListCommand_Success(t *testing.T) {
 	require.NoError(t, cmd.Execute())
 }
 
+// Expectation: The aliases and shorthands should run the same as the long forms.
+func Test_CLI_AliasesShorthands_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/src/a.txt", nil, 0o644)
+	_ = afero.WriteFile(fs, "/src/b.nfo", nil, 0o644)
+	_ = afero.WriteFile(fs, "/out.tar.gz", []byte("previous"), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"mk", "/src", "/out.tar.gz", "-f", "-e", "*.nfo"})
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, []string{"a.txt"}, readTarNames(t, fs, "/out.tar.gz"))
+
+	cmd = newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"ls", "/out.tar.gz", "-o", "/list.txt"})
+	require.NoError(t, cmd.Execute())
+
+	data, err := afero.ReadFile(fs, "/list.txt")
+	require.NoError(t, err)
+	require.Equal(t, "a.txt\n", string(data))
+
+	cmd = newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"chk", "/out.tar.gz", "/src", "-e", "*.nfo"})
+	require.NoError(t, cmd.Execute())
+
+	cmd = newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"cmp", "/out.tar.gz", "/src", "-q"})
+	require.ErrorIs(t, cmd.Execute(), ErrDiffsFound)
+}
+
 // Expectation: The root command should error when given an unknown subcommand.
 func Test_CLI_UnknownCommand_Error(t *testing.T) {
 	fs := afero.NewMemMapFs()
@@ -135,3 +211,491 @@ func Test_CLI_ListCommand_ExcludeFileMissing(t *testing.T) {
 	require.Error(t, err)
 	require.ErrorContains(t, err, "exclude")
 }
+
+// Expectation: The 'create' subcommand should apply .gitignore files when requested.
+func Test_CLI_CreateCommand_GitIgnore_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/some/input/.gitignore", []byte("*.log\n"), 0o644)
+	_ = afero.WriteFile(fs, "/some/input/file.txt", []byte("test"), 0o644)
+	_ = afero.WriteFile(fs, "/some/input/file.log", []byte("test"), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"create", "/some/input", "/some/output.tar.gz", "--gitignore"})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, ".gitignore\nfile.txt\n", stdoutBuf.String())
+}
+
+// Expectation: The 'create' subcommand should only estimate and not require an output with --estimate.
+func Test_CLI_CreateCommand_Estimate_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/some/input/file.txt", []byte("test"), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"create", "/some/input", "--estimate"})
+
+	require.NoError(t, cmd.Execute())
+	require.Contains(t, stdoutBuf.String(), "entries: 1\n")
+}
+
+// Expectation: The 'list' subcommand should error when given an invalid exclude regex.
+func Test_CLI_ListCommand_InvalidExcludeRegex_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, nil, nil)
+	cmd.SetArgs([]string{"list", "/input.tar.gz", "--exclude-regex=(a"})
+	err := cmd.Execute()
+
+	require.Error(t, err)
+	require.ErrorContains(t, err, "exclude")
+}
+
+// Expectation: The 'features' subcommand should list the optional features.
+func Test_CLI_FeaturesCommand_Success(t *testing.T) {
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), afero.NewMemMapFs(), &stdoutBuf, nil)
+	cmd.SetArgs([]string{"features"})
+
+	require.NoError(t, cmd.Execute())
+	require.Contains(t, stdoutBuf.String(), "pager")
+}
+
+// Expectation: The exit codes should be derived from the errors with the correct precedence.
+func Test_exitCodeFor_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"Success", nil, exitCodeSuccess},
+		{"Differences found", ErrDiffsFound, exitCodeDiffsFound},
+		{"Wrapped differences found", fmt.Errorf("wrapped: %w", ErrDiffsFound), exitCodeDiffsFound},
+		{"Entries skipped", errors.Join(nil, ErrEntriesSkipped), exitCodePartial},
+		{"Entries skipped with differences", errors.Join(ErrDiffsFound, ErrEntriesSkipped), exitCodePartial},
+		{"Archive corrupt", fmt.Errorf("%w: wrapped", ErrArchiveCorrupt), exitCodeCorrupt},
+		{"Archive duplicates", ErrArchiveDuplicates, exitCodeDuplicates},
+		{"Archive unsorted", ErrArchiveUnsorted, exitCodeUnsorted},
+		{"General failure", errors.New("failure"), exitCodeFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, exitCodeFor(tt.err))
+		})
+	}
+}
+
+// Expectation: The 'diff' subcommand should accept multiple merged sources on the new side.
+func Test_CLI_DiffCommand_MergedSources_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{"Movies/", "Movies/a.mkv", "TV/", "TV/e01.mkv"}), 0o644)
+	_ = afero.WriteFile(fs, "/mnt/m/a.mkv", []byte("a"), 0o644)
+	_ = afero.WriteFile(fs, "/mnt/t/e01.mkv", []byte("e"), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, nil, nil)
+	cmd.SetArgs([]string{"diff", "/snapshot.tar.gz", "dir:Movies=/mnt/m", "dir:TV=/mnt/t", "/diff.tar.gz", "--no-pager"})
+
+	require.NoError(t, cmd.Execute())
+}
+
+// Expectation: The 'recreate' subcommand should recreate a tarball from a manifest.
+func Test_CLI_RecreateCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/manifest.json", []byte(`[{"path": "a/b.txt"}]`), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"recreate", "/manifest.json", "/out.tar.gz"})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "a/\na/b.txt\n", stdoutBuf.String())
+}
+
+// Expectation: The 'check' subcommand should report differences with the appropriate error.
+func Test_CLI_CheckCommand_DiffsFound_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{"a.txt"}), 0o644)
+	_ = afero.WriteFile(fs, "/src/b.txt", []byte("b"), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"check", "/snapshot.tar.gz", "/src", "--no-pager"})
+
+	require.ErrorIs(t, cmd.Execute(), ErrDiffsFound)
+	require.Equal(t, "--- a.txt\n+++ b.txt\nremoved: 1, added: 1\n", stdoutBuf.String())
+}
+
+// Expectation: The 'diff' subcommand should compare the pairs of a pairs file.
+func Test_CLI_DiffCommand_PairsFrom_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644)
+	_ = afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt"}), 0o644)
+	_ = afero.WriteFile(fs, "/pairs.txt", []byte("/old.tar.gz\t/new.tar.gz\t/diff.tar.gz\n"), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, nil, nil)
+	cmd.SetArgs([]string{"diff", "--pairs-from=/pairs.txt", "--no-pager"})
+
+	require.NoError(t, cmd.Execute())
+}
+
+// Expectation: The 'diff' subcommand should not accept arguments along with a pairs file.
+func Test_CLI_DiffCommand_PairsFromArgs_Error(t *testing.T) {
+	cmd := newRootCmd(t.Context(), afero.NewMemMapFs(), nil, nil)
+	cmd.SetArgs([]string{"diff", "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", "--pairs-from=/pairs.txt"})
+
+	require.Error(t, cmd.Execute())
+}
+
+// Expectation: The 'diff' subcommand should not write any output file without the output argument.
+func Test_CLI_DiffCommand_OmittedOutput_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644)
+	_ = afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"b.txt"}), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"diff", "/old.tar.gz", "/new.tar.gz", "--no-pager"})
+
+	require.ErrorIs(t, cmd.Execute(), ErrDiffsFound)
+	require.Equal(t, "--- a.txt\n+++ b.txt\n", stdoutBuf.String())
+
+	files, err := afero.ReadDir(fs, "/")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+}
+
+// Expectation: The 'diff' subcommand should compare directories walked in sorted order with --assume-sorted-walk.
+func Test_CLI_DiffCommand_AssumeSortedWalk_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old/a/x.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/old/a-b", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new/a/x.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new/a/y.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new/a.txt", nil, 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"diff", "/old", "/new", "--no-pager", "--assume-sorted-walk"})
+
+	require.ErrorIs(t, cmd.Execute(), ErrDiffsFound)
+	require.Equal(t, "--- a-b\n+++ a.txt\n+++ a/y.txt\n", stdoutBuf.String())
+}
+
+// Expectation: The 'diff' subcommand should take all arguments as sources with --no-output.
+func Test_CLI_DiffCommand_NoOutput_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{"A/", "A/a.txt"}), 0o644)
+	_ = afero.WriteFile(fs, "/mnt/a/a.txt", []byte("a"), 0o644)
+	_ = afero.WriteFile(fs, "/mnt/b/b.txt", []byte("b"), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"diff", "/snapshot.tar.gz", "dir:A=/mnt/a", "dir:B=/mnt/b", "--no-output", "--no-pager"})
+
+	require.ErrorIs(t, cmd.Execute(), ErrDiffsFound)
+	require.Equal(t, "+++ B/\n+++ B/b.txt\n", stdoutBuf.String())
+
+	files, err := afero.ReadDir(fs, "/")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+}
+
+// Expectation: The 'verify' subcommand should verify an intact tarball.
+func Test_CLI_VerifyCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a/", "a/b.txt"}), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"verify", "/input.tar.gz"})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "entries: 2\n", stdoutBuf.String())
+}
+
+// Expectation: The 'index' subcommand should build the index file read by the 'list' subcommand.
+func Test_CLI_IndexCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a/", "a/b.txt"}), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"index", "/input.tar.gz"})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "entries: 2\n", stdoutBuf.String())
+
+	exists, err := afero.Exists(fs, "/input.tar.gz.tbi")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	stdoutBuf.Reset()
+
+	cmd = newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"list", "/input.tar.gz", "--no-pager"})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "a/\na/b.txt\n", stdoutBuf.String())
+}
+
+// Expectation: The bench command should report the throughput of all its stages.
+func Test_CLI_BenchCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"bench", "--files", "100", "--workers", "2"})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, 4, strings.Count(stdoutBuf.String(), "entries/s)\n"))
+}
+
+// Expectation: The mktree command should generate a tree of the requested layout.
+func Test_CLI_MktreeCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, nil)
+	cmd.SetArgs([]string{"mktree", "/tree", "3", "--depth", "1", "--files-per-dir", "2", "--name-pattern", "f%d.txt"})
+	require.NoError(t, cmd.Execute())
+
+	for _, path := range []string{"/tree/group_000000/f0.txt", "/tree/group_000000/f1.txt", "/tree/group_000001/f0.txt"} {
+		exists, err := afero.Exists(fs, path)
+		require.NoError(t, err)
+		require.True(t, exists, path)
+	}
+}
+
+// Expectation: The mktree command should reject an invalid file count.
+func Test_CLI_MktreeCommand_InvalidFileCount_Error(t *testing.T) {
+	cmd := newRootCmd(t.Context(), afero.NewMemMapFs(), io.Discard, io.Discard)
+	cmd.SetArgs([]string{"mktree", "/tree", "many"})
+
+	require.ErrorIs(t, cmd.Execute(), errInvalidFileCount)
+}
+
+// Expectation: The configurations should be copied, so later changes have no effect.
+func Test_NewProgram_ConfigCopied_Success(t *testing.T) {
+	gzipConfig := GzipConfig{CompressionLevel: 1}
+	config := ProgramConfig{MaxDepth: 1}
+
+	prog := NewProgram(afero.NewMemMapFs(), nil, nil, &gzipConfig, nil, &config)
+
+	gzipConfig.CompressionLevel = 9
+	config.MaxDepth = 2
+
+	require.Equal(t, 1, prog.gzipConfig.CompressionLevel)
+	require.Equal(t, 1, prog.config.MaxDepth)
+}
+
+// Expectation: The slices and pointers of the configuration should be copied, so later changes to their elements have no effect.
+func Test_NewProgram_ConfigElementsCopied_Table(t *testing.T) {
+	re := regexp.MustCompile("a")
+
+	newConfig := func() *ProgramConfig {
+		return &ProgramConfig{
+			ExcludeRegexes: []*regexp.Regexp{re},
+			Matches:        []string{"a"},
+			Includes:       []string{"a"},
+			Owners:         []uint32{1000},
+			Groups:         []uint32{100},
+			ExcludesOld:    []string{"a"},
+			ExcludesNew:    []string{"a"},
+			MapsOld:        []PathMapping{{From: "a", To: "b"}},
+			MapsNew:        []PathMapping{{From: "a", To: "b"}},
+			Transforms:     []*NameTransform{{Regexp: re, Replacement: "a"}},
+			EntryOwner:     &EntryOwner{Name: "a", ID: 1000},
+			EntryGroup:     &EntryOwner{Name: "a", ID: 100},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(config *ProgramConfig)
+	}{
+		{"ExcludeRegexes", func(config *ProgramConfig) { config.ExcludeRegexes[0] = nil }},
+		{"Matches", func(config *ProgramConfig) { config.Matches[0] = "b" }},
+		{"Includes", func(config *ProgramConfig) { config.Includes[0] = "b" }},
+		{"Owners", func(config *ProgramConfig) { config.Owners[0] = 0 }},
+		{"Groups", func(config *ProgramConfig) { config.Groups[0] = 0 }},
+		{"ExcludesOld", func(config *ProgramConfig) { config.ExcludesOld[0] = "b" }},
+		{"ExcludesNew", func(config *ProgramConfig) { config.ExcludesNew[0] = "b" }},
+		{"MapsOld", func(config *ProgramConfig) { config.MapsOld[0].To = "c" }},
+		{"MapsNew", func(config *ProgramConfig) { config.MapsNew[0].To = "c" }},
+		{"Transforms", func(config *ProgramConfig) { config.Transforms[0].Replacement = "b" }},
+		{"EntryOwner", func(config *ProgramConfig) { config.EntryOwner.ID = 0 }},
+		{"EntryGroup", func(config *ProgramConfig) { config.EntryGroup.ID = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newConfig()
+			prog := NewProgram(afero.NewMemMapFs(), nil, nil, nil, nil, config)
+
+			tt.change(config)
+
+			require.Equal(t, NewProgram(afero.NewMemMapFs(), nil, nil, nil, nil, newConfig()).config, prog.config)
+		})
+	}
+}
+
+// Expectation: A single program should be usable by multiple goroutines at once.
+func Test_Program_ConcurrentUse_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SkipErrors: true})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+
+	for i := range 10 {
+		wg.Add(3) //nolint:mnd
+		go func() {
+			defer wg.Done()
+			errs <- prog.Create(t.Context(), "/src", fmt.Sprintf("/out%d.tar.gz", i), nil)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := prog.Diff(t.Context(), "/old.tar.gz", "/src", fmt.Sprintf("/diff%d.tar.gz", i), nil)
+			if errors.Is(err, ErrDiffsFound) {
+				err = nil
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- prog.List(t.Context(), "/old.tar.gz", true, nil)
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+// Expectation: The 'create' subcommand should error when given an invalid tar format.
+func Test_CLI_CreateCommand_InvalidTarFormat_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/some/input/file.txt", []byte("test"), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, nil, nil)
+	cmd.SetArgs([]string{"create", "/some/input", "/some/output.tar.gz", "--tar-format=v7"})
+
+	require.ErrorIs(t, cmd.Execute(), errInvalidTarFormat)
+}
+
+// Expectation: The 'convert' subcommand should strip a content tarball into a treeball.
+func Test_CLI_ConvertCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/backup.tar", createTar([]string{"./b/x.txt", "./a.txt"}), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"convert", "/backup.tar", "/out.tar.gz", "--exclude=a.txt"})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "b/\nb/x.txt\n", stdoutBuf.String())
+}
+
+// Expectation: The 'normalize' subcommand should rewrite a foreign tarball into a canonical one.
+func Test_CLI_NormalizeCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"./b/x.txt", "./a.txt"}), 0o644)
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"normalize", "/in.tar.gz", "/out.tar.gz"})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "a.txt\nb/\nb/x.txt\n", stdoutBuf.String())
+}
+
+// Expectation: The 'hash' subcommand should print the same digest for a tree and its tarball.
+func Test_CLI_HashCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a/", "a/b.txt"}), 0o644)
+	_ = afero.WriteFile(fs, "/tree/a/b.txt", []byte("b"), 0o644)
+
+	var tarBuf, treeBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &tarBuf, nil)
+	cmd.SetArgs([]string{"hash", "/input.tar.gz"})
+	require.NoError(t, cmd.Execute())
+
+	cmd = newRootCmd(t.Context(), fs, &treeBuf, nil)
+	cmd.SetArgs([]string{"hash", "/tree"})
+	require.NoError(t, cmd.Execute())
+
+	require.Len(t, tarBuf.String(), 65)
+	require.Equal(t, tarBuf.String(), treeBuf.String())
+}
+
+// Expectation: The 'show' subcommand should print the comment of an annotated tarball.
+func Test_CLI_ShowCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/tree/a.txt", []byte("a"), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, nil, nil)
+	cmd.SetArgs([]string{"create", "/tree", "/out.tar.gz", "--comment=first"})
+	require.NoError(t, cmd.Execute())
+
+	var stdoutBuf bytes.Buffer
+
+	cmd = newRootCmd(t.Context(), fs, &stdoutBuf, nil)
+	cmd.SetArgs([]string{"show", "/out.tar.gz"})
+
+	require.NoError(t, cmd.Execute())
+	require.Contains(t, stdoutBuf.String(), "comment: first\n")
+}
+
+// Expectation: The 'diff' subcommand should reject mappings not in the FROM=>TO format.
+func Test_CLI_DiffCommand_InvalidMap_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	_ = afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644)
+	_ = afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt"}), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, nil, nil)
+	cmd.SetArgs([]string{"diff", "/old.tar.gz", "/new.tar.gz", "--map-old=mnt/disk1"})
+
+	require.ErrorIs(t, cmd.Execute(), errInvalidPathMapping)
+}
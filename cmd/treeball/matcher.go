@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// PatternSyntax is the dialect of the exclude (and include) patterns.
+type PatternSyntax int
+
+const (
+	// PatternSyntaxDoublestar matches the patterns in 'doublestar' format,
+	// anchored to the root of the tree, with the first matching one deciding.
+	PatternSyntaxDoublestar PatternSyntax = iota
+
+	// PatternSyntaxGitIgnore matches the patterns as lines of a .gitignore file
+	// at the root of the tree, with negation ("!") and the last matching deciding.
+	PatternSyntaxGitIgnore
+
+	// PatternSyntaxRsync matches the patterns as rules of an rsync filter file,
+	// with "+ " (include) and "- " (exclude) prefixes and the first matching deciding.
+	PatternSyntaxRsync
+)
+
+var errInvalidPatternSyntax = errors.New("invalid pattern syntax")
+
+// parsePatternSyntax returns the [PatternSyntax] for a name (as for --pattern-syntax).
+func parsePatternSyntax(name string) (PatternSyntax, error) {
+	switch name {
+	case "", "doublestar":
+		return PatternSyntaxDoublestar, nil
+	case "gitignore":
+		return PatternSyntaxGitIgnore, nil
+	case "rsync":
+		return PatternSyntaxRsync, nil
+	default:
+		return PatternSyntaxDoublestar, fmt.Errorf("%w: %q (expected doublestar, gitignore or rsync)", errInvalidPatternSyntax, name)
+	}
+}
+
+// Matcher matches the (relative, slash-separated) paths of a tree against a
+// list of patterns, as parsed in the respective [PatternSyntax].
+type Matcher interface {
+	// Match returns the pattern (as given) deciding about a path, if any, and
+	// whether that matches the path (false for any negated or include rules).
+	Match(path string, isDir bool) (string, bool, error)
+}
+
+// newMatcher returns the [Matcher] of the patterns in a [PatternSyntax].
+// Any invalid patterns are only reported upon matching, as with doublestar.
+func newMatcher(syntax PatternSyntax, patterns []string) Matcher {
+	switch syntax {
+	case PatternSyntaxGitIgnore:
+		return newGitIgnoreMatcher(patterns)
+	case PatternSyntaxRsync:
+		return newRsyncMatcher(patterns)
+	default:
+		return doublestarMatcher(patterns)
+	}
+}
+
+// doublestarMatcher is the [Matcher] of [PatternSyntaxDoublestar].
+type doublestarMatcher []string
+
+func (m doublestarMatcher) Match(path string, isDir bool) (string, bool, error) {
+	return matchExclude(path, isDir, m)
+}
+
+// gitIgnoreMatcher is the [Matcher] of [PatternSyntaxGitIgnore].
+type gitIgnoreMatcher []gitIgnorePattern
+
+// gitIgnorePattern is a rule of a [gitIgnoreMatcher], along with its line (as given).
+type gitIgnorePattern struct {
+	gitIgnoreRule
+
+	line string
+}
+
+func newGitIgnoreMatcher(patterns []string) gitIgnoreMatcher {
+	m := make(gitIgnoreMatcher, 0, len(patterns))
+
+	for _, p := range patterns {
+		if rule, ok := parseGitIgnoreLine(p); ok {
+			m = append(m, gitIgnorePattern{gitIgnoreRule: rule, line: p})
+		}
+	}
+
+	return m
+}
+
+func (m gitIgnoreMatcher) Match(path string, isDir bool) (string, bool, error) {
+	var decided *gitIgnorePattern
+
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	for i, rule := range m {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		matched, err := doublestar.Match(rule.pattern, path)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid exclude pattern: %w", err)
+		}
+		if matched {
+			decided = &m[i]
+		}
+	}
+
+	if decided == nil {
+		return "", false, nil
+	}
+
+	return decided.line, !decided.negate, nil
+}
+
+// rsyncRule is a single parsed rule of an rsync filter (or exclude) file.
+type rsyncRule struct {
+	line    string // Line of the rule (as given)
+	pattern string // Pattern in 'doublestar' format (relative to the root of the tree)
+	include bool   // Rule includes the matched paths ("+ " prefix)
+	dirOnly bool   // Rule only matches directories ("/" suffix)
+}
+
+// rsyncMatcher is the [Matcher] of [PatternSyntaxRsync].
+type rsyncMatcher []rsyncRule
+
+func newRsyncMatcher(patterns []string) rsyncMatcher {
+	m := make(rsyncMatcher, 0, len(patterns))
+
+	for _, p := range patterns {
+		if rule, ok := parseRsyncLine(p); ok {
+			m = append(m, rule)
+		}
+	}
+
+	return m
+}
+
+func (m rsyncMatcher) Match(path string, isDir bool) (string, bool, error) {
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	for _, rule := range m {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		matched, err := doublestar.Match(rule.pattern, path)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid exclude pattern: %w", err)
+		}
+		if matched {
+			return rule.line, !rule.include, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// parseRsyncLine converts a line of an rsync filter (or exclude) file into a
+// rule, for the "+ " and "- " prefixes (any other lines are excluding patterns).
+// The returned boolean is false for lines not containing any pattern.
+func parseRsyncLine(line string) (rsyncRule, bool) {
+	rule := rsyncRule{line: line}
+
+	line = strings.TrimSuffix(line, "\r")
+
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return rule, false
+	}
+
+	if rest, ok := strings.CutPrefix(line, "+ "); ok {
+		rule.include, line = true, rest
+	} else if rest, ok := strings.CutPrefix(line, "- "); ok {
+		line = rest
+	}
+
+	// A trailing "/***" matches a directory as well as all of its contents.
+	contents := false
+	if rest, ok := strings.CutSuffix(line, "/***"); ok {
+		contents, line = true, rest
+	}
+
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		rule.dirOnly, line = true, rest
+	}
+
+	// A leading separator anchors the pattern to the root of the tree,
+	// otherwise it is matched against the end of the paths (at any level).
+	anchored := strings.HasPrefix(line, "/")
+	line = strings.TrimLeft(line, "/")
+
+	if line == "" {
+		return rule, false
+	}
+
+	rule.pattern = line
+	if !anchored {
+		rule.pattern = "**/" + line
+	}
+
+	if contents {
+		rule.pattern += "{,/**}"
+		rule.dirOnly = false
+	}
+
+	return rule, true
+}
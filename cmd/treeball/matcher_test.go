@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The names should be parsed into their respective syntaxes, rejecting unknown ones.
+func Test_parsePatternSyntax_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected PatternSyntax
+		ok       bool
+	}{
+		{"", PatternSyntaxDoublestar, true},
+		{"doublestar", PatternSyntaxDoublestar, true},
+		{"gitignore", PatternSyntaxGitIgnore, true},
+		{"rsync", PatternSyntaxRsync, true},
+		{"regex", PatternSyntaxDoublestar, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			syntax, err := parsePatternSyntax(tt.name)
+			if !tt.ok {
+				require.ErrorIs(t, err, errInvalidPatternSyntax)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, syntax)
+		})
+	}
+}
+
+// Expectation: The lines from the table should be parsed into their respective rules.
+func Test_parseRsyncLine_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected rsyncRule
+		ok       bool
+	}{
+		{"Empty line", "", rsyncRule{}, false},
+		{"Comment line", "# comment", rsyncRule{}, false},
+		{"Semicolon comment", "; comment", rsyncRule{}, false},
+		{"Lone slash", "/", rsyncRule{}, false},
+		{"Unanchored file", "*.log", rsyncRule{pattern: "**/*.log"}, true},
+		{"Exclude prefix", "- *.log", rsyncRule{pattern: "**/*.log"}, true},
+		{"Include prefix", "+ *.log", rsyncRule{pattern: "**/*.log", include: true}, true},
+		{"Unanchored dir", "cache/", rsyncRule{pattern: "**/cache", dirOnly: true}, true},
+		{"Anchored", "/build", rsyncRule{pattern: "build"}, true},
+		{"Contents", "/build/***", rsyncRule{pattern: "build{,/**}"}, true},
+		{"Carriage return", "a.txt\r", rsyncRule{pattern: "**/a.txt"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := parseRsyncLine(tt.line)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				tt.expected.line = tt.line
+				require.Equal(t, tt.expected, rule)
+			}
+		})
+	}
+}
+
+// Expectation: The paths should be matched as per the semantics of the respective syntax.
+func Test_newMatcher_Match_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		syntax   PatternSyntax
+		patterns []string
+		path     string
+		isDir    bool
+		pattern  string
+		matched  bool
+	}{
+		{"doublestar anchored", PatternSyntaxDoublestar, []string{"*.log"}, "a/b.log", false, "", false},
+		{"doublestar match", PatternSyntaxDoublestar, []string{"**/*.log"}, "a/b.log", false, "**/*.log", true},
+		{"gitignore unanchored", PatternSyntaxGitIgnore, []string{"*.log"}, "a/b.log", false, "*.log", true},
+		{"gitignore anchored", PatternSyntaxGitIgnore, []string{"/*.log"}, "a/b.log", false, "", false},
+		{"gitignore negated", PatternSyntaxGitIgnore, []string{"*.log", "!keep.log"}, "a/keep.log", false, "!keep.log", false},
+		{"gitignore last decides", PatternSyntaxGitIgnore, []string{"!keep.log", "*.log"}, "keep.log", false, "*.log", true},
+		{"gitignore dir only", PatternSyntaxGitIgnore, []string{"cache/"}, "cache", false, "", false},
+		{"gitignore dir", PatternSyntaxGitIgnore, []string{"cache/"}, "a/cache", true, "cache/", true},
+		{"rsync unanchored", PatternSyntaxRsync, []string{"- *.log"}, "a/b.log", false, "- *.log", true},
+		{"rsync first decides", PatternSyntaxRsync, []string{"+ keep.log", "- *.log"}, "a/keep.log", false, "+ keep.log", false},
+		{"rsync anchored", PatternSyntaxRsync, []string{"/cache/"}, "a/cache", true, "", false},
+		{"rsync contents", PatternSyntaxRsync, []string{"/cache/***"}, "cache/a/b", false, "/cache/***", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, matched, err := newMatcher(tt.syntax, tt.patterns).Match(tt.path, tt.isDir)
+			require.NoError(t, err)
+			require.Equal(t, tt.pattern, pattern)
+			require.Equal(t, tt.matched, matched)
+		})
+	}
+}
+
+// Expectation: Invalid patterns should be reported upon matching, in all syntaxes.
+func Test_newMatcher_Match_InvalidPattern_Error(t *testing.T) {
+	for _, syntax := range []PatternSyntax{PatternSyntaxDoublestar, PatternSyntaxGitIgnore, PatternSyntaxRsync} {
+		_, _, err := newMatcher(syntax, []string{"a["}).Match("a.txt", false)
+		require.ErrorContains(t, err, "invalid exclude pattern")
+	}
+}
+
+// Expectation: The excludes should be applied as rsync filter rules, with the include rules taking precedence.
+func Test_Program_Create_PatternSyntaxRsync_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	for _, p := range []string{"/src/a.log", "/src/keep.log", "/src/x/b.log", "/src/x/c.txt", "/src/cache/d.txt", "/src/x/cache/e.txt"} {
+		require.NoError(t, afero.WriteFile(fs, p, nil, 0o644))
+	}
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{PatternSyntax: PatternSyntaxRsync})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{"+ keep.log", "- *.log", "/cache/"}))
+
+	require.Equal(t, "keep.log\nx\nx/c.txt\nx/cache\nx/cache/e.txt\n", stdoutBuf.String())
+}
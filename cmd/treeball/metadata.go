@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	paxXattrPrefix  = "SCHILY.xattr."            // Prefix of the PAX records of extended attributes (as GNU tar)
+	paxACLAccess    = "SCHILY.acl.access"        // PAX record of the access ACL (as GNU tar)
+	paxACLDefault   = "SCHILY.acl.default"       // PAX record of the default ACL of directories (as GNU tar)
+	xattrACLAccess  = "system.posix_acl_access"  // Extended attribute of the access ACL (on Linux)
+	xattrACLDefault = "system.posix_acl_default" // Extended attribute of the default ACL (on Linux)
+
+	posixACLVersion = 2 // Version of the binary format of ACLs within extended attributes
+)
+
+var (
+	errInvalidACL          = errors.New("invalid ACL")
+	errMetadataUnsupported = errors.New("metadata can only be captured from local directories")
+)
+
+// capturesMetadata returns if any metadata of the entries is to be captured
+// (as set in [ProgramConfig.Xattrs] and [ProgramConfig.ACLs]).
+func (prog *Program) capturesMetadata() bool {
+	return prog.config.Xattrs || prog.config.ACLs
+}
+
+// checkMetadataSupported returns an error wrapping [errMetadataUnsupported] if
+// the metadata of the entries of a tree cannot be captured, as the extended
+// attributes are only ever read from the local filesystem (not from remotes).
+func (prog *Program) checkMetadataSupported(root string) error {
+	fsys := prog.fs
+
+	if rfs, ok := fsys.(*remoteFs); ok {
+		var err error
+		if fsys, _, err = rfs.route("getxattr", root); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := fsys.(*afero.OsFs); !ok {
+		return fmt.Errorf("%w: %s", errMetadataUnsupported, root)
+	}
+
+	return nil
+}
+
+// entryMetadata returns the captured metadata of a file as PAX records, in the
+// format of GNU tar (with --xattrs and --acls), so that these can be restored
+// by it. The ACLs are those stored within the extended attributes (on Linux).
+func (prog *Program) entryMetadata(p string) (map[string]string, error) {
+	attrs, err := listXattrs(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extended attributes: %w", err)
+	}
+
+	var records map[string]string
+
+	for name, value := range attrs {
+		var key, record string
+
+		switch name {
+		case xattrACLAccess, xattrACLDefault:
+			if !prog.config.ACLs {
+				continue
+			}
+
+			if record, err = decodePosixACL(value); err != nil {
+				return nil, fmt.Errorf("failed to decode %s of %q: %w", name, p, err)
+			}
+
+			key = paxACLAccess
+			if name == xattrACLDefault {
+				key = paxACLDefault
+			}
+		default:
+			if !prog.config.Xattrs {
+				continue
+			}
+
+			key, record = paxXattrPrefix+name, string(value)
+		}
+
+		if records == nil {
+			records = make(map[string]string)
+		}
+		records[key] = record
+	}
+
+	return records, nil
+}
+
+// tarMetadata returns the PAX records of a tar header holding any metadata
+// that is captured (as with [Program.entryMetadata]), or nil if there are none.
+func (prog *Program) tarMetadata(hdr *tar.Header) map[string]string {
+	var records map[string]string
+
+	for key, record := range hdr.PAXRecords {
+		switch {
+		case key == paxACLAccess || key == paxACLDefault:
+			if !prog.config.ACLs {
+				continue
+			}
+		case strings.HasPrefix(key, paxXattrPrefix):
+			if !prog.config.Xattrs {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if records == nil {
+			records = make(map[string]string)
+		}
+		records[key] = record
+	}
+
+	return records
+}
+
+// metadataRecord returns the record of a path along with its metadata (see
+// [recordPath]), in a canonical form, so that the records of the same path
+// only ever differ by their metadata (for the detection of metadata drift).
+func metadataRecord(p string, records map[string]string) string {
+	if len(records) == 0 {
+		return p
+	}
+
+	var b strings.Builder
+
+	b.WriteString(p)
+
+	for i, key := range slices.Sorted(maps.Keys(records)) {
+		if i == 0 {
+			b.WriteByte('\x00')
+		} else {
+			b.WriteByte('\n')
+		}
+
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(strconv.QuoteToASCII(records[key]))
+	}
+
+	return b.String()
+}
+
+// decodePosixACL returns the text form of a POSIX ACL (as of "getfacl -c",
+// but comma-separated with numeric IDs, as GNU tar) from its binary form as
+// stored within the extended attributes (see [xattrACLAccess]).
+func decodePosixACL(data []byte) (string, error) {
+	const headerSize, entrySize = 4, 8
+
+	if len(data) < headerSize || (len(data)-headerSize)%entrySize != 0 {
+		return "", fmt.Errorf("%w: unexpected size of %d bytes", errInvalidACL, len(data))
+	}
+
+	if version := binary.LittleEndian.Uint32(data); version != posixACLVersion {
+		return "", fmt.Errorf("%w: unsupported version %d", errInvalidACL, version)
+	}
+
+	var entries []string
+
+	for data = data[headerSize:]; len(data) > 0; data = data[entrySize:] {
+		tag := binary.LittleEndian.Uint16(data)
+		perm := binary.LittleEndian.Uint16(data[2:])
+		id := strconv.FormatUint(uint64(binary.LittleEndian.Uint32(data[4:])), 10)
+
+		var entry string
+
+		switch tag {
+		case 0x01: //nolint:mnd // ACL_USER_OBJ
+			entry = "user::"
+		case 0x02: //nolint:mnd // ACL_USER
+			entry = "user:" + id + ":"
+		case 0x04: //nolint:mnd // ACL_GROUP_OBJ
+			entry = "group::"
+		case 0x08: //nolint:mnd // ACL_GROUP
+			entry = "group:" + id + ":"
+		case 0x10: //nolint:mnd // ACL_MASK
+			entry = "mask::"
+		case 0x20: //nolint:mnd // ACL_OTHER
+			entry = "other::"
+		default:
+			return "", fmt.Errorf("%w: unknown tag %#x", errInvalidACL, tag)
+		}
+
+		entries = append(entries, entry+aclPerms(perm))
+	}
+
+	return strings.Join(entries, ","), nil
+}
+
+// aclPerms returns the text form of the permissions of an ACL entry (as "rwx").
+func aclPerms(perm uint16) string {
+	b := []byte("---")
+
+	if perm&4 != 0 { //nolint:mnd
+		b[0] = 'r'
+	}
+	if perm&2 != 0 { //nolint:mnd
+		b[1] = 'w'
+	}
+	if perm&1 != 0 {
+		b[2] = 'x'
+	}
+
+	return string(b)
+}
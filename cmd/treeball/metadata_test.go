@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to encode a POSIX ACL in its binary form.
+func encodePosixACL(version uint32, entries ...[3]uint32) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, version)
+
+	for _, e := range entries {
+		data = binary.LittleEndian.AppendUint16(data, uint16(e[0]))
+		data = binary.LittleEndian.AppendUint16(data, uint16(e[1]))
+		data = binary.LittleEndian.AppendUint32(data, e[2])
+	}
+
+	return data
+}
+
+// Expectation: The binary ACLs should be decoded into their text form.
+func Test_decodePosixACL_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{"minimal", encodePosixACL(2, [3]uint32{0x01, 6, 0}, [3]uint32{0x04, 4, 0}, [3]uint32{0x20, 4, 0}), "user::rw-,group::r--,other::r--", false},
+		{"named", encodePosixACL(2, [3]uint32{0x01, 7, 0}, [3]uint32{0x02, 5, 1000}, [3]uint32{0x04, 5, 0}, [3]uint32{0x08, 1, 100}, [3]uint32{0x10, 7, 0}, [3]uint32{0x20, 0, 0}), "user::rwx,user:1000:r-x,group::r-x,group:100:--x,mask::rwx,other::---", false},
+		{"version", encodePosixACL(1, [3]uint32{0x01, 6, 0}), "", true},
+		{"tag", encodePosixACL(2, [3]uint32{0x40, 6, 0}), "", true},
+		{"truncated", encodePosixACL(2, [3]uint32{0x01, 6, 0})[:10], "", true},
+		{"empty", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodePosixACL(tt.data)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidACL)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The records should be canonical, with their paths recovered as such.
+func Test_metadataRecord_Success(t *testing.T) {
+	require.Equal(t, "a.txt", metadataRecord("a.txt", nil))
+
+	record := metadataRecord("a.txt", map[string]string{
+		paxXattrPrefix + "user.b": "2\x00",
+		paxACLAccess:              "user::rw-",
+		paxXattrPrefix + "user.a": "1",
+	})
+
+	require.Equal(t, "a.txt\x00SCHILY.acl.access=\"user::rw-\"\nSCHILY.xattr.user.a=\"1\"\nSCHILY.xattr.user.b=\"2\\x00\"", record)
+	require.Equal(t, "a.txt", recordPath(record))
+}
+
+// Expectation: Only the PAX records of the captured metadata should be returned.
+func Test_Program_tarMetadata_Success(t *testing.T) {
+	hdr := &tar.Header{PAXRecords: map[string]string{
+		paxXattrPrefix + "user.a": "1",
+		paxACLAccess:              "user::rw-",
+		"comment":                 "x",
+	}}
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{Xattrs: true})
+	require.Equal(t, map[string]string{paxXattrPrefix + "user.a": "1"}, prog.tarMetadata(hdr))
+
+	prog = NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{ACLs: true})
+	require.Equal(t, map[string]string{paxACLAccess: "user::rw-"}, prog.tarMetadata(hdr))
+
+	require.Nil(t, prog.tarMetadata(&tar.Header{}))
+}
+
+// Expectation: The paths differing only by their metadata should be reported as drift.
+func Test_Program_Diff_MetadataDrift_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createForeignTar([]*tar.Header{
+		{Name: "a.txt", Typeflag: tar.TypeReg, PAXRecords: map[string]string{paxXattrPrefix + "user.tag": "old"}},
+		{Name: "b.txt", Typeflag: tar.TypeReg, PAXRecords: map[string]string{paxACLAccess: "user::rw-"}},
+		{Name: "c.txt", Typeflag: tar.TypeReg},
+		{Name: "d.txt", Typeflag: tar.TypeReg},
+	}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createForeignTar([]*tar.Header{
+		{Name: "a.txt", Typeflag: tar.TypeReg, PAXRecords: map[string]string{paxXattrPrefix + "user.tag": "new"}},
+		{Name: "b.txt", Typeflag: tar.TypeReg, PAXRecords: map[string]string{paxACLAccess: "user::rwx"}},
+		{Name: "c.txt", Typeflag: tar.TypeReg, PAXRecords: map[string]string{paxXattrPrefix + "user.tag": "new"}},
+		{Name: "e.txt", Typeflag: tar.TypeReg},
+	}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Xattrs: true})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "~~~ a.txt\n~~~ c.txt\n--- d.txt\n+++ e.txt\n", stdoutBuf.String())
+	require.Equal(t, []string{"~~~/a.txt", "~~~/c.txt", "---/d.txt", "+++/e.txt"}, readTarNames(t, fs, "/diff.tar.gz"))
+
+	stdoutBuf.Reset()
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{ACLs: true})
+	_, err = prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "~~~ b.txt\n--- d.txt\n+++ e.txt\n", stdoutBuf.String())
+}
+
+// Expectation: The metadata should not be captured from trees other than local ones.
+func Test_Program_Create_MetadataUnsupported_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Xattrs: true})
+	require.ErrorIs(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil), errMetadataUnsupported)
+
+	_, err := fs.Stat("/out.tar.gz")
+	require.Error(t, err)
+}
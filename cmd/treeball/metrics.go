@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// metric is a single metric of a [Progress], as exposed in the Prometheus format.
+type metric struct {
+	name  string
+	kind  string // Type of the metric (counter or gauge)
+	help  string
+	value func(p *Progress) float64
+}
+
+// metrics are all metrics of a [Progress], as exposed in the Prometheus format.
+var metrics = []metric{
+	{"treeball_entries_read_total", "counter", "Entries read from any sources (walked or from tarballs).",
+		func(p *Progress) float64 { return float64(p.entries.Load()) }},
+	{"treeball_diffs_found_total", "counter", "Differences found between any sources.",
+		func(p *Progress) float64 { return float64(p.diffs.Load()) }},
+	{"treeball_written_bytes_total", "counter", "Bytes written to any output files.",
+		func(p *Progress) float64 { return float64(p.written.Load()) }},
+	{"treeball_spilled_bytes_total", "counter", "Bytes spilled to temporary files by external sorting.",
+		func(p *Progress) float64 { return float64(p.spilled.Load()) }},
+	{"treeball_start_time_seconds", "gauge", "Start time of the process since the unix epoch in seconds.",
+		func(p *Progress) float64 { return float64(p.start.Unix()) }},
+}
+
+// writeMetrics writes all metrics of a [Progress] in the Prometheus text format.
+func writeMetrics(w io.Writer, p *Progress) error {
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", m.name, m.help, m.name, m.kind, m.name, m.value(p)); err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
+		}
+	}
+
+	return nil
+}
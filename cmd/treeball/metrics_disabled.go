@@ -0,0 +1,15 @@
+//go:build minimal || no_metrics
+
+package main
+
+import "errors"
+
+const featureMetrics = false
+
+var errMetricsDisabled = errors.New("metrics are not compiled into this build")
+
+// startMetrics is a stub for builds without the metrics feature compiled in.
+// It always returns an error, as the metrics cannot be served in such a build.
+func startMetrics(_ string, _ *Progress) (string, func(), error) {
+	return "", nil, errMetricsDisabled
+}
@@ -0,0 +1,56 @@
+//go:build !minimal && !no_metrics
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const featureMetrics = true
+
+const (
+	metricsPath            = "/metrics"
+	metricsContentType     = "text/plain; version=0.0.4; charset=utf-8"
+	metricsHeaderTimeout   = 5 * time.Second
+	metricsShutdownTimeout = 5 * time.Second
+)
+
+// startMetrics serves the metrics of the [Progress] in the Prometheus format
+// over HTTP (at /metrics), so that long-running operations can be monitored
+// like any other backup component. The listen address is in the form of
+// [net.Listen] (e.g. ":9090"), and is established before returning, so that
+// any failures to listen surface right away.
+//
+// The actual address listened on is returned (e.g. for a port of 0), along
+// with a function that needs to be called to stop serving the metrics.
+func startMetrics(listen string, progress *Progress) (string, func(), error) {
+	ln, err := net.Listen("tcp", listen) //nolint:noctx
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", metricsContentType)
+		_ = writeMetrics(w, progress)
+	})
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: metricsHeaderTimeout}
+
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+		defer cancel()
+
+		_ = srv.Shutdown(ctx)
+	}
+
+	return ln.Addr().String(), stop, nil
+}
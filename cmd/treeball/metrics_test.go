@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: All metrics should be written in the Prometheus text format.
+func Test_writeMetrics_Success(t *testing.T) {
+	_, progress := withProgress(t.Context())
+	progress.addEntry()
+	progress.addEntry()
+	progress.addDiff()
+	progress.addWritten(2048)
+	progress.addSpilled(4096)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeMetrics(&buf, progress))
+
+	out := buf.String()
+	require.Contains(t, out, "# TYPE treeball_entries_read_total counter\ntreeball_entries_read_total 2\n")
+	require.Contains(t, out, "treeball_diffs_found_total 1\n")
+	require.Contains(t, out, "treeball_written_bytes_total 2048\n")
+	require.Contains(t, out, "treeball_spilled_bytes_total 4096\n")
+	require.Contains(t, out, "# TYPE treeball_start_time_seconds gauge\n")
+}
+
+// Expectation: The metrics should not be written to a failing writer.
+func Test_writeMetrics_Write_Error(t *testing.T) {
+	_, progress := withProgress(t.Context())
+
+	require.Error(t, writeMetrics(errorWriter{}, progress))
+}
+
+// Expectation: The metrics should be served over HTTP, reflecting the live progress.
+func Test_startMetrics_Success(t *testing.T) {
+	if !featureMetrics {
+		t.Skip("metrics are not compiled into this build")
+	}
+
+	_, progress := withProgress(t.Context())
+	progress.addDiff()
+
+	addr, stop, err := startMetrics("127.0.0.1:0", progress)
+	require.NoError(t, err)
+	defer stop()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://"+addr+"/metrics", nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain"))
+	require.Contains(t, string(body), "treeball_diffs_found_total 1\n")
+}
+
+// Expectation: A failure to listen should be returned right away.
+func Test_startMetrics_Listen_Error(t *testing.T) {
+	_, progress := withProgress(t.Context())
+
+	_, _, err := startMetrics("invalid:address:0", progress)
+	require.Error(t, err)
+}
+
+// Expectation: The written bytes of the output files should be counted.
+func Test_Progress_WrittenBytes_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	ctx, progress := withProgress(t.Context())
+	require.NoError(t, prog.Create(ctx, "/src", "/out.tar.gz", nil))
+
+	info, err := fs.Stat("/out.tar.gz")
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), progress.written.Load())
+}
+
+// Expectation: The metrics command-line flag should serve the metrics during the command.
+func Test_CLI_MetricsListen_Success(t *testing.T) {
+	if !featureMetrics {
+		t.Skip("metrics are not compiled into this build")
+	}
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	var stderrBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, &stderrBuf)
+	cmd.SetArgs([]string{"list", "/input.tar.gz", "--metrics-listen", "127.0.0.1:0", "--no-pager"})
+
+	require.NoError(t, cmd.Execute())
+	require.Regexp(t, `^serving metrics at http://127\.0\.0\.1:\d+/metrics\n$`, stderrBuf.String())
+}
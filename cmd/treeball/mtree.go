@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// mtreeMagic are the first bytes of any mtree specification (as of mtree(5)).
+var mtreeMagic = []byte("#mtree")
+
+var errInvalidMtree = errors.New("invalid mtree specification")
+
+// mtreeEscape encodes a path for an mtree specification, as with vis(3), so
+// that any whitespace, non-printable and special characters (such as those
+// of comments and keywords) are encoded as backslash-escaped octal bytes.
+func mtreeEscape(p string) string {
+	var sb strings.Builder
+
+	for i := range len(p) {
+		c := p[i]
+
+		if c <= ' ' || c >= 0x7f || c == '\\' || c == '#' || c == '=' || c == '*' || c == '?' || c == '[' {
+			fmt.Fprintf(&sb, "\\%03o", c)
+
+			continue
+		}
+
+		sb.WriteByte(c)
+	}
+
+	return sb.String()
+}
+
+// mtreeUnescape decodes a path of an mtree specification, as with unvis(3),
+// decoding any backslash-escaped octal bytes and the common C-style escapes.
+func mtreeUnescape(p string) (string, error) {
+	if !strings.Contains(p, "\\") {
+		return p, nil
+	}
+
+	var sb strings.Builder
+
+	for i := 0; i < len(p); i++ {
+		if p[i] != '\\' {
+			sb.WriteByte(p[i])
+
+			continue
+		}
+
+		if i+3 < len(p) && isOctal(p[i+1]) && isOctal(p[i+2]) && isOctal(p[i+3]) {
+			c, _ := strconv.ParseUint(p[i+1:i+4], 8, 8)
+			sb.WriteByte(byte(c))
+			i += 3
+
+			continue
+		}
+
+		if i+1 >= len(p) {
+			return "", fmt.Errorf("%w: %q (dangling escape)", errInvalidMtree, p)
+		}
+
+		i++
+
+		switch p[i] {
+		case 's':
+			sb.WriteByte(' ')
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		default:
+			sb.WriteByte(p[i])
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// formatMtreeEntry returns the line of an entry for an mtree specification, with
+// its full path (relative to the root, as "./path") and any recorded metadata.
+func formatMtreeEntry(entry archiveEntry) string {
+	var sb strings.Builder
+
+	sb.WriteString(mtreeEscape("./" + strings.TrimSuffix(entry.Path, "/")))
+	sb.WriteString(" type=")
+	sb.WriteString(entry.Type)
+
+	if entry.Size != nil {
+		fmt.Fprintf(&sb, " size=%d", *entry.Size)
+	}
+
+	if entry.ModTime != nil {
+		fmt.Fprintf(&sb, " time=%d.%09d", entry.ModTime.Unix(), entry.ModTime.Nanosecond())
+	}
+
+	return sb.String()
+}
+
+// isMtreeFile returns if the file at a path is an mtree specification (rather
+// than a tarball), as detected from its first bytes (see [Program.hasFileMagic]).
+func (prog *Program) isMtreeFile(p string) bool {
+	return prog.hasFileMagic(p, mtreeMagic)
+}
+
+// mtreePathStream returns a stream of the paths of an mtree specification, in
+// the same form as those of a tarball (with directories carrying a trailing
+// slash), skipping any paths excluded as with [Program.tarPathStream].
+//
+// Both the full-path form (as written by "treeball list --format=mtree" and
+// libarchive) and the classic hierarchical form (as written by mtree(8), with
+// ".." ascending out of directories) are read, along with any /set and /unset
+// defaults of the entries' types.
+func (prog *Program) mtreePathStream(ctx context.Context, p string, sort bool, excludes []string) (<-chan string, <-chan error) {
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	matcher := prog.excludeMatcher(excludes)
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		f, err := prog.fs.Open(p)
+		if err != nil {
+			errs <- fmt.Errorf("failed to open input file: %w", err)
+
+			return
+		}
+		defer f.Close()
+
+		err = readMtree(contextReader{ctx: ctx, r: f}, func(entry string) error {
+			if excluded, err := prog.isExcluded(entry, strings.HasSuffix(entry, "/"), matcher); err != nil {
+				return fmt.Errorf("failed to check for exclusion: %w", err)
+			} else if excluded {
+				return nil
+			}
+
+			select {
+			case paths <- entry:
+			case <-ctx.Done():
+				return fmt.Errorf("failed to stream from mtree: %w", ctx.Err())
+			}
+
+			progress.addEntry()
+
+			return nil
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	if !sort {
+		return paths, errs
+	}
+
+	return extsortStringsFunc(ctx, paths, errs, prog.extSortConfig, prog.comparePaths)
+}
+
+// readMtree reads an mtree specification from r, calling fn with the path of
+// every entry (relative to the root, with directories carrying a trailing slash).
+func readMtree(r io.Reader, fn func(entry string) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20) //nolint:mnd
+
+	defaultType := ""
+	cwd := "."
+
+	var lineNo int
+	var pending string
+
+	for sc.Scan() {
+		lineNo++
+
+		line := pending + sc.Text()
+		pending = ""
+
+		if cont, ok := strings.CutSuffix(line, "\\"); ok {
+			pending = cont + " "
+
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "/set":
+			for _, kw := range fields[1:] {
+				if v, ok := strings.CutPrefix(kw, "type="); ok {
+					defaultType = v
+				}
+			}
+
+			continue
+
+		case "/unset":
+			for _, kw := range fields[1:] {
+				if kw == "type" || kw == "all" {
+					defaultType = ""
+				}
+			}
+
+			continue
+
+		case "..":
+			if cwd == "." {
+				return fmt.Errorf("%w: line %d: ascending above the root", errInvalidMtree, lineNo)
+			}
+			cwd = path.Dir(cwd)
+
+			continue
+		}
+
+		name, err := mtreeUnescape(fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		typ := defaultType
+		for _, kw := range fields[1:] {
+			if v, ok := strings.CutPrefix(kw, "type="); ok {
+				typ = v
+			}
+		}
+
+		fullPath := strings.Contains(name, "/")
+
+		entry := path.Clean(name)
+		if !fullPath {
+			entry = path.Join(cwd, name)
+		}
+
+		if entry == ".." || strings.HasPrefix(entry, "../") || path.IsAbs(entry) {
+			return fmt.Errorf("%w: line %d: %q is outside of the root", errInvalidMtree, lineNo, name)
+		}
+
+		if !fullPath && typ == "dir" {
+			cwd = entry
+		}
+
+		if entry == "." {
+			continue
+		}
+
+		if typ == "dir" {
+			entry += "/"
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read mtree: %w", err)
+	}
+
+	return nil
+}
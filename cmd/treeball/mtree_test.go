@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to read all entries of an mtree specification.
+func readMtreeEntries(t *testing.T, spec string) ([]string, error) {
+	t.Helper()
+
+	var entries []string
+
+	err := readMtree(strings.NewReader(spec), func(entry string) error {
+		entries = append(entries, entry)
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// Expectation: Paths should be escaped for mtree specifications and unescaped again.
+func Test_mtreeEscape_Table(t *testing.T) {
+	tests := []struct {
+		path    string
+		escaped string
+	}{
+		{"a.txt", "a.txt"},
+		{"My Movie (1979).mkv", "My\\040Movie\\040(1979).mkv"},
+		{"tab\there", "tab\\011here"},
+		{"hash#equals=", "hash\\043equals\\075"},
+		{"back\\slash", "back\\134slash"},
+		{"glob*?[", "glob\\052\\077\\133"},
+		{"ümlaut", "\\303\\274mlaut"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			require.Equal(t, tt.escaped, mtreeEscape(tt.path))
+
+			unescaped, err := mtreeUnescape(tt.escaped)
+			require.NoError(t, err)
+			require.Equal(t, tt.path, unescaped)
+		})
+	}
+}
+
+// Expectation: The C-style escapes of other tooling should be unescaped as well.
+func Test_mtreeUnescape_Table(t *testing.T) {
+	tests := []struct {
+		escaped string
+		want    string
+		wantErr bool
+	}{
+		{"a\\sb", "a b", false},
+		{"a\\tb", "a\tb", false},
+		{"a\\\\b", "a\\b", false},
+		{"dangling\\", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.escaped, func(t *testing.T) {
+			got, err := mtreeUnescape(tt.escaped)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidMtree)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The entries of both the full-path and the classic form should be read.
+func Test_readMtree_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want []string
+	}{
+		{
+			"Full paths",
+			"#mtree\n./a.txt type=file size=0\n./b type=dir\n./b/c\\040d.txt type=file\n",
+			[]string{"a.txt", "b/", "b/c d.txt"},
+		},
+		{
+			"Classic form",
+			"#mtree\n/set type=file uid=0\n. type=dir\n    a.txt size=1\nb type=dir\n    c.txt\n    d type=dir\n        e.txt \\\n            size=2\n    ..\n..\nf.txt\n",
+			[]string{"a.txt", "b/", "b/c.txt", "b/d/", "b/d/e.txt", "f.txt"},
+		},
+		{
+			"Comments and unset",
+			"#mtree\n# comment\n\n/set type=dir\nx\n..\n/unset all\ny type=link\n",
+			[]string{"x/", "y"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readMtreeEntries(t, tt.spec)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: Specifications with entries outside of the root should be rejected.
+func Test_readMtree_OutsideRoot_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"Ascending above root", "#mtree\n..\n"},
+		{"Parent path", "#mtree\n./../etc/passwd type=file\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readMtreeEntries(t, tt.spec)
+			require.ErrorIs(t, err, errInvalidMtree)
+		})
+	}
+}
+
+// Expectation: An archive should be listed as an mtree specification, which is read back the same.
+func Test_Program_List_Mtree_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"z.txt", "a b/", "a b/c.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{ListFormat: ListMtree})
+	require.NoError(t, prog.List(t.Context(), "/archive.tar.gz", true, nil))
+
+	require.Equal(t, "#mtree\n./a\\040b type=dir\n./a\\040b/c.txt type=file size=0\n./z.txt type=file size=0\n", stdoutBuf.String())
+
+	entries, err := readMtreeEntries(t, stdoutBuf.String())
+	require.NoError(t, err)
+	require.Equal(t, []string{"a b/", "a b/c.txt", "z.txt"}, entries)
+}
+
+// Expectation: An mtree specification should be compared against a directory as a diff source.
+func Test_Program_Diff_Mtree_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/spec", []byte("#mtree\n. type=dir\na.txt type=file\nb type=dir\n    c.txt type=file\n..\n"), 0o644))
+	require.NoError(t, fs.MkdirAll("/data/b", 0o755))
+	require.NoError(t, afero.WriteFile(fs, "/data/b/c.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/data/d.txt", nil, 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	_, err := prog.Diff(t.Context(), "/spec", "/data", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- a.txt\n+++ d.txt\n", stdoutBuf.String())
+}
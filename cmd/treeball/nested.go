@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// nestedArchiveExtensions are the extensions of the nested archives walked as
+// directories with [ProgramConfig.DescendArchives] (matched case-insensitively).
+var nestedArchiveExtensions = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+var errVirtualEntry = errors.New("entry of a nested archive")
+
+// virtualEntry is an [fs.DirEntry] of a nested archive walked as a directory,
+// or of one of its entries, neither of which exists as such on the filesystem
+// (so that no metadata of them can be captured, nor are they ever special).
+type virtualEntry struct {
+	name  string
+	isDir bool
+}
+
+func (e virtualEntry) Name() string {
+	return e.name
+}
+
+func (e virtualEntry) IsDir() bool {
+	return e.isDir
+}
+
+func (e virtualEntry) Type() fs.FileMode {
+	if e.isDir {
+		return fs.ModeDir
+	}
+
+	return 0
+}
+
+func (e virtualEntry) Info() (fs.FileInfo, error) {
+	return nil, fmt.Errorf("failed to stat %q: %w", e.name, errVirtualEntry)
+}
+
+// isVirtualEntry returns if an entry of a walk is a [virtualEntry].
+func isVirtualEntry(d fs.DirEntry) bool {
+	_, ok := d.(virtualEntry)
+
+	return ok
+}
+
+// isNestedArchive returns if a file is a nested archive, as by its name.
+func isNestedArchive(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, ext := range nestedArchiveExtensions {
+		if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// descendArchive passes a nested archive encountered during a walk (see
+// [Program.walkTree]) as a directory to the emit function, followed by its
+// entries under it (as if the archive was a directory of them), in the order
+// of a filesystem walk. The entries are subject to the same excludes and
+// maximum depth as those of the walk, but nested archives within them are
+// not descended into any further. Unreadable archives are either skipped (with
+// [ProgramConfig.SkipErrors]) or returned as an error.
+func (prog *Program) descendArchive(ctx context.Context, archive string, relPath string, d fs.DirEntry, matcher Matcher, emit func(relPath string, d fs.DirEntry) error) error {
+	entries, err := prog.nestedArchivePaths(ctx, archive)
+	if err != nil {
+		if prog.config.SkipErrors {
+			return prog.skipEntry(ctx, archive, d, err)
+		}
+
+		return fmt.Errorf("failed to descend into archive %q: %w", archive, err)
+	}
+
+	if err := emit(relPath, virtualEntry{name: d.Name(), isDir: true}); errors.Is(err, filepath.SkipDir) {
+		return nil // The archive is a file to the walk, which would skip its siblings.
+	} else if err != nil {
+		return err
+	}
+
+	var skipped string // Directory of the archive of which the contents are skipped.
+
+	for _, entry := range entries {
+		if skipped != "" && strings.HasPrefix(entry, skipped) {
+			continue
+		}
+
+		isDir := strings.HasSuffix(entry, "/")
+		entryPath := filepath.Join(relPath, filepath.FromSlash(strings.TrimSuffix(entry, "/")))
+
+		if excluded, err := prog.isExcluded(entryPath, isDir, matcher); err != nil {
+			return fmt.Errorf("failed to check for exclusion: %w", err)
+		} else if excluded {
+			if isDir {
+				skipped = entry
+			}
+
+			continue
+		}
+
+		if err := emit(entryPath, virtualEntry{name: path.Base(entry), isDir: isDir}); errors.Is(err, filepath.SkipDir) {
+			skipped = entry
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nestedArchivePaths returns the paths of the entries of a nested archive,
+// which is either a tarball or a zip archive (see [Program.isZipFile]), in the
+// order of a filesystem walk (see [compareTarOrder]), with any duplicates
+// removed and any missing parent directories added. The paths are held in
+// memory, as nested archives are expected to be of moderate size.
+func (prog *Program) nestedArchivePaths(ctx context.Context, archive string) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	// The entries are read unfiltered, as the filters apply to their paths within the tree.
+	raw := *prog
+	raw.config = &ProgramConfig{Strict: prog.config.Strict}
+	raw.includes = nil
+
+	var paths <-chan string
+	var errs <-chan error
+
+	if raw.isZipFile(archive) {
+		paths, errs = raw.zipPathStream(ctx, archive, nil)
+	} else {
+		paths, errs = raw.tarPathStream(ctx, archive, false, nil)
+	}
+
+	seen := make(map[string]struct{})
+
+	for p := range paths {
+		seen[p] = struct{}{}
+
+		// Any seen directory has had its parents added already.
+		for dir := path.Dir(strings.TrimSuffix(p, "/")); dir != "."; dir = path.Dir(dir) {
+			if _, ok := seen[dir+"/"]; ok {
+				break
+			}
+			seen[dir+"/"] = struct{}{}
+		}
+	}
+
+	for err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]string, 0, len(seen))
+	for p := range seen {
+		entries = append(entries, p)
+	}
+	slices.SortFunc(entries, compareTarOrder)
+
+	return entries, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: Only the files with the extension of an archive should be nested archives.
+func Test_isNestedArchive_Table(t *testing.T) {
+	require.True(t, isNestedArchive("photos.zip"))
+	require.True(t, isNestedArchive("Photos.ZIP"))
+	require.True(t, isNestedArchive("backup.tar.gz"))
+	require.True(t, isNestedArchive("backup.tgz"))
+	require.True(t, isNestedArchive("backup.tar"))
+	require.False(t, isNestedArchive(".zip"))
+	require.False(t, isNestedArchive("photo.jpg"))
+	require.False(t, isNestedArchive("zip"))
+}
+
+// Expectation: The entries of nested archives should be archived under them, in the order of a walk.
+func Test_Program_Create_DescendArchives_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/set.zip", createZip(t, map[string]string{"x/y.jpg": "jpeg", "x.jpg": "jpeg", "Thumbs.db": "db"}, []string{"x/y.jpg", "x.jpg", "Thumbs.db"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/z/old.tar.gz", createTar([]string{"./b.txt", "./c/"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{DescendArchives: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{"**/Thumbs.db"}))
+
+	want := []string{"a.txt", "set.zip/", "set.zip/x/", "set.zip/x/y.jpg", "set.zip/x.jpg", "z/", "z/old.tar.gz/", "z/old.tar.gz/b.txt", "z/old.tar.gz/c/"}
+	require.Equal(t, want, readTarNames(t, fs, "/out.tar.gz"))
+
+	require.NoError(t, prog.Verify(t.Context(), "/out.tar.gz"))
+}
+
+// Expectation: The entries of nested archives should be compared as those of directories.
+func Test_Program_Diff_DescendArchives_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"set.zip/", "set.zip/a.jpg", "set.zip/b.jpg"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new/set.zip", createZip(t, map[string]string{"a.jpg": "a", "c.jpg": "c"}, []string{"a.jpg", "c.jpg"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{DescendArchives: true})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- set.zip/b.jpg\n+++ set.zip/c.jpg\n", stdoutBuf.String())
+}
+
+// Expectation: The entries of nested archives beyond the maximum depth should be left out.
+func Test_Program_Create_DescendArchives_MaxDepth_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/set.zip", createZip(t, map[string]string{"x/y.jpg": "jpeg", "z.jpg": "jpeg"}, []string{"x/y.jpg", "z.jpg"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{DescendArchives: true, MaxDepth: 2})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	require.Equal(t, []string{"set.zip/", "set.zip/x/", "set.zip/z.jpg"}, readTarNames(t, fs, "/out.tar.gz"))
+}
+
+// Expectation: An unreadable nested archive should fail the walk, or be skipped with a warning.
+func Test_Program_Create_DescendArchives_Corrupt_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/bad.zip", []byte("not a zip archive"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{DescendArchives: true})
+	require.ErrorContains(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil), "failed to descend into archive")
+
+	var stderrBuf bytes.Buffer
+
+	prog = NewProgram(fs, io.Discard, &stderrBuf, nil, nil, &ProgramConfig{DescendArchives: true, SkipErrors: true})
+	require.ErrorIs(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil), ErrEntriesSkipped)
+
+	require.Contains(t, stderrBuf.String(), "skipping")
+	require.Equal(t, []string{"a.txt"}, readTarNames(t, fs, "/out.tar.gz"))
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// tarTypeGNUVolume is the type of the volume label of GNU tar (tar -V), which
+// is not among the types of [archive/tar] (and no entry of the tree at all).
+const tarTypeGNUVolume = 'V'
+
+var (
+	errInvalidTarPath      = errors.New("invalid tar path")
+	errNonCanonicalTarPath = errors.New("non-canonical tar path")
+)
+
+// Normalize rewrites an existing (possibly foreign) tarball into a canonical
+// one, as if it had been created by treeball (see [Program.Recreate]). Any
+// entries are written as zero-byte dummies, just as with [Program.Create].
+//
+// The input parameter specifies the path to the tarball to normalize, the
+// output parameter the path of the tarball file to create. The paths of the
+// entries are cleaned (stripping any leading "./" or "/"), directories carry
+// a trailing slash, any other types of entries (such as symlinks) are written
+// as files, and the entries are written in sorted order, with any missing
+// parent directories added and any duplicate entries removed. The ctx
+// parameter controls early cancellation.
+func (prog *Program) Normalize(ctx context.Context, input string, output string) error {
+	var creationDone bool
+
+	progressFrom(ctx).setPhase("normalizing %s into %s", input, output)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input, output)
+	if err != nil {
+		return err
+	}
+	defer closeRemotes()
+
+	if filepath.Clean(input) == filepath.Clean(output) {
+		return errors.New("failed to normalize: input and output must not be the same file")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	paths, errs := prog.normalizedPathStream(ctx, input)
+
+	out, err := prog.createOutput(ctx, output)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	defer func() {
+		if !creationDone {
+			_ = prog.fs.Remove(output)
+		}
+	}()
+	defer out.Close()
+
+	gw, err := prog.newArchiveWriter(out)
+	if err != nil {
+		return err
+	}
+	defer gw.Close()
+
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	if err := prog.recreateEntries(tw, paths); err != nil {
+		return err
+	}
+
+	for err := range errs {
+		if err != nil {
+			return fmt.Errorf("failure during normalize: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+
+	if prog.config.VerifyWrites {
+		if err := prog.verifyOutput(ctx, output); err != nil {
+			return err
+		}
+	}
+
+	creationDone = true
+
+	return nil
+}
+
+// normalizedPathStream returns a sorted stream of the normalized paths of the
+// entries of a tarball (see [normalizeTarPath]), as consumed by [Program.Normalize].
+func (prog *Program) normalizedPathStream(ctx context.Context, input string) (<-chan string, <-chan error) {
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		f, err := prog.openArchive(input)
+		if err != nil {
+			errs <- fmt.Errorf("failed to open input file: %w", err)
+
+			return
+		}
+		defer f.Close()
+
+		cr := &countingReader{r: contextReader{ctx: ctx, r: f}}
+
+		ar, err := newArchiveReader(cr)
+		if err != nil {
+			errs <- err
+
+			return
+		}
+		defer ar.Close()
+
+		tc := &countingReader{r: ar}
+		tr := newConcatTarReader(tc, false)
+
+		var index int64
+		var prevPath string
+
+		for ; ; index++ {
+			offset, compressedOffset := tc.n.Load(), cr.n.Load()
+
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			} else if err != nil {
+				errs <- fmt.Errorf("failed to stream from tar: %w", &StreamError{
+					Index: index, PrevPath: prevPath,
+					Offset: offset, CompressedOffset: compressedOffset, Err: err,
+				})
+
+				return
+			}
+
+			p, ok, err := normalizeTarPath(hdr)
+			if err != nil {
+				errs <- fmt.Errorf("failed to normalize entry: %w", &StreamError{
+					Index: index, Path: hdr.Name, PrevPath: prevPath,
+					Offset: offset, CompressedOffset: compressedOffset, Err: err,
+				})
+
+				return
+			}
+
+			if ok {
+				select {
+				case paths <- p:
+				case <-ctx.Done():
+					errs <- fmt.Errorf("failed to stream from tar: %w", ctx.Err())
+
+					return
+				}
+			}
+
+			prevPath = hdr.Name
+			progress.addEntry()
+		}
+	}()
+
+	return extsortStrings(ctx, paths, errs, prog.extSortConfig)
+}
+
+// normalizeTarPath returns the cleaned path of the entry of a tar header, with
+// directories carrying a trailing slash (as within tarballs), or false for any
+// entries not representing a path of the tree (such as the root directory "./",
+// or global PAX headers). Paths escaping the root of the tree are an error.
+func normalizeTarPath(hdr *tar.Header) (string, bool, error) {
+	switch hdr.Typeflag {
+	case tar.TypeXGlobalHeader, tarTypeGNUVolume:
+		return "", false, nil
+	}
+
+	isDir := hdr.Typeflag == tar.TypeDir || strings.HasSuffix(hdr.Name, "/")
+
+	p := path.Clean(strings.TrimLeft(hdr.Name, "/"))
+	if p == "." {
+		return "", false, nil
+	}
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", false, fmt.Errorf("%w: %q escapes the root of the tree", errInvalidTarPath, hdr.Name)
+	}
+
+	if isDir {
+		p += "/"
+	}
+
+	return p, true, nil
+}
+
+// tarEntryPath returns the path of the entry of a tar header as streamed from
+// tarballs, which is normalized (see [normalizeTarPath]) to tolerate the
+// conventions of foreign tarballs (such as those of GNU tar), or false for
+// any entries not representing a path of the tree. With [ProgramConfig.Strict],
+// any such entries are rejected as an error instead.
+func (prog *Program) tarEntryPath(hdr *tar.Header) (string, bool, error) {
+	p, ok, err := normalizeTarPath(hdr)
+	if err != nil {
+		return "", false, err
+	}
+
+	if prog.config.Strict && (!ok || p != hdr.Name) {
+		return "", false, fmt.Errorf("%w: %q (see 'treeball normalize')", errNonCanonicalTarPath, hdr.Name)
+	}
+
+	return p, ok, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to create a tarball of the given raw headers.
+func createForeignTar(headers []*tar.Header) []byte {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, hdr := range headers {
+		_ = tw.WriteHeader(hdr)
+	}
+
+	_ = tw.Close()
+	_ = gz.Close()
+
+	return buf.Bytes()
+}
+
+// Expectation: A foreign tarball should be rewritten into a canonical one.
+func Test_Program_Normalize_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createForeignTar([]*tar.Header{
+		{Name: "./", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "./b", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "./b/y.txt", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "/a.txt", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "./c/d/link", Typeflag: tar.TypeSymlink, Linkname: "../../a.txt"},
+		{Name: "b/./x.txt", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "./b/y.txt", Typeflag: tar.TypeReg, Mode: 0o644},
+	}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Normalize(t.Context(), "/in.tar.gz", "/out.tar.gz"))
+
+	want := []string{"a.txt", "b/", "b/x.txt", "b/y.txt", "c/", "c/d/", "c/d/link"}
+	require.Equal(t, want, readTarNames(t, fs, "/out.tar.gz"))
+	require.Equal(t, "a.txt\nb/\nb/x.txt\nb/y.txt\nc/\nc/d/\nc/d/link\n", stdoutBuf.String())
+
+	require.NoError(t, prog.Verify(t.Context(), "/out.tar.gz"))
+}
+
+// Expectation: A normalized tarball should equal the tarball created of the same tree.
+func Test_Program_Normalize_DiffCreate_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/x.txt", nil, 0o644))
+
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createForeignTar([]*tar.Header{
+		{Name: "./b/x.txt", Typeflag: tar.TypeReg},
+		{Name: "./a.txt", Typeflag: tar.TypeReg},
+	}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Normalize(t.Context(), "/in.tar.gz", "/out.tar.gz"))
+
+	result, err := prog.Diff(t.Context(), "/out.tar.gz", "/src", "", nil)
+	require.NoError(t, err)
+	require.Zero(t, result.ExtraA+result.ExtraB)
+}
+
+// Expectation: An error should be returned for paths escaping the root, with no output left behind.
+func Test_Program_Normalize_EscapingPath_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createForeignTar([]*tar.Header{
+		{Name: "a.txt", Typeflag: tar.TypeReg},
+		{Name: "../etc/passwd", Typeflag: tar.TypeReg},
+	}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	err := prog.Normalize(t.Context(), "/in.tar.gz", "/out.tar.gz")
+	require.ErrorIs(t, err, errInvalidTarPath)
+
+	_, err = fs.Stat("/out.tar.gz")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: An error should be returned when the input would be overwritten by the output.
+func Test_Program_Normalize_SameFile_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Force: true})
+	require.ErrorContains(t, prog.Normalize(t.Context(), "/in.tar.gz", "/in.tar.gz"), "must not be the same file")
+
+	require.Equal(t, []string{"a.txt"}, readTarNames(t, fs, "/in.tar.gz"))
+}
+
+// Expectation: The tar headers should be normalized accordingly.
+func Test_normalizeTarPath_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		typeflag byte
+		want     string
+		wantOk   bool
+		wantErr  bool
+	}{
+		{"a.txt", tar.TypeReg, "a.txt", true, false},
+		{"./a.txt", tar.TypeReg, "a.txt", true, false},
+		{"/a.txt", tar.TypeReg, "a.txt", true, false},
+		{"a//b/../c", tar.TypeReg, "a/c", true, false},
+		{"dir", tar.TypeDir, "dir/", true, false},
+		{"./dir/", tar.TypeDir, "dir/", true, false},
+		{"link", tar.TypeSymlink, "link", true, false},
+		{"./", tar.TypeDir, "", false, false},
+		{"pax_global_header", tar.TypeXGlobalHeader, "", false, false},
+		{"label", tarTypeGNUVolume, "", false, false},
+		{"../a.txt", tar.TypeReg, "", false, true},
+		{"a/../../b", tar.TypeReg, "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := normalizeTarPath(&tar.Header{Name: tt.name, Typeflag: tt.typeflag})
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidTarPath)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantOk, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
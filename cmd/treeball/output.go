@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// stdoutBufferSize is the size of the buffer of the standard output, which is
+// the default capacity of pipes (on Linux), so that one write can fill a pipe.
+const stdoutBufferSize = 64 << 10
+
+// bufferOutput returns a buffered writer to the standard output, as printing
+// millions of paths otherwise costs a write (system call) each, along with the
+// function flushing it, which needs to be called upon completion. Terminals are
+// not buffered, as their output is to be seen as it is printed (and the checks
+// for terminals, such as for the pager and colors, need the file itself).
+func bufferOutput(stdout io.Writer) (io.Writer, func() error) {
+	if isTerminal(stdout) {
+		return stdout, func() error { return nil }
+	}
+
+	bw := &lockedWriter{w: bufio.NewWriterSize(stdout, stdoutBufferSize)}
+
+	return bw, bw.Flush
+}
+
+// lockedWriter is a buffered writer that is safe for concurrent use, as the
+// messages flushing it (see [Program.infof]) can come from other goroutines
+// (such as those of a walk) than the one printing the paths.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	return lw.w.Write(p) //nolint:wrapcheck
+}
+
+// Flush writes any buffered output to the underlying writer.
+func (lw *lockedWriter) Flush() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	return lw.w.Flush() //nolint:wrapcheck
+}
+
+// flushOutput flushes any buffering of the standard output (see [bufferOutput]),
+// for operations printing as they go over a longer time (as when watching), so
+// that their output is not held back until the buffer happens to be full.
+func (prog *Program) flushOutput() error {
+	if f, ok := prog.stdout.(interface{ Flush() error }); ok {
+		return f.Flush() //nolint:wrapcheck
+	}
+
+	return nil
+}
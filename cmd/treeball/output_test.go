@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The output should be held back until flushed, when not a terminal.
+func Test_bufferOutput_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	out, flush := bufferOutput(&buf)
+
+	prog := NewProgram(afero.NewMemMapFs(), out, io.Discard, nil, nil, nil)
+	prog.printPath("a.txt")
+	prog.printPath("b/")
+
+	require.Empty(t, buf.String())
+	require.NoError(t, flush())
+	require.Equal(t, "a.txt\nb/\n", buf.String())
+}
+
+// Expectation: The buffering should be flushed by the program, and be a no-op without any buffering.
+func Test_Program_flushOutput_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	out, _ := bufferOutput(&buf)
+
+	prog := NewProgram(afero.NewMemMapFs(), out, io.Discard, nil, nil, nil)
+	prog.printPath("a.txt")
+
+	require.NoError(t, prog.flushOutput())
+	require.Equal(t, "a.txt\n", buf.String())
+
+	prog = NewProgram(afero.NewMemMapFs(), &buf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.flushOutput())
+}
+
+// Expectation: Any errors of writing the output should be returned once flushing.
+func Test_bufferOutput_Error(t *testing.T) {
+	out, flush := bufferOutput(errorWriter{})
+
+	prog := NewProgram(afero.NewMemMapFs(), out, io.Discard, nil, nil, nil)
+	prog.printPath("a.txt")
+
+	require.Error(t, flush())
+}
+
+// Expectation: The buffered output should be flushed before any messages, so both stay in order in the same file.
+func Test_bufferOutput_Messages_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	out, flush := bufferOutput(&buf)
+
+	prog := NewProgram(afero.NewMemMapFs(), out, &buf, nil, nil, nil)
+	prog.printPath("a.txt")
+	prog.warnf("skipped: %s", "b.txt")
+	prog.printPath("c.txt")
+	prog.infof("done")
+
+	require.NoError(t, flush())
+	require.Equal(t, "a.txt\nwarning: skipped: b.txt\nc.txt\ndone\n", buf.String())
+}
+
+// Expectation: The buffered output should be safe for messages printed concurrently to the paths.
+func Test_bufferOutput_Concurrent_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	out, flush := bufferOutput(&buf)
+
+	prog := NewProgram(afero.NewMemMapFs(), out, io.Discard, nil, nil, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for range 100 {
+			prog.warnf("skipped")
+		}
+	}()
+
+	for range 100 {
+		prog.printPath("a.txt")
+	}
+	<-done
+
+	require.NoError(t, flush())
+	require.Equal(t, strings.Repeat("a.txt\n", 100), buf.String())
+}
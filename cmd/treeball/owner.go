@@ -0,0 +1,186 @@
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	errInvalidOwner         = errors.New("invalid owner")
+	errInvalidEntryMode     = errors.New("invalid entry mode")
+	errOwnershipUnavailable = errors.New("ownership not available")
+)
+
+// EntryOwner is the owner (user or group) to record in the headers of created
+// entries (as for --entry-owner), instead of the anonymous owner of dummy files.
+type EntryOwner struct {
+	Name string // Name of the owner (empty: none recorded)
+	ID   int    // Numeric ID of the owner
+}
+
+// parseEntryOwner returns the [EntryOwner] of an argument in the format of
+// GNU tar's --owner, being either a name (of which the ID is resolved with the
+// lookup function, such as [user.Lookup], into the ID returned by the id function),
+// a numeric ID (recorded without a name), or both a name and an ID as NAME:ID
+// (recorded as given, such as for owners only known to the extracting system).
+func parseEntryOwner[T any](arg string, lookup func(string) (T, error), id func(T) string) (*EntryOwner, error) {
+	if arg == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	name, idStr, hasID := strings.Cut(arg, ":")
+
+	if !hasID {
+		if n, err := strconv.ParseUint(name, 10, 32); err == nil {
+			return &EntryOwner{ID: int(n)}, nil
+		}
+
+		ids, err := parseOwnerIDs([]string{name}, lookup, id)
+		if err != nil {
+			return nil, err
+		}
+
+		return &EntryOwner{Name: name, ID: int(ids[0])}, nil
+	}
+
+	n, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil || name == "" {
+		return nil, fmt.Errorf("%w: %q (expected NAME, ID or NAME:ID)", errInvalidOwner, arg)
+	}
+
+	return &EntryOwner{Name: name, ID: int(n)}, nil
+}
+
+// parseEntryOwners returns the [EntryOwner] of --entry-owner and of --entry-group
+// (nil for any not given), see [parseEntryOwner] for the accepted formats.
+func parseEntryOwners(owner string, group string) (*EntryOwner, *EntryOwner, error) {
+	uid, err := parseEntryOwner(owner, user.Lookup, func(u *user.User) string { return u.Uid })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gid, err := parseEntryOwner(group, user.LookupGroup, func(g *user.Group) string { return g.Gid })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return uid, gid, nil
+}
+
+// parseEntryMode returns the permission bits of an octal mode (as for --mode-file),
+// such as "644" or "0755", or zero for an empty one (keeping the default mode).
+func parseEntryMode(arg string) (int64, error) {
+	if arg == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseUint(arg, 8, 32)
+	if err != nil || n == 0 || n > 0o7777 {
+		return 0, fmt.Errorf("%w: %q (expected non-zero octal permission bits, e.g. 0644)", errInvalidEntryMode, arg)
+	}
+
+	return int64(n), nil
+}
+
+// setEntryOwnership sets the permission bits and owners of a created entry to
+// the [ProgramConfig.FileMode] or [ProgramConfig.DirMode] and to the
+// [ProgramConfig.EntryOwner] and [ProgramConfig.EntryGroup] (each if set).
+func (prog *Program) setEntryOwnership(hdr *tar.Header) {
+	if mode := prog.config.FileMode; mode != 0 && hdr.Typeflag != tar.TypeDir {
+		hdr.Mode = mode
+	}
+
+	if mode := prog.config.DirMode; mode != 0 && hdr.Typeflag == tar.TypeDir {
+		hdr.Mode = mode
+	}
+
+	if owner := prog.config.EntryOwner; owner != nil {
+		hdr.Uid, hdr.Uname = owner.ID, owner.Name
+	}
+
+	if group := prog.config.EntryGroup; group != nil {
+		hdr.Gid, hdr.Gname = group.ID, group.Name
+	}
+}
+
+// parseOwnerIDs returns the numeric IDs of users (or groups), as given either
+// by their IDs or by their names, which are resolved with the lookup function
+// (such as [user.Lookup]) into the ID returned by the id function.
+func parseOwnerIDs[T any](names []string, lookup func(string) (T, error), id func(T) string) ([]uint32, error) {
+	ids := make([]uint32, 0, len(names))
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: empty name", errInvalidOwner)
+		}
+
+		if n, err := strconv.ParseUint(name, 10, 32); err == nil {
+			ids = append(ids, uint32(n))
+
+			continue
+		}
+
+		v, err := lookup(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", errInvalidOwner, name, err)
+		}
+
+		n, err := strconv.ParseUint(id(v), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q has no numeric id (%q)", errInvalidOwner, name, id(v))
+		}
+
+		ids = append(ids, uint32(n))
+	}
+
+	return ids, nil
+}
+
+// parseOwnerFilters returns the user and group IDs of --owner and --group,
+// which are given as either the names or the numeric IDs of users and groups.
+func parseOwnerFilters(owners []string, groups []string) ([]uint32, []uint32, error) {
+	uids, err := parseOwnerIDs(owners, user.Lookup, func(u *user.User) string { return u.Uid })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gids, err := parseOwnerIDs(groups, user.LookupGroup, func(g *user.Group) string { return g.Gid })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return uids, gids, nil
+}
+
+// filtersOwnership returns if the files of walks are filtered by their owner
+// (as set in [ProgramConfig.Owners] and [ProgramConfig.Groups]).
+func (prog *Program) filtersOwnership() bool {
+	return len(prog.config.Owners) > 0 || len(prog.config.Groups) > 0
+}
+
+// matchesOwnership returns if a file is owned by any of the [ProgramConfig.Owners]
+// and any of the [ProgramConfig.Groups] (each unless empty). Filesystems without
+// ownership (such as remotes) cannot be filtered, which is an error.
+func (prog *Program) matchesOwnership(info fs.FileInfo) (bool, error) {
+	uid, gid, ok := ownerIDs(info)
+	if !ok {
+		return false, fmt.Errorf("%w: %q", errOwnershipUnavailable, info.Name())
+	}
+
+	if len(prog.config.Owners) > 0 && !slices.Contains(prog.config.Owners, uid) {
+		return false, nil
+	}
+
+	if len(prog.config.Groups) > 0 && !slices.Contains(prog.config.Groups, gid) {
+		return false, nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to look up the names of a fixed set of users.
+func lookupTestUser(name string) (string, error) {
+	switch name {
+	case "alice":
+		return "1000", nil
+	case "nobody":
+		return "65534", nil
+	case "sid":
+		return "S-1-5-21", nil
+	}
+
+	return "", errors.New("unknown user")
+}
+
+// Expectation: The owners should be resolved by their numeric IDs or names.
+func Test_parseOwnerIDs_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		owners  []string
+		want    []uint32
+		wantErr bool
+	}{
+		{"none", nil, []uint32{}, false},
+		{"numeric", []string{"0", "1001"}, []uint32{0, 1001}, false},
+		{"names", []string{"alice", " nobody "}, []uint32{1000, 65534}, false},
+		{"mixed", []string{"alice", "42"}, []uint32{1000, 42}, false},
+		{"unknown", []string{"mallory"}, nil, true},
+		{"empty", []string{""}, nil, true},
+		{"negative", []string{"-1"}, nil, true},
+		{"non-numeric id", []string{"sid"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOwnerIDs(tt.owners, lookupTestUser, func(id string) string { return id })
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidOwner)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: Filesystems without ownership should not be filtered by owner, but fail.
+func Test_Program_Create_OwnerFilter_Unavailable_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Owners: []uint32{0}})
+	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorIs(t, err, errOwnershipUnavailable)
+
+	exists, _ := afero.Exists(fs, "/out.tar.gz")
+	require.False(t, exists)
+}
+
+// Expectation: Filesystems without ownership should be skipped with --skip-errors.
+func Test_Program_Create_OwnerFilter_Unavailable_SkipErrors_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+	require.NoError(t, fs.MkdirAll("/src/dir", 0o755))
+
+	var stdout, stderr bytes.Buffer
+
+	prog := NewProgram(fs, &stdout, &stderr, nil, nil, &ProgramConfig{Owners: []uint32{0}, SkipErrors: true})
+	require.ErrorIs(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil), ErrEntriesSkipped)
+
+	require.Equal(t, "dir\n", stdout.String())
+	require.Contains(t, stderr.String(), "a.txt")
+}
+
+// Expectation: An unknown owner should be rejected by the command.
+func Test_CLI_CreateCommand_InvalidOwner_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"create", "/src", "/out.tar.gz", "--owner=no-such-user-of-treeball"})
+
+	require.ErrorIs(t, cmd.Execute(), errInvalidOwner)
+}
+
+// Expectation: The entry owners should be parsed as names, IDs or both.
+func Test_parseEntryOwner_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    *EntryOwner
+		wantErr bool
+	}{
+		{"none", "", nil, false},
+		{"name", "alice", &EntryOwner{Name: "alice", ID: 1000}, false},
+		{"numeric", "42", &EntryOwner{ID: 42}, false},
+		{"name and id", "mallory:1234", &EntryOwner{Name: "mallory", ID: 1234}, false},
+		{"unknown", "mallory", nil, true},
+		{"missing name", ":1234", nil, true},
+		{"invalid id", "alice:x", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEntryOwner(tt.arg, lookupTestUser, func(id string) string { return id })
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidOwner)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The entry modes should be parsed as octal permission bits.
+func Test_parseEntryMode_Table(t *testing.T) {
+	tests := []struct {
+		arg     string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"644", 0o644, false},
+		{"0755", 0o755, false},
+		{"1777", 0o1777, false},
+		{"0", 0, true},
+		{"888", 0, true},
+		{"17777", 0, true},
+		{"rw-r--r--", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			got, err := parseEntryMode(tt.arg)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidEntryMode)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The created entries should carry the overridden modes and owners.
+func Test_Program_Create_EntryOwnership_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{
+		FileMode:   0o640,
+		DirMode:    0o750,
+		EntryOwner: &EntryOwner{Name: "media", ID: 1000},
+		EntryGroup: &EntryOwner{ID: 100},
+	})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	f, err := fs.Open("/out.tar.gz")
+	require.NoError(t, err)
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		if hdr.Typeflag == tar.TypeDir {
+			require.Equal(t, int64(0o750), hdr.Mode, hdr.Name)
+		} else {
+			require.Equal(t, int64(0o640), hdr.Mode, hdr.Name)
+		}
+		require.Equal(t, "media", hdr.Uname)
+		require.Equal(t, 1000, hdr.Uid)
+		require.Empty(t, hdr.Gname)
+		require.Equal(t, 100, hdr.Gid)
+	}
+}
+
+// Expectation: An invalid entry mode should be rejected by the command.
+func Test_CLI_CreateCommand_InvalidEntryMode_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"create", "/src", "/out.tar.gz", "--mode-file=rw"})
+
+	require.ErrorIs(t, cmd.Execute(), errInvalidEntryMode)
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// ownerIDs returns the user and group IDs owning a file, or false if these
+// are not available (such as for files of in-memory or remote filesystems).
+func ownerIDs(info fs.FileInfo) (uint32, uint32, bool) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Uid, st.Gid, true
+	}
+
+	return 0, 0, false
+}
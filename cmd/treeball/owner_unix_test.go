@@ -0,0 +1,59 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to create a tree of files owned by the current user.
+func createOwnerTree(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dir"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "b.txt"), nil, 0o644))
+
+	return root
+}
+
+// Expectation: Only the files owned by the given users and groups should be archived, along with all directories.
+func Test_Program_Create_OwnerFilters_Success(t *testing.T) {
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid()) //nolint:gosec
+
+	tests := []struct {
+		name   string
+		owners []uint32
+		groups []uint32
+		want   string
+	}{
+		{"owner", []uint32{uid}, nil, "a.txt\ndir\ndir/b.txt\n"},
+		{"group", nil, []uint32{gid}, "a.txt\ndir\ndir/b.txt\n"},
+		{"any owner", []uint32{uid + 1, uid}, nil, "a.txt\ndir\ndir/b.txt\n"},
+		{"other owner", []uint32{uid + 1}, nil, "dir\n"},
+		{"other group", []uint32{uid}, []uint32{gid + 1}, "dir\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := createOwnerTree(t)
+			output := filepath.Join(t.TempDir(), "out.tar.gz")
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(afero.NewOsFs(), &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Owners: tt.owners, Groups: tt.groups})
+			require.NoError(t, prog.Create(t.Context(), root, output, nil))
+
+			require.Equal(t, tt.want, stdoutBuf.String())
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// setupPager is a convenience wrapper around [startPager] for use by commands.
+// If disabled is true, stdout is returned as-is and no pager is ever started.
+// A pager that fails to start (or exit) is of no relevance to the command, so
+// the output then falls back to just being written to stdout (like with git).
+// Any output of the pager itself on standard error is written to stderr.
+func setupPager(stdout io.Writer, stderr io.Writer, disabled bool) (io.Writer, func()) {
+	if disabled {
+		return stdout, func() {}
+	}
+
+	out, wait, err := startPager(stdout, stderr)
+	if err != nil {
+		return stdout, func() {}
+	}
+
+	return out, func() { _ = wait() }
+}
+
+// isTerminal returns if the given writer is a file representing a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	return term.IsTerminal(int(f.Fd())) //nolint:gosec
+}
@@ -0,0 +1,13 @@
+//go:build minimal || no_pager
+
+package main
+
+import "io"
+
+const featurePager = false
+
+// startPager is a stub for builds without the pager feature compiled in.
+// It always returns stdout as-is, along with a no-op waiting function.
+func startPager(stdout io.Writer, _ io.Writer) (io.Writer, func() error, error) {
+	return stdout, func() error { return nil }, nil
+}
@@ -0,0 +1,75 @@
+//go:build !minimal && !no_pager
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const featurePager = true
+
+const (
+	pagerEnvVar  = "PAGER"
+	pagerDefault = "less"
+
+	lessEnvVar  = "LESS"
+	lessDefault = "FRX" // Quit if one screen, raw control characters, no screen clearing
+)
+
+// startPager pipes the standard output through an interactive pager, much like
+// git does. The pager is taken from the $PAGER environment variable, falling
+// back to "less", and is only started if stdout is a terminal at all. Setting
+// $PAGER to an empty string or "cat" disables the paging behavior entirely.
+//
+// Any output of the pager on standard error is written to stderr.
+// The returned writer is to be used in place of stdout, while the returned
+// function needs to be called upon completion to wait for the pager to exit.
+// If no pager is to be used, stdout is returned along with a no-op function.
+func startPager(stdout io.Writer, stderr io.Writer) (io.Writer, func() error, error) {
+	noop := func() error { return nil }
+
+	if !isTerminal(stdout) {
+		return stdout, noop, nil
+	}
+
+	pager, ok := os.LookupEnv(pagerEnvVar)
+	if !ok {
+		pager = pagerDefault
+	}
+
+	args := strings.Fields(pager)
+	if len(args) == 0 || args[0] == "cat" {
+		return stdout, noop, nil
+	}
+
+	cmd := exec.Command(args[0], args[1:]...) //nolint:gosec,noctx
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if _, ok := os.LookupEnv(lessEnvVar); !ok {
+		cmd.Env = append(os.Environ(), lessEnvVar+"="+lessDefault)
+	}
+
+	pipe, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to establish pager pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start pager: %w", err)
+	}
+
+	return pipe, func() error {
+		_ = pipe.Close()
+
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("failed waiting for pager: %w", err)
+		}
+
+		return nil
+	}, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: No pager should be started when it was disabled.
+func Test_setupPager_Disabled_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	out, closePager := setupPager(&buf, io.Discard, true)
+	require.Same(t, &buf, out)
+
+	closePager()
+}
+
+// Expectation: No pager should be started when the output is not a terminal.
+func Test_startPager_NoTerminal_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	out, wait, err := startPager(&buf, io.Discard)
+	require.NoError(t, err)
+	require.Same(t, &buf, out)
+	require.NoError(t, wait())
+}
+
+// Expectation: A regular file or non-file writer should not be detected as a terminal.
+func Test_isTerminal_NoTerminal_Success(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.False(t, isTerminal(f))
+	require.False(t, isTerminal(&bytes.Buffer{}))
+	require.False(t, isTerminal(nil))
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/afero"
+)
+
+// walkAheadPerWorker is the amount of directories that may be read ahead of a
+// [ParallelWalker]'s walk (per worker), bounding the memory held by the reads.
+const walkAheadPerWorker = 64
+
+// ParallelWalker is a [Walker] reading the directories concurrently.
+//
+// The walk is in the same (lexical, depth-first) order as [filepath.WalkDir],
+// with fn called serially, but any directories are read ahead of the walk by
+// up to Workers goroutines, as soon as their parent directory was walked. This
+// hides the latency of reading directories on high-latency (e.g. network)
+// filesystems, where it is otherwise the dominant cost of walking.
+//
+// With PathOrder, the entries of each directory are instead ordered as if the
+// directories carried a trailing slash (e.g. "a-b" before "a/"), so that the
+// walk is in the sorted order of the (slash-separated) paths it streams.
+type ParallelWalker struct {
+	FS        afero.Fs
+	Workers   int  // Directories read concurrently (at most; 0: one)
+	PathOrder bool // Order the walk by the paths of directories with a trailing slash
+}
+
+// aheadDir is a directory of a [ParallelWalker]'s walk, possibly read ahead.
+type aheadDir struct {
+	path     string
+	claimed  atomic.Bool   // The read was taken on (by either a worker or the walk)
+	done     chan struct{} // Closed once read by a worker (if taken on by one)
+	entries  []fs.DirEntry
+	err      error
+	panicked *workerPanic // Panic of the worker reading it (passed on to the walk)
+}
+
+// walkAhead holds the state of a single [ParallelWalker.WalkDir] operation.
+type walkAhead struct {
+	fs        afero.Fs
+	pathOrder bool
+	queue     chan *aheadDir // Directories to read ahead (in order of the walk)
+	tokens    chan struct{}  // Directories read ahead, but not yet walked
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// WalkDir is a method that walks the file tree with the semantics of [filepath.WalkDir].
+func (w ParallelWalker) WalkDir(root string, fn fs.WalkDirFunc) error {
+	workers := max(1, w.Workers)
+
+	wa := &walkAhead{
+		fs:        w.FS,
+		pathOrder: w.PathOrder,
+		queue:     make(chan *aheadDir, workers*walkAheadPerWorker),
+		tokens:    make(chan struct{}, workers*walkAheadPerWorker),
+		stop:      make(chan struct{}),
+	}
+
+	for range workers {
+		wa.wg.Add(1)
+		go wa.work()
+	}
+
+	defer func() {
+		close(wa.stop)
+		wa.wg.Wait()
+	}()
+
+	info, err := lstatIfPossible(w.FS, root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = wa.walk(root, fs.FileInfoToDirEntry(info), nil, fn)
+	}
+
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+
+	return err
+}
+
+// work reads the queued directories, until the walk is stopped.
+func (wa *walkAhead) work() {
+	defer wa.wg.Done()
+
+	for {
+		select {
+		case wa.tokens <- struct{}{}:
+		case <-wa.stop:
+			return
+		}
+
+		var dir *aheadDir
+
+		select {
+		case dir = <-wa.queue:
+		case <-wa.stop:
+			return
+		}
+
+		if !dir.claimed.CompareAndSwap(false, true) {
+			<-wa.tokens // Already read (or discarded) by the walk.
+
+			continue
+		}
+
+		wa.readAhead(dir)
+	}
+}
+
+// readAhead reads a directory ahead of the walk, recovering any panic of the
+// read for the walk to panic with (see [walkAhead.read]), as the goroutine of
+// the walk is the one of which the panics are recovered (see [recoverWorker]).
+func (wa *walkAhead) readAhead(dir *aheadDir) {
+	defer close(dir.done)
+	defer func() {
+		if r := recover(); r != nil {
+			dir.panicked = &workerPanic{value: r, stack: debug.Stack()}
+		}
+	}()
+
+	dir.entries, dir.err = wa.readDir(dir.path)
+}
+
+// read returns the entries of a directory, either as read ahead or reading
+// them directly (if not yet taken on by a worker, or not read ahead at all).
+func (wa *walkAhead) read(path string, dir *aheadDir) ([]fs.DirEntry, error) {
+	if dir == nil || dir.claimed.CompareAndSwap(false, true) {
+		return wa.readDir(path)
+	}
+
+	<-dir.done
+	<-wa.tokens
+
+	if dir.panicked != nil {
+		panic(dir.panicked)
+	}
+
+	return dir.entries, dir.err
+}
+
+// readDir reads the entries of a directory, in the order they are walked in.
+func (wa *walkAhead) readDir(path string) ([]fs.DirEntry, error) {
+	entries, err := readDirEntries(wa.fs, path)
+
+	if wa.pathOrder {
+		slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+			return strings.Compare(entryPathName(a), entryPathName(b))
+		})
+	}
+
+	return entries, err
+}
+
+// entryPathName returns the name of an entry as it sorts within paths, which
+// is with a trailing slash for directories (as these are followed by contents).
+func entryPathName(d fs.DirEntry) string {
+	if d.IsDir() {
+		return d.Name() + "/"
+	}
+
+	return d.Name()
+}
+
+// discard releases a directory that is not walked (e.g. skipped by fn).
+func (wa *walkAhead) discard(dir *aheadDir) {
+	if dir == nil || dir.claimed.CompareAndSwap(false, true) {
+		return
+	}
+
+	<-dir.done
+	<-wa.tokens
+}
+
+func (wa *walkAhead) walk(path string, d fs.DirEntry, dir *aheadDir, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		wa.discard(dir)
+
+		if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+			err = nil
+		}
+
+		return err
+	}
+
+	entries, err := wa.read(path, dir)
+	if err != nil {
+		// Second call, to report the directory reading error (as [filepath.WalkDir] does).
+		if err := fn(path, d, err); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				err = nil
+			}
+
+			return err
+		}
+	}
+
+	subdirs := make([]*aheadDir, len(entries))
+
+	for i, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		subdirs[i] = &aheadDir{path: filepath.Join(path, entry.Name()), done: make(chan struct{})}
+
+		select {
+		case wa.queue <- subdirs[i]:
+		default: // Read by the walk itself, once it gets there.
+		}
+	}
+
+	for i, entry := range entries {
+		if err := wa.walk(filepath.Join(path, entry.Name()), entry, subdirs[i], fn); err != nil {
+			for _, skipped := range subdirs[i+1:] {
+				wa.discard(skipped)
+			}
+
+			if errors.Is(err, filepath.SkipDir) {
+				return nil // Skipping the remaining entries of the directory.
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readDirEntries reads the entries of a directory, sorted by their names. Any
+// entries read before an error are returned along with it (as [os.ReadDir]).
+func readDirEntries(fsys afero.Fs, path string) ([]fs.DirEntry, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	defer f.Close()
+
+	var entries []fs.DirEntry
+
+	if rdf, ok := f.(fs.ReadDirFile); ok {
+		entries, err = rdf.ReadDir(-1)
+	} else {
+		var infos []fs.FileInfo
+
+		infos, err = f.Readdir(-1)
+		for _, info := range infos {
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return entries, err //nolint:wrapcheck
+}
+
+// lstatIfPossible returns the [fs.FileInfo] of a path, without following a
+// symbolic link at the path, where the filesystem supports it (as [os.Lstat]).
+func lstatIfPossible(fsys afero.Fs, path string) (fs.FileInfo, error) {
+	if lst, ok := fsys.(afero.Lstater); ok {
+		info, _, err := lst.LstatIfPossible(path)
+
+		return info, err //nolint:wrapcheck
+	}
+
+	return fsys.Stat(path) //nolint:wrapcheck
+}
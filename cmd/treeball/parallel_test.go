@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to create a wide and deep tree (on the given filesystem).
+func createWalkTree(t *testing.T, fsys afero.Fs, root string) {
+	t.Helper()
+
+	for i := range 8 {
+		for j := range 8 {
+			dir := filepath.Join(root, fmt.Sprintf("d%d", i), fmt.Sprintf("e%d", j), "f")
+			require.NoError(t, fsys.MkdirAll(dir, 0o755))
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(dir, "file.txt"), nil, 0o644))
+		}
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(root, fmt.Sprintf("d%d.txt", i)), nil, 0o644))
+	}
+}
+
+// Expectation: The walk should be in the same order as the serial walk, regardless of the workers.
+func Test_ParallelWalker_Order_Table(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	createWalkTree(t, fs, "/src")
+
+	want := collectWalk(t, AferoWalker{FS: fs}, "/src")
+
+	for _, workers := range []int{0, 1, 2, 4, 16} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			got := collectWalk(t, ParallelWalker{FS: fs, Workers: workers}, "/src")
+			require.Equal(t, want, got)
+		})
+	}
+}
+
+// Expectation: The walk should be in the same order, also with more directories than may be read ahead.
+func Test_ParallelWalker_Wide_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	for i := range 3 * walkAheadPerWorker {
+		require.NoError(t, afero.WriteFile(fs, fmt.Sprintf("/src/d%03d/sub/file.txt", i), nil, 0o644))
+	}
+
+	want := collectWalk(t, AferoWalker{FS: fs}, "/src")
+	got := collectWalk(t, ParallelWalker{FS: fs, Workers: 1}, "/src")
+
+	require.Len(t, got, 1+3*3*walkAheadPerWorker)
+	require.Equal(t, want, got)
+}
+
+// Expectation: The skipping of directories and siblings should follow the semantics of the serial walk.
+func Test_ParallelWalker_SkipDir_Success(t *testing.T) {
+	root := t.TempDir()
+	createWalkTree(t, afero.NewOsFs(), root)
+
+	walk := func(walker Walker) []string {
+		var got []string
+
+		require.NoError(t, walker.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			require.NoError(t, err)
+
+			rel, err := filepath.Rel(root, path)
+			require.NoError(t, err)
+			got = append(got, filepath.ToSlash(rel))
+
+			switch rel {
+			case "d1", filepath.Join("d2", "e3"):
+				return filepath.SkipDir // Skipping the directory's contents.
+			case filepath.Join("d4", "e5", "f", "file.txt"):
+				return filepath.SkipDir // Skipping the remaining entries of the parent.
+			}
+
+			return nil
+		}))
+
+		return got
+	}
+
+	want := walk(OSWalker{})
+	require.NotContains(t, want, "d1/e0")
+
+	got := walk(ParallelWalker{FS: afero.NewOsFs(), Workers: 4})
+	require.Equal(t, want, got)
+}
+
+// A helper filesystem for tests to simulate a panic upon opening a specific path.
+type panickingOpenFs struct {
+	afero.Fs
+
+	path string
+}
+
+// A helper function for tests to simulate a panic upon opening a specific path.
+func (fsys panickingOpenFs) Open(name string) (afero.File, error) {
+	if name == fsys.path {
+		panic("simulated panic")
+	}
+
+	return fsys.Fs.Open(name)
+}
+
+// Expectation: A panic of a worker should be passed on to the goroutine of the walk.
+func Test_ParallelWalker_WorkerPanic_Error(t *testing.T) {
+	baseFs := afero.NewMemMapFs()
+	createWalkTree(t, baseFs, "/src")
+
+	walker := ParallelWalker{FS: panickingOpenFs{Fs: baseFs, path: "/src/d5/e5"}, Workers: 4}
+
+	defer func() {
+		r := recover()
+		require.NotNil(t, r)
+		require.Equal(t, "simulated panic", fmt.Sprint(r))
+	}()
+
+	_ = walker.WalkDir("/src", func(path string, d fs.DirEntry, err error) error {
+		return nil
+	})
+}
+
+// Expectation: The walk should stop upon a SkipAll, without an error returned.
+func Test_ParallelWalker_SkipAll_Success(t *testing.T) {
+	baseFs := afero.NewMemMapFs()
+	createWalkTree(t, baseFs, "/src")
+
+	var got []string
+
+	require.NoError(t, ParallelWalker{FS: baseFs, Workers: 4}.WalkDir("/src", func(path string, d fs.DirEntry, err error) error {
+		got = append(got, path)
+
+		if path == "/src/d0/e1" {
+			return filepath.SkipAll
+		}
+
+		return nil
+	}))
+
+	require.Equal(t, []string{"/src", "/src/d0", "/src/d0/e0", "/src/d0/e0/f", "/src/d0/e0/f/file.txt", "/src/d0/e1"}, got)
+}
+
+// Expectation: An error returned by the walk function should stop the walk and be returned.
+func Test_ParallelWalker_WalkFunc_Error(t *testing.T) {
+	baseFs := afero.NewMemMapFs()
+	createWalkTree(t, baseFs, "/src")
+
+	errWalk := errors.New("simulated walk failure")
+
+	err := ParallelWalker{FS: baseFs, Workers: 4}.WalkDir("/src", func(path string, d fs.DirEntry, err error) error {
+		if path == "/src/d3" {
+			return errWalk
+		}
+
+		return nil
+	})
+
+	require.ErrorIs(t, err, errWalk)
+}
+
+// Expectation: A missing root should be reported to the walk function (as the serial walk does).
+func Test_ParallelWalker_MissingRoot_Error(t *testing.T) {
+	baseFs := afero.NewMemMapFs()
+
+	err := ParallelWalker{FS: baseFs, Workers: 4}.WalkDir("/missing", func(path string, d fs.DirEntry, err error) error {
+		require.Nil(t, d)
+
+		return err
+	})
+
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: The parallel walker should be used with walk workers, yielding the same paths.
+func Test_Program_fsPathStream_WalkWorkers_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	createWalkTree(t, fs, "/src")
+
+	collect := func(prog *Program) []string {
+		paths, errs := prog.fsPathStream(t.Context(), "/src", "", false, nil)
+
+		var got []string
+		for p := range paths {
+			got = append(got, p)
+		}
+
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		return got
+	}
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{WalkWorkers: 4})
+	require.IsType(t, ParallelWalker{}, prog.fsWalker)
+
+	want := collect(NewProgram(fs, io.Discard, io.Discard, nil, nil, nil))
+	require.Equal(t, want, collect(prog))
+}
+
+// Expectation: The serial walker should be used when following symbolic links.
+func Test_NewProgram_WalkWorkers_FollowSymlinks_Success(t *testing.T) {
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{WalkWorkers: 4, FollowSymlinks: true})
+	require.Equal(t, OSWalker{FollowSymlinks: true}, prog.fsWalker)
+}
+
+// Expectation: The walk should be in the sorted order of the paths with PathOrder, regardless of the workers.
+func Test_ParallelWalker_PathOrder_Table(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a/x.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/a-b", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b", nil, 0o644))
+
+	for _, workers := range []int{0, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			got := collectWalk(t, ParallelWalker{FS: fs, Workers: workers, PathOrder: true}, "/src")
+			require.Equal(t, []string{"./", "a-b", "a.txt", "a/", "a/x.txt", "b"}, got)
+		})
+	}
+}
+
+// Expectation: The paths should be streamed in sorted order without any external sorting with a sorted walk.
+func Test_Program_fsPathStream_SortedWalk_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	createWalkTree(t, fs, "/src")
+
+	require.NoError(t, afero.WriteFile(fs, "/src/d1-x.txt", nil, 0o644))
+
+	collect := func(prog *Program) []string {
+		paths, errs := prog.fsPathStream(t.Context(), "/src", "", true, nil)
+
+		var got []string
+		for p := range paths {
+			got = append(got, p)
+		}
+
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		return got
+	}
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SortedWalk: true})
+	require.Equal(t, ParallelWalker{FS: fs, PathOrder: true}, prog.fsWalker)
+
+	want := collect(NewProgram(fs, io.Discard, io.Discard, nil, nil, nil))
+	require.Equal(t, want, collect(prog))
+}
+
+// Expectation: The walk should still be sorted externally where a sorted walk is not possible.
+func Test_NewProgram_SortedWalk_Fallback_Table(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ProgramConfig
+		want   bool
+	}{
+		{"Sorted", ProgramConfig{SortedWalk: true}, true},
+		{"Not set", ProgramConfig{}, false},
+		{"Follow symlinks", ProgramConfig{SortedWalk: true, FollowSymlinks: true}, false},
+		{"Descend archives", ProgramConfig{SortedWalk: true, DescendArchives: true}, false},
+		{"Ignore case", ProgramConfig{SortedWalk: true, IgnoreCase: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, walksSorted(&tt.config))
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// pathListExtensions are the file extensions of plain text lists of paths.
+var pathListExtensions = []string{".txt", ".lst"}
+
+// pathListPeekSize is the amount of bytes of a list of paths examined for any
+// NUL bytes, as those make it a NUL-delimited list (e.g. of "find -print0").
+const pathListPeekSize = 64 * 1024
+
+// isPathList returns if the file at a path is a plain text list of paths (rather
+// than a tarball), as detected from its extension (see [pathListExtensions]).
+func isPathList(p string) bool {
+	return slices.Contains(pathListExtensions, strings.ToLower(filepath.Ext(p)))
+}
+
+// textPathStream returns a stream of the paths of a plain text list of paths,
+// such as one produced by "find", "rclone lsf" or any other tooling, skipping
+// any paths excluded as with [Program.tarPathStream].
+//
+// The paths are delimited by newlines, or by NUL bytes if there are any (as of
+// "find -print0"). Directories are expected to carry a trailing slash (as with
+// "rclone lsf"), as they cannot be told apart from files otherwise. Any leading
+// "./" or "/" is removed from the paths, and any empty lines, carriage returns
+// (of Windows line endings), and the root itself (".") are ignored.
+func (prog *Program) textPathStream(ctx context.Context, path string, sort bool, excludes []string) (<-chan string, <-chan error) {
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	matcher := prog.excludeMatcher(excludes)
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		f, err := prog.fs.Open(path)
+		if err != nil {
+			errs <- fmt.Errorf("failed to open input file: %w", err)
+
+			return
+		}
+		defer f.Close()
+
+		br := bufio.NewReaderSize(contextReader{ctx: ctx, r: f}, pathListPeekSize)
+
+		delim := byte('\n')
+		if peek, _ := br.Peek(pathListPeekSize); bytes.IndexByte(peek, 0) >= 0 {
+			delim = 0
+		}
+
+		sc := bufio.NewScanner(br)
+		sc.Buffer(nil, 1<<20) //nolint:mnd
+		sc.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.IndexByte(data, delim); i >= 0 {
+				return i + 1, data[:i], nil
+			}
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+
+			return 0, nil, nil
+		})
+
+		for sc.Scan() {
+			p := sc.Text()
+			if delim == '\n' {
+				p = strings.TrimSuffix(p, "\r")
+			}
+
+			p = strings.TrimLeft(strings.TrimPrefix(p, "./"), "/")
+			if p == "" || p == "." {
+				continue
+			}
+
+			if excluded, err := prog.isExcluded(p, strings.HasSuffix(p, "/"), matcher); err != nil {
+				errs <- fmt.Errorf("failed to check for exclusion: %w", err)
+
+				return
+			} else if excluded {
+				continue
+			}
+
+			select {
+			case paths <- p:
+			case <-ctx.Done():
+				errs <- fmt.Errorf("failed to stream from path list: %w", ctx.Err())
+
+				return
+			}
+
+			progress.addEntry()
+		}
+
+		if err := sc.Err(); err != nil {
+			errs <- fmt.Errorf("failed to stream from path list: %w", err)
+		}
+	}()
+
+	if !sort {
+		return paths, errs
+	}
+
+	return extsortStringsFunc(ctx, paths, errs, prog.extSortConfig, prog.comparePaths)
+}
+
+// hasFileMagic returns if the file at a path starts with the given magic bytes.
+// Files with the extension of an archive are never read for this, as they are
+// known to be tarballs, as are any files that cannot be read.
+func (prog *Program) hasFileMagic(p string, magic []byte) bool {
+	if _, ok := trimArchiveExtension(p); ok {
+		return false
+	}
+
+	f, err := prog.fs.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+
+	return bytes.Equal(head, magic)
+}
+
+// isPathSource returns if the file at a path is a source of paths other than
+// a tarball (see [Program.filePathStream]), so without any recorded metadata.
+func (prog *Program) isPathSource(p string) bool {
+	return isPathList(p) || prog.isMtreeFile(p) || prog.isHashdeepFile(p)
+}
+
+// filePathStream returns a stream of the paths of a file source, which is
+// either a plain text list of paths (see [Program.textPathStream]), an mtree
+// specification (see [Program.mtreePathStream]), a hashdeep manifest (see
+// [Program.hashdeepPathStream]) or a tarball otherwise.
+func (prog *Program) filePathStream(ctx context.Context, path string, sort bool, excludes []string) (<-chan string, <-chan error) {
+	switch {
+	case isPathList(path):
+		return prog.textPathStream(ctx, path, sort, excludes)
+	case prog.isMtreeFile(path):
+		return prog.mtreePathStream(ctx, path, sort, excludes)
+	case prog.isHashdeepFile(path):
+		return prog.hashdeepPathStream(ctx, path, excludes)
+	default:
+		return prog.tarPathStream(ctx, path, sort, excludes)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The paths of a list should be streamed, regardless of their delimiters and forms.
+func Test_Program_textPathStream_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"Newlines", "b/\nb/c.txt\na.txt\n"},
+		{"Windows line endings", "b/\r\nb/c.txt\r\na.txt"},
+		{"NUL bytes", "b/\x00b/c.txt\x00a.txt\x00"},
+		{"Find output", ".\n./b/\n./b/c.txt\n\n./a.txt\n"},
+		{"Absolute paths", "/b/\n/b/c.txt\n/a.txt\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/paths.txt", []byte(tt.data), 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+			paths, errs := prog.textPathStream(t.Context(), "/paths.txt", true, nil)
+
+			var got []string
+			for p := range paths {
+				got = append(got, p)
+			}
+
+			for err := range errs {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, []string{"a.txt", "b/", "b/c.txt"}, got)
+		})
+	}
+}
+
+// Expectation: The excludes should be respected for the paths of a list.
+func Test_Program_textPathStream_Excludes_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/paths.lst", []byte("a.txt\nb/\nb/c.txt\n"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.textPathStream(t.Context(), "/paths.lst", false, []string{"b/**"})
+
+	var got []string
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"a.txt"}, got)
+}
+
+// Expectation: Files should only be taken as lists of paths by their extension.
+func Test_isPathList_Table(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/listing.txt", true},
+		{"/listing.LST", true},
+		{"/archive.tar.gz", false},
+		{"/archive.tar", false},
+		{"/spec.mtree", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			require.Equal(t, tt.want, isPathList(tt.path))
+		})
+	}
+}
+
+// Expectation: A list of paths should be compared against an archive as a diff source.
+func Test_Program_Diff_PathList_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b/", "b/c.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/remote.lst", []byte("b/\nb/c.txt\nd.txt\n"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/remote.lst", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- a.txt\n+++ d.txt\n", stdoutBuf.String())
+}
+
+// Expectation: A list of paths should be listed (sorted) as well as converted into JSON Lines.
+func Test_Program_List_PathList_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/paths.txt", []byte("z.txt\nb/\n"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.List(t.Context(), "/paths.txt", true, nil))
+	require.Equal(t, "b/\nz.txt\n", stdoutBuf.String())
+
+	stdoutBuf.Reset()
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{ListFormat: ListJSONL})
+	require.NoError(t, prog.List(t.Context(), "/paths.txt", true, nil))
+	require.Equal(t, "{\"path\":\"b/\",\"type\":\"dir\"}\n{\"path\":\"z.txt\",\"type\":\"file\"}\n", stdoutBuf.String())
+}
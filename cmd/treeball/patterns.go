@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var errInvalidPatternPath = errors.New("invalid path")
+
+// ExplainPatterns prints, for each of the paths, whether it is excluded, and
+// by which of the exclusion mechanisms (the excludes slice, or those configured
+// in the program's [ProgramConfig]), as these are matched during any operation.
+//
+// The paths are relative to the root of a tree (or archive), with directories
+// carrying a trailing slash (e.g. "vendor/"). Any paths not excluded themselves,
+// but within an excluded directory, are pointed out as well, since these are
+// left out of any walks of directories (though not of tarballs, where only the
+// paths themselves are matched). The .gitignore files are not considered.
+func (prog *Program) ExplainPatterns(paths []string, excludes []string) error {
+	matcher := prog.excludeMatcher(excludes)
+
+	for _, p := range paths {
+		line, err := prog.explainPattern(p, matcher)
+		if err != nil {
+			return fmt.Errorf("failed to explain %q: %w", p, err)
+		}
+
+		prog.printPath(line)
+	}
+
+	return nil
+}
+
+// explainPattern returns the line of [Program.ExplainPatterns] for a path.
+func (prog *Program) explainPattern(p string, matcher Matcher) (string, error) {
+	isDir := strings.HasSuffix(p, "/")
+
+	clean := path.Clean(strings.TrimLeft(p, "/"))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: not within a tree", errInvalidPatternPath)
+	}
+
+	by, excluded, err := prog.matchExclusion(clean, isDir, matcher)
+	if err != nil {
+		return "", err
+	} else if excluded {
+		return fmt.Sprintf("%s: excluded by %s", p, by), nil
+	}
+
+	// The parents are matched from the top, as the first excluded one ends the walk.
+	parts := strings.Split(clean, "/")
+	for i := 1; i < len(parts); i++ {
+		parent := strings.Join(parts[:i], "/")
+
+		by, excluded, err := prog.matchExclusion(parent, true, matcher)
+		if err != nil {
+			return "", err
+		} else if excluded {
+			return fmt.Sprintf("%s: not excluded, but within %s/ (excluded by %s, so left out of walks)", p, parent, by), nil
+		}
+	}
+
+	return p + ": not excluded", nil
+}
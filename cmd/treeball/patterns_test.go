@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: Each path should be reported along with the exclusion mechanism matching it.
+func Test_Program_ExplainPatterns_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *ProgramConfig
+		excludes []string
+		path     string
+		want     string
+	}{
+		{"not excluded", nil, []string{"*.tmp"}, "a.txt", "a.txt: not excluded"},
+		{"pattern", nil, []string{"*.tmp", "*.txt"}, "a.txt", "a.txt: excluded by --exclude=*.txt"},
+		{"anchored", nil, []string{"vendor/**"}, "app/vendor/x.go", "app/vendor/x.go: not excluded"},
+		{"doublestar", nil, []string{"**/vendor/**"}, "app/vendor/x.go", "app/vendor/x.go: excluded by --exclude=**/vendor/**"},
+		{"directory only", nil, []string{"cache/"}, "cache", "cache: not excluded"},
+		{"directory", nil, []string{"cache/"}, "cache/", "cache/: excluded by --exclude=cache/"},
+		{"within directory", nil, []string{"cache/"}, "cache/x", "cache/x: not excluded, but within cache/ (excluded by --exclude=cache/, so left out of walks)"},
+		{"ignore case", &ProgramConfig{IgnoreCase: true}, []string{"*.MKV"}, "a.mkv", "a.mkv: excluded by --exclude=*.mkv"},
+		{"regex", &ProgramConfig{ExcludeRegexes: []*regexp.Regexp{regexp.MustCompile(`^cache/$`)}}, nil, "cache/", "cache/: excluded by --exclude-regex=^cache/$"},
+		{"max depth", &ProgramConfig{MaxDepth: 1}, nil, "a/b.txt", "a/b.txt: excluded by --max-depth=1"},
+		{"included", &ProgramConfig{Includes: []string{"**/*.mkv"}}, nil, "a/b.mkv", "a/b.mkv: not excluded"},
+		{"not included", &ProgramConfig{Includes: []string{"**/*.mkv"}}, nil, "a/b.txt", "a/b.txt: excluded by --include (as none matches)"},
+		{"included directory", &ProgramConfig{Includes: []string{"**/*.mkv"}}, nil, "a/", "a/: not excluded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(afero.NewMemMapFs(), &stdoutBuf, io.Discard, nil, nil, tt.config)
+			require.NoError(t, prog.ExplainPatterns([]string{tt.path}, tt.excludes))
+
+			require.Equal(t, tt.want+"\n", stdoutBuf.String())
+		})
+	}
+}
+
+// Expectation: Paths outside of a tree should be rejected.
+func Test_Program_ExplainPatterns_InvalidPath_Error(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	require.ErrorIs(t, prog.ExplainPatterns([]string{"../a.txt"}, nil), errInvalidPatternPath)
+	require.ErrorIs(t, prog.ExplainPatterns([]string{"/"}, nil), errInvalidPatternPath)
+}
+
+// Expectation: Invalid patterns should be reported as an error.
+func Test_Program_ExplainPatterns_InvalidPattern_Error(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	require.ErrorContains(t, prog.ExplainPatterns([]string{"a.txt"}, []string{"a["}), "invalid exclude pattern")
+}
+
+// Expectation: The 'patterns test' subcommand should report the matching patterns of the paths.
+func Test_CLI_PatternsTestCommand_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/excludes.txt", []byte("# comment\n*.tmp\n"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, io.Discard)
+	cmd.SetArgs([]string{"patterns", "test", "--excludes-from=/excludes.txt", "--exclude=vendor/**", "a.tmp", "vendor/", "b.txt"})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "a.tmp: excluded by --exclude=*.tmp\nvendor/: excluded by --exclude=vendor/**\nb.txt: not excluded\n", stdoutBuf.String())
+}
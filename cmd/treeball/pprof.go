@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// Profiling holds the outputs of the profiling of a command (as for --cpuprofile,
+// --memprofile and --trace), so that performance issues on the hardware at hand
+// can be analyzed (with 'go tool pprof' and 'go tool trace') without any custom
+// builds, rather than tuning the sorting and compression options by guesswork.
+type Profiling struct {
+	CPUProfile string // Path to write a CPU profile to (empty: none)
+	MemProfile string // Path to write a heap profile to, upon completion (empty: none)
+	Trace      string // Path to write an execution trace to (empty: none)
+
+	stops []func() error
+}
+
+// Start starts the profiling into the files on fs, which is to be stopped with
+// [Profiling.Stop] once the command has completed (including on any error).
+func (p *Profiling) Start(fs afero.Fs) error {
+	if p.CPUProfile != "" {
+		f, err := fs.Create(p.CPUProfile)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to create cpu profile: %w", err), p.Stop())
+		}
+
+		if err := pprof.StartCPUProfile(f); err != nil {
+			_ = f.Close()
+
+			return errors.Join(fmt.Errorf("failed to start cpu profile: %w", err), p.Stop())
+		}
+
+		p.stops = append(p.stops, func() error {
+			pprof.StopCPUProfile()
+
+			return closeProfile(f, "cpu profile")
+		})
+	}
+
+	if p.Trace != "" {
+		f, err := fs.Create(p.Trace)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to create trace: %w", err), p.Stop())
+		}
+
+		if err := trace.Start(f); err != nil {
+			_ = f.Close()
+
+			return errors.Join(fmt.Errorf("failed to start trace: %w", err), p.Stop())
+		}
+
+		p.stops = append(p.stops, func() error {
+			trace.Stop()
+
+			return closeProfile(f, "trace")
+		})
+	}
+
+	if p.MemProfile != "" {
+		f, err := fs.Create(p.MemProfile)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to create memory profile: %w", err), p.Stop())
+		}
+
+		p.stops = append(p.stops, func() error {
+			runtime.GC() // The statistics are then up to date with the completed command.
+
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				_ = f.Close()
+
+				return fmt.Errorf("failed to write memory profile: %w", err)
+			}
+
+			return closeProfile(f, "memory profile")
+		})
+	}
+
+	return nil
+}
+
+// Stop stops any profiling started with [Profiling.Start], writing its files.
+// It is safe to call more than once, doing nothing but for the first time.
+func (p *Profiling) Stop() error {
+	var errs []error
+
+	for _, stop := range p.stops {
+		errs = append(errs, stop())
+	}
+	p.stops = nil
+
+	return errors.Join(errs...)
+}
+
+// closeProfile closes the file of a profile, returning any error as for its name.
+func closeProfile(f io.Closer, name string) error {
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// stopAfterRun wraps the runs of the command and of all of its subcommands, so
+// that stop is called once any of them has completed (including on a panic).
+// Any error of stop fails an otherwise successful run, but does not replace an
+// error of the run itself (as that is more relevant to its exit code).
+func stopAfterRun(cmd *cobra.Command, stop func() error) {
+	if run := cmd.RunE; run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+			defer func() {
+				if stopErr := stop(); stopErr != nil {
+					if err == nil {
+						err = stopErr
+					} else {
+						fmt.Fprintf(cmd.ErrOrStderr(), "warning: %v\n", stopErr)
+					}
+				}
+			}()
+
+			return run(cmd, args)
+		}
+	}
+
+	for _, sub := range cmd.Commands() {
+		stopAfterRun(sub, stop)
+	}
+}
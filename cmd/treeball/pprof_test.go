@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: All of the profiles should be written once the profiling is stopped.
+func Test_Profiling_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	p := &Profiling{CPUProfile: "/cpu.prof", MemProfile: "/mem.prof", Trace: "/trace.out"}
+	require.NoError(t, p.Start(fs))
+	require.NoError(t, p.Stop())
+
+	for _, path := range []string{"/cpu.prof", "/mem.prof", "/trace.out"} {
+		info, err := fs.Stat(path)
+		require.NoError(t, err, path)
+		require.Positive(t, info.Size(), path)
+	}
+
+	require.NoError(t, p.Stop())
+}
+
+// Expectation: The profiling should not be started into files that cannot be created.
+func Test_Profiling_Create_Error(t *testing.T) {
+	p := &Profiling{CPUProfile: "/cpu.prof"}
+	require.ErrorContains(t, p.Start(afero.NewReadOnlyFs(afero.NewMemMapFs())), "failed to create cpu profile")
+}
+
+// Expectation: Any profiling already started should be stopped when another cannot be.
+func Test_Profiling_PartialStart_Error(t *testing.T) {
+	dir := t.TempDir()
+	fs := afero.NewOsFs()
+
+	p := &Profiling{CPUProfile: filepath.Join(dir, "cpu.prof"), Trace: filepath.Join(dir, "missing", "trace.out")}
+	require.ErrorContains(t, p.Start(fs), "failed to create trace")
+
+	// The profiling of the CPU was stopped, so that it can be started again.
+	p = &Profiling{CPUProfile: filepath.Join(dir, "cpu.prof")}
+	require.NoError(t, p.Start(fs))
+	require.NoError(t, p.Stop())
+}
+
+// Expectation: The profiling should be stopped after a run, failing only an otherwise successful run.
+func Test_stopAfterRun_Table(t *testing.T) {
+	errRun := errors.New("run failed")
+	errStop := errors.New("stop failed")
+
+	tests := []struct {
+		name    string
+		runErr  error
+		stopErr error
+		wantErr error
+		wantOut string
+	}{
+		{"Both succeed", nil, nil, nil, ""},
+		{"Stop fails", nil, errStop, errStop, ""},
+		{"Run fails", errRun, nil, errRun, ""},
+		{"Both fail", errRun, errStop, errRun, "warning: stop failed\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stopped int
+			var stderrBuf bytes.Buffer
+
+			rootCmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			rootCmd.SetErr(&stderrBuf)
+			rootCmd.AddCommand(&cobra.Command{
+				Use: "sub",
+				RunE: func(_ *cobra.Command, _ []string) error {
+					return tt.runErr
+				},
+			})
+			stopAfterRun(rootCmd, func() error {
+				stopped++
+
+				return tt.stopErr
+			})
+			rootCmd.SetArgs([]string{"sub"})
+
+			err := rootCmd.Execute()
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, 1, stopped)
+			require.Equal(t, tt.wantOut, stderrBuf.String())
+		})
+	}
+}
+
+// Expectation: The profiles should be written for the commands given the flags.
+func Test_CLI_Profiling_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	cmd.SetArgs([]string{"list", "/input.tar.gz", "--cpuprofile=/cpu.prof", "--memprofile=/mem.prof", "--trace=/trace.out"})
+	require.NoError(t, cmd.Execute())
+
+	for _, path := range []string{"/cpu.prof", "/mem.prof", "/trace.out"} {
+		info, err := fs.Stat(path)
+		require.NoError(t, err, path)
+		require.Positive(t, info.Size(), path)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var errInvalidProfile = errors.New("invalid profile")
+
+// Profile is a named preset of the options relevant to performance (as for
+// --profile), which are otherwise hard to reason about in their interactions.
+type Profile struct {
+	Name             string // Name of the profile
+	Description      string // Short description of the profile
+	CompressionLevel int    // Level of compression (as for --compression)
+	BlockSize        int    // Block size for compressing (as for --blocksize)
+	BlockCount       int    // Blocks to compress in parallel (as for --blockcount)
+	NumWorkers       int    // Workers for sorting and diffing (as for --workers)
+	ChunkSize        int    // Records per worker before spilling to disk (as for --chunksize)
+}
+
+// Profiles returns all of the profiles, from the fastest to the smallest.
+//
+//nolint:mnd
+func Profiles() []Profile {
+	procs := runtime.GOMAXPROCS(0)
+
+	return []Profile{
+		{
+			Name:             "fast",
+			Description:      "fastest operations, at the expense of archive size and memory",
+			CompressionLevel: gzip.BestSpeed,
+			BlockSize:        1 << 20,
+			BlockCount:       procs * 2,
+			NumWorkers:       max(4, procs),
+			ChunkSize:        500_000,
+		},
+		{
+			Name:             "balanced",
+			Description:      "balance of speed, archive size, and memory",
+			CompressionLevel: 6,
+			BlockSize:        1 << 20,
+			BlockCount:       procs,
+			NumWorkers:       min(4, procs),
+			ChunkSize:        100_000,
+		},
+		{
+			Name:             "small",
+			Description:      "smallest archives and memory footprint, at the expense of speed",
+			CompressionLevel: gzip.BestCompression,
+			BlockSize:        4 << 20,
+			BlockCount:       max(1, procs/2),
+			NumWorkers:       min(2, procs),
+			ChunkSize:        50_000,
+		},
+	}
+}
+
+// parseProfile returns the [Profile] for a profile name (as for --profile).
+func parseProfile(name string) (Profile, error) {
+	for _, p := range Profiles() {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+
+	return Profile{}, fmt.Errorf("%w: %q (expected %s)", errInvalidProfile, name, strings.Join(profileNames(), ", "))
+}
+
+// profileNames returns the names of all of the profiles (see [Profiles]).
+func profileNames() []string {
+	var names []string
+
+	for _, p := range Profiles() {
+		names = append(names, p.Name)
+	}
+
+	return names
+}
+
+// applyProfile sets the flags of a command to the values of the [Profile],
+// except for any flags given explicitly (so that they take precedence), and
+// any flags the command does not have (as not relevant to its operation).
+func applyProfile(cmd *cobra.Command, p Profile) error {
+	values := map[string]int{
+		"compression": p.CompressionLevel,
+		"blocksize":   p.BlockSize,
+		"blockcount":  p.BlockCount,
+		"workers":     p.NumWorkers,
+		"chunksize":   p.ChunkSize,
+	}
+
+	for name, value := range values {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+
+		if err := cmd.Flags().Set(name, strconv.Itoa(value)); err != nil {
+			return fmt.Errorf("failed to apply profile: %w", err)
+		}
+	}
+
+	return nil
+}
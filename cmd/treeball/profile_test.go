@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to return the value of an integer flag of a command.
+func mustGetInt(t *testing.T, cmd *cobra.Command, name string) int {
+	t.Helper()
+
+	v, err := cmd.Flags().GetInt(name)
+	require.NoError(t, err)
+
+	return v
+}
+
+// Expectation: All profiles should be parsed by name (case-insensitively), with valid options.
+func Test_parseProfile_Success(t *testing.T) {
+	for _, p := range Profiles() {
+		got, err := parseProfile(p.Name)
+		require.NoError(t, err)
+		require.Equal(t, p, got)
+
+		require.NotEmpty(t, p.Description)
+		require.GreaterOrEqual(t, p.CompressionLevel, 0)
+		require.LessOrEqual(t, p.CompressionLevel, 9)
+		require.Positive(t, p.BlockSize)
+		require.Positive(t, p.BlockCount)
+		require.Positive(t, p.NumWorkers)
+		require.Positive(t, p.ChunkSize)
+	}
+
+	_, err := parseProfile("FAST")
+	require.NoError(t, err)
+}
+
+// Expectation: An unknown profile should be returned as an error.
+func Test_parseProfile_Error(t *testing.T) {
+	_, err := parseProfile("turbo")
+	require.ErrorIs(t, err, errInvalidProfile)
+}
+
+// Expectation: The profile should set the flags of the command, except for any given explicitly.
+func Test_applyProfile_Success(t *testing.T) {
+	var compression, workers int
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().IntVar(&compression, "compression", 9, "")
+	cmd.Flags().IntVar(&workers, "workers", 4, "")
+	require.NoError(t, cmd.Flags().Parse([]string{"--workers=3"}))
+
+	p, err := parseProfile("fast")
+	require.NoError(t, err)
+	require.NoError(t, applyProfile(cmd, p))
+
+	require.Equal(t, p.CompressionLevel, compression)
+	require.Equal(t, 3, workers)
+}
+
+// Expectation: The profile should be applied to the options of a command, with explicit options taking precedence.
+func Test_CLI_Profile_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt"}), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, nil, nil)
+	cmd.SetArgs([]string{"list", "/archive.tar.gz", "--profile=small", "--chunksize=7"})
+	require.NoError(t, cmd.Execute())
+
+	listCmd, _, err := cmd.Find([]string{"list"})
+	require.NoError(t, err)
+
+	p, err := parseProfile("small")
+	require.NoError(t, err)
+
+	require.Equal(t, 7, mustGetInt(t, listCmd, "chunksize"))
+	require.Equal(t, p.NumWorkers, mustGetInt(t, listCmd, "workers"))
+}
+
+// Expectation: An unknown profile should be returned as an error by any command.
+func Test_CLI_Profile_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt"}), 0o644)
+
+	cmd := newRootCmd(t.Context(), fs, nil, nil)
+	cmd.SetArgs([]string{"list", "/archive.tar.gz", "--profile=turbo"})
+
+	require.ErrorIs(t, cmd.Execute(), errInvalidProfile)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	runtimemetrics "runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// heapSampleInterval is the interval of sampling the heap (see [Progress.trackPeakHeap]).
+const heapSampleInterval = 100 * time.Millisecond
+
+// progressKey is the context key of the live [Progress] of an operation.
+type progressKey struct{}
+
+// Progress is the live progress of a running operation, for the printing of
+// snapshots on request (e.g. upon a signal) and the exposition of metrics. All
+// of its methods are safe for concurrent use and do nothing on a nil Progress,
+// so that operations can report their progress regardless of any observers.
+type Progress struct {
+	start   time.Time
+	phase   atomic.Value // Current phase of the operation (string)
+	entries atomic.Int64 // Entries read from any sources (walked or from tarballs)
+	diffs   atomic.Int64 // Differences found between any sources
+	written atomic.Int64 // Bytes written to any output files
+	spilled atomic.Int64 // Bytes spilled to temporary files (by external sorting)
+
+	onDisk   atomic.Int64  // Bytes currently held in temporary files (by external sorting)
+	peakDisk atomic.Int64  // Most bytes held in temporary files at once
+	peakHeap atomic.Uint64 // Most bytes of live heap objects at once (as sampled, see Progress.trackPeakHeap)
+}
+
+// withProgress returns a context carrying a new [Progress], to be observed by
+// the caller. Any operations running within the context report their progress.
+func withProgress(ctx context.Context) (context.Context, *Progress) {
+	p := &Progress{start: time.Now()}
+	p.phase.Store("starting")
+
+	return context.WithValue(ctx, progressKey{}, p), p
+}
+
+// progressFrom returns the [Progress] carried by the context (or nil).
+func progressFrom(ctx context.Context) *Progress {
+	p, _ := ctx.Value(progressKey{}).(*Progress)
+
+	return p
+}
+
+// setPhase sets the current phase of the operation (as a formatted string).
+func (p *Progress) setPhase(format string, args ...any) {
+	if p == nil {
+		return
+	}
+
+	p.phase.Store(fmt.Sprintf(format, args...))
+}
+
+// addEntry counts an entry as read from a source.
+func (p *Progress) addEntry() {
+	if p == nil {
+		return
+	}
+
+	p.entries.Add(1)
+}
+
+// addDiff counts a difference as found between sources.
+func (p *Progress) addDiff() {
+	if p == nil {
+		return
+	}
+
+	p.diffs.Add(1)
+}
+
+// addWritten counts bytes as written to an output file.
+func (p *Progress) addWritten(n int) {
+	if p == nil {
+		return
+	}
+
+	p.written.Add(int64(n))
+}
+
+// addSpilled counts bytes as spilled to temporary files, which are held there
+// until released with [Progress.releaseSpilled] (once the files are removed).
+func (p *Progress) addSpilled(n int) {
+	if p == nil {
+		return
+	}
+
+	p.spilled.Add(int64(n))
+	storeMax(&p.peakDisk, p.onDisk.Add(int64(n)))
+}
+
+// releaseSpilled counts bytes as no longer held in temporary files.
+func (p *Progress) releaseSpilled(n int64) {
+	if p == nil {
+		return
+	}
+
+	p.onDisk.Add(-n)
+}
+
+// trackPeakHeap samples the bytes of the live heap objects at the interval for
+// [Progress.Usage], until the returned function is called (sampling once more).
+// Sampling (rather than e.g. a hook of the garbage collector) may miss a peak
+// between the samples, but costs next to nothing (not stopping the world).
+func (p *Progress) trackPeakHeap(interval time.Duration) func() {
+	if p == nil {
+		return func() {}
+	}
+
+	sample := []runtimemetrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	measure := func() {
+		runtimemetrics.Read(sample)
+
+		if sample[0].Value.Kind() == runtimemetrics.KindUint64 {
+			storeMax(&p.peakHeap, sample[0].Value.Uint64())
+		}
+	}
+	measure()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				measure()
+			}
+		}
+	}()
+
+	return sync.OnceFunc(func() {
+		close(done)
+		<-stopped
+		measure()
+	})
+}
+
+// storeMax stores v into the value, if it is larger than the value stored.
+func storeMax[T int64 | uint64, A interface {
+	Load() T
+	CompareAndSwap(old, new T) bool
+}](a A, v T) {
+	for {
+		current := a.Load()
+		if v <= current || a.CompareAndSwap(current, v) {
+			return
+		}
+	}
+}
+
+// trackWrites returns the file counting the bytes written through it (as of
+// [Progress.addWritten]), or the file as-is if there is no progress to track.
+func (p *Progress) trackWrites(f afero.File) afero.File {
+	if p == nil {
+		return f
+	}
+
+	return progressFile{File: f, progress: p}
+}
+
+// progressFile is an [afero.File] counting the bytes written into a [Progress].
+type progressFile struct {
+	afero.File
+
+	progress *Progress
+}
+
+func (f progressFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.progress.addWritten(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+func (f progressFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(p, off)
+	f.progress.addWritten(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+func (f progressFile) WriteString(s string) (int, error) {
+	n, err := f.File.WriteString(s)
+	f.progress.addWritten(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// Snapshot prints a single-line, human-readable snapshot of the [Progress].
+func (p *Progress) Snapshot(w io.Writer) {
+	if p == nil {
+		return
+	}
+
+	phase, _ := p.phase.Load().(string)
+
+	fmt.Fprintf(w, "progress: %s (entries: %d, spilled to disk: %s, elapsed: %s)\n",
+		phase, p.entries.Load(), formatBytes(p.spilled.Load()), time.Since(p.start).Round(time.Second))
+}
+
+// Usage prints a single-line, human-readable report of the peak usage of the
+// resources by the operation, so that the memory (governed by --chunksize) and
+// the space of the --tmpdir can be sized accordingly. The bytes held in the
+// temporary files are those of the spilled paths (without any framing).
+func (p *Progress) Usage(w io.Writer) {
+	if p == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "usage: peak heap: %s, peak temporary files: %s (spilled to disk: %s, elapsed: %s)\n",
+		formatBytes(int64(p.peakHeap.Load())), formatBytes(p.peakDisk.Load()), formatBytes(p.spilled.Load()), //nolint:gosec
+		time.Since(p.start).Round(time.Second))
+}
+
+// reportUsage tracks the peak usage of the resources by the operation (see
+// [Progress.trackPeakHeap]), returning the function to stop it and print its
+// report to w (as of [Progress.Usage]), which is meant to be deferred.
+func (p *Progress) reportUsage(w io.Writer) func() {
+	stop := p.trackPeakHeap(heapSampleInterval)
+
+	return func() {
+		stop()
+		p.Usage(w)
+	}
+}
+
+// formatBytes returns a human-readable representation of an amount of bytes.
+func formatBytes(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
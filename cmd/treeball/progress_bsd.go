@@ -0,0 +1,12 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// progressSignals are the signals requesting a progress snapshot,
+// including SIGINFO (as sent by the terminal's status key, Ctrl+T).
+var progressSignals = []os.Signal{syscall.SIGUSR2, syscall.SIGINFO}
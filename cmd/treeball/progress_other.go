@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// progressSignals are the signals requesting a progress snapshot.
+var progressSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The progress of an operation should be reported into the context's progress.
+func Test_Progress_List_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"c.txt", "a/", "a/b.txt"}), 0o644))
+
+	config := extSortConfigDefault
+	config.ChunkSize = 2 // Spilling the sorted chunks to disk.
+	config.TempFilesDir = t.TempDir()
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, &config, nil)
+
+	ctx, progress := withProgress(t.Context())
+	require.NoError(t, prog.List(ctx, "/input.tar.gz", true, nil))
+
+	var buf bytes.Buffer
+	progress.Snapshot(&buf)
+
+	require.Regexp(t, regexp.MustCompile(`^progress: listing /input\.tar\.gz \(entries: 3, spilled to disk: \d+ B, elapsed: 0s\)\n$`), buf.String())
+	require.Positive(t, progress.spilled.Load())
+
+	// The temporary files were removed once sorted, but were held at once before.
+	require.Zero(t, progress.onDisk.Load())
+	require.Equal(t, progress.spilled.Load(), progress.peakDisk.Load())
+}
+
+// Expectation: The walked entries of a directory source should be counted.
+func Test_Progress_Diff_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new/a.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new/b.txt", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	ctx, progress := withProgress(t.Context())
+	_, err := prog.Diff(ctx, "/old.tar.gz", "/new", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "comparing /old.tar.gz with /new", progress.phase.Load())
+	require.Equal(t, int64(1+3), progress.entries.Load()) // Including the walked root.
+}
+
+// Expectation: A nil progress (outside of a progress context) should be ignored.
+func Test_Progress_Nil_Success(t *testing.T) {
+	progress := progressFrom(t.Context())
+	require.Nil(t, progress)
+
+	progress.setPhase("phase")
+	progress.addEntry()
+	progress.addSpilled(1)
+	progress.releaseSpilled(1)
+	progress.trackPeakHeap(time.Millisecond)()
+
+	var buf bytes.Buffer
+	progress.Snapshot(&buf)
+	progress.Usage(&buf)
+	require.Empty(t, buf.String())
+}
+
+// Expectation: The most bytes held in temporary files at once should be tracked.
+func Test_Progress_PeakDisk_Success(t *testing.T) {
+	_, progress := withProgress(t.Context())
+
+	progress.addSpilled(10)
+	progress.addSpilled(5)
+	progress.releaseSpilled(15)
+	progress.addSpilled(3)
+
+	require.Equal(t, int64(18), progress.spilled.Load())
+	require.Equal(t, int64(3), progress.onDisk.Load())
+	require.Equal(t, int64(15), progress.peakDisk.Load())
+}
+
+// Expectation: The peak heap should be sampled until stopped, which is safe to repeat.
+func Test_Progress_trackPeakHeap_Success(t *testing.T) {
+	_, progress := withProgress(t.Context())
+
+	stop := progress.trackPeakHeap(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	stop()
+	stop()
+
+	require.Positive(t, progress.peakHeap.Load())
+}
+
+// Expectation: The peak usage should be reported in human-readable units.
+func Test_Progress_Usage_Success(t *testing.T) {
+	_, progress := withProgress(t.Context())
+
+	progress.peakHeap.Store(3 << 20)
+	progress.addSpilled(2048)
+
+	var buf bytes.Buffer
+	progress.Usage(&buf)
+
+	require.Equal(t, "usage: peak heap: 3.0 MiB, peak temporary files: 2.0 KiB (spilled to disk: 2.0 KiB, elapsed: 0s)\n", buf.String())
+}
+
+// Expectation: The peak usage should be reported upon completion of a run with --report-usage.
+func Test_CLI_ListCommand_ReportUsage_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"c.txt", "a/", "a/b.txt"}), 0o644))
+
+	var stderrBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, &stderrBuf)
+	cmd.SetArgs([]string{"list", "/input.tar.gz", "--report-usage", "--chunksize=2", "--tmpdir=" + t.TempDir()})
+	require.NoError(t, cmd.Execute())
+
+	require.Regexp(t, regexp.MustCompile(`^usage: peak heap: [\d.]+ [KMG]iB, peak temporary files: [1-9]\d* B \(spilled to disk: [1-9]\d* B, elapsed: 0s\)\n$`), stderrBuf.String())
+}
+
+// Expectation: The amounts of bytes should be formatted in human-readable units.
+func Test_formatBytes_Table(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 * 1024 * 1024, "5.0 MiB"},
+		{3 << 40, "3.0 TiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			require.Equal(t, tt.want, formatBytes(tt.n))
+		})
+	}
+}
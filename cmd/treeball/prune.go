@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// emptyDirPruner leaves out the empty directories of paths in depth-first order
+// (as of walks, or of sorted streams, where the descendants of any directory
+// follow it contiguously), for [ProgramConfig.PruneEmpty]. Any directories are
+// deferred until a file is found within them, at which point they are emitted
+// (in order) before the file, or dropped once the paths have left them behind.
+type emptyDirPruner struct {
+	pending    []pendingDir
+	ignoreCase bool
+}
+
+// pendingDir is a directory deferred by an [emptyDirPruner], by its path (as
+// slash-separated, with a trailing slash) and the function that emits it.
+type pendingDir struct {
+	path string
+	emit func() error
+}
+
+// dir defers a directory, given by its slash-separated path with a trailing slash.
+func (p *emptyDirPruner) dir(path string, emit func() error) {
+	p.leave(path)
+	p.pending = append(p.pending, pendingDir{path: path, emit: emit})
+}
+
+// file emits any deferred directories containing a file (or another leaf, such
+// as a directory at the maximum depth) before emitting the file itself.
+func (p *emptyDirPruner) file(path string, emit func() error) error {
+	p.leave(path)
+
+	for _, dir := range p.pending {
+		if err := dir.emit(); err != nil {
+			return err
+		}
+	}
+	p.pending = p.pending[:0]
+
+	return emit()
+}
+
+// leave drops any deferred directories not containing the path, as these
+// are empty, since none of their descendants can follow the path anymore.
+func (p *emptyDirPruner) leave(path string) {
+	for len(p.pending) > 0 && !p.contains(p.pending[len(p.pending)-1].path, path) {
+		p.pending = p.pending[:len(p.pending)-1]
+	}
+}
+
+func (p *emptyDirPruner) contains(dir string, path string) bool {
+	if len(path) <= len(dir) {
+		return false
+	}
+
+	if p.ignoreCase {
+		return strings.EqualFold(path[:len(dir)], dir)
+	}
+
+	return strings.HasPrefix(path, dir)
+}
+
+// isPruneLeaf returns if a path of a stream is a leaf for [emptyDirPruner],
+// which are the files and any directories at the maximum depth (as these are
+// not empty, only their contents are not considered).
+func (prog *Program) isPruneLeaf(path string) bool {
+	if !strings.HasSuffix(path, "/") {
+		return true
+	}
+
+	return prog.config.MaxDepth > 0 && pathDepth(path) >= prog.config.MaxDepth
+}
+
+// pruneEmptyStream removes the empty directories of a sorted stream of paths
+// (or records), as those left without any files by exclusions of tarballs.
+func (prog *Program) pruneEmptyStream(ctx context.Context, input <-chan string, inputErrs <-chan error) (<-chan string, <-chan error) {
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		pruner := &emptyDirPruner{ignoreCase: prog.config.IgnoreCase}
+
+		for rec := range input {
+			emit := func() error {
+				select {
+				case paths <- rec:
+					return nil
+				case <-ctx.Done():
+					return fmt.Errorf("failed to prune stream: %w", ctx.Err())
+				}
+			}
+
+			if p := recordPath(rec); prog.isPruneLeaf(p) {
+				if err := pruner.file(p, emit); err != nil {
+					errs <- err
+
+					return
+				}
+			} else {
+				pruner.dir(p, emit)
+			}
+		}
+
+		for err := range inputErrs {
+			if err != nil {
+				errs <- err
+
+				return
+			}
+		}
+	}()
+
+	return paths, errs
+}
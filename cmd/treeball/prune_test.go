@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// The paths of the tree for the pruning tests.
+var pruneTree = []string{"/src/a.txt", "/src/vendor/x/y.go", "/src/vendor/z.go", "/src/b/c/d.txt", "/src/b-c.txt", "/src/empty/nested/"}
+
+// Expectation: Only the directories with any descendant files should be emitted, in order.
+func Test_emptyDirPruner_Table(t *testing.T) {
+	tests := []struct {
+		name       string
+		paths      []string
+		ignoreCase bool
+		want       []string
+	}{
+		{"files", []string{"a.txt", "b.txt"}, false, []string{"a.txt", "b.txt"}},
+		{"empty", []string{"a/", "a/b/", "c.txt"}, false, []string{"c.txt"}},
+		{"nested", []string{"a/", "a/b/", "a/b/c.txt", "a/d/"}, false, []string{"a/", "a/b/", "a/b/c.txt"}},
+		{"sibling prefix", []string{"a-b.txt", "a/", "a/c/", "ab/", "ab/x.txt"}, false, []string{"a-b.txt", "ab/", "ab/x.txt"}},
+		{"trailing", []string{"a.txt", "z/"}, false, []string{"a.txt"}},
+		{"case", []string{"A/", "a/x.txt"}, false, []string{"a/x.txt"}},
+		{"ignore case", []string{"A/", "a/x.txt"}, true, []string{"A/", "a/x.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pruner := &emptyDirPruner{ignoreCase: tt.ignoreCase}
+
+			var got []string
+			for _, p := range tt.paths {
+				emit := func() error {
+					got = append(got, p)
+
+					return nil
+				}
+
+				if p[len(p)-1] == '/' {
+					pruner.dir(p, emit)
+				} else {
+					require.NoError(t, pruner.file(p, emit))
+				}
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: Directories without any files should be left out of created archives.
+func Test_Program_Create_PruneEmpty_Success(t *testing.T) {
+	fs := newTreeFs(t, pruneTree...)
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{PruneEmpty: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{"vendor/**"}))
+
+	require.Equal(t, "a.txt\nb\nb/c\nb/c/d.txt\nb-c.txt\n", stdoutBuf.String())
+}
+
+// Expectation: Directories at the maximum depth should be kept, as only their contents are not considered.
+func Test_Program_Create_PruneEmpty_MaxDepth_Success(t *testing.T) {
+	fs := newTreeFs(t, pruneTree...)
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{PruneEmpty: true, MaxDepth: 1})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	require.Equal(t, "a.txt\nb\nb-c.txt\nempty\nvendor\n", stdoutBuf.String())
+}
+
+// Expectation: Directories left without files by exclusions should not be differences of tarballs and trees.
+func Test_Program_Check_PruneEmpty_Success(t *testing.T) {
+	fs := newTreeFs(t, pruneTree...)
+
+	require.NoError(t, afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{
+		"a.txt", "b/", "b/c/", "b/c/d.txt", "b-c.txt", "old/", "vendor/", "vendor/x/", "vendor/x/y.go", "vendor/z.go",
+	}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{PruneEmpty: true})
+	res, err := prog.Check(t.Context(), "/snapshot.tar.gz", []string{"/src"}, []string{"vendor/**"})
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(0), res.ExtraA)
+	require.Equal(t, uint64(0), res.ExtraB)
+}
+
+// Expectation: Directories left without files by exclusions should be differences without --prune-empty.
+func Test_Program_Check_NoPruneEmpty_Success(t *testing.T) {
+	fs := newTreeFs(t, pruneTree...)
+
+	require.NoError(t, afero.WriteFile(fs, "/snapshot.tar.gz", createTar([]string{
+		"a.txt", "b/", "b/c/", "b/c/d.txt", "b-c.txt", "vendor/", "vendor/z.go",
+	}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	res, err := prog.Check(t.Context(), "/snapshot.tar.gz", []string{"/src"}, []string{"vendor/**"})
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, uint64(0), res.ExtraA)
+	require.Equal(t, uint64(2), res.ExtraB)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuotingStyle is the quoting of the paths printed in line-oriented output
+// (as for --quoting), so that any paths holding newlines, tabs or other control
+// characters do not corrupt the output (or can be safely pasted into a shell).
+type QuotingStyle int
+
+const (
+	// QuotingLiteral prints the paths as-is.
+	QuotingLiteral QuotingStyle = iota
+
+	// QuotingShell prints the paths as-is if these are safe to use as words of
+	// a POSIX shell, otherwise in single quotes, or as $'...' with any control
+	// characters escaped (as with "ls --quoting-style=shell-escape").
+	QuotingShell
+
+	// QuotingC prints the paths in double quotes, with any control characters,
+	// quotes and backslashes escaped as in C (and Go) string literals.
+	QuotingC
+)
+
+// shellSafe are the (ASCII) characters never needing any quoting in a shell.
+const shellSafe = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./-_"
+
+var errInvalidQuotingStyle = errors.New("invalid quoting style")
+
+// parseQuotingStyle returns the [QuotingStyle] for a style name (as for --quoting).
+func parseQuotingStyle(name string) (QuotingStyle, error) {
+	switch strings.ToLower(name) {
+	case "", "literal":
+		return QuotingLiteral, nil
+	case "shell":
+		return QuotingShell, nil
+	case "c":
+		return QuotingC, nil
+	default:
+		return QuotingLiteral, fmt.Errorf("%w: %q (expected literal, shell or c)", errInvalidQuotingStyle, name)
+	}
+}
+
+// quote returns a path quoted as per the [ProgramConfig.Quoting].
+func (prog *Program) quote(path string) string {
+	switch prog.config.Quoting {
+	case QuotingShell:
+		return shellQuote(path)
+	case QuotingC:
+		return strconv.Quote(path)
+	case QuotingLiteral:
+	}
+
+	return path
+}
+
+// shellQuote returns a path quoted for a POSIX shell (see [QuotingShell]).
+func shellQuote(path string) string {
+	if path != "" && strings.Trim(path, shellSafe) == "" {
+		return path
+	}
+
+	printable := utf8.ValidString(path) && strings.IndexFunc(path, func(r rune) bool {
+		return !unicode.IsPrint(r)
+	}) < 0
+
+	if printable {
+		return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+	}
+
+	// Any other paths are written as ANSI-C quoted strings, as supported by
+	// bash, zsh and ksh, with the non-printable characters (and bytes) escaped.
+	var b strings.Builder
+
+	b.WriteString("$'")
+
+	for i := 0; i < len(path); {
+		r, size := utf8.DecodeRuneInString(path[i:])
+
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(&b, `\x%02x`, path[i])
+		case r == '\'' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case !unicode.IsPrint(r) && r < utf8.RuneSelf:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case !unicode.IsPrint(r) && r <= 0xffff:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\U%08x`, r)
+		default:
+			b.WriteRune(r)
+		}
+
+		i += size
+	}
+
+	b.WriteString("'")
+
+	return b.String()
+}
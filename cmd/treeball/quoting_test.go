@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The names should be parsed into their respective styles, rejecting unknown ones.
+func Test_parseQuotingStyle_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected QuotingStyle
+		ok       bool
+	}{
+		{"", QuotingLiteral, true},
+		{"literal", QuotingLiteral, true},
+		{"shell", QuotingShell, true},
+		{"C", QuotingC, true},
+		{"escape", QuotingLiteral, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			style, err := parseQuotingStyle(tt.name)
+			if !tt.ok {
+				require.ErrorIs(t, err, errInvalidQuotingStyle)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, style)
+		})
+	}
+}
+
+// Expectation: The paths from the table should be quoted as per the respective style.
+func Test_Program_quote_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		style    QuotingStyle
+		path     string
+		expected string
+	}{
+		{"literal", QuotingLiteral, "a\nb.txt", "a\nb.txt"},
+		{"shell safe", QuotingShell, "dir/a-b_c.txt", "dir/a-b_c.txt"},
+		{"shell empty", QuotingShell, "", "''"},
+		{"shell space", QuotingShell, "a b.txt", "'a b.txt'"},
+		{"shell quote", QuotingShell, "it's.txt", `'it'\''s.txt'`},
+		{"shell unicode", QuotingShell, "Bücher/", "'Bücher/'"},
+		{"shell newline", QuotingShell, "a\nb's.txt", `$'a\nb\'s.txt'`},
+		{"shell control", QuotingShell, "a\x01\tb", `$'a\x01\tb'`},
+		{"shell invalid utf8", QuotingShell, "a\xffb", `$'a\xffb'`},
+		{"c plain", QuotingC, "a.txt", `"a.txt"`},
+		{"c newline", QuotingC, "a\n\"b\".txt", `"a\n\"b\".txt"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{Quoting: tt.style})
+			require.Equal(t, tt.expected, prog.quote(tt.path))
+		})
+	}
+}
+
+// Expectation: The differing paths should be printed quoted, keeping each on a single line.
+func Test_Program_Diff_Quoting_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt", "b\nc.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Quoting: QuotingC})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- \"b\\nc.txt\"\n", stdoutBuf.String())
+}
+
+// Expectation: The listed paths should be printed quoted, keeping each on a single line.
+func Test_Program_List_Quoting_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"a b/", "a b/c\td.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Quoting: QuotingShell})
+	require.NoError(t, prog.List(t.Context(), "/in.tar.gz", true, nil))
+
+	require.Equal(t, "'a b/'\n$'a b/c\\td.txt'\n", stdoutBuf.String())
+}
@@ -0,0 +1,21 @@
+//go:build minimal || no_rclone
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/spf13/afero"
+)
+
+const featureRclone = false
+
+var errRcloneDisabled = errors.New("rclone:// sources are not compiled into this build")
+
+// dialRclone is a stub for builds without the rclone feature compiled in.
+// It always returns an error, as no rclone remote can be walked in such a build.
+func dialRclone(_ context.Context, _ string) (afero.Fs, io.Closer, error) {
+	return nil, nil, errRcloneDisabled
+}
@@ -0,0 +1,364 @@
+//go:build !minimal && !no_rclone
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const featureRclone = true
+
+// defaultRcloneRCURL is the address of the remote control of rclone (rclone rcd),
+// unless another one is given with the $RCLONE_RC_URL environment variable.
+const defaultRcloneRCURL = "http://localhost:5572/"
+
+var (
+	errRcloneRC       = errors.New("rclone remote control failed")
+	errRcloneNotATree = errors.New("only directory trees are read from rclone remotes")
+)
+
+// dialRclone connects to a remote of rclone (as of the authority of an rclone://
+// source being the name of a configured remote), returning its filesystem (read
+// through the remote control of a running rclone instance, read-only) and the
+// closer aborting any requests in progress. Any of the remotes supported by
+// rclone can so be walked (e.g. B2, Google Drive, SMB or WebDAV).
+//
+// The remote control is reached at $RCLONE_RC_URL (otherwise at its default
+// address), authenticating with any $RCLONE_RC_USER and $RCLONE_RC_PASS.
+// The remote is checked to be configured with rclone upfront.
+func dialRclone(ctx context.Context, remote string) (afero.Fs, io.Closer, error) {
+	if remote == "" {
+		return nil, nil, fmt.Errorf("%w: %q (expected rclone://remote/path)", errInvalidRemote, remote)
+	}
+
+	endpoint := cmp.Or(os.Getenv("RCLONE_RC_URL"), defaultRcloneRCURL)
+	if u, err := url.Parse(endpoint); err != nil || u.Host == "" {
+		return nil, nil, fmt.Errorf("%w: %q (invalid $RCLONE_RC_URL)", errInvalidRemote, endpoint)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	r := &rcloneFs{
+		ctx:      ctx,
+		cancel:   cancel,
+		client:   &http.Client{},
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		user:     os.Getenv("RCLONE_RC_USER"),
+		pass:     os.Getenv("RCLONE_RC_PASS"),
+		remote:   remote + ":",
+	}
+
+	if err := r.call("operations/fsinfo", map[string]any{"fs": r.remote}, nil); err != nil {
+		cancel()
+
+		return nil, nil, fmt.Errorf("failed to connect to rclone remote %q: %w", remote, err)
+	}
+
+	return r, r, nil
+}
+
+// rcloneItem is an entry of a remote, as returned by the remote control.
+type rcloneItem struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// rcloneFs is a read-only [afero.Fs] of a remote of rclone, with the paths
+// within the remote as paths. Only directory trees can be read from it, as
+// the files (e.g. any archives) are not themselves transferred.
+type rcloneFs struct {
+	ctx      context.Context //nolint:containedctx
+	cancel   context.CancelFunc
+	client   *http.Client
+	endpoint string // Address of the remote control (without trailing slash)
+	user     string // User of the remote control (empty: no authentication)
+	pass     string // Password of the remote control
+	remote   string // Name of the remote (as in "remote:")
+}
+
+// Close aborts any requests of the filesystem still in progress.
+func (r *rcloneFs) Close() error {
+	r.cancel()
+
+	return nil
+}
+
+// call calls a method of the remote control with the parameters, decoding its
+// result into the given value (if not nil). Errors reported with a status of
+// 404 (e.g. for a directory not found) are returned as [fs.ErrNotExist].
+func (r *rcloneFs) call(method string, params any, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode parameters: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodPost, r.endpoint+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if r.user != "" {
+		req.SetBasicAuth(r.user, r.pass)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", errRcloneRC, method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var rcErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&rcErr)
+
+		msg := cmp.Or(rcErr.Error, resp.Status)
+
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", fs.ErrNotExist, msg)
+		}
+
+		return fmt.Errorf("%w: %s: %s", errRcloneRC, method, msg)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("%w: %s: failed to decode result: %w", errRcloneRC, method, err)
+	}
+
+	return nil
+}
+
+// path returns the path within the remote of a path (empty for its root).
+func (r *rcloneFs) path(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
+
+func (r *rcloneFs) Create(name string) (afero.File, error) {
+	return nil, &fs.PathError{Op: "create", Path: name, Err: errRemoteReadOnly}
+}
+
+func (r *rcloneFs) Mkdir(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errRemoteReadOnly}
+}
+
+func (r *rcloneFs) MkdirAll(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errRemoteReadOnly}
+}
+
+// Open opens a directory of the remote, as files cannot be read (see [rcloneFs]).
+func (r *rcloneFs) Open(name string) (afero.File, error) {
+	info, err := r.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errRcloneNotATree}
+	}
+
+	return &rcloneFile{fs: r, name: name, info: info}, nil
+}
+
+func (r *rcloneFs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errRemoteReadOnly}
+	}
+
+	return r.Open(name)
+}
+
+func (r *rcloneFs) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: errRemoteReadOnly}
+}
+
+func (r *rcloneFs) RemoveAll(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: errRemoteReadOnly}
+}
+
+func (r *rcloneFs) Rename(oldname string, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errRemoteReadOnly}
+}
+
+func (r *rcloneFs) Stat(name string) (os.FileInfo, error) {
+	p := r.path(name)
+	if p == "" {
+		return &rcloneFileInfo{rcloneItem{Name: "/", IsDir: true}}, nil
+	}
+
+	var result struct {
+		Item *rcloneItem `json:"item"`
+	}
+
+	if err := r.call("operations/stat", map[string]any{"fs": r.remote, "remote": p}, &result); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	if result.Item == nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return &rcloneFileInfo{*result.Item}, nil
+}
+
+func (r *rcloneFs) Name() string {
+	return "rcloneFs"
+}
+
+func (r *rcloneFs) Chmod(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: errRemoteReadOnly}
+}
+
+func (r *rcloneFs) Chown(name string, _ int, _ int) error {
+	return &fs.PathError{Op: "chown", Path: name, Err: errRemoteReadOnly}
+}
+
+func (r *rcloneFs) Chtimes(name string, _ time.Time, _ time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: errRemoteReadOnly}
+}
+
+// rcloneFileInfo is the [os.FileInfo] of an entry of an [rcloneFs].
+type rcloneFileInfo struct {
+	item rcloneItem
+}
+
+func (fi *rcloneFileInfo) Name() string       { return fi.item.Name }
+func (fi *rcloneFileInfo) Size() int64        { return max(fi.item.Size, 0) }
+func (fi *rcloneFileInfo) ModTime() time.Time { return fi.item.ModTime }
+func (fi *rcloneFileInfo) IsDir() bool        { return fi.item.IsDir }
+func (fi *rcloneFileInfo) Sys() any           { return nil }
+
+func (fi *rcloneFileInfo) Mode() fs.FileMode {
+	if fi.item.IsDir {
+		return fs.ModeDir | 0o555 //nolint:mnd
+	}
+
+	return 0o444 //nolint:mnd
+}
+
+// rcloneFile is an [afero.File] of a directory of an [rcloneFs].
+type rcloneFile struct {
+	fs   *rcloneFs
+	name string
+	info os.FileInfo
+
+	entries []os.FileInfo // Entries of the directory not yet returned by Readdir
+	read    bool          // Entries of the directory were read
+}
+
+func (f *rcloneFile) unsupported(op string) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: errRcloneNotATree}
+}
+
+func (f *rcloneFile) Close() error {
+	return nil
+}
+
+func (f *rcloneFile) Read(_ []byte) (int, error) {
+	return 0, f.unsupported("read")
+}
+
+func (f *rcloneFile) ReadAt(_ []byte, _ int64) (int, error) {
+	return 0, f.unsupported("read")
+}
+
+func (f *rcloneFile) Seek(_ int64, _ int) (int64, error) {
+	return 0, f.unsupported("seek")
+}
+
+func (f *rcloneFile) Write(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errRemoteReadOnly}
+}
+
+func (f *rcloneFile) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errRemoteReadOnly}
+}
+
+func (f *rcloneFile) WriteString(_ string) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errRemoteReadOnly}
+}
+
+func (f *rcloneFile) Name() string {
+	return f.name
+}
+
+// Readdir returns the entries of the directory, with the semantics of [os.File.Readdir].
+func (f *rcloneFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.read {
+		var result struct {
+			List []rcloneItem `json:"list"`
+		}
+
+		if err := f.fs.call("operations/list", map[string]any{"fs": f.fs.remote, "remote": f.fs.path(f.name)}, &result); err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: err}
+		}
+
+		for _, item := range result.List {
+			f.entries = append(f.entries, &rcloneFileInfo{item})
+		}
+		f.read = true
+	}
+
+	if count <= 0 {
+		entries := f.entries
+		f.entries = nil
+
+		return entries, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n := min(count, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+
+	return entries, nil
+}
+
+// Readdirnames returns the names of the entries of the directory, with the semantics of [os.File.Readdirnames].
+func (f *rcloneFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+
+	return names, err
+}
+
+func (f *rcloneFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *rcloneFile) Sync() error {
+	return nil
+}
+
+func (f *rcloneFile) Truncate(_ int64) error {
+	return &fs.PathError{Op: "truncate", Path: f.name, Err: errRemoteReadOnly}
+}
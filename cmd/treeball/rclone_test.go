@@ -0,0 +1,170 @@
+//go:build !minimal && !no_rclone
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to start a remote control of rclone serving a remote (media:)
+// with a tree at /data (requiring authentication), set as the remote control in the environment.
+func newTestRcloneRC(t *testing.T) {
+	t.Helper()
+
+	remote := afero.NewMemMapFs()
+	require.NoError(t, remote.MkdirAll("/data/dir", 0o755))
+	require.NoError(t, afero.WriteFile(remote, "/data/dir/a.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(remote, "/data/b.txt", nil, 0o644))
+
+	item := func(info os.FileInfo) map[string]any {
+		return map[string]any{"Name": info.Name(), "Size": info.Size(), "ModTime": info.ModTime(), "IsDir": info.IsDir()}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Fs     string `json:"fs"`
+			Remote string `json:"remote"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&params)
+
+		if user, pass, _ := r.BasicAuth(); user != "tester" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		if params.Fs != "media:" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": "didn't find section in config file"})
+
+			return
+		}
+
+		name := path.Join("/", params.Remote)
+
+		switch r.URL.Path {
+		case "/operations/fsinfo":
+			_ = json.NewEncoder(w).Encode(map[string]any{"Name": "media"})
+
+		case "/operations/stat":
+			info, err := remote.Stat(name)
+			if err != nil {
+				_ = json.NewEncoder(w).Encode(map[string]any{"item": nil})
+
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"item": item(info)})
+
+		case "/operations/list":
+			infos, err := afero.ReadDir(remote, name)
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				_ = json.NewEncoder(w).Encode(map[string]any{"error": "directory not found"})
+
+				return
+			}
+
+			list := []map[string]any{}
+			for _, info := range infos {
+				list = append(list, item(info))
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"list": list})
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("RCLONE_RC_URL", srv.URL+"/")
+	t.Setenv("RCLONE_RC_USER", "tester")
+	t.Setenv("RCLONE_RC_PASS", "secret")
+}
+
+// Expectation: An archive should be created from a tree of an rclone remote, also walked in parallel.
+func Test_Program_Create_Rclone_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+	}{
+		{"Serial", 0},
+		{"Parallel", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRcloneRC(t)
+
+			fs := afero.NewMemMapFs()
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{WalkWorkers: tt.workers})
+			require.NoError(t, prog.Create(t.Context(), "rclone://media/data", "/out.tar.gz", nil))
+
+			require.Equal(t, []string{"b.txt", "dir/", "dir/a.txt"}, readTarNames(t, fs, "/out.tar.gz"))
+		})
+	}
+}
+
+// Expectation: A local archive should be compared against a tree of an rclone remote.
+func Test_Program_Diff_Rclone_Success(t *testing.T) {
+	newTestRcloneRC(t)
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"b.txt", "c.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "rclone://media/data", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- c.txt\n+++ dir/\n+++ dir/a.txt\n", stdoutBuf.String())
+}
+
+// Expectation: A missing directory of an rclone remote should be returned as not existing.
+func Test_Program_Create_RcloneMissing_Error(t *testing.T) {
+	newTestRcloneRC(t)
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.Create(t.Context(), "rclone://media/missing", "/out.tar.gz", nil)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+// Expectation: A remote not configured with rclone should fail upon connecting.
+func Test_Program_Create_RcloneUnknownRemote_Error(t *testing.T) {
+	newTestRcloneRC(t)
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.Create(t.Context(), "rclone://other/data", "/out.tar.gz", nil)
+	require.ErrorIs(t, err, errRcloneRC)
+	require.ErrorContains(t, err, "didn't find section in config file")
+}
+
+// Expectation: Files of an rclone remote (such as archives) should not be opened.
+func Test_rcloneFs_OpenFile_Error(t *testing.T) {
+	newTestRcloneRC(t)
+
+	fsys, closer, err := dialRclone(t.Context(), "media")
+	require.NoError(t, err)
+	defer closer.Close()
+
+	_, err = fsys.Open("/data/b.txt")
+	require.ErrorIs(t, err, errRcloneNotATree)
+
+	_, err = fsys.Create("/data/c.txt")
+	require.ErrorIs(t, err, errRemoteReadOnly)
+}
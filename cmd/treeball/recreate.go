@@ -0,0 +1,270 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	manifestTypeFile = "file"
+	manifestTypeDir  = "dir"
+)
+
+var errInvalidManifestEntry = errors.New("invalid manifest entry")
+
+// ManifestEntry is an entry of a manifest, as consumed by [Program.Recreate].
+// Any other fields (such as metadata) are ignored, as they are not reflected
+// by the zero-byte dummies of the resulting tarball.
+type ManifestEntry struct {
+	Path string `json:"path"`           // Relative path of the entry
+	Type string `json:"type,omitempty"` // Type of the entry ("file" or "dir"; empty: trailing slash)
+}
+
+// Recreate produces a tarball purely from the entries of a manifest file.
+// Any entries are written as zero-byte dummies, just as with [Program.Create].
+//
+// The input parameter specifies the manifest, a JSON array of [ManifestEntry].
+// The output parameter is the path of the tarball file to create. The entries
+// are written in sorted order, with any missing parent directories added and
+// any duplicate entries removed, so that a canonical tarball is produced
+// regardless of how the manifest was edited. The ctx parameter controls early
+// cancellation.
+func (prog *Program) Recreate(ctx context.Context, input string, output string) error {
+	var creationDone bool
+
+	progressFrom(ctx).setPhase("recreating %s from %s", output, input)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input, output)
+	if err != nil {
+		return err
+	}
+	defer closeRemotes()
+
+	in, err := prog.fs.Open(input)
+	if err != nil {
+		return fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer in.Close()
+
+	out, err := prog.createOutput(ctx, output)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	defer func() {
+		if !creationDone {
+			_ = prog.fs.Remove(output)
+		}
+	}()
+	defer out.Close()
+
+	gw, err := prog.newArchiveWriter(out)
+	if err != nil {
+		return err
+	}
+	defer gw.Close()
+
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	paths, errs := prog.manifestPathStream(ctx, in)
+
+	if err := prog.recreateEntries(tw, paths); err != nil {
+		return err
+	}
+
+	for err := range errs {
+		if err != nil {
+			return fmt.Errorf("failure during recreate: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+
+	if prog.config.VerifyWrites {
+		if err := prog.verifyOutput(ctx, output); err != nil {
+			return err
+		}
+	}
+
+	creationDone = true
+
+	return nil
+}
+
+// recreateEntries writes the dummy entries of a sorted stream of paths to the
+// tarball, with any duplicate paths removed and any missing parent directories
+// added (so that a canonical tarball is produced from arbitrary sorted paths).
+// Any path that is both a file and a directory (e.g. "a" and "a/", or "a" and
+// "a/b") is rejected, as the tarball would otherwise contain it twice.
+func (prog *Program) recreateEntries(tw *tar.Writer, paths <-chan string) error {
+	var dirs []string  // Stack of the directories containing the current entry.
+	var files []string // Stack of the files of which the current entry has the prefix.
+	var last string
+
+	for p := range paths {
+		if p == last {
+			continue
+		}
+		last = p
+
+		for len(dirs) > 0 && !strings.HasPrefix(p, dirs[len(dirs)-1]) {
+			dirs = dirs[:len(dirs)-1]
+		}
+
+		// All paths sorted between a file and any same-named directory have
+		// the file as their prefix, so only these files need to be remembered.
+		for len(files) > 0 && !strings.HasPrefix(p, files[len(files)-1]) {
+			files = files[:len(files)-1]
+		}
+		if len(files) > 0 && strings.HasPrefix(p, files[len(files)-1]+"/") {
+			return fmt.Errorf("%w: %q is both a file and a directory", errInvalidManifestEntry, files[len(files)-1])
+		}
+
+		for _, dir := range manifestParents(p, dirs) {
+			if err := prog.recreateEntry(tw, dir); err != nil {
+				return err
+			}
+			dirs = append(dirs, dir)
+		}
+
+		if err := prog.recreateEntry(tw, p); err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(p, "/") {
+			dirs = append(dirs, p)
+		} else {
+			files = append(files, p)
+		}
+	}
+
+	return nil
+}
+
+// recreateEntry writes a dummy entry to the tarball and prints its path.
+func (prog *Program) recreateEntry(tw *tar.Writer, name string) error {
+	if err := writeDummyFile(tw, name, strings.HasSuffix(name, "/"), prog.config.TarFormat); err != nil {
+		return fmt.Errorf("failed to write dummy file: %w", err)
+	}
+
+	prog.printPath(name)
+
+	return nil
+}
+
+// manifestParents returns the parent directories of p that are not yet on the
+// stack of dirs (containing the already written parents), from the top down.
+func manifestParents(p string, dirs []string) []string {
+	var parents []string
+
+	for dir := path.Dir(strings.TrimSuffix(p, "/")); dir != "."; dir = path.Dir(dir) {
+		if len(dirs) > 0 && len(dir)+1 <= len(dirs[len(dirs)-1]) {
+			break
+		}
+		parents = append([]string{dir + "/"}, parents...)
+	}
+
+	return parents
+}
+
+// manifestPathStream returns a sorted stream of the normalized paths of the
+// entries of a manifest, which is decoded incrementally (to conserve memory).
+func (prog *Program) manifestPathStream(ctx context.Context, r io.Reader) (<-chan string, <-chan error) {
+	paths := make(chan string, fsStreamBuffer)
+	errs := make(chan error, 1)
+
+	progress := progressFrom(ctx)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		dec := json.NewDecoder(r)
+
+		if tok, err := dec.Token(); err != nil {
+			errs <- fmt.Errorf("failed to decode manifest: %w", err)
+
+			return
+		} else if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+			errs <- errors.New("failed to decode manifest: expected an array of entries")
+
+			return
+		}
+
+		for index := 0; dec.More(); index++ {
+			var entry ManifestEntry
+			if err := dec.Decode(&entry); err != nil {
+				errs <- fmt.Errorf("failed to decode manifest entry #%d: %w", index, err)
+
+				return
+			}
+
+			p, err := normalizeManifestEntry(entry)
+			if err != nil {
+				errs <- fmt.Errorf("failed to process manifest entry #%d: %w", index, err)
+
+				return
+			}
+
+			select {
+			case paths <- p:
+			case <-ctx.Done():
+				errs <- fmt.Errorf("failed to stream from manifest: %w", ctx.Err())
+
+				return
+			}
+
+			progress.addEntry()
+		}
+	}()
+
+	return extsortStrings(ctx, paths, errs, prog.extSortConfig)
+}
+
+// normalizeManifestEntry returns the cleaned path of a [ManifestEntry],
+// with directories carrying a trailing slash (as within tarballs).
+func normalizeManifestEntry(entry ManifestEntry) (string, error) {
+	isDir := strings.HasSuffix(entry.Path, "/")
+
+	switch entry.Type {
+	case "":
+	case manifestTypeFile:
+		isDir = false
+	case manifestTypeDir:
+		isDir = true
+	default:
+		return "", fmt.Errorf("%w: %q has unknown type %q", errInvalidManifestEntry, entry.Path, entry.Type)
+	}
+
+	p := path.Clean(strings.ReplaceAll(entry.Path, "\\", "/"))
+	if p == "." || path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("%w: %q is not a relative path", errInvalidManifestEntry, entry.Path)
+	}
+
+	if isDir {
+		p += "/"
+	}
+
+	return p, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to read the entry names of a tarball.
+func readTarNames(t *testing.T, fs afero.Fs, path string) []string {
+	t.Helper()
+
+	f, err := fs.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	tr := tar.NewReader(gzr)
+
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		names = append(names, hdr.Name)
+	}
+
+	return names
+}
+
+// Expectation: A canonical tarball should be recreated from the manifest.
+func Test_Program_Recreate_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	manifest := `[
+		{"path": "TV/Show/S01E01.mkv", "size": 123},
+		{"path": "Movies", "type": "dir"},
+		{"path": "Movies/b.mkv", "modTime": "2024-01-01T00:00:00Z"},
+		{"path": "./Movies/a.mkv"},
+		{"path": "Movies/a.mkv"},
+		{"path": "Empty/"}
+	]`
+	require.NoError(t, afero.WriteFile(fs, "/manifest.json", []byte(manifest), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Recreate(t.Context(), "/manifest.json", "/out.tar.gz"))
+
+	want := []string{
+		"Empty/",
+		"Movies/",
+		"Movies/a.mkv",
+		"Movies/b.mkv",
+		"TV/",
+		"TV/Show/",
+		"TV/Show/S01E01.mkv",
+	}
+
+	require.Equal(t, want, readTarNames(t, fs, "/out.tar.gz"))
+	require.Equal(t, "Empty/\nMovies/\nMovies/a.mkv\nMovies/b.mkv\nTV/\nTV/Show/\nTV/Show/S01E01.mkv\n", stdoutBuf.String())
+}
+
+// Expectation: An invalid manifest should raise the appropriate error and the output file be removed.
+func Test_Program_Recreate_InvalidManifest_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		errMsg   string
+	}{
+		{"Not an array", `{"path": "a"}`, "expected an array"},
+		{"Malformed JSON", `[{"path": "a"},`, "failed to decode manifest entry #1"},
+		{"Absolute path", `[{"path": "a"}, {"path": "/etc/passwd"}]`, "manifest entry #1"},
+		{"Escaping path", `[{"path": "../a"}]`, "not a relative path"},
+		{"Unknown type", `[{"path": "a", "type": "link"}]`, "unknown type"},
+		{"File and directory", `[{"path": "a"}, {"path": "a.txt"}, {"path": "a/"}]`, `"a" is both a file and a directory`},
+		{"File and parent", `[{"path": "a", "type": "file"}, {"path": "a-b"}, {"path": "a/b"}]`, `"a" is both a file and a directory`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/manifest.json", []byte(tt.manifest), 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+			err := prog.Recreate(t.Context(), "/manifest.json", "/out.tar.gz")
+			require.ErrorContains(t, err, tt.errMsg)
+
+			_, err = fs.Stat("/out.tar.gz")
+			require.ErrorIs(t, err, os.ErrNotExist)
+		})
+	}
+}
+
+// Expectation: A missing manifest should raise the appropriate error.
+func Test_Program_Recreate_ManifestMissing_Error(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.Recreate(t.Context(), "/manifest.json", "/out.tar.gz")
+	require.ErrorContains(t, err, "failed to open manifest")
+}
+
+// Expectation: Only the missing parent directories should be returned.
+func Test_manifestParents_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		dirs []string
+		want []string
+	}{
+		{"Top-level file", "a.txt", nil, nil},
+		{"Top-level directory", "a/", nil, nil},
+		{"Nested without parents", "a/b/c.txt", nil, []string{"a/", "a/b/"}},
+		{"Nested with some parents", "a/b/c.txt", []string{"a/"}, []string{"a/b/"}},
+		{"Nested with all parents", "a/b/c.txt", []string{"a/", "a/b/"}, nil},
+		{"Nested directory", "a/b/", []string{"a/"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, manifestParents(tt.path, tt.dirs))
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// pathMappingSeparator separates the prefixes of a [PathMapping] (as for --map-old).
+const pathMappingSeparator = "=>"
+
+var errInvalidPathMapping = errors.New("invalid path mapping")
+
+// PathMapping is a rewrite of the leading path components of the paths of a
+// source, replacing the From prefix with the To prefix (empty: removing it).
+type PathMapping struct {
+	From string // Leading path components to replace (never empty)
+	To   string // Leading path components to replace them with (empty: none)
+}
+
+// parsePathMapping returns the [PathMapping] of an argument in the FROM=>TO
+// format (as for --map-old), with both prefixes being relative paths.
+func parsePathMapping(arg string) (PathMapping, error) {
+	from, to, ok := strings.Cut(arg, pathMappingSeparator)
+	if !ok {
+		return PathMapping{}, fmt.Errorf("%w: %q (expected FROM=>TO)", errInvalidPathMapping, arg)
+	}
+
+	from, to = path.Clean(strings.Trim(from, "/")), path.Clean(strings.Trim(to, "/"))
+	if to == "." {
+		to = ""
+	}
+
+	for _, p := range []string{from, to} {
+		if p == ".." || strings.HasPrefix(p, "../") {
+			return PathMapping{}, fmt.Errorf("%w: %q (prefixes must not escape the tree)", errInvalidPathMapping, arg)
+		}
+	}
+
+	if from == "." {
+		return PathMapping{}, fmt.Errorf("%w: %q (expected a non-empty FROM)", errInvalidPathMapping, arg)
+	}
+
+	return PathMapping{From: from, To: to}, nil
+}
+
+// parsePathMappings returns the [PathMapping] of every argument (see [parsePathMapping]).
+func parsePathMappings(args []string) ([]PathMapping, error) {
+	mappings := make([]PathMapping, 0, len(args))
+
+	for _, arg := range args {
+		m, err := parsePathMapping(arg)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+
+	return mappings, nil
+}
+
+// remapPath returns a path of a source (slash-separated, with directories
+// carrying a trailing slash) with the leading path components stripped (see
+// [ProgramConfig.StripComponents]) and then rewritten by the first matching
+// of the mappings, or false for a path that is stripped or mapped away
+// entirely (such as the directory that is the From prefix of a mapping).
+func remapPath(p string, strip int, mappings []PathMapping) (string, bool) {
+	isDir := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+
+	if strip > 0 {
+		parts := strings.SplitN(p, "/", strip+1)
+		if len(parts) <= strip {
+			return "", false
+		}
+		p = parts[strip]
+	}
+
+	for _, m := range mappings {
+		if p != m.From && !strings.HasPrefix(p, m.From+"/") {
+			continue
+		}
+
+		p = path.Join(m.To, strings.TrimPrefix(p[len(m.From):], "/"))
+		if p == "" {
+			return "", false
+		}
+
+		break
+	}
+
+	if isDir {
+		p += "/"
+	}
+
+	return p, true
+}
+
+// remapsPaths returns if the paths of the sources of diffs are remapped (see
+// [Program.remappedPathStream]) with either side's mappings or with stripping.
+func (prog *Program) remapsPaths() bool {
+	return prog.config.StripComponents > 0 || len(prog.config.MapsOld) > 0 || len(prog.config.MapsNew) > 0
+}
+
+// remappedPathStream returns a sorted stream of the paths of a sorted stream,
+// remapped with [remapPath] (keeping any metadata of the records as-is). As the
+// remapping changes their order, the paths are sorted again, with any paths
+// remapped onto the same path (from different directories) only streamed once.
+func (prog *Program) remappedPathStream(ctx context.Context, input <-chan string, inputErrs <-chan error, mappings []PathMapping) (<-chan string, <-chan error) {
+	paths := make(chan string, fsStreamBuffer)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		for record := range input {
+			p := recordPath(record)
+
+			mapped, ok := remapPath(p, prog.config.StripComponents, mappings)
+			if !ok {
+				continue
+			}
+
+			select {
+			case paths <- mapped + record[len(p):]:
+			case <-ctx.Done():
+				errs <- fmt.Errorf("failed to remap paths: %w", ctx.Err())
+
+				return
+			}
+		}
+
+		for err := range inputErrs {
+			if err != nil {
+				errs <- err
+
+				return
+			}
+		}
+	}()
+
+	sorted, sortErrs := extsortStringsFunc(ctx, paths, errs, prog.extSortConfig, prog.comparePaths)
+
+	return mergeSortedStreams(ctx, []<-chan string{sorted}, []<-chan error{sortErrs}, prog.comparePaths)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The mappings should be parsed into cleaned relative prefixes, or rejected.
+func Test_parsePathMapping_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want PathMapping
+		ok   bool
+	}{
+		{"Strip", "mnt/disk1=>", PathMapping{From: "mnt/disk1"}, true},
+		{"Rename", "/disk1/=>disk2/", PathMapping{From: "disk1", To: "disk2"}, true},
+		{"Nested", "a=>b/c", PathMapping{From: "a", To: "b/c"}, true},
+		{"Root target", "a=>/", PathMapping{From: "a"}, true},
+		{"No separator", "a=b", PathMapping{}, false},
+		{"Empty source", "=>b", PathMapping{}, false},
+		{"Root source", "/=>b", PathMapping{}, false},
+		{"Parent", "a=>../b", PathMapping{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePathMapping(tt.arg)
+			if !tt.ok {
+				require.ErrorIs(t, err, errInvalidPathMapping)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The paths should be stripped and then rewritten by the first matching mapping.
+func Test_remapPath_Table(t *testing.T) {
+	mappings := []PathMapping{{From: "mnt/disk1"}, {From: "old", To: "new/sub"}, {From: "old/x", To: "never"}}
+
+	tests := []struct {
+		name  string
+		path  string
+		strip int
+		want  string
+		ok    bool
+	}{
+		{"Unmapped", "data/a.txt", 0, "data/a.txt", true},
+		{"Mapped away", "mnt/disk1/a.txt", 0, "a.txt", true},
+		{"Mapped directory", "mnt/disk1/b/", 0, "b/", true},
+		{"Prefix itself", "mnt/disk1/", 0, "", false},
+		{"Parent of prefix", "mnt/", 0, "mnt/", true},
+		{"Partial component", "mnt/disk10/a.txt", 0, "mnt/disk10/a.txt", true},
+		{"Renamed", "old/x/a.txt", 0, "new/sub/x/a.txt", true},
+		{"Renamed prefix itself", "old/", 0, "new/sub/", true},
+		{"Stripped", "root/a/b.txt", 1, "a/b.txt", true},
+		{"Stripped away", "root/", 1, "", false},
+		{"Stripped then mapped", "x/mnt/disk1/a.txt", 1, "a.txt", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := remapPath(tt.path, tt.strip, mappings)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: Archives of different mount points of the same data should not differ with mappings.
+func Test_Program_Diff_Maps_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"mnt/", "mnt/disk1/", "mnt/disk1/a.txt", "mnt/disk1/z/", "mnt/disk1/z/b.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"disk2/", "disk2/a.txt", "disk2/z/", "disk2/z/b.txt", "disk2/z/c.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{
+		MapsOld: []PathMapping{{From: "mnt/disk1"}, {From: "mnt"}},
+		MapsNew: []PathMapping{{From: "disk2"}},
+	})
+	result, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "+++ z/c.txt\n", stdoutBuf.String())
+	require.Equal(t, uint64(0), result.ExtraA)
+	require.Equal(t, uint64(1), result.ExtraB)
+}
+
+// Expectation: The paths of both sides should be compared with their leading components stripped.
+func Test_Program_Diff_StripComponents_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a/", "a/x.txt", "b/", "b/x.txt", "b/y.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"c/", "c/x.txt", "c/y.txt", "c/z.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{StripComponents: 1})
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "+++ z.txt\n", stdoutBuf.String())
+}
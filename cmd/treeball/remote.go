@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// The schemes of the remote sources, which are given as URLs with a path:
+// ssh://[user[:password]@]host[:port]/path for directories on SSH hosts
+// (read over SFTP), s3://bucket/prefix for S3-compatible object storage,
+// http(s)://host[:port]/path for archives published on web servers, and
+// rclone://remote/path for directories on any remotes configured with rclone.
+const (
+	sshScheme    = "ssh"
+	s3Scheme     = "s3"
+	httpScheme   = "http"
+	httpsScheme  = "https"
+	rcloneScheme = "rclone"
+)
+
+var (
+	errInvalidRemote      = errors.New("invalid remote source")
+	errUnknownRemote      = errors.New("unknown scheme of remote source")
+	errRemoteReadOnly     = errors.New("remote sources are read-only")
+	errRemoteNotConnected = errors.New("remote host is not connected")
+)
+
+// splitRemotePath splits the path of a remote source into its remote (i.e.
+// the scheme and authority, such as ssh://user@host or s3://bucket) and the
+// path on the remote, also for paths in the cleaned form (with "ssh:/" as
+// joined by [filepath.Join]). Local paths are returned as not remote.
+func splitRemotePath(name string) (string, string, bool) {
+	name = filepath.ToSlash(name)
+
+	for _, scheme := range []string{sshScheme, s3Scheme, httpScheme, httpsScheme, rcloneScheme} {
+		rest, ok := strings.CutPrefix(name, scheme+"://")
+		if !ok {
+			if rest, ok = strings.CutPrefix(name, scheme+":/"); !ok {
+				continue
+			}
+		}
+
+		authority, remotePath, _ := strings.Cut(rest, "/")
+
+		return scheme + "://" + authority, path.Clean("/" + remotePath), true
+	}
+
+	return "", "", false
+}
+
+// joinPath joins a directory and a name like [filepath.Join], but keeps any
+// remote source as such (e.g. s3://bucket/name rather than s3:/bucket/name).
+func joinPath(dir string, name string) string {
+	remote, remotePath, ok := splitRemotePath(dir)
+	if !ok {
+		return filepath.Join(dir, name)
+	}
+
+	return remote + path.Join(remotePath, name)
+}
+
+// dialRemote connects to a remote (as returned by [splitRemotePath]), returning
+// its filesystem and the connection, which is to be closed once no longer needed.
+func dialRemote(ctx context.Context, remote string) (afero.Fs, io.Closer, error) {
+	scheme, authority, _ := strings.Cut(remote, "://")
+
+	switch scheme {
+	case sshScheme:
+		return dialSFTP(ctx, authority)
+	case s3Scheme:
+		return dialS3(ctx, authority)
+	case httpScheme, httpsScheme:
+		return dialHTTP(ctx, remote)
+	case rcloneScheme:
+		return dialRclone(ctx, authority)
+	}
+
+	return nil, nil, fmt.Errorf("%w: %q", errUnknownRemote, remote)
+}
+
+// remoteFs is an [afero.Fs] serving the paths of any remote sources (as of
+// [splitRemotePath]) from the filesystems of their remotes, and all other paths
+// from the base filesystem, so that local and remote sources can be mixed.
+type remoteFs struct {
+	afero.Fs                     // Filesystem of the local paths
+	remotes  map[string]afero.Fs // Filesystems of the remotes (by remote)
+}
+
+// route returns the filesystem serving a path, and the path on that filesystem.
+func (r *remoteFs) route(op string, name string) (afero.Fs, string, error) {
+	remote, remotePath, ok := splitRemotePath(name)
+	if !ok {
+		return r.Fs, name, nil
+	}
+
+	fsys, ok := r.remotes[remote]
+	if !ok {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: errRemoteNotConnected}
+	}
+
+	return fsys, remotePath, nil
+}
+
+func (r *remoteFs) Create(name string) (afero.File, error) {
+	fsys, p, err := r.route("create", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fsys.Create(p) //nolint:wrapcheck
+}
+
+func (r *remoteFs) Mkdir(name string, perm os.FileMode) error {
+	fsys, p, err := r.route("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	return fsys.Mkdir(p, perm) //nolint:wrapcheck
+}
+
+func (r *remoteFs) MkdirAll(name string, perm os.FileMode) error {
+	fsys, p, err := r.route("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	return fsys.MkdirAll(p, perm) //nolint:wrapcheck
+}
+
+func (r *remoteFs) Open(name string) (afero.File, error) {
+	fsys, p, err := r.route("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fsys.Open(p) //nolint:wrapcheck
+}
+
+func (r *remoteFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	fsys, p, err := r.route("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fsys.OpenFile(p, flag, perm) //nolint:wrapcheck
+}
+
+func (r *remoteFs) Remove(name string) error {
+	fsys, p, err := r.route("remove", name)
+	if err != nil {
+		return err
+	}
+
+	return fsys.Remove(p) //nolint:wrapcheck
+}
+
+func (r *remoteFs) RemoveAll(name string) error {
+	fsys, p, err := r.route("remove", name)
+	if err != nil {
+		return err
+	}
+
+	return fsys.RemoveAll(p) //nolint:wrapcheck
+}
+
+func (r *remoteFs) Rename(oldname string, newname string) error {
+	oldFs, oldPath, err := r.route("rename", oldname)
+	if err != nil {
+		return err
+	}
+
+	newFs, newPath, err := r.route("rename", newname)
+	if err != nil {
+		return err
+	}
+
+	if oldFs != newFs {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errRemoteReadOnly}
+	}
+
+	return oldFs.Rename(oldPath, newPath) //nolint:wrapcheck
+}
+
+func (r *remoteFs) Stat(name string) (os.FileInfo, error) {
+	fsys, p, err := r.route("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fsys.Stat(p) //nolint:wrapcheck
+}
+
+// LstatIfPossible returns the [os.FileInfo] of a path, without following a
+// symbolic link at the path, where the serving filesystem supports it.
+func (r *remoteFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	fsys, p, err := r.route("lstat", name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if lst, ok := fsys.(afero.Lstater); ok {
+		return lst.LstatIfPossible(p) //nolint:wrapcheck
+	}
+
+	info, err := fsys.Stat(p)
+
+	return info, false, err //nolint:wrapcheck
+}
+
+func (r *remoteFs) Name() string {
+	return "remoteFs"
+}
+
+func (r *remoteFs) Chmod(name string, mode os.FileMode) error {
+	fsys, p, err := r.route("chmod", name)
+	if err != nil {
+		return err
+	}
+
+	return fsys.Chmod(p, mode) //nolint:wrapcheck
+}
+
+func (r *remoteFs) Chown(name string, uid int, gid int) error {
+	fsys, p, err := r.route("chown", name)
+	if err != nil {
+		return err
+	}
+
+	return fsys.Chown(p, uid, gid) //nolint:wrapcheck
+}
+
+func (r *remoteFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	fsys, p, err := r.route("chtimes", name)
+	if err != nil {
+		return err
+	}
+
+	return fsys.Chtimes(p, atime, mtime) //nolint:wrapcheck
+}
+
+// remoteWalker is a [Walker] walking the remote sources with the remote one,
+// and all other paths with the local one (as chosen for the base filesystem).
+type remoteWalker struct {
+	local  Walker
+	remote Walker
+}
+
+// WalkDir is a method that walks the file tree with the semantics of [filepath.WalkDir].
+func (w remoteWalker) WalkDir(root string, fn fs.WalkDirFunc) error {
+	if _, _, ok := splitRemotePath(root); ok {
+		return w.remote.WalkDir(root, fn) //nolint:wrapcheck
+	}
+
+	return w.local.WalkDir(root, fn) //nolint:wrapcheck
+}
+
+// withRemotes returns a copy of the program able to access any remote sources
+// among the paths (plain or in the dir:Prefix=/path format), having connected
+// to their remotes (see [dialRemote]), along with a function closing all of the
+// connections once done. Without any remote sources, the program is returned.
+func (prog *Program) withRemotes(ctx context.Context, paths ...string) (*Program, func(), error) {
+	base := prog.fs
+	remotes := map[string]afero.Fs{}
+
+	if rfs, ok := prog.fs.(*remoteFs); ok {
+		base = rfs.Fs
+		maps.Copy(remotes, rfs.remotes)
+	}
+
+	var closers []io.Closer
+
+	closeAll := func() {
+		for _, c := range closers {
+			_ = c.Close()
+		}
+	}
+
+	for _, p := range paths {
+		if src, err := parseSource(p); err == nil {
+			p = src.path
+		}
+
+		remote, _, ok := splitRemotePath(p)
+		if !ok || remotes[remote] != nil {
+			continue
+		}
+
+		fsys, c, err := dialRemote(ctx, remote)
+		if err != nil {
+			closeAll()
+
+			return nil, nil, err
+		}
+
+		remotes[remote] = fsys
+		closers = append(closers, c)
+	}
+
+	if len(closers) == 0 {
+		return prog, func() {}, nil
+	}
+
+	rp := *prog
+	rp.fs = &remoteFs{Fs: base, remotes: remotes}
+	rp.fsWalker = newWalker(rp.fs, rp.config)
+
+	return &rp, closeAll, nil
+}
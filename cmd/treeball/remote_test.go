@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to return a filesystem with a remote host (tester@nas) serving a tree at /data.
+func newTestRemoteFs(t *testing.T) (*remoteFs, afero.Fs) {
+	t.Helper()
+
+	remote := newTreeFs(t, "/data/dir/a.txt", "/data/b.txt")
+
+	return &remoteFs{Fs: afero.NewMemMapFs(), remotes: map[string]afero.Fs{"ssh://tester@nas": remote}}, remote
+}
+
+// Expectation: Remote paths should be split into their remote and remote path, also in cleaned form.
+func Test_splitRemotePath_Table(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		remote     string
+		remotePath string
+		ok         bool
+	}{
+		{"Plain", "ssh://tester@nas/data", "ssh://tester@nas", "/data", true},
+		{"With port", "ssh://tester@nas:2222/data/dir", "ssh://tester@nas:2222", "/data/dir", true},
+		{"Cleaned", "ssh:/tester@nas/data/dir/a.txt", "ssh://tester@nas", "/data/dir/a.txt", true},
+		{"Without path", "ssh://nas", "ssh://nas", "/", true},
+		{"Bucket", "s3://bucket/prefix/a.txt", "s3://bucket", "/prefix/a.txt", true},
+		{"Bucket cleaned", "s3:/bucket", "s3://bucket", "/", true},
+		{"Web server", "https://host:8443/snapshots/snap.tar.gz", "https://host:8443", "/snapshots/snap.tar.gz", true},
+		{"Rclone remote", "rclone://gdrive/Media", "rclone://gdrive", "/Media", true},
+		{"Local", "/data/dir", "", "", false},
+		{"Relative", "ssh/data", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, remotePath, ok := splitRemotePath(tt.path)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.remote, remote)
+			require.Equal(t, tt.remotePath, remotePath)
+		})
+	}
+}
+
+// Expectation: An archive should be created from a remote tree into a local file, also walked in parallel.
+func Test_Program_Create_Remote_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+	}{
+		{"Serial", 0},
+		{"Parallel", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, _ := newTestRemoteFs(t)
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{WalkWorkers: tt.workers})
+			require.NoError(t, prog.Create(t.Context(), "ssh://tester@nas/data", "/out.tar.gz", nil))
+
+			require.Equal(t, []string{"b.txt", "dir/", "dir/a.txt"}, readTarNames(t, fs.Fs, "/out.tar.gz"))
+		})
+	}
+}
+
+// Expectation: A local archive should be compared against a remote tree.
+func Test_Program_Diff_Remote_Success(t *testing.T) {
+	fs, _ := newTestRemoteFs(t)
+	require.NoError(t, afero.WriteFile(fs.Fs, "/old.tar.gz", createTar([]string{"b.txt", "c.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "ssh://tester@nas/data", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+
+	require.Equal(t, "--- c.txt\n+++ dir/\n+++ dir/a.txt\n", stdoutBuf.String())
+}
+
+// Expectation: A remote tree should be merged under its prefix with a local one.
+func Test_Program_Check_RemotePrefixed_Success(t *testing.T) {
+	fs, _ := newTestRemoteFs(t)
+	require.NoError(t, fs.Fs.MkdirAll("/local", 0o755))
+	require.NoError(t, afero.WriteFile(fs.Fs, "/local/x.txt", nil, 0o644))
+	require.NoError(t, afero.WriteFile(fs.Fs, "/archive.tar.gz", createTar([]string{"l/", "l/x.txt", "r/", "r/b.txt", "r/dir/", "r/dir/a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Check(t.Context(), "/archive.tar.gz", []string{"dir:l=/local", "dir:r=ssh://tester@nas/data"}, nil)
+	require.NoError(t, err)
+}
+
+// Expectation: The paths of a host not connected should be returned as an error.
+func Test_remoteFs_NotConnected_Error(t *testing.T) {
+	fs, _ := newTestRemoteFs(t)
+
+	_, err := fs.Stat("ssh://other@nas/data")
+	require.ErrorIs(t, err, errRemoteNotConnected)
+}
+
+// Expectation: A file should not be renamed between a local and a remote filesystem.
+func Test_remoteFs_Rename_Error(t *testing.T) {
+	fs, _ := newTestRemoteFs(t)
+
+	err := fs.Rename("ssh://tester@nas/data/b.txt", "/b.txt")
+	require.ErrorIs(t, err, errRemoteReadOnly)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// RestorePolicy is the policy of a [Program.Restore] operation for the entries
+// of which the paths exist already in the destination (see [ProgramConfig.OnExists]).
+type RestorePolicy int
+
+const (
+	// RestoreFail fails upon the first existing path (so nothing is replaced by mistake).
+	RestoreFail RestorePolicy = iota
+
+	// RestoreSkip leaves any existing paths as they are (restoring only missing ones).
+	RestoreSkip
+
+	// RestoreOverwrite replaces any existing paths (but never existing directories).
+	RestoreOverwrite
+
+	// RestoreRename restores the entries under a free name next to existing paths.
+	RestoreRename
+)
+
+// restoreRenameSuffix is the suffix of the names of entries restored next to
+// existing paths (see [RestoreRename]), followed by a counter if already taken.
+const restoreRenameSuffix = ".restored"
+
+var (
+	errInvalidRestorePolicy = errors.New("invalid restore policy")
+	errRestoreDirExists     = errors.New("refusing to overwrite an existing directory")
+	errRestoreSymlink       = errors.New("refusing to restore through a symbolic link")
+)
+
+// parseRestorePolicy returns the [RestorePolicy] for a policy name (as for --on-exists).
+func parseRestorePolicy(name string) (RestorePolicy, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return RestoreFail, nil
+	case "skip":
+		return RestoreSkip, nil
+	case "overwrite":
+		return RestoreOverwrite, nil
+	case "rename":
+		return RestoreRename, nil
+	default:
+		return RestoreFail, fmt.Errorf("%w: %q (expected skip, overwrite or rename)", errInvalidRestorePolicy, name)
+	}
+}
+
+// RestoreResult holds the amounts of entries of a [Program.Restore] operation.
+type RestoreResult struct {
+	Restored    int64 // Entries created in the destination (including renamed ones)
+	Skipped     int64 // Entries left out as existing (including the subtrees of directories)
+	Overwritten int64 // Entries replacing existing paths
+	Renamed     int64 // Entries restored under a free name next to existing paths
+}
+
+// Restore materializes the tree of a given tarball in a destination directory,
+// with its directories and its files as zero-byte placeholders (as they are in
+// the tarball), so that a placeholder tree can be merged into a partially
+// existing destination (such as re-seeding a media library after data loss).
+//
+// The input parameter specifies the path to the tarball, the dest parameter the
+// directory to restore into, which is created if missing. Any paths matching the
+// excludes slice are skipped. Existing directories are merged into, while any
+// other existing paths are handled as per [ProgramConfig.OnExists], failing with
+// an error wrapping [ErrOutputExists] by default. A directory entry which is
+// skipped (as a non-directory exists at its path) is so along with its subtree,
+// and one which is renamed receives its subtree under its new name. The paths of
+// the restored entries are printed to standard output (relative to dest), with a
+// summary at the end. The ctx parameter controls early cancellation.
+func (prog *Program) Restore(ctx context.Context, input string, dest string, excludes []string) (*RestoreResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	progressFrom(ctx).setPhase("restoring %s into %s", input, dest)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input, dest)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRemotes()
+
+	if err := prog.fs.MkdirAll(dest, 0o777); err != nil { //nolint:mnd
+		return nil, fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	// The sorted stream has any parent directories before their contents,
+	// so that these are always created (or skipped, or renamed) first.
+	paths, errs := prog.tarPathStream(ctx, input, true, excludes)
+
+	r := &restorer{prog: prog, dest: dest, moved: make(map[string]string), dirs: make(map[string]struct{}), result: &RestoreResult{}}
+
+	for p := range paths {
+		if err := r.restore(p); err != nil {
+			return r.result, err
+		}
+	}
+
+	for err := range errs {
+		if err != nil {
+			return r.result, fmt.Errorf("failure during restore: %w", err)
+		}
+	}
+
+	prog.infof("restored %d entries into %q (skipped: %d, overwritten: %d, renamed: %d)",
+		r.result.Restored, dest, r.result.Skipped, r.result.Overwritten, r.result.Renamed)
+
+	return r.result, nil
+}
+
+// restorer restores the entries of a tarball one after another (see [Program.Restore]).
+type restorer struct {
+	prog   *Program
+	dest   string
+	moved  map[string]string   // Renamed (or skipped, as empty) directories, by their paths in the tarball
+	dirs   map[string]struct{} // Parent directories checked to not be symbolic links (see checkParents)
+	result *RestoreResult
+}
+
+// restore restores an entry of the tarball at its path (with a trailing slash
+// for directories), as relocated under any renamed parent directory.
+func (r *restorer) restore(p string) error {
+	isDir := strings.HasSuffix(p, "/")
+	name := strings.TrimSuffix(p, "/")
+
+	target, ok := r.relocate(name)
+	if !ok {
+		r.result.Skipped++
+
+		return nil
+	}
+
+	abs := filepath.Join(r.dest, filepath.FromSlash(target))
+
+	if err := r.checkParents(target, abs); err != nil {
+		return err
+	}
+
+	info, err := lstatIfPossible(r.prog.fs, abs)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r.create(abs, target, isDir)
+	} else if err != nil {
+		return fmt.Errorf("failed to stat destination path: %w", err)
+	}
+
+	if isDir && info.IsDir() {
+		return nil // Merged into.
+	}
+
+	switch r.prog.config.OnExists {
+	case RestoreSkip:
+		if isDir {
+			r.moved[name] = ""
+		}
+		r.result.Skipped++
+
+		return nil
+
+	case RestoreOverwrite:
+		if info.IsDir() {
+			return fmt.Errorf("%w: %s", errRestoreDirExists, abs)
+		}
+
+		if err := r.prog.fs.Remove(abs); err != nil {
+			return fmt.Errorf("failed to remove existing path: %w", err)
+		}
+		r.result.Overwritten++
+
+		return r.create(abs, target, isDir)
+
+	case RestoreRename:
+		free, err := r.freeName(abs)
+		if err != nil {
+			return err
+		}
+
+		renamed := path.Join(path.Dir(target), filepath.Base(free))
+		if isDir {
+			r.moved[name] = renamed
+		}
+		r.result.Renamed++
+
+		return r.create(free, renamed, isDir)
+
+	default:
+		return fmt.Errorf("%w: %s (use --on-exists or --only-missing)", ErrOutputExists, abs)
+	}
+}
+
+// relocate returns the path of an entry relative to the destination, which is
+// under the new name of any renamed parent directory, or false if any parent
+// directory was skipped (along with its subtree).
+func (r *restorer) relocate(name string) (string, bool) {
+	if len(r.moved) == 0 {
+		return name, true
+	}
+
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if moved, ok := r.moved[dir]; ok {
+			if moved == "" {
+				return "", false
+			}
+
+			return moved + strings.TrimPrefix(name, dir), true
+		}
+	}
+
+	return name, true
+}
+
+// checkParents returns an error wrapping [errRestoreSymlink] if any existing
+// parent directory of an entry (relative to the destination) is a symbolic link,
+// as the entry would otherwise be created (or overwritten) wherever it points to,
+// including outside of the destination. Any missing parent directories are yet
+// to be created as such. Those checked are remembered for the entries to come.
+func (r *restorer) checkParents(rel string, abs string) error {
+	dir := path.Dir(rel)
+	if dir == "." {
+		return nil
+	}
+
+	var parent string
+
+	for part := range strings.SplitSeq(dir, "/") {
+		parent = path.Join(parent, part)
+
+		if _, ok := r.dirs[parent]; ok {
+			continue
+		}
+
+		info, err := lstatIfPossible(r.prog.fs, filepath.Join(r.dest, filepath.FromSlash(parent)))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to stat destination path: %w", err)
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s (at %s)", errRestoreSymlink, abs, parent)
+		}
+
+		r.dirs[parent] = struct{}{}
+	}
+
+	return nil
+}
+
+// create creates an entry at the absolute path, being either a directory or an
+// empty placeholder file, and prints its path relative to the destination. Any
+// parent directories missing from the tarball (as in foreign ones) are created.
+func (r *restorer) create(abs string, rel string, isDir bool) error {
+	if err := r.prog.fs.MkdirAll(filepath.Dir(abs), 0o777); err != nil { //nolint:mnd
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if isDir {
+		if err := r.prog.fs.Mkdir(abs, 0o777); err != nil { //nolint:mnd
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	} else {
+		f, err := r.prog.fs.OpenFile(abs, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666) //nolint:mnd
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to close file: %w", err)
+		}
+	}
+
+	r.result.Restored++
+
+	r.prog.printPath(filepath.FromSlash(rel))
+
+	return nil
+}
+
+// freeName returns the first free name out of path.restored, path.restored.1,
+// path.restored.2 and so on, for restoring an entry next to an existing path.
+func (r *restorer) freeName(abs string) (string, error) {
+	free := abs + restoreRenameSuffix
+
+	for i := 1; ; i++ {
+		if _, err := lstatIfPossible(r.prog.fs, free); errors.Is(err, fs.ErrNotExist) {
+			return free, nil
+		} else if err != nil {
+			return "", fmt.Errorf("failed to stat destination path: %w", err)
+		}
+
+		free = fmt.Sprintf("%s%s.%d", abs, restoreRenameSuffix, i)
+	}
+}
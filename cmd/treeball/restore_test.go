@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The restore policies should be parsed from their names.
+func Test_parseRestorePolicy_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    RestorePolicy
+		wantErr bool
+	}{
+		{"", RestoreFail, false},
+		{"skip", RestoreSkip, false},
+		{"OVERWRITE", RestoreOverwrite, false},
+		{"rename", RestoreRename, false},
+		{"merge", RestoreFail, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRestorePolicy(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidRestorePolicy)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: The tree should be restored into a missing destination, with its files as placeholders.
+func Test_Program_Restore_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"b/", "b/x.txt", "a.txt", "b/c/"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	res, err := prog.Restore(t.Context(), "/in.tar.gz", "/dest", nil)
+	require.NoError(t, err)
+	require.Equal(t, &RestoreResult{Restored: 4}, res)
+	require.Equal(t, "a.txt\nb\nb/c\nb/x.txt\n", stdoutBuf.String())
+
+	info, err := fs.Stat("/dest/b/x.txt")
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	info, err = fs.Stat("/dest/b/c")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+}
+
+// Expectation: Existing directories should be merged into, but any other existing paths should fail by default.
+func Test_Program_Restore_Exists_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"b/", "b/x.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/dest/b/x.txt", []byte("data"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Restore(t.Context(), "/in.tar.gz", "/dest", nil)
+	require.ErrorIs(t, err, ErrOutputExists)
+
+	data, err := afero.ReadFile(fs, "/dest/b/x.txt")
+	require.NoError(t, err)
+	require.Equal(t, "data", string(data))
+}
+
+// Expectation: Existing paths should be left as they are, along with the subtrees of directory entries.
+func Test_Program_Restore_Skip_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt", "c.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/dest/a.txt", []byte("data"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/dest/b", []byte("file"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{OnExists: RestoreSkip})
+
+	res, err := prog.Restore(t.Context(), "/in.tar.gz", "/dest", nil)
+	require.NoError(t, err)
+	require.Equal(t, &RestoreResult{Restored: 1, Skipped: 3}, res)
+	require.Equal(t, "c.txt\n", stdoutBuf.String())
+
+	data, err := afero.ReadFile(fs, "/dest/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "data", string(data))
+}
+
+// Expectation: Existing files should be replaced by their placeholders, but existing directories never.
+func Test_Program_Restore_Overwrite_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/dest/a.txt", []byte("data"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{OnExists: RestoreOverwrite})
+
+	res, err := prog.Restore(t.Context(), "/in.tar.gz", "/dest", nil)
+	require.NoError(t, err)
+	require.Equal(t, &RestoreResult{Restored: 1, Overwritten: 1}, res)
+
+	info, err := fs.Stat("/dest/a.txt")
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	require.NoError(t, fs.MkdirAll("/dest/b.txt", 0o755))
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"b.txt"}), 0o644))
+
+	_, err = prog.Restore(t.Context(), "/in.tar.gz", "/dest", nil)
+	require.ErrorIs(t, err, errRestoreDirExists)
+}
+
+// Expectation: Entries should be restored next to existing paths, with the subtrees of directories under their new names.
+func Test_Program_Restore_Rename_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/dest/a.txt", []byte("data"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/dest/a.txt.restored", []byte("data"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/dest/b", []byte("file"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{OnExists: RestoreRename})
+
+	res, err := prog.Restore(t.Context(), "/in.tar.gz", "/dest", nil)
+	require.NoError(t, err)
+	require.Equal(t, &RestoreResult{Restored: 3, Renamed: 2}, res)
+	require.Equal(t, "a.txt.restored.1\nb.restored\nb.restored/x.txt\n", stdoutBuf.String())
+
+	data, err := afero.ReadFile(fs, "/dest/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "data", string(data))
+
+	_, err = fs.Stat("/dest/b.restored/x.txt")
+	require.NoError(t, err)
+}
+
+// Expectation: Entries should never be restored (or overwritten) through a symbolic link within the destination.
+func Test_Program_Restore_Symlink_Error(t *testing.T) {
+	base := t.TempDir()
+	dest := filepath.Join(base, "dest")
+	outside := filepath.Join(base, "outside")
+
+	require.NoError(t, os.MkdirAll(dest, 0o755))
+	require.NoError(t, os.MkdirAll(outside, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "victim"), []byte("data"), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join("..", "outside"), filepath.Join(dest, "a")))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "in.tar.gz"), createTar([]string{"a/new", "a/victim"}), 0o644))
+
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{OnExists: RestoreOverwrite})
+
+	res, err := prog.Restore(t.Context(), filepath.Join(base, "in.tar.gz"), dest, nil)
+	require.ErrorIs(t, err, errRestoreSymlink)
+	require.Equal(t, &RestoreResult{}, res)
+
+	data, err := os.ReadFile(filepath.Join(outside, "victim"))
+	require.NoError(t, err)
+	require.Equal(t, "data", string(data))
+
+	_, err = os.Lstat(filepath.Join(outside, "new"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: The 'restore' command should restore only the missing paths with --only-missing.
+func Test_CLI_Restore_OnlyMissing_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/in.tar.gz", createTar([]string{"a.txt", "b/", "b/x.txt", "c/"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/dest/b/x.txt", []byte("data"), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), fs, &stdoutBuf, io.Discard)
+	cmd.SetArgs([]string{"restore", "/in.tar.gz", "/dest", "--only-missing", "--exclude=c/"})
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "a.txt\n", stdoutBuf.String())
+
+	_, err := fs.Stat("/dest/c")
+	require.Error(t, err)
+}
+
+// Expectation: The 'restore' command should reject an unknown restore policy.
+func Test_CLI_Restore_InvalidPolicy_Error(t *testing.T) {
+	cmd := newRootCmd(t.Context(), afero.NewMemMapFs(), io.Discard, io.Discard)
+	cmd.SetArgs([]string{"restore", "/in.tar.gz", "/dest", "--on-exists=merge"})
+	require.ErrorIs(t, cmd.Execute(), errInvalidRestorePolicy)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const checkpointSuffix = ".checkpoint"
+
+var errNoCheckpoint = errors.New("no checkpoint to resume from")
+
+// createCheckpoint is a persisted resumption point of a [Program.Create] operation.
+//
+// The entries of a filesystem walk are always written in the same, well-defined
+// order (sorted by name within each directory, with directories preceding their
+// contents), so that everything up to the last written path is known to be
+// contained within the output file up to the offset (ending a gzip member).
+type createCheckpoint struct {
+	Input    string `json:"input"`    // Absolute path of the root directory being packaged
+	LastPath string `json:"lastPath"` // Relative path of the last entry written before the checkpoint
+	Offset   int64  `json:"offset"`   // Size of the output file at the checkpoint (a gzip member boundary)
+	Entries  int64  `json:"entries"`  // Amount of entries written before the checkpoint
+}
+
+// checkpointPath returns the path of the checkpoint file of an output file.
+func checkpointPath(output string) string {
+	return output + checkpointSuffix
+}
+
+// readCheckpoint reads the checkpoint file of an output file.
+// An error wrapping [errNoCheckpoint] is returned if there is none.
+func (prog *Program) readCheckpoint(output string) (*createCheckpoint, error) {
+	data, err := afero.ReadFile(prog.fs, checkpointPath(output))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", errNoCheckpoint, checkpointPath(output))
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var cp createCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+
+	return &cp, nil
+}
+
+// writeCheckpoint writes the checkpoint file of an output file.
+// It is first written to a temporary file, which then replaces the previous
+// one, so that an interruption never leaves behind a torn checkpoint file.
+func (prog *Program) writeCheckpoint(output string, cp createCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	tmp := checkpointPath(output) + ".tmp"
+
+	if err := afero.WriteFile(prog.fs, tmp, data, 0o644); err != nil { //nolint:mnd
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	if err := prog.fs.Rename(tmp, checkpointPath(output)); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// resumeSkip returns if an entry was already written before the checkpoint
+// with the given last path, along with the [fs.WalkDirFunc] result for it.
+// Directories written before are only descended into if they contain the last
+// path, since all of the contents of any others were written before as well.
+func resumeSkip(relPath string, d fs.DirEntry, lastPath string) (bool, error) {
+	if c := compareWalkOrder(relPath, lastPath); c > 0 {
+		return false, nil
+	} else if c == 0 || !d.IsDir() {
+		return true, nil
+	}
+
+	if strings.HasPrefix(lastPath, relPath+string(filepath.Separator)) {
+		return true, nil
+	}
+
+	return true, filepath.SkipDir
+}
+
+// compareWalkOrder compares two relative paths by the order in which they are
+// encountered during a filesystem walk, so comparing them element by element.
+func compareWalkOrder(a string, b string) int {
+	return compareElements(a, b, string(filepath.Separator))
+}
+
+// compareTarOrder is a variant of [compareWalkOrder] for the paths of tarballs,
+// which are always slash-separated (with directories carrying a trailing slash).
+func compareTarOrder(a string, b string) int {
+	return compareElements(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"), "/")
+}
+
+func compareElements(a string, b string, sep string) int {
+	as := strings.Split(a, sep)
+	bs := strings.Split(b, sep)
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+
+	return len(as) - len(bs)
+}
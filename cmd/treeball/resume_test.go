@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// The paths of the tree for the resumption tests.
+var resumeTree = []string{"/src/a.txt", "/src/b/c.txt", "/src/b/d/e.txt", "/src/b/f.txt", "/src/g.txt", "/src/h/i.txt"}
+
+// Expectation: An interrupted creation should be continued from its last checkpoint.
+func Test_Program_Create_Resume_Success(t *testing.T) {
+	fs := newTreeFs(t, resumeTree...)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Create(t.Context(), "/src", "/want.tar.gz", nil))
+
+	prog = NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{CheckpointEvery: 2})
+	prog.fsWalker = failingPathWalker{walker: prog.fsWalker, path: "/src/b/f.txt"}
+
+	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorContains(t, err, "simulated permission denied")
+
+	cp, err := prog.readCheckpoint("/out.tar.gz")
+	require.NoError(t, err)
+	require.Equal(t, "b/d", cp.LastPath)
+	require.Equal(t, int64(4), cp.Entries)
+
+	prog = NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{CheckpointEvery: 2, Resume: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	require.Equal(t, readTarNames(t, fs, "/want.tar.gz"), readTarNames(t, fs, "/out.tar.gz"))
+
+	_, err = fs.Stat(checkpointPath("/out.tar.gz"))
+	require.Error(t, err)
+}
+
+// Expectation: An interrupted creation should be removed, as it is not checkpointed by default.
+func Test_Program_Create_NoCheckpoints_Error(t *testing.T) {
+	fs := newTreeFs(t, resumeTree...)
+
+	cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+	createCmd, _, err := cmd.Find([]string{"create"})
+	require.NoError(t, err)
+	require.Equal(t, "0", createCmd.Flags().Lookup("checkpoint-every").DefValue)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	prog.fsWalker = failingPathWalker{walker: prog.fsWalker, path: "/src/b/f.txt"}
+
+	err = prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorContains(t, err, "simulated permission denied")
+
+	_, err = fs.Stat("/out.tar.gz")
+	require.Error(t, err)
+
+	_, err = fs.Stat(checkpointPath("/out.tar.gz"))
+	require.Error(t, err)
+}
+
+// Expectation: Checkpoints and resuming should be rejected for split archives upon evaluating the flags.
+func Test_newCreateCmd_SplitCheckpoints_Error(t *testing.T) {
+	for _, flag := range []string{"--checkpoint-every=2", "--resume"} {
+		t.Run(flag, func(t *testing.T) {
+			fs := newTreeFs(t, resumeTree...)
+
+			cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+			cmd.SetArgs([]string{"create", "/src", "/out.tar.gz", "--split-size=1M", flag})
+
+			err := cmd.Execute()
+			require.ErrorContains(t, err, "split-size")
+
+			_, err = fs.Stat(splitPartPath("/out.tar.gz", 0))
+			require.Error(t, err)
+		})
+	}
+}
+
+// Expectation: Resuming should fail without a checkpoint or for another input.
+func Test_Program_Create_Resume_Error(t *testing.T) {
+	fs := newTreeFs(t, resumeTree...)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Resume: true})
+
+	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorIs(t, err, errNoCheckpoint)
+
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", []byte{}, 0o644))
+	require.NoError(t, prog.writeCheckpoint("/out.tar.gz", createCheckpoint{Input: "/other", LastPath: "a.txt"}))
+
+	err = prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorContains(t, err, "checkpoint is of another input")
+
+	require.NoError(t, prog.writeCheckpoint("/out.tar.gz", createCheckpoint{Input: "/src", LastPath: "a.txt", Offset: 100}))
+
+	err = prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorContains(t, err, "smaller than at its checkpoint")
+}
+
+// Expectation: An interrupted creation should point to resuming instead of overwriting.
+func Test_Program_Create_ResumeHint_Error(t *testing.T) {
+	fs := newTreeFs(t, resumeTree...)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", []byte{}, 0o644))
+	require.NoError(t, prog.writeCheckpoint("/out.tar.gz", createCheckpoint{Input: "/src", LastPath: "a.txt"}))
+
+	err := prog.Create(t.Context(), "/src", "/out.tar.gz", nil)
+	require.ErrorIs(t, err, ErrOutputExists)
+	require.ErrorContains(t, err, "--resume")
+}
+
+// Expectation: The paths should be compared in the order of a filesystem walk.
+func Test_compareWalkOrder_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{"Equal", "a/b", "a/b", 0},
+		{"Parent before child", "a", "a/b", -1},
+		{"Child after parent", "a/b", "a", 1},
+		{"Siblings by name", "a/b", "a/c", -1},
+		{"Directory contents before later sibling", "a/z", "a.txt", -1},
+		{"Later sibling after directory contents", "b", "a/z/z", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareWalkOrder(tt.a, tt.b)
+
+			switch {
+			case tt.want < 0:
+				require.Negative(t, got)
+			case tt.want > 0:
+				require.Positive(t, got)
+			default:
+				require.Zero(t, got)
+			}
+		})
+	}
+}
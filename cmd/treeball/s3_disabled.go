@@ -0,0 +1,21 @@
+//go:build minimal || no_s3
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/spf13/afero"
+)
+
+const featureS3 = false
+
+var errS3Disabled = errors.New("s3:// sources are not compiled into this build")
+
+// dialS3 is a stub for builds without the s3 feature compiled in.
+// It always returns an error, as no object storage can be connected in such a build.
+func dialS3(_ context.Context, _ string) (afero.Fs, io.Closer, error) {
+	return nil, nil, errS3Disabled
+}
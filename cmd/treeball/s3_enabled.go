@@ -0,0 +1,449 @@
+//go:build !minimal && !no_s3
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+	"github.com/spf13/afero"
+)
+
+const featureS3 = true
+
+// defaultS3Endpoint is the endpoint of the object storage, unless set in the environment.
+const defaultS3Endpoint = "https://s3.amazonaws.com"
+
+// s3PartSize is the size of the parts of uploaded objects, which are buffered
+// in memory (as the size of an archive being written is not known beforehand).
+const s3PartSize = 16 << 20
+
+// dialS3 connects to the S3-compatible object storage of a bucket (i.e. the
+// part of the s3:// source between the scheme and the path), returning its
+// filesystem and the connection (see [dialRemote]).
+//
+// The endpoint is taken from $AWS_ENDPOINT_URL_S3 or $AWS_ENDPOINT_URL (such as
+// http://localhost:9000 for a MinIO server), defaulting to Amazon S3 itself.
+// The region is taken from $AWS_REGION or $AWS_DEFAULT_REGION (if set), and
+// the credentials from the usual environment variables, the AWS credentials
+// file, or the instance metadata (in this order of precedence).
+func dialS3(ctx context.Context, bucket string) (afero.Fs, io.Closer, error) {
+	if err := s3utils.CheckValidBucketName(bucket); err != nil {
+		return nil, nil, fmt.Errorf("%w: %q (expected s3://bucket/prefix): %w", errInvalidRemote, bucket, err)
+	}
+
+	endpoint, err := url.Parse(cmp.Or(os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"), defaultS3Endpoint))
+	if err != nil || endpoint.Host == "" {
+		return nil, nil, fmt.Errorf("%w: endpoint %q (expected e.g. https://host:port)", errInvalidRemote, endpoint)
+	}
+
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		}),
+		Secure: endpoint.Scheme != "http",
+		Region: cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize s3 client: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &s3Fs{ctx: ctx, cancel: cancel, client: client, bucket: bucket}
+
+	return s, s, nil
+}
+
+// s3Fs is an [afero.Fs] of a bucket of an S3-compatible object storage, with
+// the keys of the objects as paths (and any prefixes of them as directories).
+//
+// Objects are written as a whole, uploaded in parts while being written, and
+// only stored once closed. They can neither be appended to nor written at any
+// offsets (so that, e.g., the creation of an archive cannot be resumed).
+type s3Fs struct {
+	ctx    context.Context //nolint:containedctx
+	cancel context.CancelFunc
+	client *minio.Client
+	bucket string
+}
+
+// Close aborts any operations of the filesystem still in progress.
+func (s *s3Fs) Close() error {
+	s.cancel()
+
+	return nil
+}
+
+// key returns the key of the object at a path (empty for the bucket itself).
+func (s *s3Fs) key(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
+
+func (s *s3Fs) Create(name string) (afero.File, error) {
+	return s.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666) //nolint:mnd
+}
+
+// Mkdir does nothing, as the directories only exist as prefixes of the keys.
+func (s *s3Fs) Mkdir(_ string, _ os.FileMode) error {
+	return nil
+}
+
+// MkdirAll does nothing, as the directories only exist as prefixes of the keys.
+func (s *s3Fs) MkdirAll(_ string, _ os.FileMode) error {
+	return nil
+}
+
+func (s *s3Fs) Open(name string) (afero.File, error) {
+	info, err := s.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &s3File{fs: s, name: name, info: info}
+
+	if !info.IsDir() {
+		if f.obj, err = s.client.GetObject(s.ctx, s.bucket, s.key(name), minio.GetObjectOptions{}); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	return f, nil
+}
+
+func (s *s3Fs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return s.Open(name)
+	}
+
+	// A truncated object has nothing to be read back, so (as for an [os.Create])
+	// it can also be opened for reading and writing, even if written only.
+	if flag&os.O_APPEND != 0 || (flag&os.O_RDWR != 0 && flag&os.O_TRUNC == 0) || s.key(name) == "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	}
+
+	if flag&os.O_EXCL != 0 {
+		if _, err := s.Stat(name); err == nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+		}
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		// The payload is not hashed for signing (saving a pass over all data).
+		_, err := s.client.PutObject(s.ctx, s.bucket, s.key(name), pr, -1, minio.PutObjectOptions{
+			PartSize:             s3PartSize,
+			DisableContentSha256: true,
+		})
+		pr.CloseWithError(cmp.Or(err, io.ErrClosedPipe))
+		done <- err
+	}()
+
+	return &s3File{fs: s, name: name, pw: pw, done: done}, nil
+}
+
+func (s *s3Fs) Remove(name string) error {
+	key := s.key(name)
+	if key == "" {
+		return &fs.PathError{Op: "remove", Path: name, Err: errors.ErrUnsupported}
+	}
+
+	if err := s.client.RemoveObject(s.ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+
+	return nil
+}
+
+func (s *s3Fs) RemoveAll(name string) error {
+	prefix := s.key(name)
+	if prefix == "" {
+		return &fs.PathError{Op: "remove", Path: name, Err: errors.ErrUnsupported}
+	}
+
+	if err := s.Remove(name); err != nil {
+		return err
+	}
+
+	for obj := range s.client.ListObjects(s.ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix + "/", Recursive: true}) {
+		if obj.Err != nil {
+			return &fs.PathError{Op: "remove", Path: name, Err: obj.Err}
+		}
+
+		if err := s.client.RemoveObject(s.ctx, s.bucket, obj.Key, minio.RemoveObjectOptions{}); err != nil {
+			return &fs.PathError{Op: "remove", Path: name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// Rename copies an object to its new key and removes the old one (as objects
+// cannot be renamed), which is therefore not atomic (and limited to 5 GiB).
+func (s *s3Fs) Rename(oldname string, newname string) error {
+	if _, err := s.client.CopyObject(s.ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: s.key(newname)},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: s.key(oldname)},
+	); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+
+	return s.Remove(oldname)
+}
+
+// Stat returns the [os.FileInfo] of an object, or of a directory if there are
+// any objects with the path as prefix (or for the bucket itself, if existing).
+func (s *s3Fs) Stat(name string) (os.FileInfo, error) {
+	key := s.key(name)
+
+	if key == "" {
+		if ok, err := s.client.BucketExists(s.ctx, s.bucket); err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+		} else if !ok {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+		}
+
+		return &s3FileInfo{name: "/", dir: true}, nil
+	}
+
+	obj, err := s.client.StatObject(s.ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return &s3FileInfo{name: path.Base(key), size: obj.Size, modTime: obj.LastModified}, nil
+	} else if minio.ToErrorResponse(err).StatusCode != http.StatusNotFound {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel() // Stops the listing after the first object.
+
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: key + "/", MaxKeys: 1}) {
+		if minio.ToErrorResponse(obj.Err).Code == minio.NoSuchBucket {
+			break
+		} else if obj.Err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: obj.Err}
+		}
+
+		return &s3FileInfo{name: path.Base(key), dir: true}, nil
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (s *s3Fs) Name() string {
+	return "s3Fs"
+}
+
+func (s *s3Fs) Chmod(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (s *s3Fs) Chown(name string, _ int, _ int) error {
+	return &fs.PathError{Op: "chown", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (s *s3Fs) Chtimes(name string, _ time.Time, _ time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
+}
+
+// s3FileInfo is the [os.FileInfo] of an object (or directory) of an [s3Fs].
+type s3FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *s3FileInfo) Name() string       { return fi.name }
+func (fi *s3FileInfo) Size() int64        { return fi.size }
+func (fi *s3FileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *s3FileInfo) IsDir() bool        { return fi.dir }
+func (fi *s3FileInfo) Sys() any           { return nil }
+
+func (fi *s3FileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0o755 //nolint:mnd
+	}
+
+	return 0o644 //nolint:mnd
+}
+
+// s3File is an [afero.File] of an [s3Fs], which is either an object being
+// read, a directory (as a prefix of keys), or an object being written.
+type s3File struct {
+	fs   *s3Fs
+	name string
+	info os.FileInfo // Info of the object or directory being read
+
+	obj *minio.Object // Object being read (nil: directory or written)
+
+	pw      *io.PipeWriter // Object being written (nil: read)
+	done    chan error     // Result of the upload of the object being written
+	written int64          // Bytes of the object written
+
+	entries []os.FileInfo // Entries of the directory not yet returned by Readdir
+	read    bool          // Entries of the directory were read
+}
+
+func (f *s3File) unsupported(op string) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: errors.ErrUnsupported}
+}
+
+// Close closes the file, which completes the upload of an object being written.
+func (f *s3File) Close() error {
+	switch {
+	case f.obj != nil:
+		return f.obj.Close() //nolint:wrapcheck
+	case f.pw != nil:
+		_ = f.pw.Close()
+		f.pw = nil
+
+		if err := <-f.done; err != nil {
+			return &fs.PathError{Op: "close", Path: f.name, Err: fmt.Errorf("failed to upload: %w", err)}
+		}
+	}
+
+	return nil
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.obj == nil {
+		return 0, f.unsupported("read")
+	}
+
+	return f.obj.Read(p) //nolint:wrapcheck
+}
+
+func (f *s3File) ReadAt(p []byte, off int64) (int, error) {
+	if f.obj == nil {
+		return 0, f.unsupported("read")
+	}
+
+	return f.obj.ReadAt(p, off) //nolint:wrapcheck
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	if f.obj == nil {
+		return 0, f.unsupported("seek")
+	}
+
+	return f.obj.Seek(offset, whence) //nolint:wrapcheck
+}
+
+func (f *s3File) Write(p []byte) (int, error) {
+	if f.pw == nil {
+		return 0, f.unsupported("write")
+	}
+
+	n, err := f.pw.Write(p)
+	f.written += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+func (f *s3File) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, f.unsupported("write")
+}
+
+func (f *s3File) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *s3File) Name() string {
+	return f.name
+}
+
+// Readdir returns the entries of the directory, with the semantics of [os.File.Readdir].
+func (f *s3File) Readdir(count int) ([]os.FileInfo, error) {
+	if f.info == nil || !f.info.IsDir() {
+		return nil, f.unsupported("readdir")
+	}
+
+	if !f.read {
+		prefix := f.fs.key(f.name)
+		if prefix != "" {
+			prefix += "/"
+		}
+
+		for obj := range f.fs.client.ListObjects(f.fs.ctx, f.fs.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+			if obj.Err != nil {
+				return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: obj.Err}
+			}
+
+			name := strings.TrimPrefix(obj.Key, prefix)
+			if name == "" {
+				continue // The marker object of the directory itself.
+			}
+
+			if dir, ok := strings.CutSuffix(name, "/"); ok {
+				f.entries = append(f.entries, &s3FileInfo{name: dir, dir: true})
+			} else {
+				f.entries = append(f.entries, &s3FileInfo{name: name, size: obj.Size, modTime: obj.LastModified})
+			}
+		}
+		f.read = true
+	}
+
+	if count <= 0 {
+		entries := f.entries
+		f.entries = nil
+
+		return entries, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n := min(count, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+
+	return entries, nil
+}
+
+// Readdirnames returns the names of the entries of the directory, with the semantics of [os.File.Readdirnames].
+func (f *s3File) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+
+	return names, err
+}
+
+func (f *s3File) Stat() (os.FileInfo, error) {
+	if f.info == nil {
+		return &s3FileInfo{name: path.Base(f.fs.key(f.name)), size: f.written, modTime: time.Now()}, nil
+	}
+
+	return f.info, nil
+}
+
+// Sync does nothing, as an object being written is only stored once closed.
+func (f *s3File) Sync() error {
+	return nil
+}
+
+func (f *s3File) Truncate(_ int64) error {
+	return f.unsupported("truncate")
+}
@@ -0,0 +1,375 @@
+//go:build !minimal && !no_s3
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3Object is an object stored by a [fakeS3].
+type fakeS3Object struct {
+	data    []byte
+	modTime time.Time
+}
+
+// fakeS3 is an in-memory S3-compatible server of a single bucket (for tests),
+// supporting the requests of an [s3Fs] (in their simplest forms).
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]fakeS3Object
+	uploads map[string]map[int][]byte
+}
+
+// A helper function for tests to start a [fakeS3] of a bucket, set as the endpoint in the environment.
+func newFakeS3(t *testing.T, bucket string) *fakeS3 {
+	t.Helper()
+
+	f := &fakeS3{bucket: bucket, objects: map[string]fakeS3Object{}, uploads: map[string]map[int][]byte{}}
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "tester")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	return f
+}
+
+// A helper function for tests to store objects in a [fakeS3] (of the given modification time).
+func (f *fakeS3) put(modTime time.Time, objects map[string][]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for key, data := range objects {
+		f.objects[key] = fakeS3Object{data: data, modTime: modTime}
+	}
+}
+
+// A helper function for tests to return the sorted keys of the objects stored in a [fakeS3].
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Sorted(func(yield func(string) bool) {
+		for key := range f.objects {
+			if !yield(key) {
+				return
+			}
+		}
+	})
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+
+	body, _ := io.ReadAll(r.Body)
+
+	if bucket != f.bucket {
+		f.writeError(w, r, http.StatusNotFound, "NoSuchBucket")
+
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, q)
+
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = map[int][]byte{}
+
+		f.writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Bucket: bucket, Key: key, UploadID: id})
+
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		f.uploads[q.Get("uploadId")][n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part%d"`, n))
+
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts := f.uploads[q.Get("uploadId")]
+		delete(f.uploads, q.Get("uploadId"))
+
+		var data []byte
+		for n := 1; n <= len(parts); n++ {
+			data = append(data, parts[n]...)
+		}
+		f.objects[key] = fakeS3Object{data: data, modTime: time.Now()}
+
+		f.writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: `"object"`})
+
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		src, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		obj, ok := f.objects[strings.TrimPrefix(strings.TrimPrefix(src, "/"), bucket+"/")]
+		if !ok {
+			f.writeError(w, r, http.StatusNotFound, "NoSuchKey")
+
+			return
+		}
+		f.objects[key] = fakeS3Object{data: obj.data, modTime: time.Now()}
+
+		f.writeXML(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			LastModified string
+			ETag         string
+		}{LastModified: time.Now().UTC().Format(time.RFC3339), ETag: `"object"`})
+
+	case r.Method == http.MethodPut:
+		f.objects[key] = fakeS3Object{data: body, modTime: time.Now()}
+		w.Header().Set("ETag", `"object"`)
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		obj, ok := f.objects[key]
+		if !ok {
+			f.writeError(w, r, http.StatusNotFound, "NoSuchKey")
+
+			return
+		}
+
+		w.Header().Set("ETag", `"object"`)
+		http.ServeContent(w, r, key, obj.modTime, bytes.NewReader(obj.data))
+	}
+}
+
+// list writes the objects (and common prefixes) of a ListObjectsV2 request.
+func (f *fakeS3) list(w http.ResponseWriter, q url.Values) {
+	type object struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+	}
+
+	type commonPrefix struct {
+		Prefix string
+	}
+
+	result := struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Name           string
+		Prefix         string
+		Delimiter      string
+		KeyCount       int
+		IsTruncated    bool
+		Contents       []object
+		CommonPrefixes []commonPrefix
+	}{Name: f.bucket, Prefix: q.Get("prefix"), Delimiter: q.Get("delimiter")}
+
+	for _, key := range slices.Sorted(func(yield func(string) bool) {
+		for key := range f.objects {
+			if !yield(key) {
+				return
+			}
+		}
+	}) {
+		rest, ok := strings.CutPrefix(key, result.Prefix)
+		if !ok {
+			continue
+		}
+
+		if i := strings.Index(rest, result.Delimiter); result.Delimiter != "" && i >= 0 {
+			cp := commonPrefix{Prefix: result.Prefix + rest[:i+1]}
+			if !slices.Contains(result.CommonPrefixes, cp) {
+				result.CommonPrefixes = append(result.CommonPrefixes, cp)
+			}
+
+			continue
+		}
+
+		obj := f.objects[key]
+		result.Contents = append(result.Contents, object{
+			Key:          key,
+			LastModified: obj.modTime.UTC().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         `"object"`,
+			Size:         len(obj.data),
+		})
+	}
+
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+
+	f.writeXML(w, result)
+}
+
+func (f *fakeS3) writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeS3) writeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+	}{Code: code})
+}
+
+// Expectation: An archive should be created from the keys of a bucket as a tree (with any directory markers).
+func Test_Program_Create_S3Tree_Success(t *testing.T) {
+	f := newFakeS3(t, "media")
+	f.put(time.Now(), map[string][]byte{
+		"cold/Movies/":            nil,
+		"cold/Movies/Alien.mkv":   []byte("alien"),
+		"cold/TV/Show/S01E01.mkv": []byte("show"),
+		"other.txt":               nil,
+	})
+
+	fs := afero.NewMemMapFs()
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Create(t.Context(), "s3://media/cold", "/out.tar.gz", nil))
+
+	require.Equal(t, []string{"Movies/", "Movies/Alien.mkv", "TV/", "TV/Show/", "TV/Show/S01E01.mkv"}, readTarNames(t, fs, "/out.tar.gz"))
+}
+
+// Expectation: An archive should be created into a bucket, and then be listed from it.
+func Test_Program_CreateList_S3Archive_Success(t *testing.T) {
+	f := newFakeS3(t, "archives")
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/src/dir", 0o755))
+	require.NoError(t, afero.WriteFile(fs, "/src/dir/a.txt", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Create(t.Context(), "/src", "s3://archives/nightly/out.tar.gz", nil))
+	require.Equal(t, []string{"nightly/out.tar.gz"}, f.keys())
+
+	var stdoutBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.List(t.Context(), "s3://archives/nightly/out.tar.gz", true, nil))
+	require.Equal(t, "dir/\ndir/a.txt\n", stdoutBuf.String())
+
+	err := prog.Create(t.Context(), "/src", "s3://archives/nightly/out.tar.gz", nil)
+	require.ErrorIs(t, err, ErrOutputExists)
+}
+
+// Expectation: An archive in a bucket should be compared against a tree in the same bucket.
+func Test_Program_Diff_S3_Success(t *testing.T) {
+	f := newFakeS3(t, "media")
+	f.put(time.Now(), map[string][]byte{
+		"archive.tar.gz": createTar([]string{"a.txt", "b.txt"}),
+		"tree/b.txt":     nil,
+		"tree/c.txt":     nil,
+	})
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(afero.NewMemMapFs(), &stdoutBuf, io.Discard, nil, nil, nil)
+
+	_, err := prog.Diff(t.Context(), "s3://media/archive.tar.gz", "s3://media/tree", "", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+	require.Equal(t, "--- a.txt\n+++ c.txt\n", stdoutBuf.String())
+}
+
+// Expectation: The snapshots in a bucket should be pruned as per the retention policy.
+func Test_Program_Snapshot_S3_Success(t *testing.T) {
+	f := newFakeS3(t, "snaps")
+	f.put(time.Now().Add(-48*time.Hour), map[string][]byte{"src-2020-01-01T000000.tar.gz": createTar(nil)})
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/src", 0o755))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	output, pruned, err := prog.Snapshot(t.Context(), "/src", nil, &SnapshotConfig{Dest: "s3://snaps", KeepLast: 1})
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(output, "s3://snaps/src-"))
+	require.Equal(t, []string{"s3://snaps/src-2020-01-01T000000.tar.gz"}, pruned)
+	require.Len(t, f.keys(), 1)
+}
+
+// Expectation: A missing bucket should be returned as not existing.
+func Test_Program_Create_S3MissingBucket_Error(t *testing.T) {
+	newFakeS3(t, "media")
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.Create(t.Context(), "s3://other/cold", "/out.tar.gz", nil)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+// Expectation: A renamed object should be copied to its new key, with the old one removed.
+func Test_s3Fs_Rename_Success(t *testing.T) {
+	f := newFakeS3(t, "media")
+	f.put(time.Now(), map[string][]byte{"a.txt": []byte("a")})
+
+	fsys, closer, err := dialS3(t.Context(), "media")
+	require.NoError(t, err)
+	defer closer.Close()
+
+	require.NoError(t, fsys.Rename("/a.txt", "/b.txt"))
+	require.Equal(t, []string{"b.txt"}, f.keys())
+
+	data, err := afero.ReadFile(fsys, "/b.txt")
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+}
+
+// Expectation: Objects should neither be appended to nor be opened for reading and writing.
+func Test_s3Fs_OpenFile_Error(t *testing.T) {
+	newFakeS3(t, "media")
+
+	fsys, closer, err := dialS3(t.Context(), "media")
+	require.NoError(t, err)
+	defer closer.Close()
+
+	_, err = fsys.OpenFile("/a.txt", os.O_RDWR, 0)
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+
+	_, err = fsys.OpenFile("/a.txt", os.O_WRONLY|os.O_APPEND, 0)
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}
@@ -0,0 +1,18 @@
+//go:build minimal || no_serve
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+const featureServe = false
+
+var errServeDisabled = errors.New("serving is not compiled into this build")
+
+// Serve is a stub for builds without the serve feature compiled in.
+// It always returns an error, as the archives cannot be served in such a build.
+func (prog *Program) Serve(_ context.Context, _ string, _ []string) error {
+	return errServeDisabled
+}
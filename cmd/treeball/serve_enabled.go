@@ -0,0 +1,443 @@
+//go:build !minimal && !no_serve
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const featureServe = true
+
+const (
+	serveHeaderTimeout   = 5 * time.Second
+	serveShutdownTimeout = 10 * time.Second
+)
+
+var (
+	errArchiveNotFound = errors.New("archive not found")
+	errMissingParam    = errors.New("missing parameter")
+	errInvalidParam    = errors.New("invalid parameter")
+)
+
+// ServedArchive is an archive registered with a [Program.Serve] operation.
+type ServedArchive struct {
+	Name string `json:"name"` // Name of the archive (file name without the extension)
+	Path string `json:"path"` // Path of the archive (as registered)
+	Size int64  `json:"size"` // Size of the archive in bytes
+}
+
+// servedCount is the response of the stats endpoint (see [ListCount]).
+type servedCount struct {
+	Files       int64 `json:"files"`
+	Directories int64 `json:"directories"`
+}
+
+// Serve serves a REST API over the archives of sources via HTTP, until the
+// ctx is cancelled (upon which a cancellation error is returned).
+//
+// The sources are either tarballs or directories containing tarballs (those
+// ending in .tar.gz, .tgz or .tar), which are registered under their file names
+// without the extension. Directories are rescanned upon every request, so
+// that newly added archives (e.g. of nightly backups) are served right away.
+// With multiple archives of the same name, the first one is served. The
+// listen address is in the form of [net.Listen] (e.g. ":8080"). The API
+// offers these endpoints, with any failures returned as HTTP errors (those of
+// reading an archive as a JSON object, with the context of its [StreamError]):
+//
+//	GET /archives               - JSON array of the registered archives
+//	GET /archives/{name}/list   - listing of an archive (as with the list command)
+//	GET /archives/{name}/find   - unsorted listing of the paths matching ?match
+//	GET /archives/{name}/stats  - JSON object of the amounts of files and directories
+//	GET /diff?old={a}&new={b}   - differences between two archives (as with the diff command)
+//
+// The listings and differences are streamed as plain text, one path per line.
+// These take the query parameters exclude, match and type (for the listings),
+// sort and sort-by (for the list endpoint), and only and files-only (for the
+// diff endpoint), which correspond to the respective command's flags.
+func (prog *Program) Serve(ctx context.Context, listen string, sources []string) error {
+	if _, err := prog.servedArchives(sources); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", listen) //nolint:noctx
+	if err != nil {
+		return fmt.Errorf("failed to listen for requests: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           prog.serveHandler(sources),
+		ReadHeaderTimeout: serveHeaderTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx // Cancels any running operations upon shutdown.
+		},
+	}
+
+	progressFrom(ctx).setPhase("serving %s", strings.Join(sources, ", "))
+	prog.infof("serving archives at http://%s", ln.Addr())
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("failed to serve requests: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), serveShutdownTimeout)
+	defer cancel()
+
+	_ = srv.Shutdown(shutdownCtx)
+
+	return fmt.Errorf("failed to serve requests: %w", ctx.Err())
+}
+
+// servedArchives returns the archives of the sources, ordered by their name.
+func (prog *Program) servedArchives(sources []string) ([]ServedArchive, error) {
+	var archives []ServedArchive
+
+	seen := make(map[string]bool)
+
+	register := func(path string, size int64) {
+		base := filepath.Base(path)
+		name, _ := trimArchiveExtension(base)
+
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+
+		archives = append(archives, ServedArchive{Name: name, Path: path, Size: size})
+	}
+
+	for _, source := range sources {
+		info, err := prog.fs.Stat(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat source: %w", err)
+		}
+
+		if !info.IsDir() {
+			register(source, info.Size())
+
+			continue
+		}
+
+		entries, err := readDirEntries(prog.fs, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source directory: %w", err)
+		}
+
+		for _, entry := range entries {
+			if _, ok := trimArchiveExtension(entry.Name()); entry.IsDir() || !ok {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat archive: %w", err)
+			}
+
+			register(filepath.Join(source, entry.Name()), info.Size())
+		}
+	}
+
+	slices.SortFunc(archives, func(a, b ServedArchive) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return archives, nil
+}
+
+// servedArchive returns the path of the archive of the given name.
+func (prog *Program) servedArchive(sources []string, name string) (string, error) {
+	archives, err := prog.servedArchives(sources)
+	if err != nil {
+		return "", err
+	}
+
+	for _, a := range archives {
+		if a.Name == name {
+			return a.Path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", errArchiveNotFound, name)
+}
+
+// serveHandler returns the [http.Handler] of the API (see [Program.Serve]).
+func (prog *Program) serveHandler(sources []string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /archives", func(w http.ResponseWriter, _ *http.Request) {
+		archives, err := prog.servedArchives(sources)
+		if err != nil {
+			prog.serveError(w, err)
+
+			return
+		}
+
+		prog.serveJSON(w, archives)
+	})
+
+	mux.HandleFunc("GET /archives/{name}/list", func(w http.ResponseWriter, r *http.Request) {
+		prog.serveList(&responseWriter{ResponseWriter: w}, r, sources, false)
+	})
+
+	mux.HandleFunc("GET /archives/{name}/find", func(w http.ResponseWriter, r *http.Request) {
+		prog.serveList(&responseWriter{ResponseWriter: w}, r, sources, true)
+	})
+
+	mux.HandleFunc("GET /archives/{name}/stats", func(w http.ResponseWriter, r *http.Request) {
+		prog.serveStats(w, r, sources)
+	})
+
+	mux.HandleFunc("GET /diff", func(w http.ResponseWriter, r *http.Request) {
+		prog.serveDiff(&responseWriter{ResponseWriter: w}, r, sources)
+	})
+
+	return mux
+}
+
+// responseWriter is an [http.ResponseWriter] recording if the response was
+// started, as any failures can only be returned as HTTP errors before that.
+type responseWriter struct {
+	http.ResponseWriter
+
+	started bool
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.started = true
+
+	return w.ResponseWriter.Write(p) //nolint:wrapcheck
+}
+
+// requestProgram returns a copy of the [Program] for a single request, which
+// prints to w and has its configuration adapted from the query parameters of
+// the request (as for the listings). The excludes of the request are returned.
+func (prog *Program) requestProgram(w io.Writer, r *http.Request) (*Program, []string, error) {
+	query := r.URL.Query()
+
+	typ, err := parseEntryType(query.Get("type"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := *prog.config
+	config.OnlyType = typ
+	config.Matches = query["match"]
+
+	reqProg := *prog
+	reqProg.config = &config
+	reqProg.stdout = w
+
+	return &reqProg, query["exclude"], nil
+}
+
+// queryBool returns the boolean value of a query parameter (or the fallback).
+func queryBool(r *http.Request, name string, fallback bool) (bool, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return fallback, nil
+	}
+
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s=%q", errInvalidParam, name, s)
+	}
+
+	return v, nil
+}
+
+func (prog *Program) serveList(w *responseWriter, r *http.Request, sources []string, find bool) {
+	archive, err := prog.servedArchive(sources, r.PathValue("name"))
+	if err != nil {
+		prog.serveError(w, err)
+
+		return
+	}
+
+	reqProg, excludes, err := prog.requestProgram(w, r)
+	if err != nil {
+		prog.serveError(w, err)
+
+		return
+	}
+
+	var sort bool
+
+	if find {
+		if len(reqProg.config.Matches) == 0 {
+			prog.serveError(w, fmt.Errorf("%w: match", errMissingParam))
+
+			return
+		}
+	} else {
+		if sort, err = queryBool(r, "sort", true); err != nil {
+			prog.serveError(w, err)
+
+			return
+		}
+
+		if reqProg.config.SortBy, err = parseSortOrder(r.URL.Query().Get("sort-by")); err != nil {
+			prog.serveError(w, err)
+
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	prog.serveStreamed(w, reqProg.List(r.Context(), archive, sort, excludes))
+}
+
+func (prog *Program) serveStats(w http.ResponseWriter, r *http.Request, sources []string) {
+	archive, err := prog.servedArchive(sources, r.PathValue("name"))
+	if err != nil {
+		prog.serveError(w, err)
+
+		return
+	}
+
+	reqProg, excludes, err := prog.requestProgram(io.Discard, r)
+	if err != nil {
+		prog.serveError(w, err)
+
+		return
+	}
+
+	count, err := reqProg.Count(r.Context(), archive, excludes)
+	if err != nil {
+		prog.serveError(w, err)
+
+		return
+	}
+
+	prog.serveJSON(w, servedCount{Files: count.Files, Directories: count.Directories})
+}
+
+func (prog *Program) serveDiff(w *responseWriter, r *http.Request, sources []string) {
+	var archives []string
+
+	for _, param := range []string{"old", "new"} {
+		name := r.URL.Query().Get(param)
+		if name == "" {
+			prog.serveError(w, fmt.Errorf("%w: %s", errMissingParam, param))
+
+			return
+		}
+
+		archive, err := prog.servedArchive(sources, name)
+		if err != nil {
+			prog.serveError(w, err)
+
+			return
+		}
+		archives = append(archives, archive)
+	}
+
+	reqProg, excludes, err := prog.requestProgram(w, r)
+	if err != nil {
+		prog.serveError(w, err)
+
+		return
+	}
+
+	if reqProg.config.OnlySide, err = parseDiffSide(r.URL.Query().Get("only")); err != nil {
+		prog.serveError(w, err)
+
+		return
+	}
+
+	if reqProg.config.FilesOnly, err = queryBool(r, "files-only", false); err != nil {
+		prog.serveError(w, err)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	_, err = reqProg.Diff(r.Context(), archives[0], archives[1], "", excludes)
+	if errors.Is(err, ErrDiffsFound) {
+		err = nil
+	}
+
+	prog.serveStreamed(w, err)
+}
+
+// serveStreamed concludes a streamed response with the operation's error. Once
+// the response was started, the status was already sent along with it, so any
+// such error is printed to standard error instead (with the response cut short).
+func (prog *Program) serveStreamed(w *responseWriter, err error) {
+	if err == nil {
+		return
+	}
+
+	if !w.started {
+		prog.serveError(w, err)
+
+		return
+	}
+
+	prog.warnf("failed to serve request: %v", err)
+}
+
+// servedStreamError is the response of an error of reading an archive, with
+// the context for locating the offending entry within it (see [StreamError]).
+type servedStreamError struct {
+	Error  string       `json:"error"`
+	Stream *StreamError `json:"stream"`
+}
+
+// serveError writes an error as the response, with the status of the error.
+// An error of reading an archive is written as a JSON object (with its entry
+// context), any other error as plain text.
+func (prog *Program) serveError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, errArchiveNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errMissingParam), errors.Is(err, errInvalidParam),
+		errors.Is(err, errInvalidEntryType), errors.Is(err, errInvalidSortOrder),
+		errors.Is(err, errInvalidDiffSide):
+		status = http.StatusBadRequest
+	}
+
+	var serr *StreamError
+	if errors.As(err, &serr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+
+		if err := json.NewEncoder(w).Encode(servedStreamError{Error: err.Error(), Stream: serr}); err != nil {
+			prog.warnf("failed to serve request: %v", err)
+		}
+
+		return
+	}
+
+	http.Error(w, err.Error(), status)
+}
+
+// serveJSON writes a value as the JSON response.
+func (prog *Program) serveJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		prog.warnf("failed to serve request: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+//go:build !minimal && !no_serve
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// The archives (of their entry names) for the serving tests.
+var serveArchives = map[string][]string{
+	"/backups/monday.tar.gz": {"z.txt", "a.txt", "dir/", "dir/b.mkv"},
+	"/backups/tuesday.tgz":   {"a.txt", "dir/", "dir/c.mkv"},
+	"/other/monday.tar.gz":   {"other.txt"},
+}
+
+// A helper function for tests to request a path of the API, returning the status and body.
+func serveGet(t *testing.T, handler http.Handler, target string) (int, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), http.MethodGet, target, nil))
+
+	return rec.Code, rec.Body.String()
+}
+
+// Expectation: The archives of files and directories should be served by name, with the first of a name served.
+func Test_Program_serveHandler_Archives_Success(t *testing.T) {
+	fs := newArchiveFs(t, serveArchives)
+	require.NoError(t, afero.WriteFile(fs, "/backups/notes.txt", []byte("not an archive"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	handler := prog.serveHandler([]string{"/backups", "/other/monday.tar.gz"})
+
+	code, body := serveGet(t, handler, "/archives")
+	require.Equal(t, http.StatusOK, code)
+
+	var archives []ServedArchive
+	require.NoError(t, json.Unmarshal([]byte(body), &archives))
+
+	require.Len(t, archives, 2)
+	require.Equal(t, "monday", archives[0].Name)
+	require.Equal(t, "/backups/monday.tar.gz", archives[0].Path)
+	require.Positive(t, archives[0].Size)
+	require.Equal(t, "tuesday", archives[1].Name)
+}
+
+// Expectation: Archives added to a served directory should be served without a restart.
+func Test_Program_serveHandler_Archives_Rescan_Success(t *testing.T) {
+	fs := newArchiveFs(t, serveArchives)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	handler := prog.serveHandler([]string{"/backups"})
+
+	code, _ := serveGet(t, handler, "/archives/wednesday/list")
+	require.Equal(t, http.StatusNotFound, code)
+
+	require.NoError(t, afero.WriteFile(fs, "/backups/wednesday.tar.gz", createTar([]string{"new.txt"}), 0o644))
+
+	code, body := serveGet(t, handler, "/archives/wednesday/list")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "new.txt\n", body)
+}
+
+// Expectation: An archive should be listed as with the list command, respecting the query parameters.
+func Test_Program_serveHandler_List_Table(t *testing.T) {
+	prog := NewProgram(newArchiveFs(t, serveArchives), io.Discard, io.Discard, nil, nil, nil)
+	handler := prog.serveHandler([]string{"/backups"})
+
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"sorted", "/archives/monday/list", []string{"a.txt", "dir/", "dir/b.mkv", "z.txt"}},
+		{"unsorted", "/archives/monday/list?sort=false", []string{"z.txt", "a.txt", "dir/", "dir/b.mkv"}},
+		{"reverse", "/archives/monday/list?sort-by=reverse", []string{"z.txt", "dir/b.mkv", "dir/", "a.txt"}},
+		{"type", "/archives/monday/list?type=d", []string{"dir/"}},
+		{"excludes", "/archives/monday/list?exclude=z.txt&exclude=dir/**", []string{"a.txt"}},
+		{"find", "/archives/monday/find?match=**/*.mkv&match=z.txt", []string{"z.txt", "dir/b.mkv"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveGet(t, handler, tt.target)
+			require.Equal(t, http.StatusOK, code, body)
+			require.Equal(t, tt.want, strings.Split(strings.TrimSpace(body), "\n"))
+		})
+	}
+}
+
+// Expectation: The amounts of files and directories of an archive should be served as JSON.
+func Test_Program_serveHandler_Stats_Success(t *testing.T) {
+	prog := NewProgram(newArchiveFs(t, serveArchives), io.Discard, io.Discard, nil, nil, nil)
+	handler := prog.serveHandler([]string{"/backups"})
+
+	code, body := serveGet(t, handler, "/archives/monday/stats")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"files": 3, "directories": 1}`, body)
+}
+
+// Expectation: The differences between two archives should be served as with the diff command.
+func Test_Program_serveHandler_Diff_Table(t *testing.T) {
+	prog := NewProgram(newArchiveFs(t, serveArchives), io.Discard, io.Discard, nil, nil, nil)
+	handler := prog.serveHandler([]string{"/backups"})
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"both", "/diff?old=monday&new=tuesday", "--- dir/b.mkv\n+++ dir/c.mkv\n--- z.txt\n"},
+		{"removed", "/diff?old=monday&new=tuesday&only=removed", "--- dir/b.mkv\n--- z.txt\n"},
+		{"excludes", "/diff?old=monday&new=tuesday&exclude=**/*.mkv", "--- z.txt\n"},
+		{"identical", "/diff?old=monday&new=monday", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveGet(t, handler, tt.target)
+			require.Equal(t, http.StatusOK, code, body)
+			require.Equal(t, tt.want, body)
+		})
+	}
+}
+
+// Expectation: Invalid requests should be answered with the respective HTTP errors.
+func Test_Program_serveHandler_Error_Table(t *testing.T) {
+	prog := NewProgram(newArchiveFs(t, serveArchives), io.Discard, io.Discard, nil, nil, nil)
+	handler := prog.serveHandler([]string{"/backups"})
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"unknown archive", "/archives/friday/list", http.StatusNotFound},
+		{"unknown stats", "/archives/friday/stats", http.StatusNotFound},
+		{"unknown diff", "/diff?old=monday&new=friday", http.StatusNotFound},
+		{"invalid type", "/archives/monday/list?type=x", http.StatusBadRequest},
+		{"invalid sort", "/archives/monday/list?sort=maybe", http.StatusBadRequest},
+		{"invalid sort-by", "/archives/monday/list?sort-by=size", http.StatusBadRequest},
+		{"missing match", "/archives/monday/find", http.StatusBadRequest},
+		{"missing new", "/diff?old=monday", http.StatusBadRequest},
+		{"invalid only", "/diff?old=monday&new=tuesday&only=some", http.StatusBadRequest},
+		{"invalid files-only", "/diff?old=monday&new=tuesday&files-only=maybe", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := serveGet(t, handler, tt.target)
+			require.Equal(t, tt.want, code)
+		})
+	}
+}
+
+// Expectation: A corrupt archive should be answered with an internal server error.
+func Test_Program_serveHandler_Corrupt_Error(t *testing.T) {
+	fs := newArchiveFs(t, serveArchives)
+	require.NoError(t, afero.WriteFile(fs, "/backups/broken.tar.gz", []byte("not a tarball"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	handler := prog.serveHandler([]string{"/backups"})
+
+	code, _ := serveGet(t, handler, "/archives/broken/list")
+	require.Equal(t, http.StatusInternalServerError, code)
+}
+
+// A helper function for tests to create a tarball with a corrupt third header.
+func createCorruptTar(t *testing.T) []byte {
+	t.Helper()
+
+	var raw bytes.Buffer
+	tw := tar.NewWriter(&raw)
+
+	for _, name := range []string{"a.txt", "b/", "b/x.txt"} {
+		require.NoError(t, writeDummyFile(tw, name, strings.HasSuffix(name, "/"), tar.FormatUnknown))
+	}
+	require.NoError(t, tw.Close())
+
+	data := raw.Bytes()
+	copy(data[2*512+148:], "garbage!") // Checksum field of the third header
+
+	return data
+}
+
+// Expectation: An archive with a corrupt header should be answered with a JSON object locating the entry.
+func Test_Program_serveHandler_StreamError_Success(t *testing.T) {
+	fs := newArchiveFs(t, serveArchives)
+	require.NoError(t, afero.WriteFile(fs, "/backups/broken.tar", createCorruptTar(t), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	handler := prog.serveHandler([]string{"/backups"})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/archives/broken/stats", nil))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var got struct {
+		Error  string         `json:"error"`
+		Stream map[string]any `json:"stream"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Contains(t, got.Error, `entry #2 (after "b/") past offset 1024`)
+
+	require.Contains(t, got.Stream, "compressedOffset") // Approximate, as of the reads.
+	delete(got.Stream, "compressedOffset")
+
+	require.Equal(t, map[string]any{
+		"index":    float64(2),
+		"prevPath": "b/",
+		"offset":   float64(1024),
+		"error":    tar.ErrHeader.Error(),
+	}, got.Stream)
+}
+
+// Expectation: A failure after the response was started should be printed as a warning.
+func Test_Program_serveHandler_Streamed_Error(t *testing.T) {
+	fs := newArchiveFs(t, serveArchives)
+	require.NoError(t, afero.WriteFile(fs, "/backups/broken.tar", createCorruptTar(t), 0o644))
+
+	var stderrBuf bytes.Buffer
+
+	prog := NewProgram(fs, io.Discard, &stderrBuf, nil, nil, nil)
+	handler := prog.serveHandler([]string{"/backups"})
+
+	code, body := serveGet(t, handler, "/archives/broken/list")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "a.txt\nb/\n", body)
+	require.Contains(t, stderrBuf.String(), `warning: failed to serve request: `)
+	require.Contains(t, stderrBuf.String(), `entry #2 (after "b/")`)
+}
+
+// Expectation: The API should be served until the context is cancelled.
+func Test_Program_Serve_Success(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+
+	prog := NewProgram(newArchiveFs(t, serveArchives), io.Discard, io.Discard, nil, nil, nil)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- prog.Serve(ctx, "127.0.0.1:0", []string{"/backups"})
+	}()
+
+	cancel()
+	require.ErrorIs(t, <-errs, context.Canceled)
+}
+
+// Expectation: A missing source should be returned as an error before serving.
+func Test_Program_Serve_MissingSource_Error(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	require.ErrorIs(t, prog.Serve(t.Context(), "127.0.0.1:0", []string{"/missing"}), os.ErrNotExist)
+}
+
+// Expectation: An invalid listen address should be returned as an error.
+func Test_Program_Serve_Listen_Error(t *testing.T) {
+	prog := NewProgram(newArchiveFs(t, serveArchives), io.Discard, io.Discard, nil, nil, nil)
+
+	require.Error(t, prog.Serve(t.Context(), "invalid:address:0", []string{"/backups"}))
+}
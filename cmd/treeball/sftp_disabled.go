@@ -0,0 +1,21 @@
+//go:build minimal || no_sftp
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/spf13/afero"
+)
+
+const featureSFTP = false
+
+var errSFTPDisabled = errors.New("ssh:// sources are not compiled into this build")
+
+// dialSFTP is a stub for builds without the sftp feature compiled in.
+// It always returns an error, as no remote host can be connected in such a build.
+func dialSFTP(_ context.Context, _ string) (afero.Fs, io.Closer, error) {
+	return nil, nil, errSFTPDisabled
+}
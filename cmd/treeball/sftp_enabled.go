@@ -0,0 +1,287 @@
+//go:build !minimal && !no_sftp
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const featureSFTP = true
+
+// sftpDialTimeout is the timeout of connecting to a remote host (including the handshake).
+const sftpDialTimeout = 30 * time.Second
+
+// sshIdentityFiles are the private keys (within ~/.ssh) tried for authentication.
+var sshIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+var errNoSSHAuth = errors.New("no SSH authentication available (agent, keys, or password)")
+
+// dialSFTP connects to the remote host of an authority (i.e. the part of the
+// ssh:// source between the scheme and the path) over SSH, returning its
+// filesystem (served over SFTP, read-only) and the connection (see [dialRemote]).
+//
+// The key of the host is verified against the ~/.ssh/known_hosts file. The
+// authentication is done with any running SSH agent, any of the default keys
+// within ~/.ssh (which are not protected by a passphrase), and any password
+// given as part of the authority. The user defaults to the current one.
+func dialSFTP(ctx context.Context, authority string) (afero.Fs, io.Closer, error) {
+	u, err := url.Parse(sshScheme + "://" + authority)
+	if err != nil || u.Hostname() == "" {
+		return nil, nil, fmt.Errorf("%w: %q (expected ssh://[user@]host[:port]/path)", errInvalidRemote, authority)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to obtain home directory: %w", err)
+	}
+
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load known hosts: %w", err)
+	}
+
+	username := u.User.Username()
+	if username == "" {
+		if cur, err := user.Current(); err == nil {
+			username = cur.Username
+		}
+	}
+
+	auth, closeAgent := sshAuthMethods(home, u.User)
+	defer closeAgent()
+
+	if len(auth) == 0 {
+		return nil, nil, errNoSSHAuth
+	}
+
+	addr := net.JoinHostPort(u.Hostname(), cmp.Or(u.Port(), "22"))
+
+	dialer := net.Dialer{Timeout: sftpDialTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to remote host: %w", err)
+	}
+
+	// The handshake itself is not cancelable, so the connection is closed instead.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         sftpDialTimeout,
+	})
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, nil, fmt.Errorf("failed to establish ssh connection: %w", err)
+	}
+
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		_ = client.Close()
+
+		return nil, nil, fmt.Errorf("failed to start sftp session: %w", err)
+	}
+
+	return &sftpFs{client: sc}, &sftpConn{ssh: client, sftp: sc}, nil
+}
+
+// sshAuthMethods returns the available SSH authentication methods, along with
+// a function closing the connection to any SSH agent (once authenticated).
+func sshAuthMethods(home string, userinfo *url.Userinfo) ([]ssh.AuthMethod, func()) {
+	var methods []ssh.AuthMethod
+
+	closeAgent := func() {}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+			closeAgent = func() { _ = conn.Close() }
+		}
+	}
+
+	var signers []ssh.Signer
+
+	for _, name := range sshIdentityFiles {
+		data, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			continue // Protected by a passphrase (left to the agent).
+		}
+		signers = append(signers, signer)
+	}
+
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if password, ok := userinfo.Password(); ok {
+		methods = append(methods, ssh.Password(password))
+	}
+
+	return methods, closeAgent
+}
+
+// sftpConn is the connection to a remote host, as returned by [dialRemote].
+type sftpConn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+// Close closes the SFTP session and the underlying SSH connection.
+func (c *sftpConn) Close() error {
+	return errors.Join(c.sftp.Close(), c.ssh.Close())
+}
+
+// sftpFs is a read-only [afero.Fs] of a remote host, served over SFTP.
+type sftpFs struct {
+	client *sftp.Client
+}
+
+func (s *sftpFs) Create(name string) (afero.File, error) {
+	return nil, &fs.PathError{Op: "create", Path: name, Err: errRemoteReadOnly}
+}
+
+func (s *sftpFs) Mkdir(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errRemoteReadOnly}
+}
+
+func (s *sftpFs) MkdirAll(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errRemoteReadOnly}
+}
+
+func (s *sftpFs) Open(name string) (afero.File, error) {
+	f, err := s.client.Open(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &sftpFile{File: f, client: s.client}, nil
+}
+
+func (s *sftpFs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errRemoteReadOnly}
+	}
+
+	return s.Open(name)
+}
+
+func (s *sftpFs) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: errRemoteReadOnly}
+}
+
+func (s *sftpFs) RemoveAll(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: errRemoteReadOnly}
+}
+
+func (s *sftpFs) Rename(oldname string, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errRemoteReadOnly}
+}
+
+func (s *sftpFs) Stat(name string) (os.FileInfo, error) {
+	return s.client.Stat(name) //nolint:wrapcheck
+}
+
+// LstatIfPossible returns the [os.FileInfo] of a path, without following a symbolic link at the path.
+func (s *sftpFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	info, err := s.client.Lstat(name)
+
+	return info, true, err //nolint:wrapcheck
+}
+
+func (s *sftpFs) Name() string {
+	return "sftpFs"
+}
+
+func (s *sftpFs) Chmod(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: errRemoteReadOnly}
+}
+
+func (s *sftpFs) Chown(name string, _ int, _ int) error {
+	return &fs.PathError{Op: "chown", Path: name, Err: errRemoteReadOnly}
+}
+
+func (s *sftpFs) Chtimes(name string, _ time.Time, _ time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: errRemoteReadOnly}
+}
+
+// sftpFile is an [afero.File] of a remote host, as opened by an [sftpFs].
+type sftpFile struct {
+	*sftp.File
+
+	client  *sftp.Client
+	entries []os.FileInfo // Entries of the directory not yet returned by Readdir
+	read    bool          // Entries of the directory were read
+}
+
+// Readdir returns the entries of the directory, with the semantics of [os.File.Readdir].
+func (f *sftpFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.read {
+		entries, err := f.client.ReadDir(f.Name())
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		f.entries = entries
+		f.read = true
+	}
+
+	if count <= 0 {
+		entries := f.entries
+		f.entries = nil
+
+		return entries, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n := min(count, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+
+	return entries, nil
+}
+
+// Readdirnames returns the names of the entries of the directory, with the semantics of [os.File.Readdirnames].
+func (f *sftpFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+
+	return names, err
+}
+
+func (f *sftpFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s)) //nolint:wrapcheck
+}
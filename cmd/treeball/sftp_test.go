@@ -0,0 +1,91 @@
+//go:build !minimal && !no_sftp
+
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to return an [sftpFs] served in-process (of the local filesystem).
+func newTestSFTPFs(t *testing.T) *sftpFs {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+
+	server, err := sftp.NewServer(serverConn)
+	require.NoError(t, err)
+	go func() { _ = server.Serve() }()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return &sftpFs{client: client}
+}
+
+// Expectation: An archive should be created from a tree walked over SFTP.
+func Test_Program_Create_SFTP_Success(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dir"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "a.txt"), nil, 0o644))
+	require.NoError(t, os.Symlink("dir", filepath.Join(root, "link")))
+
+	fs := &remoteFs{Fs: afero.NewMemMapFs(), remotes: map[string]afero.Fs{"ssh://tester@nas": newTestSFTPFs(t)}}
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Create(t.Context(), "ssh://tester@nas"+filepath.ToSlash(root), "/out.tar.gz", nil))
+
+	require.Equal(t, []string{"dir/", "dir/a.txt", "link"}, readTarNames(t, fs.Fs, "/out.tar.gz"))
+}
+
+// Expectation: The entries of a directory should be returned in parts, ending with io.EOF.
+func Test_sftpFile_Readdir_Success(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), nil, 0o644))
+	}
+
+	f, err := newTestSFTPFs(t).Open(root)
+	require.NoError(t, err)
+	defer f.Close()
+
+	names, err := f.Readdirnames(2)
+	require.NoError(t, err)
+	require.Len(t, names, 2)
+
+	names, err = f.Readdirnames(2)
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+
+	_, err = f.Readdirnames(2)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+// Expectation: Any writes to the remote filesystem should be returned as errors.
+func Test_sftpFs_ReadOnly_Error(t *testing.T) {
+	root := t.TempDir()
+	fs := newTestSFTPFs(t)
+
+	_, err := fs.OpenFile(filepath.Join(root, "a.txt"), os.O_CREATE|os.O_WRONLY, 0o644)
+	require.ErrorIs(t, err, errRemoteReadOnly)
+
+	_, err = fs.Create(filepath.Join(root, "a.txt"))
+	require.ErrorIs(t, err, errRemoteReadOnly)
+
+	require.ErrorIs(t, fs.Remove(root), errRemoteReadOnly)
+
+	_, err = os.Stat(filepath.Join(root, "a.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
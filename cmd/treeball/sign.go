@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	signatureSuffix    = ".sig"
+	signatureNamespace = "file" // Namespace of the signatures (as the default of "ssh-keygen -Y sign").
+)
+
+var errInvalidSignature = errors.New("invalid signature file")
+
+// archiveSigner creates detached signatures of archives.
+type archiveSigner interface {
+	// sign returns the detached signature (in armored form) of the data of r.
+	sign(r io.Reader) ([]byte, error)
+}
+
+// signaturePath returns the path of the detached signature of an archive.
+func signaturePath(archive string) string {
+	return archive + signatureSuffix
+}
+
+// signer returns the [archiveSigner] of the key set in the program's
+// [ProgramConfig.SignKey], or nil if no archives are to be signed at all.
+func (prog *Program) signer() (archiveSigner, error) {
+	if prog.config.SignKey == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	data, err := afero.ReadFile(prog.fs, prog.config.SignKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	return newArchiveSigner(data)
+}
+
+// writeSignature writes the detached signature of an archive (which must be
+// fully written) alongside it (see [signaturePath]), as an SSH signature that
+// can also be verified with "ssh-keygen -Y verify" (in the "file" namespace).
+// The signature file is protected as the output files (see [Program.createOutput]).
+func (prog *Program) writeSignature(ctx context.Context, signer archiveSigner, archive string) error {
+	f, err := prog.openArchive(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive for signing: %w", err)
+	}
+	defer f.Close()
+
+	sig, err := signer.sign(contextReader{ctx: ctx, r: f})
+	if err != nil {
+		return fmt.Errorf("failed to sign archive: %w", err)
+	}
+
+	out, err := prog.createOutput(ctx, signaturePath(archive))
+	if err != nil {
+		return fmt.Errorf("failed to create signature file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := out.Write(sig); err != nil {
+		return fmt.Errorf("failed to write signature file: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close signature file: %w", err)
+	}
+
+	return nil
+}
+
+// VerifySignature checks the detached signature of an archive, as written with
+// [ProgramConfig.SignKey] (or by "ssh-keygen -Y sign -n file").
+//
+// The input parameter specifies the path to the archive, the signature parameter
+// the path to its signature (empty: the default of [signaturePath]). The trusted
+// parameter is the path to a file of the public keys trusted to have signed the
+// archive, in the format of an authorized_keys file (such as any *.pub file).
+// The fingerprint of the signing key is printed to standard output. An error
+// wrapping [ErrSignatureInvalid] is returned for any archives not matching their
+// signature, or signed by untrusted keys. The ctx parameter controls early cancellation.
+func (prog *Program) VerifySignature(ctx context.Context, input string, signature string, trusted string) error {
+	progressFrom(ctx).setPhase("verifying signature of %s", input)
+
+	if signature == "" {
+		signature = signaturePath(input)
+	}
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input, signature, trusted)
+	if err != nil {
+		return err
+	}
+	defer closeRemotes()
+
+	sig, err := afero.ReadFile(prog.fs, signature)
+	if err != nil {
+		return fmt.Errorf("failed to read signature file: %w", err)
+	}
+
+	keys, err := afero.ReadFile(prog.fs, trusted)
+	if err != nil {
+		return fmt.Errorf("failed to read trusted keys: %w", err)
+	}
+
+	f, err := prog.openArchive(input)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	fingerprint, err := verifyArchiveSignature(contextReader{ctx: ctx, r: f}, sig, keys)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(prog.stdout, "signed by: %s\n", fingerprint)
+
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build minimal || no_sign
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+const featureSign = false
+
+var errSignDisabled = errors.New("signing of archives is not compiled into this build")
+
+// newArchiveSigner is a stub for builds without the sign feature compiled in.
+// It always returns an error, as no archives can be signed in such a build.
+func newArchiveSigner(_ []byte) (archiveSigner, error) {
+	return nil, errSignDisabled
+}
+
+// verifyArchiveSignature is a stub for builds without the sign feature compiled in.
+// It always returns an error, as no signatures can be verified in such a build.
+func verifyArchiveSignature(_ io.Reader, _ []byte, _ []byte) (string, error) {
+	return "", errSignDisabled
+}
@@ -0,0 +1,231 @@
+//go:build !minimal && !no_sign
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const featureSign = true
+
+const (
+	sshSigMagic      = "SSHSIG"
+	sshSigVersion    = 1
+	sshSigHash       = "sha512"
+	sshSigArmorBegin = "-----BEGIN SSH SIGNATURE-----"
+	sshSigArmorEnd   = "-----END SSH SIGNATURE-----"
+	sshSigLineLength = 70
+)
+
+// sshSigBlob is the (binary) content of an armored SSH signature (see PROTOCOL.sshsig of OpenSSH).
+type sshSigBlob struct {
+	Version   uint32
+	PublicKey []byte
+	Namespace string
+	Reserved  string
+	HashAlg   string
+	Signature []byte
+}
+
+// sshSigSignedData is the data actually signed for an SSH signature (following the magic).
+type sshSigSignedData struct {
+	Namespace string
+	Reserved  string
+	HashAlg   string
+	Hash      []byte
+}
+
+// sshArchiveSigner is an [archiveSigner] creating SSH signatures with a private key.
+type sshArchiveSigner struct {
+	signer ssh.Signer
+}
+
+// newArchiveSigner returns an [archiveSigner] of an OpenSSH private key (as
+// created with "ssh-keygen"), which must not be protected by a passphrase.
+func newArchiveSigner(key []byte) (archiveSigner, error) {
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		var passErr *ssh.PassphraseMissingError
+		if errors.As(err, &passErr) {
+			return nil, errors.New("failed to parse signing key: keys protected by a passphrase are not supported")
+		}
+
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	return &sshArchiveSigner{signer: signer}, nil
+}
+
+// sign is a method that returns the armored SSH signature of the data of r.
+func (s *sshArchiveSigner) sign(r io.Reader) ([]byte, error) {
+	h := sha512.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("failed to hash data: %w", err)
+	}
+
+	signedData := sshSigMessage(signatureNamespace, sshSigHash, h.Sum(nil))
+
+	var sig *ssh.Signature
+	var err error
+
+	// RSA keys would otherwise sign with the deprecated SHA-1 (rejected by OpenSSH).
+	if as, ok := s.signer.(ssh.AlgorithmSigner); ok && s.signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		sig, err = as.SignWithAlgorithm(rand.Reader, signedData, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = s.signer.Sign(rand.Reader, signedData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign data: %w", err)
+	}
+
+	blob := append([]byte(sshSigMagic), ssh.Marshal(sshSigBlob{
+		Version:   sshSigVersion,
+		PublicKey: s.signer.PublicKey().Marshal(),
+		Namespace: signatureNamespace,
+		HashAlg:   sshSigHash,
+		Signature: ssh.Marshal(sig),
+	})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+
+	var buf bytes.Buffer
+
+	buf.WriteString(sshSigArmorBegin + "\n")
+	for len(encoded) > sshSigLineLength {
+		buf.WriteString(encoded[:sshSigLineLength] + "\n")
+		encoded = encoded[sshSigLineLength:]
+	}
+	buf.WriteString(encoded + "\n")
+	buf.WriteString(sshSigArmorEnd + "\n")
+
+	return buf.Bytes(), nil
+}
+
+// sshSigMessage returns the data to sign for an SSH signature of a hash.
+func sshSigMessage(namespace string, hashAlg string, sum []byte) []byte {
+	return append([]byte(sshSigMagic), ssh.Marshal(sshSigSignedData{
+		Namespace: namespace,
+		HashAlg:   hashAlg,
+		Hash:      sum,
+	})...)
+}
+
+// verifyArchiveSignature checks an armored SSH signature of the data of r,
+// which must have been made by any of the trusted keys (in the format of an
+// authorized_keys file). The fingerprint of the signing key is returned.
+func verifyArchiveSignature(r io.Reader, armored []byte, trusted []byte) (string, error) {
+	blob, err := decodeSSHSig(armored)
+	if err != nil {
+		return "", err
+	}
+
+	if blob.Namespace != signatureNamespace {
+		return "", fmt.Errorf("%w: unexpected namespace %q", ErrSignatureInvalid, blob.Namespace)
+	}
+
+	pub, err := ssh.ParsePublicKey(blob.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errInvalidSignature, err)
+	}
+
+	trustedKey, err := containsSSHKey(trusted, pub)
+	if err != nil {
+		return "", err
+	}
+	if !trustedKey {
+		return "", fmt.Errorf("%w: signed by an untrusted key (%s)", ErrSignatureInvalid, ssh.FingerprintSHA256(pub))
+	}
+
+	var h hash.Hash
+
+	switch blob.HashAlg {
+	case "sha512":
+		h = sha512.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return "", fmt.Errorf("%w: unsupported hash algorithm %q", errInvalidSignature, blob.HashAlg)
+	}
+
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("failed to hash data: %w", err)
+	}
+
+	sig := new(ssh.Signature)
+	if err := ssh.Unmarshal(blob.Signature, sig); err != nil {
+		return "", fmt.Errorf("%w: %w", errInvalidSignature, err)
+	}
+
+	if err := pub.Verify(sshSigMessage(blob.Namespace, blob.HashAlg, h.Sum(nil)), sig); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSignatureInvalid, err)
+	}
+
+	return ssh.FingerprintSHA256(pub), nil
+}
+
+// decodeSSHSig decodes an armored SSH signature into its [sshSigBlob].
+func decodeSSHSig(armored []byte) (*sshSigBlob, error) {
+	text := strings.TrimSpace(string(armored))
+
+	body, ok := strings.CutPrefix(text, sshSigArmorBegin)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %q", errInvalidSignature, sshSigArmorBegin)
+	}
+
+	body, ok = strings.CutSuffix(body, sshSigArmorEnd)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %q", errInvalidSignature, sshSigArmorEnd)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidSignature, err)
+	}
+
+	data, ok = bytes.CutPrefix(data, []byte(sshSigMagic))
+	if !ok {
+		return nil, fmt.Errorf("%w: bad magic", errInvalidSignature)
+	}
+
+	blob := new(sshSigBlob)
+	if err := ssh.Unmarshal(data, blob); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidSignature, err)
+	}
+
+	if blob.Version != sshSigVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", errInvalidSignature, blob.Version)
+	}
+
+	return blob, nil
+}
+
+// containsSSHKey returns if a public key is among the keys of an authorized_keys file.
+func containsSSHKey(keys []byte, pub ssh.PublicKey) (bool, error) {
+	want := pub.Marshal()
+
+	for len(bytes.TrimSpace(keys)) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(keys)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse trusted keys: %w", err)
+		}
+
+		if bytes.Equal(key.Marshal(), want) {
+			return true, nil
+		}
+
+		keys = rest
+	}
+
+	return false, nil
+}
@@ -0,0 +1,111 @@
+//go:build !minimal && !no_sign
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// A helper function for tests to write a new SSH key pair (as "ssh-keygen -t ed25519" would).
+func writeSSHKeyPair(t *testing.T, fs afero.Fs, path string) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	require.NoError(t, err)
+	require.NoError(t, afero.WriteFile(fs, path, pem.EncodeToMemory(block), 0o600))
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	require.NoError(t, afero.WriteFile(fs, path+".pub", ssh.MarshalAuthorizedKey(sshPub), 0o644))
+}
+
+// Expectation: A created archive should be signed, with the signature verified against the trusted key.
+func Test_Program_Create_Signed_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeSSHKeyPair(t, fs, "/key")
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SignKey: "/key"})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	sig, err := afero.ReadFile(fs, "/out.tar.gz.sig")
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(sig, []byte("-----BEGIN SSH SIGNATURE-----\n")))
+
+	var stdoutBuf bytes.Buffer
+
+	prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.VerifySignature(t.Context(), "/out.tar.gz", "", "/key.pub"))
+	require.Contains(t, stdoutBuf.String(), "signed by: SHA256:")
+}
+
+// Expectation: A diff tarball should be signed, but only if it was written (for any differences).
+func Test_Program_Diff_Signed_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeSSHKeyPair(t, fs, "/key")
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/new.tar.gz", createTar([]string{"b.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SignKey: "/key"})
+
+	_, err := prog.Diff(t.Context(), "/old.tar.gz", "/new.tar.gz", "/diff.tar.gz", nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+	require.NoError(t, prog.VerifySignature(t.Context(), "/diff.tar.gz", "", "/key.pub"))
+
+	_, err = prog.Diff(t.Context(), "/old.tar.gz", "/old.tar.gz", "/same.tar.gz", nil)
+	require.NoError(t, err)
+
+	exists, err := afero.Exists(fs, "/same.tar.gz.sig")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+// Expectation: A tampered archive, or one signed by an untrusted key, should fail verification.
+func Test_Program_VerifySignature_Invalid_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  bool
+		trusted string
+	}{
+		{"Tampered archive", true, "/key.pub"},
+		{"Untrusted key", false, "/other.pub"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			writeSSHKeyPair(t, fs, "/key")
+			writeSSHKeyPair(t, fs, "/other")
+			require.NoError(t, afero.WriteFile(fs, "/src/a.txt", nil, 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SignKey: "/key"})
+			require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+			if tt.tamper {
+				require.NoError(t, afero.WriteFile(fs, "/out.tar.gz", createTar([]string{"a.txt", "b.txt"}), 0o644))
+			}
+
+			err := prog.VerifySignature(t.Context(), "/out.tar.gz", "/out.tar.gz.sig", tt.trusted)
+			require.ErrorIs(t, err, ErrSignatureInvalid)
+			require.Equal(t, exitCodeCorrupt, exitCodeFor(err))
+		})
+	}
+}
+
+// Expectation: A malformed signature file should be rejected.
+func Test_decodeSSHSig_Error(t *testing.T) {
+	_, err := decodeSSHSig([]byte("-----BEGIN SSH SIGNATURE-----\nbm90IGEgc2lnbmF0dXJl\n-----END SSH SIGNATURE-----\n"))
+	require.ErrorIs(t, err, errInvalidSignature)
+}
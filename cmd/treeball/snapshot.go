@@ -0,0 +1,337 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultSnapshotName = "{{.Root}}-{{.Date}}T{{.Time}}.tar.gz"
+
+var errInvalidSnapshotName = errors.New("invalid snapshot name")
+
+// SnapshotConfig is the configuration of a [Program.Snapshot] operation.
+type SnapshotConfig struct {
+	Dest        string // Directory of the archives
+	Name        string // Template of the archive names (see [SnapshotName]; empty: default)
+	KeepLast    int    // Most recent archives to keep
+	KeepDaily   int    // Most recent days to keep the last archive of
+	KeepWeekly  int    // Most recent (ISO) weeks to keep the last archive of
+	KeepMonthly int    // Most recent months to keep the last archive of
+}
+
+// SnapshotName holds the fields available to the name template of a [SnapshotConfig].
+type SnapshotName struct {
+	Host      string // Hostname of the machine
+	Root      string // Base name of the root directory
+	Date      string // Local date of the creation (e.g. 2025-01-02)
+	Time      string // Local time of the creation (e.g. 150405)
+	Timestamp string // UTC time of the creation (e.g. 20250102T150405Z)
+	Unix      string // Unix time of the creation (in seconds)
+}
+
+// hasRetention returns if any retention policy is set in the [SnapshotConfig].
+func (c *SnapshotConfig) hasRetention() bool {
+	return c.KeepLast > 0 || c.KeepDaily > 0 || c.KeepWeekly > 0 || c.KeepMonthly > 0
+}
+
+// Snapshot creates a timestamped archive of a directory tree into a directory
+// of archives, then prunes the older archives of the directory as per policy.
+//
+// The name of the archive is rendered from the [SnapshotConfig.Name] template
+// (as of [text/template], with the fields of [SnapshotName]). Any paths matching
+// the excludes slice are skipped (as with [Program.Create]).
+//
+// If any retention policy is set in the [SnapshotConfig], the older archives of
+// the same name template (with any times differing) are pruned, unless they are
+// among the last archives, or the last archive of one of the most recent days,
+// weeks or months (as configured). The times of the archives are those of their
+// last modification. The created archive is always kept. Any parts and sidecar
+// files (checksums, signatures and indexes) of pruned archives are removed along
+// with them. The path of the created archive is printed to standard output, as
+// are any pruned ones (as "pruned: "). If any entries were skipped by the
+// creation, pruning still happens, with [ErrPartialSuccess] returned after.
+// The ctx parameter controls early cancellation.
+func (prog *Program) Snapshot(ctx context.Context, root string, excludes []string, config *SnapshotConfig) (string, []string, error) {
+	prog, closeRemotes, err := prog.withRemotes(ctx, root, config.Dest)
+	if err != nil {
+		return "", nil, err
+	}
+	defer closeRemotes()
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(cmp.Or(config.Name, defaultSnapshotName))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse name template: %w", err)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve root: %w", err)
+	}
+
+	now := time.Now()
+
+	name, err := renderSnapshotName(tmpl, SnapshotName{
+		Host:      host,
+		Root:      filepath.Base(absRoot),
+		Date:      now.Format("2006-01-02"),
+		Time:      now.Format("150405"),
+		Timestamp: now.UTC().Format("20060102T150405Z"),
+		Unix:      fmt.Sprint(now.Unix()),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := prog.fs.MkdirAll(config.Dest, 0o755); err != nil {
+		return "", nil, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	output := joinPath(config.Dest, name)
+
+	// Only the archives are printed, not any of their entries (as by the creation).
+	quiet := *prog
+	quiet.stdout = io.Discard
+
+	// A partial success (with entries skipped) still prunes, with its error returned after.
+	createErr := quiet.Create(ctx, root, output, excludes)
+	if createErr != nil && !errors.Is(createErr, ErrPartialSuccess) {
+		return "", nil, createErr
+	}
+	prog.printPath(output)
+
+	if !config.hasRetention() {
+		return output, nil, createErr
+	}
+
+	progressFrom(ctx).setPhase("pruning %s", config.Dest)
+
+	pattern, err := snapshotPattern(tmpl, host, filepath.Base(absRoot))
+	if err != nil {
+		return output, nil, err
+	}
+
+	snapshots, err := prog.findSnapshots(config.Dest, pattern)
+	if err != nil {
+		return output, nil, err
+	}
+
+	var pruned []string
+
+	for _, s := range pruneSnapshots(snapshots, config) {
+		if s.path == output {
+			continue
+		}
+
+		for _, f := range s.files {
+			if err := prog.fs.Remove(f); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return output, pruned, fmt.Errorf("failed to prune archive: %w", err)
+			}
+		}
+		pruned = append(pruned, s.path)
+
+		prog.printPath("pruned: " + s.path)
+	}
+
+	return output, pruned, createErr
+}
+
+// renderSnapshotName renders the name of an archive from its template.
+func renderSnapshotName(tmpl *template.Template, data SnapshotName) (string, error) {
+	var sb strings.Builder
+
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render name template: %w", err)
+	}
+
+	name := sb.String()
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%w: %q (must be a file name)", errInvalidSnapshotName, name)
+	}
+
+	return name, nil
+}
+
+// snapshotPattern returns the expression matching the names of the archives
+// of a template (for a host and root), with the times matched by their formats.
+func snapshotPattern(tmpl *template.Template, host string, root string) (*regexp.Regexp, error) {
+	// The times are rendered as placeholders, which cannot be within any of the
+	// literal parts, then replaced by their expressions after quoting the rest.
+	times := []struct {
+		placeholder string
+		expr        string
+	}{
+		{"\x00date\x00", `\d{4}-\d{2}-\d{2}`},
+		{"\x00time\x00", `\d{6}`},
+		{"\x00timestamp\x00", `\d{8}T\d{6}Z`},
+		{"\x00unix\x00", `\d+`},
+	}
+
+	name, err := renderSnapshotName(tmpl, SnapshotName{
+		Host:      host,
+		Root:      root,
+		Date:      times[0].placeholder,
+		Time:      times[1].placeholder,
+		Timestamp: times[2].placeholder,
+		Unix:      times[3].placeholder,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expr := regexp.QuoteMeta(name)
+	for _, t := range times {
+		expr = strings.ReplaceAll(expr, t.placeholder, t.expr)
+	}
+
+	return regexp.Compile("^" + expr + "$")
+}
+
+// snapshotSidecarSuffixes are the suffixes of the files accompanying an
+// archive (as written by its creation), which are pruned along with it.
+var snapshotSidecarSuffixes = []string{
+	checksumPath("", ChecksumSHA256),
+	checksumPath("", ChecksumBLAKE3),
+	signatureSuffix,
+	indexSuffix,
+}
+
+// snapshotFile is an archive found in the directory of a [Program.Snapshot].
+type snapshotFile struct {
+	path    string
+	modTime time.Time
+	files   []string // Files of the archive (with any parts and sidecars)
+}
+
+// findSnapshots returns the archives in a directory matching the pattern,
+// with those split into parts found by their first part (as .000).
+func (prog *Program) findSnapshots(dir string, pattern *regexp.Regexp) ([]snapshotFile, error) {
+	entries, err := readDirEntries(prog.fs, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destination directory: %w", err)
+	}
+
+	var snapshots []snapshotFile
+
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+
+		name := e.Name()
+		if !pattern.MatchString(name) {
+			name = strings.TrimSuffix(name, splitFirstSuffix)
+			if name == e.Name() || !pattern.MatchString(name) {
+				continue
+			}
+		}
+
+		info, err := e.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to stat archive: %w", err)
+		}
+
+		snapshots = append(snapshots, snapshotFile{
+			path:    joinPath(dir, name),
+			modTime: info.ModTime(),
+			files:   snapshotFiles(entries, dir, name),
+		})
+	}
+
+	return snapshots, nil
+}
+
+// snapshotFiles returns the files of the directory entries belonging to an
+// archive, being the archive itself, any of its parts and any of its sidecars.
+func snapshotFiles(entries []fs.DirEntry, dir string, archive string) []string {
+	var files []string
+
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), archive)
+		if !ok {
+			continue
+		}
+
+		isPart := len(suffix) == len(splitFirstSuffix) && suffix[0] == '.' &&
+			strings.Trim(suffix[1:], "0123456789") == ""
+
+		if suffix == "" || isPart || slices.Contains(snapshotSidecarSuffixes, suffix) {
+			files = append(files, joinPath(dir, e.Name()))
+		}
+	}
+
+	return files
+}
+
+// pruneSnapshots returns the archives to prune as per the policy of the
+// [SnapshotConfig], which are those not kept by any of its rules (with the
+// rules keeping the last archive of each of their most recent periods).
+func pruneSnapshots(snapshots []snapshotFile, config *SnapshotConfig) []snapshotFile {
+	sorted := slices.Clone(snapshots)
+	slices.SortStableFunc(sorted, func(a, b snapshotFile) int {
+		return b.modTime.Compare(a.modTime) // Newest first.
+	})
+
+	keep := make([]bool, len(sorted))
+
+	rules := []struct {
+		count  int
+		period func(s snapshotFile) string
+	}{
+		{config.KeepLast, func(s snapshotFile) string { return s.path }},
+		{config.KeepDaily, func(s snapshotFile) string { return s.modTime.Local().Format("2006-01-02") }},
+		{config.KeepWeekly, func(s snapshotFile) string {
+			year, week := s.modTime.Local().ISOWeek()
+
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{config.KeepMonthly, func(s snapshotFile) string { return s.modTime.Local().Format("2006-01") }},
+	}
+
+	for _, rule := range rules {
+		var kept int
+		var lastPeriod string
+
+		for i, s := range sorted {
+			if kept >= rule.count {
+				break
+			}
+
+			period := rule.period(s)
+			if i > 0 && period == lastPeriod {
+				continue // Only the last archive of each period is kept.
+			}
+			lastPeriod = period
+
+			keep[i] = true
+			kept++
+		}
+	}
+
+	var prune []snapshotFile
+
+	for i, s := range sorted {
+		if !keep[i] {
+			prune = append(prune, s)
+		}
+	}
+
+	return prune
+}
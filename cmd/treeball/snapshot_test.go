@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to create snapshot files of the given ages (in hours).
+func createSnapshotFiles(t *testing.T, fs afero.Fs, now time.Time, hours ...int) []snapshotFile {
+	t.Helper()
+
+	snapshots := make([]snapshotFile, 0, len(hours))
+
+	for _, h := range hours {
+		s := snapshotFile{
+			path:    filepath.Join("/snaps", "src-"+now.Add(-time.Duration(h)*time.Hour).Format("2006-01-02T150405")+".tar.gz"),
+			modTime: now.Add(-time.Duration(h) * time.Hour),
+		}
+		require.NoError(t, afero.WriteFile(fs, s.path, createTar([]string{"old.txt"}), 0o644))
+		require.NoError(t, fs.Chtimes(s.path, s.modTime, s.modTime))
+
+		snapshots = append(snapshots, s)
+	}
+
+	return snapshots
+}
+
+// Expectation: The archives not kept by any of the rules should be pruned.
+func Test_pruneSnapshots_Table(t *testing.T) {
+	now := time.Date(2025, 6, 18, 12, 0, 0, 0, time.Local) // A Wednesday.
+
+	// Twice a day for the last 60 days (i.e. hours 0, 12, 24, 36, ...).
+	var hours []int
+	for h := 0; h < 60*24; h += 12 {
+		hours = append(hours, h)
+	}
+
+	tests := []struct {
+		name   string
+		config SnapshotConfig
+		kept   int
+	}{
+		{"Last", SnapshotConfig{KeepLast: 3}, 3},
+		{"Daily", SnapshotConfig{KeepDaily: 7}, 7},
+		{"Weekly", SnapshotConfig{KeepWeekly: 4}, 4},
+		{"Monthly", SnapshotConfig{KeepMonthly: 2}, 2},
+		{"Daily and weekly", SnapshotConfig{KeepDaily: 7, KeepWeekly: 4}, 7 + 2}, // The last day of the previous week is kept daily.
+		{"Overlapping last and daily", SnapshotConfig{KeepLast: 2, KeepDaily: 2}, 3},
+		{"More than existing", SnapshotConfig{KeepLast: 1000}, len(hours)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots := createSnapshotFiles(t, afero.NewMemMapFs(), now, hours...)
+
+			pruned := pruneSnapshots(snapshots, &tt.config)
+			require.Len(t, pruned, len(hours)-tt.kept)
+
+			for _, p := range pruned {
+				require.NotEqual(t, snapshots[0].path, p.path, "newest archive was pruned")
+			}
+		})
+	}
+}
+
+// Expectation: The last archive of each day should be the one kept.
+func Test_pruneSnapshots_LastOfPeriod_Success(t *testing.T) {
+	now := time.Date(2025, 6, 18, 23, 0, 0, 0, time.Local)
+	snapshots := createSnapshotFiles(t, afero.NewMemMapFs(), now, 0, 2, 4, 24, 26)
+
+	pruned := pruneSnapshots(snapshots, &SnapshotConfig{KeepDaily: 2})
+
+	require.Equal(t, []snapshotFile{snapshots[1], snapshots[2], snapshots[4]}, pruned)
+}
+
+// Expectation: The archive should be created and the older ones of the template pruned.
+func Test_Program_Snapshot_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, fs.MkdirAll("/src/dir", 0o755))
+	require.NoError(t, afero.WriteFile(fs, "/src/dir/a.txt", nil, 0o644))
+
+	old := createSnapshotFiles(t, fs, time.Now(), 48, 72, 96)
+	require.NoError(t, afero.WriteFile(fs, "/snaps/other.tar.gz", nil, 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	output, pruned, err := prog.Snapshot(t.Context(), "/src", nil, &SnapshotConfig{Dest: "/snaps", KeepLast: 2})
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(filepath.Base(output), "src-"))
+	require.Equal(t, []string{"dir/", "dir/a.txt"}, readTarNames(t, fs, output))
+	require.Equal(t, []string{old[1].path, old[2].path}, pruned)
+
+	for _, p := range []string{output, old[0].path, "/snaps/other.tar.gz"} {
+		_, err := fs.Stat(p)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, output+"\npruned: "+old[1].path+"\npruned: "+old[2].path+"\n", stdoutBuf.String())
+}
+
+// Expectation: Without any retention policy, no archives should be pruned.
+func Test_Program_Snapshot_NoRetention_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, fs.MkdirAll("/src", 0o755))
+	createSnapshotFiles(t, fs, time.Now(), 48, 72)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, pruned, err := prog.Snapshot(t.Context(), "/src", nil, &SnapshotConfig{Dest: "/snaps"})
+	require.NoError(t, err)
+	require.Empty(t, pruned)
+
+	entries, err := afero.ReadDir(fs, "/snaps")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+}
+
+// Expectation: The name should be rendered from the template, also into a new destination.
+func Test_Program_Snapshot_Name_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, fs.MkdirAll("/src", 0o755))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	output, _, err := prog.Snapshot(t.Context(), "/src", nil, &SnapshotConfig{Dest: "/new/snaps", Name: "inventory-{{.Root}}-{{.Date}}.tar.gz"})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("/new/snaps", "inventory-src-"+time.Now().Format("2006-01-02")+".tar.gz"), output)
+}
+
+// Expectation: An existing archive of the same name should not be overwritten (without --force).
+func Test_Program_Snapshot_Exists_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, fs.MkdirAll("/src", 0o755))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	config := &SnapshotConfig{Dest: "/snaps", Name: "{{.Root}}.tar.gz"}
+
+	_, _, err := prog.Snapshot(t.Context(), "/src", nil, config)
+	require.NoError(t, err)
+
+	_, _, err = prog.Snapshot(t.Context(), "/src", nil, config)
+	require.ErrorIs(t, err, ErrOutputExists)
+}
+
+// Expectation: Invalid name templates should be returned as errors.
+func Test_Program_Snapshot_Name_Error_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  error
+	}{
+		{"Unparsable", "{{.Root", nil},
+		{"Unknown field", "{{.Unknown}}.tar.gz", nil},
+		{"Path separator", "{{.Root}}/{{.Date}}.tar.gz", errInvalidSnapshotName},
+		{"Empty rendering", "{{if false}}x{{end}}", errInvalidSnapshotName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, fs.MkdirAll("/src", 0o755))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+			_, _, err := prog.Snapshot(t.Context(), "/src", nil, &SnapshotConfig{Dest: "/snaps", Name: tt.template})
+			require.Error(t, err)
+
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Expectation: Only the archives of the same root should be pruned, not those of roots sharing its prefix.
+func Test_Program_Snapshot_SharedPrefix_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, fs.MkdirAll("/media", 0o755))
+
+	stamp := time.Now().Add(-48 * time.Hour).Format("2006-01-02T150405")
+	own := "/snaps/media-" + stamp + ".tar.gz"
+	other := "/snaps/media-old-" + stamp + ".tar.gz"
+
+	for _, p := range []string{own, other} {
+		require.NoError(t, afero.WriteFile(fs, p, createTar([]string{"old.txt"}), 0o644))
+	}
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, pruned, err := prog.Snapshot(t.Context(), "/media", nil, &SnapshotConfig{Dest: "/snaps", KeepLast: 1})
+	require.NoError(t, err)
+	require.Equal(t, []string{own}, pruned)
+
+	_, err = fs.Stat(other)
+	require.NoError(t, err)
+}
+
+// Expectation: The parts and sidecar files of a pruned archive should be removed along with it.
+func Test_Program_Snapshot_Sidecars_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, fs.MkdirAll("/src", 0o755))
+
+	old := createSnapshotFiles(t, fs, time.Now(), 48)[0].path
+	split := "/snaps/src-" + time.Now().Add(-72*time.Hour).Format("2006-01-02T150405") + ".tar.gz"
+
+	files := []string{
+		old + ".sha256", old + ".blake3", old + ".sig", old + ".tbi",
+		split + ".000", split + ".001", split + ".sha256",
+	}
+	for _, p := range files {
+		require.NoError(t, afero.WriteFile(fs, p, nil, 0o644))
+	}
+	require.NoError(t, afero.WriteFile(fs, old+".notes", nil, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, pruned, err := prog.Snapshot(t.Context(), "/src", nil, &SnapshotConfig{Dest: "/snaps", KeepLast: 1})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{old, split}, pruned)
+
+	for _, p := range append(files, old) {
+		_, err := fs.Stat(p)
+		require.ErrorIs(t, err, os.ErrNotExist, p)
+	}
+
+	_, err = fs.Stat(old + ".notes")
+	require.NoError(t, err)
+}
+
+// Expectation: Skipped entries should still prune, returning the partial success after.
+func Test_Program_Snapshot_SkipErrors_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+
+	old := createSnapshotFiles(t, fs, time.Now(), 48)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SkipErrors: true})
+	prog.fsWalker = failingPathWalker{walker: prog.fsWalker, path: "/src/b"}
+
+	output, pruned, err := prog.Snapshot(t.Context(), "/src", nil, &SnapshotConfig{Dest: "/snaps", KeepLast: 1})
+	require.ErrorIs(t, err, ErrPartialSuccess)
+	require.Equal(t, exitCodePartial, exitCodeFor(err))
+
+	require.Equal(t, []string{"a.txt"}, readTarNames(t, fs, output))
+	require.Equal(t, []string{old[0].path}, pruned)
+}
+
+// Expectation: The pattern should match the times by their formats and any other fields literally.
+func Test_snapshotPattern_Table(t *testing.T) {
+	tests := []struct {
+		template string
+		name     string
+		match    bool
+	}{
+		{defaultSnapshotName, "src-2025-01-02T150405.tar.gz", true},
+		{defaultSnapshotName, "src-old-2025-01-02T150405.tar.gz", false},
+		{defaultSnapshotName, "src-2025-01-02T150405.tar.gz.sha256", false},
+		{defaultSnapshotName, "src-2025-01-02T1504.tar.gz", false},
+		{"{{.Host}}-{{.Timestamp}}.tar.gz", "host[1]*-20250102T150405Z.tar.gz", true},
+		{"{{.Host}}-{{.Timestamp}}.tar.gz", "host1x-20250102T150405Z.tar.gz", false},
+		{"{{.Root}}-{{.Unix}}.tar", "src-1735830245.tar", true},
+		{"{{.Root}}-{{.Unix}}.tar", "src-x.tar", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, err := snapshotPattern(template.Must(template.New("name").Parse(tt.template)), "host[1]*", "src")
+			require.NoError(t, err)
+			require.Equal(t, tt.match, pattern.MatchString(tt.name))
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const dirSourcePrefix = "dir:"
+
+var (
+	errInvalidSource     = errors.New("invalid source")
+	errSourceNeedsPrefix = errors.New("merged sources must each be given as dir:Prefix=/path")
+	errSourceNotDir      = errors.New("source with prefix is not a directory")
+)
+
+// pathSource is a source of paths, being either a directory or a tarball (or
+// the index of a git repository). The paths of a directory can be placed under
+// a prefix, so that multiple directories can be merged into one tree (such as
+// that of a multi-root archive).
+type pathSource struct {
+	prefix string // Prefix to place the paths under (empty: none)
+	path   string // Path of the directory or tarball (or the git worktree)
+	git    bool   // Path is a git worktree, of which the index is streamed
+}
+
+// parseSource parses a source argument, which is either a plain path (of a
+// directory or tarball), a directory with a prefix in the dir:Prefix=/path
+// format, or a git worktree in the git:/path format. Prefixes must be relative
+// paths, which are returned in cleaned form.
+func parseSource(arg string) (pathSource, error) {
+	if repo, ok := strings.CutPrefix(arg, gitSourcePrefix); ok {
+		if repo == "" {
+			return pathSource{}, fmt.Errorf("%w: %q (expected git:/path)", errInvalidSource, arg)
+		}
+
+		return pathSource{path: repo, git: true}, nil
+	}
+
+	spec, ok := strings.CutPrefix(arg, dirSourcePrefix)
+	if !ok {
+		return pathSource{path: arg}, nil
+	}
+
+	prefix, dir, ok := strings.Cut(spec, "=")
+	if !ok || dir == "" {
+		return pathSource{}, fmt.Errorf("%w: %q (expected dir:Prefix=/path)", errInvalidSource, arg)
+	}
+
+	prefix = path.Clean(strings.ReplaceAll(prefix, "\\", "/"))
+	if prefix == "." || path.IsAbs(prefix) || prefix == ".." || strings.HasPrefix(prefix, "../") {
+		return pathSource{}, fmt.Errorf("%w: %q (prefix must be a relative path)", errInvalidSource, arg)
+	}
+
+	return pathSource{prefix: prefix, path: dir}, nil
+}
+
+// sourcesPathStream returns a sorted stream of the paths of the given sources.
+//
+// A single source without prefix is streamed as-is (see [Program.multiPathStream]),
+// as is a single git worktree (see [Program.gitPathStream]).
+// Otherwise all sources must be directories with prefixes, which are then merged
+// into one tree, with any directories making up the prefixes themselves included.
+// Any paths contained in more than one of the sources are only streamed once.
+func (prog *Program) sourcesPathStream(ctx context.Context, args []string, excludes []string) (<-chan string, <-chan error, error) {
+	sources := make([]pathSource, 0, len(args))
+
+	for _, arg := range args {
+		src, err := parseSource(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		sources = append(sources, src)
+	}
+
+	if len(sources) == 1 && sources[0].git {
+		paths, errs := prog.gitPathStream(ctx, sources[0].path, true, excludes)
+
+		return paths, errs, nil
+	}
+
+	if len(sources) == 1 && sources[0].prefix == "" {
+		return prog.multiPathStream(ctx, sources[0].path, true, excludes)
+	}
+
+	matcher := prog.excludeMatcher(excludes)
+
+	prefixDirs := []string{}
+	for _, src := range sources {
+		if src.prefix == "" {
+			return nil, nil, fmt.Errorf("%w: %q", errSourceNeedsPrefix, src.path)
+		}
+
+		info, err := prog.fs.Stat(src.path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to stat: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, nil, fmt.Errorf("%w: %q", errSourceNotDir, src.path)
+		}
+
+		for dir := src.prefix; dir != "."; dir = path.Dir(dir) {
+			if excluded, err := prog.isExcluded(dir, true, matcher); err != nil {
+				return nil, nil, fmt.Errorf("failed to check for exclusion: %w", err)
+			} else if !excluded {
+				prefixDirs = append(prefixDirs, dir+"/")
+			}
+		}
+	}
+
+	slices.SortFunc(prefixDirs, prog.comparePaths)
+
+	prefixStream := make(chan string, len(prefixDirs))
+	for _, dir := range slices.Compact(prefixDirs) {
+		prefixStream <- dir
+	}
+	close(prefixStream)
+
+	streams := []<-chan string{prefixStream}
+	errs := []<-chan error{}
+
+	for _, src := range sources {
+		paths, srcErrs := prog.fsPathStream(ctx, src.path, src.prefix, true, excludes)
+		streams = append(streams, paths)
+		errs = append(errs, srcErrs)
+	}
+
+	paths, mergedErrs := mergeSortedStreams(ctx, streams, errs, prog.comparePaths)
+
+	return paths, mergedErrs, nil
+}
+
+// mergeSortedStreams merges multiple sorted streams into one sorted stream,
+// with any duplicate paths (contained in more than one stream) removed. The
+// streams are expected as sorted by the compare function (as for [strings.Compare]).
+// Only the first error observed from any of the errs channels is sent downstream.
+func mergeSortedStreams(ctx context.Context, streams []<-chan string, errs []<-chan error, compare func(a, b string) int) (<-chan string, <-chan error) {
+	out := make(chan string, fsStreamBuffer)
+	mergedErrs := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer recoverWorker(ctx)
+
+		heads := make([]string, len(streams))
+		live := make([]bool, len(streams))
+
+		for i, stream := range streams {
+			heads[i], live[i] = <-stream
+		}
+
+		var last string
+		var emitted bool
+
+		for {
+			next := -1
+			for i := range streams {
+				if live[i] && (next < 0 || compare(heads[i], heads[next]) < 0) {
+					next = i
+				}
+			}
+
+			if next < 0 {
+				return
+			}
+
+			if !emitted || compare(heads[next], last) != 0 {
+				select {
+				case out <- heads[next]:
+				case <-ctx.Done():
+					return
+				}
+				last, emitted = heads[next], true
+			}
+
+			heads[next], live[next] = <-streams[next]
+		}
+	}()
+
+	go func() {
+		defer close(mergedErrs)
+		defer recoverWorker(ctx)
+
+		var once sync.Once
+		var wg sync.WaitGroup
+
+		for _, errc := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer recoverWorker(ctx)
+
+				for err := range errc {
+					if err != nil {
+						once.Do(func() { mergedErrs <- err })
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		if err := ctx.Err(); err != nil {
+			once.Do(func() { mergedErrs <- fmt.Errorf("failed to merge streams: %w", err) })
+		}
+	}()
+
+	return out, mergedErrs
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The source arguments should be parsed into their prefixes and paths.
+func Test_parseSource_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    pathSource
+		wantErr bool
+	}{
+		{"Plain directory", "/mnt/m", pathSource{path: "/mnt/m"}, false},
+		{"Plain tarball", "old.tar.gz", pathSource{path: "old.tar.gz"}, false},
+		{"Prefixed directory", "dir:Movies=/mnt/m", pathSource{prefix: "Movies", path: "/mnt/m"}, false},
+		{"Nested prefix", "dir:media/TV/=/mnt/t", pathSource{prefix: "media/TV", path: "/mnt/t"}, false},
+		{"Path containing equals", "dir:A=/mnt/a=b", pathSource{prefix: "A", path: "/mnt/a=b"}, false},
+		{"Missing equals", "dir:Movies", pathSource{}, true},
+		{"Missing path", "dir:Movies=", pathSource{}, true},
+		{"Empty prefix", "dir:=/mnt/m", pathSource{}, true},
+		{"Absolute prefix", "dir:/Movies=/mnt/m", pathSource{}, true},
+		{"Escaping prefix", "dir:../Movies=/mnt/m", pathSource{}, true},
+		{"Git worktree", "git:/src/project", pathSource{path: "/src/project", git: true}, false},
+		{"Missing git worktree", "git:", pathSource{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSource(tt.arg)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidSource)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: Multiple directories should be merged under their prefixes.
+func Test_Program_sourcesPathStream_Merged_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/mnt/m/b.mkv", []byte("b"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/mnt/m/a/x.mkv", []byte("x"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/mnt/t/s01/e01.mkv", []byte("e"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/mnt/o/c.txt", []byte("c"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs, err := prog.sourcesPathStream(t.Context(), []string{
+		"dir:Movies=/mnt/m", "dir:media/TV=/mnt/t", "dir:media/Other=/mnt/o",
+	}, nil)
+	require.NoError(t, err)
+
+	var got []string
+	for p := range paths {
+		got = append(got, p)
+	}
+	require.NoError(t, <-errs)
+
+	require.Equal(t, []string{
+		"Movies/",
+		"Movies/a/",
+		"Movies/a/x.mkv",
+		"Movies/b.mkv",
+		"media/",
+		"media/Other/",
+		"media/Other/c.txt",
+		"media/TV/",
+		"media/TV/s01/",
+		"media/TV/s01/e01.mkv",
+	}, got)
+}
+
+// Expectation: Excludes should be applied to the merged paths, including the prefixes.
+func Test_Program_sourcesPathStream_MergedExcludes_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/mnt/m/a.mkv", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/mnt/m/a.nfo", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/mnt/t/e01.mkv", []byte("e"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs, err := prog.sourcesPathStream(t.Context(), []string{
+		"dir:Movies=/mnt/m", "dir:TV=/mnt/t",
+	}, []string{"Movies/*.nfo", "TV", "TV/**"})
+	require.NoError(t, err)
+
+	var got []string
+	for p := range paths {
+		got = append(got, p)
+	}
+	require.NoError(t, <-errs)
+
+	require.Equal(t, []string{"Movies/", "Movies/a.mkv"}, got)
+}
+
+// Expectation: Merging should fail for sources without prefix or those not being directories.
+func Test_Program_sourcesPathStream_Invalid_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/mnt/m/a.mkv", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/old.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, _, err := prog.sourcesPathStream(t.Context(), []string{"dir:Movies=/mnt/m", "/mnt/m"}, nil)
+	require.ErrorIs(t, err, errSourceNeedsPrefix)
+
+	_, _, err = prog.sourcesPathStream(t.Context(), []string{"dir:Old=/old.tar.gz"}, nil)
+	require.ErrorIs(t, err, errSourceNotDir)
+
+	_, _, err = prog.sourcesPathStream(t.Context(), []string{"dir:Missing=/missing"}, nil)
+	require.ErrorContains(t, err, "failed to stat")
+
+	_, _, err = prog.sourcesPathStream(t.Context(), []string{"dir:=/mnt/m"}, nil)
+	require.ErrorIs(t, err, errInvalidSource)
+}
+
+// Expectation: Duplicate paths across streams should only be emitted once.
+func Test_mergeSortedStreams_Duplicates_Success(t *testing.T) {
+	streamOf := func(paths ...string) <-chan string {
+		ch := make(chan string, len(paths))
+		for _, p := range paths {
+			ch <- p
+		}
+		close(ch)
+
+		return ch
+	}
+
+	errc := make(chan error)
+	close(errc)
+
+	paths, errs := mergeSortedStreams(t.Context(),
+		[]<-chan string{streamOf("a/", "a/x", "c"), streamOf("a/", "b"), streamOf()},
+		[]<-chan error{errc},
+		strings.Compare,
+	)
+
+	var got []string
+	for p := range paths {
+		got = append(got, p)
+	}
+	require.NoError(t, <-errs)
+
+	require.Equal(t, []string{"a/", "a/x", "b", "c"}, got)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"archive/tar"
+	"io/fs"
+)
+
+// specialTypeflag returns the tar type flag of a special file (FIFOs and
+// devices), or false for any other files. Sockets have no type within tar
+// archives (and are not archived by GNU tar either), so remain regular files.
+func specialTypeflag(mode fs.FileMode) (byte, bool) {
+	switch {
+	case mode&fs.ModeNamedPipe != 0:
+		return tar.TypeFifo, true
+	case mode&fs.ModeCharDevice != 0:
+		return tar.TypeChar, true
+	case mode&fs.ModeDevice != 0:
+		return tar.TypeBlock, true
+	default:
+		return 0, false
+	}
+}
+
+// setSpecialType sets the actual type of a special file (see [specialTypeflag])
+// in the tar header of its dummy file, along with the device numbers of devices.
+func setSpecialType(hdr *tar.Header, typeflag byte, info fs.FileInfo) {
+	hdr.Typeflag = typeflag
+
+	if typeflag == tar.TypeChar || typeflag == tar.TypeBlock {
+		hdr.Devmajor, hdr.Devminor = deviceNumbers(info)
+	}
+}
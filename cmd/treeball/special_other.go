@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// deviceNumbers returns zero device numbers, as these are not available.
+func deviceNumbers(_ fs.FileInfo) (int64, int64) {
+	return 0, 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"archive/tar"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The special files should be mapped to their tar type flags.
+func Test_specialTypeflag_Table(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   fs.FileMode
+		want   byte
+		wantOk bool
+	}{
+		{"fifo", fs.ModeNamedPipe | 0o644, tar.TypeFifo, true},
+		{"char", fs.ModeDevice | fs.ModeCharDevice | 0o666, tar.TypeChar, true},
+		{"block", fs.ModeDevice | 0o660, tar.TypeBlock, true},
+		{"socket", fs.ModeSocket | 0o755, 0, false},
+		{"symlink", fs.ModeSymlink | 0o777, 0, false},
+		{"dir", fs.ModeDir | 0o755, 0, false},
+		{"regular", 0o644, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := specialTypeflag(tt.mode)
+			require.Equal(t, tt.wantOk, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
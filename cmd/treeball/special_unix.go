@@ -0,0 +1,21 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// deviceNumbers returns the major and minor numbers of a device file.
+func deviceNumbers(info fs.FileInfo) (int64, int64) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		rdev := uint64(st.Rdev) //nolint:unconvert,nolintlint
+
+		return int64(unix.Major(rdev)), int64(unix.Minor(rdev))
+	}
+
+	return 0, 0
+}
@@ -0,0 +1,124 @@
+//go:build unix
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to create a tree containing a FIFO and a device.
+func createSpecialTree(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), nil, 0o644))
+	require.NoError(t, syscall.Mkfifo(filepath.Join(root, "fifo"), 0o644))
+
+	if _, err := os.Stat("/dev/null"); err == nil {
+		require.NoError(t, os.Symlink("/dev/null", filepath.Join(root, "null")))
+	}
+
+	return root
+}
+
+// A helper function for tests to read the type flags of the entries of a tarball.
+func readTarTypes(t *testing.T, path string) map[string]byte {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	tr := tar.NewReader(gzr)
+
+	types := make(map[string]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		types[hdr.Name] = hdr.Typeflag
+	}
+
+	return types
+}
+
+// Expectation: The special files should be written with their types when enabled.
+func Test_Program_Create_SpecialFiles_Success(t *testing.T) {
+	root := createSpecialTree(t)
+	out := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{SpecialFiles: true})
+	require.NoError(t, prog.Create(t.Context(), root, out, nil))
+
+	types := readTarTypes(t, out)
+	require.Equal(t, byte(tar.TypeReg), types["a.txt"])
+	require.Equal(t, byte(tar.TypeFifo), types["fifo"])
+	require.Equal(t, byte(tar.TypeReg), types["null"]) // Symlinks are not followed to devices.
+
+	paths, errs := prog.tarPathStream(t.Context(), out, true, nil)
+
+	var got []string
+	for p := range paths {
+		got = append(got, p)
+	}
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Contains(t, got, "fifo")
+}
+
+// Expectation: The special files should be written as regular files when not enabled.
+func Test_Program_Create_SpecialFilesDisabled_Success(t *testing.T) {
+	root := createSpecialTree(t)
+	out := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, nil)
+	require.NoError(t, prog.Create(t.Context(), root, out, nil))
+
+	require.Equal(t, byte(tar.TypeReg), readTarTypes(t, out)["fifo"])
+}
+
+// Expectation: The device numbers of a device should be set along with its type.
+func Test_setSpecialType_Device_Success(t *testing.T) {
+	info, err := os.Stat("/dev/null")
+	if err != nil {
+		t.Skip("no /dev/null on this system")
+	}
+
+	typeflag, ok := specialTypeflag(info.Mode())
+	require.True(t, ok)
+
+	hdr := dummyHeader("dev/null", false, tar.FormatUnknown)
+	setSpecialType(hdr, typeflag, info)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, writeTarHeader(tw, hdr))
+	require.NoError(t, tw.Close())
+
+	hdr, err = tar.NewReader(&buf).Next()
+	require.NoError(t, err)
+	require.Equal(t, byte(tar.TypeChar), hdr.Typeflag)
+
+	major, minor := deviceNumbers(info)
+	require.Equal(t, major, hdr.Devmajor)
+	require.Equal(t, minor, hdr.Devminor)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// splitFirstSuffix is the suffix of the first part of a split archive.
+const splitFirstSuffix = ".000"
+
+var errInvalidSize = errors.New("invalid size")
+
+// splitPartPath returns the path of a part of a split archive (as output.tar.gz.000).
+func splitPartPath(archive string, part int) string {
+	return fmt.Sprintf("%s.%03d", archive, part)
+}
+
+// parseSize returns the amount of bytes of a size (as for --split-size), such
+// as "1G" or "700MiB", with the units being binary ones (as with split -b).
+func parseSize(s string) (int64, error) {
+	num := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B"), "I")
+
+	var shift uint
+
+	if n := len(num); n > 0 {
+		switch num[n-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		case 'T':
+			shift = 40
+		}
+		if shift > 0 {
+			num = num[:n-1]
+		}
+	}
+
+	v, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || v <= 0 || v > (1<<62)>>shift {
+		return 0, fmt.Errorf("%w: %q (expected e.g. 700M or 4G)", errInvalidSize, s)
+	}
+
+	return v << shift, nil
+}
+
+// splitWriter is an [io.WriteCloser] writing into the parts of a split archive,
+// each of which is filled up to the size limit before continuing with the next
+// one (as with split -b), so that the parts only need to be concatenated again.
+type splitWriter struct {
+	ctx  context.Context //nolint:containedctx
+	prog *Program
+
+	archive string
+	size    int64
+
+	parts   []string   // Paths of the parts created so far
+	cur     afero.File // Currently written part (nil: none yet, or full)
+	written int64      // Bytes written into the currently written part
+}
+
+// createSplitOutput returns a [splitWriter] into the parts of a split archive
+// of parts of the given size (as output.tar.gz.000, output.tar.gz.001, ...),
+// which are created as they are needed, so that no part is ever left empty.
+//
+// Any existing parts are protected as the output files (see [Program.createOutput]),
+// all of which are renamed aside with [ProgramConfig.Backup] or removed with
+// [ProgramConfig.Force] up front, so that no stale parts of a previously larger
+// archive can remain (which would otherwise be read as part of the new one).
+func (prog *Program) createSplitOutput(ctx context.Context, archive string, size int64) (*splitWriter, error) {
+	for i := 0; ; i++ {
+		part := splitPartPath(archive, i)
+
+		if _, err := prog.fs.Stat(part); errors.Is(err, fs.ErrNotExist) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to stat output part: %w", err)
+		}
+
+		switch {
+		case prog.config.Backup:
+			if err := prog.backupOutput(part); err != nil {
+				return nil, err
+			}
+		case prog.config.Force:
+			if err := prog.fs.Remove(part); err != nil {
+				return nil, fmt.Errorf("failed to remove output part: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s (use --force or --backup)", ErrOutputExists, part)
+		}
+	}
+
+	return &splitWriter{ctx: ctx, prog: prog, archive: archive, size: size}, nil
+}
+
+// Write is a method that writes into the parts of the split archive.
+func (sw *splitWriter) Write(p []byte) (int, error) {
+	var n int
+
+	for len(p) > 0 {
+		if sw.cur == nil {
+			part := splitPartPath(sw.archive, len(sw.parts))
+
+			f, err := sw.prog.createOutput(sw.ctx, part)
+			if err != nil {
+				return n, fmt.Errorf("failed to create output part: %w", err)
+			}
+
+			sw.cur, sw.written = f, 0
+			sw.parts = append(sw.parts, part)
+		}
+
+		chunk := p[:min(int64(len(p)), sw.size-sw.written)]
+
+		m, err := sw.cur.Write(chunk)
+		n += m
+		sw.written += int64(m)
+		p = p[m:]
+
+		if err != nil {
+			return n, fmt.Errorf("failed to write output part: %w", err)
+		}
+
+		if sw.written >= sw.size {
+			if err := sw.cur.Close(); err != nil {
+				return n, fmt.Errorf("failed to close output part: %w", err)
+			}
+			sw.cur = nil
+		}
+	}
+
+	return n, nil
+}
+
+// Close is a method that closes the currently written part of the split archive.
+func (sw *splitWriter) Close() error {
+	if sw.cur == nil {
+		return nil
+	}
+
+	err := sw.cur.Close()
+	sw.cur = nil
+
+	return err //nolint:wrapcheck
+}
+
+// Sync is a method that syncs the currently written part of the split archive.
+func (sw *splitWriter) Sync() error {
+	if sw.cur == nil {
+		return nil
+	}
+
+	return sw.cur.Sync() //nolint:wrapcheck
+}
+
+// remove removes all parts of the split archive created so far (upon failure).
+func (sw *splitWriter) remove() {
+	_ = sw.Close()
+
+	for _, part := range sw.parts {
+		_ = sw.prog.fs.Remove(part)
+	}
+}
+
+// archiveParts returns the paths of the files making up an archive, which is
+// either the archive itself or, if there is no such file, the parts of a split
+// archive (as written with --split-size). The first part of a split archive
+// (ending with .000) can also be given for the archive itself.
+func (prog *Program) archiveParts(archive string) ([]string, error) {
+	_, err := prog.fs.Stat(archive)
+
+	switch {
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return []string{archive}, nil // Surfacing the error upon opening.
+	case err == nil:
+		base, ok := strings.CutSuffix(archive, splitFirstSuffix)
+		if !ok {
+			return []string{archive}, nil
+		}
+		archive = base
+	default:
+		if _, err := prog.fs.Stat(splitPartPath(archive, 0)); err != nil {
+			return []string{archive}, nil // Surfacing the error upon opening.
+		}
+	}
+
+	var parts []string
+
+	for i := 0; ; i++ {
+		part := splitPartPath(archive, i)
+
+		if _, err := prog.fs.Stat(part); errors.Is(err, fs.ErrNotExist) {
+			return parts, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to stat archive part: %w", err)
+		}
+
+		parts = append(parts, part)
+	}
+}
+
+// openArchive opens an archive for reading, which can also be a split archive
+// (see [Program.archiveParts]), of which the parts are then read one after
+// another (as if they were concatenated again).
+func (prog *Program) openArchive(archive string) (io.ReadCloser, error) {
+	parts, err := prog.archiveParts(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) == 1 {
+		return prog.fs.Open(parts[0]) //nolint:wrapcheck
+	}
+
+	return &partsReader{fs: prog.fs, parts: parts}, nil
+}
+
+// partsReader is an [io.ReadCloser] reading the parts of a split archive one
+// after another, with only one of them being open at any given time.
+type partsReader struct {
+	fs    afero.Fs
+	parts []string
+	cur   afero.File
+}
+
+// Read is a method that reads from the current part, moving on to the next
+// part once the current one is exhausted.
+func (pr *partsReader) Read(p []byte) (int, error) {
+	for {
+		if pr.cur == nil {
+			if len(pr.parts) == 0 {
+				return 0, io.EOF
+			}
+
+			f, err := pr.fs.Open(pr.parts[0])
+			if err != nil {
+				return 0, err //nolint:wrapcheck
+			}
+
+			pr.cur, pr.parts = f, pr.parts[1:]
+		}
+
+		n, err := pr.cur.Read(p)
+		if errors.Is(err, io.EOF) {
+			_ = pr.cur.Close()
+			pr.cur = nil
+
+			if n == 0 {
+				continue
+			}
+
+			return n, nil
+		}
+
+		return n, err //nolint:wrapcheck
+	}
+}
+
+// Close is a method that closes the currently read part.
+func (pr *partsReader) Close() error {
+	if pr.cur == nil {
+		return nil
+	}
+
+	return pr.cur.Close() //nolint:wrapcheck
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// The paths of the tree for the splitting tests.
+var splitTree = []string{"/src/a.txt", "/src/b/c.txt", "/src/b/d.txt", "/src/e.txt"}
+
+// Expectation: The sizes should be parsed with binary units.
+func Test_parseSize_Table(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"4k", 4 << 10, false},
+		{"700M", 700 << 20, false},
+		{"700MiB", 700 << 20, false},
+		{"4GB", 4 << 30, false},
+		{"1T", 1 << 40, false},
+		{"", 0, true},
+		{"0", 0, true},
+		{"-1G", 0, true},
+		{"1X", 0, true},
+		{"99999999999T", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			got, err := parseSize(tt.size)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidSize)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Expectation: A split archive should be written in parts, which are read again as one archive.
+func Test_Program_Create_Split_Success(t *testing.T) {
+	fs := newTreeFs(t, splitTree...)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SplitSize: 100})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	parts, err := prog.archiveParts("/out.tar.gz")
+	require.NoError(t, err)
+	require.Greater(t, len(parts), 1)
+
+	var joined []byte
+	for i, part := range parts {
+		data, err := afero.ReadFile(fs, part)
+		require.NoError(t, err)
+		require.Equal(t, splitPartPath("/out.tar.gz", i), part)
+		require.LessOrEqual(t, len(data), 100)
+		joined = append(joined, data...)
+	}
+	require.NoError(t, afero.WriteFile(fs, "/joined.tar.gz", joined, 0o644))
+
+	for _, input := range []string{"/out.tar.gz", "/out.tar.gz.000"} {
+		var stdoutBuf bytes.Buffer
+
+		prog = NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+		require.NoError(t, prog.List(t.Context(), input, true, nil))
+		require.Equal(t, "a.txt\nb/\nb/c.txt\nb/d.txt\ne.txt\n", stdoutBuf.String())
+	}
+
+	require.Equal(t, readTarNames(t, fs, "/joined.tar.gz"), []string{"a.txt", "b/", "b/c.txt", "b/d.txt", "e.txt"})
+}
+
+// Expectation: A split archive should be compared against its source without any differences.
+func Test_Program_Diff_Split_Success(t *testing.T) {
+	fs := newTreeFs(t, splitTree...)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SplitSize: 64})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	result, err := prog.Diff(t.Context(), "/out.tar.gz", "/src", "", nil)
+	require.NoError(t, err)
+	require.Zero(t, result.ExtraA+result.ExtraB)
+}
+
+// Expectation: Existing parts should be protected, and all of them replaced with --force.
+func Test_Program_Create_SplitExisting_Success(t *testing.T) {
+	fs := newTreeFs(t, splitTree...)
+	for i := range 20 {
+		require.NoError(t, afero.WriteFile(fs, splitPartPath("/out.tar.gz", i), []byte("stale"), 0o644))
+	}
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SplitSize: 100})
+	require.ErrorIs(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil), ErrOutputExists)
+
+	prog = NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SplitSize: 100, Force: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	parts, err := prog.archiveParts("/out.tar.gz")
+	require.NoError(t, err)
+	require.Less(t, len(parts), 20)
+
+	require.NoError(t, prog.Verify(t.Context(), "/out.tar.gz"))
+}
+
+// Expectation: Split archives should not be resumed.
+func Test_Program_Create_SplitResume_Error(t *testing.T) {
+	fs := newTreeFs(t, splitTree...)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{SplitSize: 100, Resume: true})
+	require.ErrorContains(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil), "not supported for split archives")
+}
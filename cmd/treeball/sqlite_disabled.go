@@ -0,0 +1,18 @@
+//go:build minimal || no_sqlite
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+const featureSQLite = false
+
+var errSQLiteDisabled = errors.New("sqlite exports are not compiled into this build")
+
+// writeSQLite is a stub for builds without the sqlite feature compiled in.
+// It always returns an error, as no SQLite database can be written in such a build.
+func writeSQLite(_ context.Context, _ string, _ <-chan archiveEntry) (int64, error) {
+	return 0, errSQLiteDisabled
+}
@@ -0,0 +1,82 @@
+//go:build !minimal && !no_sqlite
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite" // Registers the "sqlite" driver (without cgo).
+)
+
+const featureSQLite = true
+
+// sqliteSchema is the schema of the SQLite databases written by [Program.Export].
+const sqliteSchema = `CREATE TABLE entries (
+	path  TEXT NOT NULL,
+	type  TEXT NOT NULL CHECK (type IN ('file', 'dir')),
+	size  INTEGER,
+	mtime INTEGER
+);`
+
+// sqliteIndexes are created after all entries were inserted, as that is faster.
+const sqliteIndexes = `CREATE INDEX entries_path ON entries (path);`
+
+// writeSQLite writes the entries into a new SQLite database at the given path
+// (see [Program.Export] for its schema), returning the amount of entries. The
+// entries are inserted within a single transaction, so that even millions of
+// entries are written quickly. Upon failure, any remaining entries are left unread.
+func writeSQLite(ctx context.Context, path string, entries <-chan archiveEntry) (int64, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		return 0, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO entries (path, type, size, mtime) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	var count int64
+
+	for e := range entries {
+		var mtime *int64
+		if e.ModTime != nil {
+			unix := e.ModTime.Unix()
+			mtime = &unix
+		}
+
+		if _, err := stmt.ExecContext(ctx, e.Path, e.Type, e.Size, mtime); err != nil {
+			return 0, fmt.Errorf("failed to insert entry %q: %w", e.Path, err)
+		}
+
+		count++
+	}
+
+	if _, err := tx.ExecContext(ctx, sqliteIndexes); err != nil {
+		return 0, fmt.Errorf("failed to create indexes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if err := db.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,146 @@
+//go:build !minimal && !no_sqlite
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to copy an exported database onto the local disk and open it.
+func openExportedDB(t *testing.T, fs afero.Fs, path string) *sql.DB {
+	t.Helper()
+
+	data, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+
+	local := filepath.Join(t.TempDir(), "export.db")
+	require.NoError(t, os.WriteFile(local, data, 0o644))
+
+	db, err := sql.Open("sqlite", local)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// Expectation: The entries of an archive should be exported into the SQLite database.
+func Test_Program_Export_SQLite_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt", "b/", "b/c.txt", "d.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+
+	count, err := prog.Export(t.Context(), "/archive.tar.gz", "/inventory.db", ExportSQLite, []string{"d.txt"})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count)
+	require.Equal(t, "entries: 3\n", stdoutBuf.String())
+
+	db := openExportedDB(t, fs, "/inventory.db")
+
+	rows, err := db.QueryContext(t.Context(), "SELECT path, type, size, mtime FROM entries ORDER BY path")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var path, typ string
+		var size, mtime sql.NullInt64
+
+		require.NoError(t, rows.Scan(&path, &typ, &size, &mtime))
+		require.False(t, mtime.Valid)
+		require.Equal(t, typ == "file", size.Valid)
+
+		got = append(got, path+" "+typ)
+	}
+	require.NoError(t, rows.Err())
+
+	require.Equal(t, []string{"a.txt file", "b/ dir", "b/c.txt file"}, got)
+}
+
+// Expectation: Only the entries within the subdirectory should be exported.
+func Test_Program_Export_Subdir_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt", "b/", "b/c.txt", "bc.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Subdir: "b/"})
+
+	count, err := prog.Export(t.Context(), "/archive.tar.gz", "/inventory.db", ExportSQLite, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+}
+
+// Expectation: The sizes and modification times recorded in an archive should be exported.
+func Test_Program_Export_SQLiteMetadata_Success(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "movie.mkv", Typeflag: tar.TypeReg, Size: 5, ModTime: modTime, Mode: 0o644}))
+	_, err := tw.Write([]byte("movie"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", buf.Bytes(), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err = prog.Export(t.Context(), "/archive.tar.gz", "/inventory.db", ExportSQLite, nil)
+	require.NoError(t, err)
+
+	db := openExportedDB(t, fs, "/inventory.db")
+
+	var size, mtime int64
+	require.NoError(t, db.QueryRowContext(t.Context(), "SELECT size, mtime FROM entries WHERE path = 'movie.mkv'").Scan(&size, &mtime))
+
+	require.Equal(t, int64(5), size)
+	require.Equal(t, modTime.Unix(), mtime)
+}
+
+// Expectation: A corrupt archive should fail to be exported, leaving no output file behind.
+func Test_Program_Export_Corrupt_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	data := createTar([]string{"a.txt", "b.txt"})
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", data[:len(data)/2], 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Export(t.Context(), "/archive.tar.gz", "/inventory.db", ExportSQLite, nil)
+	require.Error(t, err)
+
+	_, err = fs.Stat("/inventory.db")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: An existing output file should not be overwritten without --force.
+func Test_Program_Export_OutputExists_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt"}), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/inventory.db", []byte("existing"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+
+	_, err := prog.Export(t.Context(), "/archive.tar.gz", "/inventory.db", ExportSQLite, nil)
+	require.ErrorIs(t, err, ErrOutputExists)
+
+	data, err := afero.ReadFile(fs, "/inventory.db")
+	require.NoError(t, err)
+	require.Equal(t, "existing", string(data))
+}
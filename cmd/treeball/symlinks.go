@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// fileID uniquely identifies a directory on a system, for loop detection.
+type fileID struct {
+	dev  uint64 // Device number (where available)
+	ino  uint64 // Inode number (where available)
+	path string // Resolved path (where device and inode numbers are not available)
+}
+
+// followWalkDir is a variant of [filepath.WalkDir] that follows symbolic links.
+//
+// Any symbolic links pointing to directories are descended into as if they
+// were regular directories, while any loops (symbolic links pointing back to a
+// directory that is currently being walked) are detected and not descended into.
+// Broken symbolic links are passed to fn as they are, with no error attached.
+// The semantics of [filepath.SkipDir] and [filepath.SkipAll] are preserved.
+func followWalkDir(root string, fn fs.WalkDirFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = followWalk(root, fs.FileInfoToDirEntry(info), info, map[fileID]struct{}{}, fn)
+	}
+
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+
+	return err
+}
+
+func followWalk(path string, d fs.DirEntry, info fs.FileInfo, ancestors map[fileID]struct{}, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+			err = nil
+		}
+
+		return err
+	}
+
+	id := identifyFile(path, info)
+	if _, loop := ancestors[id]; loop {
+		return nil
+	}
+	ancestors[id] = struct{}{}
+	defer delete(ancestors, id)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		// Second call, to report the directory reading error (as [filepath.WalkDir] does).
+		if err := fn(path, d, err); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				err = nil
+			}
+
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(path, entry.Name())
+		entryInfo, err := entry.Info()
+
+		if err == nil && entry.Type()&fs.ModeSymlink != 0 {
+			if targetInfo, err := os.Stat(entryPath); err == nil {
+				entryInfo = targetInfo
+				entry = fs.FileInfoToDirEntry(renamedFileInfo{targetInfo, entry.Name()})
+			}
+		}
+
+		if err != nil {
+			if err := fn(entryPath, entry, err); err != nil {
+				if errors.Is(err, filepath.SkipDir) {
+					return nil
+				}
+
+				return err
+			}
+
+			continue
+		}
+
+		if err := followWalk(entryPath, entry, entryInfo, ancestors, fn); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				return nil // Skipping the remaining entries of the directory.
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renamedFileInfo is a [fs.FileInfo] of a symbolic link's target,
+// which is named like the symbolic link itself (and not the target).
+type renamedFileInfo struct {
+	fs.FileInfo
+
+	name string
+}
+
+func (fi renamedFileInfo) Name() string {
+	return fi.name
+}
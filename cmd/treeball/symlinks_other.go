@@ -0,0 +1,17 @@
+//go:build !unix
+
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// identifyFile returns the resolved path of a file as its [fileID].
+func identifyFile(path string, _ fs.FileInfo) fileID {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return fileID{path: resolved}
+	}
+
+	return fileID{path: path}
+}
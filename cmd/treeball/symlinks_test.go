@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to collect the relative paths of a walk.
+func collectWalk(t *testing.T, walker Walker, root string) []string {
+	t.Helper()
+
+	var got []string
+
+	require.NoError(t, walker.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+
+		rel, err := filepath.Rel(root, path)
+		require.NoError(t, err)
+
+		if d.IsDir() {
+			rel += "/"
+		}
+		got = append(got, filepath.ToSlash(rel))
+
+		return nil
+	}))
+
+	return got
+}
+
+// A helper function for tests to create a tree with symbolic links (on the OS filesystem).
+func createSymlinkTree(t *testing.T) (string, string) {
+	t.Helper()
+
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	other := filepath.Join(base, "other")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dir"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(other, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "a.txt"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(other, "sub", "b.txt"), nil, 0o644))
+
+	require.NoError(t, os.Symlink(other, filepath.Join(root, "linked")))
+	require.NoError(t, os.Symlink(root, filepath.Join(root, "dir", "loop")))
+	require.NoError(t, os.Symlink(filepath.Join(base, "missing"), filepath.Join(root, "broken")))
+
+	return root, other
+}
+
+// Expectation: The symbolic links should not be followed by default.
+func Test_OSWalker_NoFollow_Success(t *testing.T) {
+	root, _ := createSymlinkTree(t)
+
+	got := collectWalk(t, OSWalker{}, root)
+	require.Equal(t, []string{"./", "broken", "dir/", "dir/a.txt", "dir/loop", "linked"}, got)
+}
+
+// Expectation: The symbolic links to directories should be followed, without descending into loops.
+func Test_OSWalker_FollowSymlinks_Success(t *testing.T) {
+	root, _ := createSymlinkTree(t)
+
+	got := collectWalk(t, OSWalker{FollowSymlinks: true}, root)
+	require.Equal(t, []string{
+		"./",
+		"broken",
+		"dir/",
+		"dir/a.txt",
+		"dir/loop/",
+		"linked/",
+		"linked/sub/",
+		"linked/sub/b.txt",
+	}, got)
+}
+
+// Expectation: A symbolic link given as the root should be followed.
+func Test_OSWalker_FollowSymlinks_Root_Success(t *testing.T) {
+	_, other := createSymlinkTree(t)
+
+	link := filepath.Join(t.TempDir(), "link")
+	require.NoError(t, os.Symlink(other, link))
+
+	got := collectWalk(t, OSWalker{FollowSymlinks: true}, link)
+	require.Equal(t, []string{"./", "sub/", "sub/b.txt"}, got)
+}
+
+// Expectation: The semantics of skipping directories should be preserved.
+func Test_OSWalker_FollowSymlinks_SkipDir_Success(t *testing.T) {
+	root, _ := createSymlinkTree(t)
+
+	var got []string
+
+	require.NoError(t, OSWalker{FollowSymlinks: true}.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+
+		got = append(got, filepath.Base(path))
+		if d.IsDir() && d.Name() == "linked" {
+			return filepath.SkipDir
+		}
+
+		return nil
+	}))
+
+	require.Equal(t, []string{"root", "broken", "dir", "a.txt", "loop", "linked"}, got)
+}
+
+// Expectation: A missing root should be reported to the walk function.
+func Test_OSWalker_FollowSymlinks_MissingRoot_Error(t *testing.T) {
+	err := OSWalker{FollowSymlinks: true}.WalkDir(filepath.Join(t.TempDir(), "missing"), func(_ string, _ fs.DirEntry, err error) error {
+		return err
+	})
+
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Expectation: A tarball should be created including the contents of symbolically linked directories.
+func Test_Program_Create_FollowSymlinks_Success(t *testing.T) {
+	root, _ := createSymlinkTree(t)
+	out := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(afero.NewOsFs(), &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{FollowSymlinks: true})
+	require.NoError(t, prog.Create(t.Context(), root, out, nil))
+
+	paths := strings.Split(strings.TrimSpace(stdoutBuf.String()), "\n")
+	require.Equal(t, []string{"broken", "dir", "dir/a.txt", "dir/loop", "linked", "linked/sub", "linked/sub/b.txt"}, paths)
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// identifyFile returns the device and inode numbers of a file as its [fileID].
+func identifyFile(path string, info fs.FileInfo) fileID {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fileID{dev: uint64(st.Dev), ino: st.Ino} //nolint:unconvert,nolintlint
+	}
+
+	return fileID{path: path}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var errInvalidTransform = errors.New("invalid transform")
+
+// NameTransform is a sed-style substitution of the names of created entries
+// (as for --transform), such as s/^staging\///, which rewrites the (first, or
+// with the g flag every) match of Regexp in a name with the Replacement.
+type NameTransform struct {
+	Regexp      *regexp.Regexp // Expression to match in the names (without trailing slashes)
+	Replacement string         // Replacement of the matches (in the syntax of [regexp.Regexp.Expand])
+	Global      bool           // Replace every match (instead of only the first)
+}
+
+// parseNameTransform returns the [NameTransform] of an argument in the sed
+// format s/REGEX/REPLACEMENT/FLAGS, with any character following the s as
+// the delimiter (e.g. s,a/b,c,), and with the flags g (replace every match)
+// and i (match case-insensitively). In the replacement, & stands for the
+// entire match and \1 to \9 for the submatches (\& for a literal &).
+func parseNameTransform(arg string) (*NameTransform, error) {
+	if !strings.HasPrefix(arg, "s") || len(arg) < 2 { //nolint:mnd
+		return nil, fmt.Errorf("%w: %q (expected s/REGEX/REPLACEMENT/FLAGS)", errInvalidTransform, arg)
+	}
+
+	delim, size := utf8.DecodeRuneInString(arg[1:])
+	if delim == '\\' || delim == '\n' {
+		return nil, fmt.Errorf("%w: %q (invalid delimiter %q)", errInvalidTransform, arg, delim)
+	}
+
+	parts := splitTransform(arg[1+size:], delim)
+	if len(parts) != 3 { //nolint:mnd
+		return nil, fmt.Errorf("%w: %q (expected s/REGEX/REPLACEMENT/FLAGS)", errInvalidTransform, arg)
+	}
+
+	t := &NameTransform{Replacement: sedReplacement(parts[1])}
+	expr := parts[0]
+
+	for _, flag := range parts[2] {
+		switch flag {
+		case 'g':
+			t.Global = true
+		case 'i':
+			expr = "(?i)" + expr
+		default:
+			return nil, fmt.Errorf("%w: %q (unknown flag %q)", errInvalidTransform, arg, flag)
+		}
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q (%w)", errInvalidTransform, arg, err)
+	}
+	t.Regexp = re
+
+	return t, nil
+}
+
+// parseNameTransforms returns the [NameTransform] of every argument (see [parseNameTransform]).
+func parseNameTransforms(args []string) ([]*NameTransform, error) {
+	transforms := make([]*NameTransform, 0, len(args))
+
+	for _, arg := range args {
+		t, err := parseNameTransform(arg)
+		if err != nil {
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+
+	return transforms, nil
+}
+
+// splitTransform splits the remainder of a transform (after its s and the
+// delimiter) at the unescaped delimiters, with any escaped delimiters
+// unescaped (but all other escapes kept, as for the expression itself).
+func splitTransform(s string, delim rune) []string {
+	var parts []string
+	var part strings.Builder
+
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			if r != delim {
+				part.WriteRune('\\')
+			}
+			part.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == delim:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteRune(r)
+		}
+	}
+
+	if escaped {
+		part.WriteRune('\\')
+	}
+
+	return append(parts, part.String())
+}
+
+// sedReplacement returns a replacement in the sed syntax (with & and \1 to \9)
+// in the syntax of [regexp.Regexp.Expand] (with ${0} and ${1} to ${9}).
+func sedReplacement(s string) string {
+	var b strings.Builder
+
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped && r >= '0' && r <= '9':
+			b.WriteString("${" + string(r) + "}")
+			escaped = false
+		case escaped:
+			if r == '$' {
+				b.WriteRune('$')
+			}
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '&':
+			b.WriteString("${0}")
+		case r == '$':
+			b.WriteString("$$")
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	if escaped {
+		b.WriteRune('\\')
+	}
+
+	return b.String()
+}
+
+// apply returns a name with the first (or every) match of the transform replaced.
+func (t *NameTransform) apply(name string) string {
+	if t.Global {
+		return t.Regexp.ReplaceAllString(name, t.Replacement)
+	}
+
+	loc := t.Regexp.FindStringSubmatchIndex(name)
+	if loc == nil {
+		return name
+	}
+
+	return name[:loc[0]] + string(t.Regexp.ExpandString(nil, t.Replacement, name, loc)) + name[loc[1]:]
+}
+
+// transformName returns the name of an entry (slash-separated, with directories
+// carrying a trailing slash) as rewritten by all of [ProgramConfig.Transforms]
+// in order, which are applied to the name without its trailing slash, or false
+// for a name that is transformed away entirely (so not to be written at all).
+func (prog *Program) transformName(name string) (string, bool) {
+	if len(prog.config.Transforms) == 0 {
+		return name, true
+	}
+
+	isDir := strings.HasSuffix(name, "/")
+	name = strings.TrimSuffix(name, "/")
+
+	for _, t := range prog.config.Transforms {
+		name = t.apply(name)
+	}
+
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return "", false
+	}
+
+	if isDir {
+		name += "/"
+	}
+
+	return name, true
+}
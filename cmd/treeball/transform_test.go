@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The expressions should be parsed with any delimiter and flags, or rejected.
+func Test_parseNameTransform_Table(t *testing.T) {
+	tests := []struct {
+		name   string
+		arg    string
+		input  string
+		want   string
+		global bool
+		ok     bool
+	}{
+		{"Prefix", "s,^,disk1/,", "a/b.txt", "disk1/a/b.txt", false, true},
+		{"Drop prefix", "s/^staging\\///", "staging/a.txt", "a.txt", false, true},
+		{"First only", "s/a/x/", "aaa", "xaa", false, true},
+		{"Global", "s/a/x/g", "aaa", "xxx", true, true},
+		{"Ignore case", "s/abc/x/i", "ABC.txt", "x.txt", false, true},
+		{"Match", "s/[0-9]+/<&>/", "file42.txt", "file<42>.txt", false, true},
+		{"Groups", "s/(.*)\\.(.*)/\\2.\\1/", "a.txt", "txt.a", false, true},
+		{"Literal", "s/a/\\&$1/", "a", "&$1", false, true},
+		{"No match", "s/^x//", "a.txt", "a.txt", false, true},
+		{"Not substitution", "y/a/b/", "", "", false, false},
+		{"Missing part", "s/a/b", "", "", false, false},
+		{"Unknown flag", "s/a/b/q", "", "", false, false},
+		{"Invalid regex", "s/(/b/", "", "", false, false},
+		{"Empty", "s", "", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNameTransform(tt.arg)
+			if !tt.ok {
+				require.ErrorIs(t, err, errInvalidTransform)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.global, got.Global)
+			require.Equal(t, tt.want, got.apply(tt.input))
+		})
+	}
+}
+
+// Expectation: The transforms should be applied in order, keeping the trailing slashes of directories.
+func Test_Program_transformName_Table(t *testing.T) {
+	transforms, err := parseNameTransforms([]string{"s,^staging/,,", "s,^,disk1/,"})
+	require.NoError(t, err)
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{Transforms: transforms})
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		ok    bool
+	}{
+		{"File", "staging/a.txt", "disk1/a.txt", true},
+		{"Directory", "staging/b/", "disk1/b/", true},
+		{"Unmatched", "other/c.txt", "disk1/other/c.txt", true},
+		{"Prefix itself", "staging/", "disk1/staging/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := prog.transformName(tt.input)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	transforms, err = parseNameTransforms([]string{"s,^tmp$,,"})
+	require.NoError(t, err)
+
+	prog = NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{Transforms: transforms})
+
+	_, ok := prog.transformName("tmp/")
+	require.False(t, ok)
+}
+
+// Expectation: The transformed names should be both written to the tarball and printed.
+func Test_Program_Create_Transform_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/tmp/b.txt", []byte("b"), 0o644))
+
+	transforms, err := parseNameTransforms([]string{"s,^tmp/,,", "s,^,disk1/,"})
+	require.NoError(t, err)
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Transforms: transforms})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", nil))
+
+	require.Equal(t, []string{"disk1/a.txt", "disk1/tmp/", "disk1/b.txt"}, readTarNames(t, fs, "/out.tar.gz"))
+	require.Equal(t, "disk1/a.txt\ndisk1/tmp\ndisk1/b.txt\n", stdoutBuf.String())
+}
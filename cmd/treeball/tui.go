@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/lanrat/extsort/diff"
+)
+
+const (
+	tuiMinWidth  = 20 // Minimum width of the viewer (in columns)
+	tuiMinHeight = 3  // Minimum height of the viewer (in rows)
+
+	tuiReverse    = "\x1b[7m"
+	tuiClear      = "\x1b[H\x1b[2J"
+	tuiAltScreen  = "\x1b[?1049h\x1b[?25l" // Alternate screen buffer, hidden cursor
+	tuiMainScreen = "\x1b[?25h\x1b[?1049l" // Main screen buffer, visible cursor
+)
+
+var errNoTerminal = errors.New("not a terminal")
+
+// tuiKey is a key pressed within the viewer of [Program.DiffTUI].
+type tuiKey int
+
+const (
+	tuiKeyNone tuiKey = iota
+	tuiKeyUp
+	tuiKeyDown
+	tuiKeyLeft
+	tuiKeyRight
+	tuiKeyPageUp
+	tuiKeyPageDown
+	tuiKeyTop
+	tuiKeyBottom
+	tuiKeyToggle
+	tuiKeyQuit
+)
+
+// diffNode is an entry of the tree of a [diffView], either a difference or a
+// directory containing any (which then exists on both sides, as context).
+type diffNode struct {
+	name     string
+	isDir    bool
+	delta    diff.Delta // Delta of the entry (if differing at all)
+	differs  bool       // Entry is a difference itself (not just context)
+	parent   *diffNode
+	children []*diffNode
+	index    map[string]*diffNode // Children by their name (for building)
+
+	collapsed bool
+}
+
+// diffView is the (side-by-side) tree of differences of [Program.DiffTUI],
+// along with the state of its viewer (cursor, scrolling and collapsing).
+type diffView struct {
+	root     *diffNode
+	old, new string // Labels of the compared sources
+	removed  int
+	added    int
+	drifted  int
+
+	rows   []*diffNode // Visible rows (as of the collapsing)
+	cursor int
+	offset int
+}
+
+// newDiffView returns an empty [diffView] of the labeled sources.
+func newDiffView(old string, new string) *diffView {
+	return &diffView{
+		root: &diffNode{isDir: true, index: make(map[string]*diffNode)},
+		old:  old,
+		new:  new,
+	}
+}
+
+// add places a difference into the tree of the view, along with any of its
+// parent directories (as context, unless they are reported on their own).
+func (v *diffView) add(delta diff.Delta, item string) {
+	switch delta {
+	case diff.OLD:
+		v.removed++
+	case diff.NEW:
+		v.added++
+	case diffDrift:
+		v.drifted++
+	}
+
+	// The names are kept, so must not keep the (shared) blocks of a pathArena alive.
+	item = strings.Clone(item)
+
+	isDir := strings.HasSuffix(item, "/")
+	parts := strings.Split(strings.TrimSuffix(item, "/"), "/")
+
+	node := v.root
+	for i, name := range parts {
+		child, ok := node.index[name]
+		if !ok {
+			child = &diffNode{name: name, isDir: true, parent: node, index: make(map[string]*diffNode)}
+			node.index[name] = child
+			node.children = append(node.children, child)
+		}
+		if i == len(parts)-1 {
+			child.isDir = isDir || len(child.children) > 0
+			child.delta, child.differs = delta, true
+		}
+		node = child
+	}
+
+	v.rows = nil
+}
+
+// visibleRows returns the rows of the view, as of the collapsed directories.
+func (v *diffView) visibleRows() []*diffNode {
+	if v.rows != nil {
+		return v.rows
+	}
+
+	var walk func(n *diffNode)
+	walk = func(n *diffNode) {
+		for _, c := range n.children {
+			v.rows = append(v.rows, c)
+			if !c.collapsed {
+				walk(c)
+			}
+		}
+	}
+	v.rows = make([]*diffNode, 0, len(v.root.children))
+	walk(v.root)
+
+	return v.rows
+}
+
+// depth returns the depth of a node within the tree (zero: top level).
+func (n *diffNode) depth() int {
+	d := 0
+	for p := n.parent; p != nil && p.parent != nil; p = p.parent {
+		d++
+	}
+
+	return d
+}
+
+// handle applies a key to the state of the viewer, for a given page height.
+// It returns false for keys ending the viewer.
+func (v *diffView) handle(key tuiKey, height int) bool {
+	rows := v.visibleRows()
+
+	switch key {
+	case tuiKeyQuit:
+		return false
+	case tuiKeyUp:
+		v.cursor--
+	case tuiKeyDown:
+		v.cursor++
+	case tuiKeyPageUp:
+		v.cursor -= height
+	case tuiKeyPageDown:
+		v.cursor += height
+	case tuiKeyTop:
+		v.cursor = 0
+	case tuiKeyBottom:
+		v.cursor = len(rows) - 1
+	case tuiKeyLeft:
+		if v.cursor < len(rows) {
+			n := rows[v.cursor]
+			if n.isDir && len(n.children) > 0 && !n.collapsed {
+				v.setCollapsed(n, true)
+			} else if n.parent != v.root {
+				v.setCollapsed(n.parent, true)
+				v.cursor = v.rowOf(n.parent)
+			}
+		}
+	case tuiKeyRight, tuiKeyToggle:
+		if v.cursor < len(rows) {
+			n := rows[v.cursor]
+			if n.isDir && len(n.children) > 0 {
+				v.setCollapsed(n, key == tuiKeyToggle && !n.collapsed)
+			}
+		}
+	case tuiKeyNone:
+	}
+
+	v.cursor = max(0, min(v.cursor, len(v.visibleRows())-1))
+
+	if v.cursor < v.offset {
+		v.offset = v.cursor
+	} else if v.cursor >= v.offset+height {
+		v.offset = v.cursor - height + 1
+	}
+
+	return true
+}
+
+// setCollapsed collapses (or expands) a directory of the view.
+func (v *diffView) setCollapsed(n *diffNode, collapsed bool) {
+	n.collapsed = collapsed
+	v.rows = nil
+}
+
+// rowOf returns the visible row of a node (or zero if none).
+func (v *diffView) rowOf(n *diffNode) int {
+	for i, r := range v.visibleRows() {
+		if r == n {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// render writes a screen of the view of the given size to w, with a header of
+// the sources, the visible rows (old on the left, new on the right) and a footer
+// of the amounts of differences and the keys.
+func (v *diffView) render(w io.Writer, width int, height int) error {
+	width, height = max(width, tuiMinWidth), max(height, tuiMinHeight)
+	pane := (width - 3) / 2 //nolint:mnd
+
+	var b strings.Builder
+
+	b.WriteString(tuiClear)
+	b.WriteString(tuiReverse + fitCell("old: "+v.old, pane) + " │ " + fitCell("new: "+v.new, pane) + colorReset + "\r\n")
+
+	rows := v.visibleRows()
+	page := height - 2 //nolint:mnd
+
+	for i := v.offset; i < v.offset+page; i++ {
+		if i >= len(rows) {
+			b.WriteString("\r\n")
+
+			continue
+		}
+
+		left, right := v.cells(rows[i], pane)
+		if i == v.cursor {
+			left, right = tuiReverse+left+colorReset, tuiReverse+right+colorReset
+		}
+		b.WriteString(left + " │ " + right + "\r\n")
+	}
+
+	b.WriteString(fitCell(fmt.Sprintf("removed: %d, added: %d, drifted: %d | ↑↓ move, ←→ collapse/expand, q quit", v.removed, v.added, v.drifted), width))
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to render: %w", err)
+	}
+
+	return nil
+}
+
+// cells returns the left (old) and right (new) cells of a row, each of the
+// given width and highlighted as per the delta of its node.
+func (v *diffView) cells(n *diffNode, width int) (string, string) {
+	marker := "  "
+	if n.isDir && len(n.children) > 0 {
+		marker = "▾ "
+		if n.collapsed {
+			marker = "▸ "
+		}
+	}
+
+	name := n.name
+	if n.isDir {
+		name += "/"
+	}
+	text := fitCell(strings.Repeat("  ", n.depth())+marker+name, width)
+	blank := strings.Repeat(" ", width)
+
+	if !n.differs {
+		return colorDim + text + colorReset, colorDim + text + colorReset
+	}
+
+	switch n.delta {
+	case diff.OLD:
+		return colorRed + text + colorReset, blank
+	case diff.NEW:
+		return blank, colorGreen + text + colorReset
+	default:
+		return colorYellow + text + colorReset, colorYellow + text + colorReset
+	}
+}
+
+// fitCell returns the text truncated or padded to the given width (in runes).
+func fitCell(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		return string(runes[:max(0, width-1)]) + "…"
+	}
+
+	return text + strings.Repeat(" ", width-len(runes))
+}
+
+// readKey reads the next key (or escape sequence) from the terminal input.
+// Any unknown keys are returned as [tuiKeyNone].
+func readKey(r *bufio.Reader) (tuiKey, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return tuiKeyNone, err //nolint:wrapcheck
+	}
+
+	switch c {
+	case 'q', 'Q', 0x03: // Ctrl+C (as no signal is raised in raw mode)
+		return tuiKeyQuit, nil
+	case 'k':
+		return tuiKeyUp, nil
+	case 'j':
+		return tuiKeyDown, nil
+	case 'h':
+		return tuiKeyLeft, nil
+	case 'l':
+		return tuiKeyRight, nil
+	case 'g':
+		return tuiKeyTop, nil
+	case 'G':
+		return tuiKeyBottom, nil
+	case ' ', '\r', '\n':
+		return tuiKeyToggle, nil
+	case 0x1b:
+		return readEscape(r)
+	}
+
+	return tuiKeyNone, nil
+}
+
+// readEscape reads the remainder of an escape sequence (as of arrow keys).
+func readEscape(r *bufio.Reader) (tuiKey, error) {
+	if r.Buffered() == 0 {
+		return tuiKeyQuit, nil // A lone escape key
+	}
+
+	if c, err := r.ReadByte(); err != nil || c != '[' {
+		return tuiKeyNone, err //nolint:wrapcheck
+	}
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return tuiKeyNone, err //nolint:wrapcheck
+	}
+
+	switch c {
+	case 'A':
+		return tuiKeyUp, nil
+	case 'B':
+		return tuiKeyDown, nil
+	case 'C':
+		return tuiKeyRight, nil
+	case 'D':
+		return tuiKeyLeft, nil
+	case 'H':
+		return tuiKeyTop, nil
+	case 'F':
+		return tuiKeyBottom, nil
+	case '5', '6':
+		if t, err := r.ReadByte(); err != nil || t != '~' {
+			return tuiKeyNone, err //nolint:wrapcheck
+		}
+		if c == '5' {
+			return tuiKeyPageUp, nil
+		}
+
+		return tuiKeyPageDown, nil
+	}
+
+	return tuiKeyNone, nil
+}
+
+// run renders the view to w and handles the keys read from r, until either
+// quitting or the end of the input. The size function returns the current
+// size of the terminal (so that it is followed upon any resizing).
+func (v *diffView) run(ctx context.Context, r io.Reader, w io.Writer, size func() (int, int)) error {
+	br := bufio.NewReader(r)
+
+	for {
+		width, height := size()
+		if err := v.render(w, width, height); err != nil {
+			return err
+		}
+
+		key, err := readKey(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read key: %w", err)
+		}
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to view: %w", context.Cause(ctx))
+		}
+
+		if !v.handle(key, max(height, tuiMinHeight)-2) { //nolint:mnd
+			return nil
+		}
+	}
+}
+
+// DiffTUI compares two sources like [Program.DiffSources], but instead of
+// printing the differences, shows them in an interactive (side-by-side)
+// viewer in the terminal, with the removed paths on the left (old) side, the
+// added paths on the right (new) side and collapsible directories. The viewer
+// is only shown with any differences (and stdout being a terminal at all).
+// The returns are those of [Program.Diff], once the viewer was quit.
+func (prog *Program) DiffTUI(ctx context.Context, cmpOld []string, cmpNew []string, excludes []string) (*diff.Result, error) {
+	if !isTerminal(prog.stdout) {
+		return nil, fmt.Errorf("failed to start viewer: %w (standard output)", errNoTerminal)
+	}
+
+	progressFrom(ctx).setPhase("comparing %s with %s", strings.Join(cmpOld, ", "), strings.Join(cmpNew, ", "))
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, slices.Concat(cmpOld, cmpNew)...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRemotes()
+
+	view := newDiffView(strings.Join(cmpOld, ", "), strings.Join(cmpNew, ", "))
+
+	result, err := prog.diffSources(ctx, cmpOld, cmpNew, excludes, func(delta diff.Delta, item string) error {
+		view.add(delta, item)
+
+		return nil
+	})
+	if result == nil || (result.ExtraA == 0 && result.ExtraB == 0) {
+		return result, err
+	}
+
+	if verr := runViewer(ctx, prog.stdout, view); verr != nil {
+		return nil, verr
+	}
+
+	return result, err
+}
@@ -0,0 +1,19 @@
+//go:build minimal || no_tui
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+const featureTUI = false
+
+var errTUIDisabled = errors.New("interactive viewer is not compiled into this build")
+
+// runViewer is a stub for builds without the tui feature compiled in.
+// It always returns an error, as no viewer can be shown in such a build.
+func runViewer(_ context.Context, _ io.Writer, _ *diffView) error {
+	return errTUIDisabled
+}
@@ -0,0 +1,45 @@
+//go:build !minimal && !no_tui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+const featureTUI = true
+
+// runViewer shows a [diffView] on the terminal of stdout until it is quit,
+// reading the keys from the standard input (put into raw mode meanwhile).
+// The viewer is drawn on the alternate screen, so that the scrollback of the
+// terminal is restored to what it was before as soon as the viewer is quit.
+func runViewer(ctx context.Context, stdout io.Writer, view *diffView) error {
+	out, ok := stdout.(*os.File)
+	if !ok || !term.IsTerminal(int(os.Stdin.Fd())) { //nolint:gosec
+		return fmt.Errorf("failed to start viewer: %w (standard input)", errNoTerminal)
+	}
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd())) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("failed to start viewer: %w", err)
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state) //nolint:errcheck,gosec
+
+	fmt.Fprint(out, tuiAltScreen)
+	defer fmt.Fprint(out, tuiMainScreen)
+
+	size := func() (int, int) {
+		width, height, err := term.GetSize(int(out.Fd())) //nolint:gosec
+		if err != nil {
+			return tuiMinWidth, tuiMinHeight
+		}
+
+		return width, height
+	}
+
+	return view.run(ctx, os.Stdin, out, size)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lanrat/extsort/diff"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// testDiffView returns a [diffView] of a few nested differences.
+func testDiffView() *diffView {
+	v := newDiffView("old.tar.gz", "/mnt/new")
+
+	v.add(diff.OLD, "a/")
+	v.add(diff.OLD, "a/x.txt")
+	v.add(diff.NEW, "b/c/y.txt")
+	v.add(diffDrift, "z.txt")
+
+	return v
+}
+
+// rowNames returns the names of the visible rows of a view.
+func rowNames(v *diffView) []string {
+	var names []string
+	for _, n := range v.visibleRows() {
+		names = append(names, n.name)
+	}
+
+	return names
+}
+
+// Expectation: The differences should be placed into a tree, along with their parent directories as context.
+func Test_diffView_add_Success(t *testing.T) {
+	v := testDiffView()
+
+	require.Equal(t, []string{"a", "x.txt", "b", "c", "y.txt", "z.txt"}, rowNames(v))
+	require.Equal(t, 2, v.removed)
+	require.Equal(t, 1, v.added)
+	require.Equal(t, 1, v.drifted)
+
+	rows := v.visibleRows()
+	require.True(t, rows[0].differs)
+	require.True(t, rows[0].isDir)
+	require.False(t, rows[2].differs)
+	require.Equal(t, 2, rows[4].depth())
+}
+
+// Expectation: The keys should move the cursor and collapse or expand the directories.
+func Test_diffView_handle_Success(t *testing.T) {
+	v := testDiffView()
+
+	require.True(t, v.handle(tuiKeyToggle, 10))
+	require.Equal(t, []string{"a", "b", "c", "y.txt", "z.txt"}, rowNames(v))
+
+	require.True(t, v.handle(tuiKeyRight, 10))
+	require.Equal(t, []string{"a", "x.txt", "b", "c", "y.txt", "z.txt"}, rowNames(v))
+
+	require.True(t, v.handle(tuiKeyBottom, 10))
+	require.Equal(t, 5, v.cursor)
+
+	require.True(t, v.handle(tuiKeyDown, 10))
+	require.Equal(t, 5, v.cursor)
+
+	require.True(t, v.handle(tuiKeyUp, 10))
+	require.True(t, v.handle(tuiKeyLeft, 10))
+	require.Equal(t, []string{"a", "x.txt", "b", "c", "z.txt"}, rowNames(v))
+	require.Equal(t, 3, v.cursor)
+
+	require.True(t, v.handle(tuiKeyTop, 10))
+	require.Equal(t, 0, v.cursor)
+
+	require.False(t, v.handle(tuiKeyQuit, 10))
+}
+
+// Expectation: The view should scroll to keep the cursor within the page.
+func Test_diffView_handle_Scrolling_Success(t *testing.T) {
+	v := testDiffView()
+
+	require.True(t, v.handle(tuiKeyPageDown, 2))
+	require.Equal(t, 2, v.cursor)
+	require.Equal(t, 1, v.offset)
+
+	require.True(t, v.handle(tuiKeyPageUp, 2))
+	require.Equal(t, 0, v.cursor)
+	require.Equal(t, 0, v.offset)
+}
+
+// Expectation: The removed paths should be rendered on the left, the added paths on the right.
+func Test_diffView_render_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	v := testDiffView()
+	require.NoError(t, v.render(&buf, 43, 10))
+
+	lines := strings.Split(buf.String(), "\r\n")
+	require.Len(t, lines, 10)
+	require.Contains(t, lines[0], "old: old.tar.gz")
+	require.Contains(t, lines[0], "new: /mnt/new")
+
+	left, right, ok := strings.Cut(lines[2], " │ ")
+	require.True(t, ok)
+	require.Contains(t, left, colorRed+"    x.txt")
+	require.Equal(t, strings.Repeat(" ", 20), right)
+
+	left, right, ok = strings.Cut(lines[5], " │ ")
+	require.True(t, ok)
+	require.Equal(t, strings.Repeat(" ", 20), left)
+	require.Contains(t, right, colorGreen+"      y.txt")
+
+	require.Contains(t, lines[9], "removed: 2, added: 1, drifted: 1")
+}
+
+// Expectation: Rendering to a failing writer should return an error.
+func Test_diffView_render_Write_Error(t *testing.T) {
+	require.Error(t, testDiffView().render(errorWriter{}, 80, 24))
+}
+
+// Expectation: The cells should be truncated or padded to their width.
+func Test_fitCell_Table(t *testing.T) {
+	require.Equal(t, "abc  ", fitCell("abc", 5))
+	require.Equal(t, "abcd…", fitCell("abcdefg", 5))
+	require.Equal(t, "äöü", fitCell("äöü", 3))
+}
+
+// Expectation: The keys and escape sequences should be read as their respective keys.
+func Test_readKey_Table(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected tuiKey
+	}{
+		{"q", tuiKeyQuit},
+		{"\x03", tuiKeyQuit},
+		{"\x1b", tuiKeyQuit},
+		{"j", tuiKeyDown},
+		{"k", tuiKeyUp},
+		{"h", tuiKeyLeft},
+		{"l", tuiKeyRight},
+		{"g", tuiKeyTop},
+		{"G", tuiKeyBottom},
+		{"\r", tuiKeyToggle},
+		{"\x1b[A", tuiKeyUp},
+		{"\x1b[B", tuiKeyDown},
+		{"\x1b[C", tuiKeyRight},
+		{"\x1b[D", tuiKeyLeft},
+		{"\x1b[5~", tuiKeyPageUp},
+		{"\x1b[6~", tuiKeyPageDown},
+		{"x", tuiKeyNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			key, err := readKey(bufio.NewReader(strings.NewReader(tt.input)))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, key)
+		})
+	}
+}
+
+// Expectation: The viewer should handle the keys until quitting.
+func Test_diffView_run_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	v := testDiffView()
+	size := func() (int, int) { return 80, 24 }
+
+	require.NoError(t, v.run(t.Context(), strings.NewReader("jj q"), &buf, size))
+	require.Equal(t, 2, v.cursor)
+	require.Equal(t, 4, strings.Count(buf.String(), tuiClear))
+}
+
+// Expectation: The viewer should end with the end of its input.
+func Test_diffView_run_EOF_Success(t *testing.T) {
+	v := testDiffView()
+	size := func() (int, int) { return 80, 24 }
+
+	require.NoError(t, v.run(t.Context(), strings.NewReader("j"), io.Discard, size))
+	require.Equal(t, 1, v.cursor)
+}
+
+// Expectation: The viewer should not be started when stdout is not a terminal.
+func Test_Program_DiffTUI_NoTerminal_Error(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), &bytes.Buffer{}, io.Discard, nil, nil, nil)
+
+	_, err := prog.DiffTUI(t.Context(), []string{"/old"}, []string{"/new"}, nil)
+	require.ErrorIs(t, err, errNoTerminal)
+}
@@ -3,17 +3,27 @@ package main
 import (
 	"archive/tar"
 	"bufio"
+	"bytes"
+	"cmp"
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
 
 	"github.com/bmatcuk/doublestar/v4"
+	pgzip "github.com/klauspost/pgzip"
 	"github.com/lanrat/extsort"
 	"github.com/spf13/afero"
 )
@@ -22,7 +32,373 @@ import (
 type GzipConfig struct {
 	BlockSize        int // Approximate size of blocks (pgzip operations)
 	BlockCount       int // Amount of blocks processing in parallel (pgzip operations)
-	CompressionLevel int // Target level for compression (0: none to 9: highest)
+	CompressionLevel int // Target level for compression (0: none, as plain tar to 9: highest)
+}
+
+// ProgramConfig is the configuration for the general behavior of a [Program].
+type ProgramConfig struct {
+	GitIgnore       bool             // Apply any .gitignore files encountered during filesystem walks
+	ExcludeRegexes  []*regexp.Regexp // Regular expressions of paths to exclude (in addition to patterns)
+	ExcludesOld     []string         // Patterns of paths to exclude from only the old sources of diffs
+	ExcludesNew     []string         // Patterns of paths to exclude from only the new sources of diffs
+	StripComponents int              // Leading path components to strip from the paths of the sources of diffs (0: none)
+	MapsOld         []PathMapping    // Rewrites of the leading path components of the old sources of diffs (after stripping)
+	MapsNew         []PathMapping    // Rewrites of the leading path components of the new sources of diffs (after stripping)
+	Includes        []string         // Patterns of which any must match for files to be included (empty: all)
+	PatternSyntax   PatternSyntax    // Dialect of the exclude and include patterns (zero: doublestar)
+	IgnoreCase      bool             // Match the paths case-insensitively against any exclusion mechanisms
+	MaxDepth        int              // Maximum depth of paths to consider (0: unlimited)
+	FollowSymlinks  bool             // Descend into symbolic links to directories during filesystem walks
+	WalkWorkers     int              // Directories read concurrently during filesystem walks (0: serially)
+	DescendArchives bool             // Walk any nested archives as directories of their entries (see Program.descendArchive)
+	SortedWalk      bool             // Walk directories in the sorted order of their paths, without sorting them (see walksSorted)
+	SkipErrors      bool             // Skip (with warning) unreadable entries during filesystem walks
+	Force           bool             // Overwrite any existing output files (instead of refusing to)
+	Backup          bool             // Rename any existing output files aside (to *.bak) before writing
+	OnExists        RestorePolicy    // Handling of the existing paths of restored entries (zero: fail, see Program.Restore)
+	Fsync           bool             // Sync the output files (and their directories) to their storage upon closing (see syncedFile)
+	VerifyWrites    bool             // Re-read and decode the output archives once written (see Program.verifyOutput)
+	CheckpointEvery int              // Entries between checkpoints of resumable creations (0: none)
+	MemberEvery     int              // Entries between gzip members of created tarballs (0: only at checkpoints)
+	Rsyncable       bool             // End gzip members of created tarballs at content-defined entries (see isRsyncableBoundary)
+	TarFormat       tar.Format       // Format of the written tar headers (unknown: chosen per header)
+	Resume          bool             // Resume an interrupted creation from its last checkpoint
+	FilesOnly       bool             // Compare only the files of sources (ignoring directory entries)
+	OnlySide        DiffSide         // Side of the differences to consider (zero: both sides)
+	AddedPrefix     string           // Synthetic directory of the added paths in diff tarballs (empty: "+++")
+	RemovedPrefix   string           // Synthetic directory of the removed paths in diff tarballs (empty: "---")
+	MaxDiffs        int              // Differences after which to stop comparing early (0: unlimited)
+	Print0          bool             // Terminate printed paths with NUL bytes (instead of newlines)
+	Color           ColorMode        // Colorization of the printed output (only ColorAlways colorizes, see ColorMode.resolve)
+	Quiet           bool             // Print only errors to standard error (see Program.infof and Program.warnf)
+	Quoting         QuotingStyle     // Quoting of the printed paths of line-oriented output (zero: literal)
+	Matches         []string         // Patterns of which any must match for paths to be listed (empty: all)
+	OnlyType        EntryType        // Type of the entries to be listed (zero: any type)
+	Subdir          string           // Subdirectory of archives to list or export (with a trailing slash, empty: all)
+	SortBy          SortOrder        // Order of sorted listings (zero: lexicographic by name)
+	NoIndex         bool             // Never read the sidecar index files of archives (see Program.Index)
+	ListFormat      ListFormat       // Format of listings (zero: text)
+	SignKey         string           // Private key to sign created archives with (empty: none, see Program.VerifySignature)
+	Checksum        ChecksumAlgo     // Algorithm of the checksum files of created archives (zero: none)
+	SplitSize       int64            // Maximum size of the parts of created archives (0: not split)
+	Strict          bool             // Reject the non-canonical entries of tarballs (instead of normalizing them)
+	SpecialFiles    bool             // Write special files (FIFOs, devices) with their types (instead of as regular files)
+	Xattrs          bool             // Capture the extended attributes of entries (as PAX records, compared by diffs)
+	ACLs            bool             // Capture the POSIX ACLs of entries (as PAX records, compared by diffs)
+	Annotate        bool             // Annotate created archives (in a PAX global header, see Program.Show)
+	Comment         string           // Comment to annotate created archives with (implies Annotate)
+	Transforms      []*NameTransform // Rewrites of the names of created entries, applied in order (see Program.transformName)
+	ModTime         time.Time        // Modification time of created entries, their gzip headers and annotations (zero: none)
+	GzipHeader      GzipHeader       // Metadata of the gzip headers of created tarballs (see parseGzipHeader)
+	FileMode        int64            // Permission bits of created (non-directory) entries (0: 0666)
+	DirMode         int64            // Permission bits of created directory entries (0: 0777)
+	EntryOwner      *EntryOwner      // User to record as the owner of created entries (nil: anonymous)
+	EntryGroup      *EntryOwner      // Group to record as the owner of created entries (nil: anonymous)
+	NewerThan       time.Time        // Walk only the files modified after this time (zero: any)
+	OlderThan       time.Time        // Walk only the files modified before this time (zero: any)
+	PruneEmpty      bool             // Leave out directories without any files (such as after exclusions)
+	Owners          []uint32         // User IDs of which any must own the walked files (empty: any)
+	Groups          []uint32         // Group IDs of which any must own the walked files (empty: any)
+}
+
+// contextReader is an [io.Reader] that stops reading upon context cancellation.
+// It allows for long-running (de-)compression operations to observe cancellation
+// within the duration of a single read, rather than only at their next boundary.
+type contextReader struct {
+	ctx context.Context //nolint:containedctx
+	r   io.Reader
+}
+
+// Read is a method that reads from the underlying [io.Reader], unless the
+// context was cancelled, in which case the context's error is returned.
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return cr.r.Read(p) //nolint:wrapcheck
+}
+
+// contextWriter is an [io.Writer] that stops writing upon context cancellation.
+// It allows for long-running compression operations to observe cancellation
+// within the duration of a single write, rather than only at their next entry.
+type contextWriter struct {
+	ctx context.Context //nolint:containedctx
+	w   io.Writer
+}
+
+// Write is a method that writes to the underlying [io.Writer], unless the
+// context was cancelled, in which case the context's error is returned.
+func (cw contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return cw.w.Write(p) //nolint:wrapcheck
+}
+
+// countingReader is an [io.Reader] that counts the bytes read through it.
+// The count is safe to load while reading happens elsewhere (e.g. read-ahead).
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+// Read is a method that reads from the underlying [io.Reader] and keeps count.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n.Add(int64(n))
+
+	return n, err //nolint:wrapcheck
+}
+
+// countingWriter is an [io.Writer] that counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write is a method that writes to the underlying [io.Writer] and keeps count.
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// nopWriteCloser is an [io.WriteCloser] with a Close method doing nothing,
+// leaving the closing of the underlying [io.Writer] to its owner.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close is a method that does nothing.
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// gzipMagic are the first bytes of any gzip stream (of a compressed tarball).
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// newArchiveReader returns a reader of the tar stream of an archive read from
+// r, which is decompressed for a compressed tarball (as detected from its first
+// bytes), or otherwise read as-is for a plain tarball (i.e. an uncompressed .tar).
+// Inputs too short to be detected are treated as (truncated) compressed tarballs.
+func newArchiveReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	if magic, err := br.Peek(len(gzipMagic)); err == nil && !bytes.Equal(magic, gzipMagic) {
+		return io.NopCloser(br), nil
+	}
+
+	// The decompression happens concurrently (read-ahead) with the reading
+	// of the tar headers, as it is otherwise the dominant cost of streaming.
+	gz, err := pgzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize gzip reader: %w", err)
+	}
+
+	return gz, nil
+}
+
+// newArchiveWriter returns a writer of the tar stream of an archive to w, which
+// is compressed as per the [GzipConfig], unless with a compression level of none
+// ([gzip.NoCompression]), with which the tar stream is written as a plain tarball.
+// The gzip headers (of all members) carry the [ProgramConfig.GzipHeader].
+// Closing the writer finishes any compression, but does not close w itself.
+func (prog *Program) newArchiveWriter(w io.Writer) (io.WriteCloser, error) {
+	if prog.gzipConfig.CompressionLevel == gzip.NoCompression {
+		return nopWriteCloser{w}, nil
+	}
+
+	gw, err := pgzip.NewWriterLevel(w, prog.gzipConfig.CompressionLevel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize gzip writer: %w", err)
+	}
+
+	if err := gw.SetConcurrency(prog.gzipConfig.BlockSize, prog.gzipConfig.BlockCount); err != nil {
+		return nil, fmt.Errorf("failed to set gzip writer settings: %w", err)
+	}
+
+	// A zero time is not recorded as none by pgzip (unlike by compress/gzip),
+	// but as its (negative) seconds since the epoch, truncated into a bogus one.
+	gw.ModTime = time.Unix(0, 0)
+	if !prog.config.ModTime.IsZero() {
+		gw.ModTime = prog.config.ModTime
+	}
+	if !prog.config.GzipHeader.ModTime.IsZero() {
+		gw.ModTime = prog.config.GzipHeader.ModTime
+	}
+	gw.Name = prog.config.GzipHeader.Name
+	gw.Comment = prog.config.GzipHeader.Comment
+
+	return gw, nil
+}
+
+// tarBlockSize is the size of the blocks of a tar stream (also of its trailer).
+const tarBlockSize = 512
+
+// concatTarReader is a reader of the entries of a tar stream, which continues
+// past the end-of-archive trailer with any further tar streams concatenated to
+// it (as with concatenated tarballs, e.g. split uploads or appended snapshots),
+// skipping all zero blocks in between (much like the --ignore-zeros of GNU tar).
+//
+// Unless strict, any data following a trailer that is neither readable nor a
+// further tar stream (such as padding or garbage after the gzip stream) is
+// taken as the end of the tarball, just as by other tar readers. Only strict
+// readers (as of 'verify') fail for such data, reading all of r to its end.
+type concatTarReader struct {
+	r      io.Reader
+	tr     *tar.Reader
+	strict bool
+	fresh  bool // The current tar stream follows a trailer (and has no entries yet)
+}
+
+// newConcatTarReader returns a [concatTarReader] of the tar stream read from r.
+func newConcatTarReader(r io.Reader, strict bool) *concatTarReader {
+	return &concatTarReader{r: r, tr: tar.NewReader(r), strict: strict}
+}
+
+// Next advances to the next entry, with the semantics of [tar.Reader.Next].
+// A strict reader only returns [io.EOF] once all of r was read, so that any
+// checksums at the end of the stream (such as of the last gzip member) are
+// also verified.
+func (cr *concatTarReader) Next() (*tar.Header, error) {
+	for {
+		hdr, err := cr.tr.Next()
+		if err != nil && !errors.Is(err, io.EOF) && cr.fresh && !cr.strict {
+			return nil, io.EOF // Trailing data not being a further tar stream.
+		}
+		if !errors.Is(err, io.EOF) {
+			cr.fresh = false
+
+			return hdr, err //nolint:wrapcheck
+		}
+		err = nil
+
+		// Not read with [io.ReadFull], as that would turn a (partial) zero block
+		// at the end into an [io.ErrUnexpectedEOF], not distinguishable from an
+		// [io.ErrUnexpectedEOF] of the underlying reader (e.g. truncated gzip).
+		block := make([]byte, tarBlockSize)
+
+		var n int
+		for n < len(block) && err == nil {
+			var nn int
+			nn, err = cr.r.Read(block[n:])
+			n += nn
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			if !cr.strict {
+				return nil, io.EOF // Trailing data not being readable.
+			}
+
+			return nil, err //nolint:wrapcheck
+		}
+
+		if isZeroBlock(block[:n]) {
+			if err != nil {
+				return nil, io.EOF
+			}
+
+			continue // Trailer (or padding) of the last tar stream.
+		}
+
+		cr.tr = tar.NewReader(io.MultiReader(bytes.NewReader(block[:n]), cr.r))
+		cr.fresh = true
+	}
+}
+
+// isZeroBlock returns if a block (of a tar stream) consists of only zero bytes.
+func isZeroBlock(block []byte) bool {
+	for _, b := range block {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// StreamError is an error that occurred at a specific entry of a path stream.
+// It carries the context needed for locating the offending entry in its source,
+// which otherwise (e.g. for a single corrupt header within a large archive)
+// would need to be located through some rather time-consuming bisection.
+// The offsets are those after the header of the last good entry, before any
+// of its content, which is also where the offending entry starts, unless the
+// last good entry has any content (unlike the placeholders written by 'create').
+// The compressed offset is only approximate, as the compressed input is read
+// ahead of its decompression (so it may lie well past the offending entry).
+type StreamError struct {
+	Index            int64  // Index of the offending entry within the stream (zero-based)
+	Path             string // Path of the offending entry (empty if unreadable)
+	PrevPath         string // Path of the last good entry preceding the offending one
+	Offset           int64  // Byte offset within the (uncompressed) stream after the last good entry (-1: unknown)
+	CompressedOffset int64  // Approximate byte offset within the compressed input file (-1: unknown)
+	Err              error  // Underlying error
+}
+
+// Error is a method that returns the error message including the entry context.
+func (e *StreamError) Error() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "entry #%d", e.Index)
+
+	if e.Path != "" {
+		fmt.Fprintf(&sb, " %q", e.Path)
+	} else if e.PrevPath != "" {
+		fmt.Fprintf(&sb, " (after %q)", e.PrevPath)
+	}
+
+	if e.Offset >= 0 {
+		fmt.Fprintf(&sb, " past offset %d", e.Offset)
+	}
+
+	if e.CompressedOffset >= 0 {
+		fmt.Fprintf(&sb, " (compressed: approx. %d)", e.CompressedOffset)
+	}
+
+	fmt.Fprintf(&sb, ": %v", e.Err)
+
+	return sb.String()
+}
+
+// Unwrap is a method that returns the underlying error.
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON is a method that returns the error as a machine-readable object.
+// Any unknown offsets and paths are omitted, the underlying error is a message.
+func (e *StreamError) MarshalJSON() ([]byte, error) {
+	type streamErrorJSON struct {
+		Index            int64  `json:"index"`
+		Path             string `json:"path,omitempty"`
+		PrevPath         string `json:"prevPath,omitempty"`
+		Offset           *int64 `json:"offset,omitempty"`
+		CompressedOffset *int64 `json:"compressedOffset,omitempty"`
+		Error            string `json:"error"`
+	}
+
+	out := streamErrorJSON{
+		Index:    e.Index,
+		Path:     e.Path,
+		PrevPath: e.PrevPath,
+	}
+
+	if e.Offset >= 0 {
+		out.Offset = &e.Offset
+	}
+
+	if e.CompressedOffset >= 0 {
+		out.CompressedOffset = &e.CompressedOffset
+	}
+
+	if e.Err != nil {
+		out.Error = e.Err.Error()
+	}
+
+	return json.Marshal(out) //nolint:wrapcheck
 }
 
 // Walker is an interface describing a filesystem walking function.
@@ -48,10 +424,17 @@ func (w AferoWalker) WalkDir(root string, fn fs.WalkDirFunc) error {
 }
 
 // OSWalker is a wrapper structure for the native [filepath.WalkDir] function.
-type OSWalker struct{}
+type OSWalker struct {
+	FollowSymlinks bool // Descend into symbolic links to directories (with loop detection)
+}
 
 // WalkDir is a wrapper method for the native [filepath.WalkDir] function.
+// If FollowSymlinks is set, it instead uses a variant following symbolic links.
 func (w OSWalker) WalkDir(root string, fn fs.WalkDirFunc) error {
+	if w.FollowSymlinks {
+		return followWalkDir(root, fn)
+	}
+
 	return filepath.WalkDir(root, fn) //nolint:wrapcheck
 }
 
@@ -76,6 +459,13 @@ func (fi fileInfoDirEntry) Name() string {
 }
 
 func isExcluded(path string, isDir bool, excludes []string) (bool, error) {
+	_, matched, err := matchExclude(path, isDir, excludes)
+
+	return matched, err
+}
+
+// matchExclude returns the first of the excludes matching a path, if any.
+func matchExclude(path string, isDir bool, excludes []string) (string, bool, error) {
 	path = filepath.ToSlash(filepath.Clean(path))
 
 	for _, rawPattern := range excludes {
@@ -86,27 +476,220 @@ func isExcluded(path string, isDir bool, excludes []string) (bool, error) {
 
 		matched, err := doublestar.Match(pattern, path)
 		if err != nil {
-			return false, fmt.Errorf("invalid exclude pattern: %w", err)
+			return "", false, fmt.Errorf("invalid exclude pattern: %w", err)
 		}
 		if matched {
 			if needDirMatch && !isDir {
 				continue
 			}
 
+			return rawPattern, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// isRegexExcluded returns if a path is matched by any of the regular expressions.
+// The expressions are matched against the slash-separated form of the path, with
+// directories carrying a trailing slash (for them to be distinguishable from files).
+func isRegexExcluded(path string, isDir bool, regexes []*regexp.Regexp) bool {
+	return matchRegexExclude(path, isDir, regexes) != nil
+}
+
+// matchRegexExclude returns the first of the regular expressions matching a path, if any.
+func matchRegexExclude(path string, isDir bool, regexes []*regexp.Regexp) *regexp.Regexp {
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	if isDir {
+		path += "/"
+	}
+
+	for _, re := range regexes {
+		if re.MatchString(path) {
+			return re
+		}
+	}
+
+	return nil
+}
+
+// isExcluded returns if a path is excluded by either the excludes patterns or
+// any of the exclusion mechanisms configured in the program's [ProgramConfig].
+//
+// The excludes are expected as the [Matcher] of [Program.excludeMatcher], as
+// that is best built (and folded for case-insensitivity) once per operation.
+func (prog *Program) isExcluded(path string, isDir bool, excludes Matcher) (bool, error) {
+	_, excluded, err := prog.matchExclusion(path, isDir, excludes)
+
+	return excluded, err
+}
+
+// matchExclusion is a variant of [Program.isExcluded] that also returns the
+// exclusion mechanism having excluded the path (as the flag it was given by).
+func (prog *Program) matchExclusion(path string, isDir bool, excludes Matcher) (string, bool, error) {
+	if prog.config.MaxDepth > 0 && pathDepth(path) > prog.config.MaxDepth {
+		return fmt.Sprintf("--max-depth=%d", prog.config.MaxDepth), true, nil
+	}
+
+	if prog.config.IgnoreCase {
+		path = strings.ToLower(path)
+	}
+
+	if pattern, excluded, err := excludes.Match(path, isDir); err != nil {
+		return "", false, err
+	} else if excluded {
+		return "--exclude=" + pattern, true, nil
+	}
+
+	if re := matchRegexExclude(path, isDir, prog.config.ExcludeRegexes); re != nil {
+		return "--exclude-regex=" + re.String(), true, nil
+	}
+
+	// Directories are never excluded by the includes, as their contents may still match.
+	if !isDir && prog.includes != nil {
+		if included, err := isIncluded(path, prog.includes); err != nil {
+			return "", false, err
+		} else if !included {
+			return "--include (as none matches)", true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// isIncluded returns if a file, or any of its parent directories, matches any
+// of the includes (so that including a directory includes all of its files).
+func isIncluded(path string, includes Matcher) (bool, error) {
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	for isDir := false; ; isDir = true {
+		if _, matched, err := includes.Match(path, isDir); err != nil {
+			return false, fmt.Errorf("invalid include pattern: %w", err)
+		} else if matched {
 			return true, nil
 		}
+
+		i := strings.LastIndex(path, "/")
+		if i <= 0 {
+			return false, nil
+		}
+		path = path[:i]
+	}
+}
+
+var errInvalidMaxDepth = errors.New("invalid max depth")
+
+// checkMaxDepth returns an error for a negative maximum depth (as for --max-depth),
+// which would otherwise be taken as unlimited (like the zero value).
+func checkMaxDepth(depth int) error {
+	if depth < 0 {
+		return fmt.Errorf("%w: %d (expected 0 or more)", errInvalidMaxDepth, depth)
+	}
+
+	return nil
+}
+
+// pathDepth returns the depth of a relative path, as in its amount of components.
+// A path on the first level of a tree (e.g. "a.txt" or "dir/") has a depth of one.
+func pathDepth(path string) int {
+	path = strings.Trim(filepath.ToSlash(filepath.Clean(path)), "/")
+	if path == "" || path == "." {
+		return 0
+	}
+
+	return strings.Count(path, "/") + 1
+}
+
+// foldExcludes returns the excludes in lower case, if case-insensitivity is
+// enabled in the program's [ProgramConfig], or otherwise returns them as-is.
+func (prog *Program) foldExcludes(excludes []string) []string {
+	if !prog.config.IgnoreCase {
+		return excludes
+	}
+
+	folded := make([]string, 0, len(excludes))
+	for _, pattern := range excludes {
+		folded = append(folded, strings.ToLower(pattern))
+	}
+
+	return folded
+}
+
+// excludeMatcher returns the [Matcher] of the excludes of an operation, in the
+// [ProgramConfig.PatternSyntax], folded as per [Program.foldExcludes].
+func (prog *Program) excludeMatcher(excludes []string) Matcher {
+	return newMatcher(prog.config.PatternSyntax, prog.foldExcludes(excludes))
+}
+
+// compileRegexes compiles a slice of regular expressions for use as excludes.
+// With ignoreCase set to true, the expressions are made case-insensitive.
+func compileRegexes(exprs []string, ignoreCase bool) ([]*regexp.Regexp, error) {
+	regexes := make([]*regexp.Regexp, 0, len(exprs))
+
+	for _, expr := range exprs {
+		if ignoreCase {
+			expr = "(?i)" + expr
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude regex: %w", err)
+		}
+
+		regexes = append(regexes, re)
 	}
 
-	return false, nil
+	return regexes, nil
+}
+
+// printPath prints a record (such as a path) to standard output, terminated by
+// a newline or (with [ProgramConfig.Print0]) a NUL byte, so that paths holding
+// any newlines can be processed safely (e.g. with "xargs -0").
+func (prog *Program) printPath(record string) {
+	if prog.config.Print0 {
+		fmt.Fprintf(prog.stdout, "%s\x00", record)
+
+		return
+	}
+
+	fmt.Fprintln(prog.stdout, record)
+}
+
+var errInvalidTarFormat = errors.New("invalid tar format")
+
+// parseTarFormat returns the [tar.Format] for a format name (as for --tar-format).
+// The "auto" format leaves the choice to the tar writer, which uses the oldest
+// format able to represent each header (ustar, then PAX, then GNU).
+func parseTarFormat(name string) (tar.Format, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return tar.FormatUnknown, nil
+	case "ustar":
+		return tar.FormatUSTAR, nil
+	case "pax":
+		return tar.FormatPAX, nil
+	case "gnu":
+		return tar.FormatGNU, nil
+	default:
+		return tar.FormatUnknown, fmt.Errorf("%w: %q (expected auto, ustar, pax or gnu)", errInvalidTarFormat, name)
+	}
 }
 
 func (prog *Program) mergeExcludes(excludeSlice []string, excludeFile string) ([]string, error) {
-	excludes := []string{}
+	return mergePatterns(prog.fs, "exclude", excludeSlice, excludeFile)
+}
 
-	if excludeFile != "" {
-		file, err := prog.fs.Open(excludeFile)
+// mergePatterns returns the patterns of a file (one per line, skipping any blank
+// lines and "#" comments) followed by those of the slice, as for --excludes-from
+// and --includes-from, with kind naming the type of patterns for any errors.
+func mergePatterns(fs afero.Fs, kind string, patternSlice []string, patternFile string) ([]string, error) {
+	patterns := []string{}
+
+	if patternFile != "" {
+		file, err := fs.Open(patternFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open exclude file: %w", err)
+			return nil, fmt.Errorf("failed to open %s file: %w", kind, err)
 		}
 		defer file.Close()
 
@@ -118,25 +701,32 @@ func (prog *Program) mergeExcludes(excludeSlice []string, excludeFile string) ([
 				continue
 			}
 
-			excludes = append(excludes, line)
+			patterns = append(patterns, line)
 		}
 
 		if err := scanner.Err(); err != nil {
-			return nil, fmt.Errorf("failed reading exclude file: %w", err)
+			return nil, fmt.Errorf("failed reading %s file: %w", kind, err)
 		}
 	}
 
-	excludes = append(excludes, excludeSlice...)
+	patterns = append(patterns, patternSlice...)
+
+	return patterns, nil
+}
 
-	return excludes, nil
+func writeDummyFile(tw *tar.Writer, name string, isDir bool, format tar.Format) error {
+	return writeTarHeader(tw, dummyHeader(name, isDir, format))
 }
 
-func writeDummyFile(tw *tar.Writer, name string, isDir bool) error {
+// dummyHeader returns the tar header of a dummy entry (a zero-byte file, or a
+// directory with a trailing slash), as all entries are written by treeball.
+func dummyHeader(name string, isDir bool, format tar.Format) *tar.Header {
 	name = filepath.ToSlash(name)
 
 	hdr := &tar.Header{
 		Name:    name,
 		ModTime: time.Time{},
+		Format:  format,
 	}
 
 	if isDir {
@@ -153,6 +743,11 @@ func writeDummyFile(tw *tar.Writer, name string, isDir bool) error {
 
 	hdr.Size = 0
 
+	return hdr
+}
+
+// writeTarHeader writes a tar header (of an entry without any content).
+func writeTarHeader(tw *tar.Writer, hdr *tar.Header) error {
 	if err := tw.WriteHeader(hdr); err != nil {
 		return fmt.Errorf("failed to write tar header: %w", err)
 	}
@@ -160,63 +755,387 @@ func writeDummyFile(tw *tar.Writer, name string, isDir bool) error {
 	return nil
 }
 
+// createOutput creates the output file at path, protecting any existing one.
+//
+// An existing regular file is renamed aside with [ProgramConfig.Backup], or
+// overwritten with [ProgramConfig.Force], otherwise an error wrapping
+// [ErrOutputExists] is returned. Any existing non-regular files (such as
+// /dev/null) are always written to, as there is nothing to protect in them
+// (nor to sync, as with [ProgramConfig.Fsync] for all other output files).
+func (prog *Program) createOutput(ctx context.Context, path string) (afero.File, error) {
+	info, err := prog.fs.Stat(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat output file: %w", err)
+	}
+
+	if info != nil && !info.Mode().IsRegular() {
+		f, err := prog.fs.Create(path)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		return progressFrom(ctx).trackWrites(f), nil
+	}
+
+	if info != nil && prog.config.Backup {
+		if err := prog.backupOutput(path); err != nil {
+			return nil, err
+		}
+	}
+
+	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if !prog.config.Force {
+		flags |= os.O_EXCL
+	}
+
+	f, err := prog.fs.OpenFile(path, flags, 0o666)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("%w: %s (use --force or --backup)", ErrOutputExists, path)
+	} else if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return progressFrom(ctx).trackWrites(prog.syncOnClose(f)), nil
+}
+
+// backupOutput renames an existing output file at path aside, to the first
+// free name out of path.bak, path.bak.1, path.bak.2 and so on (never replacing
+// any previous backups), so that no previous snapshots are ever destroyed.
+func (prog *Program) backupOutput(path string) error {
+	backup := path + ".bak"
+
+	for i := 1; ; i++ {
+		if _, err := prog.fs.Stat(backup); errors.Is(err, fs.ErrNotExist) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to stat backup file: %w", err)
+		}
+
+		backup = fmt.Sprintf("%s.bak.%d", path, i)
+	}
+
+	if err := prog.fs.Rename(path, backup); err != nil {
+		return fmt.Errorf("failed to back up output file: %w", err)
+	}
+
+	prog.infof("backed up %q to %q", path, backup)
+
+	return nil
+}
+
 func (prog *Program) multiPathStream(ctx context.Context, path string, sort bool, excludes []string) (<-chan string, <-chan error, error) {
 	info, err := prog.fs.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, partErr := prog.fs.Stat(splitPartPath(path, 0)); partErr == nil {
+			paths, errs := prog.tarPathStream(ctx, path, sort, excludes) // A split archive.
+
+			return paths, errs, nil
+		}
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to stat: %w", err)
 	}
 
 	if info.IsDir() {
-		paths, errs := prog.fsPathStream(ctx, path, sort, excludes)
+		paths, errs := prog.fsPathStream(ctx, path, "", sort, excludes)
 
 		return paths, errs, nil
 	}
 
-	paths, errs := prog.tarPathStream(ctx, path, sort, excludes)
+	paths, errs := prog.filePathStream(ctx, path, sort, excludes)
 
 	return paths, errs, nil
 }
 
-func (prog *Program) fsPathStream(ctx context.Context, path string, sort bool, excludes []string) (<-chan string, <-chan error) {
-	paths := make(chan string, fsStreamBuffer)
-	errs := make(chan error, 1)
+// walkTree walks the directory tree at root with the program's [Walker].
+//
+// The fn function is called with the relative path of every encountered entry
+// (except the root itself) that was not filtered out by either the excludes or
+// any other filtering mechanism enabled in the program's [ProgramConfig].
+// Excluded directories are skipped entirely, including all of their contents.
+//
+// A non-empty prefix is prepended to all relative paths, as if root was placed
+// under it, with the excludes and maximum depth applying to the prefixed paths.
+// Any .gitignore files are still applied relative to their own locations.
+//
+// With [ProgramConfig.DescendArchives], any nested archives are passed as
+// directories instead, followed by their entries (see [Program.descendArchive]).
+func (prog *Program) walkTree(ctx context.Context, root string, prefix string, excludes []string, fn func(relPath string, d fs.DirEntry) error) error {
+	var ignores *gitIgnoreStack
+	if prog.config.GitIgnore {
+		ignores = &gitIgnoreStack{ignoreCase: prog.config.IgnoreCase}
+	}
 
-	go func() {
-		defer close(paths)
-		defer close(errs)
+	matcher := prog.excludeMatcher(excludes)
 
-		if err := prog.fsWalker.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
-			if err := ctx.Err(); err != nil {
-				return fmt.Errorf("failed to walk filesystem: %w", err)
+	var index int64
+	var prevPath string
+
+	progress := progressFrom(ctx)
+
+	var pruner *emptyDirPruner
+	if prog.config.PruneEmpty {
+		pruner = &emptyDirPruner{}
+	}
+
+	// emit passes an entry to the function, returning [filepath.SkipDir] for
+	// any directories of which the contents are not to be walked any further.
+	emit := func(relPath string, d fs.DirEntry) error {
+		atMaxDepth := d.IsDir() && prog.config.MaxDepth > 0 && pathDepth(relPath) >= prog.config.MaxDepth
+
+		if pruner != nil {
+			prunePath := filepath.ToSlash(relPath)
+
+			if d.IsDir() && !atMaxDepth {
+				// Directories are deferred, so none can skip their remaining contents anymore
+				// (as with resumes), which are then just skipped by the function one by one.
+				pruner.dir(prunePath+"/", func() error {
+					if err := fn(relPath, d); err != nil && !errors.Is(err, filepath.SkipDir) {
+						return err
+					}
+
+					return nil
+				})
+
+				return nil
 			}
 
-			if err != nil {
-				return fmt.Errorf("failed to walk filesystem: %w", err)
+			if err := pruner.file(prunePath, func() error { return fn(relPath, d) }); err != nil {
+				return err
+			}
+		} else if err := fn(relPath, d); err != nil {
+			return err
+		}
+
+		// Contents of directories at maximum depth would be excluded anyway.
+		if atMaxDepth {
+			return filepath.SkipDir
+		}
+
+		return nil
+	}
+
+	return prog.fsWalker.WalkDir(root, func(path string, d fs.DirEntry, err error) error { //nolint:wrapcheck
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("failed to walk filesystem: %w", err)
+		}
+
+		if err != nil {
+			if prog.config.SkipErrors && path != root {
+				return prog.skipEntry(ctx, path, d, err)
 			}
 
-			if p == path {
+			return fmt.Errorf("failed to walk filesystem: %w", &StreamError{
+				Index: index, Path: path, PrevPath: prevPath,
+				Offset: -1, CompressedOffset: -1, Err: err,
+			})
+		}
+
+		index++
+		prevPath = path
+		progress.addEntry()
+
+		if path == root {
+			if ignores != nil && d.IsDir() {
+				if err := ignores.load(prog.fs, path, ""); err != nil {
+					return fmt.Errorf("failed to load gitignore: %w", err)
+				}
+			}
+
+			return nil
+		}
+
+		treePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return fmt.Errorf("failed to obtain relative path: %w", err)
+		}
+
+		relPath := treePath
+		if prefix != "" {
+			relPath = filepath.Join(prefix, treePath)
+		}
+
+		if excluded, err := prog.isExcluded(relPath, d.IsDir(), matcher); err != nil {
+			return fmt.Errorf("failed to check for exclusion: %w", err)
+		} else if excluded && d.IsDir() {
+			return filepath.SkipDir
+		} else if excluded {
+			return nil
+		}
+
+		if ignores != nil {
+			if ignores.match(treePath, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+
 				return nil
 			}
 
-			relPath, err := filepath.Rel(path, p)
-			if err != nil {
-				return fmt.Errorf("failed to obtain relative path: %w", err)
+			if d.IsDir() {
+				if err := ignores.load(prog.fs, path, treePath); err != nil {
+					return fmt.Errorf("failed to load gitignore: %w", err)
+				}
 			}
+		}
+
+		// Directories are never filtered by their age or owner, as their contents may still match.
+		if !d.IsDir() && (prog.filtersAge() || prog.filtersOwnership()) {
+			if matches, err := prog.matchesFileFilters(d); err != nil {
+				if prog.config.SkipErrors {
+					return prog.skipEntry(ctx, path, d, err)
+				}
 
-			if excluded, err := isExcluded(relPath, d.IsDir(), excludes); err != nil {
-				return fmt.Errorf("failed to check for exclusion: %w", err)
-			} else if excluded && d.IsDir() {
-				return filepath.SkipDir
-			} else if excluded {
+				return err
+			} else if !matches {
 				return nil
 			}
+		}
+
+		if prog.config.DescendArchives && !d.IsDir() && isNestedArchive(d.Name()) {
+			return prog.descendArchive(ctx, path, relPath, d, matcher, emit)
+		}
+
+		return emit(relPath, d)
+	})
+}
+
+// matchesFileFilters returns if a file matches the filters of walks by age and
+// ownership (see [Program.matchesAge] and [Program.matchesOwnership]).
+func (prog *Program) matchesFileFilters(d fs.DirEntry) (bool, error) {
+	info, err := d.Info()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat entry: %w", err)
+	}
+
+	if prog.filtersAge() && !prog.matchesAge(info) {
+		return false, nil
+	}
+
+	if prog.filtersOwnership() {
+		return prog.matchesOwnership(info)
+	}
+
+	return true, nil
+}
+
+// skipCounterKey is the context key of the per-operation count of skipped entries.
+type skipCounterKey struct{}
+
+// withSkipCounter returns a context carrying a new count of skipped entries.
+// Operations count within their own context, so that any concurrent operations
+// of the same [Program] do not share (or need to synchronize) their counts.
+func withSkipCounter(ctx context.Context) (context.Context, *atomic.Int64) {
+	counter := &atomic.Int64{}
+
+	return context.WithValue(ctx, skipCounterKey{}, counter), counter
+}
+
+// skipEntry records an unreadable entry encountered during a filesystem walk
+// as skipped, printing a warning and returning the appropriate [fs.WalkDirFunc]
+// result for the walk to continue past the entry (and any of its contents).
+func (prog *Program) skipEntry(ctx context.Context, path string, d fs.DirEntry, err error) error {
+	if counter, ok := ctx.Value(skipCounterKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+
+	prog.warnf("skipping %q: %v", path, err)
+
+	if d != nil && d.IsDir() {
+		return filepath.SkipDir
+	}
+
+	return nil
+}
+
+// checkSkipped reports the count of any entries skipped during the operation.
+// If there were any, the operation's result err is joined with [ErrEntriesSkipped]
+// (a [ErrPartialSuccess]), unless it was a failure, which is returned as-is.
+func (prog *Program) checkSkipped(err error, skipped *atomic.Int64) error {
+	if err != nil && !errors.Is(err, ErrDiffsFound) {
+		return err
+	}
+
+	n := skipped.Load()
+	if n == 0 {
+		return err
+	}
+
+	prog.warnf("%d entries were skipped due to errors", n)
+
+	return errors.Join(err, ErrEntriesSkipped)
+}
+
+var errUnsortedWalk = errors.New("walk is not in sorted order")
+
+// walksSorted returns if the walks of directories are in the sorted order of
+// their paths (see [ParallelWalker]), so that their streams need no sorting,
+// with [ProgramConfig.SortedWalk]. This is not possible when following any
+// symbolic links or descending into nested archives (where the paths are not
+// of the directory itself), nor with [ProgramConfig.IgnoreCase] (where the
+// paths are compared case-insensitively), which are then sorted as usual.
+func walksSorted(config *ProgramConfig) bool {
+	return config.SortedWalk && !config.FollowSymlinks && !config.DescendArchives && !config.IgnoreCase
+}
+
+// fsPathStream returns a stream of the paths of a directory tree, which are
+// sorted externally if sort is set, unless they are walked sorted already (see
+// [walksSorted]), in which case their order is still verified while streaming.
+func (prog *Program) fsPathStream(ctx context.Context, path string, prefix string, sort bool, excludes []string) (<-chan string, <-chan error) {
+	paths := make(chan string, fsStreamBuffer)
+	errs := make(chan error, 1)
+
+	sorted := sort && walksSorted(prog.config)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		if prog.capturesMetadata() {
+			if err := prog.checkMetadataSupported(path); err != nil {
+				errs <- fmt.Errorf("failed to capture metadata: %w", err)
+
+				return
+			}
+		}
+
+		var arena pathArena
+		var prevPath string
+
+		if err := prog.walkTree(ctx, path, prefix, excludes, func(relPath string, d fs.DirEntry) error {
+			var records map[string]string
+
+			if prog.capturesMetadata() && !isVirtualEntry(d) {
+				treePath, err := filepath.Rel(filepath.Join(prefix, "."), relPath)
+				if err != nil {
+					return fmt.Errorf("failed to obtain relative path: %w", err)
+				}
+
+				if records, err = prog.entryMetadata(filepath.Join(path, treePath)); err != nil {
+					return err
+				}
+			}
 
 			relPath = filepath.ToSlash(relPath)
 			if d.IsDir() && !strings.HasSuffix(relPath, "/") {
-				relPath += "/"
+				relPath = arena.join(relPath, "/")
+			} else {
+				relPath = arena.join(relPath, "")
 			}
 
-			paths <- relPath
+			if sorted {
+				if prevPath != "" && relPath <= prevPath {
+					return fmt.Errorf("%w: %q after %q", errUnsortedWalk, relPath, prevPath)
+				}
+				prevPath = relPath
+			}
+
+			select {
+			case paths <- metadataRecord(relPath, records):
+			case <-ctx.Done():
+				return fmt.Errorf("failed to send path: %w", ctx.Err())
+			}
 
 			return nil
 		}); err != nil {
@@ -224,22 +1143,47 @@ func (prog *Program) fsPathStream(ctx context.Context, path string, sort bool, e
 		}
 	}()
 
-	if !sort {
+	if !sort || sorted {
 		return paths, errs
 	}
 
-	return extsortStrings(ctx, paths, errs, prog.extSortConfig)
+	return extsortStringsFunc(ctx, paths, errs, prog.extSortConfig, prog.comparePaths)
 }
 
 func (prog *Program) tarPathStream(ctx context.Context, path string, sort bool, excludes []string) (<-chan string, <-chan error) {
+	paths, errs := prog.readTarPaths(ctx, path, excludes, false)
+
+	if !sort {
+		return paths, errs
+	}
+
+	sorted, sortErrs := extsortStringsFunc(ctx, paths, errs, prog.extSortConfig, prog.comparePaths)
+	unique, uniqueErrs := prog.uniquePathStream(ctx, sorted, sortErrs)
+
+	if prog.config.PruneEmpty {
+		return prog.pruneEmptyStream(ctx, unique, uniqueErrs)
+	}
+
+	return unique, uniqueErrs
+}
+
+// readTarPaths returns the stream of the paths of a tarball in the order of its
+// entries (see [Program.tarPathStream]). With strict, the tarball is read to the
+// end of its compressed stream, failing for any data following its trailer
+// (see [concatTarReader]), as is only wanted for verifying its integrity.
+func (prog *Program) readTarPaths(ctx context.Context, path string, excludes []string, strict bool) (<-chan string, <-chan error) {
 	paths := make(chan string, tarStreamBuffer)
 	errs := make(chan error, 1)
 
+	matcher := prog.excludeMatcher(excludes)
+	progress := progressFrom(ctx)
+
 	go func() {
 		defer close(paths)
 		defer close(errs)
+		defer recoverWorker(ctx)
 
-		f, err := prog.fs.Open(path)
+		f, err := prog.openArchive(path)
 		if err != nil {
 			errs <- fmt.Errorf("failed to open input file: %w", err)
 
@@ -247,26 +1191,41 @@ func (prog *Program) tarPathStream(ctx context.Context, path string, sort bool,
 		}
 		defer f.Close()
 
-		gz, err := gzip.NewReader(f)
+		cr := &countingReader{r: contextReader{ctx: ctx, r: f}}
+
+		ar, err := newArchiveReader(cr)
 		if err != nil {
-			errs <- fmt.Errorf("failed to initialize gzip reader: %w", err)
+			errs <- err
 
 			return
 		}
-		defer gz.Close()
+		defer ar.Close()
+
+		// The offsets are those of the tar stream after the last good entry,
+		// any compressed offsets are approximate due to read-ahead.
+		tc := &countingReader{r: ar}
+		tr := newConcatTarReader(tc, strict)
 
-		tr := tar.NewReader(gz)
-		for {
+		var arena pathArena
+		var index int64
+		var prevPath string
+
+		for ; ; index++ {
 			if err := ctx.Err(); err != nil {
 				errs <- fmt.Errorf("failed to stream from tar: %w", err)
 
 				return
 			}
 
+			offset, compressedOffset := tc.n.Load(), cr.n.Load()
+
 			hdr, err := tr.Next()
 			if err != nil {
 				if !errors.Is(err, io.EOF) {
-					errs <- fmt.Errorf("failed to stream from tar: %w", err)
+					errs <- fmt.Errorf("failed to stream from tar: %w", &StreamError{
+						Index: index, PrevPath: prevPath,
+						Offset: offset, CompressedOffset: compressedOffset, Err: err,
+					})
 
 					return
 				}
@@ -274,21 +1233,120 @@ func (prog *Program) tarPathStream(ctx context.Context, path string, sort bool,
 				break // EOF
 			}
 
-			if excluded, err := isExcluded(hdr.Name, strings.HasSuffix(hdr.Name, "/"), excludes); err != nil {
-				errs <- fmt.Errorf("failed to check for exclusion: %w", err)
+			p, ok, err := prog.tarEntryPath(hdr)
+			if err != nil {
+				errs <- fmt.Errorf("failed to stream from tar: %w", &StreamError{
+					Index: index, Path: hdr.Name, PrevPath: prevPath,
+					Offset: offset, CompressedOffset: compressedOffset, Err: err,
+				})
+
+				return
+			}
+
+			if excluded, err := prog.isExcluded(p, strings.HasSuffix(p, "/"), matcher); err != nil {
+				errs <- fmt.Errorf("failed to check for exclusion: %w", &StreamError{
+					Index: index, Path: hdr.Name, PrevPath: prevPath,
+					Offset: offset, CompressedOffset: compressedOffset, Err: err,
+				})
 
 				return
-			} else if !excluded {
-				paths <- hdr.Name
+			} else if ok && !excluded {
+				if prog.capturesMetadata() {
+					p = metadataRecord(p, prog.tarMetadata(hdr))
+				}
+
+				select {
+				case paths <- arena.join(p, ""):
+				case <-ctx.Done():
+					errs <- fmt.Errorf("failed to stream from tar: %w", ctx.Err())
+
+					return
+				}
 			}
+
+			prevPath = hdr.Name
+			progress.addEntry()
 		}
 	}()
 
-	if !sort {
-		return paths, errs
+	return paths, errs
+}
+
+// uniquePathStream removes the duplicate paths of a sorted stream of the paths
+// of a tarball (as of archives appended to with "tar -r"), so that these are not
+// reported as differences. With [ProgramConfig.Strict], the first duplicate path
+// is rejected as an error instead.
+func (prog *Program) uniquePathStream(ctx context.Context, input <-chan string, inputErrs <-chan error) (<-chan string, <-chan error) {
+	paths := make(chan string, tarStreamBuffer)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(paths)
+		defer close(errs)
+		defer recoverWorker(ctx)
+
+		var last string
+		var started bool
+
+		for p := range input {
+			if started && p == last {
+				if prog.config.Strict {
+					errs <- fmt.Errorf("failed to stream from tar: %w: duplicate entry %q", errNonCanonicalTarPath, p)
+
+					return
+				}
+
+				continue
+			}
+			started, last = true, p
+
+			select {
+			case paths <- p:
+			case <-ctx.Done():
+				errs <- fmt.Errorf("failed to stream from tar: %w", ctx.Err())
+
+				return
+			}
+		}
+
+		for err := range inputErrs {
+			if err != nil {
+				errs <- err
+
+				return
+			}
+		}
+	}()
+
+	return paths, errs
+}
+
+// comparePaths compares two paths in the order of sorted path streams, which
+// is lexicographic, or case-insensitive with [ProgramConfig.IgnoreCase] (so
+// that paths differing only by case are considered equal by comparisons).
+func (prog *Program) comparePaths(a string, b string) int {
+	if prog.config.IgnoreCase {
+		return compareFold(a, b)
 	}
 
-	return extsortStrings(ctx, paths, errs, prog.extSortConfig)
+	return strings.Compare(a, b)
+}
+
+// compareFold compares two strings case-insensitively (by their lower case),
+// without allocating any lower case copies (as it is called for every sort).
+func compareFold(a string, b string) int {
+	for a != "" && b != "" {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+
+		if la, lb := unicode.ToLower(ra), unicode.ToLower(rb); la != lb {
+			return cmp.Compare(la, lb)
+		}
+
+		a, b = a[na:], b[nb:]
+	}
+
+	return cmp.Compare(len(a), len(b))
 }
 
 // extsortStrings wraps [extsort.Strings] for internal use.
@@ -299,16 +1357,54 @@ func (prog *Program) tarPathStream(ctx context.Context, path string, sort bool,
 //
 // Do note that only the first error observed from these sources is sent downstream.
 func extsortStrings(ctx context.Context, input <-chan string, extErrs <-chan error, config *extsort.Config) (<-chan string, <-chan error) {
-	sorter, sorterOut, sorterErrs := extsort.Strings(input, config)
+	return extsortStringsFunc(ctx, input, extErrs, config, strings.Compare)
+}
+
+// extsortStringsFunc is a variant of [extsortStrings] sorting by the order of
+// the compare function (as for [strings.Compare]), rather than lexicographically.
+func extsortStringsFunc(ctx context.Context, input <-chan string, extErrs <-chan error, config *extsort.Config, compare func(a, b string) int) (<-chan string, <-chan error) {
+	progress := progressFrom(ctx)
+
+	// The serialization only happens for the chunks spilled to temporary files.
+	// Both directions observe cancellation per record, so that the spilling and
+	// merging of large chunks stop within a record, rather than at their end.
+	var onDisk atomic.Int64
+	toBytes := func(s string) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		progress.addSpilled(len(s))
+		onDisk.Add(int64(len(s)))
+
+		return stringToBytes(s)
+	}
+	fromBytes := func(d []byte) (string, error) {
+		if err := ctx.Err(); err != nil {
+			return "", err //nolint:wrapcheck
+		}
+
+		return stringFromBytes(d)
+	}
+
+	sorter, sorterOut, sorterErrs := extsort.Generic(input, fromBytes, toBytes, compare, config)
 
 	if sorter != nil {
-		go sorter.Sort(ctx)
+		go func() {
+			defer recoverWorker(ctx)
+
+			sorter.Sort(ctx)
+		}()
 	}
 
 	mergedErrs := make(chan error, 1)
 	go func() {
 		defer close(mergedErrs)
 
+		// The temporary files of the sorter are removed once it is done merging.
+		defer func() { progress.releaseSpilled(onDisk.Load()) }()
+		defer recoverWorker(ctx)
+
 		for extErrs != nil || sorterErrs != nil {
 			select {
 			case err, ok := <-extErrs:
@@ -332,3 +1428,20 @@ func extsortStrings(ctx context.Context, input <-chan string, extErrs <-chan err
 
 	return sorterOut, mergedErrs
 }
+
+// stringFromBytes returns a record read back from a temporary file as a string,
+// without copying it, as [extsort] reads each record into a newly allocated slice
+// of its own (which it neither modifies nor keeps after passing it in here).
+func stringFromBytes(d []byte) (string, error) {
+	if len(d) == 0 {
+		return "", nil
+	}
+
+	return unsafe.String(&d[0], len(d)), nil
+}
+
+// stringToBytes returns a record to be written to a temporary file as bytes,
+// without copying it, as [extsort] only ever writes the bytes (never modifying them).
+func stringToBytes(s string) ([]byte, error) {
+	return unsafe.Slice(unsafe.StringData(s), len(s)), nil
+}
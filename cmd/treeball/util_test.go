@@ -5,11 +5,15 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"testing"
 
+	"github.com/lanrat/extsort"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
@@ -22,6 +26,38 @@ func (errorWriter) Write(p []byte) (int, error) {
 	return 0, errors.New("simulated write failure")
 }
 
+// A helper function for tests to create a tree on an in-memory filesystem,
+// with empty files at the paths (or directories, for those with a trailing slash).
+func newTreeFs(t *testing.T, paths ...string) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+
+	for _, p := range paths {
+		if strings.HasSuffix(p, "/") {
+			require.NoError(t, fs.MkdirAll(p, 0o755))
+
+			continue
+		}
+		require.NoError(t, afero.WriteFile(fs, p, nil, 0o644))
+	}
+
+	return fs
+}
+
+// A helper function for tests to create tarballs (of the entry names) on an in-memory filesystem.
+func newArchiveFs(t *testing.T, archives map[string][]string) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+
+	for p, names := range archives {
+		require.NoError(t, afero.WriteFile(fs, p, createTar(names), 0o644))
+	}
+
+	return fs
+}
+
 // Expectation: The function should stream paths from a directory using fsPathStream.
 func Test_Program_multiPathStream_Dir_Success(t *testing.T) {
 	fs := afero.NewMemMapFs()
@@ -30,7 +66,7 @@ func Test_Program_multiPathStream_Dir_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/project/a.txt", []byte("a"), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/project/assets/b.txt", []byte("b"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs, err := prog.multiPathStream(t.Context(), "/project", true, []string{"assets/b.txt"})
 
 	require.NoError(t, err)
@@ -56,7 +92,7 @@ func Test_Program_multiPathStream_File_Success(t *testing.T) {
 	tarData := createTar([]string{"alpha.txt", "zeta/", "zeta/beta.txt"})
 	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tarData, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs, err := prog.multiPathStream(t.Context(), "/archive.tar.gz", true, nil)
 
 	require.NoError(t, err)
@@ -79,7 +115,7 @@ func Test_Program_multiPathStream_File_Success(t *testing.T) {
 func Test_Program_multiPathStream_Stat_Error(t *testing.T) {
 	fs := afero.NewMemMapFs()
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs, err := prog.multiPathStream(t.Context(), "/missing", false, nil)
 
 	require.Error(t, err)
@@ -96,7 +132,7 @@ func Test_Program_tarPathStream_Sorted_Success(t *testing.T) {
 	tarData := createTar([]string{"z.txt", "b/", "b/c.txt"})
 	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tarData, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", true, nil)
 
 	got := make([]string, 0, len(paths))
@@ -118,7 +154,7 @@ func Test_Program_tarPathStream_Unsorted_Success(t *testing.T) {
 	tarData := createTar([]string{"z.txt", "b/", "b/c.txt"})
 	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tarData, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", false, nil)
 
 	got := make([]string, 0, len(paths))
@@ -133,6 +169,87 @@ func Test_Program_tarPathStream_Unsorted_Success(t *testing.T) {
 	require.Equal(t, []string{"z.txt", "b/", "b/c.txt"}, got)
 }
 
+// Expecation: The entries of a foreign tarball should be normalized, with any duplicates removed.
+func Test_Program_tarPathStream_Foreign_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	longName := "./" + strings.Repeat("d", 120) + "/f.txt"
+
+	tarData := createForeignTar([]*tar.Header{
+		{Name: "pax_global_header", Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": "x"}},
+		{Name: "./", Typeflag: tar.TypeDir},
+		{Name: "./b/", Typeflag: tar.TypeDir},
+		{Name: "./b/c.txt", Typeflag: tar.TypeReg},
+		{Name: "/z.txt", Typeflag: tar.TypeReg},
+		{Name: longName, Typeflag: tar.TypeReg, Format: tar.FormatGNU},
+		{Name: "./b/c.txt", Typeflag: tar.TypeReg},
+	})
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tarData, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", true, nil)
+
+	got := make([]string, 0, len(paths))
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"b/", "b/c.txt", longName[2:], "z.txt"}, got)
+}
+
+// Expecation: The channels should contain the error of a non-canonical entry with strict mode.
+func Test_Program_tarPathStream_Strict_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	tarData := createForeignTar([]*tar.Header{
+		{Name: "b/", Typeflag: tar.TypeDir},
+		{Name: "./b/c.txt", Typeflag: tar.TypeReg},
+	})
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tarData, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Strict: true})
+	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", true, nil)
+
+	for range paths {
+	}
+
+	var gotErr error
+	for err := range errs {
+		if err != nil {
+			gotErr = err
+		}
+	}
+
+	require.ErrorIs(t, gotErr, errNonCanonicalTarPath)
+}
+
+// Expecation: The channels should contain the error of a duplicate entry with strict mode.
+func Test_Program_tarPathStream_StrictDuplicate_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt", "b.txt", "a.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{Strict: true})
+	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", true, nil)
+
+	for range paths {
+	}
+
+	var gotErr error
+	for err := range errs {
+		if err != nil {
+			gotErr = err
+		}
+	}
+
+	require.ErrorIs(t, gotErr, errNonCanonicalTarPath)
+	require.ErrorContains(t, gotErr, "duplicate entry")
+}
+
 // Expecation: The channels should contain the correct error and no paths.
 func Test_Program_tarPathStream_Open_Error(t *testing.T) {
 	baseFs := afero.NewMemMapFs()
@@ -141,7 +258,7 @@ func Test_Program_tarPathStream_Open_Error(t *testing.T) {
 
 	fs := errorFs{Fs: baseFs}
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", false, nil)
 
 	for range paths {
@@ -157,13 +274,125 @@ func Test_Program_tarPathStream_Open_Error(t *testing.T) {
 	}
 }
 
+// Expectation: A plain (uncompressed) tarball should be streamed like a compressed one.
+func Test_Program_tarPathStream_PlainTar_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"z.txt", "b/", "b/c.txt"} {
+		require.NoError(t, writeDummyFile(tw, name, strings.HasSuffix(name, "/"), tar.FormatUnknown))
+	}
+	require.NoError(t, tw.Close())
+
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar", buf.Bytes(), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar", true, nil)
+
+	got := make([]string, 0, len(paths))
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"b/", "b/c.txt", "z.txt"}, got)
+}
+
+// Expectation: Concatenated tarballs (also as gzip members) should be streamed as one archive.
+func Test_Program_tarPathStream_Concatenated_Table(t *testing.T) {
+	plainTar := func(names ...string) []byte {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, name := range names {
+			require.NoError(t, writeDummyFile(tw, name, strings.HasSuffix(name, "/"), tar.FormatUnknown))
+		}
+		require.NoError(t, tw.Close())
+
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Gzip members", slices.Concat(createTar([]string{"a.txt", "b/"}), createTar([]string{"b/c.txt"}), createTar([]string{"d.txt"}))},
+		{"Plain tarballs", slices.Concat(plainTar("a.txt", "b/"), plainTar("b/c.txt"), plainTar("d.txt"))},
+		{"Empty tarball between", slices.Concat(createTar([]string{"a.txt", "b/"}), createTar(nil), createTar([]string{"b/c.txt", "d.txt"}))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tt.data, 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+			paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", false, nil)
+
+			got := make([]string, 0, len(paths))
+			for p := range paths {
+				got = append(got, p)
+			}
+
+			for err := range errs {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, []string{"a.txt", "b/", "b/c.txt", "d.txt"}, got)
+		})
+	}
+}
+
+// Expectation: Any data following the trailer that is not a further tarball should be ignored (unlike by 'verify').
+func Test_Program_tarPathStream_TrailingData_Success(t *testing.T) {
+	var garbage bytes.Buffer
+	gz := gzip.NewWriter(&garbage)
+	_, err := gz.Write(bytes.Repeat([]byte("garbage!"), 64))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"After the gzip stream", slices.Concat(createTar([]string{"a.txt", "b/"}), []byte("trailing garbage"))},
+		{"Within the gzip stream", slices.Concat(createTar([]string{"a.txt", "b/"}), garbage.Bytes())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tt.data, 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+			paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", false, nil)
+
+			got := make([]string, 0, len(paths))
+			for p := range paths {
+				got = append(got, p)
+			}
+
+			for err := range errs {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, []string{"a.txt", "b/"}, got)
+
+			require.ErrorIs(t, prog.Verify(t.Context(), "/archive.tar.gz"), ErrArchiveCorrupt)
+		})
+	}
+}
+
 // Expecation: The channels should contain the correct error and no paths.
 func Test_Program_tarPathStream_GzipDecode_Error(t *testing.T) {
 	fs := afero.NewMemMapFs()
 
-	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", []byte("not a gzip file"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", []byte("\x1f\x8bnot a gzip file"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", false, nil)
 
 	for range paths {
@@ -193,7 +422,7 @@ func Test_Program_tarPathStream_TarDecode_Error(t *testing.T) {
 	fs := afero.NewMemMapFs()
 	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", buf.Bytes(), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", false, nil)
 
 	for range paths {
@@ -219,7 +448,7 @@ func Test_Program_tarPathStream_CtxCancel_Error(t *testing.T) {
 	ctx, cancel := context.WithCancel(t.Context())
 	cancel()
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs := prog.tarPathStream(ctx, "/archive.tar.gz", false, nil)
 
 	for range paths {
@@ -241,7 +470,7 @@ func Test_Program_tarPathStream_InvalidExcludePattern_Error(t *testing.T) {
 	tarData := createTar([]string{"foo.txt"})
 	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tarData, 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", false, []string{"invalid["})
 
 	for range paths {
@@ -265,8 +494,8 @@ func Test_Program_fsPathStream_Sorted_Success(t *testing.T) {
 	require.NoError(t, afero.WriteFile(fs, "/testdir/a.txt", []byte("a"), 0o644))
 	require.NoError(t, afero.WriteFile(fs, "/testdir/subdir/b.txt", []byte("b"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
-	paths, errs := prog.fsPathStream(t.Context(), "/testdir", true, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.fsPathStream(t.Context(), "/testdir", "", true, nil)
 
 	got := make([]string, 0, len(paths))
 	for p := range paths {
@@ -286,10 +515,10 @@ func Test_Program_fsPathStream_WalkDir_Error(t *testing.T) {
 
 	require.NoError(t, afero.WriteFile(fs, "/somefile", []byte("data"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	prog.fsWalker = errorWalker{}
 
-	paths, errs := prog.fsPathStream(t.Context(), "/somefile", false, nil)
+	paths, errs := prog.fsPathStream(t.Context(), "/somefile", "", false, nil)
 
 	for range paths {
 		t.Fatal("should not emit paths")
@@ -314,8 +543,8 @@ func Test_Program_fsPathStream_CtxCancel_Error(t *testing.T) {
 	ctx, cancel := context.WithCancel(t.Context())
 	cancel()
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
-	paths, errs := prog.fsPathStream(ctx, "/cancel", false, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.fsPathStream(ctx, "/cancel", "", false, nil)
 
 	for range paths {
 		t.Fatal("should not emit paths")
@@ -333,8 +562,8 @@ func Test_Program_fsPathStream_InvalidExcludePattern_Error(t *testing.T) {
 	require.NoError(t, fs.MkdirAll("/data", 0o755))
 	require.NoError(t, afero.WriteFile(fs, "/data/file.txt", []byte("x"), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
-	paths, errs := prog.fsPathStream(t.Context(), "/data", false, []string{"invalid["})
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.fsPathStream(t.Context(), "/data", "", false, []string{"invalid["})
 
 	for range paths {
 		t.Fatal("should not emit any paths")
@@ -353,7 +582,7 @@ func Test_Program_fsPathStream_InvalidExcludePattern_Error(t *testing.T) {
 func Test_Program_mergeExcludes_SliceOnly_Success(t *testing.T) {
 	fs := afero.NewMemMapFs()
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	result, err := prog.mergeExcludes([]string{"foo", "bar"}, "")
 
 	require.NoError(t, err)
@@ -367,7 +596,7 @@ func Test_Program_mergeExcludes_FileOnly_Success(t *testing.T) {
 	content := "alpha\nbeta\n"
 	require.NoError(t, afero.WriteFile(fs, "/excludes.txt", []byte(content), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	result, err := prog.mergeExcludes(nil, "/excludes.txt")
 
 	require.NoError(t, err)
@@ -381,7 +610,7 @@ func Test_Program_mergeExcludes_FileAndSlice_Success(t *testing.T) {
 	content := "one\ntwo\n"
 	require.NoError(t, afero.WriteFile(fs, "/ex.txt", []byte(content), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	result, err := prog.mergeExcludes([]string{"three", "four"}, "/ex.txt")
 
 	require.NoError(t, err)
@@ -401,7 +630,7 @@ bar
 `
 	require.NoError(t, afero.WriteFile(fs, "/ignore.txt", []byte(content), 0o644))
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	result, err := prog.mergeExcludes(nil, "/ignore.txt")
 
 	require.NoError(t, err)
@@ -412,7 +641,7 @@ bar
 func Test_Program_mergeExcludes_NoExcludes_Success(t *testing.T) {
 	fs := afero.NewMemMapFs()
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	result, err := prog.mergeExcludes(nil, "")
 
 	require.NoError(t, err)
@@ -424,13 +653,72 @@ func Test_Program_mergeExcludes_NoExcludes_Success(t *testing.T) {
 func Test_Program_mergeExcludes_ExcludeFileMissing_Error(t *testing.T) {
 	fs := afero.NewMemMapFs()
 
-	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil)
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
 	_, err := prog.mergeExcludes(nil, "/missing.txt")
 
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "failed to open exclude file")
 }
 
+// Expectation: Should parse include files just as exclude files, naming them in any errors.
+func Test_mergePatterns_Includes_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/includes.txt", []byte("# videos\n**/*.mkv\n\n  docs/  \n"), 0o644))
+
+	result, err := mergePatterns(fs, "include", []string{"*.txt"}, "/includes.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"**/*.mkv", "docs/", "*.txt"}, result)
+
+	_, err = mergePatterns(fs, "include", nil, "/missing.txt")
+	require.ErrorContains(t, err, "failed to open include file")
+}
+
+// Expectation: Files should be included if they or any of their parent directories match.
+func Test_isIncluded_Table(t *testing.T) {
+	tests := []struct {
+		path     string
+		includes []string
+		expected bool
+	}{
+		{"a.mkv", []string{"**/*.mkv"}, true},
+		{"a/b/c.mkv", []string{"**/*.mkv"}, true},
+		{"a.txt", []string{"**/*.mkv"}, false},
+		{"docs/a/b.txt", []string{"docs"}, true},
+		{"docs/a/b.txt", []string{"docs/"}, true},
+		{"docs", []string{"docs/"}, false},
+		{"other/docs.txt", []string{"docs"}, false},
+		{"a/docs/b.txt", []string{"**/docs"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			included, err := isIncluded(tt.path, doublestarMatcher(tt.includes))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, included)
+		})
+	}
+
+	_, err := isIncluded("a.txt", doublestarMatcher{"a["})
+	require.ErrorContains(t, err, "invalid include pattern")
+}
+
+// Expectation: Only the files matching the includes should be archived, along with all directories.
+func Test_Program_Create_Includes_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	for _, p := range []string{"/src/a.MKV", "/src/a.txt", "/src/docs/b.txt", "/src/x/y/c.mkv", "/src/x/z.txt"} {
+		require.NoError(t, afero.WriteFile(fs, p, nil, 0o644))
+	}
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Includes: []string{"**/*.mkv", "docs"}, IgnoreCase: true})
+	require.NoError(t, prog.Create(t.Context(), "/src", "/out.tar.gz", []string{"docs/b.txt"}))
+
+	require.Equal(t, "a.MKV\ndocs\nx\nx/y\nx/y/c.mkv\n", stdoutBuf.String())
+}
+
 // Expectation: The tar buffer should contain the appropriate files and folders.
 func Test_writeDummyFile_Success(t *testing.T) {
 	var buf bytes.Buffer
@@ -438,8 +726,8 @@ func Test_writeDummyFile_Success(t *testing.T) {
 	tw := tar.NewWriter(&buf)
 	require.NotNil(t, tw)
 
-	require.NoError(t, writeDummyFile(tw, "foo.txt", false))
-	require.NoError(t, writeDummyFile(tw, "bar", true))
+	require.NoError(t, writeDummyFile(tw, "foo.txt", false, tar.FormatUnknown))
+	require.NoError(t, writeDummyFile(tw, "bar", true, tar.FormatUnknown))
 	require.NoError(t, tw.Close())
 
 	tr := tar.NewReader(&buf)
@@ -473,12 +761,83 @@ func Test_writeDummyFile_Success(t *testing.T) {
 // Expectation: The function should return the correct error on header write failure.
 func Test_writeDummyFile_WriteHeader_Error(t *testing.T) {
 	tw := tar.NewWriter(errorWriter{})
-	err := writeDummyFile(tw, "fail.txt", false)
+	err := writeDummyFile(tw, "fail.txt", false, tar.FormatUnknown)
 
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "header")
 }
 
+// Expectation: The headers should be written in the given format, failing if unrepresentable.
+func Test_writeDummyFile_Formats_Table(t *testing.T) {
+	longUnicode := strings.Repeat("ü", 60) + "/" + strings.Repeat("ß", 80) + ".txt"
+
+	tests := []struct {
+		name    string
+		format  tar.Format
+		path    string
+		want    tar.Format
+		wantErr bool
+	}{
+		{"Auto with short path", tar.FormatUnknown, "a.txt", tar.FormatUSTAR, false},
+		{"Auto with long unicode path", tar.FormatUnknown, longUnicode, tar.FormatPAX, false},
+		{"USTAR with short path", tar.FormatUSTAR, "a.txt", tar.FormatUSTAR, false},
+		{"USTAR with long unicode path", tar.FormatUSTAR, longUnicode, tar.FormatUnknown, true},
+		{"PAX with long unicode path", tar.FormatPAX, longUnicode, tar.FormatPAX, false},
+		{"GNU with long unicode path", tar.FormatGNU, longUnicode, tar.FormatGNU, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			tw := tar.NewWriter(&buf)
+
+			err := writeDummyFile(tw, tt.path, false, tt.format)
+			if tt.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, tw.Close())
+
+			hdr, err := tar.NewReader(&buf).Next()
+			require.NoError(t, err)
+			require.Equal(t, tt.path, hdr.Name)
+			require.Equal(t, tt.want, hdr.Format)
+		})
+	}
+}
+
+// Expectation: The format names should be parsed into their respective formats.
+func Test_parseTarFormat_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    tar.Format
+		wantErr bool
+	}{
+		{"auto", tar.FormatUnknown, false},
+		{"", tar.FormatUnknown, false},
+		{"ustar", tar.FormatUSTAR, false},
+		{"PAX", tar.FormatPAX, false},
+		{"gnu", tar.FormatGNU, false},
+		{"v7", tar.FormatUnknown, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTarFormat(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidTarFormat)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
 // Expectation: The channels should contain the correct ordered paths and no errors.
 func Test_extsortStrings_Success(t *testing.T) {
 	in := make(chan string, 3)
@@ -504,6 +863,65 @@ func Test_extsortStrings_Success(t *testing.T) {
 	require.Equal(t, []string{"a", "b", "c"}, got)
 }
 
+// Expectation: The channels should contain the paths ordered by the compare function (also when spilling to disk).
+func Test_extsortStringsFunc_Success(t *testing.T) {
+	in := make(chan string, 5)
+	in <- "file10"
+	in <- "file2"
+	in <- "file1"
+	in <- "file20"
+	in <- "file3"
+	close(in)
+
+	extErrs := make(chan error)
+	close(extErrs)
+
+	config := extSortConfigDefault
+	config.ChunkSize = 2
+	config.TempFilesDir = t.TempDir()
+
+	out, errs := extsortStringsFunc(t.Context(), in, extErrs, &config, compareVersion)
+
+	got := make([]string, 0, len(out))
+	for p := range out {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"file1", "file2", "file3", "file10", "file20"}, got)
+}
+
+// Expectation: The records should be read back unchanged from disk, including any empty ones.
+func Test_extsortStrings_SpilledRecords_Success(t *testing.T) {
+	want := []string{"", "a/", "a/b\x00mode=644", "b", strings.Repeat("c", 4*pathArenaMaxString)}
+
+	in := make(chan string, len(want))
+	for _, p := range slices.Backward(want) {
+		in <- p
+	}
+	close(in)
+
+	config := extSortConfigDefault
+	config.ChunkSize = 2
+	config.TempFilesDir = t.TempDir()
+
+	out, errs := extsortStrings(t.Context(), in, nil, &config)
+
+	got := make([]string, 0, len(want))
+	for p := range out {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, want, got)
+}
+
 // Expectation: The channels should contain the correct error and no paths.
 func Test_extsortStrings_ExternalChannel_Error(t *testing.T) {
 	in := make(chan string)
@@ -705,3 +1123,552 @@ func Test_isExcluded_InvalidExcludePattern_Error(t *testing.T) {
 	require.ErrorContains(t, err, "pattern")
 	require.False(t, result)
 }
+
+// Expectation: The regular expressions should be matched against slash-separated paths.
+func Test_isRegexExcluded_Table(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		isDir    bool
+		regexes  []string
+		expected bool
+	}{
+		{"No regexes", "a.txt", false, nil, false},
+		{"Simple match", "a.txt", false, []string{`\.txt$`}, true},
+		{"Simple no match", "a.txt", false, []string{`\.go$`}, false},
+		{"Numeric range", "season_07/ep.mkv", false, []string{`^season_0[5-9]/`}, true},
+		{"Numeric range no match", "season_03/ep.mkv", false, []string{`^season_0[5-9]/`}, false},
+		{"Dir with trailing slash", "vendor", true, []string{`^vendor/$`}, true},
+		{"File without trailing slash", "vendor", false, []string{`^vendor/$`}, false},
+		{"Across components", "a/tmp/b/c.log", false, []string{`(^|/)tmp/.*\.log$`}, true},
+		{"Trailing slash input", "dir/", true, []string{`^dir/$`}, true},
+		{"Second regex matches", "b.bak", false, []string{`\.txt$`, `\.bak$`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regexes, err := compileRegexes(tt.regexes, false)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, isRegexExcluded(tt.path, tt.isDir, regexes))
+		})
+	}
+}
+
+// Expectation: An invalid regular expression should produce an error.
+func Test_compileRegexes_Invalid_Error(t *testing.T) {
+	_, err := compileRegexes([]string{`valid`, `(invalid`}, false)
+
+	require.Error(t, err)
+	require.ErrorContains(t, err, "exclude regex")
+}
+
+// Expectation: The regular expressions should be applied to tar streams.
+func Test_Program_tarPathStream_ExcludeRegex_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	tarData := createTar([]string{"a.txt", "b/", "b/c_10.txt", "b/c_9.txt"})
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tarData, 0o644))
+
+	regexes, err := compileRegexes([]string{`_[0-9]{2,}\.txt$`}, false)
+	require.NoError(t, err)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{ExcludeRegexes: regexes})
+	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", true, nil)
+
+	got := make([]string, 0, len(paths))
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"a.txt", "b/", "b/c_9.txt"}, got)
+}
+
+// Expectation: The regular expressions should be applied to filesystem streams, skipping excluded directories.
+func Test_Program_fsPathStream_ExcludeRegex_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/cache/b.txt", []byte("b"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/cache.txt", []byte("c"), 0o644))
+
+	regexes, err := compileRegexes([]string{`^cache/$`}, false)
+	require.NoError(t, err)
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{ExcludeRegexes: regexes})
+	paths, errs := prog.fsPathStream(t.Context(), "/src", "", true, nil)
+
+	got := make([]string, 0, len(paths))
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"a.txt", "cache.txt"}, got)
+}
+
+// Expectation: The excludes should be matched case-insensitively when enabled.
+func Test_Program_isExcluded_IgnoreCase_Success(t *testing.T) {
+	regexes, err := compileRegexes([]string{`^Extras/`}, true)
+	require.NoError(t, err)
+
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{IgnoreCase: true, ExcludeRegexes: regexes})
+	excludes := prog.excludeMatcher([]string{"**/*.MKV", "Sample/"})
+
+	for _, tt := range []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"Movies/Film.mkv", false, true},
+		{"Movies/Film.MkV", false, true},
+		{"Movies/Film.mp4", false, false},
+		{"sample", true, true},
+		{"SAMPLE", false, false},
+		{"extras/a.txt", false, true},
+	} {
+		excluded, err := prog.isExcluded(tt.path, tt.isDir, excludes)
+		require.NoError(t, err)
+		require.Equalf(t, tt.expected, excluded, "path=%q", tt.path)
+	}
+}
+
+// Expectation: The excludes should be matched case-sensitively by default.
+func Test_Program_isExcluded_CaseSensitive_Success(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+	excludes := prog.excludeMatcher([]string{"**/*.MKV"})
+
+	excluded, err := prog.isExcluded("Movies/Film.mkv", false, excludes)
+	require.NoError(t, err)
+	require.False(t, excluded)
+
+	excluded, err = prog.isExcluded("Movies/Film.MKV", false, excludes)
+	require.NoError(t, err)
+	require.True(t, excluded)
+}
+
+// Expectation: The excludes should be applied case-insensitively to tar streams when enabled.
+func Test_Program_tarPathStream_IgnoreCase_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	tarData := createTar([]string{"a.MKV", "b.mkv", "c.txt"})
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tarData, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{IgnoreCase: true})
+	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", true, []string{"*.mkv"})
+
+	got := make([]string, 0, len(paths))
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"c.txt"}, got)
+}
+
+// Expectation: The writer should pass through writes until the context is cancelled.
+func Test_contextWriter_CtxCancel_Error(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+
+	var buf bytes.Buffer
+
+	cw := contextWriter{ctx: ctx, w: &buf}
+
+	n, err := cw.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	cancel()
+
+	n, err = cw.Write([]byte("def"))
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, n)
+	require.Equal(t, "abc", buf.String())
+}
+
+// Expectation: The merging of spilled chunks should stop with an error upon context cancellation.
+func Test_extsortStrings_SpilledCtxCancel_Error(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	in := make(chan string, 100)
+	for i := range 100 {
+		in <- fmt.Sprintf("file%03d", 99-i)
+	}
+	close(in)
+
+	extErrs := make(chan error)
+	close(extErrs)
+
+	config := extSortConfigDefault
+	config.ChunkSize = 10
+	config.SortedChanBuffSize = 1
+	config.TempFilesDir = t.TempDir()
+
+	out, errs := extsortStrings(ctx, in, extErrs, &config)
+
+	require.Equal(t, "file000", <-out)
+	cancel()
+
+	var got int
+	for range out {
+		got++
+	}
+
+	var gotErr error
+	for err := range errs {
+		gotErr = errors.Join(gotErr, err)
+	}
+
+	require.ErrorIs(t, gotErr, context.Canceled)
+	require.Less(t, got, 99)
+}
+
+// Expectation: The reader should pass through reads until the context is cancelled.
+func Test_contextReader_CtxCancel_Error(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+
+	cr := contextReader{ctx: ctx, r: strings.NewReader("abcdef")}
+
+	buf := make([]byte, 3)
+	n, err := cr.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(buf[:n]))
+
+	cancel()
+
+	n, err = cr.Read(buf)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, n)
+}
+
+// Expectation: A cancelled stream should terminate even if nobody is consuming its paths.
+func Test_Program_tarPathStream_CtxCancelBlocked_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	entries := make([]string, 0, 3*tarStreamBuffer)
+	for i := range 3 * tarStreamBuffer {
+		entries = append(entries, fmt.Sprintf("file_%05d.txt", i))
+	}
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar(entries), 0o644))
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.tarPathStream(ctx, "/archive.tar.gz", false, nil)
+
+	<-paths
+	cancel()
+
+	for err := range errs {
+		require.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+// Expectation: A cancelled stream should terminate even if nobody is consuming its paths.
+func Test_Program_fsPathStream_CtxCancelBlocked_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	for i := range 3 * fsStreamBuffer {
+		require.NoError(t, afero.WriteFile(fs, fmt.Sprintf("/src/file_%05d.txt", i), nil, 0o644))
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.fsPathStream(ctx, "/src", "", false, nil)
+
+	<-paths
+	cancel()
+
+	for err := range errs {
+		require.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+// Expectation: The depths of the paths should be calculated correctly.
+func Test_pathDepth_Table(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"", 0},
+		{".", 0},
+		{"a.txt", 1},
+		{"dir/", 1},
+		{"dir/a.txt", 2},
+		{"dir/sub/", 2},
+		{"./dir/sub/a.txt", 3},
+		{"/dir/sub/a.txt", 3},
+	}
+
+	for _, tt := range tests {
+		require.Equalf(t, tt.expected, pathDepth(tt.path), "path=%q", tt.path)
+	}
+}
+
+// Expectation: Only paths up to the maximum depth should be streamed from a tar.
+func Test_Program_tarPathStream_MaxDepth_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	tarData := createTar([]string{"a.txt", "b/", "b/c.txt", "b/d/", "b/d/e.txt"})
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", tarData, 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{MaxDepth: 2})
+	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", true, nil)
+
+	got := make([]string, 0, len(paths))
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"a.txt", "b/", "b/c.txt", "b/d/"}, got)
+}
+
+// Expectation: Only paths up to the maximum depth should be streamed from a filesystem.
+func Test_Program_fsPathStream_MaxDepth_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	require.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/c.txt", []byte("c"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/src/b/d/e.txt", []byte("e"), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, &ProgramConfig{MaxDepth: 1})
+	paths, errs := prog.fsPathStream(t.Context(), "/src", "", true, nil)
+
+	got := make([]string, 0, len(paths))
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"a.txt", "b/"}, got)
+}
+
+// Expectation: A negative maximum depth should be rejected by the commands, rather than taken as unlimited.
+func Test_CLI_MaxDepth_Negative_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", createTar([]string{"a.txt"}), 0o644))
+
+	for _, args := range [][]string{
+		{"list", "/archive.tar.gz", "--max-depth=-1"},
+		{"create", "/src", "/out.tar.gz", "--max-depth=-1"},
+	} {
+		cmd := newRootCmd(t.Context(), fs, io.Discard, io.Discard)
+		cmd.SetArgs(args)
+		require.ErrorIs(t, cmd.Execute(), errInvalidMaxDepth, "args=%v", args)
+	}
+}
+
+// Expectation: A corrupt tar header should be reported with its entry context.
+func Test_Program_tarPathStream_CorruptHeader_StreamError(t *testing.T) {
+	var raw bytes.Buffer
+	tw := tar.NewWriter(&raw)
+
+	for _, name := range []string{"a.txt", "b/", "b/x.txt"} {
+		require.NoError(t, writeDummyFile(tw, name, strings.HasSuffix(name, "/"), tar.FormatUnknown))
+	}
+	require.NoError(t, tw.Close())
+
+	data := raw.Bytes()
+	copy(data[2*512+148:], "garbage!") // Checksum field of the third header
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+
+	_, err := gz.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar.gz", buf.Bytes(), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	paths, errs := prog.tarPathStream(t.Context(), "/archive.tar.gz", false, nil)
+
+	var got []string
+	for p := range paths {
+		got = append(got, p)
+	}
+	require.Equal(t, []string{"a.txt", "b/"}, got)
+
+	err = <-errs
+	require.ErrorIs(t, err, tar.ErrHeader)
+
+	var serr *StreamError
+	require.ErrorAs(t, err, &serr)
+	require.Equal(t, int64(2), serr.Index)
+	require.Empty(t, serr.Path)
+	require.Equal(t, "b/", serr.PrevPath)
+	require.Equal(t, int64(2*512), serr.Offset)
+	require.Positive(t, serr.CompressedOffset)
+	require.ErrorContains(t, err, `entry #2 (after "b/") past offset 1024`)
+	require.ErrorContains(t, err, "(compressed: approx. ")
+}
+
+// Expectation: A walking error should be reported with its entry context.
+func Test_Program_fsPathStream_WalkDir_StreamError(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	prog.fsWalker = errorWalker{}
+
+	paths, errs := prog.fsPathStream(t.Context(), "/somefile", "", false, nil)
+
+	for range paths {
+		t.Fatal("should not emit paths")
+	}
+
+	err := <-errs
+
+	var serr *StreamError
+	require.ErrorAs(t, err, &serr)
+	require.Equal(t, int64(0), serr.Index)
+	require.Equal(t, int64(-1), serr.Offset)
+	require.NotContains(t, err.Error(), "offset")
+}
+
+// Expectation: The error should be marshaled omitting any unknown context.
+func Test_StreamError_MarshalJSON_Success(t *testing.T) {
+	serr := &StreamError{
+		Index: 3, PrevPath: "b/",
+		Offset: 1536, CompressedOffset: -1, Err: tar.ErrHeader,
+	}
+
+	b, err := json.Marshal(serr)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"index":3,"prevPath":"b/","offset":1536,"error":"archive/tar: invalid tar header"}`, string(b))
+}
+
+// Expectation: The strings should be compared case-insensitively.
+func Test_compareFold_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{"Equal", "movie.mkv", "movie.mkv", 0},
+		{"Equal by case", "Movie.mkv", "movie.MKV", 0},
+		{"Equal by unicode case", "Ärger/", "ärger/", 0},
+		{"Smaller ignoring case", "a.txt", "B.txt", -1},
+		{"Larger ignoring case", "b.txt", "A.txt", 1},
+		{"Prefix", "Movie", "movie.mkv", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareFold(tt.a, tt.b)
+
+			switch {
+			case tt.want < 0:
+				require.Negative(t, got)
+			case tt.want > 0:
+				require.Positive(t, got)
+			default:
+				require.Zero(t, got)
+			}
+		})
+	}
+}
+
+// A helper function for benchmarks to drain a stream of paths (failing on any error).
+func drainPathStream(b *testing.B, paths <-chan string, errs <-chan error) int {
+	b.Helper()
+
+	var n int
+	for range paths {
+		n++
+	}
+
+	for err := range errs {
+		require.NoError(b, err)
+	}
+
+	return n
+}
+
+// A helper function for benchmarks to generate paths in (not sorted) walk order.
+func benchPaths(n int) []string {
+	paths := make([]string, 0, n)
+
+	for i := range n {
+		paths = append(paths, fmt.Sprintf("dir%04d/sub%02d/file%06d.txt", (i*7919)%(n/100+1), i%100, i))
+	}
+
+	return paths
+}
+
+// Benchmark: The external sorting of paths, with spilling to temporary files.
+func Benchmark_extsortStrings_Spilled(b *testing.B) {
+	paths := benchPaths(200_000)
+
+	config := extSortConfigDefault
+	config.ChunkSize = 20_000
+	config.TempFilesDir = b.TempDir()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		input := make(chan string, fsStreamBuffer)
+		go func() {
+			defer close(input)
+
+			for _, p := range paths {
+				input <- p
+			}
+		}()
+
+		sorted, errs := extsortStrings(b.Context(), input, nil, &config)
+		require.Equal(b, len(paths), drainPathStream(b, sorted, errs))
+	}
+}
+
+// Benchmark: The sorted streaming of the paths of a directory tree.
+func Benchmark_Program_fsPathStream_Sorted(b *testing.B) {
+	fs := afero.NewMemMapFs()
+
+	for i := range 20_000 {
+		require.NoError(b, afero.WriteFile(fs, fmt.Sprintf("/src/dir%03d/file%06d.txt", i%200, i), nil, 0o644))
+	}
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, &extsort.Config{ChunkSize: 5_000, NumWorkers: 2, ChanBuffSize: 1, SortedChanBuffSize: 1000, TempFilesDir: b.TempDir()}, nil)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		paths, errs := prog.fsPathStream(b.Context(), "/src", "", true, nil)
+		require.Equal(b, 20_200, drainPathStream(b, paths, errs))
+	}
+}
+
+// Benchmark: The sorted streaming of the paths of a tarball.
+func Benchmark_Program_tarPathStream_Sorted(b *testing.B) {
+	fs := afero.NewMemMapFs()
+	require.NoError(b, afero.WriteFile(fs, "/input.tar.gz", createTar(benchPaths(50_000)), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, &extsort.Config{ChunkSize: 10_000, NumWorkers: 2, ChanBuffSize: 1, SortedChanBuffSize: 1000, TempFilesDir: b.TempDir()}, nil)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		paths, errs := prog.tarPathStream(b.Context(), "/input.tar.gz", true, nil)
+		require.Equal(b, 50_000, drainPathStream(b, paths, errs))
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	pgzip "github.com/klauspost/pgzip"
+)
+
+// Verify fully decodes a given tarball, checking it for its integrity.
+//
+// The input parameter specifies the path to the tarball. The entire gzip
+// stream is decoded (verifying its checksums) along with every tar header.
+// Any duplicate entries are printed to standard output, as is the first entry
+// breaking the sorted order; an archive is considered sorted if it is in the
+// order of a filesystem walk (as from [Program.Create]) or in alphabetically
+// sorted order (as from [Program.Recreate]). Any set fields of the gzip header
+// are printed (see [printGzipHeader]), and the amount of entries at the end. The ctx parameter controls early cancellation.
+//
+// An error wrapping [ErrArchiveCorrupt] is returned for corrupt or truncated
+// archives, otherwise one wrapping [ErrArchiveDuplicates] for any duplicate
+// entries, or one wrapping [ErrArchiveUnsorted] for archives out of order.
+func (prog *Program) Verify(ctx context.Context, input string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the streaming in case of an early return.
+
+	progressFrom(ctx).setPhase("verifying %s", input)
+
+	prog, closeRemotes, err := prog.withRemotes(ctx, input)
+	if err != nil {
+		return err
+	}
+	defer closeRemotes()
+
+	paths, errs := prog.readTarPaths(ctx, input, nil, true)
+
+	var entries int64
+	var unsorted string
+
+	ordered := make(chan string, tarStreamBuffer)
+	orderDone := make(chan struct{})
+
+	go func() {
+		defer close(orderDone)
+		defer close(ordered)
+		defer recoverWorker(ctx)
+
+		walkSorted, byteSorted := true, true
+
+		var prev string
+		for p := range paths {
+			if entries > 0 && unsorted == "" {
+				walkSorted = walkSorted && compareTarOrder(prev, p) <= 0
+				byteSorted = byteSorted && prev <= p
+
+				if !walkSorted && !byteSorted {
+					unsorted = fmt.Sprintf("%s (after %s)", p, prev)
+				}
+			}
+
+			entries++
+			prev = p
+
+			select {
+			case ordered <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	sorted, sortErrs := extsortStrings(ctx, ordered, errs, prog.extSortConfig)
+
+	var duplicates int64
+	var last string
+	var lastReported bool
+
+	for p := range sorted {
+		if p != last {
+			last, lastReported = p, false
+
+			continue
+		}
+
+		duplicates++
+
+		if !lastReported {
+			fmt.Fprintf(prog.stdout, "duplicate: %s\n", p)
+			lastReported = true
+		}
+	}
+
+	<-orderDone
+
+	for err := range sortErrs {
+		if err != nil {
+			if isCorruption(err) {
+				return fmt.Errorf("%w: %w", ErrArchiveCorrupt, err)
+			}
+
+			return fmt.Errorf("failure during verify: %w", err)
+		}
+	}
+
+	hdr, err := prog.readGzipHeader(input)
+	if err != nil {
+		return fmt.Errorf("failure during verify: %w", err)
+	}
+	printGzipHeader(prog.stdout, hdr)
+
+	if unsorted != "" {
+		fmt.Fprintf(prog.stdout, "unsorted: %s\n", unsorted)
+	}
+
+	fmt.Fprintf(prog.stdout, "entries: %d\n", entries)
+
+	switch {
+	case duplicates > 0:
+		return fmt.Errorf("%w: %d duplicate entries", ErrArchiveDuplicates, duplicates)
+	case unsorted != "":
+		return fmt.Errorf("%w: %s", ErrArchiveUnsorted, unsorted)
+	}
+
+	return nil
+}
+
+// isCorruption returns if an error of a tar stream originates from the
+// contents of the tarball, rather than from the operation (e.g. cancellation).
+func isCorruption(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var serr *StreamError
+	if errors.As(err, &serr) {
+		return true
+	}
+
+	return errors.Is(err, pgzip.ErrHeader) || errors.Is(err, pgzip.ErrChecksum) ||
+		errors.Is(err, tar.ErrHeader) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
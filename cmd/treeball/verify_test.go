@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: An intact archive in either sorted order should verify successfully.
+func Test_Program_Verify_Success(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+	}{
+		{"Walk order", []string{"a/", "a/z.txt", "a.txt", "b.txt"}},
+		{"Alphabetical order", []string{"a.txt", "a/", "a/z.txt", "b.txt"}},
+		{"Empty archive", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar(tt.entries), 0o644))
+
+			var stdoutBuf bytes.Buffer
+
+			prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+			require.NoError(t, prog.Verify(t.Context(), "/input.tar.gz"))
+
+			require.Contains(t, stdoutBuf.String(), "entries: ")
+			require.NotContains(t, stdoutBuf.String(), "duplicate")
+		})
+	}
+}
+
+// Expectation: Duplicate entries should be reported and raise the appropriate error.
+func Test_Program_Verify_Duplicates_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a.txt", "b.txt", "b.txt", "b.txt", "c.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	err := prog.Verify(t.Context(), "/input.tar.gz")
+
+	require.ErrorIs(t, err, ErrArchiveDuplicates)
+	require.ErrorContains(t, err, "2 duplicate entries")
+	require.Equal(t, "duplicate: b.txt\nentries: 5\n", stdoutBuf.String())
+}
+
+// Expectation: An archive out of sorted order should be reported and raise the appropriate error.
+func Test_Program_Verify_Unsorted_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"a.txt", "c.txt", "b.txt"}), 0o644))
+
+	var stdoutBuf bytes.Buffer
+
+	prog := NewProgram(fs, &stdoutBuf, io.Discard, nil, nil, nil)
+	err := prog.Verify(t.Context(), "/input.tar.gz")
+
+	require.ErrorIs(t, err, ErrArchiveUnsorted)
+	require.Equal(t, "unsorted: b.txt (after c.txt)\nentries: 3\n", stdoutBuf.String())
+}
+
+// Expectation: Duplicate entries should take precedence over an unsorted order.
+func Test_Program_Verify_DuplicatesUnsorted_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", createTar([]string{"b.txt", "a.txt", "b.txt"}), 0o644))
+
+	prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+	err := prog.Verify(t.Context(), "/input.tar.gz")
+
+	require.ErrorIs(t, err, ErrArchiveDuplicates)
+	require.NotErrorIs(t, err, ErrArchiveUnsorted)
+}
+
+// Expectation: A corrupt or truncated archive should raise the appropriate error.
+func Test_Program_Verify_Corrupt_Error(t *testing.T) {
+	data := createTar([]string{"a.txt", "b/", "b/c.txt"})
+
+	checksum := bytes.Clone(data)
+	checksum[len(checksum)-8] ^= 0xFF // The CRC-32 of the gzip trailer.
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Checksum mismatch", checksum},
+		{"Truncated archive", data[:len(data)/2]},
+		{"Truncated trailer", data[:len(data)-4]},
+		{"Trailing data", slices.Concat(data, []byte("trailing garbage"))},
+		{"Not an archive", []byte("not a tarball")},
+		{"Empty file", []byte{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/input.tar.gz", tt.data, 0o644))
+
+			prog := NewProgram(fs, io.Discard, io.Discard, nil, nil, nil)
+			err := prog.Verify(t.Context(), "/input.tar.gz")
+
+			require.ErrorIs(t, err, ErrArchiveCorrupt)
+		})
+	}
+}
+
+// Expectation: A missing archive should be a general failure rather than corruption.
+func Test_Program_Verify_InputMissing_Error(t *testing.T) {
+	prog := NewProgram(afero.NewMemMapFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.Verify(t.Context(), "/input.tar.gz")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrArchiveCorrupt)
+	require.Equal(t, exitCodeFailure, exitCodeFor(err))
+}
+
+// Expectation: The paths of tarballs should be compared in the order of a filesystem walk.
+func Test_compareTarOrder_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{"Equal", "a/b", "a/b", 0},
+		{"Directory before its contents", "a/", "a/b", -1},
+		{"Directory contents before later sibling", "a/z", "a.txt", -1},
+		{"Later sibling after directory", "a.txt", "a/", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareTarOrder(tt.a, tt.b)
+
+			switch {
+			case tt.want < 0:
+				require.Negative(t, got)
+			case tt.want > 0:
+				require.Positive(t, got)
+			default:
+				require.Zero(t, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+var (
+	// Commit is automatically populated by the build process (Makefile).
+	Commit string
+
+	// BuildDate is automatically populated by the build process (Makefile).
+	BuildDate string
+)
+
+// BuildInfo describes a build of the program, so that exactly what is deployed
+// can be identified (as for --version and the 'version' command).
+type BuildInfo struct {
+	Version   string   `json:"version"`   // Version of the program
+	Commit    string   `json:"commit"`    // Hash of the commit of the sources
+	BuildDate string   `json:"buildDate"` // Date of the build (RFC 3339)
+	GoVersion string   `json:"goVersion"` // Version of the Go toolchain of the build
+	Platform  string   `json:"platform"`  // Operating system and architecture of the build
+	Features  []string `json:"features"`  // Names of the optional features compiled in (see Features)
+}
+
+// buildInfo returns the [BuildInfo] of the running program. Any metadata not
+// populated by the build process (with ldflags) is taken from the information
+// embedded by the Go toolchain instead (such as with 'go install'), if any.
+func buildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Features:  []string{},
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		var modified bool
+
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+
+		if modified && Commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = strings.TrimPrefix(bi.Main.Version, "v")
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "devel"
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+
+	for _, f := range Features() {
+		if f.Enabled {
+			info.Features = append(info.Features, f.Name)
+		}
+	}
+
+	return info
+}
+
+// String returns the human-readable description of the build, as printed for
+// --version (following the "treeball version" prefix).
+func (info BuildInfo) String() string {
+	features := strings.Join(info.Features, ", ")
+	if features == "" {
+		features = "none"
+	}
+
+	return fmt.Sprintf("%s\n  commit:    %s\n  built:     %s\n  go:        %s (%s)\n  features:  %s",
+		info.Version, info.Commit, info.BuildDate, info.GoVersion, info.Platform, features)
+}
+
+// printVersion writes the [BuildInfo] to w, either human-readable or as JSON.
+func printVersion(w io.Writer, info BuildInfo, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(info); err != nil {
+			return fmt.Errorf("failed to write version: %w", err)
+		}
+
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "treeball version %s\n", info); err != nil {
+		return fmt.Errorf("failed to write version: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expectation: The build metadata should be populated, preferring that of the build process.
+func Test_buildInfo_Success(t *testing.T) {
+	oldVersion, oldCommit, oldDate := Version, Commit, BuildDate
+	t.Cleanup(func() { Version, Commit, BuildDate = oldVersion, oldCommit, oldDate })
+
+	Version, Commit, BuildDate = "1.2.3", "abcdef0", "2026-01-31T12:00:00Z"
+
+	info := buildInfo()
+	require.Equal(t, "1.2.3", info.Version)
+	require.Equal(t, "abcdef0", info.Commit)
+	require.Equal(t, "2026-01-31T12:00:00Z", info.BuildDate)
+	require.Equal(t, runtime.Version(), info.GoVersion)
+	require.Equal(t, runtime.GOOS+"/"+runtime.GOARCH, info.Platform)
+
+	for _, f := range Features() {
+		require.Equal(t, f.Enabled, slices.Contains(info.Features, f.Name), f.Name)
+	}
+}
+
+// Expectation: Any build metadata not populated should never be left empty.
+func Test_buildInfo_Unpopulated_Success(t *testing.T) {
+	oldVersion, oldCommit, oldDate := Version, Commit, BuildDate
+	t.Cleanup(func() { Version, Commit, BuildDate = oldVersion, oldCommit, oldDate })
+
+	Version, Commit, BuildDate = "", "", ""
+
+	info := buildInfo()
+	require.NotEmpty(t, info.Version)
+	require.NotEmpty(t, info.Commit)
+	require.NotEmpty(t, info.BuildDate)
+	require.NotNil(t, info.Features)
+}
+
+// Expectation: The build metadata should be printed human-readable.
+func Test_printVersion_Text_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	info := BuildInfo{Version: "1.2.3", Commit: "abcdef0", BuildDate: "2026-01-31T12:00:00Z", GoVersion: "go1.25.0", Platform: "linux/amd64", Features: []string{"pager", "s3"}}
+	require.NoError(t, printVersion(&buf, info, false))
+
+	require.Equal(t, "treeball version 1.2.3\n"+
+		"  commit:    abcdef0\n"+
+		"  built:     2026-01-31T12:00:00Z\n"+
+		"  go:        go1.25.0 (linux/amd64)\n"+
+		"  features:  pager, s3\n", buf.String())
+}
+
+// Expectation: The build metadata should be printed as JSON with --json.
+func Test_printVersion_JSON_Success(t *testing.T) {
+	var buf bytes.Buffer
+
+	info := BuildInfo{Version: "1.2.3", Commit: "abcdef0", BuildDate: "2026-01-31T12:00:00Z", GoVersion: "go1.25.0", Platform: "linux/amd64", Features: []string{}}
+	require.NoError(t, printVersion(&buf, info, true))
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	require.Equal(t, "abcdef0", got["commit"])
+	require.Equal(t, "2026-01-31T12:00:00Z", got["buildDate"])
+	require.Equal(t, "go1.25.0", got["goVersion"])
+	require.Equal(t, []any{}, got["features"])
+}
+
+// Expectation: The build metadata should not be printed to a failing writer.
+func Test_printVersion_Write_Error(t *testing.T) {
+	require.Error(t, printVersion(errorWriter{}, buildInfo(), false))
+	require.Error(t, printVersion(errorWriter{}, buildInfo(), true))
+}
+
+// Expectation: The version command and --version should print the same build metadata.
+func Test_CLI_Version_Success(t *testing.T) {
+	var cmdBuf, flagBuf bytes.Buffer
+
+	cmd := newRootCmd(t.Context(), nil, &cmdBuf, io.Discard)
+	cmd.SetArgs([]string{"version"})
+	require.NoError(t, cmd.Execute())
+
+	cmd = newRootCmd(t.Context(), nil, &flagBuf, io.Discard)
+	cmd.SetArgs([]string{"--version"})
+	require.NoError(t, cmd.Execute())
+
+	require.Contains(t, cmdBuf.String(), "treeball version ")
+	require.Contains(t, cmdBuf.String(), "commit:")
+	require.Equal(t, cmdBuf.String(), flagBuf.String())
+}
@@ -0,0 +1,19 @@
+//go:build minimal || no_watch
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const featureWatch = false
+
+var errWatchDisabled = errors.New("watching is not compiled into this build")
+
+// Watch is a stub for builds without the watch feature compiled in.
+// It always returns an error, as the tree cannot be watched in such a build.
+func (prog *Program) Watch(_ context.Context, _ string, _ string, _ time.Duration, _ bool, _ []string) error {
+	return errWatchDisabled
+}
@@ -0,0 +1,257 @@
+//go:build !minimal && !no_watch
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const featureWatch = true
+
+// snapshotTimeFormat is the format of the times within the names of snapshots,
+// which is of millisecond precision, so that even short intervals are unique.
+const snapshotTimeFormat = "20060102T150405.000Z"
+
+// snapshotTempSuffix is the suffix of snapshots (and diffs) being written.
+const snapshotTempSuffix = ".tmp"
+
+var (
+	errInvalidInterval = errors.New("invalid interval")
+	errWatcherClosed   = errors.New("watcher was closed")
+)
+
+// Watch continuously snapshots a directory tree into tarballs, until the ctx
+// is cancelled (upon which a cancellation error is returned).
+//
+// An initial snapshot of the root is created in the outputDir right away,
+// after which the tree is watched for changes (with inotify, kqueue, etc.).
+// Once per interval, a new snapshot is created if any changes happened since
+// the last one, so that the tree is only rescanned when it actually changed.
+// The snapshots are named by their (UTC) time of creation, e.g.
+// snapshot-20250102T150405.000Z.tar.gz, with their paths printed to standard
+// output. If diffs is true, a diff tarball against the previous snapshot is
+// also created alongside each snapshot (e.g. diff-20250102T150405.000Z.tar.gz),
+// while any snapshots without differences to their predecessor are discarded.
+//
+// Any paths matching the excludes slice are skipped, as are any events within
+// the outputDir (so that it can be within the root). Failures of snapshots
+// after the initial one are printed to standard error and retried upon the
+// next interval, so that a long-running watch survives transient failures.
+func (prog *Program) Watch(ctx context.Context, root string, outputDir string, interval time.Duration, diffs bool, excludes []string) error {
+	if interval <= 0 {
+		return fmt.Errorf("%w: %s", errInvalidInterval, interval)
+	}
+
+	if err := prog.fs.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	absOutput, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	ws := &watchState{prog: prog, watcher: watcher, outputDir: absOutput}
+
+	if err := ws.add(root); err != nil {
+		return err
+	}
+
+	// The snapshots are excluded from themselves, if their directory is within the root.
+	if absRoot, err := filepath.Abs(root); err == nil {
+		if rel, err := filepath.Rel(absRoot, absOutput); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			rel = filepath.ToSlash(rel)
+			excludes = append(slices.Clip(excludes), rel, rel+"/**")
+		}
+	}
+
+	snap := &snapshotter{prog: prog, root: root, outputDir: outputDir, diffs: diffs, excludes: excludes}
+
+	if err := snap.snapshot(ctx); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var changed bool
+
+	for {
+		progressFrom(ctx).setPhase("watching %s", root)
+
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("failed to watch: %w", errWatcherClosed)
+			}
+
+			if ws.isOutput(event.Name) {
+				continue
+			}
+			changed = true
+
+			if event.Has(fsnotify.Create) {
+				if err := ws.add(event.Name); err != nil {
+					prog.warnf("%v", err)
+				}
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("failed to watch: %w", errWatcherClosed)
+			}
+
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				return fmt.Errorf("failed to watch: %w", err)
+			}
+			changed = true // Events were lost, so the tree is rescanned to be sure.
+
+		case <-ticker.C:
+			if !changed {
+				continue
+			}
+
+			if err := snap.snapshot(ctx); err != nil {
+				if ctx.Err() != nil {
+					return fmt.Errorf("failed to watch: %w", ctx.Err())
+				}
+				prog.warnf("%v (retrying)", err)
+
+				continue
+			}
+			changed = false
+
+		case <-ctx.Done():
+			return fmt.Errorf("failed to watch: %w", ctx.Err())
+		}
+	}
+}
+
+// watchState holds the directories being watched by a [Program.Watch] operation.
+type watchState struct {
+	prog      *Program
+	watcher   *fsnotify.Watcher
+	outputDir string // Absolute path of the output directory (not watched)
+}
+
+// isOutput returns if a path is within the output directory.
+func (ws *watchState) isOutput(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	return abs == ws.outputDir || strings.HasPrefix(abs, ws.outputDir+string(filepath.Separator))
+}
+
+// add watches a directory and all of its subdirectories (skipping the output
+// directory), as the watchers are not recursive. Paths of any non-directories
+// are ignored, as are any that vanished before they could be watched.
+func (ws *watchState) add(path string) error {
+	err := ws.prog.fsWalker.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if ws.isOutput(p) {
+			return filepath.SkipDir
+		}
+
+		return ws.watcher.Add(p) //nolint:wrapcheck
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch directory: %w", err)
+	}
+
+	return nil
+}
+
+// snapshotter creates the snapshots of a [Program.Watch] operation.
+type snapshotter struct {
+	prog      *Program
+	root      string
+	outputDir string
+	diffs     bool
+	excludes  []string
+	previous  string // Path of the previous snapshot (empty: none yet)
+}
+
+// snapshot creates a snapshot of the root (and a diff tarball, if enabled).
+// The files are written under a temporary name first, and only renamed once
+// complete, so that any consumers of the output directory never see partial
+// (or discarded) files, e.g. when serving the directory with [Program.Serve].
+func (s *snapshotter) snapshot(ctx context.Context) error {
+	stamp := time.Now().UTC().Format(snapshotTimeFormat)
+	output := filepath.Join(s.outputDir, "snapshot-"+stamp+".tar.gz")
+	diffOutput := filepath.Join(s.outputDir, "diff-"+stamp+".tar.gz")
+
+	quiet := *s.prog
+	quiet.stdout = io.Discard
+
+	if err := quiet.Create(ctx, s.root, output+snapshotTempSuffix, s.excludes); err != nil && !errors.Is(err, ErrPartialSuccess) {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer s.prog.fs.Remove(output + snapshotTempSuffix) //nolint:errcheck
+
+	hasDiff := false
+
+	if s.diffs && s.previous != "" {
+		_, err := quiet.Diff(ctx, s.previous, output+snapshotTempSuffix, diffOutput+snapshotTempSuffix, nil)
+		if err == nil {
+			return nil // Without any differences, the snapshot is redundant with the previous one.
+		} else if !errors.Is(err, ErrDiffsFound) {
+			return fmt.Errorf("failed to create diff: %w", err)
+		}
+		defer s.prog.fs.Remove(diffOutput + snapshotTempSuffix) //nolint:errcheck
+
+		hasDiff = true
+	}
+
+	if err := s.prog.fs.Rename(output+snapshotTempSuffix, output); err != nil {
+		return fmt.Errorf("failed to rename snapshot: %w", err)
+	}
+	s.prog.printPath(output)
+	s.previous = output
+
+	if hasDiff {
+		if err := s.prog.fs.Rename(diffOutput+snapshotTempSuffix, diffOutput); err != nil {
+			return fmt.Errorf("failed to rename diff: %w", err)
+		}
+		s.prog.printPath(diffOutput)
+	}
+
+	if s.prog.config.Fsync {
+		if err := syncDir(s.prog.fs, s.outputDir); err != nil { // The renames persist only then.
+			return err
+		}
+	}
+
+	_ = s.prog.flushOutput() // Printed as they are created, as the watch goes on.
+
+	return nil
+}
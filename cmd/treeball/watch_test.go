@@ -0,0 +1,146 @@
+//go:build !minimal && !no_watch
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper function for tests to return the names of the snapshots and diffs in a directory.
+func readSnapshotNames(t *testing.T, dir string) ([]string, []string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if !os.IsNotExist(err) {
+		require.NoError(t, err)
+	}
+
+	var snapshots, diffs []string
+
+	for _, e := range entries {
+		switch {
+		case strings.HasSuffix(e.Name(), snapshotTempSuffix):
+			continue
+		case strings.HasPrefix(e.Name(), "snapshot-"):
+			snapshots = append(snapshots, filepath.Join(dir, e.Name()))
+		case strings.HasPrefix(e.Name(), "diff-"):
+			diffs = append(diffs, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return snapshots, diffs
+}
+
+// A helper function for tests to run a watch in the background, returning its result channel.
+func startWatch(t *testing.T, ctx context.Context, root string, output string, diffs bool) <-chan error {
+	t.Helper()
+
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- prog.Watch(ctx, root, output, 50*time.Millisecond, diffs, nil)
+	}()
+
+	return errs
+}
+
+// Expectation: An initial snapshot should be created, and another one only upon changes.
+func Test_Program_Watch_Success(t *testing.T) {
+	root := t.TempDir()
+	output := filepath.Join(t.TempDir(), "snapshots")
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), nil, 0o644))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errs := startWatch(t, ctx, root, output, false)
+
+	require.Eventually(t, func() bool {
+		snapshots, _ := readSnapshotNames(t, output)
+
+		return len(snapshots) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	time.Sleep(200 * time.Millisecond) // No changes, so no further snapshots.
+	snapshots, _ := readSnapshotNames(t, output)
+	require.Len(t, snapshots, 1)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.txt"), nil, 0o644))
+
+	require.Eventually(t, func() bool {
+		snapshots, _ := readSnapshotNames(t, output)
+
+		return len(snapshots) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errs, context.Canceled)
+
+	snapshots, diffs := readSnapshotNames(t, output)
+	require.Empty(t, diffs)
+	require.Equal(t, []string{"a.txt", "b.txt"}, readTarNames(t, afero.NewOsFs(), snapshots[1]))
+}
+
+// Expectation: Changes within new subdirectories should be watched as well, with diffs created.
+func Test_Program_Watch_Diffs_Success(t *testing.T) {
+	root := t.TempDir()
+	output := filepath.Join(root, "snapshots") // Within the watched root.
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errs := startWatch(t, ctx, root, output, true)
+
+	require.Eventually(t, func() bool {
+		snapshots, _ := readSnapshotNames(t, output)
+
+		return len(snapshots) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.Mkdir(filepath.Join(root, "sub"), 0o755))
+
+	require.Eventually(t, func() bool {
+		_, diffs := readSnapshotNames(t, output)
+
+		return len(diffs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "b.txt"), nil, 0o644))
+
+	require.Eventually(t, func() bool {
+		_, diffs := readSnapshotNames(t, output)
+
+		return len(diffs) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errs, context.Canceled)
+
+	snapshots, diffs := readSnapshotNames(t, output)
+	require.Len(t, snapshots, 3)
+	require.Equal(t, []string{"+++/sub/"}, readTarNames(t, afero.NewOsFs(), diffs[0]))
+	require.Equal(t, []string{"+++/sub/b.txt"}, readTarNames(t, afero.NewOsFs(), diffs[1]))
+}
+
+// Expectation: An invalid interval should be returned as an error.
+func Test_Program_Watch_Interval_Error(t *testing.T) {
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.Watch(t.Context(), t.TempDir(), t.TempDir(), 0, false, nil)
+	require.ErrorIs(t, err, errInvalidInterval)
+}
+
+// Expectation: A missing root should be returned as an error.
+func Test_Program_Watch_MissingRoot_Error(t *testing.T) {
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, nil)
+
+	err := prog.Watch(t.Context(), filepath.Join(t.TempDir(), "missing"), t.TempDir(), time.Second, false, nil)
+	require.Error(t, err)
+}
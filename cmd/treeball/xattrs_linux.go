@@ -0,0 +1,6 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// errNoXattr is the error of reading an extended attribute that does not exist.
+const errNoXattr = unix.ENODATA
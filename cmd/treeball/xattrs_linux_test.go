@@ -0,0 +1,89 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// A helper function for tests to create a tree with an extended attribute,
+// skipping the test for any filesystems not supporting user attributes.
+func createXattrTree(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.txt"), nil, 0o644))
+
+	if err := unix.Setxattr(file, "user.label", []byte("public"), 0); errors.Is(err, unix.ENOTSUP) {
+		t.Skip("no support for extended attributes in the temporary directory")
+	} else {
+		require.NoError(t, err)
+	}
+
+	return root
+}
+
+// Expectation: The extended attributes should be captured, and their drift detected.
+func Test_Program_Create_Xattrs_Success(t *testing.T) {
+	root := createXattrTree(t)
+	out := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{Xattrs: true})
+	require.NoError(t, prog.Create(t.Context(), root, out, nil))
+
+	f, err := os.Open(out)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	records := make(map[string]map[string]string)
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		records[hdr.Name] = hdr.PAXRecords
+	}
+
+	require.Equal(t, "public", records["a.txt"][paxXattrPrefix+"user.label"])
+	require.NotContains(t, records["b.txt"], paxXattrPrefix+"user.label")
+
+	_, err = prog.Check(t.Context(), out, []string{root}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, unix.Setxattr(filepath.Join(root, "a.txt"), "user.label", []byte("private"), 0))
+
+	var stdoutBuf bytes.Buffer
+
+	prog = NewProgram(afero.NewOsFs(), &stdoutBuf, io.Discard, nil, nil, &ProgramConfig{Xattrs: true})
+	_, err = prog.Check(t.Context(), out, []string{root}, nil)
+	require.ErrorIs(t, err, ErrDiffsFound)
+	require.Equal(t, "~~~ a.txt\nremoved: 1, added: 1\n", stdoutBuf.String())
+}
+
+// Expectation: The metadata should not be captured in tar formats without PAX records.
+func Test_Program_Create_XattrsFormat_Error(t *testing.T) {
+	root := createXattrTree(t)
+	out := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	prog := NewProgram(afero.NewOsFs(), io.Discard, io.Discard, nil, nil, &ProgramConfig{Xattrs: true, TarFormat: tar.FormatGNU})
+	require.ErrorContains(t, prog.Create(t.Context(), root, out, nil), "not representable in the gnu format")
+}
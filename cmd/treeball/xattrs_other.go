@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd
+
+package main
+
+import "errors"
+
+var errXattrsUnsupported = errors.New("extended attributes are not supported on this platform")
+
+// listXattrs is a stub for platforms without support for extended attributes.
+// It always returns an error, as no metadata can be captured on such platforms.
+func listXattrs(_ string) (map[string][]byte, error) {
+	return nil, errXattrsUnsupported
+}
@@ -0,0 +1,68 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package main
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// listXattrs returns the extended attributes of a file (not following any
+// symbolic links), or none for filesystems not supporting them at all.
+func listXattrs(p string) (map[string][]byte, error) {
+	size, err := unix.Llistxattr(p, nil)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if size == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, size)
+
+	size, err = unix.Llistxattr(p, buf)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	attrs := make(map[string][]byte)
+
+	for name := range bytes.SplitSeq(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+
+		value, err := getXattr(p, string(name))
+		if errors.Is(err, errNoXattr) {
+			continue // Removed in the meantime.
+		} else if err != nil {
+			return nil, err
+		}
+
+		attrs[string(name)] = value
+	}
+
+	return attrs, nil
+}
+
+// getXattr returns the value of an extended attribute of a file.
+func getXattr(p string, name string) ([]byte, error) {
+	size, err := unix.Lgetxattr(p, name, nil)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	value := make([]byte, size)
+
+	size, err = unix.Lgetxattr(p, name, value)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return value[:size], nil
+}
@@ -0,0 +1,174 @@
+// Package mktree implements the creation of synthetic trees (for benchmarking).
+//
+//nolint:mnd
+package mktree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// ErrInvalidConfig is returned for a [Config] that cannot produce a valid tree.
+var ErrInvalidConfig = errors.New("invalid tree configuration")
+
+// Config is the configuration for the layout of a synthetic tree.
+type Config struct {
+	FilesPerDir int    // Files per directory (in the innermost directories)
+	Depth       int    // Levels of directories above the files
+	NamePattern string // Pattern of the file names (formatted with the index in the directory)
+}
+
+// DefaultConfig is the default configuration for the layout of a synthetic tree.
+var DefaultConfig = Config{
+	FilesPerDir: 100,
+	Depth:       4,
+	NamePattern: "data_%06d.txt",
+}
+
+// levelNames are the names of the innermost levels of directories (from the innermost),
+// with any further levels (above those) named after their level instead (e.g. level4).
+var levelNames = []string{"group_%06d", "batch_%04d", "proj_%03d", "dept_%02d"}
+
+var workers = runtime.GOMAXPROCS(0) * 2
+
+// Validate returns an error wrapping [ErrInvalidConfig] if the [Config] is invalid.
+func (c Config) Validate() error {
+	if c.FilesPerDir <= 0 {
+		return fmt.Errorf("%w: files per directory must be positive: %d", ErrInvalidConfig, c.FilesPerDir)
+	}
+
+	if c.Depth <= 0 {
+		return fmt.Errorf("%w: depth must be positive: %d", ErrInvalidConfig, c.Depth)
+	}
+
+	first, second := fmt.Sprintf(c.NamePattern, 0), fmt.Sprintf(c.NamePattern, 1)
+
+	if first == second || strings.Contains(first, "%!") || strings.ContainsAny(first, `/\`) {
+		return fmt.Errorf("%w: name pattern must format the index into unique file names: %q", ErrInvalidConfig, c.NamePattern)
+	}
+
+	return nil
+}
+
+func (c Config) buildPath(base string, d int) string {
+	elems := make([]string, 0, c.Depth+1)
+	elems = append(elems, base)
+
+	for level := c.Depth - 1; level >= 0; level-- {
+		index := d / int(math.Pow10(min(level, 18)))
+
+		if level < len(levelNames) {
+			elems = append(elems, fmt.Sprintf(levelNames[level], index))
+		} else {
+			elems = append(elems, fmt.Sprintf("level%d_%02d", level, index))
+		}
+	}
+
+	return filepath.Join(elems...)
+}
+
+func (c Config) createDirAndFiles(ctx context.Context, fs afero.Fs, base string, d int, totalFiles int) error {
+	subdir := c.buildPath(base, d)
+
+	if err := fs.MkdirAll(subdir, 0o755); err != nil {
+		return fmt.Errorf("error creating dir: %w", err)
+	}
+
+	for f := range c.FilesPerDir {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("error during creation: %w", err)
+		}
+
+		index := d*c.FilesPerDir + f
+		if index >= totalFiles {
+			break
+		}
+
+		fileName := fmt.Sprintf(c.NamePattern, f)
+		path := filepath.Join(subdir, fileName)
+
+		fh, err := fs.Create(path)
+		if err != nil {
+			return fmt.Errorf("error creating file: %w", err)
+		}
+		_ = fh.Close()
+	}
+
+	return nil
+}
+
+// CreateTree creates a synthetic tree of totalFiles (empty) files under base.
+// The files are spread across directories of the configured amount of files
+// each, which are nested in the configured levels of directories (with any nil
+// config substituted by [DefaultConfig]). The ctx parameter controls early
+// cancellation.
+func CreateTree(ctx context.Context, fs afero.Fs, base string, totalFiles int, config *Config) error {
+	var once sync.Once
+	var wg sync.WaitGroup
+
+	cfg := DefaultConfig
+	if config != nil {
+		cfg = *config
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tasks := make(chan int, workers)
+	errCh := make(chan error, 1)
+
+	dirsNeeded := (totalFiles + cfg.FilesPerDir - 1) / cfg.FilesPerDir
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range tasks {
+				if err := cfg.createDirAndFiles(ctx, fs, base, d, totalFiles); err != nil {
+					once.Do(func() {
+						errCh <- err
+						cancel()
+					})
+
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(tasks)
+		for d := range dirsNeeded {
+			select {
+			case tasks <- d:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errCh)
+
+	if err, ok := <-errCh; ok && err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error during creation: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package mktree
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// A helper filesystem for tests to simulate filesystem errors.
+type failingFs struct {
+	afero.Fs
+
+	failMkdirAll bool
+	failCreate   bool
+}
+
+// A helper function for tests to simulate folder creation failure.
+func (f *failingFs) MkdirAll(path string, perm os.FileMode) error {
+	if f.failMkdirAll {
+		return errors.New("simulated mkdirall error")
+	}
+
+	return f.Fs.MkdirAll(path, perm) //nolint:wrapcheck
+}
+
+// A helper function for tests to simulate file creation failure.
+func (f *failingFs) Create(name string) (afero.File, error) {
+	if f.failCreate {
+		return nil, errors.New("simulated create error")
+	}
+
+	return f.Fs.Create(name) //nolint:wrapcheck
+}
+
+// Expectation: The requested tree should be produced without errors.
+func Test_CreateTree_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	base := "/testroot"
+	totalFiles := 250
+	expectedDepth := 5 // dept/proj/batch/group/file
+
+	err := CreateTree(t.Context(), fs, base, totalFiles, nil)
+	require.NoError(t, err)
+
+	var fileCount int
+	err = afero.Walk(fs, base, func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+
+		if info.Mode().IsRegular() {
+			fileCount++
+
+			require.True(t, strings.HasPrefix(info.Name(), "data_") && strings.HasSuffix(info.Name(), ".txt"))
+
+			relPath, relErr := filepath.Rel(base, path)
+			require.NoError(t, relErr)
+
+			depth := len(strings.Split(relPath, string(filepath.Separator)))
+			require.Equal(t, expectedDepth, depth)
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, totalFiles, fileCount)
+}
+
+// Expectation: The requested tree creation should fail with the correct error.
+func Test_CreateTree_MkDirAll_Error(t *testing.T) {
+	fs := &failingFs{
+		Fs:           afero.NewMemMapFs(),
+		failMkdirAll: true,
+	}
+
+	err := CreateTree(t.Context(), fs, "/fail", 100000, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "mkdirall")
+}
+
+// Expectation: The requested tree creation should fail with the correct error.
+func Test_CreateTree_CreateFile_Error(t *testing.T) {
+	fs := &failingFs{
+		Fs:         afero.NewMemMapFs(),
+		failCreate: true,
+	}
+
+	err := CreateTree(t.Context(), fs, "/fail", 100000, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "creating file")
+}
+
+// Expectation: The requested tree creation should respect a cancellation.
+func Test_CreateTree_CtxCancel_Error(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	err := CreateTree(ctx, fs, "/tree", 100000, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// Expectation: The requested tree should be produced with the configured layout.
+func Test_CreateTree_Config_Success(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	cfg := &Config{FilesPerDir: 2, Depth: 6, NamePattern: "IMG_%02d.jpg"}
+	require.NoError(t, CreateTree(t.Context(), fs, "/tree", 3, cfg))
+
+	for _, path := range []string{
+		"/tree/level5_00/level4_00/dept_00/proj_000/batch_0000/group_000000/IMG_00.jpg",
+		"/tree/level5_00/level4_00/dept_00/proj_000/batch_0000/group_000000/IMG_01.jpg",
+		"/tree/level5_00/level4_00/dept_00/proj_000/batch_0000/group_000001/IMG_00.jpg",
+	} {
+		exists, err := afero.Exists(fs, path)
+		require.NoError(t, err)
+		require.True(t, exists, path)
+	}
+
+	exists, err := afero.Exists(fs, "/tree/level5_00/level4_00/dept_00/proj_000/batch_0000/group_000001/IMG_01.jpg")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+// Expectation: The innermost levels of directories should be named after the default layout.
+func Test_Config_buildPath_Table(t *testing.T) {
+	tests := []struct {
+		depth int
+		d     int
+		want  string
+	}{
+		{1, 12, "/base/group_000012"},
+		{2, 12, "/base/batch_0001/group_000012"},
+		{4, 1234, "/base/dept_01/proj_012/batch_0123/group_001234"},
+		{5, 12345, "/base/level4_01/dept_12/proj_123/batch_1234/group_012345"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			cfg := DefaultConfig
+			cfg.Depth = tt.depth
+
+			require.Equal(t, filepath.FromSlash(tt.want), cfg.buildPath("/base", tt.d))
+		})
+	}
+}
+
+// Expectation: An invalid configuration should be rejected before creating anything.
+func Test_CreateTree_InvalidConfig_Table(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"no files per dir", Config{FilesPerDir: 0, Depth: 4, NamePattern: "f_%d"}},
+		{"no depth", Config{FilesPerDir: 10, Depth: 0, NamePattern: "f_%d"}},
+		{"no verb", Config{FilesPerDir: 10, Depth: 4, NamePattern: "file.txt"}},
+		{"wrong verb", Config{FilesPerDir: 10, Depth: 4, NamePattern: "f_%s_%d"}},
+		{"separator", Config{FilesPerDir: 10, Depth: 4, NamePattern: "sub/f_%d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			err := CreateTree(t.Context(), fs, "/tree", 100, &tt.cfg)
+			require.ErrorIs(t, err, ErrInvalidConfig)
+
+			exists, err := afero.Exists(fs, "/tree")
+			require.NoError(t, err)
+			require.False(t, exists)
+		})
+	}
+}